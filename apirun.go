@@ -3,23 +3,33 @@ package apirun
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/loykin/apirun/internal/auth"
+	"github.com/loykin/apirun/internal/auth/ntlm"
 	"github.com/loykin/apirun/internal/common"
 	imig "github.com/loykin/apirun/internal/migration"
 	"github.com/loykin/apirun/internal/store"
 	"github.com/loykin/apirun/internal/task"
 	"github.com/loykin/apirun/internal/util"
 	"github.com/loykin/apirun/pkg/env"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const DriverSqlite = store.DriverSqlite
 const DriverPostgresql = store.DriverPostgresql
+const DriverMongo = store.DriverMongo
+
+// DefaultVersionPattern is the migration file name pattern used when Migrator.VersionPattern is empty.
+const DefaultVersionPattern = imig.DefaultVersionPattern
 
 type DriverConfig interface {
 	ToMap() map[string]interface{}
@@ -28,11 +38,23 @@ type DriverConfig interface {
 type SqliteConfig = store.SqliteConfig
 type PostgresConfig = store.PostgresConfig
 
+// MongoConfig configures the MongoDB store: the connection URI and the database holding the
+// schema_migrations, migration_runs and stored_env collections.
+type MongoConfig = store.MongoConfig
+
 type TableNames = store.TableNames
 type StoreConfig struct {
 	store.Config
 }
 
+// StoredEnvLimits caps the env stored per version and sets the rows per insert statement
+// (StoreConfig.StoredEnv); zero values use the defaults.
+type StoredEnvLimits = store.StoredEnvLimits
+
+// DefaultLockTimeout is how long a run waits for the store's run lock when
+// StoreConfig.LockTimeout is not set.
+const DefaultLockTimeout = store.DefaultLockTimeout
+
 // NewPostgresStoreConfig creates a StoreConfig for PostgreSQL with the given driver config and table names
 func NewPostgresStoreConfig(driverConfig *PostgresConfig, tableNames TableNames) *StoreConfig {
 	return &StoreConfig{
@@ -44,6 +66,18 @@ func NewPostgresStoreConfig(driverConfig *PostgresConfig, tableNames TableNames)
 	}
 }
 
+// NewMongoStoreConfig creates a StoreConfig for MongoDB with the given driver config and table
+// (collection) names
+func NewMongoStoreConfig(driverConfig *MongoConfig, tableNames TableNames) *StoreConfig {
+	return &StoreConfig{
+		Config: store.Config{
+			Driver:       DriverMongo,
+			DriverConfig: driverConfig,
+			TableNames:   tableNames,
+		},
+	}
+}
+
 // NewSqliteStoreConfig creates a StoreConfig for SQLite with the given driver config and table names
 func NewSqliteStoreConfig(driverConfig *SqliteConfig, tableNames TableNames) *StoreConfig {
 	return &StoreConfig{
@@ -55,6 +89,17 @@ func NewSqliteStoreConfig(driverConfig *SqliteConfig, tableNames TableNames) *St
 	}
 }
 
+// NewCustomStoreConfig creates a StoreConfig for a driver registered with RegisterStoreDriver
+func NewCustomStoreConfig(driver string, options StoreOptions, tableNames TableNames) *StoreConfig {
+	return &StoreConfig{
+		Config: store.Config{
+			Driver:       strings.ToLower(strings.TrimSpace(driver)),
+			DriverConfig: options,
+			TableNames:   tableNames,
+		},
+	}
+}
+
 // Migrator is the root struct-based API to run migrations programmatically.
 // Users can create it, set Store and Env, then call its methods.
 //
@@ -66,14 +111,42 @@ func NewSqliteStoreConfig(driverConfig *SqliteConfig, tableNames TableNames) *St
 // tokenStore is reserved for future enhancements and currently unused.
 // It is kept to match the internal design and to allow future per-migrator token scoping.
 // Exported fields mirror the user's configuration surface.
+//
+// A Migrator, including its zero value, is not safe for concurrent use. To run migrations in
+// parallel (e.g. one run per tenant), give each goroutine its own Clone.
 // #nosec G101 -- field name isn't a credential
 type Migrator struct {
-	Dir              string
+	// Dir is the migration directory; when FS is set it is a path within FS.
+	Dir string
+	// FS, when set (e.g. an embed.FS), is used to read migration files and body_file references.
+	// The default SQLite store is then created as StoreDBFileName in the working directory.
+	FS               fs.FS
 	store            Store
 	Env              *env.Env
 	Auth             []auth.Auth
 	StoreConfig      *StoreConfig
 	SaveResponseBody bool
+	// EnvPrecedence orders the env layers merged into {{.env.x}}, the first winning: "local" (a
+	// migration's env block and stored env_from values) and "global" (Env), each once. nil keeps
+	// local over global. {{.local.x}} and {{.global.x}} always read a single layer.
+	EnvPrecedence []string
+	// EnvFiles are YAML (.yaml/.yml), JSON (.json) or dotenv (.env) files loaded into the global env
+	// before each run, later files overriding earlier ones; values set in Env.Global override them.
+	// A file that cannot be read or parsed fails the run.
+	EnvFiles []string
+	// RecordDir, when set, receives a JSON fixture per executed up/down step, e.g. "3_up.json"
+	// (see StepRecording), with the masked requests and full responses. Dry runs write nothing.
+	RecordDir string
+	// MaxResponseBodyBytes bounds response bodies (0 = unbounded): saved bodies are truncated to
+	// it with a marker, and a body more than ResponseLimitMargin over it fails the step unread.
+	MaxResponseBodyBytes int64
+	// SaveResponseHeaders records the response headers named in an up step's header_from with
+	// its run history (see RunHistory.Headers); header_from values reach env either way.
+	SaveResponseHeaders bool
+	// EnableCookies shares a cookie jar between the requests of a run, so that a session cookie
+	// set by a login migration is sent by later ones. It lasts for one MigrateUp, MigrateDown or
+	// Redo call only.
+	EnableCookies bool
 	// RenderBodyDefault controls default templating for RequestSpec bodies (nil = default true)
 	RenderBodyDefault *bool
 	// DryRun disables store mutations and simulates applied versions from DryRunFrom.
@@ -82,87 +155,269 @@ type Migrator struct {
 	DryRunFrom int
 	// TLSConfig applies to all HTTP requests executed during migrations
 	TLSConfig *tls.Config
+	// CAFile is a PEM bundle of private CAs to verify server certificates against instead of the
+	// system roots. A file that cannot be loaded fails the run before any request is sent.
+	CAFile string
+	// RootCAs is an in-memory pool of CAs replacing the system roots; CAFile adds to it.
+	RootCAs *x509.CertPool
+	// Transport tunes the HTTP transport: HTTP/2, idle connection pool and keep-alives (nil = defaults).
+	Transport *TransportConfig
 	// DelayBetweenMigrations configures the delay between migration executions for backend consistency.
 	// If not set, defaults to 1 second. Set to 0 to disable delays.
 	DelayBetweenMigrations time.Duration
+	// RetryPolicy configures retries for transient HTTP failures (network errors, 502/503, ...).
+	// Requests may override it with their own `retry` block. nil uses the client default.
+	RetryPolicy *RetryPolicy
+	// TokenExpirySkew re-acquires cached auth tokens this long before they expire (0 = 30s default).
+	TokenExpirySkew time.Duration
+	// BeforeStep and AfterStep are called around every executed up/down step (not in dry-run mode).
+	// A BeforeStep error aborts the step; AfterStep errors are logged unless FailOnAfterHookError is set.
+	BeforeStep           BeforeStepFunc
+	AfterStep            AfterStepFunc
+	FailOnAfterHookError bool
+	// MaxParallel runs up to N migrations concurrently when their depends_on allow it (0/1 = sequential).
+	MaxParallel int
+	// ContinueOnError keeps applying later versions when a sequential up step fails; MigrateUp then
+	// returns a *MigrationErrors and the store version stops before the first failure.
+	ContinueOnError bool
+	// StrictChecksums fails MigrateUp when an applied migration file was edited after it was applied;
+	// by default such drift is only logged.
+	StrictChecksums bool
+	// VersionPattern overrides the migration file name pattern; its first capture group is the
+	// integer version, e.g. `^V(\d+)__.*\.ya?ml$` ("" = DefaultVersionPattern).
+	VersionPattern string
+	// RollbackOnCancel rolls back the versions applied by a MigrateUp call whose context is
+	// cancelled mid-run (e.g. on ctrl-C), newest first, before it returns. See docs for the risks.
+	RollbackOnCancel bool
+	// DefaultHeaders are sent with every request (templated); per-request headers override them.
+	DefaultHeaders map[string]string
+	// AuthInjection adds an acquired auth as a header (e.g. "Bearer {{.auth.main}}") to every
+	// request; per-request headers of the same name override it.
+	AuthInjection *AuthInjection
+	// Notify posts a summary to a webhook after MigrateUp/MigrateDown; failures are only logged.
+	Notify *NotifyConfig
+	// Tags limits MigrateUp/Plan to migrations tagged with one of them. The run stops at the first
+	// pending migration without a selected tag unless TagsAllowGaps is set, which skips it instead.
+	Tags          []string
+	TagsAllowGaps bool
+	// TracerProvider, when set, emits OpenTelemetry spans for MigrateUp/MigrateDown runs, each
+	// executed step and each HTTP request. nil (the default) disables tracing.
+	TracerProvider trace.TracerProvider
+	// RequestMiddleware runs, in order, before each attempt of every up, down, find and probe
+	// request, after headers, auth and body are set (e.g. to sign requests). ResponseMiddleware
+	// runs, in order, on each response before its status is validated (e.g. for metrics).
+	RequestMiddleware  []func(*resty.Request)
+	ResponseMiddleware []func(*resty.Response)
+	// ContextKeys exposes values of the run's context to templates: name -> context key, rendered
+	// as {{.ctx.name}}. A value missing from the context renders empty, or fails the step when its
+	// response sets env_missing: fail.
+	ContextKeys map[string]interface{}
+	// RunID correlates one run: it renders as {{.run_id}}, is added to every log line of the run
+	// and is recorded with its run history entries. Empty (the default) generates a UUID for each
+	// MigrateUp, MigrateDown, MigrateTo or Redo call.
+	RunID string
+	// CircuitBreaker, when set, fails requests fast for a cooldown period once a host has failed
+	// repeatedly (network errors, 5xx). It is independent of RetryPolicy.
+	CircuitBreaker *CircuitBreakerConfig
+	// tokenCache keeps acquired auth tokens across MigrateUp/MigrateDown calls on this Migrator.
+	tokenCache *auth.TokenCache
+	// breaker keeps the circuit state across MigrateUp/MigrateDown calls on this Migrator.
+	breaker *task.CircuitBreaker
+	// ntlmCredentials keeps the NTLM credentials acquired on this Migrator across calls, like
+	// the tokens in tokenCache that reference them.
+	ntlmCredentials *ntlm.Credentials
+	// memoryStore names the unnamed in-memory sqlite database of StoreConfig, so that every
+	// connection made by this Migrator opens the same database.
+	memoryStore string
 }
 
 // MigrateUp applies pending migrations up to targetVersion (0 = all) using this Migrator's Store and Env.
 func (m *Migrator) MigrateUp(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.MigrateUp(ctx, targetVersion)
+}
+
+// MigrateTo migrates up or down so that exactly targetVersion is applied (0 = fully rolled back).
+// It is a no-op when the store is already at targetVersion.
+func (m *Migrator) MigrateTo(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.MigrateTo(ctx, targetVersion)
+}
+
+// Redo rolls back the highest applied version and applies it again (e.g. after editing it).
+// The migration must have a down section.
+func (m *Migrator) Redo(ctx context.Context) ([]*ExecWithVersion, error) {
+	return m.RedoVersion(ctx, 0)
+}
+
+// RedoVersion rolls back and re-applies the given applied version (0 = highest applied).
+func (m *Migrator) RedoVersion(ctx context.Context, version int) ([]*ExecWithVersion, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.Redo(ctx, version)
+}
+
+// Baseline marks the migrations up to targetVersion as applied without sending their requests,
+// for adopting apirun on an existing system. It records a run with direction DirectionBaseline per
+// version and fails when one of them is already applied. It returns the baselined versions.
+func (m *Migrator) Baseline(ctx context.Context, targetVersion int) ([]int, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.Baseline(ctx, targetVersion)
+}
+
+// Plan returns the pending migrations MigrateUp(ctx, 0) would apply, without sending requests
+// or recording anything.
+func (m *Migrator) Plan(ctx context.Context) (*MigrationPlan, error) {
+	return m.PlanUp(ctx, 0)
+}
+
+// PlanUp returns the migrations MigrateUp(ctx, targetVersion) would apply, in order.
+func (m *Migrator) PlanUp(ctx context.Context, targetVersion int) (*MigrationPlan, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.PlanUp(ctx, targetVersion)
+}
+
+// PlanDown returns the migrations MigrateDown(ctx, targetVersion) would roll back, newest first.
+func (m *Migrator) PlanDown(ctx context.Context, targetVersion int) (*MigrationPlan, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.PlanDown(ctx, targetVersion)
+}
+
+// PlanDiff returns the migrations MigrateUp(ctx, targetVersion) would apply, each marked
+// would-apply, already-satisfied or unknown from its up.probe. Only the read-only probes are
+// sent; nothing is recorded.
+func (m *Migrator) PlanDiff(ctx context.Context, targetVersion int) (*PlanDiff, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.PlanDiff(ctx, targetVersion)
+}
+
+// Smoke runs the read-only steps of the migrations (GET/HEAD or readonly-tagged up requests, and
+// probes) against each of opts.Targets concurrently and reports the outcome per target. The store
+// is not used.
+func (m *Migrator) Smoke(ctx context.Context, opts SmokeOptions) (*SmokeReport, error) {
+	im := m.internalMigrator()
+	return im.Smoke(ctx, opts)
+}
+
+// VerifyChecksums reports applied versions whose migration file changed since they were applied.
+func (m *Migrator) VerifyChecksums(ctx context.Context) ([]ChecksumDrift, error) {
+	if err := m.connectStore(); err != nil {
+		return nil, err
+	}
+	im := m.internalMigrator()
+	return im.VerifyChecksums(ctx)
+}
+
+// connectStore opens the configured store, defaulting to sqlite under m.Dir.
+func (m *Migrator) connectStore() error {
 	if m.StoreConfig != nil {
-		// Support wrapper StoreConfig, direct store.Config, and direct driver configs
-		cfg := m.StoreConfig
-		if strings.TrimSpace(cfg.Driver) == "" {
-			// Infer driver from DriverConfig type when not explicitly set
-			switch cfg.DriverConfig.(type) {
-			case *store.PostgresConfig:
-				cfg.Driver = DriverPostgresql
-			default:
-				cfg.Driver = DriverSqlite
-			}
+		if sc, ok := m.StoreConfig.DriverConfig.(*store.SqliteConfig); ok && sc.Memory && m.memoryStore == "" {
+			m.memoryStore = newMemoryStoreName()
 		}
-		// For sqlite, ensure default file path under m.Dir when missing
-		if strings.EqualFold(cfg.Driver, DriverSqlite) {
-			if sc, ok := cfg.DriverConfig.(*store.SqliteConfig); ok {
-				if strings.TrimSpace(sc.Path) == "" {
-					sc.Path = filepath.Join(m.Dir, StoreDBFileName)
-				}
-			}
+		cfg, err := connectConfig(m.StoreConfig.Config, m.defaultSQLitePath(), m.memoryStore, m.Env)
+		if err != nil {
+			return err
 		}
 		// Apply custom table names before connecting so EnsureSchema uses them
 		m.store.TableName = cfg.TableNames
-		if err := m.store.Connect(cfg.Config); err != nil {
-			return nil, err
+		return m.store.Connect(cfg)
+	}
+	// default sqlite under dir
+	wrapper := store.Config{Driver: DriverSqlite,
+		TableNames:   m.store.TableName,
+		DriverConfig: &store.SqliteConfig{Path: m.defaultSQLitePath()}}
+	return m.store.Connect(wrapper)
+}
+
+// connectConfig returns a copy of cfg to connect with: the driver is inferred from DriverConfig
+// when not set, and a sqlite path defaults to defaultPath and is rendered by renderSqlitePath.
+// An unnamed in-memory database is named memoryName, or a new name when that is empty. cfg and
+// its DriverConfig are left as they are.
+func connectConfig(cfg store.Config, defaultPath, memoryName string, e *env.Env) (store.Config, error) {
+	if strings.TrimSpace(cfg.Driver) == "" {
+		// Infer driver from DriverConfig type when not explicitly set
+		switch cfg.DriverConfig.(type) {
+		case *store.PostgresConfig:
+			cfg.Driver = DriverPostgresql
+		case *store.MongoConfig:
+			cfg.Driver = DriverMongo
+		default:
+			cfg.Driver = DriverSqlite
 		}
-	} else {
-		// default sqlite under dir
-		wrapper := store.Config{Driver: DriverSqlite,
-			TableNames:   m.store.TableName,
-			DriverConfig: &store.SqliteConfig{Path: filepath.Join(m.Dir, StoreDBFileName)}}
-		if err := m.store.Connect(wrapper); err != nil {
-			return nil, err
+	}
+	if strings.EqualFold(cfg.Driver, DriverSqlite) {
+		if sc, ok := cfg.DriverConfig.(*store.SqliteConfig); ok {
+			local := *sc
+			switch {
+			case local.Memory && strings.TrimSpace(local.Path) == "":
+				if local.Path = memoryName; local.Path == "" {
+					local.Path = newMemoryStoreName()
+				}
+			case !local.Memory:
+				if strings.TrimSpace(local.Path) == "" {
+					local.Path = defaultPath
+				}
+				path, err := renderSqlitePath(local.Path, e)
+				if err != nil {
+					return cfg, err
+				}
+				local.Path = path
+			}
+			cfg.DriverConfig = &local
 		}
 	}
+	return cfg, nil
+}
 
-	im := imig.Migrator{Dir: m.Dir, Store: m.store, Env: m.Env, Auth: m.Auth, SaveResponseBody: m.SaveResponseBody, RenderBodyDefault: m.RenderBodyDefault, DryRun: m.DryRun, DryRunFrom: m.DryRunFrom, TLSConfig: m.TLSConfig, DelayBetweenMigrations: m.DelayBetweenMigrations}
-	return im.MigrateUp(ctx, targetVersion)
+// defaultSQLitePath is the sqlite file used when no path is configured: under Dir, or in the
+// working directory when migrations are read from an FS (which cannot hold the database).
+func (m *Migrator) defaultSQLitePath() string {
+	if m.FS != nil {
+		return StoreDBFileName
+	}
+	return filepath.Join(m.Dir, StoreDBFileName)
+}
+
+// internalMigrator builds the internal migrator from this Migrator's configuration.
+func (m *Migrator) internalMigrator() imig.Migrator {
+	if m.tokenCache == nil {
+		m.tokenCache = auth.NewTokenCache()
+	}
+	if m.ntlmCredentials == nil {
+		m.ntlmCredentials = ntlm.NewCredentials()
+	}
+	if m.breaker == nil && m.CircuitBreaker != nil {
+		m.breaker = task.NewCircuitBreaker(*m.CircuitBreaker)
+	}
+	return imig.Migrator{Dir: m.Dir, FS: m.FS, Store: m.store, Env: m.Env, EnvPrecedence: m.EnvPrecedence, EnvFiles: m.EnvFiles, Auth: m.Auth, SaveResponseBody: m.SaveResponseBody, RecordDir: m.RecordDir, SaveResponseHeaders: m.SaveResponseHeaders, EnableCookies: m.EnableCookies, MaxResponseBodyBytes: m.MaxResponseBodyBytes, RenderBodyDefault: m.RenderBodyDefault, DryRun: m.DryRun, DryRunFrom: m.DryRunFrom, TLSConfig: m.TLSConfig, CAFile: m.CAFile, RootCAs: m.RootCAs, Transport: m.Transport, DelayBetweenMigrations: m.DelayBetweenMigrations, RetryPolicy: m.RetryPolicy, TokenCache: m.tokenCache, TokenExpirySkew: m.TokenExpirySkew, BeforeStep: m.BeforeStep, AfterStep: m.AfterStep, FailOnAfterHookError: m.FailOnAfterHookError, MaxParallel: m.MaxParallel, ContinueOnError: m.ContinueOnError, StrictChecksums: m.StrictChecksums, VersionPattern: m.VersionPattern, RollbackOnCancel: m.RollbackOnCancel, DefaultHeaders: m.DefaultHeaders, AuthInjection: m.AuthInjection, Notify: m.Notify, Tags: m.Tags, TagsAllowGaps: m.TagsAllowGaps, TracerProvider: m.TracerProvider, RequestMiddleware: m.RequestMiddleware, ResponseMiddleware: m.ResponseMiddleware, ContextKeys: m.ContextKeys, RunID: m.RunID, CircuitBreaker: m.CircuitBreaker, Breaker: m.breaker, NTLMCredentials: m.ntlmCredentials}
 }
 
 // MigrateDown rolls back applied migrations down to targetVersion using this Migrator's Store and Env.
 func (m *Migrator) MigrateDown(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
-	if m.StoreConfig != nil {
-		// Support wrapper StoreConfig, direct store.Config, and direct driver configs
-		cfg := m.StoreConfig
-		if strings.TrimSpace(cfg.Driver) == "" {
-			// Infer driver from DriverConfig type when not explicitly set
-			switch cfg.DriverConfig.(type) {
-			case *store.PostgresConfig:
-				cfg.Driver = DriverPostgresql
-			default:
-				cfg.Driver = DriverSqlite
-			}
-		}
-		// For sqlite, ensure default file path under m.Dir when missing
-		if strings.EqualFold(cfg.Driver, DriverSqlite) {
-			if sc, ok := cfg.DriverConfig.(*store.SqliteConfig); ok {
-				if strings.TrimSpace(sc.Path) == "" {
-					sc.Path = filepath.Join(m.Dir, StoreDBFileName)
-				}
-			}
-		}
-		// Apply custom table names before connecting so EnsureSchema uses them
-		m.store.TableName = cfg.TableNames
-		if err := m.store.Connect(cfg.Config); err != nil {
-			return nil, err
-		}
-	} else if m.store.DB == nil {
-		// default sqlite under dir
-		wrapper := store.Config{Driver: DriverSqlite, DriverConfig: &store.SqliteConfig{Path: filepath.Join(m.Dir, StoreDBFileName)}}
-		if err := m.store.Connect(wrapper); err != nil {
-			return nil, err
-		}
+	if err := m.connectStore(); err != nil {
+		return nil, err
 	}
-	im := imig.Migrator{Dir: m.Dir, Store: m.store, Env: m.Env, Auth: m.Auth, SaveResponseBody: m.SaveResponseBody, RenderBodyDefault: m.RenderBodyDefault, DryRun: m.DryRun, DryRunFrom: m.DryRunFrom, TLSConfig: m.TLSConfig, DelayBetweenMigrations: m.DelayBetweenMigrations}
+	im := m.internalMigrator()
 	return im.MigrateDown(ctx, targetVersion)
 }
 
@@ -171,12 +426,179 @@ func (m *Migrator) MigrateDown(ctx context.Context, targetVersion int) ([]*ExecW
 // ExecResult is the result of a single task execution.
 type ExecResult = task.ExecResult
 
+// ExecError describes why a step whose request got a response failed (ExecResult.Error).
+type ExecError = task.ExecError
+
+// Reasons reported by ExecError.Reason.
+const (
+	ReasonUnexpectedStatus = task.ReasonUnexpectedStatus
+	ReasonAssertion        = task.ReasonAssertion
+	ReasonEnvMissing       = task.ReasonEnvMissing
+)
+
+// RetryPolicy configures retries with exponential backoff for transient HTTP failures.
+type RetryPolicy = task.RetryPolicy
+
+// TransportConfig tunes the HTTP transport of migration requests; zero fields keep the defaults.
+type TransportConfig = task.TransportConfig
+
+// CircuitBreakerConfig configures the per-host circuit breaker of migration requests: after
+// FailureThreshold consecutive failures (within Window) a host fails fast for Cooldown.
+type CircuitBreakerConfig = task.CircuitBreakerConfig
+
+// ErrCircuitOpen is wrapped by the errors of requests rejected by an open circuit.
+var ErrCircuitOpen = task.ErrCircuitOpen
+
 // ExecWithVersion pairs an execution result with its version number.
 type ExecWithVersion = imig.ExecWithVersion
 
+// StepRecording is the content of a Migrator.RecordDir file: the HTTP exchanges of one step.
+type StepRecording = imig.StepRecording
+
+// RecordedExchange is one recorded request with its response.
+type RecordedExchange = imig.RecordedExchange
+
+// FailedVersionsError is implemented by errors that report which migration versions failed.
+type FailedVersionsError = imig.FailedVersionsError
+
+// MigrationErrors aggregates the failures of a ContinueOnError run.
+type MigrationErrors = imig.MigrationErrors
+
+// VersionError is the failure of a single migration version.
+type VersionError = imig.VersionError
+
+// FailedVersions returns the versions reported by err, or nil when err does not carry them.
+func FailedVersions(err error) []int { return imig.FailedVersions(err) }
+
+// DirectionBaseline is the run direction recorded by Baseline.
+const DirectionBaseline = imig.DirectionBaseline
+
+// DirectionSkipped is the run direction recorded for versions skipped by an up response's
+// on_match directive.
+const DirectionSkipped = imig.DirectionSkipped
+
+// SkipAction is an on_match action triggered by an up response (ExecResult.Skip).
+type SkipAction = task.SkipAction
+
+// ErrVersionGap is reported when applied versions have no migration file (e.g. deleted files):
+// by up runs and plans when the current version is above the newest file, and, wrapped in
+// ErrMissingMigrationFile, by down runs, plans and redo.
+var ErrVersionGap = imig.ErrVersionGap
+
+// ErrMissingMigrationFile reports an applied version whose migration file is missing; match it
+// with errors.As. It wraps ErrVersionGap.
+type ErrMissingMigrationFile = imig.ErrMissingMigrationFile
+
+// ChecksumDrift reports an applied version whose file no longer matches its recorded checksum.
+type ChecksumDrift = imig.ChecksumDrift
+
+// ErrChecksumMismatch is wrapped by MigrateUp errors when StrictChecksums detects drift.
+var ErrChecksumMismatch = imig.ErrChecksumMismatch
+
+// MigrationPlan lists the migrations an up or down run would execute, in order.
+type MigrationPlan = imig.MigrationPlan
+
+// PlannedMigration is a single entry of a MigrationPlan.
+type PlannedMigration = imig.PlannedMigration
+
+// PlanDiff reports which pending migrations a live environment already satisfies.
+type PlanDiff = imig.PlanDiff
+
+// PlanDiffEntry is a single entry of a PlanDiff.
+type PlanDiffEntry = imig.PlanDiffEntry
+
+// Statuses of a PlanDiffEntry.
+const (
+	DiffWouldApply       = imig.DiffWouldApply
+	DiffAlreadySatisfied = imig.DiffAlreadySatisfied
+	DiffUnknown          = imig.DiffUnknown
+)
+
+// SmokeOptions configures Migrator.Smoke.
+type SmokeOptions = imig.SmokeOptions
+
+// SmokeReport is the outcome of Migrator.Smoke per migration and target.
+type SmokeReport = imig.SmokeReport
+
+// SmokeTarget is the outcome of all migrations against one target of a SmokeReport.
+type SmokeTarget = imig.SmokeTarget
+
+// SmokeStep is the outcome of one migration against one target.
+type SmokeStep = imig.SmokeStep
+
+// SmokeMigration names a migration of a SmokeReport.
+type SmokeMigration = imig.SmokeMigration
+
+// Modes of a SmokeStep.
+const (
+	SmokeRequest = imig.SmokeRequest
+	SmokeProbe   = imig.SmokeProbe
+	SmokeSkipped = imig.SmokeSkipped
+)
+
+// ProbeSpec is the read-only up.probe request used by PlanDiff.
+type ProbeSpec = task.ProbeSpec
+
+// StepInfo describes the migration step passed to BeforeStep/AfterStep hooks.
+type StepInfo = imig.StepInfo
+
+// BeforeStepFunc is called before a migration step runs; returning an error aborts the step.
+type BeforeStepFunc = imig.BeforeStepFunc
+
+// AfterStepFunc is called after a migration step ran with its result and error.
+type AfterStepFunc = imig.AfterStepFunc
+
+// NotifyConfig configures the webhook that receives a NotifySummary after each run.
+type NotifyConfig = imig.NotifyConfig
+
+// NotifySummary is the payload data sent to the Notify webhook.
+type NotifySummary = imig.NotifySummary
+
+// ResponseLimitMargin is how far past Migrator.MaxResponseBodyBytes a body is still read.
+const ResponseLimitMargin = task.ResponseLimitMargin
+
+// AuthInjection adds an auth header to every migration request; see Migrator.AuthInjection.
+type AuthInjection = imig.AuthInjection
+
+// RenderedRequest is a rendered (and masked) request reported by dry runs instead of being sent.
+type RenderedRequest = task.RenderedRequest
+
 // Store is an alias to the internal store type.
 type Store = store.Store
 
+// AppliedInfo is an applied version and when it was applied (see Store.ListAppliedWithTime);
+// AppliedAt is zero for versions applied before apirun recorded it.
+type AppliedInfo = store.AppliedInfo
+
+// StoreBackend is the interface a custom store driver implements (see RegisterStoreDriver).
+// Every method receives the validated TableNames; a backend that is not database/sql based
+// returns a nil *sql.DB from Connect. The methods are:
+//   - Load, Validate, Connect, Close: configure from the factory map, open and release the backend
+//   - Ensure: create the schema (called on every connect, so it must be idempotent)
+//   - Apply, Remove, IsApplied, CurrentVersion, ListApplied, ListAppliedWithTime, SetVersion:
+//     the set of applied versions and when each was applied
+//   - SetChecksum, ListChecksums: the migration file checksum of each applied version
+//   - RecordRun, ListRuns, ListRunsFiltered, LoadEnv: the run history (StoreRun records)
+//   - InsertStoredEnv, LoadStoredEnv, DeleteStoredEnv: env kept for the down step of a version
+//
+// A backend may also implement WithTx(fn func(tx StoreBackend) error) error to make the writes
+// of one version atomic (Store.WithTx); without it they are made one by one.
+// Lock(th TableNames, timeout time.Duration) (func() error, error) lets it serialize the runs
+// of several processes (StoreConfig.Lock).
+type StoreBackend = store.Backend
+
+// StoreDriverFactory builds a StoreBackend from the driver config map.
+type StoreDriverFactory = store.DriverFactory
+
+// StoreOptions is a DriverConfig passing a plain map to a registered driver's factory.
+type StoreOptions = store.Options
+
+// StoreRun is a run history record as stored by a StoreBackend.
+type StoreRun = store.Run
+
+// RunDetails is the request metadata a StoreBackend records with a run.
+type RunDetails = store.RunDetails
+
 // RunHistory is a public representation of a migration run entry.
 type RunHistory struct {
 	ID         int
@@ -187,14 +609,40 @@ type RunHistory struct {
 	RanAt      string
 	Body       *string
 	Env        map[string]string
+	// Request metadata of the executed step; empty (nil DurationMs) for runs recorded by older versions.
+	Method     string
+	URL        string
+	StartedAt  string
+	DurationMs *int64
+	// Headers are the response headers saved with the run when SaveResponseHeaders was set.
+	Headers map[string]string
+	// RunID is the Migrator.RunID of the call that recorded the run; empty for older runs.
+	RunID string
 }
 
+// RunFilter narrows ListRunsFiltered by version, direction, failure, age and count; zero values do not filter.
+type RunFilter = store.RunFilter
+
 // ListRuns returns the migration run history for the provided store.
 func ListRuns(st *Store) ([]RunHistory, error) {
 	r, err := st.ListRuns()
 	if err != nil {
 		return nil, err
 	}
+	return toRunHistory(r), nil
+}
+
+// ListRunsFiltered returns the run history matching f, filtered by the database; with f.Limit
+// only the newest matching runs are returned (still oldest first).
+func ListRunsFiltered(st *Store, f RunFilter) ([]RunHistory, error) {
+	r, err := st.ListRunsFiltered(f)
+	if err != nil {
+		return nil, err
+	}
+	return toRunHistory(r), nil
+}
+
+func toRunHistory(r []store.Run) []RunHistory {
 	out := make([]RunHistory, 0, len(r))
 	for _, it := range r {
 		out = append(out, RunHistory{
@@ -206,9 +654,15 @@ func ListRuns(st *Store) ([]RunHistory, error) {
 			RanAt:      it.RanAt,
 			Body:       it.Body,
 			Env:        it.Env,
+			Method:     it.Method,
+			URL:        it.URL,
+			StartedAt:  it.StartedAt,
+			DurationMs: it.DurationMs,
+			Headers:    it.Headers,
+			RunID:      it.RunID,
 		})
 	}
-	return out, nil
+	return out
 }
 
 // StoreDBFileName is the default sqlite filename used for migration history.
@@ -217,6 +671,10 @@ const StoreDBFileName = store.DbFileName
 // AuthMethod Plugin-style provider interface and registration
 type AuthMethod = auth.Method
 
+// AuthMethodWithExpiry is an optional extension of AuthMethod for providers that report token expiry.
+// Tokens from such providers are cached per auth name and re-acquired shortly before they expire.
+type AuthMethodWithExpiry = auth.ExpiringMethod
+
 type AuthFactory = auth.Factory
 
 // Auth Expose struct-based auth configuration for external users.
@@ -229,11 +687,30 @@ func NewAuthSpecFromMap(m map[string]interface{}) MethodConfig { return auth.New
 // RegisterAuthProvider exposes custom auth provider registration for library users.
 func RegisterAuthProvider(typ string, f AuthFactory) { auth.Register(typ, f) }
 
+// ResponseExtractor extracts env_from values from a response body: spec maps each env_from key to
+// its path, and keys missing from the result count as missing.
+type ResponseExtractor = task.Extractor
+
+// RegisterExtractor makes env_from use fn for responses whose Content-Type has the media type
+// contentType (e.g. "application/xml"), so that non-JSON bodies can be parsed. Responses of other
+// types keep the built-in JSON extraction.
+func RegisterExtractor(contentType string, fn ResponseExtractor) {
+	task.RegisterExtractor(contentType, fn)
+}
+
 // RenderAnyTemplate exposes template rendering used for config/auth maps in the CLI.
 func RenderAnyTemplate(v interface{}, base *env.Env) interface{} {
 	return util.RenderAnyTemplate(v, base)
 }
 
+// RegisterStoreDriver registers a custom store backend under name, selected with
+// StoreConfig.Driver (e.g. with StoreOptions as DriverConfig). The built-in sqlite and
+// postgresql drivers cannot be replaced.
+func RegisterStoreDriver(name string, f StoreDriverFactory) { store.RegisterDriver(name, f) }
+
+// IsStoreDriverRegistered reports whether a custom store driver is registered under name.
+func IsStoreDriverRegistered(name string) bool { return store.IsRegisteredDriver(name) }
+
 // OpenStoreFromOptions opens a store based on StoreConfig.
 // If storeConfig is nil, opens sqlite at dir/StoreDBFileName.
 // Otherwise, connects using the provided driver and driver config; for sqlite, missing path defaults to dir/StoreDBFileName.
@@ -245,30 +722,9 @@ func OpenStoreFromOptions(dir string, storeConfig *StoreConfig) (*Store, error)
 		storeConfig.Config.DriverConfig = &SqliteConfig{Path: filepath.Join(dir, StoreDBFileName)}
 	}
 
-	cfg := storeConfig.Config
-	// If driver not set, infer from driver config type (defaults to sqlite)
-	drv := strings.ToLower(strings.TrimSpace(cfg.Driver))
-	if drv == "" {
-		switch cfg.DriverConfig.(type) {
-		case *store.PostgresConfig:
-			drv = DriverPostgresql
-		default:
-			drv = DriverSqlite
-		}
-		cfg.Driver = drv
-	}
-
-	// For sqlite, ensure a default path when empty
-	if cfg.Driver == DriverSqlite {
-		if sc, ok := cfg.DriverConfig.(*store.SqliteConfig); ok {
-			if strings.TrimSpace(sc.Path) == "" {
-				sc.Path = filepath.Join(dir, StoreDBFileName)
-			}
-
-			if err := os.MkdirAll(filepath.Dir(sc.Path), 0o750); err != nil {
-				return nil, fmt.Errorf("failed to create sqlite dir: %w", err)
-			}
-		}
+	cfg, err := connectConfig(storeConfig.Config, filepath.Join(dir, StoreDBFileName), "", nil)
+	if err != nil {
+		return nil, err
 	}
 
 	st := &store.Store{}
@@ -279,6 +735,34 @@ func OpenStoreFromOptions(dir string, storeConfig *StoreConfig) (*Store, error)
 	return st, nil
 }
 
+// renderSqlitePath expands ${VAR} from the OS environment and renders {{.env.*}} against e
+// in a sqlite path, then creates its parent directory, so per-environment database files can
+// be selected with a single configured path.
+func renderSqlitePath(path string, e *env.Env) (string, error) {
+	rendered := os.ExpandEnv(path)
+	if strings.Contains(rendered, "{{") {
+		if e == nil {
+			e = env.New()
+		}
+		var err error
+		if rendered, err = e.RenderGoTemplateErr(rendered); err != nil {
+			return "", fmt.Errorf("failed to render sqlite path %q: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(rendered), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create sqlite dir: %w", err)
+	}
+	return rendered, nil
+}
+
+// memoryStoreSeq numbers the in-memory databases named by newMemoryStoreName.
+var memoryStoreSeq atomic.Int64
+
+// newMemoryStoreName returns a name for an unnamed in-memory sqlite database.
+func newMemoryStoreName() string {
+	return fmt.Sprintf("apirun-store-%d", memoryStoreSeq.Add(1))
+}
+
 // Logging API - Public interface for structured logging
 // Re-export common logging types for public use
 
@@ -310,6 +794,22 @@ func NewColorLogger(level LogLevel) *Logger {
 	return common.NewColorLogger(level)
 }
 
+// LogOptions configures the format, timestamps and output of a logger
+type LogOptions = common.LogOptions
+
+// NewLoggerWithOptions creates a structured logger configured by opts
+func NewLoggerWithOptions(level LogLevel, opts LogOptions) *Logger {
+	return common.NewLoggerWithOptions(level, opts)
+}
+
+// FileLogOptions controls size and age based rotation of a file logger
+type FileLogOptions = common.FileLogOptions
+
+// NewFileLogger creates a structured logger writing to a rotating file at path
+func NewFileLogger(level LogLevel, path string, opts FileLogOptions) (*Logger, error) {
+	return common.NewFileLogger(level, path, opts)
+}
+
 // SetDefaultLogger sets the global default logger for apirun
 func SetDefaultLogger(logger *Logger) {
 	common.SetDefaultLogger(logger)
@@ -6,8 +6,11 @@ import (
 	iauth "github.com/loykin/apirun/internal/auth"
 	"github.com/loykin/apirun/internal/auth/basic"
 	"github.com/loykin/apirun/internal/auth/common"
+	"github.com/loykin/apirun/internal/auth/ntlm"
 	"github.com/loykin/apirun/internal/auth/oauth2"
 	"github.com/loykin/apirun/internal/auth/pocketbase"
+	"github.com/loykin/apirun/internal/auth/vault"
+	xoauth2 "golang.org/x/oauth2"
 )
 
 // Public constants for known auth provider types usable with AcquireAuthAndSetEnv typ parameter.
@@ -16,6 +19,8 @@ const (
 	AuthTypeBasic      = common.AuthTypeBasic
 	AuthTypeOAuth2     = common.AuthTypeOAuth2
 	AuthTypePocketBase = common.AuthTypePocketBase
+	AuthTypeVault      = common.AuthTypeVault
+	AuthTypeNegotiate  = common.AuthTypeNegotiate
 )
 
 // Public, type-safe wrappers for built-in auth providers.
@@ -55,12 +60,45 @@ func (c OAuth2ImplicitConfig) ToMap() map[string]interface{} {
 	return oauth2.ImplicitConfig(c).ToMap()
 }
 
+// AuthTypeTokenSource is the Type of Auth entries built by AuthFromTokenSource.
+const AuthTypeTokenSource = "token_source"
+
+// TokenSourceAuthMethod returns an AuthMethod that takes the access token from ts.Token().
+// Errors from the token source are returned from Acquire.
+func TokenSourceAuthMethod(ts xoauth2.TokenSource) AuthMethod {
+	return oauth2.TokenSourceMethod{TS: ts}
+}
+
+// AuthFromTokenSource returns an Auth entry backed by an existing oauth2.TokenSource, so
+// embedding services can reuse their credentials. The access token is available to templates
+// as {{.auth.<name>}} and is cached until shortly before the token's expiry.
+func AuthFromTokenSource(name string, ts xoauth2.TokenSource) Auth {
+	return Auth{Type: AuthTypeTokenSource, Name: name, Method: TokenSourceAuthMethod(ts)}
+}
+
 type PocketBaseAuthConfig pocketbase.Config
 
 func (c PocketBaseAuthConfig) ToMap() map[string]interface{} {
 	return pocketbase.Config(c).ToMap()
 }
 
+// VaultAuthConfig mirrors the internal vault.Config: it reads Field of the KV secret at Path.
+// Token supports ${VAULT_TOKEN} style expansion; AuthMethod "bearer" prefixes the value with "Bearer ".
+type VaultAuthConfig vault.Config
+
+func (c VaultAuthConfig) ToMap() map[string]interface{} {
+	return vault.Config(c).ToMap()
+}
+
+// NegotiateAuthConfig mirrors the internal ntlm.Config: explicit NTLM credentials for servers
+// that answer with an NTLM or Negotiate challenge. The acquired value is a handle that must be
+// sent in the Authorization header, e.g. Authorization: "{{.auth.corp}}".
+type NegotiateAuthConfig ntlm.Config
+
+func (c NegotiateAuthConfig) ToMap() map[string]interface{} {
+	return ntlm.Config(c).ToMap()
+}
+
 // Below are convenience variants that accept an explicit logical name argument
 // so callers don't need to embed the name into the config/spec.
 
@@ -146,3 +184,9 @@ func AcquirePocketBaseWithName(ctx context.Context, cfg PocketBaseAuthConfig) (s
 	v, err := iauth.AcquireAndStoreWithName(ctx, "pocketbase", spec)
 	return v, err
 }
+
+// AcquireVaultWithName reads the configured Vault secret field and returns it as the auth value.
+func AcquireVaultWithName(ctx context.Context, cfg VaultAuthConfig) (string, error) {
+	v, err := iauth.AcquireAndStoreWithName(ctx, common.AuthTypeVault, cfg.ToMap())
+	return v, err
+}
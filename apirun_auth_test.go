@@ -4,16 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	iauth "github.com/loykin/apirun/internal/auth"
 	"github.com/loykin/apirun/pkg/env"
+	xoauth2 "golang.org/x/oauth2"
 )
 
 // helper to decode application/x-www-form-urlencoded bodies in test servers
@@ -325,3 +330,65 @@ func TestEmbeddedAuthMultiAndMigrateUp(t *testing.T) {
 		t.Fatalf("expected both endpoints to be hit once, got a=%d b=%d", hitsA, hitsB)
 	}
 }
+
+// fakeTokenSource hands out tokens from a fixed sequence (or err) and counts calls.
+type fakeTokenSource struct {
+	calls  int32
+	tokens []string
+	err    error
+}
+
+func (f *fakeTokenSource) Token() (*xoauth2.Token, error) {
+	n := atomic.AddInt32(&f.calls, 1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &xoauth2.Token{AccessToken: f.tokens[int(n)-1], Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAuthFromTokenSource_AcquireAndMigrateUp(t *testing.T) {
+	ts := &fakeTokenSource{tokens: []string{"tok-1", "tok-2"}}
+	if v, err := TokenSourceAuthMethod(ts).Acquire(context.Background()); err != nil || v != "tok-1" {
+		t.Fatalf("Acquire: %q %v", v, err)
+	}
+
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i, name := range []string{"001_a.yaml", "002_b.yaml"} {
+		mig := fmt.Sprintf("up:\n  request:\n    method: POST\n    url: %s/%d\n    headers:\n      - { name: Authorization, value: 'Bearer {{.auth.svc}}' }\n  response:\n    result_code: ['200']\n", srv.URL, i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	storeConfig := StoreConfig{}
+	storeConfig.DriverConfig = &SqliteConfig{Path: filepath.Join(dir, StoreDBFileName)}
+	m := Migrator{Dir: dir, Env: env.New(), StoreConfig: &storeConfig, DelayBetweenMigrations: time.Millisecond,
+		Auth: []Auth{AuthFromTokenSource("svc", ts)}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	// The token is cached until its expiry, so both migrations use the second token
+	if strings.Join(got, ",") != "Bearer tok-2,Bearer tok-2" || atomic.LoadInt32(&ts.calls) != 2 {
+		t.Fatalf("unexpected headers %v after %d Token calls", got, ts.calls)
+	}
+}
+
+func TestAuthFromTokenSource_ErrorSurfaces(t *testing.T) {
+	ts := &fakeTokenSource{err: errors.New("refresh token revoked")}
+	a := AuthFromTokenSource("svc", ts)
+	if a.Type != AuthTypeTokenSource || a.Name != "svc" {
+		t.Fatalf("unexpected auth: %+v", a)
+	}
+	_, err := a.Acquire(context.Background(), env.New())
+	if err == nil || !strings.Contains(err.Error(), "refresh token revoked") {
+		t.Fatalf("expected token source error, got %v", err)
+	}
+	if !errors.Is(err, ts.err) {
+		t.Fatalf("expected error to wrap the token source error, got %v", err)
+	}
+}
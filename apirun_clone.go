@@ -0,0 +1,75 @@
+package apirun
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns an independent copy of m for use in another goroutine, e.g. one Migrator per
+// tenant. A Migrator, including its zero value, is not safe for concurrent use: its calls install
+// auth values into Env.Auth, (re)connect its store and keep a token cache and circuit state.
+//
+// The clone gets its own copy of Env (global, local, auth and ctx maps), of the Auth slice and of
+// the configuration slices, maps and pointed-to structs (StoreConfig, TLSConfig, Transport,
+// RetryPolicy, Notify, ...). It starts unconnected, with an empty token cache and closed
+// circuits. Functions and interface values are shared as they are: FS, hooks, middleware,
+// TracerProvider and the Method/Methods of Auth entries must be safe for concurrent use. A
+// sqlite StoreConfig keeps its path, so give clones distinct paths or stores unless they
+// are meant to share one history; an unnamed in-memory database is never shared.
+func (m *Migrator) Clone() *Migrator {
+	c := *m
+	c.store = Store{}
+	c.tokenCache = nil
+	c.breaker = nil
+	c.memoryStore = ""
+	if m.Env != nil {
+		c.Env = m.Env.Clone()
+		c.Env.Precedence = slices.Clone(m.Env.Precedence)
+	}
+	c.Auth = slices.Clone(m.Auth)
+	c.StoreConfig = cloneStoreConfig(m.StoreConfig)
+	c.EnvPrecedence = slices.Clone(m.EnvPrecedence)
+	c.EnvFiles = slices.Clone(m.EnvFiles)
+	c.RenderBodyDefault = clonePtr(m.RenderBodyDefault)
+	c.TLSConfig = m.TLSConfig.Clone()
+	c.Transport = clonePtr(m.Transport)
+	if c.RetryPolicy = clonePtr(m.RetryPolicy); c.RetryPolicy != nil {
+		c.RetryPolicy.RetryableStatusCodes = slices.Clone(m.RetryPolicy.RetryableStatusCodes)
+	}
+	c.DefaultHeaders = maps.Clone(m.DefaultHeaders)
+	c.AuthInjection = clonePtr(m.AuthInjection)
+	if c.Notify = clonePtr(m.Notify); c.Notify != nil {
+		c.Notify.Headers = maps.Clone(m.Notify.Headers)
+	}
+	c.Tags = slices.Clone(m.Tags)
+	c.RequestMiddleware = slices.Clone(m.RequestMiddleware)
+	c.ResponseMiddleware = slices.Clone(m.ResponseMiddleware)
+	c.ContextKeys = maps.Clone(m.ContextKeys)
+	c.CircuitBreaker = clonePtr(m.CircuitBreaker)
+	return &c
+}
+
+// cloneStoreConfig copies cfg and its built-in driver config, so the clone can change them.
+func cloneStoreConfig(cfg *StoreConfig) *StoreConfig {
+	if cfg == nil {
+		return nil
+	}
+	out := *cfg
+	switch dc := cfg.DriverConfig.(type) {
+	case *SqliteConfig:
+		out.DriverConfig = clonePtr(dc)
+	case *PostgresConfig:
+		out.DriverConfig = clonePtr(dc)
+	case *MongoConfig:
+		out.DriverConfig = clonePtr(dc)
+	}
+	return &out
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -0,0 +1,120 @@
+package apirun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+type staticTokenMethod string
+
+func (s staticTokenMethod) Acquire(context.Context) (string, error) { return string(s), nil }
+
+// Run with -race: the clones share migrations, auth method and hooks, nothing mutable.
+func TestMigrator_Clone_ParallelTenants(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  name: tenant\n  request:\n    method: PUT\n    url: " + srv.URL + "/tenants/{{.env.tenant}}\n" +
+		"    headers:\n      - { name: Authorization, value: 'Bearer {{.auth.api}}' }\n  response:\n    result_code: ['200']\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_tenant.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	base := env.New()
+	_ = base.SetString("global", "tenant", "template")
+	proto := &Migrator{
+		Dir:         dir,
+		Env:         base,
+		Auth:        []Auth{{Name: "api", Method: staticTokenMethod("tok")}},
+		StoreConfig: NewSqliteStoreConfig(&SqliteConfig{Memory: true}, TableNames{}),
+	}
+
+	const tenants = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, tenants)
+	for i := 0; i < tenants; i++ {
+		m := proto.Clone()
+		tenant := fmt.Sprintf("t%d", i)
+		_ = m.Env.SetString("global", "tenant", tenant)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+				errs <- fmt.Errorf("%s: %w", tenant, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if len(seen) != tenants {
+		t.Fatalf("requests = %v, want one per tenant", seen)
+	}
+	for i := 0; i < tenants; i++ {
+		if got := seen[fmt.Sprintf("/tenants/t%d", i)]; got != "Bearer tok" {
+			t.Fatalf("tenant t%d: Authorization = %q", i, got)
+		}
+	}
+	// The prototype is left as it was
+	if got := proto.Env.GetString("global", "tenant"); got != "template" {
+		t.Fatalf("prototype tenant = %q", got)
+	}
+	if len(proto.Env.Auth) != 0 || proto.tokenCache != nil {
+		t.Fatalf("prototype auth state touched: %v, cache %v", proto.Env.Auth, proto.tokenCache)
+	}
+	if sc := proto.StoreConfig.DriverConfig.(*SqliteConfig); sc.Path != "" {
+		t.Fatalf("prototype store config filled in: %q", sc.Path)
+	}
+}
+
+func TestMigrator_Clone_CopiesConfiguration(t *testing.T) {
+	render := false
+	proto := &Migrator{
+		Env:               env.New(),
+		Auth:              []Auth{{Name: "a"}},
+		RenderBodyDefault: &render,
+		RetryPolicy:       &RetryPolicy{MaxAttempts: 2, RetryableStatusCodes: []int{503}},
+		DefaultHeaders:    map[string]string{"X-A": "1"},
+		Notify:            &NotifyConfig{URL: "http://n", Headers: map[string]string{"X-N": "1"}},
+		Tags:              []string{"core"},
+		ContextKeys:       map[string]interface{}{"tenant": "k"},
+		EnvFiles:          []string{"base.env"},
+	}
+	_ = proto.Env.SetString("local", "l", "1")
+	c := proto.Clone()
+
+	_ = c.Env.SetString("local", "l", "2")
+	c.Auth[0].Name = "b"
+	*c.RenderBodyDefault = true
+	c.RetryPolicy.RetryableStatusCodes[0] = 500
+	c.DefaultHeaders["X-A"] = "2"
+	c.Notify.Headers["X-N"] = "2"
+	c.Tags[0] = "edge"
+	c.ContextKeys["tenant"] = "other"
+	c.EnvFiles[0] = "tenant.env"
+
+	if proto.Env.GetString("local", "l") != "1" || proto.Auth[0].Name != "a" || *proto.RenderBodyDefault ||
+		proto.RetryPolicy.RetryableStatusCodes[0] != 503 || proto.DefaultHeaders["X-A"] != "1" ||
+		proto.Notify.Headers["X-N"] != "1" || proto.Tags[0] != "core" || proto.ContextKeys["tenant"] != "k" ||
+		proto.EnvFiles[0] != "base.env" {
+		t.Fatalf("changing the clone changed the prototype: %+v", proto)
+	}
+}
@@ -0,0 +1,339 @@
+package apirun
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// memBackend is a minimal in-memory StoreBackend used to exercise RegisterStoreDriver.
+type memBackend struct {
+	name      string
+	ensured   int
+	closed    bool
+	applied   map[int]time.Time
+	checksums map[int]string
+	runs      []StoreRun
+	stored    map[int]map[string]string
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{applied: map[int]time.Time{}, checksums: map[int]string{}, stored: map[int]map[string]string{}}
+}
+
+func (b *memBackend) Connect() (*sql.DB, error) { return nil, nil }
+func (b *memBackend) Validate() error           { return nil }
+func (b *memBackend) Load(cfg map[string]interface{}) error {
+	b.name, _ = cfg["name"].(string)
+	return nil
+}
+func (b *memBackend) Ensure(TableNames) error { b.ensured++; return nil }
+func (b *memBackend) Apply(_ TableNames, v int) error {
+	if _, ok := b.applied[v]; !ok {
+		b.applied[v] = time.Now()
+	}
+	return nil
+}
+func (b *memBackend) IsApplied(_ TableNames, v int) (bool, error) {
+	_, ok := b.applied[v]
+	return ok, nil
+}
+func (b *memBackend) CurrentVersion(th TableNames) (int, error) {
+	vs, _ := b.ListApplied(th)
+	if len(vs) == 0 {
+		return 0, nil
+	}
+	return vs[len(vs)-1], nil
+}
+func (b *memBackend) ListApplied(TableNames) ([]int, error) {
+	vs := []int{}
+	for v := range b.applied {
+		vs = append(vs, v)
+	}
+	sort.Ints(vs)
+	return vs, nil
+}
+func (b *memBackend) ListAppliedWithTime(th TableNames) ([]AppliedInfo, error) {
+	vs, _ := b.ListApplied(th)
+	out := make([]AppliedInfo, 0, len(vs))
+	for _, v := range vs {
+		out = append(out, AppliedInfo{Version: v, AppliedAt: b.applied[v]})
+	}
+	return out, nil
+}
+func (b *memBackend) Remove(_ TableNames, v int) error {
+	delete(b.applied, v)
+	delete(b.checksums, v)
+	return nil
+}
+func (b *memBackend) SetChecksum(_ TableNames, v int, checksum string) error {
+	b.checksums[v] = checksum
+	return nil
+}
+func (b *memBackend) ListChecksums(TableNames) (map[int]string, error) { return b.checksums, nil }
+func (b *memBackend) SetVersion(_ TableNames, target int) error {
+	for v := range b.applied {
+		if v > target {
+			delete(b.applied, v)
+		}
+	}
+	for v := 1; v <= target; v++ {
+		if _, ok := b.applied[v]; !ok {
+			b.applied[v] = time.Now()
+		}
+	}
+	return nil
+}
+func (b *memBackend) RecordRun(_ TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, d RunDetails) error {
+	b.runs = append(b.runs, StoreRun{ID: len(b.runs) + 1, Version: version, Direction: direction, StatusCode: status,
+		Body: body, Env: env, Failed: failed, RanAt: time.Now().Format(time.RFC3339Nano), Method: d.Method, URL: d.URL})
+	return nil
+}
+func (b *memBackend) LoadEnv(_ TableNames, version int, direction string) (map[string]string, error) {
+	for i := len(b.runs) - 1; i >= 0; i-- {
+		if r := b.runs[i]; r.Version == version && r.Direction == direction {
+			return r.Env, nil
+		}
+	}
+	return map[string]string{}, nil
+}
+func (b *memBackend) InsertStoredEnv(_ TableNames, version int, kv map[string]string) error {
+	if len(kv) == 0 {
+		return nil
+	}
+	if b.stored[version] == nil {
+		b.stored[version] = map[string]string{}
+	}
+	for k, v := range kv {
+		b.stored[version][k] = v
+	}
+	return nil
+}
+func (b *memBackend) LoadStoredEnv(_ TableNames, version int) (map[string]string, error) {
+	out := map[string]string{}
+	for k, v := range b.stored[version] {
+		out[k] = v
+	}
+	return out, nil
+}
+func (b *memBackend) DeleteStoredEnv(_ TableNames, version int) error {
+	delete(b.stored, version)
+	return nil
+}
+func (b *memBackend) ListRuns(TableNames) ([]StoreRun, error) { return b.runs, nil }
+func (b *memBackend) ListRunsFiltered(_ TableNames, f RunFilter) ([]StoreRun, error) {
+	var out []StoreRun
+	for _, r := range b.runs {
+		if (f.Version == 0 || r.Version == f.Version) && (f.Direction == "" || r.Direction == f.Direction) && (!f.FailedOnly || r.Failed) {
+			out = append(out, r)
+		}
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out, nil
+}
+func (b *memBackend) Close() error { b.closed = true; return nil }
+
+func TestRegisterStoreDriver_OpenStoreFromOptions(t *testing.T) {
+	var backend *memBackend
+	RegisterStoreDriver("Memory", func(cfg map[string]interface{}) (StoreBackend, error) {
+		backend = newMemBackend()
+		return backend, backend.Load(cfg)
+	})
+	if !IsStoreDriverRegistered("memory") {
+		t.Fatalf("expected the driver to be registered under its normalized name")
+	}
+
+	dir := t.TempDir()
+	st, err := OpenStoreFromOptions(dir, NewCustomStoreConfig("memory", StoreOptions{"name": "unit"}, TableNames{}))
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions: %v", err)
+	}
+	if backend == nil || backend.name != "unit" || backend.ensured != 1 {
+		t.Fatalf("expected the factory to build, load and ensure the backend, got %+v", backend)
+	}
+	if st.Driver != "memory" {
+		t.Fatalf("Driver = %q, want memory", st.Driver)
+	}
+	if err := st.Apply(2); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if v, _ := st.CurrentVersion(); v != 2 {
+		t.Fatalf("CurrentVersion = %d, want 2", v)
+	}
+	if err := st.Close(); err != nil || !backend.closed {
+		t.Fatalf("expected Close to reach the backend, err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, StoreDBFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no sqlite file for a custom driver, stat err=%v", err)
+	}
+}
+
+func TestRegisterStoreDriver_Errors(t *testing.T) {
+	if _, err := OpenStoreFromOptions(t.TempDir(), NewCustomStoreConfig("no-such-driver", nil, TableNames{})); err == nil || !strings.Contains(err.Error(), "unknown store driver: no-such-driver") {
+		t.Fatalf("expected unknown driver error, got %v", err)
+	}
+
+	RegisterStoreDriver("broken", func(map[string]interface{}) (StoreBackend, error) {
+		return nil, errors.New("no endpoint configured")
+	})
+	if _, err := OpenStoreFromOptions(t.TempDir(), NewCustomStoreConfig("broken", nil, TableNames{})); err == nil || !strings.Contains(err.Error(), "no endpoint configured") {
+		t.Fatalf("expected the factory error, got %v", err)
+	}
+
+	// Built-in drivers cannot be replaced
+	for _, name := range []string{DriverSqlite, DriverMongo} {
+		RegisterStoreDriver(name, func(map[string]interface{}) (StoreBackend, error) {
+			return nil, errors.New("replaced")
+		})
+		if IsStoreDriverRegistered(name) {
+			t.Fatalf("expected the %s driver to stay built-in", name)
+		}
+	}
+}
+
+func TestMigrator_CustomStoreDriver_UpAndDown(t *testing.T) {
+	// The state outlives connections, like a database would (MigrateDown connects again)
+	backend := newMemBackend()
+	RegisterStoreDriver("memory-migrator", func(map[string]interface{}) (StoreBackend, error) {
+		return backend, nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"item-1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/items\n  response:\n    result_code: ['201']\n    env_from:\n      item_id: id\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/items/{{.env.item_id}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_item.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m := Migrator{Dir: dir, Env: env.New(), StoreConfig: NewCustomStoreConfig("memory-migrator", nil, TableNames{})}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if vs, _ := backend.ListApplied(TableNames{}); !reflect.DeepEqual(vs, []int{1}) {
+		t.Fatalf("applied = %v, want [1]", vs)
+	}
+	if backend.stored[1]["item_id"] != "item-1" {
+		t.Fatalf("expected item_id to be stored for the down step, got %v", backend.stored)
+	}
+
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(backend.applied) != 0 {
+		t.Fatalf("expected no applied versions after down, got %v", backend.applied)
+	}
+	if n := len(backend.runs); n != 2 || backend.runs[1].URL != srv.URL+"/items/item-1" {
+		t.Fatalf("expected an up and a down run against /items/item-1, got %+v", backend.runs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, StoreDBFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no sqlite file for a custom driver, stat err=%v", err)
+	}
+}
+
+func TestMigrator_MemoryStore_UpAndDown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"item-1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/items\n  response:\n    result_code: ['201']\n    env_from:\n      item_id: id\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/items/{{.env.item_id}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_item.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	wd, _ := os.Getwd()
+	_, statErr := os.Stat(filepath.Join(wd, StoreDBFileName))
+	hadCwdDB := statErr == nil
+
+	// MigrateUp and MigrateDown connect separately; both must see the same database
+	m := Migrator{Dir: dir, Env: env.New(), StoreConfig: NewSqliteStoreConfig(&SqliteConfig{Memory: true}, TableNames{})}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if v, err := m.store.CurrentVersion(); err != nil || v != 1 {
+		t.Fatalf("current version = %d, %v; want 1", v, err)
+	}
+	if sc := m.StoreConfig.DriverConfig.(*SqliteConfig); sc.Path != "" {
+		t.Fatalf("expected the store config left unnamed, got path %q", sc.Path)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if v, _ := m.store.CurrentVersion(); v != 0 {
+		t.Fatalf("current version after down = %d, want 0", v)
+	}
+	runs, err := m.store.ListRuns()
+	if err != nil || len(runs) != 2 || runs[1].URL != srv.URL+"/items/item-1" {
+		t.Fatalf("expected an up and a down run against /items/item-1, got %+v (err=%v)", runs, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only the migration file in %s, got %d entries", dir, len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(wd, StoreDBFileName)); !hadCwdDB && err == nil {
+		t.Fatalf("expected no sqlite file in the working directory")
+	}
+}
+
+func TestOpenStoreFromOptions_NamedMemoryStore(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewSqliteStoreConfig(&SqliteConfig{Memory: true, Path: "shared-" + t.Name()}, TableNames{})
+	a, err := OpenStoreFromOptions(dir, cfg)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	if err := a.Apply(3); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	// A second store with the same name shares the database; an unnamed one does not
+	b, err := OpenStoreFromOptions(dir, NewSqliteStoreConfig(&SqliteConfig{Memory: true, Path: "shared-" + t.Name()}, TableNames{}))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = b.Close() }()
+	if v, _ := b.CurrentVersion(); v != 3 {
+		t.Fatalf("named store version = %d, want 3", v)
+	}
+	c, err := OpenStoreFromOptions(dir, NewSqliteStoreConfig(&SqliteConfig{Memory: true}, TableNames{}))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	if v, _ := c.CurrentVersion(); v != 0 {
+		t.Fatalf("unnamed store version = %d, want 0", v)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no files in %s, got %d", dir, len(entries))
+	}
+}
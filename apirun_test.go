@@ -9,9 +9,13 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/loykin/apirun/internal/httpc"
@@ -82,6 +86,22 @@ func TestOpenStoreFromOptions_Postgres_EmptyDSN_Err(t *testing.T) {
 	}
 }
 
+// A MongoConfig selects the mongo driver when Driver is empty, instead of the sqlite default
+func TestOpenStoreFromOptions_InfersMongo(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &StoreConfig{}
+	cfg.DriverConfig = &MongoConfig{Database: "app"}
+	if _, err := OpenStoreFromOptions(dir, cfg); err == nil || !strings.Contains(err.Error(), "mongo store: uri is required") {
+		t.Fatalf("expected the mongo driver to be selected, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, StoreDBFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no sqlite file for a mongo store, stat err=%v", err)
+	}
+	if c := NewMongoStoreConfig(&MongoConfig{URI: "mongodb://db"}, TableNames{}); c.Driver != DriverMongo {
+		t.Fatalf("NewMongoStoreConfig driver = %q", c.Driver)
+	}
+}
+
 func TestPublicToMap_BasicAuthConfig(t *testing.T) {
 	c := BasicAuthConfig{Username: "u", Password: "p"}
 	m := c.ToMap()
@@ -190,6 +210,52 @@ func TestOpenStore_CreatesSQLiteFile(t *testing.T) {
 	}
 }
 
+func TestOpenStore_NestedTemplatedPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("APIRUN_TEST_STAGE", "staging")
+	cfg := &StoreConfig{}
+	cfg.Config.DriverConfig = &SqliteConfig{Path: filepath.Join(dir, "${APIRUN_TEST_STAGE}", "nested", "apirun.db")}
+	st, err := OpenStoreFromOptions(dir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions error: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	p := filepath.Join(dir, "staging", "nested", "apirun.db")
+	if _, err := os.Stat(p); err != nil {
+		t.Fatalf("expected sqlite file at %s, stat err: %v", p, err)
+	}
+}
+
+func TestMigrator_StoreConfig_PathFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &StoreConfig{}
+	cfg.Config.DriverConfig = &SqliteConfig{Path: filepath.Join(dir, "db", "{{.env.stage}}.db")}
+	m := &Migrator{Dir: dir, StoreConfig: cfg, Env: &env.Env{Global: env.FromStringMap(map[string]string{"stage": "prod"})}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	p := filepath.Join(dir, "db", "prod.db")
+	if _, err := os.Stat(p); err != nil {
+		t.Fatalf("expected sqlite file at %s, stat err: %v", p, err)
+	}
+	// The path is rendered for each connection; the configured one is left as it is
+	if sc := cfg.Config.DriverConfig.(*SqliteConfig); sc.Path != filepath.Join(dir, "db", "{{.env.stage}}.db") || cfg.Config.Driver != "" {
+		t.Fatalf("store config modified: driver=%q path=%q", cfg.Config.Driver, sc.Path)
+	}
+	_ = m.Env.SetString("global", "stage", "dev")
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db", "dev.db")); err != nil {
+		t.Fatalf("expected the path rendered again for MigrateDown, stat err: %v", err)
+	}
+
+	cfg.Config.DriverConfig = &SqliteConfig{Path: filepath.Join(dir, "{{.env.missing}}.db")}
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "sqlite path") {
+		t.Fatalf("expected sqlite path render error, got %v", err)
+	}
+}
+
 func TestNewHTTPClient_TLSHelpers(t *testing.T) {
 	// Default settings
 	c := resty.New()
@@ -333,6 +399,26 @@ func TestMigrator_StoreConfig_DefaultSqlitePath(t *testing.T) {
 	}
 }
 
+// With an FS the default sqlite file is created in the working directory, not inside the FS
+func TestMigrator_FS_DefaultSqlitePathInWorkingDir(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	wd := t.TempDir()
+	t.Chdir(wd)
+	fsys := fstest.MapFS{"migration/001_ok.yaml": {Data: []byte("up:\n  request:\n    method: GET\n    url: " + srv.URL + "\n" +
+		"  response:\n    result_code: ['200']\n")}}
+	m := &Migrator{Dir: "migration", FS: fsys}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wd, StoreDBFileName)); err != nil {
+		t.Fatalf("expected sqlite file in working dir: %v", err)
+	}
+}
+
 // Test struct-based Auth acquires a token via registered provider
 func TestAuth_Acquire_StoresInAuth(t *testing.T) {
 	// Register a fake provider that returns a fixed token value
@@ -1032,3 +1118,132 @@ func TestLoggerIntegration(t *testing.T) {
 		"error", "authentication failed",
 		"token", "jwt_token_123")
 }
+
+func TestMigrator_Plan_ListsPendingWithoutRunning(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001_a.yaml", "002_b.yaml"} {
+		body := "up:\n  name: " + name + "\n  request:\n    method: POST\n    url: http://127.0.0.1:1\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	m := Migrator{Dir: dir}
+	plan, err := m.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Versions(), []int{1, 2}) || plan.Migrations[1].Name != "002_b.yaml" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	down, err := m.PlanDown(context.Background(), 0)
+	if err != nil || len(down.Migrations) != 0 {
+		t.Fatalf("expected empty down plan, got %+v, %v", down, err)
+	}
+}
+
+func TestMigrator_Middleware_UpDownAndFind(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]string{} // "METHOD path" -> X-Signature received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Method+" "+r.URL.Path] = r.Header.Get("X-Signature")
+		mu.Unlock()
+		if r.URL.Path == "/items" && r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"id":"item-1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/items\n    headers:\n      - { name: Authorization, value: Bearer t0k }\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/items/{{.env.item_id}}\n" +
+		"  find:\n    request: { method: GET, url: " + srv.URL + "/items }\n    response:\n      env_from: { item_id: id }\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_item.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var statuses []int
+	m := Migrator{Env: env.New(), Dir: dir, DelayBetweenMigrations: time.Millisecond,
+		RequestMiddleware: []func(*resty.Request){
+			func(r *resty.Request) { r.SetHeader("X-Signature", "sig:"+r.Header.Get("Authorization")) },
+			func(r *resty.Request) { r.SetHeader("X-Signature", r.Header.Get("X-Signature")+":"+r.Method) },
+		},
+		ResponseMiddleware: []func(*resty.Response){
+			func(r *resty.Response) { statuses = append(statuses, r.StatusCode()) },
+		},
+	}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	want := map[string]string{
+		"POST /items":          "sig:Bearer t0k:POST",
+		"GET /items":           "sig::GET",
+		"DELETE /items/item-1": "sig::DELETE",
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Fatalf("unexpected signatures:\n got %v\nwant %v", seen, want)
+	}
+	if !reflect.DeepEqual(statuses, []int{200, 200, 200}) {
+		t.Fatalf("expected the response middleware to see every response, got %v", statuses)
+	}
+}
+
+type testCtxKey string
+
+func TestMigrator_ContextKeys_RenderedIntoRequests(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string // "METHOD path?query tenant-header"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Trace-Id"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/tenants/{{.ctx.tenant_id}}/items\n" +
+		"    headers:\n      - { name: X-Trace-Id, value: '{{.ctx.trace_id}}' }\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/tenants/{{.ctx.tenant_id}}/items\n" +
+		"  headers:\n    - { name: X-Trace-Id, value: '{{.ctx.trace_id}}' }\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_item.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m := Migrator{Env: env.New(), Dir: dir, DelayBetweenMigrations: time.Millisecond,
+		ContextKeys: map[string]interface{}{"tenant_id": testCtxKey("tenant"), "trace_id": testCtxKey("trace")},
+	}
+	ctx := context.WithValue(context.Background(), testCtxKey("tenant"), "acme")
+	ctx = context.WithValue(ctx, testCtxKey("trace"), "tr-1")
+	if _, err := m.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	// Without the trace value the header renders empty
+	if _, err := m.MigrateDown(context.WithValue(context.Background(), testCtxKey("tenant"), "acme"), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	want := []string{"POST /tenants/acme/items tr-1", "DELETE /tenants/acme/items "}
+	if !reflect.DeepEqual(seen, want) {
+		t.Fatalf("unexpected requests:\n got %q\nwant %q", seen, want)
+	}
+}
+
+func TestMigrator_ContextKeys_MissingWithEnvMissingFail(t *testing.T) {
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: http://127.0.0.1:1/tenants/{{.ctx.tenant_id}}\n  response:\n    env_missing: fail\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_item.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	m := Migrator{Env: env.New(), Dir: dir, ContextKeys: map[string]interface{}{"tenant_id": testCtxKey("tenant")}}
+	_, err := m.MigrateUp(context.Background(), 0)
+	if err == nil || !strings.Contains(err.Error(), `context value "tenant_id" is missing`) {
+		t.Fatalf("expected a missing context value error, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var BaselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Mark migrations up to --to N as applied without running them (adopting an existing system)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		to := v.GetInt("baseline_to")
+		src, err := resolveConfigSource(v)
+		if err != nil {
+			return err
+		}
+		loc := resolveStoreLocation(src, "baseline")
+		if loc.disabled {
+			return fmt.Errorf("store is disabled - baseline records applied versions in the store")
+		}
+		dir := loc.dir
+		// Normalize to absolute path to avoid working-directory surprises
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		m := apirun.Migrator{Dir: dir, StoreConfig: loc.storeCfg, VersionPattern: loc.versionPattern()}
+		versions, err := m.Baseline(context.Background(), to)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Baselined %d migration(s) up to version %d: %v\n", len(versions), to, versions)
+		return nil
+	},
+}
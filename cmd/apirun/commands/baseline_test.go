@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestBaselineCmd_ThenUpRunsRemaining(t *testing.T) {
+	var hits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		_ = writeFile(t, tdir, fmt.Sprintf("%03d_v.yaml", i), fmt.Sprintf("up:\n  name: v%d\n  request:\n    method: GET\n    url: %s/v%d\n", i, srv.URL, i))
+	}
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n")
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("baseline_to", 2)
+	if err := BaselineCmd.RunE(BaselineCmd, nil); err != nil {
+		t.Fatalf("BaselineCmd.RunE error: %v", err)
+	}
+	v.Set("to", 0)
+	if err := UpCmd.RunE(UpCmd, nil); err != nil {
+		t.Fatalf("UpCmd.RunE error: %v", err)
+	}
+	if !reflect.DeepEqual(hits, []string{"/v3"}) {
+		t.Fatalf("expected only v3 to run after the baseline, got %v", hits)
+	}
+
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(tdir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(tdir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions error: %v", err)
+	}
+	defer func() { _ = st.Close() }()
+	runs, err := st.ListRunsFiltered(apirun.RunFilter{Direction: apirun.DirectionBaseline})
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("expected two baseline runs, got %v (%v)", runs, err)
+	}
+}
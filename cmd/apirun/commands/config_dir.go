@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// configDirFiles are the config file names looked up in a --config-dir, in order.
+var configDirFiles = []string{"config.yaml", "config.yml"}
+
+// configDirMigrations is the migration folder looked up in a --config-dir.
+const configDirMigrations = "migration"
+
+// ConfigDirLayout is what ResolveConfigDir found in a --config-dir directory.
+type ConfigDirLayout struct {
+	// ConfigPath is the config file, "" when the directory has none.
+	ConfigPath string
+	// MigrateDir is the migration folder, "" when the directory has none. It is the default
+	// migrate dir; a migrate_dir set in the config takes precedence.
+	MigrateDir string
+}
+
+// ResolveConfigDir locates config.yaml (or config.yml) and the migration folder in dir. It
+// fails when dir is not a directory or contains neither.
+func ResolveConfigDir(dir string) (ConfigDirLayout, error) {
+	var layout ConfigDirLayout
+	info, err := os.Stat(dir)
+	if err != nil {
+		return layout, fmt.Errorf("--config-dir: %w", err)
+	}
+	if !info.IsDir() {
+		return layout, fmt.Errorf("--config-dir: %s is not a directory", dir)
+	}
+	for _, name := range configDirFiles {
+		p := filepath.Join(dir, name)
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			layout.ConfigPath = p
+			break
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, configDirMigrations)); err == nil && fi.IsDir() {
+		layout.MigrateDir = filepath.Join(dir, configDirMigrations)
+	}
+	if layout.ConfigPath == "" && layout.MigrateDir == "" {
+		return layout, fmt.Errorf("--config-dir: %s contains neither a config.yaml nor a %s/ folder", dir, configDirMigrations)
+	}
+	return layout, nil
+}
+
+// configSource is where a command reads its config file and migrations from.
+type configSource struct {
+	// Path is the config file, "" when there is none.
+	Path string
+	// DefaultDir is the migrate dir used when the config does not set migrate_dir, "" for
+	// the directory of the config file.
+	DefaultDir string
+}
+
+// resolveConfigSource returns the config of --config-dir (APIRUN_CONFIG_DIR) when it is set, else
+// the file of --config (or APIMIGRATE_CONFIG). The root command clears config_dir when --config is
+// given explicitly, so an explicit --config wins over APIRUN_CONFIG_DIR.
+func resolveConfigSource(v *viper.Viper) (configSource, error) {
+	if dir := strings.TrimSpace(v.GetString("config_dir")); dir != "" {
+		layout, err := ResolveConfigDir(dir)
+		if err != nil {
+			return configSource{}, err
+		}
+		return configSource{Path: layout.ConfigPath, DefaultDir: layout.MigrateDir}, nil
+	}
+	configPath := v.GetString("config")
+	if strings.TrimSpace(configPath) == "" {
+		configPath = os.Getenv("APIMIGRATE_CONFIG")
+	}
+	return configSource{Path: configPath}, nil
+}
+
+// migrateDir returns the migrate dir for a config whose migrate_dir is configured ("" if unset).
+func (src configSource) migrateDir(configured string) string {
+	if dir := strings.TrimSpace(configured); dir != "" {
+		return dir
+	}
+	if src.DefaultDir != "" {
+		return src.DefaultDir
+	}
+	if strings.TrimSpace(src.Path) != "" {
+		// Fallback: use the directory of the config file if migrate_dir is not set
+		return filepath.Dir(src.Path)
+	}
+	return "./config/migration"
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestResolveConfigDir_Errors(t *testing.T) {
+	dir := t.TempDir()
+	migDir := filepath.Join(dir, "migration")
+	if err := os.Mkdir(migDir, 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	_ = writeFile(t, dir, "config.yaml", "env: []\n")
+
+	layout, err := ResolveConfigDir(migDir)
+	if err == nil || !strings.Contains(err.Error(), "neither a config.yaml nor a migration/ folder") {
+		t.Fatalf("expected a directory without either to fail, got %+v, %v", layout, err)
+	}
+	if _, err := ResolveConfigDir(filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "--config-dir") {
+		t.Fatalf("expected a missing directory to fail, got %v", err)
+	}
+	if _, err := ResolveConfigDir(filepath.Join(dir, "config.yaml")); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("expected a file to fail, got %v", err)
+	}
+}
+
+func TestResolveConfigDir_MigrationsOnly(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "migration"), 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	layout, err := ResolveConfigDir(dir)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if layout.ConfigPath != "" || layout.MigrateDir != filepath.Join(dir, "migration") {
+		t.Fatalf("layout = %+v", layout)
+	}
+
+	// config.yml is found too
+	_ = writeFile(t, dir, "config.yml", "env: []\n")
+	if layout, err = ResolveConfigDir(dir); err != nil || layout.ConfigPath != filepath.Join(dir, "config.yml") {
+		t.Fatalf("layout = %+v, %v", layout, err)
+	}
+}
+
+func TestBuildMigrator_ConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	migDir := filepath.Join(dir, "migration")
+	if err := os.Mkdir(migDir, 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	_ = writeFile(t, dir, "config.yaml", "max_parallel: 4\nenv:\n  - name: base\n    value: http://api\n")
+
+	v := viper.New()
+	v.Set("config", "./does/not/exist.yaml")
+	v.Set("config_dir", dir)
+	m, err := BuildMigrator(v, MigratorOptions{})
+	if err != nil {
+		t.Fatalf("BuildMigrator: %v", err)
+	}
+	if m.Dir != migDir || m.MaxParallel != 4 || m.Env.GetString("global", "base") != "http://api" {
+		t.Fatalf("expected the --config-dir config and migration folder, got dir %q, %+v", m.Dir, m)
+	}
+	if sc, ok := m.StoreConfig.Config.DriverConfig.(*apirun.SqliteConfig); !ok || sc.Path != "" {
+		t.Fatalf("expected the default sqlite store (under the migration folder), got %+v", m.StoreConfig)
+	}
+
+	// status, history and the other store commands open the same store
+	src, err := resolveConfigSource(v)
+	if err != nil {
+		t.Fatalf("resolveConfigSource: %v", err)
+	}
+	if loc := resolveStoreLocation(src, "test"); loc.dir != migDir {
+		t.Fatalf("store location dir = %q, want %q", loc.dir, migDir)
+	}
+
+	// a migrate_dir in the config still wins over the migration folder
+	other := t.TempDir()
+	_ = writeFile(t, dir, "config.yaml", "migrate_dir: "+other+"\n")
+	if m, err = BuildMigrator(v, MigratorOptions{}); err != nil || m.Dir != other {
+		t.Fatalf("expected migrate_dir %q, got %v, %v", other, m, err)
+	}
+
+	v.Set("config_dir", filepath.Join(dir, "missing"))
+	if _, err := BuildMigrator(v, MigratorOptions{}); err == nil || !strings.Contains(err.Error(), "--config-dir") {
+		t.Fatalf("expected a missing --config-dir to fail, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/loykin/apirun"
+)
+
+// isInteractive reports whether in is a terminal a user can answer a prompt on.
+// Tests replace it to simulate a terminal.
+var isInteractive = func(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmRollback lists the migrations plan rolls back and asks for "yes" to be typed on in.
+// Without a terminal it fails, since nobody can answer; --yes skips the prompt.
+func confirmRollback(in io.Reader, out io.Writer, plan *apirun.MigrationPlan) error {
+	n := len(plan.Migrations)
+	if !isInteractive(in) {
+		return fmt.Errorf("down would roll back %d migration(s) (versions %s) and needs confirmation: rerun with --yes in non-interactive sessions",
+			n, joinVersions(plan.Versions()))
+	}
+	_, _ = fmt.Fprintf(out, "The following migrations will be rolled back (current version %d, target %d):\n", plan.CurrentVersion, plan.TargetVersion)
+	for _, pm := range plan.Migrations {
+		line := fmt.Sprintf("  %d  %s", pm.Version, pm.File)
+		if pm.Name != "" {
+			line += "  (" + pm.Name + ")"
+		}
+		_, _ = fmt.Fprintln(out, line)
+	}
+	_, _ = fmt.Fprintf(out, "Type \"yes\" to roll back %d migration(s): ", n)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != "yes" {
+		return fmt.Errorf("rollback aborted: confirmation not given")
+	}
+	return nil
+}
+
+func joinVersions(versions []int) string {
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -44,7 +44,8 @@ var CreateCmd = &cobra.Command{
 			name = args[0]
 		}
 
-		p, err := apirun.CreateMigration(apirun.CreateOptions{Name: name, Dir: dir})
+		p, err := apirun.CreateMigration(apirun.CreateOptions{Name: name, Dir: dir,
+			TemplatePath: v.GetString("create_template"), Kind: v.GetString("create_kind"), TemplatesDir: v.GetString("create_templates_dir")})
 		if err != nil {
 			return err
 		}
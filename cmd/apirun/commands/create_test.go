@@ -56,3 +56,39 @@ func TestCreateCmd_GeneratesTimestampedFileWithTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateCmd_Kind(t *testing.T) {
+	migDir := t.TempDir()
+	tmplDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmplDir, "create-user.yaml"), []byte("up:\n  name: [[.Name]]\n"), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("---\nmigrate_dir: "+migDir+"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("create_kind", "create-user")
+	v.Set("create_templates_dir", tmplDir)
+	defer func() {
+		v.Set("create_kind", "")
+		v.Set("create_templates_dir", "")
+	}()
+
+	if err := CreateCmd.RunE(CreateCmd, []string{"bob"}); err != nil {
+		t.Fatalf("CreateCmd.RunE: %v", err)
+	}
+	entries, err := os.ReadDir(migDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 file created, got %v (%v)", entries, err)
+	}
+	b, err := os.ReadFile(filepath.Join(migDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("read created: %v", err)
+	}
+	if string(b) != "up:\n  name: bob\n" {
+		t.Fatalf("unexpected content: %q", b)
+	}
+}
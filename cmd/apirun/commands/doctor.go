@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/cmd/apirun/config"
+	"github.com/loykin/apirun/cmd/apirun/validation"
+	iauth "github.com/loykin/apirun/internal/auth"
+	"github.com/loykin/apirun/internal/httpc"
+	imig "github.com/loykin/apirun/internal/migration"
+	"github.com/loykin/apirun/internal/util"
+	"github.com/loykin/apirun/pkg/env"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorStoreTimeout bounds opening and pinging the store.
+const doctorStoreTimeout = 10 * time.Second
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common setup issues in the config, migrations, store, auth and TLS settings",
+	Long: `Run a checklist against the current setup and report each check as PASS, FAIL or SKIP:
+- config: the config file loads and its env resolves
+- migrate_dir: the directory exists and its migration files pass validate
+- store: the store opens and answers a ping
+- auth: every auth entry names a registered provider whose config decodes (no token is acquired)
+- client: TLS versions are known and coherent, transport and circuit breaker settings parse
+
+Exits non-zero when any check fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := resolveConfigSource(viper.GetViper())
+		if err != nil {
+			return err
+		}
+		configPath := src.Path
+		checks := runDoctor(context.Background(), configPath)
+		writeDoctorReport(os.Stdout, configPath, checks)
+		if failed := countFailedChecks(checks); failed > 0 {
+			return fmt.Errorf("doctor: %d of %d checks failed", failed, len(checks))
+		}
+		return nil
+	},
+}
+
+// doctorCheck is one line of the doctor checklist.
+type doctorCheck struct {
+	Name   string
+	Status string // pass, fail or skip
+	Detail string
+}
+
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// runDoctor runs the checks in order. Checks that need the config are skipped when it does not load.
+func runDoctor(ctx context.Context, configPath string) []doctorCheck {
+	var doc config.ConfigDoc
+	cfgCheck := doctorCheck{Name: "config", Status: checkPass}
+	var base *env.Env
+	if strings.TrimSpace(configPath) == "" {
+		cfgCheck.Status, cfgCheck.Detail = checkFail, "no config file given (--config or APIRUN_CONFIG)"
+	} else if err := doc.Load(configPath); err != nil {
+		cfgCheck.Status, cfgCheck.Detail = checkFail, fmt.Sprintf("failed to load %s: %v", configPath, err)
+	} else if base, err = doc.GetEnv(); err != nil {
+		cfgCheck.Status, cfgCheck.Detail = checkFail, fmt.Sprintf("failed to process env: %v", err)
+	} else {
+		cfgCheck.Detail = fmt.Sprintf("loaded %s", configPath)
+	}
+	checks := []doctorCheck{cfgCheck}
+	if cfgCheck.Status != checkPass {
+		for _, name := range []string{"migrate_dir", "store", "auth", "client"} {
+			checks = append(checks, doctorCheck{Name: name, Status: checkSkip, Detail: "config did not load"})
+		}
+		return checks
+	}
+	return append(checks,
+		checkMigrateDir(&doc, configPath),
+		checkStore(ctx, configPath),
+		checkAuth(&doc, base),
+		checkClient(doc.Client),
+	)
+}
+
+func checkMigrateDir(doc *config.ConfigDoc, configPath string) doctorCheck {
+	c := doctorCheck{Name: "migrate_dir"}
+	dir := strings.TrimSpace(doc.MigrateDir)
+	if dir == "" {
+		// up and down fall back to the config file directory
+		dir = filepath.Dir(configPath)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("cannot access %s: %v", dir, err)
+		return c
+	}
+	if !info.IsDir() {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("%s is not a directory", dir)
+		return c
+	}
+	nameRegex, err := imig.CompileVersionPattern(doc.VersionPattern)
+	if err != nil {
+		c.Status, c.Detail = checkFail, err.Error()
+		return c
+	}
+	results, err := validation.ValidateMigrationDir(dir, doc.VersionPattern)
+	if err != nil {
+		c.Status, c.Detail = checkFail, err.Error()
+		return c
+	}
+	if len(results.Results) == 0 {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("no migration files matching %s in %s", nameRegex, dir)
+		return c
+	}
+	if results.HasErrors() {
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("%d error(s) in the migration files of %s, run 'apirun validate' for details", results.ErrorCount(), dir)
+		return c
+	}
+	c.Status, c.Detail = checkPass, fmt.Sprintf("%d migration file(s) in %s", len(results.Results), dir)
+	if ignored := ignoredYAMLFiles(dir, configPath, nameRegex); len(ignored) > 0 {
+		c.Detail += fmt.Sprintf("; not loaded as migrations: %s", strings.Join(ignored, ", "))
+	}
+	return c
+}
+
+// ignoredYAMLFiles lists the YAML files in dir, other than the config file, whose names the
+// migrator skips. They are often misnamed migrations.
+func ignoredYAMLFiles(dir, configPath string, nameRegex *regexp.Regexp) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	cfgAbs, _ := filepath.Abs(configPath)
+	var ignored []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || nameRegex.MatchString(name) {
+			continue
+		}
+		if ext := filepath.Ext(name); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		if abs, _ := filepath.Abs(filepath.Join(dir, name)); abs == cfgAbs {
+			continue
+		}
+		ignored = append(ignored, name)
+	}
+	return ignored
+}
+
+func checkStore(ctx context.Context, configPath string) doctorCheck {
+	c := doctorCheck{Name: "store"}
+	loc := resolveStoreLocation(configSource{Path: configPath}, "doctor")
+	if loc.disabled {
+		c.Status, c.Detail = checkPass, "disabled (store.disabled: true)"
+		return c
+	}
+	st, err := apirun.OpenStoreFromOptions(loc.dir, loc.storeCfg)
+	if err != nil {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("failed to open: %v", err)
+		return c
+	}
+	defer func() { _ = st.Close() }()
+	// Stores that are not database/sql based (mongo, custom drivers) were checked by opening them
+	if st.DB == nil {
+		c.Status, c.Detail = checkPass, fmt.Sprintf("%s store reachable", st.Driver)
+		return c
+	}
+	pctx, cancel := context.WithTimeout(ctx, doctorStoreTimeout)
+	defer cancel()
+	if err := st.DB.PingContext(pctx); err != nil {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("%s store does not answer: %v", st.Driver, err)
+		return c
+	}
+	c.Status, c.Detail = checkPass, fmt.Sprintf("%s store reachable", st.Driver)
+	return c
+}
+
+// checkAuth builds every configured auth method like up does, without acquiring tokens.
+func checkAuth(doc *config.ConfigDoc, base *env.Env) doctorCheck {
+	c := doctorCheck{Name: "auth"}
+	if len(doc.Auth) == 0 {
+		c.Status, c.Detail = checkPass, "no auth configured"
+		return c
+	}
+	auths := make([]iauth.Auth, 0, len(doc.Auth))
+	for i, a := range doc.Auth {
+		pt, ok := util.TrimEmptyCheck(a.Type)
+		if !ok {
+			c.Status, c.Detail = checkFail, fmt.Sprintf("auth[%d]: missing type", i)
+			return c
+		}
+		name, ok := util.TrimEmptyCheck(a.Name)
+		if !ok {
+			c.Status, c.Detail = checkFail, fmt.Sprintf("auth[%d] type=%s: missing name", i, pt)
+			return c
+		}
+		spec := a.Config
+		if strings.TrimSpace(a.DependsOn) == "" {
+			// Chained entries render against their dependency's token, which is not acquired here
+			spec, _ = apirun.RenderAnyTemplate(a.Config, base).(map[string]interface{})
+		}
+		if _, err := iauth.NewMethod(pt, spec); err != nil {
+			c.Status, c.Detail = checkFail, fmt.Sprintf("auth %s: %v", name, err)
+			return c
+		}
+		auths = append(auths, iauth.Auth{Type: pt, Name: name, DependsOn: a.DependsOn})
+	}
+	if _, err := iauth.Order(auths); err != nil {
+		c.Status, c.Detail = checkFail, err.Error()
+		return c
+	}
+	names := make([]string, len(auths))
+	for i, a := range auths {
+		names[i] = fmt.Sprintf("%s (%s)", a.Name, a.Type)
+	}
+	c.Status, c.Detail = checkPass, strings.Join(names, ", ")
+	return c
+}
+
+func checkClient(client config.ClientConfig) doctorCheck {
+	c := doctorCheck{Name: "client"}
+	minV, maxV := parseTLSVersion(client.MinTLSVersion), parseTLSVersion(client.MaxTLSVersion)
+	if strings.TrimSpace(client.MinTLSVersion) != "" && minV == 0 {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("unknown client.min_tls_version %q (use 1.0 to 1.3)", client.MinTLSVersion)
+		return c
+	}
+	if strings.TrimSpace(client.MaxTLSVersion) != "" && maxV == 0 {
+		c.Status, c.Detail = checkFail, fmt.Sprintf("unknown client.max_tls_version %q (use 1.0 to 1.3)", client.MaxTLSVersion)
+		return c
+	}
+	if minV != 0 && maxV != 0 && minV > maxV {
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("client.min_tls_version %s is above client.max_tls_version %s", client.MinTLSVersion, client.MaxTLSVersion)
+		return c
+	}
+	if _, _, err := client.ToClientOptions(); err != nil {
+		c.Status, c.Detail = checkFail, err.Error()
+		return c
+	}
+	if caFile := strings.TrimSpace(client.TLS.CAFile); caFile != "" {
+		if _, err := httpc.AppendCAFile(nil, caFile); err != nil {
+			c.Status, c.Detail = checkFail, err.Error()
+			return c
+		}
+	}
+	tlsRange := "TLS defaults"
+	if minV != 0 || maxV != 0 {
+		tlsRange = fmt.Sprintf("TLS %s to %s", orDefault(client.MinTLSVersion), orDefault(client.MaxTLSVersion))
+	}
+	c.Status, c.Detail = checkPass, tlsRange
+	if caFile := strings.TrimSpace(client.TLS.CAFile); caFile != "" {
+		c.Detail += ", CAs from " + caFile
+	}
+	if client.Insecure {
+		c.Detail += ", certificate verification disabled (insecure: true)"
+	}
+	return c
+}
+
+func orDefault(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "default"
+	}
+	return s
+}
+
+func countFailedChecks(checks []doctorCheck) int {
+	n := 0
+	for _, c := range checks {
+		if c.Status == checkFail {
+			n++
+		}
+	}
+	return n
+}
+
+// writeDoctorReport prints the checklist, one check per line.
+func writeDoctorReport(w io.Writer, configPath string, checks []doctorCheck) {
+	_, _ = fmt.Fprintf(w, "apirun doctor (config: %s)\n\n", configPath)
+	for _, c := range checks {
+		_, _ = fmt.Fprintf(w, "[%s] %-11s %s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+	}
+	_, _ = fmt.Fprintln(w)
+	if failed := countFailedChecks(checks); failed > 0 {
+		_, _ = fmt.Fprintf(w, "%d check(s) failed\n", failed)
+		return
+	}
+	_, _ = fmt.Fprintln(w, "All checks passed")
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func doctorStatuses(checks []doctorCheck) map[string]string {
+	out := make(map[string]string, len(checks))
+	for _, c := range checks {
+		out[c.Name] = c.Status
+	}
+	return out
+}
+
+func TestDoctor_HealthySetup(t *testing.T) {
+	dir := t.TempDir()
+	migDir := filepath.Join(dir, "migration")
+	if err := os.MkdirAll(migDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, migDir, "001_create.yaml", "up:\n  name: create\n  request:\n    method: GET\n    url: http://127.0.0.1/x\n  response:\n    result_code: [\"200\"]\n")
+	cfgPath := writeFile(t, dir, "config.yaml", `migrate_dir: `+migDir+`
+env:
+  - name: api_base
+    value: http://127.0.0.1
+auth:
+  - type: oauth2
+    name: api
+    config:
+      grant_type: client_credentials
+      grant_config:
+        client_id: svc
+        client_secret: s3
+        token_url: "{{.env.api_base}}/oauth/token"
+client:
+  min_tls_version: "1.2"
+  max_tls_version: "1.3"
+`)
+
+	checks := runDoctor(context.Background(), cfgPath)
+	for _, c := range checks {
+		if c.Status != checkPass {
+			t.Fatalf("check %s = %s (%s), want pass", c.Name, c.Status, c.Detail)
+		}
+	}
+	var buf bytes.Buffer
+	writeDoctorReport(&buf, cfgPath, checks)
+	if out := buf.String(); !strings.Contains(out, "[PASS] store       sqlite store reachable") ||
+		!strings.Contains(out, "[PASS] client      TLS 1.2 to 1.3") || !strings.Contains(out, "All checks passed") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+
+	viper.GetViper().Set("config", cfgPath)
+	defer viper.GetViper().Set("config", "")
+	_ = captureOutput(t, func() {
+		if err := DoctorCmd.RunE(DoctorCmd, nil); err != nil {
+			t.Fatalf("doctor on a healthy setup: %v", err)
+		}
+	})
+}
+
+func TestDoctor_BrokenSetup(t *testing.T) {
+	dir := t.TempDir()
+	// A file in place of the sqlite directory makes the store fail to open
+	blocker := writeFile(t, dir, "blocker", "")
+	cfgPath := writeFile(t, dir, "config.yaml", `migrate_dir: `+filepath.Join(dir, "missing")+`
+store:
+  type: sqlite
+  sqlite:
+    path: `+filepath.Join(blocker, "apirun.db")+`
+auth:
+  - type: kerberos-ng
+    name: corp
+    config: {}
+client:
+  min_tls_version: "1.3"
+  max_tls_version: "1.2"
+`)
+
+	checks := runDoctor(context.Background(), cfgPath)
+	want := map[string]string{"config": checkPass, "migrate_dir": checkFail, "store": checkFail, "auth": checkFail, "client": checkFail}
+	if got := doctorStatuses(checks); !maps.Equal(got, want) {
+		t.Fatalf("statuses = %v, want %v\n%+v", got, want, checks)
+	}
+	var buf bytes.Buffer
+	writeDoctorReport(&buf, cfgPath, checks)
+	out := buf.String()
+	for _, s := range []string{"unsupported provider type: kerberos-ng", "min_tls_version 1.3 is above", "4 check(s) failed"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("report lacks %q:\n%s", s, out)
+		}
+	}
+
+	viper.GetViper().Set("config", cfgPath)
+	defer viper.GetViper().Set("config", "")
+	_ = captureOutput(t, func() {
+		if err := DoctorCmd.RunE(DoctorCmd, nil); err == nil || !strings.Contains(err.Error(), "4 of 5 checks failed") {
+			t.Fatalf("expected doctor to fail, got %v", err)
+		}
+	})
+}
+
+func TestDoctor_UnreadableConfigSkipsTheRest(t *testing.T) {
+	cfgPath := writeFile(t, t.TempDir(), "config.yaml", "migrate_dir: [unclosed\n")
+	checks := runDoctor(context.Background(), cfgPath)
+	want := map[string]string{"config": checkFail, "migrate_dir": checkSkip, "store": checkSkip, "auth": checkSkip, "client": checkSkip}
+	if got := doctorStatuses(checks); !maps.Equal(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+}
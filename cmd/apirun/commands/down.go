@@ -3,13 +3,8 @@ package commands
 import (
 	"context"
 	"fmt"
-	"path/filepath"
-	"strings"
-	"time"
+	"os"
 
-	"github.com/loykin/apirun"
-	"github.com/loykin/apirun/cmd/apirun/config"
-	"github.com/loykin/apirun/pkg/env"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -19,84 +14,49 @@ var DownCmd = &cobra.Command{
 	Short: "Rollback down to a target version",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		v := viper.GetViper()
-		configPath := v.GetString("config")
 		dry := v.GetBool("dry_run")
-		dryRunFrom := v.GetInt("dry_run_from")
+		dryRunFormat := v.GetString("dry_run_format")
+		if err := validateDryRunFormat(dryRunFormat); err != nil {
+			return err
+		}
+		output, err := ParseOutputTemplate(v.GetString("down_output"))
+		if err != nil {
+			return err
+		}
+		if output != nil && dry {
+			return fmt.Errorf("--output cannot be combined with --dry-run (use --dry-run-format)")
+		}
 		to := v.GetInt("to")
+		// Dry runs never contact the API, so there is nothing to wait for
+		m, err := BuildMigrator(v, MigratorOptions{Wait: !dry})
+		if err != nil {
+			return err
+		}
+		m.DryRun = dry
+		m.DryRunFrom = v.GetInt("dry_run_from")
 		ctx := context.Background()
-		be := env.New()
-		baseEnv := &be
-		dir := ""
-		saveResp := false
-		var storeCfgFromDoc *apirun.StoreConfig
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err != nil {
-				return fmt.Errorf("failed to load configuration file '%s': %w\nPlease verify the file exists and contains valid YAML", configPath, err)
-			}
-			mDir := strings.TrimSpace(doc.MigrateDir)
-			if mDir == "" {
-				// Fallback: use the directory of the config file if migrate_dir is not set
-				mDir = filepath.Dir(configPath)
-			}
-			envFromCfg, err := doc.GetEnv()
+		// Rolling back is destructive: show what goes and ask first, unless --yes or a dry run
+		if !dry && !v.GetBool("down_yes") {
+			plan, err := m.PlanDown(ctx, to)
 			if err != nil {
-				return fmt.Errorf("failed to process environment variables from config: %w", err)
-			}
-			if err := DoWait(ctx, envFromCfg, doc.Wait, doc.Client); err != nil {
-				return fmt.Errorf("dependency wait check failed: %w\nCheck that required services are running and accessible", err)
-			}
-			if err := doc.DecodeAuth(ctx, envFromCfg); err != nil {
-				return fmt.Errorf("authentication setup failed: %w\nVerify auth configuration in config file", err)
+				return explainVersionGap(err)
 			}
-			// Store configuration is controlled via config file (store.disabled)
-			storeCfgFromDoc = doc.Store.ToStorOptions()
-			saveBody := doc.Store.SaveResponseBody
-			if mDir != "" {
-				dir = mDir
-			}
-			// Always use env from config (may carry Auth even if Global is empty)
-			baseEnv = &envFromCfg
-			saveResp = saveBody
-		}
-		if strings.TrimSpace(dir) == "" {
-			dir = "./config/migration"
-		}
-		// Normalize to absolute path to avoid working-directory surprises
-		if abs, err := filepath.Abs(dir); err == nil {
-			dir = abs
-		}
-		m := apirun.Migrator{Env: *baseEnv, Dir: dir, SaveResponseBody: saveResp, DryRun: dry, DryRunFrom: dryRunFrom}
-		// Set default render_body and delay from config if provided
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err == nil {
-				if doc.RenderBody != nil {
-					m.RenderBodyDefault = doc.RenderBody
-				}
-				if strings.TrimSpace(doc.DelayBetweenMigrations) != "" {
-					if duration, err := time.ParseDuration(doc.DelayBetweenMigrations); err == nil {
-						m.DelayBetweenMigrations = duration
-					}
+			if len(plan.Migrations) > 0 {
+				if err := confirmRollback(cmd.InOrStdin(), cmd.OutOrStdout(), plan); err != nil {
+					return err
 				}
 			}
 		}
-		// Configure store via Migrator.StoreConfig (auto-connect inside MigrateDown)
-		var scPtr *apirun.StoreConfig
-		if strings.TrimSpace(configPath) != "" {
-			if storeCfgFromDoc != nil {
-				scPtr = storeCfgFromDoc
-			}
+		results, err := m.MigrateDown(ctx, to)
+		if outErr := WriteOutputSummary(os.Stdout, "down", results, output); outErr != nil && err == nil {
+			return outErr
+		}
+		if err != nil {
+			return explainVersionGap(err)
 		}
-		if scPtr == nil {
-			// default to sqlite under dir explicitly
-			tmp := &apirun.StoreConfig{}
-			tmp.Config.Driver = apirun.DriverSqlite
-			tmp.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(dir, apirun.StoreDBFileName)}
-			scPtr = tmp
+		if dry {
+			return writeDryRunReport(os.Stdout, "down", results, dryRunFormat)
 		}
-		m.StoreConfig = scPtr
-		_, err := m.MigrateDown(ctx, to)
-		return err
+		return nil
 	},
 }
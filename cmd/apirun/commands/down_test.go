@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/loykin/apirun"
@@ -118,6 +121,7 @@ migrate_dir: %s
 		t.Fatalf("UpCmd.RunE error: %v", err)
 	}
 	// Then rollback all the way down to 0
+	v.Set("down_yes", true)
 	if err := DownCmd.RunE(DownCmd, nil); err != nil {
 		t.Fatalf("DownCmd.RunE error: %v", err)
 	}
@@ -216,6 +220,7 @@ migrate_dir: %s
 
 	// Now roll back to version 1
 	v.Set("to", 1)
+	v.Set("down_yes", true)
 	if err := DownCmd.RunE(DownCmd, nil); err != nil {
 		t.Fatalf("DownCmd.RunE error: %v", err)
 	}
@@ -242,3 +247,70 @@ migrate_dir: %s
 		t.Fatalf("expected current version 1 after partial down, got %d", cur)
 	}
 }
+
+// down asks before rolling back: it fails without a terminal, aborts unless "yes" is typed,
+// and lists the versions it is about to roll back.
+func TestDownCmd_ConfirmRollback(t *testing.T) {
+	downs := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			downs++
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	for i, name := range []string{"001_first.yaml", "002_second.yaml"} {
+		mig := fmt.Sprintf("up:\n  name: v%[1]d\n  request:\n    method: GET\n    url: %[2]s\ndown:\n  name: undo v%[1]d\n  method: DELETE\n  url: %[2]s\n", i+1, srv.URL)
+		_ = writeFile(t, tdir, name, mig)
+	}
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n")
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	v.Set("down_yes", false)
+	if err := UpCmd.RunE(UpCmd, nil); err != nil {
+		t.Fatalf("UpCmd.RunE error: %v", err)
+	}
+	var out bytes.Buffer
+	DownCmd.SetOut(&out)
+	defer func() {
+		DownCmd.SetIn(nil)
+		DownCmd.SetOut(nil)
+	}()
+
+	// No terminal: --yes is required
+	DownCmd.SetIn(strings.NewReader("yes\n"))
+	if err := DownCmd.RunE(DownCmd, nil); err == nil || !strings.Contains(err.Error(), "--yes") || !strings.Contains(err.Error(), "versions 2, 1") {
+		t.Fatalf("expected a non-interactive error asking for --yes, got %v", err)
+	}
+
+	prev := isInteractive
+	isInteractive = func(io.Reader) bool { return true }
+	defer func() { isInteractive = prev }()
+
+	DownCmd.SetIn(strings.NewReader("y\n"))
+	if err := DownCmd.RunE(DownCmd, nil); err == nil || !strings.Contains(err.Error(), "aborted") {
+		t.Fatalf("expected the rollback to be aborted, got %v", err)
+	}
+	if downs != 0 {
+		t.Fatalf("no down request may be sent before confirmation, got %d", downs)
+	}
+
+	out.Reset()
+	DownCmd.SetIn(strings.NewReader("yes\n"))
+	if err := DownCmd.RunE(DownCmd, nil); err != nil {
+		t.Fatalf("DownCmd.RunE error: %v", err)
+	}
+	if downs != 2 {
+		t.Fatalf("expected both versions rolled back, got %d down requests", downs)
+	}
+	prompt := out.String()
+	for _, want := range []string{"current version 2, target 0", "2  002_second.yaml  (undo v2)", "1  001_first.yaml  (undo v1)", `Type "yes"`} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt %q does not contain %q", prompt, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/loykin/apirun"
+)
+
+// Supported values for --dry-run-format.
+const (
+	DryRunFormatText = "text"
+	DryRunFormatJSON = "json"
+)
+
+type dryRunMigration struct {
+	Version  int                       `json:"version"`
+	Requests []*apirun.RenderedRequest `json:"requests"`
+}
+
+type dryRunReport struct {
+	Direction  string            `json:"direction"`
+	Migrations []dryRunMigration `json:"migrations"`
+}
+
+// validateDryRunFormat rejects unknown --dry-run-format values before anything runs.
+func validateDryRunFormat(format string) error {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", DryRunFormatText, DryRunFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported dry-run format %q (use %s or %s)", format, DryRunFormatText, DryRunFormatJSON)
+}
+
+// writeDryRunReport prints the rendered requests of a dry run in the requested format.
+func writeDryRunReport(w io.Writer, direction string, results []*apirun.ExecWithVersion, format string) error {
+	report := dryRunReport{Direction: direction, Migrations: []dryRunMigration{}}
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		report.Migrations = append(report.Migrations, dryRunMigration{Version: r.Version, Requests: r.Requests})
+	}
+	if strings.EqualFold(strings.TrimSpace(format), DryRunFormatJSON) {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dry run (%s): %d migration(s)\n", direction, len(report.Migrations))
+	for _, mig := range report.Migrations {
+		fmt.Fprintf(&b, "\nversion %d\n", mig.Version)
+		for _, req := range mig.Requests {
+			fmt.Fprintf(&b, "  [%s] %s %s\n", req.Step, req.Method, req.URL)
+			for _, k := range sortedKeys(req.Headers) {
+				fmt.Fprintf(&b, "    header %s: %s\n", k, req.Headers[k])
+			}
+			for _, k := range sortedKeys(req.Queries) {
+				fmt.Fprintf(&b, "    query %s=%s\n", k, req.Queries[k])
+			}
+			if req.Body != "" {
+				b.WriteString("    body:\n")
+				for _, line := range strings.Split(strings.TrimRight(req.Body, "\n"), "\n") {
+					fmt.Fprintf(&b, "      %s\n", line)
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestUpCmd_DryRunFormatJSON_PrintsRenderedRequests(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_user.yaml", fmt.Sprintf(`---
+up:
+  name: create user
+  request:
+    method: POST
+    url: %s/users
+    headers:
+      - name: Authorization
+        value: "Basic {{.auth.a1}}"
+    queries:
+      - name: realm
+        value: "{{.env.realm}}"
+    body: '{"realm":"{{.env.realm}}"}'
+`, srv.URL))
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf(`---
+auth:
+  - type: basic
+    name: a1
+    config:
+      username: u1
+      password: p1
+env:
+  - name: realm
+    value: demo
+migrate_dir: %s
+`, tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	v.Set("dry_run", true)
+	v.Set("dry_run_from", 0)
+	v.Set("dry_run_format", "json")
+	defer func() { v.Set("dry_run", false); v.Set("dry_run_format", "text") }()
+
+	var runErr error
+	out := captureOutput(t, func() { runErr = UpCmd.RunE(UpCmd, nil) })
+	if runErr != nil {
+		t.Fatalf("up dry-run: %v", runErr)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no HTTP calls in dry-run, got %d", calls)
+	}
+	var report struct {
+		Direction  string `json:"direction"`
+		Migrations []struct {
+			Version  int                       `json:"version"`
+			Requests []*apirun.RenderedRequest `json:"requests"`
+		} `json:"migrations"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, out)
+	}
+	if report.Direction != "up" || len(report.Migrations) != 1 || len(report.Migrations[0].Requests) != 1 {
+		t.Fatalf("unexpected report: %s", out)
+	}
+	req := report.Migrations[0].Requests[0]
+	if req.Method != "POST" || req.URL != srv.URL+"/users" || req.Queries["realm"] != "demo" || req.Body != `{"realm":"demo"}` {
+		t.Fatalf("unexpected rendered request: %+v", req)
+	}
+	if req.Headers["Authorization"] != "***MASKED***" {
+		t.Fatalf("expected masked Authorization header, got %q", req.Headers["Authorization"])
+	}
+}
+
+func TestWriteDryRunReport_Text(t *testing.T) {
+	results := []*apirun.ExecWithVersion{{
+		Version: 3,
+		Requests: []*apirun.RenderedRequest{{
+			Step:    "down",
+			Method:  "DELETE",
+			URL:     "http://api.local/users/1",
+			Headers: map[string]string{"X-B": "2", "X-A": "1"},
+			Body:    "{\"a\":1}",
+		}},
+	}}
+	var buf bytes.Buffer
+	if err := writeDryRunReport(&buf, "down", results, DryRunFormatText); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Dry run (down): 1 migration(s)", "version 3", "[down] DELETE http://api.local/users/1", "header X-A: 1\n    header X-B: 2", "      {\"a\":1}"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in report:\n%s", want, out)
+		}
+	}
+}
+
+func TestValidateDryRunFormat(t *testing.T) {
+	for _, f := range []string{"", "text", "JSON"} {
+		if err := validateDryRunFormat(f); err != nil {
+			t.Fatalf("format %q: unexpected error %v", f, err)
+		}
+	}
+	if err := validateDryRunFormat("yaml"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/loykin/apirun"
+)
+
+// explainVersionGap adds a remediation hint to errors about applied versions without a
+// migration file; other errors are returned unchanged.
+func explainVersionGap(err error) error {
+	var missing *apirun.ErrMissingMigrationFile
+	switch {
+	case errors.As(err, &missing):
+		return fmt.Errorf("%w\nVersion %d is recorded as applied but its migration file is missing from migrate_dir. "+
+			"Restore the file (e.g. from version control), or check that migrate_dir points at the directory it was applied from", err, missing.Version)
+	case errors.Is(err, apirun.ErrVersionGap):
+		return fmt.Errorf("%w\nThe store records versions newer than any migration file in migrate_dir. "+
+			"Restore the deleted migration files, or check that migrate_dir and the store belong to the same project", err)
+	}
+	return err
+}
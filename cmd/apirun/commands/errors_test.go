@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestUpDownCmd_DeletedMigrationFile_ExplainsRemediation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	mig := fmt.Sprintf("up:\n  name: v\n  request:\n    method: GET\n    url: %s\ndown:\n  name: v\n  method: DELETE\n  url: %s\n", srv.URL, srv.URL)
+	_ = writeFile(t, tdir, "001_first.yaml", mig)
+	second := writeFile(t, tdir, "002_second.yaml", mig)
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n")
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	if err := UpCmd.RunE(UpCmd, nil); err != nil {
+		t.Fatalf("UpCmd.RunE error: %v", err)
+	}
+	if err := os.Remove(second); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	err := UpCmd.RunE(UpCmd, nil)
+	if !errors.Is(err, apirun.ErrVersionGap) || !strings.Contains(err.Error(), "Restore the deleted migration files") {
+		t.Fatalf("expected a version gap error with a hint, got %v", err)
+	}
+	err = DownCmd.RunE(DownCmd, nil)
+	var missing *apirun.ErrMissingMigrationFile
+	if !errors.As(err, &missing) || missing.Version != 2 || !strings.Contains(err.Error(), "Version 2 is recorded as applied") {
+		t.Fatalf("expected a missing file error with a hint, got %v", err)
+	}
+}
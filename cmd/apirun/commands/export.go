@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the migration history store (applied versions, runs, stored env) as JSON",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		st, err := openCommandStore(v, "export")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = st.Close() }()
+
+		out := strings.TrimSpace(v.GetString("export_out"))
+		var w io.Writer = os.Stdout
+		if out != "" && out != "-" {
+			f, err := os.Create(filepath.Clean(out))
+			if err != nil {
+				return fmt.Errorf("failed to create export file: %w", err)
+			}
+			defer func() { _ = f.Close() }()
+			w = f
+		}
+		if err := st.Export(w); err != nil {
+			return err
+		}
+		if w != os.Stdout {
+			fmt.Printf("Exported the store to %s\n", out)
+		}
+		return nil
+	},
+}
+
+var ImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a store export into an empty migration history store (e.g. to move to another backend)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		in := strings.TrimSpace(v.GetString("import_in"))
+		if in == "" {
+			return fmt.Errorf("--in is required")
+		}
+		f, err := os.Open(filepath.Clean(in))
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		st, err := openCommandStore(v, "import")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = st.Close() }()
+		if err := st.Import(f); err != nil {
+			return err
+		}
+		fmt.Printf("Imported %s into the store\n", in)
+		return nil
+	},
+}
+
+// openCommandStore opens the store of the command's config (see resolveConfigSource); it fails
+// when the store is disabled.
+func openCommandStore(v *viper.Viper, component string) (*apirun.Store, error) {
+	src, err := resolveConfigSource(v)
+	if err != nil {
+		return nil, err
+	}
+	loc := resolveStoreLocation(src, component)
+	if loc.disabled {
+		return nil, fmt.Errorf("store is disabled - %s needs a migration history store", component)
+	}
+	return apirun.OpenStoreFromOptions(loc.dir, loc.storeCfg)
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestExportImportCmd_MovesHistory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_v.yaml", "up:\n  name: v1\n  request:\n    method: GET\n    url: "+srv.URL+"/v1\n")
+	srcCfg := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n")
+	dstDB := filepath.Join(tdir, "copy.db")
+	dstCfg := writeFile(t, tdir, "copy.yaml", "---\nmigrate_dir: "+tdir+"\nstore:\n  type: sqlite\n  sqlite:\n    path: "+dstDB+"\n"+
+		"  table_prefix: copy\n")
+	out := filepath.Join(tdir, "history.json")
+
+	v := viper.GetViper()
+	v.Set("config", srcCfg)
+	v.Set("to", 0)
+	if err := UpCmd.RunE(UpCmd, nil); err != nil {
+		t.Fatalf("UpCmd.RunE error: %v", err)
+	}
+	v.Set("export_out", out)
+	if err := ExportCmd.RunE(ExportCmd, nil); err != nil {
+		t.Fatalf("ExportCmd.RunE error: %v", err)
+	}
+	v.Set("config", dstCfg)
+	v.Set("import_in", out)
+	if err := ImportCmd.RunE(ImportCmd, nil); err != nil {
+		t.Fatalf("ImportCmd.RunE error: %v", err)
+	}
+
+	runs := func(cfgPath string) []apirun.StoreRun {
+		loc := resolveStoreLocation(configSource{Path: cfgPath}, "test")
+		st, err := apirun.OpenStoreFromOptions(loc.dir, loc.storeCfg)
+		if err != nil {
+			t.Fatalf("OpenStoreFromOptions error: %v", err)
+		}
+		defer func() { _ = st.Close() }()
+		rs, err := st.ListRuns()
+		if err != nil {
+			t.Fatalf("ListRuns error: %v", err)
+		}
+		return rs
+	}
+	src, dst := runs(srcCfg), runs(dstCfg)
+	if len(src) != 1 || !reflect.DeepEqual(src, dst) {
+		t.Fatalf("expected the history to be copied, got %v and %v", src, dst)
+	}
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/pkg/status"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	historyVersion    int
+	historyDirection  string
+	historyFailedOnly bool
+	historyLimit      int
+	historySince      string
+	historyJSON       bool
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show migration run history, optionally filtered by version, direction or failure",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		src, err := resolveConfigSource(v)
+		if err != nil {
+			return err
+		}
+		loc := resolveStoreLocation(src, "history")
+		if loc.disabled {
+			logger := common.GetLogger().WithComponent("history")
+			logger.Info("store is disabled - no migration history available")
+			return nil
+		}
+
+		since, err := parseTimeOrAge(historySince, time.Now())
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		st, err := apirun.OpenStoreFromOptions(loc.dir, loc.storeCfg)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = st.Close() }()
+
+		items, err := status.HistoryFromStore(st, apirun.RunFilter{
+			Version:    historyVersion,
+			Direction:  historyDirection,
+			FailedOnly: historyFailedOnly,
+			Limit:      historyLimit,
+			Since:      since,
+		})
+		if err != nil {
+			return err
+		}
+		if historyJSON {
+			out, err := status.FormatHistoryJSON(items)
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		}
+		fmt.Print(status.FormatHistoryTable(items))
+		return nil
+	},
+}
+
+func init() {
+	HistoryCmd.Flags().IntVar(&historyVersion, "version", 0, "only show runs of this version (0 = all)")
+	HistoryCmd.Flags().StringVar(&historyDirection, "direction", "", "only show runs in this direction: up, down, baseline or skipped")
+	HistoryCmd.Flags().BoolVar(&historyFailedOnly, "failed-only", false, "only show failed runs")
+	HistoryCmd.Flags().IntVar(&historyLimit, "limit", 0, "show only the N most recent matching runs (0 = all)")
+	HistoryCmd.Flags().StringVar(&historySince, "since", "", "only show runs from this age (7d, 12h) or RFC3339 time on")
+	HistoryCmd.Flags().BoolVar(&historyJSON, "json", false, "print the runs as a JSON array instead of a table")
+}
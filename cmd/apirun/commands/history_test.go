@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/pkg/status"
+	"github.com/spf13/viper"
+)
+
+func seedHistory(t *testing.T, dir string) {
+	t.Helper()
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(dir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(dir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions: %v", err)
+	}
+	defer func() { _ = st.Close() }()
+	for _, r := range []struct {
+		version   int
+		direction string
+		code      int
+		failed    bool
+	}{{1, "up", 200, false}, {2, "up", 400, true}, {2, "up", 201, false}, {2, "down", 204, false}} {
+		if err := st.RecordRun(r.version, r.direction, r.code, nil, nil, r.failed); err != nil {
+			t.Fatalf("RecordRun: %v", err)
+		}
+	}
+}
+
+func runHistory(t *testing.T, version int, direction string, failedOnly bool, limit int, asJSON bool) (string, error) {
+	t.Helper()
+	historyVersion, historyDirection, historyFailedOnly, historyLimit, historyJSON = version, direction, failedOnly, limit, asJSON
+	t.Cleanup(func() {
+		historyVersion, historyDirection, historyFailedOnly, historyLimit, historyJSON = 0, "", false, 0, false
+	})
+	var err error
+	out := captureOutput(t, func() { err = HistoryCmd.RunE(HistoryCmd, nil) })
+	return out, err
+}
+
+func TestHistoryCmd_FiltersAndFormats(t *testing.T) {
+	tdir := t.TempDir()
+	seedHistory(t, tdir)
+	viper.GetViper().Set("config", writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n"))
+
+	out, err := runHistory(t, 0, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[2], "400") {
+		t.Fatalf("unexpected table:\n%s", out)
+	}
+
+	out, err = runHistory(t, 2, "up", false, 1, true)
+	if err != nil {
+		t.Fatalf("history --json: %v", err)
+	}
+	var items []status.HistoryItem
+	if err := json.Unmarshal([]byte(out), &items); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if len(items) != 1 || items[0].ID != 3 || items[0].StatusCode != 201 {
+		t.Fatalf("expected the newest up run of version 2, got %+v", items)
+	}
+
+	out, err = runHistory(t, 0, "", true, 0, true)
+	if err != nil || !strings.Contains(out, `"failed": true`) || strings.Contains(out, `"failed": false`) {
+		t.Fatalf("expected only failed runs, got %v:\n%s", err, out)
+	}
+
+	out, err = runHistory(t, 9, "", false, 0, false)
+	if err != nil || out != "no runs found\n" {
+		t.Fatalf("expected empty result, got %v %q", err, out)
+	}
+
+	if _, err := runHistory(t, 0, "sideways", false, 0, false); err == nil || !strings.Contains(err.Error(), "invalid direction") {
+		t.Fatalf("expected invalid direction error, got %v", err)
+	}
+}
+
+func TestHistoryCmd_Since(t *testing.T) {
+	tdir := t.TempDir()
+	seedHistory(t, tdir)
+	viper.GetViper().Set("config", writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n"))
+	defer func() { historySince = "" }()
+
+	historySince = "1h"
+	out, err := runHistory(t, 0, "", false, 0, false)
+	if err != nil || len(strings.Split(strings.TrimSpace(out), "\n")) != 5 {
+		t.Fatalf("expected every run recorded in the last hour, got %v:\n%s", err, out)
+	}
+	historySince = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if out, err = runHistory(t, 0, "", false, 0, false); err != nil || out != "no runs found\n" {
+		t.Fatalf("expected no runs after a future time, got %v %q", err, out)
+	}
+	historySince = "soon"
+	if _, err := runHistory(t, 0, "", false, 0, false); err == nil || !strings.Contains(err.Error(), "--since") {
+		t.Fatalf("expected an invalid --since error, got %v", err)
+	}
+}
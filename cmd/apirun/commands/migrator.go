@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/cmd/apirun/config"
+	"github.com/spf13/viper"
+
+	ienv "github.com/loykin/apirun/pkg/env"
+)
+
+// MigratorOptions tunes BuildMigrator for a command.
+type MigratorOptions struct {
+	// Wait runs the config's dependency wait checks before the migrator is returned
+	Wait bool
+	// SetupLogging configures the global logger from the config's logging section
+	SetupLogging bool
+}
+
+// BuildMigrator loads the config file of --config-dir or --config (see resolveConfigSource) once
+// and returns a Migrator for the migrations, env, auth, client and store it configures. Without a
+// config file the migrations are read from the --config-dir migration folder, else from
+// ./config/migration, with a sqlite store under that directory.
+func BuildMigrator(v *viper.Viper, opts MigratorOptions) (*apirun.Migrator, error) {
+	src, err := resolveConfigSource(v)
+	if err != nil {
+		return nil, err
+	}
+	configPath := src.Path
+	ctx := context.Background()
+	m := &apirun.Migrator{Env: ienv.New(), EnvFiles: v.GetStringSlice("env_files")}
+	var doc config.ConfigDoc
+	if strings.TrimSpace(configPath) != "" {
+		if err := doc.Load(configPath); err != nil {
+			return nil, fmt.Errorf("failed to load configuration file '%s': %w\nPlease verify the file exists and contains valid YAML", configPath, err)
+		}
+		if opts.SetupLogging {
+			if err := doc.SetupLogging(); err != nil {
+				return nil, fmt.Errorf("failed to setup logging from config: %w", err)
+			}
+		}
+		envFromCfg, err := doc.GetEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to process environment variables from config: %w", err)
+		}
+		if opts.Wait {
+			if err := DoWait(ctx, envFromCfg, doc.Wait, doc.Client); err != nil {
+				return nil, fmt.Errorf("dependency wait check failed: %w\nCheck that required services are running and accessible", err)
+			}
+		}
+		// The migrator acquires the tokens within its runs, where NTLM credentials are kept
+		auths, err := doc.AuthEntries(envFromCfg)
+		if err != nil {
+			return nil, fmt.Errorf("authentication setup failed: %w\nVerify auth configuration in config file", err)
+		}
+		m.Auth = auths
+		m.Env = envFromCfg
+		if strings.TrimSpace(doc.DelayBetweenMigrations) != "" {
+			delay, err := time.ParseDuration(doc.DelayBetweenMigrations)
+			if err != nil {
+				return nil, fmt.Errorf("invalid delay_between_migrations %q: %w", doc.DelayBetweenMigrations, err)
+			}
+			m.DelayBetweenMigrations = delay
+		}
+		transport, breaker, err := doc.Client.ToClientOptions()
+		if err != nil {
+			return nil, err
+		}
+		m.Transport = transport
+		m.CircuitBreaker = breaker
+		m.TLSConfig = clientTLSConfig(doc.Client)
+		m.CAFile = doc.Client.TLS.CAFile
+		m.DefaultHeaders = doc.Client.DefaultHeaders
+		m.MaxResponseBodyBytes = doc.Client.MaxResponseBodyBytes
+		m.RenderBodyDefault = doc.RenderBody
+		m.MaxParallel = doc.MaxParallel
+		m.StrictChecksums = doc.StrictChecksums
+		m.VersionPattern = doc.VersionPattern
+		m.Notify = doc.Notify.ToNotify()
+		// Store configuration is controlled via config file (store.disabled)
+		m.StoreConfig = doc.Store.ToStorOptions()
+		m.SaveResponseBody = doc.Store.SaveResponseBody
+		m.SaveResponseHeaders = doc.Store.SaveResponseHeaders
+	}
+	dir := src.migrateDir(doc.MigrateDir)
+	// Normalize to absolute path to avoid working-directory surprises
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	m.Dir = dir
+	if m.StoreConfig == nil {
+		// default to sqlite under dir explicitly
+		sc := &apirun.StoreConfig{}
+		sc.Config.Driver = apirun.DriverSqlite
+		sc.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(dir, apirun.StoreDBFileName)}
+		m.StoreConfig = sc
+	}
+	return m, nil
+}
+
+// clientTLSConfig returns the TLS versions and verification of the client settings; the CAs of
+// client.tls.ca_file are added by the migrator (Migrator.CAFile).
+func clientTLSConfig(clientCfg config.ClientConfig) *tls.Config {
+	// #nosec G402 -- InsecureSkipVerify only when client.insecure is explicitly configured
+	return &tls.Config{
+		MinVersion:         parseTLSVersion(clientCfg.MinTLSVersion),
+		MaxVersion:         parseTLSVersion(clientCfg.MaxTLSVersion),
+		InsecureSkipVerify: clientCfg.Insecure,
+	}
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestBuildMigrator_AppliesConfig(t *testing.T) {
+	tdir := t.TempDir()
+	migDir := filepath.Join(tdir, "migrations")
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+migDir+"\ndelay_between_migrations: 25ms\nstrict_checksums: true\n"+
+		"max_parallel: 3\nstore:\n  save_response_body: true\n  max_stored_env_entries: 9\nclient:\n  max_response_body_bytes: 4096\n  insecure: true\n  min_tls_version: \"1.2\"\nenv:\n  - name: base\n    value: http://api\n")
+
+	v := viper.New()
+	v.Set("config", cfgPath)
+	m, err := BuildMigrator(v, MigratorOptions{})
+	if err != nil {
+		t.Fatalf("BuildMigrator: %v", err)
+	}
+	if m.Dir != migDir || m.DelayBetweenMigrations != 25*time.Millisecond || !m.StrictChecksums || m.MaxParallel != 3 || !m.SaveResponseBody || m.MaxResponseBodyBytes != 4096 {
+		t.Fatalf("config not applied: %+v", m)
+	}
+	if m.TLSConfig == nil || !m.TLSConfig.InsecureSkipVerify || m.TLSConfig.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("client TLS settings not applied: %+v", m.TLSConfig)
+	}
+	if got := m.Env.GetString("global", "base"); got != "http://api" {
+		t.Fatalf("env from config not applied: %v", m.Env.Global)
+	}
+	sc, ok := m.StoreConfig.Config.DriverConfig.(*apirun.SqliteConfig)
+	if !ok || sc.Path != "" || m.StoreConfig.StoredEnv.MaxEntries != 9 {
+		t.Fatalf("expected the default sqlite store with the store limits, got %+v", m.StoreConfig)
+	}
+}
+
+func TestBuildMigrator_ConfigFromEnvironment(t *testing.T) {
+	tdir := t.TempDir()
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmax_parallel: 2\n")
+	t.Setenv("APIMIGRATE_CONFIG", cfgPath)
+
+	v := viper.New()
+	v.Set("config", "")
+	m, err := BuildMigrator(v, MigratorOptions{})
+	if err != nil {
+		t.Fatalf("BuildMigrator: %v", err)
+	}
+	// Without migrate_dir the migrations sit next to the config file
+	if m.Dir != tdir || m.MaxParallel != 2 {
+		t.Fatalf("APIMIGRATE_CONFIG not used: dir=%s max_parallel=%d", m.Dir, m.MaxParallel)
+	}
+}
+
+func TestBuildMigrator_Errors(t *testing.T) {
+	tdir := t.TempDir()
+	cases := map[string]struct {
+		config string
+		want   string
+	}{
+		"missing file":  {filepath.Join(tdir, "missing.yaml"), "failed to load configuration file"},
+		"invalid delay": {writeFile(t, tdir, "delay.yaml", "---\ndelay_between_migrations: soon\n"), "invalid delay_between_migrations"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("config", tc.config)
+			if _, err := BuildMigrator(v, MigratorOptions{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected %q error, got %v", tc.want, err)
+			}
+		})
+	}
+}
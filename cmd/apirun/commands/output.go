@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/loykin/apirun"
+)
+
+// OutputSummary is the data of an --output template, rendered once per migration of a run, e.g.
+// 'v{{.Version}} -> {{.StatusCode}} in {{.Duration}}'.
+type OutputSummary struct {
+	Version   int
+	Direction string // up or down
+	// StatusCode is 0 when the step got no response or was skipped.
+	StatusCode int
+	// Env holds the values extracted by env_from ({{.Env.user_id}}).
+	Env        map[string]string
+	Method     string
+	URL        string // secrets masked
+	Duration   time.Duration
+	DurationMs int64
+	Failed     bool
+	Skipped    bool
+}
+
+// ParseOutputTemplate parses an --output value; "" returns nil, which keeps the default output.
+func ParseOutputTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("output").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --output template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// WriteOutputSummary renders tmpl for each result of a run, one line per migration.
+func WriteOutputSummary(w io.Writer, direction string, results []*apirun.ExecWithVersion, tmpl *template.Template) error {
+	if tmpl == nil {
+		return nil
+	}
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		s := OutputSummary{Version: r.Version, Direction: direction, Env: map[string]string{}, Failed: r.Failed, Skipped: r.Skipped}
+		if res := r.Result; res != nil {
+			s.StatusCode = res.StatusCode
+			if res.ExtractedEnv != nil {
+				s.Env = res.ExtractedEnv
+			}
+			s.Method = res.Method
+			s.URL = apirun.MaskSensitiveData(res.URL)
+			s.DurationMs = res.DurationMs
+			s.Duration = time.Duration(res.DurationMs) * time.Millisecond
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, s); err != nil {
+			return fmt.Errorf("render --output for version %d: %w", r.Version, err)
+		}
+		line := strings.TrimRight(b.String(), "\n")
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
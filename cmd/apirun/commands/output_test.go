@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestWriteOutputSummary_RendersOneLinePerResult(t *testing.T) {
+	tmpl, err := ParseOutputTemplate(`v{{.Version}} {{.Method}} -> {{.StatusCode}} id={{.Env.id}}{{if .Failed}} FAILED{{end}}{{"\n"}}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	results := []*apirun.ExecWithVersion{
+		{Version: 1, Result: &apirun.ExecResult{StatusCode: 201, Method: "POST", ExtractedEnv: map[string]string{"id": "u-1"}}},
+		nil,
+		{Version: 2, Failed: true, Result: &apirun.ExecResult{StatusCode: 500, Method: "PUT"}},
+		{Version: 3, Skipped: true},
+	}
+	var buf bytes.Buffer
+	if err := WriteOutputSummary(&buf, "up", results, tmpl); err != nil {
+		t.Fatalf("WriteOutputSummary: %v", err)
+	}
+	want := "v1 POST -> 201 id=u-1\nv2 PUT -> 500 id= FAILED\nv3  -> 0 id=\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+
+	if tmpl, err := ParseOutputTemplate("  "); err != nil || tmpl != nil {
+		t.Fatalf("blank template should keep the default output: %v, %v", tmpl, err)
+	}
+	if _, err := ParseOutputTemplate("{{.Version"); err == nil || !strings.Contains(err.Error(), "--output") {
+		t.Fatalf("expected a template error, got %v", err)
+	}
+}
+
+func TestUpDownCmd_OutputTemplate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"u-1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_user.yaml", fmt.Sprintf(`---
+up:
+  name: create user
+  request:
+    method: POST
+    url: %[1]s/users
+  response:
+    result_code: ["201"]
+    env_from:
+      user_id: id
+down:
+  name: delete user
+  method: DELETE
+  url: %[1]s/users/{{.env.user_id}}
+`, srv.URL))
+	cfgPath := writeFile(t, tdir, "config.yaml", "migrate_dir: "+tdir+"\n")
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	v.Set("dry_run", false)
+	v.Set("up_output", "v{{.Version}} {{.Direction}} -> {{.StatusCode}} user={{.Env.user_id}}")
+	v.Set("down_output", "v{{.Version}} {{.Direction}} -> {{.StatusCode}}")
+	v.Set("down_yes", true)
+	defer func() { v.Set("up_output", ""); v.Set("down_output", ""); v.Set("down_yes", false) }()
+
+	var runErr error
+	out := captureOutput(t, func() { runErr = UpCmd.RunE(UpCmd, nil) })
+	if runErr != nil {
+		t.Fatalf("up: %v", runErr)
+	}
+	if out != "v1 up -> 201 user=u-1\n" {
+		t.Fatalf("up output = %q", out)
+	}
+	out = captureOutput(t, func() { runErr = DownCmd.RunE(DownCmd, nil) })
+	if runErr != nil {
+		t.Fatalf("down: %v", runErr)
+	}
+	if out != "v1 down -> 204\n" {
+		t.Fatalf("down output = %q", out)
+	}
+
+	v.Set("dry_run", true)
+	defer v.Set("dry_run", false)
+	if err := UpCmd.RunE(UpCmd, nil); err == nil || !strings.Contains(err.Error(), "--dry-run") {
+		t.Fatalf("expected --output with --dry-run to fail, got %v", err)
+	}
+}
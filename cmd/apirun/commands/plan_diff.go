@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var PlanDiffCmd = &cobra.Command{
+	Use:   "plan-diff",
+	Short: "Probe a live environment and report which pending migrations are already satisfied",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		format := v.GetString("plan_diff_format")
+		if err := validateDryRunFormat(format); err != nil {
+			return err
+		}
+		m, err := BuildMigrator(v, MigratorOptions{Wait: true})
+		if err != nil {
+			return err
+		}
+		diff, err := m.PlanDiff(context.Background(), v.GetInt("plan_diff_to"))
+		if err != nil {
+			return explainVersionGap(err)
+		}
+		return writePlanDiffReport(os.Stdout, diff, format)
+	},
+}
+
+// writePlanDiffReport prints a plan diff in the requested format (text or json).
+func writePlanDiffReport(w io.Writer, diff *apirun.PlanDiff, format string) error {
+	if strings.EqualFold(strings.TrimSpace(format), DryRunFormatJSON) {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan diff from version %d: %d pending, %d would apply, %d already satisfied, %d unknown\n",
+		diff.CurrentVersion, len(diff.Migrations), diff.Count(apirun.DiffWouldApply), diff.Count(apirun.DiffAlreadySatisfied), diff.Count(apirun.DiffUnknown))
+	for _, e := range diff.Migrations {
+		line := fmt.Sprintf("  %d %s: %s", e.Version, e.File, e.Status)
+		switch {
+		case !e.Probed:
+			line += " (no probe)"
+		case e.StatusCode != 0 && e.Reason != "":
+			line += fmt.Sprintf(" (HTTP %d: %s)", e.StatusCode, e.Reason)
+		case e.StatusCode != 0:
+			line += fmt.Sprintf(" (HTTP %d)", e.StatusCode)
+		case e.Reason != "":
+			line += fmt.Sprintf(" (%s)", e.Reason)
+		}
+		b.WriteString(line + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestPlanDiffCmd_TextAndJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("plan-diff sent %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Path == "/users/alice" {
+			_, _ = w.Write([]byte(`{"name":"alice"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_alice.yaml", fmt.Sprintf(`---
+up:
+  request:
+    method: POST
+    url: %s/users
+  probe:
+    request:
+      url: %s/users/alice
+    assert:
+      name: alice
+`, srv.URL, srv.URL))
+	_ = writeFile(t, tdir, "002_bob.yaml", fmt.Sprintf(`---
+up:
+  request:
+    method: POST
+    url: %s/users
+  probe:
+    request:
+      url: %s/users/bob
+`, srv.URL, srv.URL))
+	_ = writeFile(t, tdir, "003_noprobe.yaml", fmt.Sprintf("---\nup:\n  request:\n    method: POST\n    url: %s/teams\n", srv.URL))
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("---\nmigrate_dir: %s\n", tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("plan_diff_format", "text")
+	defer v.Set("plan_diff_format", nil)
+	out := captureOutput(t, func() {
+		if err := PlanDiffCmd.RunE(PlanDiffCmd, nil); err != nil {
+			t.Fatalf("plan-diff: %v", err)
+		}
+	})
+	for _, want := range []string{
+		"3 pending, 2 would apply, 1 already satisfied, 0 unknown",
+		"1 001_alice.yaml: already-satisfied (HTTP 200)",
+		"2 002_bob.yaml: would-apply (HTTP 404",
+		"3 003_noprobe.yaml: would-apply (no probe)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	v.Set("plan_diff_format", "json")
+	out = captureOutput(t, func() {
+		if err := PlanDiffCmd.RunE(PlanDiffCmd, nil); err != nil {
+			t.Fatalf("plan-diff json: %v", err)
+		}
+	})
+	var diff apirun.PlanDiff
+	if err := json.Unmarshal([]byte(out), &diff); err != nil {
+		t.Fatalf("decode json: %v\n%s", err, out)
+	}
+	if len(diff.Migrations) != 3 || diff.Migrations[0].Status != apirun.DiffAlreadySatisfied || diff.Migrations[2].Probed {
+		t.Fatalf("unexpected json report: %+v", diff)
+	}
+	if cur := toCurrentVersion(t, tdir); cur != 0 {
+		t.Fatalf("plan-diff must not apply migrations, got version %d", cur)
+	}
+
+	v.Set("plan_diff_format", "yaml")
+	if err := PlanDiffCmd.RunE(PlanDiffCmd, nil); err == nil {
+		t.Fatalf("expected an unsupported format error")
+	}
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var PruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old migration run records, keeping the latest N per version",
+	Long: `Delete migration_runs records older than --before, except the newest --keep runs of each
+version. Applied versions and stored env are never touched. At least one of --before and
+--keep is required; with only --keep every version keeps its newest runs whatever their age.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		before, err := parseTimeOrAge(v.GetString("prune_before"), time.Now())
+		if err != nil {
+			return fmt.Errorf("invalid --before: %w", err)
+		}
+		keep := v.GetInt("prune_keep")
+		st, err := openCommandStore(v, "prune")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = st.Close() }()
+		n, err := st.PruneRuns(before, keep)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pruned %d migration run record(s)\n", n)
+		return nil
+	},
+}
+
+// parseTimeOrAge reads a point in time given as an RFC3339 timestamp or as an age before now,
+// e.g. "30d", "12h" or "1h30m" ("" = zero time).
+func parseTimeOrAge(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%q is not a timestamp or an age such as 30d or 12h", s)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a timestamp or an age such as 30d or 12h", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestParseTimeOrAge(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"30d", now.AddDate(0, 0, -30)},
+		{"12h", now.Add(-12 * time.Hour)},
+		{"1h30m", now.Add(-90 * time.Minute)},
+		{"2025-01-02T03:04:05Z", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeOrAge(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Fatalf("parseTimeOrAge(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"30 days", "-1d", "-5h", "yesterday"} {
+		if _, err := parseTimeOrAge(bad, now); err == nil {
+			t.Fatalf("parseTimeOrAge(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPruneCmd_KeepsLatestPerVersion(t *testing.T) {
+	tdir := t.TempDir()
+	seedHistory(t, tdir)
+	v := viper.GetViper()
+	v.Set("config", writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n"))
+	v.Set("prune_keep", 1)
+	defer v.Set("prune_keep", 0)
+
+	var err error
+	out := captureOutput(t, func() { err = PruneCmd.RunE(PruneCmd, nil) })
+	if err != nil || !strings.Contains(out, "Pruned 2 migration run record(s)") {
+		t.Fatalf("prune: %v, output %q", err, out)
+	}
+
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(tdir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(tdir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions: %v", err)
+	}
+	defer func() { _ = st.Close() }()
+	runs, err := apirun.ListRuns(st)
+	if err != nil || len(runs) != 2 || runs[0].Version != 1 || runs[1].Version != 2 || runs[1].Direction != "down" {
+		t.Fatalf("runs after prune: %v %+v", err, runs)
+	}
+
+	// Neither --before nor --keep: refuse instead of clearing the history
+	v.Set("prune_keep", 0)
+	if err := PruneCmd.RunE(PruneCmd, nil); err == nil {
+		t.Fatalf("expected prune without --before or --keep to fail")
+	}
+}
@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var RedoCmd = &cobra.Command{
+	Use:   "redo",
+	Short: "Roll back and re-apply the latest applied migration (or --to N)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		m, err := BuildMigrator(v, MigratorOptions{Wait: true})
+		if err != nil {
+			return err
+		}
+		_, err = m.RedoVersion(context.Background(), v.GetInt("redo_to"))
+		return explainVersionGap(err)
+	},
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRedoCmd_LatestAndSpecificVersion(t *testing.T) {
+	calls := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method+" "+r.URL.Path]++
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	for i := 1; i <= 2; i++ {
+		m := fmt.Sprintf(`---
+up:
+  name: v%d
+  request:
+    method: POST
+    url: %s/item%d
+  response:
+    result_code: ["200"]
+down:
+  name: v%ddown
+  method: DELETE
+  url: %s/item%d
+`, i, srv.URL, i, i, srv.URL, i)
+		_ = writeFile(t, tdir, fmt.Sprintf("%03d_item.yaml", i), m)
+	}
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("---\nmigrate_dir: %s\ndelay_between_migrations: 1ms\n", tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("version", 2)
+	defer v.Set("version", nil)
+	if err := ToCmd.RunE(ToCmd, nil); err != nil {
+		t.Fatalf("to 2: %v", err)
+	}
+
+	if err := RedoCmd.RunE(RedoCmd, nil); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if calls["DELETE /item2"] != 1 || calls["POST /item2"] != 2 || calls["DELETE /item1"] != 0 {
+		t.Fatalf("unexpected calls after redo: %v", calls)
+	}
+
+	v.Set("redo_to", 1)
+	defer v.Set("redo_to", nil)
+	if err := RedoCmd.RunE(RedoCmd, nil); err != nil {
+		t.Fatalf("redo --to 1: %v", err)
+	}
+	if calls["DELETE /item1"] != 1 || calls["POST /item1"] != 2 {
+		t.Fatalf("unexpected calls after redo --to 1: %v", calls)
+	}
+	if cur := toCurrentVersion(t, tdir); cur != 2 {
+		t.Fatalf("expected version 2, got %d", cur)
+	}
+}
+
+func TestRedoCmd_NoDownSection_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_only.yaml", fmt.Sprintf(`---
+up:
+  name: only
+  request:
+    method: GET
+    url: %s/only
+`, srv.URL))
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("---\nmigrate_dir: %s\ndelay_between_migrations: 1ms\n", tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("version", 1)
+	defer v.Set("version", nil)
+	if err := ToCmd.RunE(ToCmd, nil); err != nil {
+		t.Fatalf("to 1: %v", err)
+	}
+	err := RedoCmd.RunE(RedoCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "has no down section") {
+		t.Fatalf("expected missing down error, got %v", err)
+	}
+}
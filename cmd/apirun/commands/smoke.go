@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var SmokeCmd = &cobra.Command{
+	Use:   "smoke",
+	Short: "Run the read-only steps of the migrations against many targets and report a pass/fail matrix",
+	Long: `Smoke-test the migration set against a fleet of endpoints without changing them.
+
+Each target base URL is set as the global env value --url-var (default base_url), so migrations
+should build their URLs from {{.env.base_url}}. Against every target the migrations are walked in
+version order: GET/HEAD up requests and those of migrations tagged --readonly-tag are sent, other
+migrations send their up.probe, and the rest are skipped. The store is not used. The command fails
+when a step fails on any target.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		format := v.GetString("smoke_format")
+		if err := validateDryRunFormat(format); err != nil {
+			return err
+		}
+		targets, err := smokeTargets(v.GetStringSlice("smoke_targets"), v.GetString("smoke_targets_file"))
+		if err != nil {
+			return err
+		}
+		// No dependency wait: the targets are checked as they are
+		m, err := BuildMigrator(v, MigratorOptions{})
+		if err != nil {
+			return err
+		}
+		report, err := m.Smoke(context.Background(), apirun.SmokeOptions{
+			Targets:       targets,
+			URLVar:        v.GetString("smoke_url_var"),
+			ReadOnlyTag:   v.GetString("smoke_readonly_tag"),
+			Parallel:      v.GetInt("smoke_parallel"),
+			TargetVersion: v.GetInt("smoke_to"),
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeSmokeReport(os.Stdout, report, format); err != nil {
+			return err
+		}
+		if n := report.Failed(); n > 0 {
+			return fmt.Errorf("smoke test failed on %d of %d targets", n, len(report.Targets))
+		}
+		return nil
+	},
+}
+
+// smokeTargets returns the --target URLs followed by those of the targets file (one per line,
+// blank lines and # comments ignored).
+func smokeTargets(flagTargets []string, file string) ([]string, error) {
+	var targets []string
+	for _, t := range flagTargets {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	if strings.TrimSpace(file) != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read targets file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				targets = append(targets, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read targets file: %w", err)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets: pass --target URL (repeatable) or --targets-file")
+	}
+	return targets, nil
+}
+
+// writeSmokeReport prints a smoke report in the requested format: text prints a matrix of the
+// migrations (rows) against the targets (columns) followed by the failure reasons; json the
+// report as is.
+func writeSmokeReport(w io.Writer, report *apirun.SmokeReport, format string) error {
+	if strings.EqualFold(strings.TrimSpace(format), DryRunFormatJSON) {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	failed := report.Failed()
+	if _, err := fmt.Fprintf(w, "Smoke test of %d migrations against %d targets: %d passed, %d failed\n",
+		len(report.Migrations), len(report.Targets), len(report.Targets)-failed, failed); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"VERSION", "FILE"}
+	for _, t := range report.Targets {
+		header = append(header, t.URL)
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for i, mig := range report.Migrations {
+		row := []string{fmt.Sprint(mig.Version), mig.File}
+		for _, t := range report.Targets {
+			row = append(row, smokeCell(t.Steps[i]))
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	result := []string{"RESULT", ""}
+	for _, t := range report.Targets {
+		if t.Passed {
+			result = append(result, "PASS")
+		} else {
+			result = append(result, "FAIL")
+		}
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(result, "\t"))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, t := range report.Targets {
+		for i, s := range t.Steps {
+			if !s.Passed {
+				fmt.Fprintf(&b, "  %s %d %s: %s\n", t.URL, s.Version, report.Migrations[i].File, s.Reason)
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w, "Failures:\n"+b.String())
+	return err
+}
+
+// smokeCell renders a step of the smoke matrix, e.g. "pass (probe)" or "FAIL (HTTP 500)".
+func smokeCell(s apirun.SmokeStep) string {
+	if s.Mode == apirun.SmokeSkipped {
+		return "skip"
+	}
+	cell := "pass"
+	if !s.Passed {
+		cell = "FAIL"
+	}
+	switch {
+	case s.Mode == apirun.SmokeProbe && s.StatusCode != 0:
+		cell += fmt.Sprintf(" (probe, HTTP %d)", s.StatusCode)
+	case s.Mode == apirun.SmokeProbe:
+		cell += " (probe)"
+	case s.StatusCode != 0:
+		cell += fmt.Sprintf(" (HTTP %d)", s.StatusCode)
+	}
+	return cell
+}
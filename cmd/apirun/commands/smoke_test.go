@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func TestSmokeCmd_HealthyAndFailingTargets(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	target := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			methods = append(methods, r.Method)
+			mu.Unlock()
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"id":"1"}`))
+		}))
+	}
+	healthy, failing := target(http.StatusOK), target(http.StatusInternalServerError)
+	defer healthy.Close()
+	defer failing.Close()
+
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_health.yaml", "---\nup:\n  request:\n    method: GET\n    url: \"{{.env.base_url}}/health\"\n    retry: { max_attempts: 1 }\n")
+	_ = writeFile(t, tdir, "002_user.yaml", `---
+up:
+  request:
+    method: POST
+    url: "{{.env.base_url}}/users"
+  probe:
+    request:
+      url: "{{.env.base_url}}/users/alice"
+      retry: { max_attempts: 1 }
+`)
+	_ = writeFile(t, tdir, "003_team.yaml", "---\nup:\n  request:\n    method: POST\n    url: \"{{.env.base_url}}/teams\"\n")
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("---\nmigrate_dir: %s\n", tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("smoke_targets", []string{healthy.URL, failing.URL})
+	v.Set("smoke_parallel", 2)
+	v.Set("smoke_format", "text")
+	defer func() {
+		for _, k := range []string{"smoke_targets", "smoke_parallel", "smoke_format"} {
+			v.Set(k, nil)
+		}
+	}()
+	var runErr error
+	out := captureOutput(t, func() { runErr = SmokeCmd.RunE(SmokeCmd, nil) })
+	if runErr == nil || !strings.Contains(runErr.Error(), "smoke test failed on 1 of 2 targets") {
+		t.Fatalf("expected failure on 1 of 2 targets, got %v", runErr)
+	}
+	for _, want := range []string{
+		"Smoke test of 3 migrations against 2 targets: 1 passed, 1 failed",
+		"pass (HTTP 200)",
+		"FAIL (HTTP 500)",
+		"pass (probe, HTTP 200)",
+		"FAIL (probe, HTTP 500)",
+		"skip",
+		"Failures:\n  " + failing.URL + " 1 001_health.yaml: status 500",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	mu.Lock()
+	for _, m := range methods {
+		if m != http.MethodGet {
+			t.Fatalf("smoke sent a %s request", m)
+		}
+	}
+	mu.Unlock()
+
+	v.Set("smoke_format", "json")
+	out = captureOutput(t, func() { runErr = SmokeCmd.RunE(SmokeCmd, nil) })
+	if runErr == nil {
+		t.Fatalf("expected an error for the failing target")
+	}
+	var report apirun.SmokeReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("decode json: %v\n%s", err, out)
+	}
+	if len(report.Targets) != 2 || !report.Targets[0].Passed || report.Targets[1].Passed {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if s := report.Targets[1].Steps[2]; s.Mode != apirun.SmokeSkipped || !s.Passed {
+		t.Fatalf("expected the POST without probe to be skipped, got %+v", s)
+	}
+}
+
+func TestSmokeTargets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(file, []byte("# fleet\nhttp://b\n\n  http://c  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := smokeTargets([]string{"http://a", " "}, file)
+	if err != nil || strings.Join(got, ",") != "http://a,http://b,http://c" {
+		t.Fatalf("smokeTargets = %v, %v", got, err)
+	}
+	if _, err := smokeTargets(nil, ""); err == nil {
+		t.Fatalf("expected an error without targets")
+	}
+}
@@ -2,7 +2,10 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/loykin/apirun/internal/common"
 	"github.com/loykin/apirun/pkg/orchestrator"
@@ -32,16 +35,20 @@ var stagesDownCmd = &cobra.Command{
 
 var stagesStatusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Show status of stages and their dependencies",
+	Short: "Show status of stages and their dependencies, as recorded in the stages state file",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		configPath, _ := cmd.Flags().GetString("config")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		asJSON, _ := cmd.Flags().GetBool("json")
 
-		orch, err := orchestrator.LoadFromFile(configPath)
+		orch, err := loadStagesStatus(configPath)
 		if err != nil {
 			return err
 		}
 
+		if asJSON {
+			return writeStagesStatusJSON(os.Stdout, orch)
+		}
 		return showStagesStatus(orch, verbose)
 	},
 }
@@ -64,25 +71,64 @@ var stagesValidateCmd = &cobra.Command{
 	},
 }
 
+var stagesGraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print the stage dependency graph in Graphviz DOT, stages that run in parallel on one rank",
+	Long: `Print the stage dependency graph in Graphviz DOT: an edge points from a stage to each stage
+depending on it, and the stages of each execution batch share a rank in a "batch N" cluster.
+Render it with Graphviz, e.g. "dot -Tsvg stages.dot -o stages.svg".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, _ := cmd.Flags().GetString("config")
+		out, _ := cmd.Flags().GetString("out")
+
+		orch, err := orchestrator.LoadFromFile(configPath)
+		if err != nil {
+			return err
+		}
+		return writeStagesGraph(orch, out)
+	},
+}
+
+// writeStagesGraph writes the DOT graph of orch to the file out, or to stdout when out is empty.
+func writeStagesGraph(orch *orchestrator.Orchestrator, out string) error {
+	dot := orch.GraphDOT()
+	if out == "" {
+		_, err := io.WriteString(os.Stdout, dot)
+		return err
+	}
+	if err := os.WriteFile(out, []byte(dot), 0o600); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
 func init() {
 	// Add subcommands to stages
 	StagesCmd.AddCommand(stagesUpCmd)
 	StagesCmd.AddCommand(stagesDownCmd)
 	StagesCmd.AddCommand(stagesStatusCmd)
 	StagesCmd.AddCommand(stagesValidateCmd)
+	StagesCmd.AddCommand(stagesGraphCmd)
 
 	// Add common flags to all stage commands
 	addCommonStageFlags(stagesUpCmd)
 	addCommonStageFlags(stagesDownCmd)
 	addCommonStageFlags(stagesStatusCmd)
 	addCommonStageFlags(stagesValidateCmd)
+	addCommonStageFlags(stagesGraphCmd)
 
 	// Add execution-specific flags to up/down commands
 	addExecutionFlags(stagesUpCmd)
 	addExecutionFlags(stagesDownCmd)
 
+	stagesUpCmd.Flags().Bool("resume", false, "Skip stages that succeeded in the previous run, reusing their extracted env (keeps results in .<config>.state unless global.state_file is set)")
+
 	// Add status-specific flags
 	stagesStatusCmd.Flags().BoolP("verbose", "v", false, "Show detailed information")
+	stagesStatusCmd.Flags().Bool("json", false, "Print the execution plan and each stage's result as JSON")
+
+	// Add graph-specific flags
+	stagesGraphCmd.Flags().String("out", "", "Write the DOT graph to this file instead of stdout")
 }
 
 func addCommonStageFlags(cmd *cobra.Command) {
@@ -127,7 +173,17 @@ func executeStagesCommand(cmd *cobra.Command, isUp bool) error {
 
 	// Execute stages
 	ctx := context.Background()
+	resume := false
+	if isUp {
+		resume, _ = cmd.Flags().GetBool("resume")
+	}
+	useStateFile(orch, configPath, resume)
 	if isUp {
+		if resume {
+			if _, err := orch.Resume(); err != nil {
+				return err
+			}
+		}
 		err = orch.ExecuteStages(ctx, fromStage, toStage)
 	} else {
 		err = orch.ExecuteStagesDown(ctx, fromStage, toStage)
@@ -146,6 +202,22 @@ func executeStagesCommand(cmd *cobra.Command, isUp bool) error {
 	return nil
 }
 
+// useStateFile points orch at the state file next to the stages config when the config sets no
+// state_file: always for `stages up --resume`, which opts in to persisting results, and otherwise
+// only when an earlier --resume run left that file behind.
+func useStateFile(orch *orchestrator.Orchestrator, configPath string, optIn bool) {
+	if orch.StateFile() != "" {
+		return
+	}
+	path := orchestrator.DefaultStatePath(configPath)
+	if !optIn {
+		if _, err := os.Stat(path); err != nil {
+			return
+		}
+	}
+	orch.SetStateFile(path)
+}
+
 func showExecutionPlan(cmd *cobra.Command, fromStage, toStage, direction string) error {
 	configPath, _ := cmd.Flags().GetString("config")
 
@@ -197,6 +269,20 @@ func showExecutionPlan(cmd *cobra.Command, fromStage, toStage, direction string)
 	return nil
 }
 
+// loadStagesStatus loads the stages config with the results of the last run kept in its state
+// file (global.state_file, or the file of an earlier --resume run).
+func loadStagesStatus(configPath string) (*orchestrator.Orchestrator, error) {
+	orch, err := orchestrator.LoadFromFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	useStateFile(orch, configPath, false)
+	if _, err := orch.LoadState(); err != nil {
+		return nil, err
+	}
+	return orch, nil
+}
+
 func showStagesStatus(orch *orchestrator.Orchestrator, verbose bool) error {
 	fmt.Println("📊 Stages Status:")
 
@@ -220,6 +306,10 @@ func showStagesStatus(orch *orchestrator.Orchestrator, verbose bool) error {
 		}
 		fmt.Println()
 
+		if verbose && result.SkipReason != "" {
+			fmt.Printf("    Skipped: %s\n", result.SkipReason)
+		}
+
 		if verbose && result.Error != "" {
 			fmt.Printf("    Error: %s\n", result.Error)
 		}
@@ -234,3 +324,67 @@ func showStagesStatus(orch *orchestrator.Orchestrator, verbose bool) error {
 
 	return nil
 }
+
+// stagesStatusReport is the `stages status --json` output: the up execution plan and a result
+// entry for every stage, in topological order.
+type stagesStatusReport struct {
+	Plan   [][]string          `json:"plan"`
+	Stages []stageStatusResult `json:"stages"`
+}
+
+type stageStatusResult struct {
+	Name string `json:"name"`
+	// Status is "success", "failed", "skipped" or "pending" (not executed)
+	Status        string `json:"status"`
+	Success       bool   `json:"success"`
+	DurationMs    int64  `json:"duration_ms"`
+	ExtractedVars int    `json:"extracted_vars"`
+	SkipReason    string `json:"skip_reason,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// buildStagesStatusReport combines the execution plan with the stage results of orch.
+func buildStagesStatusReport(orch *orchestrator.Orchestrator) (stagesStatusReport, error) {
+	plan, err := orch.GetExecutionPlan("", "", "up")
+	if err != nil {
+		return stagesStatusReport{}, fmt.Errorf("failed to get execution plan: %w", err)
+	}
+	report := stagesStatusReport{Plan: plan, Stages: []stageStatusResult{}}
+	if report.Plan == nil {
+		report.Plan = [][]string{}
+	}
+	results := orch.GetStageResults()
+	for _, batch := range plan {
+		for _, name := range batch {
+			entry := stageStatusResult{Name: name, Status: "pending"}
+			if r, ok := results[name]; ok {
+				entry.Success = r.Success
+				entry.DurationMs = r.Duration.Milliseconds()
+				entry.ExtractedVars = len(r.ExtractedEnv)
+				entry.SkipReason = r.SkipReason
+				entry.Error = r.Error
+				switch {
+				case r.SkipReason != "":
+					entry.Status = "skipped"
+				case r.Success:
+					entry.Status = "success"
+				default:
+					entry.Status = "failed"
+				}
+			}
+			report.Stages = append(report.Stages, entry)
+		}
+	}
+	return report, nil
+}
+
+// writeStagesStatusJSON writes the status report of orch to w as indented JSON.
+func writeStagesStatusJSON(w io.Writer, orch *orchestrator.Orchestrator) error {
+	report, err := buildStagesStatusReport(orch)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
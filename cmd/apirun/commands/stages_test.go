@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/orchestrator"
+)
+
+// writeStage writes a stage whose single migration calls url and extracts "id" when present.
+func writeStage(t *testing.T, root, name, url string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, "migrations"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	mig := fmt.Sprintf("up:\n  name: %s\n  request:\n    method: GET\n    url: %s\n    retry:\n      max_attempts: 1\n"+
+		"  response:\n    result_code: [\"200\"]\n    env_from:\n      %s_id: id\n", name, url, name)
+	writeFile(t, filepath.Join(dir, "migrations"), "001_call.yaml", mig)
+	writeFile(t, dir, "stage.yaml", "migrate_dir: ./migrations\n")
+}
+
+func TestStagesStatus_JSON_MixedResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	for name, path := range map[string]string{"base": "/ok", "broken": "/fail", "gated": "/ok", "child": "/ok", "final": "/ok"} {
+		writeStage(t, root, name, srv.URL+path)
+	}
+	cfgPath := writeFile(t, root, "stages.yaml", `global:
+  state_file: .stages.state
+stages:
+  - { name: base, config_path: base/stage.yaml }
+  - { name: broken, config_path: broken/stage.yaml, on_failure: skip_dependents }
+  - { name: gated, config_path: gated/stage.yaml, condition: "false" }
+  - { name: child, config_path: child/stage.yaml, depends_on: [broken] }
+  - { name: final, config_path: final/stage.yaml, depends_on: [child, gated] }
+`)
+	orch, err := orchestrator.LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", "child"); err != nil {
+		t.Fatalf("ExecuteStages: %v", err)
+	}
+	// status runs in a later process: the results come from the state file
+	if orch, err = loadStagesStatus(cfgPath); err != nil {
+		t.Fatalf("loadStagesStatus: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeStagesStatusJSON(&buf, orch); err != nil {
+		t.Fatalf("writeStagesStatusJSON: %v", err)
+	}
+	var report stagesStatusReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if want := [][]string{{"base", "broken", "gated"}, {"child"}, {"final"}}; !reflect.DeepEqual(report.Plan, want) {
+		t.Fatalf("plan = %v, want %v", report.Plan, want)
+	}
+
+	var names []string
+	byName := map[string]stageStatusResult{}
+	for _, s := range report.Stages {
+		names = append(names, s.Name)
+		byName[s.Name] = s
+	}
+	if want := []string{"base", "broken", "gated", "child", "final"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("stages must follow topological order, got %v", names)
+	}
+	if s := byName["base"]; s.Status != "success" || !s.Success || s.ExtractedVars != 1 || s.Error != "" {
+		t.Fatalf("base = %+v", s)
+	}
+	if s := byName["broken"]; s.Status != "failed" || s.Success || s.Error == "" {
+		t.Fatalf("broken = %+v", s)
+	}
+	if s := byName["gated"]; s.Status != "skipped" || s.SkipReason != `condition "false" not met` {
+		t.Fatalf("gated = %+v", s)
+	}
+	if s := byName["child"]; s.Status != "skipped" || s.SkipReason != "dependency broken failed" || s.Success {
+		t.Fatalf("child = %+v", s)
+	}
+	if s := byName["final"]; s.Status != "pending" || s.Success || s.DurationMs != 0 || s.Error != "" {
+		t.Fatalf("final = %+v", s)
+	}
+}
+
+func TestStagesGraph_WritesDOT(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"infra", "auth", "db", "app"} {
+		writeStage(t, root, name, "http://127.0.0.1:1/"+name)
+	}
+	cfgPath := writeFile(t, root, "stages.yaml", `stages:
+  - { name: infra, config_path: infra/stage.yaml }
+  - { name: auth, config_path: auth/stage.yaml, depends_on: [infra] }
+  - { name: db, config_path: db/stage.yaml, depends_on: [infra] }
+  - { name: app, config_path: app/stage.yaml, depends_on: [auth, db] }
+`)
+	out := filepath.Join(root, "stages.dot")
+	if err := stagesGraphCmd.Flags().Set("config", cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := stagesGraphCmd.Flags().Set("out", out); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = stagesGraphCmd.Flags().Set("config", "stages.yaml")
+		_ = stagesGraphCmd.Flags().Set("out", "")
+	})
+	if err := stagesGraphCmd.RunE(stagesGraphCmd, nil); err != nil {
+		t.Fatalf("stages graph: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read graph: %v", err)
+	}
+	dot := string(data)
+	for _, want := range []string{
+		"digraph stages {",
+		"subgraph cluster_batch_2 {\n\t\tlabel=\"batch 2\";\n\t\tstyle=dashed;\n\t\trank=same;\n\t\t\"auth\";\n\t\t\"db\";\n\t}",
+		`"infra" -> "auth";`,
+		`"infra" -> "db";`,
+		`"auth" -> "app";`,
+		`"db" -> "app";`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expected %q in graph:\n%s", want, dot)
+		}
+	}
+}
+
+func TestUseStateFile_OptIn(t *testing.T) {
+	root := t.TempDir()
+	writeStage(t, root, "base", "http://127.0.0.1/unused")
+	cfgPath := writeFile(t, root, "stages.yaml", "stages:\n  - { name: base, config_path: base/stage.yaml }\n")
+	statePath := orchestrator.DefaultStatePath(cfgPath)
+
+	orch, err := orchestrator.LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	useStateFile(orch, cfgPath, false)
+	if orch.StateFile() != "" {
+		t.Fatalf("StateFile = %q, want none without --resume or an existing state file", orch.StateFile())
+	}
+	useStateFile(orch, cfgPath, true)
+	if orch.StateFile() != statePath {
+		t.Fatalf("StateFile = %q, want %q for --resume", orch.StateFile(), statePath)
+	}
+
+	// a state file left by an earlier --resume run is used by later commands
+	writeFile(t, root, filepath.Base(statePath), "config_hash: x\n")
+	if orch, err = orchestrator.LoadFromFile(cfgPath); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	useStateFile(orch, cfgPath, false)
+	if orch.StateFile() != statePath {
+		t.Fatalf("StateFile = %q, want the existing %q", orch.StateFile(), statePath)
+	}
+}
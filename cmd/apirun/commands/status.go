@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -17,62 +18,30 @@ var (
 	statusHistory      bool
 	statusHistoryAll   bool
 	statusHistoryLimit int
+	statusJSON         bool
+	statusPending      bool
+	statusApplied      bool
 )
 
 var StatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show current migration version, applied versions, and optionally history",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		v := viper.GetViper()
-		configPath := v.GetString("config")
-
-		dir := ""
-		var storeCfg *apirun.StoreConfig
-		var colorEnabled bool = false // Default to no color
-
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err != nil {
-				logger := common.GetLogger().WithComponent("status")
-				logger.Warn("failed to load config", "error", err, "config_path", configPath)
-			} else {
-				// Enable color from config if available
-				if doc.Logging.Color != nil {
-					colorEnabled = *doc.Logging.Color
-				}
-
-				mDir := strings.TrimSpace(doc.MigrateDir)
-				if mDir == "" {
-					// Fallback: use config file directory if migrate_dir not specified
-					mDir = filepath.Dir(configPath)
-				}
-				// Store configuration is controlled via config file only
-				tmpStoreCfg := doc.Store.ToStorOptions()
-				if mDir != "" {
-					dir = mDir
-				}
-				storeCfg = tmpStoreCfg
-			}
-		}
-		if strings.TrimSpace(dir) == "" {
-			dir = "./config/migration"
+		src, err := resolveConfigSource(viper.GetViper())
+		if err != nil {
+			return err
 		}
-
-		// Check if store is explicitly disabled in config
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err == nil && doc.Store.Disabled {
-				logger := common.GetLogger().WithComponent("status")
-				logger.Info("store is disabled - no migration status available")
-				return nil
-			}
+		loc := resolveStoreLocation(src, "status")
+		if loc.disabled {
+			logger := common.GetLogger().WithComponent("status")
+			logger.Info("store is disabled - no migration status available")
+			return nil
 		}
-
-		// Use default SQLite store if no store config provided
-		if storeCfg == nil {
-			storeCfg = &apirun.StoreConfig{}
-			storeCfg.Config.Driver = apirun.DriverSqlite
-			storeCfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(dir, apirun.StoreDBFileName)}
+		dir, storeCfg := loc.dir, loc.storeCfg
+		versionPattern := loc.versionPattern()
+		colorEnabled := false // Default to no color
+		if loc.doc != nil && loc.doc.Logging.Color != nil {
+			colorEnabled = *loc.doc.Logging.Color
 		}
 
 		// centralized store opening
@@ -86,17 +55,102 @@ var StatusCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		drifts, err := status.DriftFromStore(context.Background(), dir, st, versionPattern)
+		if err != nil {
+			return err
+		}
+		info.Drifted = status.DriftedVersions(drifts)
+		var plan *apirun.MigrationPlan
+		if statusJSON || statusPending {
+			if plan, err = status.PlanFromStore(context.Background(), dir, st, versionPattern); err != nil {
+				return err
+			}
+			info.Pending = plan.Versions()
+		}
+		if statusJSON {
+			out, err := info.FormatJSON(statusHistory)
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		}
 		if statusHistory {
 			fmt.Print(info.FormatColorizedWithLimit(true, statusHistoryLimit, statusHistoryAll, colorEnabled))
 		} else {
 			fmt.Print(info.FormatColorized(false, colorEnabled))
 		}
+		if statusApplied {
+			fmt.Print(info.FormatApplied())
+		}
+		fmt.Print(status.FormatDrift(drifts))
+		if statusPending {
+			fmt.Print(status.FormatPending(plan))
+		}
 		return nil
 	},
 }
 
+// storeLocation is the migration directory and store configuration resolved from a config file.
+type storeLocation struct {
+	dir      string
+	storeCfg *apirun.StoreConfig
+	// doc is the loaded config, nil when there is none or it failed to load.
+	doc      *config.ConfigDoc
+	disabled bool
+}
+
+// versionPattern returns the configured migration file name pattern ("" = default).
+func (loc storeLocation) versionPattern() string {
+	if loc.doc == nil {
+		return ""
+	}
+	return loc.doc.VersionPattern
+}
+
+// resolveStoreLocation resolves the store the read-only commands (status, history) open.
+// A config that fails to load is logged and the defaults are used: the sqlite file under
+// the migrate dir of src (see configSource.migrateDir).
+func resolveStoreLocation(src configSource, component string) storeLocation {
+	var loc storeLocation
+	configPath := src.Path
+	configured := ""
+	if strings.TrimSpace(configPath) != "" {
+		var doc config.ConfigDoc
+		if err := doc.Load(configPath); err != nil {
+			logger := common.GetLogger().WithComponent(component)
+			logger.Warn("failed to load config", "error", err, "config_path", configPath)
+		} else {
+			loc.doc = &doc
+			loc.disabled = doc.Store.Disabled
+			configured = doc.MigrateDir
+			// Store configuration is controlled via config file only
+			loc.storeCfg = doc.Store.ToStorOptions()
+			// Resolve {{.env.*}} in the sqlite path against the config env, as up/down do
+			if loc.storeCfg != nil {
+				if sc, ok := loc.storeCfg.DriverConfig.(*apirun.SqliteConfig); ok {
+					if base, err := doc.GetEnv(); err == nil {
+						sc.Path = base.RenderGoTemplate(sc.Path)
+					}
+				}
+			}
+		}
+	}
+	loc.dir = src.migrateDir(configured)
+	// Use default SQLite store if no store config provided
+	if loc.storeCfg == nil {
+		loc.storeCfg = &apirun.StoreConfig{}
+		loc.storeCfg.Config.Driver = apirun.DriverSqlite
+		loc.storeCfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(loc.dir, apirun.StoreDBFileName)}
+	}
+	return loc
+}
+
 func init() {
 	StatusCmd.Flags().BoolVar(&statusHistory, "history", false, "show migration run history as well")
 	StatusCmd.Flags().BoolVar(&statusHistoryAll, "history-all", false, "when used with --history, show all history entries (newest first)")
 	StatusCmd.Flags().IntVar(&statusHistoryLimit, "history-limit", 10, "when used with --history, show up to N latest entries (default 10)")
+	StatusCmd.Flags().BoolVar(&statusPending, "pending", false, "also list the migrations 'up' would apply, with file and step names")
+	StatusCmd.Flags().BoolVar(&statusApplied, "applied", false, "also list when each applied version was applied")
+	StatusCmd.Flags().BoolVar(&statusJSON, "json", false, "print status as JSON including pending versions (with --history, the full run history)")
 }
@@ -2,9 +2,13 @@ package commands
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/loykin/apirun"
@@ -102,3 +106,123 @@ func TestStatusCmd_WithAppliedVersions_PrintsCurrentAndList(t *testing.T) {
 		t.Fatalf("unexpected output.\nwant: %q\n got: %q", want, out)
 	}
 }
+
+func TestStatusCmd_JSON_ReportsAppliedAndPending(t *testing.T) {
+	tdir := t.TempDir()
+	for _, name := range []string{"001_a.yaml", "002_b.yaml", "003_c.yml"} {
+		writeFile(t, tdir, name, "up: {}\n")
+	}
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(tdir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(tdir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions: %v", err)
+	}
+	if err := st.Apply(1); err != nil {
+		t.Fatalf("Apply(1): %v", err)
+	}
+	_ = st.Close()
+
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n")
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("v", false)
+	statusJSON = true
+	defer func() { statusJSON = false }()
+
+	out := captureOutput(t, func() {
+		if err := StatusCmd.RunE(StatusCmd, nil); err != nil {
+			t.Fatalf("StatusCmd.RunE error: %v", err)
+		}
+	})
+
+	// applied_at holds the (non-deterministic) time version 1 was applied
+	re := regexp.MustCompile(`^\{\n  "version": 1,\n  "applied": \[\n    1\n  \],\n  "pending": \[\n    2,\n    3\n  \],\n  "applied_at": \{\n    "1": "[0-9T:-]+Z"\n  \}\n\}\n$`)
+	if !re.MatchString(out) {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestStatusCmd_Pending_ListsPlannedMigrations(t *testing.T) {
+	tdir := t.TempDir()
+	writeFile(t, tdir, "001_a.yaml", "up:\n  name: create a\n")
+	writeFile(t, tdir, "002_b.yaml", "up:\n  name: create b\n")
+	writeFile(t, tdir, "003_c.yaml", "up: {}\n")
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(tdir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(tdir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions: %v", err)
+	}
+	if err := st.Apply(1); err != nil {
+		t.Fatalf("Apply(1): %v", err)
+	}
+	_ = st.Close()
+
+	cfgPath := writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n")
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("v", false)
+	statusPending = true
+	defer func() { statusPending = false }()
+
+	out := captureOutput(t, func() {
+		if err := StatusCmd.RunE(StatusCmd, nil); err != nil {
+			t.Fatalf("StatusCmd.RunE error: %v", err)
+		}
+	})
+
+	want := "current: 1\napplied: [1]\npending: 2\n  v=2 file=002_b.yaml name=\"create b\"\n  v=3 file=003_c.yaml\n"
+	if out != want {
+		t.Fatalf("unexpected output.\nwant: %q\n got: %q", want, out)
+	}
+}
+
+func TestStatusCmd_ReportsDriftedVersions(t *testing.T) {
+	tdir := t.TempDir()
+	writeFile(t, tdir, "001_a.yaml", "up: {}\n")
+	writeFile(t, tdir, "002_b.yaml", "up: {}\n")
+	writeFile(t, tdir, "003_c.yaml", "up: {}\n")
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(tdir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(tdir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions: %v", err)
+	}
+	unchanged := sha256.Sum256([]byte("up: {}\n"))
+	for v, sum := range map[int]string{1: "recorded-before-edit", 2: hex.EncodeToString(unchanged[:])} {
+		if err := st.Apply(v); err != nil {
+			t.Fatalf("Apply(%d): %v", v, err)
+		}
+		if err := st.SetChecksum(v, sum); err != nil {
+			t.Fatalf("SetChecksum(%d): %v", v, err)
+		}
+	}
+	_ = st.Close()
+
+	v := viper.GetViper()
+	v.Set("config", writeFile(t, tdir, "config.yaml", "---\nmigrate_dir: "+tdir+"\n"))
+	out := captureOutput(t, func() {
+		if err := StatusCmd.RunE(StatusCmd, nil); err != nil {
+			t.Fatalf("StatusCmd.RunE error: %v", err)
+		}
+	})
+	want := "current: 2\napplied: [1 2]\ndrifted: 1 (applied files changed since they were applied)\n  v=1 file=001_a.yaml\n"
+	if out != want {
+		t.Fatalf("unexpected output.\nwant: %q\n got: %q", want, out)
+	}
+
+	statusJSON = true
+	defer func() { statusJSON = false }()
+	out = captureOutput(t, func() {
+		if err := StatusCmd.RunE(StatusCmd, nil); err != nil {
+			t.Fatalf("StatusCmd.RunE error: %v", err)
+		}
+	})
+	if !strings.Contains(out, "\"drifted\": [\n    1\n  ]") {
+		t.Fatalf("expected drifted versions in JSON, got %s", out)
+	}
+}
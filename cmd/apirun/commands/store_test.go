@@ -192,6 +192,7 @@ migrate_dir: %s
 	}
 
 	// Now down to 0 -> should DELETE with stored id 123 and remove stored rows
+	v.Set("down_yes", true)
 	if err := DownCmd.RunE(DownCmd, nil); err != nil {
 		t.Fatalf("down error: %v", err)
 	}
@@ -282,10 +283,10 @@ migrate_dir: %s
 	}
 }
 
-func TestBuildStoreOptions_EmptyType_ReturnsNil(t *testing.T) {
+func TestBuildStoreOptions_EmptyType_DefaultsToSqlite(t *testing.T) {
 	doc := config.ConfigDoc{Store: config.StoreConfig{Type: ""}}
-	if got := doc.Store.ToStorOptions(); got != nil {
-		t.Fatalf("expected nil for empty type, got %#v", got)
+	if got := doc.Store.ToStorOptions(); got == nil || got.Config.Driver != apirun.DriverSqlite {
+		t.Fatalf("expected sqlite options for empty type, got %#v", got)
 	}
 }
 
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ToCmd = &cobra.Command{
+	Use:   "to",
+	Short: "Migrate up or down to exactly the given version (0 = fully rolled back)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		if !v.IsSet("version") {
+			return fmt.Errorf("target version is required: use --version N")
+		}
+		m, err := BuildMigrator(v, MigratorOptions{Wait: true})
+		if err != nil {
+			return err
+		}
+		_, err = m.MigrateTo(context.Background(), v.GetInt("version"))
+		return explainVersionGap(err)
+	},
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/spf13/viper"
+)
+
+func toCurrentVersion(t *testing.T, dir string) int {
+	t.Helper()
+	cfg := &apirun.StoreConfig{}
+	cfg.Config.Driver = apirun.DriverSqlite
+	cfg.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(dir, apirun.StoreDBFileName)}
+	st, err := apirun.OpenStoreFromOptions(dir, cfg)
+	if err != nil {
+		t.Fatalf("OpenStoreFromOptions error: %v", err)
+	}
+	defer func() { _ = st.Close() }()
+	cur, err := st.CurrentVersion()
+	if err != nil {
+		t.Fatalf("CurrentVersion error: %v", err)
+	}
+	return cur
+}
+
+func TestToCmd_MigratesUpDownAndNoop(t *testing.T) {
+	calls := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method+" "+r.URL.Path]++
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		m := fmt.Sprintf(`---
+up:
+  name: v%d
+  request:
+    method: POST
+    url: %s/item%d
+  response:
+    result_code: ["200"]
+down:
+  name: v%ddown
+  method: DELETE
+  url: %s/item%d
+`, i, srv.URL, i, i, srv.URL, i)
+		_ = writeFile(t, tdir, fmt.Sprintf("%03d_item.yaml", i), m)
+	}
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("---\nmigrate_dir: %s\ndelay_between_migrations: 0s\n", tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	defer v.Set("version", nil)
+
+	v.Set("version", 2)
+	if err := ToCmd.RunE(ToCmd, nil); err != nil {
+		t.Fatalf("to 2: %v", err)
+	}
+	if cur := toCurrentVersion(t, tdir); cur != 2 {
+		t.Fatalf("expected version 2, got %d", cur)
+	}
+	if calls["POST /item1"] != 1 || calls["POST /item2"] != 1 || calls["POST /item3"] != 0 {
+		t.Fatalf("unexpected up calls: %v", calls)
+	}
+
+	// Same target again is a no-op
+	if err := ToCmd.RunE(ToCmd, nil); err != nil {
+		t.Fatalf("to 2 again: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected no new calls, got %v", calls)
+	}
+
+	v.Set("version", 1)
+	if err := ToCmd.RunE(ToCmd, nil); err != nil {
+		t.Fatalf("to 1: %v", err)
+	}
+	if cur := toCurrentVersion(t, tdir); cur != 1 {
+		t.Fatalf("expected version 1, got %d", cur)
+	}
+	if calls["DELETE /item2"] != 1 || calls["DELETE /item1"] != 0 {
+		t.Fatalf("unexpected down calls: %v", calls)
+	}
+
+	v.Set("version", 0)
+	if err := ToCmd.RunE(ToCmd, nil); err != nil {
+		t.Fatalf("to 0: %v", err)
+	}
+	if cur := toCurrentVersion(t, tdir); cur != 0 {
+		t.Fatalf("expected version 0, got %d", cur)
+	}
+	if calls["DELETE /item1"] != 1 {
+		t.Fatalf("expected rollback of item1, got %v", calls)
+	}
+}
+
+func TestToCmd_TargetAboveHighest_Errors(t *testing.T) {
+	tdir := t.TempDir()
+	_ = writeFile(t, tdir, "001_only.yaml", `---
+up:
+  name: only
+  request:
+    method: GET
+    url: http://127.0.0.1:1/never
+`)
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("---\nmigrate_dir: %s\n", tdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("version", 5)
+	defer v.Set("version", nil)
+
+	if err := ToCmd.RunE(ToCmd, nil); err == nil {
+		t.Fatalf("expected error for target above highest migration")
+	}
+}
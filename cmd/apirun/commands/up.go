@@ -4,16 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/loykin/apirun"
-	"github.com/loykin/apirun/cmd/apirun/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-
-	ienv "github.com/loykin/apirun/pkg/env"
 )
 
 var UpCmd = &cobra.Command{
@@ -21,89 +15,50 @@ var UpCmd = &cobra.Command{
 	Short: "Apply up migrations up to a target version (0 = all)",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		v := viper.GetViper()
-		configPath := v.GetString("config")
-		if strings.TrimSpace(configPath) == "" {
-			configPath = os.Getenv("APIMIGRATE_CONFIG")
-		}
 		dry := v.GetBool("dry_run")
-		dryRunFrom := v.GetInt("dry_run_from")
-		to := v.GetInt("to")
-		ctx := context.Background()
-		be := ienv.New()
-		baseEnv := be
-		dir := ""
-		saveResp := false
-		var storeCfgFromDoc *apirun.StoreConfig
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err != nil {
-				return fmt.Errorf("failed to load configuration file '%s': %w\nPlease verify the file exists and contains valid YAML", configPath, err)
-			}
-			mDir := strings.TrimSpace(doc.MigrateDir)
-			if mDir == "" {
-				// Fallback: use the directory of the config file if migrate_dir is not set
-				mDir = filepath.Dir(configPath)
-			}
-			envFromCfg, err := doc.GetEnv()
-			if err != nil {
-				return fmt.Errorf("failed to process environment variables from config: %w", err)
-			}
-			if err := DoWait(ctx, envFromCfg, doc.Wait, doc.Client); err != nil {
-				return fmt.Errorf("dependency wait check failed: %w\nCheck that required services are running and accessible", err)
-			}
-			if err := doc.DecodeAuth(ctx, envFromCfg); err != nil {
-				return fmt.Errorf("authentication setup failed: %w\nVerify auth configuration in config file", err)
-			}
-			// Store configuration is controlled via config file (store.disabled)
-			// Build store options now; we'll pass them to Migrator below
-			storeCfgFromDoc = doc.Store.ToStorOptions()
-			saveBody := doc.Store.SaveResponseBody
-			if mDir != "" {
-				dir = mDir
-			}
-			// Always use env from config (may carry Auth even if Global is empty)
-			baseEnv = envFromCfg
-			saveResp = saveBody
+		dryRunFormat := v.GetString("dry_run_format")
+		if err := validateDryRunFormat(dryRunFormat); err != nil {
+			return err
+		}
+		output, err := ParseOutputTemplate(v.GetString("up_output"))
+		if err != nil {
+			return err
 		}
-		if strings.TrimSpace(dir) == "" {
-			dir = "./config/migration"
+		if output != nil && dry {
+			return fmt.Errorf("--output cannot be combined with --dry-run (use --dry-run-format)")
 		}
-		// Normalize to absolute path to avoid working-directory surprises
-		if abs, err := filepath.Abs(dir); err == nil {
-			dir = abs
+		// Dry runs never contact the API, so there is nothing to wait for
+		m, err := BuildMigrator(v, MigratorOptions{Wait: !dry})
+		if err != nil {
+			return err
 		}
-		m := apirun.Migrator{Env: baseEnv, Dir: dir, SaveResponseBody: saveResp, DryRun: dry, DryRunFrom: dryRunFrom}
-		// Set default render_body and delay from config if provided
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err == nil {
-				if doc.RenderBody != nil {
-					m.RenderBodyDefault = doc.RenderBody
-				}
-				if strings.TrimSpace(doc.DelayBetweenMigrations) != "" {
-					if duration, err := time.ParseDuration(doc.DelayBetweenMigrations); err == nil {
-						m.DelayBetweenMigrations = duration
-					}
-				}
-			}
+		m.DryRun = dry
+		m.DryRunFrom = v.GetInt("dry_run_from")
+		m.ContinueOnError = v.IsSet("fail_fast") && !v.GetBool("fail_fast")
+		m.Tags = parseTags(v.GetString("tags"))
+		m.TagsAllowGaps = v.GetBool("tags_allow_gaps")
+		ctx := context.Background()
+		results, err := m.MigrateUp(ctx, v.GetInt("to"))
+		if outErr := WriteOutputSummary(os.Stdout, "up", results, output); outErr != nil && err == nil {
+			return outErr
 		}
-		// Configure store via Migrator.StoreConfig (auto-connect inside MigrateUp)
-		var scPtr *apirun.StoreConfig
-		if strings.TrimSpace(configPath) != "" {
-			// Reuse store config parsed earlier
-			if storeCfgFromDoc != nil {
-				scPtr = storeCfgFromDoc
-			}
+		if err != nil {
+			return explainVersionGap(err)
 		}
-		if scPtr == nil {
-			// default to sqlite under dir explicitly
-			tmp := &apirun.StoreConfig{}
-			tmp.Config.Driver = apirun.DriverSqlite
-			tmp.Config.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(dir, apirun.StoreDBFileName)}
-			scPtr = tmp
+		if dry {
+			return writeDryRunReport(os.Stdout, "up", results, dryRunFormat)
 		}
-		m.StoreConfig = scPtr
-		_, err := m.MigrateUp(ctx, to)
-		return err
+		return nil
 	},
 }
+
+// parseTags splits a comma-separated --tags value, dropping empty entries.
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
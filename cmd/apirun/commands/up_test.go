@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -200,3 +201,122 @@ migrate_dir: %s
 		t.Fatalf("expected one call to /one and /two, got: %v", calls)
 	}
 }
+
+func TestUpCmd_FailFastFalse_AttemptsAllMigrations(t *testing.T) {
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		if r.URL.Path == "/two" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	mdir := filepath.Join(tdir, "migration")
+	if err := os.MkdirAll(mdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range []string{"/one", "/two", "/three"} {
+		writeFile(t, mdir, fmt.Sprintf("%03d_step.yaml", i+1), fmt.Sprintf(`up:
+  request:
+    method: GET
+    url: %s%s
+  response:
+    result_code: ["200"]
+`, srv.URL, p))
+	}
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("migrate_dir: %s\ndelay_between_migrations: 1ms\n", mdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	v.Set("fail_fast", false)
+	t.Cleanup(func() { v.Set("fail_fast", true) })
+
+	err := UpCmd.RunE(UpCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "versions [2]") {
+		t.Fatalf("expected aggregate error for version 2, got %v", err)
+	}
+	if calls["/one"] != 1 || calls["/two"] != 1 || calls["/three"] != 1 {
+		t.Fatalf("expected every migration to be attempted once, got %v", calls)
+	}
+}
+
+func TestUpCmd_Tags(t *testing.T) {
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	mdir := filepath.Join(tdir, "migration")
+	if err := os.MkdirAll(mdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, tag := range []string{"seed", "schema", "seed"} {
+		writeFile(t, mdir, fmt.Sprintf("%03d_step.yaml", i+1), fmt.Sprintf(`tags: [%s]
+up:
+  request:
+    method: GET
+    url: %s/%d
+  response:
+    result_code: ["200"]
+`, tag, srv.URL, i+1))
+	}
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("migrate_dir: %s\ndelay_between_migrations: 1ms\n", mdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	v.Set("tags", " seed, ")
+	v.Set("tags_allow_gaps", true)
+	t.Cleanup(func() {
+		v.Set("tags", "")
+		v.Set("tags_allow_gaps", false)
+	})
+
+	if err := UpCmd.RunE(UpCmd, nil); err != nil {
+		t.Fatalf("up --tags: %v", err)
+	}
+	if calls["/1"] != 1 || calls["/2"] != 0 || calls["/3"] != 1 {
+		t.Fatalf("expected only the seed migrations to run, got %v", calls)
+	}
+}
+
+func TestUpCmd_EnvFiles(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tdir := t.TempDir()
+	mdir := filepath.Join(tdir, "migration")
+	if err := os.MkdirAll(mdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, mdir, "001_realm.yaml", "up:\n  request:\n    method: POST\n    url: \"{{.env.api}}/realms/{{.env.realm}}/{{.env.owner}}\"\n")
+	base := writeFile(t, tdir, "base.yaml", "api: http://unused\nrealm: base\nowner: files\n")
+	prod := writeFile(t, tdir, "prod.env", "api="+srv.URL+"\nrealm=prod\n")
+	// env from the config wins over the env files
+	cfgPath := writeFile(t, tdir, "config.yaml", fmt.Sprintf("migrate_dir: %s\ndelay_between_migrations: 1ms\nenv:\n  - name: owner\n    value: config\n", mdir))
+
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("to", 0)
+	v.Set("env_files", []string{base, prod})
+	t.Cleanup(func() { v.Set("env_files", nil) })
+
+	if err := UpCmd.RunE(UpCmd, nil); err != nil {
+		t.Fatalf("up --env-file: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/realms/prod/config" {
+		t.Fatalf("paths = %v, want [/realms/prod/config]", paths)
+	}
+}
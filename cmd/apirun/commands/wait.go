@@ -3,11 +3,16 @@ package commands
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/loykin/apirun/cmd/apirun/config"
+	"github.com/loykin/apirun/internal/common"
 	"github.com/loykin/apirun/internal/constants"
 	"github.com/loykin/apirun/internal/httpc"
 	"github.com/loykin/apirun/internal/util"
@@ -39,6 +44,7 @@ type waitParams struct {
 	expected int
 	timeout  time.Duration
 	interval time.Duration
+	backoff  waitBackoff
 }
 
 // parseWaitConfig parses and normalizes wait configuration with defaults
@@ -52,6 +58,23 @@ func parseWaitConfig(wc config.WaitConfig, env *env.Env) waitParams {
 		expected = constants.DefaultWaitStatus
 	}
 
+	timeout, interval := parseWaitTiming(wc)
+
+	url := env.RenderGoTemplate(urlRaw)
+
+	return waitParams{
+		url:      url,
+		method:   method,
+		expected: expected,
+		timeout:  timeout,
+		interval: interval,
+		backoff:  parseWaitBackoff(wc, interval),
+	}
+}
+
+// parseWaitTiming returns the wait timeout and polling interval, falling back to the
+// defaults for empty or invalid durations.
+func parseWaitTiming(wc config.WaitConfig) (time.Duration, time.Duration) {
 	timeout := constants.DefaultWaitTimeout
 	if s, hasTimeout := util.TrimEmptyCheck(wc.Timeout); hasTimeout {
 		if d, err := time.ParseDuration(s); err == nil {
@@ -65,20 +88,81 @@ func parseWaitConfig(wc config.WaitConfig, env *env.Env) waitParams {
 			interval = d
 		}
 	}
+	return timeout, interval
+}
 
-	url := env.RenderGoTemplate(urlRaw)
+// waitBackoff is the parsed wait.backoff; the zero value polls at the fixed interval.
+type waitBackoff struct {
+	enabled    bool
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+}
 
-	return waitParams{
-		url:      url,
-		method:   method,
-		expected: expected,
-		timeout:  timeout,
-		interval: interval,
+// parseWaitBackoff parses wc.Backoff, falling back to the defaults for empty or invalid values.
+func parseWaitBackoff(wc config.WaitConfig, interval time.Duration) waitBackoff {
+	bc := wc.Backoff
+	if bc == nil {
+		return waitBackoff{}
+	}
+	b := waitBackoff{enabled: true, initial: interval, max: constants.DefaultWaitBackoffMax, multiplier: bc.Multiplier, jitter: bc.Jitter}
+	if s, hasInitial := util.TrimEmptyCheck(bc.Initial); hasInitial {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			b.initial = d
+		}
+	}
+	if s, hasMax := util.TrimEmptyCheck(bc.Max); hasMax {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			b.max = d
+		}
+	}
+	if b.max < b.initial {
+		b.max = b.initial
+	}
+	if b.multiplier < 1 {
+		b.multiplier = constants.DefaultWaitBackoffMultiplier
+	}
+	b.jitter = math.Min(math.Max(b.jitter, 0), 1)
+	return b
+}
+
+// delay returns how long to wait after the given attempt (1-based): the interval in fixed
+// mode, otherwise the exponential delay capped at max and randomized by the jitter.
+func (b waitBackoff) delay(attempt int, interval time.Duration) time.Duration {
+	if !b.enabled {
+		return interval
+	}
+	d := float64(b.initial) * math.Pow(b.multiplier, float64(attempt-1))
+	if b.jitter > 0 {
+		d *= 1 + b.jitter*(2*rand.Float64()-1) // #nosec G404 -- jitter needs no cryptographic randomness
+	}
+	if d <= 0 || d > float64(b.max) {
+		d = float64(b.max)
+	}
+	return time.Duration(d)
+}
+
+// sleepUntilNextAttempt waits d before the next attempt and reports whether one may still be
+// made: it stops at the deadline instead of sleeping past it. It returns ctx.Err() when ctx is
+// done first.
+func sleepUntilNextAttempt(ctx context.Context, d time.Duration, deadline time.Time) (bool, error) {
+	remaining := time.Until(deadline)
+	if d >= remaining {
+		d = max(remaining, 0)
+	}
+	timer := time.NewTimer(d)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return false, ctx.Err()
+	case <-timer.C:
+		return time.Now().Before(deadline), nil
 	}
 }
 
-// setupTLSConfig creates TLS configuration from client config
-func setupTLSConfig(clientCfg config.ClientConfig) *tls.Config {
+// setupTLSConfig creates TLS configuration from client config, trusting the CAs of client.tls.ca_file
+func setupTLSConfig(clientCfg config.ClientConfig) (*tls.Config, error) {
 	minV := parseTLSVersion(clientCfg.MinTLSVersion)
 	maxV := parseTLSVersion(clientCfg.MaxTLSVersion)
 
@@ -89,7 +173,14 @@ func setupTLSConfig(clientCfg config.ClientConfig) *tls.Config {
 		// #nosec G402 — Intentionally allow self-signed certificates for the wait probe when explicitly configured
 		cfg.InsecureSkipVerify = true
 	}
-	return cfg
+	if caFile := strings.TrimSpace(clientCfg.TLS.CAFile); caFile != "" {
+		pool, err := httpc.AppendCAFile(nil, caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
 }
 
 // performHTTPRequest executes an HTTP request with the specified method
@@ -126,43 +217,65 @@ func performHTTPRequest(ctx context.Context, hcfg *httpc.Httpc, method, url stri
 
 // performPolling repeatedly polls the endpoint until success or timeout
 func performPolling(ctx context.Context, hcfg *httpc.Httpc, params waitParams) error {
+	logger := common.GetLogger().WithComponent("wait")
 	deadline := time.Now().Add(params.timeout)
-	var lastStatus int
+	var last string
 
-	for {
-		status, err := performHTTPRequest(ctx, hcfg, params.method, params.url)
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithDeadline(ctx, deadline)
+		status, err := performHTTPRequest(attemptCtx, hcfg, params.method, params.url)
+		cancel()
 
 		if err == nil && status == params.expected {
+			logger.Debug("wait attempt succeeded", "attempt", attempt, "url", params.url, "status", status)
 			return nil
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 
-		lastStatus = status
-		if time.Now().After(deadline) {
-			return fmt.Errorf("wait: timeout waiting for %s to return %d (last=%d)",
-				params.url, params.expected, lastStatus)
+		switch {
+		case err == nil:
+			last = strconv.Itoa(status)
+		case errors.Is(err, context.DeadlineExceeded) && last != "":
+			// Cut short by the overall deadline; keep the previous answer
+		default:
+			last = err.Error()
 		}
 
-		// Context-aware sleep
-		timer := time.NewTimer(params.interval)
-		select {
-		case <-ctx.Done():
-			timer.Stop()
-			return ctx.Err()
-		case <-timer.C:
-			// Continue polling
+		delay := params.backoff.delay(attempt, params.interval)
+		logger.Debug("wait attempt failed", "attempt", attempt, "url", params.url, "last", last, "next_in", delay.String())
+		more, err := sleepUntilNextAttempt(ctx, delay, deadline)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return fmt.Errorf("wait: timeout waiting for %s to return %d after %d attempts (last=%s)",
+				params.url, params.expected, attempt, last)
 		}
 	}
 }
 
 // DoWait polls an HTTP endpoint until it returns the expected status or timeout elapses.
+// With type "grpc" it polls a gRPC health check instead (see doGRPCWait).
 //
 // Behavior:
 // - method defaults to GET; supports GET and HEAD (others fallback to GET)
 // - expected status defaults to 200
 // - timeout defaults to 60s; interval defaults to 2s
+// - with backoff set, the delay between attempts grows exponentially instead (see WaitBackoffConfig)
+// - the timeout is a hard deadline: no attempt starts after it; the error reports the attempts
 // - url is rendered with Go template using provided env
 // - TLS client options are applied via clientCfg and attached to the polling context
 func DoWait(ctx context.Context, env *env.Env, wc config.WaitConfig, clientCfg config.ClientConfig) error {
+	switch util.TrimAndLower(wc.Type) {
+	case "", constants.WaitTypeHTTP:
+	case constants.WaitTypeGRPC:
+		return doGRPCWait(ctx, env, wc, clientCfg)
+	default:
+		return fmt.Errorf("wait: unknown type %q (use %s or %s)", wc.Type, constants.WaitTypeHTTP, constants.WaitTypeGRPC)
+	}
+
 	// Early exit if no URL is provided
 	if _, hasURL := util.TrimEmptyCheck(wc.URL); !hasURL {
 		return nil
@@ -172,8 +285,16 @@ func DoWait(ctx context.Context, env *env.Env, wc config.WaitConfig, clientCfg c
 	params := parseWaitConfig(wc, env)
 
 	// Setup TLS configuration
-	tlsConfig := setupTLSConfig(clientCfg)
-	hcfg := &httpc.Httpc{TlsConfig: tlsConfig}
+	tlsConfig, err := setupTLSConfig(clientCfg)
+	if err != nil {
+		return err
+	}
+	transport, err := clientCfg.Transport.ToTransport()
+	if err != nil {
+		return err
+	}
+	// Attempts are paced by the polling loop, not by the client's retry policy
+	hcfg := &httpc.Httpc{TlsConfig: tlsConfig, Retry: &httpc.RetryPolicy{MaxAttempts: 1}, Transport: transport}
 
 	// Perform polling until success or timeout
 	return performPolling(ctx, hcfg, params)
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/loykin/apirun/cmd/apirun/config"
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/util"
+	"github.com/loykin/apirun/pkg/env"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// grpcStatusServing is the status name a health check must report for the wait to succeed.
+var grpcStatusServing = healthpb.HealthCheckResponse_SERVING.String()
+
+// grpcWaitParams holds the parsed parameters of a gRPC health wait
+type grpcWaitParams struct {
+	target   string
+	service  string
+	tls      bool
+	timeout  time.Duration
+	interval time.Duration
+	backoff  waitBackoff
+}
+
+// parseGRPCWaitConfig parses a gRPC wait configuration; target and service are rendered with env
+func parseGRPCWaitConfig(wc config.WaitConfig, env *env.Env) grpcWaitParams {
+	timeout, interval := parseWaitTiming(wc)
+	return grpcWaitParams{
+		target:   env.RenderGoTemplate(strings.TrimSpace(wc.Target)),
+		service:  env.RenderGoTemplate(strings.TrimSpace(wc.Service)),
+		tls:      wc.TLS,
+		timeout:  timeout,
+		interval: interval,
+		backoff:  parseWaitBackoff(wc, interval),
+	}
+}
+
+// newGRPCConn returns a client connection to target: over TLS when useTLS, otherwise plaintext.
+// The connection is established lazily by the first call.
+func newGRPCConn(target string, tlsConfig *tls.Config, useTLS bool) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("wait: invalid gRPC target %q: %w", target, err)
+	}
+	return conn, nil
+}
+
+// grpcHealthCheck performs one Check call and returns the reported serving status name.
+func grpcHealthCheck(ctx context.Context, client healthpb.HealthClient, params grpcWaitParams) (string, error) {
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: params.service})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			return "", fmt.Errorf("grpc status %s: %s", st.Code(), st.Message())
+		}
+		return "", err
+	}
+	return resp.GetStatus().String(), nil
+}
+
+// performGRPCPolling repeatedly calls the health check until it reports SERVING or timeout
+func performGRPCPolling(ctx context.Context, client healthpb.HealthClient, params grpcWaitParams) error {
+	logger := common.GetLogger().WithComponent("wait")
+	deadline := time.Now().Add(params.timeout)
+	subject := params.target
+	if params.service != "" {
+		subject = fmt.Sprintf("service %q at %s", params.service, params.target)
+	}
+	var last string
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithDeadline(ctx, deadline)
+		servingStatus, err := grpcHealthCheck(attemptCtx, client, params)
+		cancel()
+
+		if err == nil && servingStatus == grpcStatusServing {
+			logger.Debug("wait attempt succeeded", "attempt", attempt, "target", params.target, "status", servingStatus)
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		switch {
+		case err == nil:
+			last = servingStatus
+		case errors.Is(err, context.DeadlineExceeded) && last != "":
+			// Cut short by the overall deadline; keep the previous answer
+		default:
+			last = err.Error()
+		}
+
+		delay := params.backoff.delay(attempt, params.interval)
+		logger.Debug("wait attempt failed", "attempt", attempt, "target", params.target, "last", last, "next_in", delay.String())
+		more, err := sleepUntilNextAttempt(ctx, delay, deadline)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return fmt.Errorf("wait: timeout waiting for gRPC health of %s to be SERVING after %d attempts (last=%s)", subject, attempt, last)
+		}
+	}
+}
+
+// doGRPCWait polls the standard gRPC health check (grpc.health.v1.Health/Check) of
+// wc.Service at wc.Target until it reports SERVING or the timeout elapses. wc.TLS dials
+// over TLS with the client TLS settings; otherwise a plaintext connection is used.
+func doGRPCWait(ctx context.Context, env *env.Env, wc config.WaitConfig, clientCfg config.ClientConfig) error {
+	if _, hasTarget := util.TrimEmptyCheck(wc.Target); !hasTarget {
+		return errors.New("wait: type grpc requires a target (host:port)")
+	}
+	params := parseGRPCWaitConfig(wc, env)
+	tlsConfig, err := setupTLSConfig(clientCfg)
+	if err != nil {
+		return err
+	}
+	conn, err := newGRPCConn(params.target, tlsConfig, params.tls)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	return performGRPCPolling(ctx, healthpb.NewHealthClient(conn), params)
+}
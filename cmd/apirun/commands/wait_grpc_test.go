@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/loykin/apirun/cmd/apirun/config"
+	"github.com/loykin/apirun/pkg/env"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// newHealthServer starts an in-process gRPC server with the standard health service. onCheck,
+// when set, runs before each Check call is answered.
+func newHealthServer(t *testing.T, useTLS bool, onCheck func(hs *health.Server)) (*health.Server, string) {
+	t.Helper()
+	var opts []grpc.ServerOption
+	if useTLS {
+		// Borrow the self-signed certificate of an httptest TLS server
+		ts := httptest.NewUnstartedServer(nil)
+		ts.StartTLS()
+		cert := ts.TLS.Certificates[0]
+		ts.Close()
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})))
+	}
+	hs := health.NewServer()
+	if onCheck != nil {
+		opts = append(opts, grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			onCheck(hs)
+			return handler(ctx, req)
+		}))
+	}
+	srv := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(srv, hs)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return hs, lis.Addr().String()
+}
+
+func TestGRPCWait_PollsUntilServing(t *testing.T) {
+	var calls int32
+	hs, addr := newHealthServer(t, false, func(hs *health.Server) {
+		if atomic.AddInt32(&calls, 1) > 2 {
+			hs.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+		}
+	})
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	wc := config.WaitConfig{Type: "grpc", Target: "{{.env.grpc_addr}}", Service: "orders", Timeout: "2s", Interval: "20ms"}
+	e := env.New()
+	_ = e.SetString("global", "grpc_addr", addr)
+	if err := DoWait(context.Background(), e, wc, config.ClientConfig{}); err != nil {
+		t.Fatalf("DoWait: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 health checks (2 NOT_SERVING + 1 SERVING), got %d", n)
+	}
+}
+
+func TestGRPCWait_TLS(t *testing.T) {
+	_, addr := newHealthServer(t, true, nil)
+
+	wc := config.WaitConfig{Type: "GRPC", Target: addr, TLS: true, Timeout: "2s", Interval: "20ms"}
+	if err := DoWait(context.Background(), env.New(), wc, config.ClientConfig{Insecure: true}); err != nil {
+		t.Fatalf("DoWait over TLS: %v", err)
+	}
+}
+
+func TestGRPCWait_TimeoutReportsLastStatus(t *testing.T) {
+	hs, addr := newHealthServer(t, false, nil)
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	err := DoWait(context.Background(), env.New(), config.WaitConfig{Type: "grpc", Target: addr, Timeout: "100ms", Interval: "20ms"}, config.ClientConfig{})
+	if err == nil || !strings.Contains(err.Error(), "last=NOT_SERVING") {
+		t.Fatalf("expected timeout with last=NOT_SERVING, got %v", err)
+	}
+	err = DoWait(context.Background(), env.New(), config.WaitConfig{Type: "grpc", Target: addr, Service: "missing", Timeout: "100ms", Interval: "20ms"}, config.ClientConfig{})
+	if err == nil || !strings.Contains(err.Error(), "grpc status NotFound: unknown service") {
+		t.Fatalf("expected the NOT_FOUND status in the timeout error, got %v", err)
+	}
+}
+
+func TestDoWait_InvalidGRPCConfig(t *testing.T) {
+	if err := DoWait(context.Background(), env.New(), config.WaitConfig{Type: "grpc"}, config.ClientConfig{}); err == nil || !strings.Contains(err.Error(), "requires a target") {
+		t.Fatalf("expected missing target error, got %v", err)
+	}
+	if err := DoWait(context.Background(), env.New(), config.WaitConfig{Type: "tcp", URL: "http://x"}, config.ClientConfig{}); err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Fatalf("expected unknown type error, got %v", err)
+	}
+}
@@ -145,6 +145,68 @@ migrate_dir: %s
 	}
 }
 
+func TestWait_BackoffFromConfig(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(503)
+	}))
+	defer srv.Close()
+	tdir := t.TempDir()
+	cfg := fmt.Sprintf(`---
+wait:
+  url: %s/health
+  timeout: 500ms
+  backoff:
+    initial: 20ms
+    max: 200ms
+    multiplier: 2
+    jitter: 0.1
+migrate_dir: %s
+`, srv.URL, tdir)
+	cfgPath := writeFile(t, tdir, "config.yaml", cfg)
+	v := viper.GetViper()
+	v.Set("config", cfgPath)
+	v.Set("v", false)
+	v.Set("to", 0)
+	err := UpCmd.RunE(UpCmd, nil)
+	n := atomic.LoadInt32(&calls)
+	// Delays of ~20, 40, 80, 160, 200ms: about 5 or 6 attempts in 500ms, far fewer than at a fixed 20ms
+	if n < 4 || n > 8 {
+		t.Fatalf("expected 4-8 attempts with backoff, got %d", n)
+	}
+	want := fmt.Sprintf("after %d attempts (last=503)", n)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error to include %q, got %v", want, err)
+	}
+}
+
+func TestParseWaitBackoff(t *testing.T) {
+	if b := parseWaitBackoff(config.WaitConfig{}, time.Second); b.enabled || b.delay(5, time.Second) != time.Second {
+		t.Fatalf("expected fixed interval without backoff, got %+v", b)
+	}
+
+	b := parseWaitBackoff(config.WaitConfig{Backoff: &config.WaitBackoffConfig{Jitter: 3}}, 500*time.Millisecond)
+	want := waitBackoff{enabled: true, initial: 500 * time.Millisecond, max: 30 * time.Second, multiplier: 2, jitter: 1}
+	if b != want {
+		t.Fatalf("defaults = %+v, want %+v", b, want)
+	}
+
+	b = parseWaitBackoff(config.WaitConfig{Backoff: &config.WaitBackoffConfig{Initial: "100ms", Max: "1s", Multiplier: 3}}, time.Second)
+	for attempt, d := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second} {
+		if got := b.delay(attempt+1, time.Second); got != d {
+			t.Fatalf("delay(%d) = %v, want %v", attempt+1, got, d)
+		}
+	}
+
+	b = parseWaitBackoff(config.WaitConfig{Backoff: &config.WaitBackoffConfig{Initial: "100ms", Max: "10s", Jitter: 0.5}}, time.Second)
+	for i := 0; i < 100; i++ {
+		if got := b.delay(2, time.Second); got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("jittered delay %v outside 200ms +/- 50%%", got)
+		}
+	}
+}
+
 func TestWait_TemplatedURL(t *testing.T) {
 	var seen int32
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -331,7 +393,10 @@ func TestSetupTLSConfig(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg := setupTLSConfig(tt.clientCfg)
+			cfg, err := setupTLSConfig(tt.clientCfg)
+			if err != nil {
+				t.Fatalf("setupTLSConfig: %v", err)
+			}
 			tt.checkFunc(t, cfg)
 		})
 	}
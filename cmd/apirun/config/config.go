@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/loykin/apirun"
 	iauth "github.com/loykin/apirun/internal/auth"
@@ -20,12 +22,14 @@ type SQLiteStoreConfig struct {
 }
 
 type AuthConfig struct {
-	// Provider type key (e.g., "basic", "oauth2", "pocketbase")
+	// Provider type key (e.g., "basic", "oauth2", "pocketbase", "vault", "negotiate")
 	Type string `mapstructure:"type" yaml:"type"`
 	// Logical name under which the acquired token will be stored
 	Name string `mapstructure:"name" yaml:"name"`
 	// Provider-specific configuration (rendered before acquisition)
 	Config map[string]interface{} `mapstructure:"config" yaml:"config"`
+	// Name of another auth entry acquired first; its token is available as {{.auth.<name>}} in Config
+	DependsOn string `mapstructure:"depends_on" yaml:"depends_on"`
 	// Legacy: providers array inside the object (optional, alternative to single provider)
 	Providers []map[string]interface{} `mapstructure:"providers" yaml:"providers"`
 }
@@ -41,14 +45,40 @@ type LoggingConfig struct {
 	Format        string `mapstructure:"format" yaml:"format"`                 // text, json, color
 	MaskSensitive *bool  `mapstructure:"mask_sensitive" yaml:"mask_sensitive"` // enable/disable sensitive data masking
 	Color         *bool  `mapstructure:"color" yaml:"color"`                   // enable/disable colorized output
+	// TimeFormat is the Go time layout of log timestamps, e.g. "2006-01-02 15:04:05.000"
+	// (default RFC 3339); UTC logs them in UTC instead of the local time zone.
+	TimeFormat string `mapstructure:"time_format" yaml:"time_format"`
+	UTC        bool   `mapstructure:"utc" yaml:"utc"`
+	// File writes logs to a rotating file instead of stdout when Path is set.
+	File LogFileConfig `mapstructure:"file" yaml:"file"`
+}
+
+// LogFileConfig configures file output and its rotation limits.
+type LogFileConfig struct {
+	Path       string `mapstructure:"path" yaml:"path"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb" yaml:"max_size_mb"` // rotate after this many MB (default 100)
+	MaxBackups int    `mapstructure:"max_backups" yaml:"max_backups"` // rotated files to keep (0 = all)
+	MaxAge     string `mapstructure:"max_age" yaml:"max_age"`         // drop rotated files older than this, e.g. "168h"
 }
 
 type StoreConfig struct {
-	Disabled         bool              `mapstructure:"disabled" yaml:"disabled" json:"disabled"`
-	SaveResponseBody bool              `mapstructure:"save_response_body" yaml:"save_response_body"`
-	Type             string            `mapstructure:"type" yaml:"type"`
-	SQLite           SQLiteStoreConfig `mapstructure:"sqlite" yaml:"sqlite"`
-	Postgres         postgresql.Config `mapstructure:"postgres" yaml:"postgres"`
+	Disabled            bool `mapstructure:"disabled" yaml:"disabled" json:"disabled"`
+	SaveResponseBody    bool `mapstructure:"save_response_body" yaml:"save_response_body"`
+	SaveResponseHeaders bool `mapstructure:"save_response_headers" yaml:"save_response_headers"`
+	// MaxStoredEnvEntries caps the env_from values stored per version; StoredEnvBatchSize is the
+	// rows per insert statement (0 = the defaults, 100000 and 300).
+	MaxStoredEnvEntries int `mapstructure:"max_stored_env_entries" yaml:"max_stored_env_entries"`
+	StoredEnvBatchSize  int `mapstructure:"stored_env_batch_size" yaml:"stored_env_batch_size"`
+	// Lock serializes concurrent runs against a PostgreSQL store (nil = on); LockTimeout bounds
+	// the wait for it (0 = 10m).
+	Lock        *bool              `mapstructure:"lock" yaml:"lock"`
+	LockTimeout time.Duration      `mapstructure:"lock_timeout" yaml:"lock_timeout"`
+	Type        string             `mapstructure:"type" yaml:"type"`
+	SQLite      SQLiteStoreConfig  `mapstructure:"sqlite" yaml:"sqlite"`
+	Postgres    postgresql.Config  `mapstructure:"postgres" yaml:"postgres"`
+	Mongo       apirun.MongoConfig `mapstructure:"mongo" yaml:"mongo"`
+	// Options configures a custom store driver registered with apirun.RegisterStoreDriver
+	Options map[string]interface{} `mapstructure:"options" yaml:"options"`
 	// Optional table name customization
 	TablePrefix           string `mapstructure:"table_prefix" yaml:"table_prefix"`
 	TableSchemaMigrations string `mapstructure:"table_schema_migrations" yaml:"table_schema_migrations"`
@@ -63,17 +93,141 @@ func (c *StoreConfig) ToStorOptions() *apirun.StoreConfig {
 
 type ClientConfig struct {
 	// Explicit options only
-	Insecure      bool   `mapstructure:"insecure"`
-	MinTLSVersion string `mapstructure:"min_tls_version"`
-	MaxTLSVersion string `mapstructure:"max_tls_version"`
+	Insecure      bool   `mapstructure:"insecure" yaml:"insecure"`
+	MinTLSVersion string `mapstructure:"min_tls_version" yaml:"min_tls_version"`
+	MaxTLSVersion string `mapstructure:"max_tls_version" yaml:"max_tls_version"`
+	// TLS adds trust in private CAs.
+	TLS ClientTLSConfig `mapstructure:"tls" yaml:"tls"`
+	// DefaultHeaders are sent with every migration request; per-request headers override them.
+	DefaultHeaders map[string]string `mapstructure:"default_headers" yaml:"default_headers"`
+	// Transport tunes the HTTP transport of migration, wait and notify requests.
+	Transport TransportConfig `mapstructure:"transport" yaml:"transport"`
+	// CircuitBreaker makes migration requests to a host that keeps failing fail fast for a while.
+	CircuitBreaker *CircuitBreakerConfig `mapstructure:"circuit_breaker" yaml:"circuit_breaker"`
+	// MaxResponseBodyBytes bounds response bodies; longer saved bodies are truncated (0 = unbounded).
+	MaxResponseBodyBytes int64 `mapstructure:"max_response_body_bytes" yaml:"max_response_body_bytes"`
+}
+
+// ClientTLSConfig holds the certificate settings of the HTTP client.
+type ClientTLSConfig struct {
+	// CAFile is a PEM bundle of CAs server certificates are verified against instead of the system roots.
+	CAFile string `mapstructure:"ca_file" yaml:"ca_file"`
+}
+
+// CircuitBreakerConfig opens a host's circuit after FailureThreshold consecutive failures within
+// Window; requests then fail fast for Cooldown. Unset fields keep the defaults (5 failures, 30s).
+type CircuitBreakerConfig struct {
+	FailureThreshold int    `mapstructure:"failure_threshold" yaml:"failure_threshold"`
+	Window           string `mapstructure:"window" yaml:"window"`
+	Cooldown         string `mapstructure:"cooldown" yaml:"cooldown"`
+}
+
+// ToCircuitBreaker converts the config to the library form; nil when the section is absent.
+func (c *CircuitBreakerConfig) ToCircuitBreaker() (*apirun.CircuitBreakerConfig, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.FailureThreshold < 0 {
+		return nil, fmt.Errorf("client.circuit_breaker.failure_threshold must not be negative, got %d", c.FailureThreshold)
+	}
+	out := &apirun.CircuitBreakerConfig{FailureThreshold: c.FailureThreshold}
+	for _, f := range []struct {
+		name string
+		s    string
+		dst  *time.Duration
+	}{{"window", c.Window, &out.Window}, {"cooldown", c.Cooldown, &out.Cooldown}} {
+		if s := strings.TrimSpace(f.s); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid client.circuit_breaker.%s %q", f.name, f.s)
+			}
+			*f.dst = d
+		}
+	}
+	return out, nil
+}
+
+// ToClientOptions converts the transport and circuit breaker settings to their library form.
+func (c ClientConfig) ToClientOptions() (*apirun.TransportConfig, *apirun.CircuitBreakerConfig, error) {
+	transport, err := c.Transport.ToTransport()
+	if err != nil {
+		return nil, nil, err
+	}
+	breaker, err := c.CircuitBreaker.ToCircuitBreaker()
+	if err != nil {
+		return nil, nil, err
+	}
+	return transport, breaker, nil
+}
+
+// TransportConfig tunes the HTTP transport; unset fields keep the built-in defaults.
+type TransportConfig struct {
+	ForceAttemptHTTP2 bool   `mapstructure:"force_attempt_http2" yaml:"force_attempt_http2"`
+	MaxIdleConns      int    `mapstructure:"max_idle_conns" yaml:"max_idle_conns"`
+	IdleConnTimeout   string `mapstructure:"idle_conn_timeout" yaml:"idle_conn_timeout"`
+	DisableKeepAlives bool   `mapstructure:"disable_keep_alives" yaml:"disable_keep_alives"`
+}
+
+// ToTransport converts the config to the library form; nil when nothing is configured.
+func (t TransportConfig) ToTransport() (*apirun.TransportConfig, error) {
+	if t == (TransportConfig{}) {
+		return nil, nil
+	}
+	if t.MaxIdleConns < 0 {
+		return nil, fmt.Errorf("client.transport.max_idle_conns must not be negative, got %d", t.MaxIdleConns)
+	}
+	out := &apirun.TransportConfig{ForceAttemptHTTP2: t.ForceAttemptHTTP2, MaxIdleConns: t.MaxIdleConns, DisableKeepAlives: t.DisableKeepAlives}
+	if s := strings.TrimSpace(t.IdleConnTimeout); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid client.transport.idle_conn_timeout %q", t.IdleConnTimeout)
+		}
+		out.IdleConnTimeout = d
+	}
+	return out, nil
+}
+
+// NotifyConfig configures a webhook called with a summary after up/down runs.
+type NotifyConfig struct {
+	URL      string            `mapstructure:"url" yaml:"url"`
+	Headers  map[string]string `mapstructure:"headers" yaml:"headers"`
+	Template string            `mapstructure:"template" yaml:"template"`
+}
+
+// ToNotify converts the config to the library form; nil when no URL is configured.
+func (n NotifyConfig) ToNotify() *apirun.NotifyConfig {
+	if strings.TrimSpace(n.URL) == "" {
+		return nil
+	}
+	return &apirun.NotifyConfig{URL: n.URL, Headers: n.Headers, Template: n.Template}
 }
 
 type WaitConfig struct {
+	// Type selects the probe: "http" (default) polls URL, "grpc" polls the standard
+	// grpc.health.v1 health check of Service at Target until it reports SERVING.
+	Type     string `mapstructure:"type"`
 	URL      string `mapstructure:"url"`
 	Method   string `mapstructure:"method"`
 	Status   int    `mapstructure:"status"`
 	Timeout  string `mapstructure:"timeout"`
 	Interval string `mapstructure:"interval"`
+	// Target is the gRPC server address (host:port); Service is the checked service name
+	// ("" = overall server health). TLS dials the target over TLS using the client settings.
+	Target  string `mapstructure:"target"`
+	Service string `mapstructure:"service"`
+	TLS     bool   `mapstructure:"tls"`
+	// Backoff, when set, replaces the fixed Interval between attempts with exponential backoff.
+	Backoff *WaitBackoffConfig `mapstructure:"backoff"`
+}
+
+// WaitBackoffConfig configures exponential backoff between wait attempts: the delay starts at
+// Initial (default: the interval) and grows by Multiplier (default 2) up to Max (default 30s).
+// Jitter randomizes each delay by up to that fraction in either direction (0-1, default 0).
+type WaitBackoffConfig struct {
+	Initial    string  `mapstructure:"initial"`
+	Max        string  `mapstructure:"max"`
+	Multiplier float64 `mapstructure:"multiplier"`
+	Jitter     float64 `mapstructure:"jitter"`
 }
 
 type ConfigDoc struct {
@@ -89,28 +243,57 @@ type ConfigDoc struct {
 	// DelayBetweenMigrations configures the delay between migration executions.
 	// Can be specified as duration string (e.g., "500ms", "1s", "2m"). Defaults to "1s".
 	DelayBetweenMigrations string `mapstructure:"delay_between_migrations" yaml:"delay_between_migrations"`
+	// MaxParallel runs up to N migrations concurrently when their depends_on allow it (0/1 = sequential).
+	MaxParallel int `mapstructure:"max_parallel" yaml:"max_parallel"`
+	// StrictChecksums fails "up" when an applied migration file changed since it was applied.
+	StrictChecksums bool `mapstructure:"strict_checksums" yaml:"strict_checksums"`
+	// VersionPattern is the regular expression migration file names must match; its first capture
+	// group is the integer version (default `^(\d+)_.*\.ya?ml$`).
+	VersionPattern string `mapstructure:"version_pattern" yaml:"version_pattern"`
+	// Notify posts a run summary to a webhook once migrations finish or fail.
+	Notify NotifyConfig `mapstructure:"notify" yaml:"notify"`
 }
 
-func (c *ConfigDoc) DecodeAuth(ctx context.Context, e *env.Env) error {
-	// Ensure map exists
-	if e.Auth == nil {
-		e.Auth = env.Map{}
-	}
-	// Prepare lazy acquisition closures per auth name
+// AuthEntries returns the auth entries of the config, dependencies first. The templates of
+// entries without depends_on are rendered with e; chained entries are rendered when acquired.
+func (c *ConfigDoc) AuthEntries(e *env.Env) ([]iauth.Auth, error) {
+	auths := make([]iauth.Auth, 0, len(c.Auth))
 	for i, a := range c.Auth {
 		pt, ptOk := util.TrimEmptyCheck(a.Type)
 		if !ptOk {
-			return fmt.Errorf("auth[%d]: missing type", i)
+			return nil, fmt.Errorf("auth[%d]: missing type", i)
 		}
 		storedName, nameOk := util.TrimEmptyCheck(a.Name)
 		if !nameOk {
-			return fmt.Errorf("auth[%d] type=%s: missing name (use auth[].name)", i, pt)
+			return nil, fmt.Errorf("auth[%d] type=%s: missing name (use auth[].name)", i, pt)
 		}
-		// Render templated values in the auth config using the base env
-		renderedAny := apirun.RenderAnyTemplate(a.Config, e)
-		renderedCfg, _ := renderedAny.(map[string]interface{})
-		// Build struct-based config for later acquisition
-		authCfg := &iauth.Auth{Type: pt, Name: storedName, Methods: iauth.NewAuthSpecFromMap(renderedCfg)}
+		cfg := a.Config
+		if strings.TrimSpace(a.DependsOn) == "" {
+			// Render templated values in the auth config using the base env. Chained entries are
+			// rendered when acquired, so that their dependency is not acquired eagerly here.
+			renderedAny := apirun.RenderAnyTemplate(a.Config, e)
+			cfg, _ = renderedAny.(map[string]interface{})
+		}
+		auths = append(auths, iauth.Auth{Type: pt, Name: storedName, Methods: iauth.NewAuthSpecFromMap(cfg), DependsOn: a.DependsOn})
+	}
+	return iauth.Order(auths)
+}
+
+// DecodeAuth installs a lazy value in e.Auth for each auth entry that acquires the token with
+// ctx when first rendered.
+func (c *ConfigDoc) DecodeAuth(ctx context.Context, e *env.Env) error {
+	// Ensure map exists
+	if e.Auth == nil {
+		e.Auth = env.Map{}
+	}
+	ordered, err := c.AuthEntries(e)
+	if err != nil {
+		return err
+	}
+	// Prepare lazy acquisition closures per auth name, dependencies first
+	for i := range ordered {
+		authCfg := &ordered[i]
+		storedName := authCfg.Name
 
 		// Install lazy value using env.MakeLazy
 		e.Auth[storedName] = e.MakeLazy(func(env *env.Env) (string, error) {
@@ -146,7 +329,14 @@ func (c *ConfigDoc) GetEnv() (*env.Env, error) {
 	return base, nil
 }
 
+// Load reads the config file at path, merging ActiveProfile over it when set.
 func (c *ConfigDoc) Load(path string) error {
+	return c.LoadProfile(path, ActiveProfile)
+}
+
+// LoadProfile reads the config file at path and deep-merges the named entry of its profiles
+// section over the top-level keys ("" = base config only).
+func (c *ConfigDoc) LoadProfile(path, profile string) error {
 	clean := filepath.Clean(path)
 	// Ensure path points to a regular file to avoid opening directories/special files
 	if info, statErr := os.Stat(clean); statErr != nil || !info.Mode().IsRegular() {
@@ -161,8 +351,19 @@ func (c *ConfigDoc) Load(path string) error {
 		return err
 	}
 	defer func() { _ = f.Close() }()
-	dec := yaml.NewDecoder(f)
-	return dec.Decode(c)
+	var root yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&root); err != nil {
+		return err
+	}
+	// Merge the selected profile first, so that other profiles are not interpolated
+	if err := applyProfile(&root, strings.TrimSpace(profile)); err != nil {
+		return fmt.Errorf("config %s: %w", clean, err)
+	}
+	// Expand ${VAR} / ${VAR:-default} from the OS environment in string values
+	if err := interpolateEnv(&root); err != nil {
+		return fmt.Errorf("config %s: %w", clean, err)
+	}
+	return root.Decode(c)
 }
 
 func (c *ConfigDoc) parseLogLevel() (apirun.LogLevel, error) {
@@ -181,6 +382,11 @@ func (c *ConfigDoc) parseLogLevel() (apirun.LogLevel, error) {
 	}
 }
 
+// logOptions returns the stdout logger options of the logging config for format.
+func (c *ConfigDoc) logOptions(format string) apirun.LogOptions {
+	return apirun.LogOptions{Format: format, TimeFormat: c.Logging.TimeFormat, UTC: c.Logging.UTC}
+}
+
 // SetupLogging configures the global logger based on config settings
 func (c *ConfigDoc) SetupLogging() error {
 	// Determine log level from config
@@ -188,6 +394,7 @@ func (c *ConfigDoc) SetupLogging() error {
 	if err != nil {
 		return err
 	}
+	level = EffectiveLogLevel(level)
 
 	// Determine format
 	var logger *apirun.Logger
@@ -201,17 +408,32 @@ func (c *ConfigDoc) SetupLogging() error {
 		useColor = true
 	}
 
-	switch format {
-	case "json":
-		logger = apirun.NewJSONLogger(level)
-	case "color", "colour":
-		logger = apirun.NewColorLogger(level)
-	case "text", "":
+	switch {
+	case strings.TrimSpace(c.Logging.File.Path) != "":
+		if format != "text" && format != "" && format != "json" {
+			return fmt.Errorf("invalid logging format for file output: %s (valid: text, json)", c.Logging.Format)
+		}
+		opts, err := c.Logging.File.toOptions()
+		if err != nil {
+			return err
+		}
+		opts.JSON = format == "json"
+		opts.TimeFormat = c.Logging.TimeFormat
+		opts.UTC = c.Logging.UTC
+		if logger, err = apirun.NewFileLogger(level, c.Logging.File.Path, opts); err != nil {
+			return err
+		}
+		useColor = false
+	case format == "json":
+		logger = apirun.NewLoggerWithOptions(level, c.logOptions("json"))
+	case format == "color" || format == "colour":
+		logger = apirun.NewLoggerWithOptions(level, c.logOptions("color"))
+	case format == "text" || format == "":
 		if useColor {
-			logger = apirun.NewColorLogger(level)
+			logger = apirun.NewLoggerWithOptions(level, c.logOptions("color"))
 		} else {
 			// Default to text format
-			logger = apirun.NewLogger(level)
+			logger = apirun.NewLoggerWithOptions(level, c.logOptions("text"))
 		}
 	default:
 		return fmt.Errorf("invalid logging format: %s (valid: text, json, color)", c.Logging.Format)
@@ -237,7 +459,23 @@ func (c *ConfigDoc) SetupLogging() error {
 		"level", levelStr,
 		"format", format,
 		"color", useColor,
-		"mask_sensitive", maskingEnabled)
+		"mask_sensitive", maskingEnabled,
+		"file", c.Logging.File.Path)
 
 	return nil
 }
+
+func (f LogFileConfig) toOptions() (apirun.FileLogOptions, error) {
+	opts := apirun.FileLogOptions{
+		MaxSize:    int64(f.MaxSizeMB) * 1024 * 1024,
+		MaxBackups: f.MaxBackups,
+	}
+	if s := strings.TrimSpace(f.MaxAge); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return opts, fmt.Errorf("invalid logging.file.max_age %q: %w", f.MaxAge, err)
+		}
+		opts.MaxAge = d
+	}
+	return opts, nil
+}
@@ -2,9 +2,13 @@ package config
 
 import (
 	"context"
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/loykin/apirun"
 	iauth "github.com/loykin/apirun/internal/auth"
@@ -60,10 +64,17 @@ func TestConfigDoc_DecodeAuth_BasicFlow(t *testing.T) {
 
 // Sanity: ToStorOptions builds default sqlite when Type empty and table prefix derivation
 func TestStoreConfig_ToStorOptions_TablePrefixAndDefault(t *testing.T) {
-	cfg := &StoreConfig{Type: "", TablePrefix: "appx"}
+	// Without a type the store limits still apply, to the default sqlite store
+	cfg := &StoreConfig{Type: "", TablePrefix: "appx", MaxStoredEnvEntries: 7, LockTimeout: time.Minute}
 	so := cfg.ToStorOptions()
-	if so != nil {
-		// When Type empty, our CLI treats it as nil options; mimic call-site behavior
+	if so == nil || so.Config.Driver != apirun.DriverSqlite || so.StoredEnv.MaxEntries != 7 || so.LockTimeout != time.Minute {
+		t.Fatalf("expected default sqlite options with the store limits, got %+v", so)
+	}
+	if sc, ok := so.Config.DriverConfig.(*apirun.SqliteConfig); !ok || sc.Path != "" {
+		t.Fatalf("expected an unset sqlite path, got %+v", so.Config.DriverConfig)
+	}
+	if (&StoreConfig{Disabled: true}).ToStorOptions() != nil {
+		t.Fatalf("expected no options for a disabled store")
 	}
 	cfg2 := &StoreConfig{Type: "sqlite", TablePrefix: "appx", SQLite: SQLiteStoreConfig{Path: filepath.Join(t.TempDir(), "x.db")}}
 	so2 := cfg2.ToStorOptions()
@@ -73,6 +84,43 @@ func TestStoreConfig_ToStorOptions_TablePrefixAndDefault(t *testing.T) {
 	if so2.Config.TableNames.SchemaMigrations != "appx_schema_migrations" || so2.Config.TableNames.MigrationRuns != "appx_migration_log" || so2.Config.TableNames.StoredEnv != "appx_stored_env" {
 		t.Fatalf("prefix-derived names mismatch: %#v", so2.Config.TableNames)
 	}
+	cfg2.MaxStoredEnvEntries, cfg2.StoredEnvBatchSize = 500, 50
+	if got := cfg2.ToStorOptions().StoredEnv; got != (apirun.StoredEnvLimits{MaxEntries: 500, BatchSize: 50}) {
+		t.Fatalf("stored env limits = %+v", got)
+	}
+	off := false
+	cfg2.Lock, cfg2.LockTimeout = &off, 30*time.Second
+	if so := cfg2.ToStorOptions(); so.Lock == nil || *so.Lock || so.LockTimeout != 30*time.Second {
+		t.Fatalf("lock options = %v, %v", so.Lock, so.LockTimeout)
+	}
+}
+
+// A registered custom driver gets its options; unknown types still default to sqlite
+func TestStoreConfig_ToStorOptions_RegisteredDriver(t *testing.T) {
+	apirun.RegisterStoreDriver("cli-custom", func(map[string]interface{}) (apirun.StoreBackend, error) { return nil, nil })
+	cfg := &StoreConfig{Type: "CLI-Custom", TablePrefix: "appx", Options: map[string]interface{}{"endpoint": "http://etcd:2379"}}
+	so := cfg.ToStorOptions()
+	if so == nil || so.Config.Driver != "cli-custom" {
+		t.Fatalf("expected the registered driver, got %#v", so)
+	}
+	if so.Config.DriverConfig.ToMap()["endpoint"] != "http://etcd:2379" || so.Config.TableNames.StoredEnv != "appx_stored_env" {
+		t.Fatalf("expected options and table names to be passed through, got %#v", so.Config)
+	}
+	if so := (&StoreConfig{Type: "not-registered"}).ToStorOptions(); so == nil || so.Config.Driver != apirun.DriverSqlite {
+		t.Fatalf("expected sqlite for an unknown type, got %#v", so)
+	}
+}
+
+func TestStoreConfig_ToStorOptions_Mongo(t *testing.T) {
+	cfg := &StoreConfig{Type: "mongo", TablePrefix: "appx", Mongo: apirun.MongoConfig{URI: "mongodb://db:27017", Database: "ops"}}
+	so := cfg.ToStorOptions()
+	if so == nil || so.Config.Driver != apirun.DriverMongo {
+		t.Fatalf("expected the mongo driver, got %#v", so)
+	}
+	mc, ok := so.Config.DriverConfig.(*apirun.MongoConfig)
+	if !ok || mc.URI != "mongodb://db:27017" || mc.Database != "ops" || so.Config.TableNames.StoredEnv != "appx_stored_env" {
+		t.Fatalf("unexpected mongo store config: %#v", so.Config)
+	}
 }
 
 // Ensure CLI sees struct-based auth types via re-export and map builder
@@ -97,6 +145,27 @@ func TestDecodeAuth_RendersTemplatesInAuthConfig(t *testing.T) {
 	}
 }
 
+func TestDecodeAuth_DependsOn(t *testing.T) {
+	doc := &ConfigDoc{Auth: []AuthConfig{
+		{Type: "basic", Name: "scoped", DependsOn: "service", Config: map[string]interface{}{"username": "scoped", "password": "{{.auth.service}}"}},
+		{Type: "basic", Name: "service", Config: map[string]interface{}{"username": "u", "password": "p"}},
+	}}
+	base := env.New()
+	if err := doc.DecodeAuth(context.Background(), base); err != nil {
+		t.Fatalf("DecodeAuth: %v", err)
+	}
+	service := base64.StdEncoding.EncodeToString([]byte("u:p"))
+	want := base64.StdEncoding.EncodeToString([]byte("scoped:" + service))
+	if got := base.RenderGoTemplate("{{.auth.scoped}}"); got != want {
+		t.Fatalf("expected the chained token %q, got %q", want, got)
+	}
+
+	doc.Auth[1].DependsOn = "scoped"
+	if err := doc.DecodeAuth(context.Background(), env.New()); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
+
 func TestPublicAuthHelpers_WireThrough(t *testing.T) {
 	// Register a temporary provider and acquire via struct-based API
 	iauth.Register("test-wire", func(spec map[string]interface{}) (iauth.Method, error) {
@@ -112,3 +181,100 @@ func TestPublicAuthHelpers_WireThrough(t *testing.T) {
 type dummyMethodWire string
 
 func (d dummyMethodWire) Acquire(_ context.Context) (string, error) { return string(d), nil }
+
+func TestSetupLogging_FileOutput(t *testing.T) {
+	prev := apirun.GetLogger()
+	t.Cleanup(func() { apirun.SetDefaultLogger(prev) })
+
+	path := filepath.Join(t.TempDir(), "apirun.log")
+	doc := ConfigDoc{Logging: LoggingConfig{Format: "json", File: LogFileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 3, MaxAge: "24h"}}}
+	if err := doc.SetupLogging(); err != nil {
+		t.Fatalf("SetupLogging: %v", err)
+	}
+	logger := apirun.GetLogger()
+	defer func() { _ = logger.Close() }()
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		t.Fatalf("expected log file to receive records, got %q, %v", data, err)
+	}
+
+	stamped := filepath.Join(t.TempDir(), "stamped.log")
+	doc = ConfigDoc{Logging: LoggingConfig{TimeFormat: "2006/01/02 15:04:05Z07:00", UTC: true, File: LogFileConfig{Path: stamped}}}
+	if err := doc.SetupLogging(); err != nil {
+		t.Fatalf("SetupLogging: %v", err)
+	}
+	_ = apirun.GetLogger().Close()
+	if data, err := os.ReadFile(stamped); err != nil || !regexp.MustCompile(`time="\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}Z"`).Match(data) {
+		t.Fatalf("expected time_format and utc in the log file, got %q, %v", data, err)
+	}
+
+	bad := ConfigDoc{Logging: LoggingConfig{File: LogFileConfig{Path: path, MaxAge: "soon"}}}
+	if err := bad.SetupLogging(); err == nil {
+		t.Fatalf("expected error for invalid max_age")
+	}
+	color := ConfigDoc{Logging: LoggingConfig{Format: "color", File: LogFileConfig{Path: path}}}
+	if err := color.SetupLogging(); err == nil {
+		t.Fatalf("expected error for color format with file output")
+	}
+}
+
+func TestTransportConfig_ToTransport(t *testing.T) {
+	if tr, err := (TransportConfig{}).ToTransport(); err != nil || tr != nil {
+		t.Fatalf("expected nil for an empty config, got %#v, %v", tr, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := "client:\n  transport:\n    force_attempt_http2: true\n    max_idle_conns: 20\n    idle_conn_timeout: 45s\n    disable_keep_alives: true\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	var doc ConfigDoc
+	if err := doc.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tr, err := doc.Client.Transport.ToTransport()
+	if err != nil {
+		t.Fatalf("ToTransport: %v", err)
+	}
+	want := apirun.TransportConfig{ForceAttemptHTTP2: true, MaxIdleConns: 20, IdleConnTimeout: 45 * time.Second, DisableKeepAlives: true}
+	if tr == nil || *tr != want {
+		t.Fatalf("got %#v, want %#v", tr, want)
+	}
+
+	for _, bad := range []TransportConfig{{IdleConnTimeout: "soon"}, {IdleConnTimeout: "-1s"}, {MaxIdleConns: -1}} {
+		if _, err := bad.ToTransport(); err == nil {
+			t.Fatalf("expected an error for %#v", bad)
+		}
+	}
+}
+
+func TestCircuitBreakerConfig_ToCircuitBreaker(t *testing.T) {
+	var none *CircuitBreakerConfig
+	if cb, err := none.ToCircuitBreaker(); err != nil || cb != nil {
+		t.Fatalf("expected nil without a section, got %#v, %v", cb, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := "client:\n  circuit_breaker:\n    failure_threshold: 3\n    window: 1m\n    cooldown: 10s\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	var doc ConfigDoc
+	if err := doc.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	_, cb, err := doc.Client.ToClientOptions()
+	if err != nil {
+		t.Fatalf("ToClientOptions: %v", err)
+	}
+	want := apirun.CircuitBreakerConfig{FailureThreshold: 3, Window: time.Minute, Cooldown: 10 * time.Second}
+	if cb == nil || *cb != want {
+		t.Fatalf("got %#v, want %#v", cb, want)
+	}
+
+	for _, bad := range []CircuitBreakerConfig{{Cooldown: "soon"}, {Window: "-1s"}, {FailureThreshold: -1}} {
+		if _, err := bad.ToCircuitBreaker(); err == nil {
+			t.Fatalf("expected an error for %#v", bad)
+		}
+	}
+}
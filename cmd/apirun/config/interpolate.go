@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRefRegex matches ${VAR} and ${VAR:-default}, optionally preceded by an escaping '$'.
+// Go templates ({{...}}) and ${{ ... }} never match because a variable name must follow "${".
+var envRefRegex = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// expandEnvRefs replaces ${VAR} / ${VAR:-default} in s with OS environment values.
+// The default is used when VAR is unset or empty; an unset VAR without default is an error.
+// $${VAR} yields the literal text ${VAR}.
+func expandEnvRefs(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var firstErr error
+	out := envRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		m := envRefRegex.FindStringSubmatch(ref)
+		name, def := m[1], m[2]
+		val, ok := os.LookupEnv(name)
+		if def != "" {
+			if !ok || val == "" {
+				return strings.TrimPrefix(def, ":-")
+			}
+			return val
+		}
+		if !ok && firstErr == nil {
+			firstErr = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return val
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
+
+// interpolateEnv expands environment references in every scalar value of the parsed document.
+// Expansion happens after parsing so that values cannot change the YAML structure. Plain
+// (unquoted) scalars have their tag re-resolved, so "max_parallel: ${N}" still decodes as an int.
+func interpolateEnv(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		if !strings.Contains(n.Value, "${") {
+			return nil
+		}
+		v, err := expandEnvRefs(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = v
+		if n.Style == 0 && n.Tag == "!!str" {
+			n.Tag = ""
+		}
+		return nil
+	}
+	for _, c := range n.Content {
+		if err := interpolateEnv(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
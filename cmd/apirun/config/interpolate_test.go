@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandEnvRefs(t *testing.T) {
+	t.Setenv("APIRUN_TEST_HOST", "db.local")
+	t.Setenv("APIRUN_TEST_EMPTY", "")
+	tests := []struct {
+		in, want string
+	}{
+		{"postgres://${APIRUN_TEST_HOST}:5432", "postgres://db.local:5432"},
+		{"${APIRUN_TEST_MISSING:-fallback}", "fallback"},
+		{"${APIRUN_TEST_EMPTY:-fallback}", "fallback"},
+		{"${APIRUN_TEST_HOST:-fallback}", "db.local"},
+		{"${APIRUN_TEST_MISSING:-}", ""},
+		{"${APIRUN_TEST_EMPTY}", ""},
+		{"$${APIRUN_TEST_HOST}", "${APIRUN_TEST_HOST}"},
+		{"{{.env.host}} and ${{ not.a.var }}", "{{.env.host}} and ${{ not.a.var }}"},
+		{"$HOME stays", "$HOME stays"},
+	}
+	for _, tt := range tests {
+		got, err := expandEnvRefs(tt.in)
+		if err != nil {
+			t.Fatalf("expandEnvRefs(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Fatalf("expandEnvRefs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if _, err := expandEnvRefs("x ${APIRUN_TEST_MISSING} y"); err == nil || !strings.Contains(err.Error(), "APIRUN_TEST_MISSING is not set") {
+		t.Fatalf("expected missing variable error, got %v", err)
+	}
+}
+
+func TestConfigDoc_Load_InterpolatesEnv(t *testing.T) {
+	t.Setenv("APIRUN_TEST_PG_DSN", "postgres://u:p@db:5432/app?sslmode=disable")
+	t.Setenv("APIRUN_TEST_PARALLEL", "4")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := `migrate_dir: ${APIRUN_TEST_DIR:-./migrations}
+max_parallel: ${APIRUN_TEST_PARALLEL}
+store:
+  type: postgres
+  postgres:
+    dsn: ${APIRUN_TEST_PG_DSN}
+    max_open_conns: 40
+    conn_max_lifetime: 5m
+env:
+  - name: greeting
+    value: "hello {{.env.name}}"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var doc ConfigDoc
+	if err := doc.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if doc.MigrateDir != "./migrations" || doc.MaxParallel != 4 {
+		t.Fatalf("unexpected migrate_dir/max_parallel: %q %d", doc.MigrateDir, doc.MaxParallel)
+	}
+	if doc.Store.Postgres.DSN != "postgres://u:p@db:5432/app?sslmode=disable" {
+		t.Fatalf("unexpected dsn: %q", doc.Store.Postgres.DSN)
+	}
+	if doc.Store.Postgres.MaxOpenConns != 40 || doc.Store.Postgres.ConnMaxLifetime != 5*time.Minute {
+		t.Fatalf("unexpected pool settings: %+v", doc.Store.Postgres)
+	}
+	if len(doc.Env) != 1 || doc.Env[0].Value != "hello {{.env.name}}" {
+		t.Fatalf("templates must be left untouched: %+v", doc.Env)
+	}
+
+	if err := os.WriteFile(path, []byte("store:\n  postgres:\n    dsn: ${APIRUN_TEST_UNSET_DSN}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var bad ConfigDoc
+	if err := bad.Load(path); err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "APIRUN_TEST_UNSET_DSN") {
+		t.Fatalf("expected missing variable error with line, got %v", err)
+	}
+}
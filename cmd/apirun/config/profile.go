@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ActiveProfile names the profile that Load merges over the base config ("" = none).
+// The CLI sets it from --profile or APIRUN_PROFILE.
+var ActiveProfile string
+
+const profilesKey = "profiles"
+
+// applyProfile removes the profiles section from the parsed document and, when profile is set,
+// deep-merges that profile over the top-level keys.
+func applyProfile(root *yaml.Node, profile string) error {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		if profile != "" {
+			return fmt.Errorf("profile %q: the config defines no profiles", profile)
+		}
+		return nil
+	}
+	var profiles *yaml.Node
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == profilesKey {
+			profiles = doc.Content[i+1]
+			doc.Content = append(doc.Content[:i], doc.Content[i+2:]...)
+			break
+		}
+	}
+	if profiles != nil && profiles.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: profiles must be a mapping of profile names", profiles.Line)
+	}
+	if profile == "" {
+		return nil
+	}
+	if profiles == nil || len(profiles.Content) == 0 {
+		return fmt.Errorf("profile %q: the config defines no profiles", profile)
+	}
+	names := make([]string, 0, len(profiles.Content)/2)
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		name, over := profiles.Content[i].Value, profiles.Content[i+1]
+		if name != profile {
+			names = append(names, name)
+			continue
+		}
+		if over.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: profile %q must be a mapping", over.Line, profile)
+		}
+		mergeNode(doc, over)
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown profile %q (available: %s)", profile, strings.Join(names, ", "))
+}
+
+// mergeNode merges over into base. Mappings merge key by key, lists of named entries (env,
+// auth) merge entry by entry on their name, and everything else is replaced.
+func mergeNode(base, over *yaml.Node) {
+	switch {
+	case base.Kind == yaml.MappingNode && over.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(over.Content); i += 2 {
+			key, val := over.Content[i], over.Content[i+1]
+			if cur := mappingValue(base, key.Value); cur != nil {
+				mergeNode(cur, val)
+				continue
+			}
+			base.Content = append(base.Content, key, val)
+		}
+	case base.Kind == yaml.SequenceNode && over.Kind == yaml.SequenceNode && len(over.Content) > 0 && namedEntries(base) && namedEntries(over):
+		for _, item := range over.Content {
+			name := mappingValue(item, "name").Value
+			matched := false
+			for _, cur := range base.Content {
+				if mappingValue(cur, "name").Value == name {
+					mergeNode(cur, item)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				base.Content = append(base.Content, item)
+			}
+		}
+	default:
+		*base = *over
+	}
+}
+
+// mappingValue returns the value stored under key in a mapping node, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// namedEntries reports whether every item of a sequence is a mapping with a scalar name.
+func namedEntries(n *yaml.Node) bool {
+	for _, item := range n.Content {
+		name := mappingValue(item, "name")
+		if name == nil || name.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// profileConfig is written by writeProfileConfig with DIR replaced by a temp dir, so that any
+// migration dir or store it names stays out of the source tree.
+const profileConfig = `migrate_dir: DIR/migration
+max_parallel: 2
+env:
+  - name: base_url
+    value: http://localhost:8080
+  - name: realm
+    value: dev
+store:
+  save_response_body: true
+  sqlite:
+    path: DIR/dev.db
+auth:
+  - type: basic
+    name: admin
+    config:
+      username: admin
+      password: admin
+profiles:
+  prod:
+    migrate_dir: DIR/migration-prod
+    env:
+      - name: base_url
+        value: https://api.example.com
+      - name: region
+        value: eu
+    store:
+      type: postgres
+      postgres:
+        dsn: ${APIRUN_TEST_PROD_DSN}
+    auth:
+      - name: admin
+        config:
+          password: s3cret
+  stage:
+    max_parallel: 4
+`
+
+func writeProfileConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(profileConfig, "DIR", filepath.ToSlash(dir))), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestConfigDoc_LoadProfile_MergesOverBase(t *testing.T) {
+	t.Setenv("APIRUN_TEST_PROD_DSN", "postgres://prod")
+	var doc ConfigDoc
+	path := writeProfileConfig(t)
+	dir := filepath.ToSlash(filepath.Dir(path))
+	if err := doc.LoadProfile(path, "prod"); err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if doc.MigrateDir != dir+"/migration-prod" {
+		t.Fatalf("migrate_dir = %q, want the profile value", doc.MigrateDir)
+	}
+	if doc.MaxParallel != 2 {
+		t.Fatalf("max_parallel = %d, want the base value", doc.MaxParallel)
+	}
+	// Mappings merge key by key
+	if doc.Store.Type != "postgres" || doc.Store.Postgres.DSN != "postgres://prod" {
+		t.Fatalf("store = %+v, want the profile postgres store", doc.Store)
+	}
+	if !doc.Store.SaveResponseBody || doc.Store.SQLite.Path != dir+"/dev.db" {
+		t.Fatalf("store lost base keys: %+v", doc.Store)
+	}
+	// Named entries merge on their name; new ones are appended
+	want := []EnvConfig{{Name: "base_url", Value: "https://api.example.com"}, {Name: "realm", Value: "dev"}, {Name: "region", Value: "eu"}}
+	if len(doc.Env) != len(want) {
+		t.Fatalf("env = %+v, want %+v", doc.Env, want)
+	}
+	for i := range want {
+		if doc.Env[i] != want[i] {
+			t.Fatalf("env = %+v, want %+v", doc.Env, want)
+		}
+	}
+	if len(doc.Auth) != 1 || doc.Auth[0].Type != "basic" || doc.Auth[0].Config["username"] != "admin" || doc.Auth[0].Config["password"] != "s3cret" {
+		t.Fatalf("auth = %+v", doc.Auth)
+	}
+}
+
+func TestConfigDoc_LoadProfile_BaseOnly(t *testing.T) {
+	// Without a profile the profiles section is ignored, including its unset variables
+	var doc ConfigDoc
+	path := writeProfileConfig(t)
+	if err := doc.LoadProfile(path, ""); err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if doc.MigrateDir != filepath.ToSlash(filepath.Dir(path))+"/migration" || doc.Store.Type != "" || len(doc.Env) != 2 {
+		t.Fatalf("base config changed: %+v", doc)
+	}
+	if err := doc.LoadProfile(writeProfileConfig(t), "stage"); err != nil {
+		t.Fatalf("LoadProfile(stage): %v", err)
+	}
+	if doc.MaxParallel != 4 {
+		t.Fatalf("max_parallel = %d, want 4", doc.MaxParallel)
+	}
+}
+
+func TestConfigDoc_LoadProfile_Unknown(t *testing.T) {
+	var doc ConfigDoc
+	err := doc.LoadProfile(writeProfileConfig(t), "qa")
+	if err == nil || !strings.Contains(err.Error(), `unknown profile "qa" (available: prod, stage)`) {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "plain.yaml")
+	if err := os.WriteFile(path, []byte("migrate_dir: ./migration\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := doc.LoadProfile(path, "prod"); err == nil || !strings.Contains(err.Error(), "defines no profiles") {
+		t.Fatalf("expected no profiles error, got %v", err)
+	}
+}
+
+func TestConfigDoc_Load_ActiveProfile(t *testing.T) {
+	t.Setenv("APIRUN_TEST_PROD_DSN", "postgres://prod")
+	prev := ActiveProfile
+	ActiveProfile = "prod"
+	defer func() { ActiveProfile = prev }()
+	var doc ConfigDoc
+	path := writeProfileConfig(t)
+	if err := doc.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if doc.MigrateDir != filepath.ToSlash(filepath.Dir(path))+"/migration-prod" {
+		t.Fatalf("migrate_dir = %q, want the active profile value", doc.MigrateDir)
+	}
+}
@@ -17,16 +17,14 @@ func NewStoreFactory() *StoreFactory {
 	return &StoreFactory{}
 }
 
-// CreateStoreConfig creates a store configuration from the given config
+// CreateStoreConfig creates a store configuration from the given config, for sqlite when no
+// type is set. It returns nil when the store is disabled.
 func (f *StoreFactory) CreateStoreConfig(config StoreConfig) *apirun.StoreConfig {
 	if config.Disabled {
 		return nil
 	}
 
 	stType := util.TrimAndLower(config.Type)
-	if stType == "" {
-		return nil
-	}
 
 	// Build table names
 	tableNames := buildTableNames(
@@ -37,12 +35,23 @@ func (f *StoreFactory) CreateStoreConfig(config StoreConfig) *apirun.StoreConfig
 	)
 
 	// Use appropriate database-specific builder
-	if stType == apirun.DriverPostgresql {
-		return buildPostgresStoreConfig(config.Postgres, tableNames)
+	var sc *apirun.StoreConfig
+	switch {
+	case stType == apirun.DriverPostgresql:
+		sc = buildPostgresStoreConfig(config.Postgres, tableNames)
+	case stType == apirun.DriverMongo:
+		mc := config.Mongo
+		sc = apirun.NewMongoStoreConfig(&mc, tableNames)
+	case apirun.IsStoreDriverRegistered(stType):
+		sc = apirun.NewCustomStoreConfig(stType, config.Options, tableNames)
+	default:
+		// Default to SQLite; an empty path is resolved under the migration directory on connect
+		sc = buildSqliteStoreConfig(config.SQLite.Path, tableNames)
 	}
-
-	// Default to SQLite
-	return buildSqliteStoreConfig(config.SQLite.Path, tableNames)
+	sc.StoredEnv = apirun.StoredEnvLimits{MaxEntries: config.MaxStoredEnvEntries, BatchSize: config.StoredEnvBatchSize}
+	sc.Lock = config.Lock
+	sc.LockTimeout = config.LockTimeout
+	return sc
 }
 
 // buildTableNames constructs the table names based on the configuration
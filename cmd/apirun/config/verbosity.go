@@ -0,0 +1,31 @@
+package config
+
+import "github.com/loykin/apirun"
+
+// Verbosity overrides the configured log level: > 0 logs at debug, -1 at warn and -2 or
+// less at error (0 = as configured). The CLI sets it from the -v and -q counts.
+var Verbosity int
+
+// EffectiveLogLevel returns level overridden by Verbosity.
+func EffectiveLogLevel(level apirun.LogLevel) apirun.LogLevel {
+	switch {
+	case Verbosity > 0:
+		return apirun.LogLevelDebug
+	case Verbosity == -1:
+		return apirun.LogLevelWarn
+	case Verbosity < -1:
+		return apirun.LogLevelError
+	default:
+		return level
+	}
+}
+
+// ApplyVerbosity installs a default logger at the level chosen by -v/-q, so that commands
+// that do not set up logging from the config honor the flags too. Without flags it does
+// nothing.
+func ApplyVerbosity() {
+	if Verbosity == 0 {
+		return
+	}
+	apirun.SetDefaultLogger(apirun.NewLogger(EffectiveLogLevel(apirun.LogLevelInfo)))
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/loykin/apirun"
+)
+
+func TestSetupLogging_VerbosityOverridesConfig(t *testing.T) {
+	prev := apirun.GetLogger()
+	t.Cleanup(func() { apirun.SetDefaultLogger(prev); Verbosity = 0 })
+
+	doc := ConfigDoc{Logging: LoggingConfig{Level: "error"}}
+	for v, want := range map[int]apirun.LogLevel{0: apirun.LogLevelError, 1: apirun.LogLevelDebug, 3: apirun.LogLevelDebug, -1: apirun.LogLevelWarn, -2: apirun.LogLevelError} {
+		Verbosity = v
+		if err := doc.SetupLogging(); err != nil {
+			t.Fatalf("SetupLogging: %v", err)
+		}
+		if got := apirun.GetLogger().Level(); got != want {
+			t.Fatalf("verbosity %d: level = %v, want %v", v, got, want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/loykin/apirun/cmd/apirun/commands"
+	"github.com/loykin/apirun/cmd/apirun/config"
 	"github.com/loykin/apirun/cmd/apirun/runner"
 	"github.com/loykin/apirun/cmd/apirun/validation"
 	"github.com/spf13/cobra"
@@ -13,6 +15,16 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "apirun",
 	Short: "Run API migrations defined in YAML files",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// An explicit --config (or APIRUN_CONFIG) wins over APIRUN_CONFIG_DIR; the two flags
+		// themselves are mutually exclusive
+		if cmd.Flags().Changed("config") || (os.Getenv("APIRUN_CONFIG") != "" && !cmd.Flags().Changed("config-dir")) {
+			viper.Set("config_dir", "")
+		}
+		config.ActiveProfile = viper.GetString("profile")
+		config.Verbosity = viper.GetInt("verbose") - viper.GetInt("quiet")
+		config.ApplyVerbosity()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 		r := runner.NewMigrationRunner(ctx)
@@ -27,33 +39,116 @@ func init() {
 	v.SetDefault("to", 0)
 	v.SetDefault("dry_run", false)
 	v.SetDefault("dry_run_from", 0)
+	v.SetDefault("dry_run_format", "text")
+	v.SetDefault("fail_fast", true)
 
 	// Environment variables support: APIRUN_CONFIG, ...
 	v.SetEnvPrefix("APIRUN")
 	v.AutomaticEnv()
 	// Bind flags via Cobra and then bind to Viper
 	rootCmd.PersistentFlags().String("config", v.GetString("config"), "path to a config yaml (like examples/keycloak_migration/config.yaml)")
+	rootCmd.PersistentFlags().String("profile", "", "config profile merged over the base config (env: APIRUN_PROFILE)")
+	rootCmd.PersistentFlags().StringSlice("env-file", nil, "YAML, JSON or .env file of global env values (repeatable; later files win, config env wins over files)")
+	rootCmd.PersistentFlags().CountP("verbose", "v", "log at debug level, overriding the config's logging level")
+	rootCmd.PersistentFlags().CountP("quiet", "q", "log only warnings (-q) or errors (-qq), overriding the config's logging level")
+	rootCmd.Flags().String("output", "", "Go template printed per applied migration, e.g. 'v{{.Version}} -> {{.StatusCode}}'")
+	rootCmd.PersistentFlags().String("config-dir", "", "directory holding config.yaml and/or a migration/ folder, used instead of --config (env: APIRUN_CONFIG_DIR)")
 	commands.UpCmd.Flags().Int("to", v.GetInt("to"), "target version to migrate up to (0 = all)")
 	commands.UpCmd.Flags().Bool("dry-run", v.GetBool("dry_run"), "simulate migrations without writing to the store")
 	commands.UpCmd.Flags().Int("dry-run-from", v.GetInt("dry_run_from"), "version from which to start dry-run mode (0 = disabled)")
+	commands.UpCmd.Flags().String("dry-run-format", v.GetString("dry_run_format"), "dry-run report format: text or json")
+	commands.UpCmd.Flags().Bool("fail-fast", v.GetBool("fail_fast"), "stop at the first failing migration; --fail-fast=false attempts all and reports the failed versions")
+	commands.UpCmd.Flags().String("tags", "", "comma-separated tags; only apply migrations tagged with one of them")
+	commands.UpCmd.Flags().Bool("tags-allow-gaps", false, "with --tags, skip non-matching pending migrations instead of stopping at the first one")
+	commands.UpCmd.Flags().String("output", "", "Go template printed per applied migration, e.g. 'v{{.Version}} -> {{.StatusCode}}'")
 	commands.DownCmd.Flags().Int("to", v.GetInt("to"), "target version to migrate down to")
 	commands.DownCmd.Flags().Bool("dry-run", v.GetBool("dry_run"), "simulate rollbacks without writing to the store")
 	commands.DownCmd.Flags().Int("dry-run-from", v.GetInt("dry_run_from"), "version from which to start dry-run mode (0 = disabled)")
+	commands.DownCmd.Flags().String("dry-run-format", v.GetString("dry_run_format"), "dry-run report format: text or json")
+	commands.DownCmd.Flags().Bool("yes", false, "roll back without the confirmation prompt (required in non-interactive sessions)")
+	commands.DownCmd.Flags().String("output", "", "Go template printed per rolled back migration, e.g. 'v{{.Version}} -> {{.StatusCode}}'")
+	commands.ToCmd.Flags().Int("version", 0, "exact version to migrate to (0 = fully rolled back)")
+	commands.RedoCmd.Flags().Int("to", 0, "applied version to redo (0 = latest applied)")
+	commands.PlanDiffCmd.Flags().Int("to", 0, "target version to diff up to (0 = all)")
+	commands.PlanDiffCmd.Flags().String("format", "text", "report format: text or json")
+	commands.SmokeCmd.Flags().StringSlice("target", nil, "base URL to smoke-test (repeatable or comma-separated)")
+	commands.SmokeCmd.Flags().String("targets-file", "", "file of base URLs to smoke-test, one per line")
+	commands.SmokeCmd.Flags().Int("parallel", 4, "targets checked concurrently (0 = all)")
+	commands.SmokeCmd.Flags().String("url-var", "base_url", "global env name each target URL is set as")
+	commands.SmokeCmd.Flags().String("readonly-tag", "readonly", "tag of migrations whose up request is sent whatever its method")
+	commands.SmokeCmd.Flags().Int("to", 0, "highest version to check (0 = all)")
+	commands.SmokeCmd.Flags().String("format", "text", "report format: text or json")
+	commands.BaselineCmd.Flags().Int("to", 0, "highest version to mark as applied")
+	commands.ExportCmd.Flags().String("out", "", "file to write the export to (default: stdout)")
+	commands.ImportCmd.Flags().String("in", "", "export file to import into the (empty) store")
+	commands.PruneCmd.Flags().String("before", "", "only delete runs older than this: an age (30d, 12h) or an RFC3339 time")
+	commands.PruneCmd.Flags().Int("keep", 0, "runs to keep per version regardless of age")
+	commands.CreateCmd.Flags().String("template", "", "template file for the new migration instead of the built-in skeleton")
+	commands.CreateCmd.Flags().String("kind", "", "name of a template in the templates directory (<kind>.yaml)")
+	commands.CreateCmd.Flags().String("templates-dir", "", "directory of --kind templates (default: <migrate_dir>/templates)")
+	validation.LintCmd.Flags().Int("max-warnings", -1, "fail when there are more than this many warnings (-1 = no limit)")
 
 	_ = v.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	_ = v.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	_ = v.BindPFlag("env_files", rootCmd.PersistentFlags().Lookup("env-file"))
+	_ = v.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	_ = v.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
+	_ = v.BindPFlag("output", rootCmd.Flags().Lookup("output"))
+	_ = v.BindPFlag("config_dir", rootCmd.PersistentFlags().Lookup("config-dir"))
+	rootCmd.MarkFlagsMutuallyExclusive("config", "config-dir")
 	_ = v.BindPFlag("to", commands.UpCmd.Flags().Lookup("to"))
 	_ = v.BindPFlag("dry_run", commands.UpCmd.Flags().Lookup("dry-run"))
 	_ = v.BindPFlag("dry_run_from", commands.UpCmd.Flags().Lookup("dry-run-from"))
+	_ = v.BindPFlag("dry_run_format", commands.UpCmd.Flags().Lookup("dry-run-format"))
+	_ = v.BindPFlag("fail_fast", commands.UpCmd.Flags().Lookup("fail-fast"))
+	_ = v.BindPFlag("tags", commands.UpCmd.Flags().Lookup("tags"))
+	_ = v.BindPFlag("tags_allow_gaps", commands.UpCmd.Flags().Lookup("tags-allow-gaps"))
+	_ = v.BindPFlag("up_output", commands.UpCmd.Flags().Lookup("output"))
 	_ = v.BindPFlag("to", commands.DownCmd.Flags().Lookup("to"))
 	_ = v.BindPFlag("dry_run", commands.DownCmd.Flags().Lookup("dry-run"))
 	_ = v.BindPFlag("dry_run_from", commands.DownCmd.Flags().Lookup("dry-run-from"))
+	_ = v.BindPFlag("dry_run_format", commands.DownCmd.Flags().Lookup("dry-run-format"))
+	_ = v.BindPFlag("down_yes", commands.DownCmd.Flags().Lookup("yes"))
+	_ = v.BindPFlag("down_output", commands.DownCmd.Flags().Lookup("output"))
+	_ = v.BindPFlag("version", commands.ToCmd.Flags().Lookup("version"))
+	_ = v.BindPFlag("redo_to", commands.RedoCmd.Flags().Lookup("to"))
+	_ = v.BindPFlag("plan_diff_to", commands.PlanDiffCmd.Flags().Lookup("to"))
+	_ = v.BindPFlag("plan_diff_format", commands.PlanDiffCmd.Flags().Lookup("format"))
+	_ = v.BindPFlag("smoke_targets", commands.SmokeCmd.Flags().Lookup("target"))
+	_ = v.BindPFlag("smoke_targets_file", commands.SmokeCmd.Flags().Lookup("targets-file"))
+	_ = v.BindPFlag("smoke_parallel", commands.SmokeCmd.Flags().Lookup("parallel"))
+	_ = v.BindPFlag("smoke_url_var", commands.SmokeCmd.Flags().Lookup("url-var"))
+	_ = v.BindPFlag("smoke_readonly_tag", commands.SmokeCmd.Flags().Lookup("readonly-tag"))
+	_ = v.BindPFlag("smoke_to", commands.SmokeCmd.Flags().Lookup("to"))
+	_ = v.BindPFlag("smoke_format", commands.SmokeCmd.Flags().Lookup("format"))
+	_ = v.BindPFlag("baseline_to", commands.BaselineCmd.Flags().Lookup("to"))
+	_ = v.BindPFlag("export_out", commands.ExportCmd.Flags().Lookup("out"))
+	_ = v.BindPFlag("import_in", commands.ImportCmd.Flags().Lookup("in"))
+	_ = v.BindPFlag("prune_before", commands.PruneCmd.Flags().Lookup("before"))
+	_ = v.BindPFlag("prune_keep", commands.PruneCmd.Flags().Lookup("keep"))
+	_ = v.BindPFlag("create_template", commands.CreateCmd.Flags().Lookup("template"))
+	_ = v.BindPFlag("create_kind", commands.CreateCmd.Flags().Lookup("kind"))
+	_ = v.BindPFlag("create_templates_dir", commands.CreateCmd.Flags().Lookup("templates-dir"))
+	_ = v.BindPFlag("lint_max_warnings", validation.LintCmd.Flags().Lookup("max-warnings"))
 
 	rootCmd.AddCommand(commands.UpCmd)
 	rootCmd.AddCommand(commands.DownCmd)
+	rootCmd.AddCommand(commands.ToCmd)
+	rootCmd.AddCommand(commands.RedoCmd)
+	rootCmd.AddCommand(commands.PlanDiffCmd)
+	rootCmd.AddCommand(commands.SmokeCmd)
+	rootCmd.AddCommand(commands.BaselineCmd)
 	rootCmd.AddCommand(commands.StatusCmd)
+	rootCmd.AddCommand(commands.HistoryCmd)
+	rootCmd.AddCommand(commands.ExportCmd)
+	rootCmd.AddCommand(commands.ImportCmd)
+	rootCmd.AddCommand(commands.PruneCmd)
 	rootCmd.AddCommand(commands.CreateCmd)
 	rootCmd.AddCommand(commands.StagesCmd)
+	rootCmd.AddCommand(commands.DoctorCmd)
 	rootCmd.AddCommand(validation.ValidateCmd)
+	rootCmd.AddCommand(validation.LintCmd)
+	rootCmd.AddCommand(validation.SchemaCmd)
 }
 
 func main() {
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/cmd/apirun/config"
+)
+
+func TestRootCmd_VerbosityFlags(t *testing.T) {
+	prev := apirun.GetLogger()
+	t.Cleanup(func() {
+		apirun.SetDefaultLogger(prev)
+		config.Verbosity = 0
+		rootCmd.SetArgs(nil)
+	})
+
+	dir := t.TempDir()
+	mig := "up:\n  name: x\n  request:\n    method: GET\n    url: http://localhost/x\n  response:\n    result_code: ['200']\ndown:\n  name: x\n  method: DELETE\n  url: http://localhost/x\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_x.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfg, []byte("migrate_dir: "+dir+"\nlogging:\n  level: info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want apirun.LogLevel
+	}{
+		{[]string{"validate", "-v", "--config", cfg}, apirun.LogLevelDebug},
+		{[]string{"-qq", "validate", "--config", cfg}, apirun.LogLevelError},
+		{[]string{"validate", "-q", "--config", cfg}, apirun.LogLevelWarn},
+	} {
+		for _, f := range []string{"verbose", "quiet"} {
+			_ = rootCmd.PersistentFlags().Set(f, "0")
+		}
+		rootCmd.SetArgs(tc.args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if got := apirun.GetLogger().Level(); got != tc.want {
+			t.Fatalf("%v: logger level = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestRootCmd_ConfigWinsOverConfigDirEnv(t *testing.T) {
+	t.Cleanup(func() { rootCmd.SetArgs(nil) })
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfg, []byte("migrate_dir: "+dir+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// a broken APIRUN_CONFIG_DIR would fail the command if it were used
+	t.Setenv("APIRUN_CONFIG_DIR", filepath.Join(dir, "missing"))
+
+	rootCmd.SetArgs([]string{"status", "--config", cfg})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("expected --config to win over APIRUN_CONFIG_DIR: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRunner_ConfigDir_DiscoversConfigAndMigrations(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	migDir := filepath.Join(dir, "migration")
+	if err := os.Mkdir(migDir, 0o750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	_ = writeFile(t, dir, "config.yaml", fmt.Sprintf("env:\n  - name: base\n    value: %s\n", srv.URL))
+	_ = writeFile(t, migDir, "001_ping.yaml", "up:\n  name: ping\n  request:\n    method: GET\n    url: '{{.env.base}}/ping'\n  response:\n    result_code: ['200']\n")
+
+	v := viper.GetViper()
+	v.Set("config_dir", dir)
+	defer v.Set("config_dir", "")
+	if err := NewMigrationRunner(context.Background()).Run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Join(got, ",") != "/ping" {
+		t.Fatalf("requests = %v, want the config env to reach the migration", got)
+	}
+	if _, err := os.Stat(filepath.Join(migDir, "apirun.db")); err != nil {
+		t.Fatalf("expected the store under the discovered migration folder: %v", err)
+	}
+
+}
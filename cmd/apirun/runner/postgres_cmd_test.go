@@ -144,6 +144,7 @@ store:
 	}
 
 	// Run down and validate the API effect (path recorded by the server)
+	v.Set("down_yes", true)
 	if err := commands.DownCmd.RunE(commands.DownCmd, nil); err != nil {
 		t.Fatalf("down error: %v", err)
 	}
@@ -8,12 +8,25 @@ import (
 	"regexp"
 	"sort"
 
+	imig "github.com/loykin/apirun/internal/migration"
+	"github.com/loykin/apirun/internal/task"
 	"gopkg.in/yaml.v3"
 )
 
+// ValidateMigrationDir runs the checks of the validate command on the migration files in dir.
+// versionPattern selects them as the migrator does ("" = the default, 001_name.yaml); results is
+// empty when no file matches.
+func ValidateMigrationDir(dir, versionPattern string) (*ValidationResults, error) {
+	return validateMigrationFiles(dir, versionPattern)
+}
+
 // validateMigrationFiles validates all migration files in the specified directory
-func validateMigrationFiles(dir string) (*ValidationResults, error) {
+func validateMigrationFiles(dir, versionPattern string) (*ValidationResults, error) {
 	results := &ValidationResults{}
+	re, err := imig.CompileVersionPattern(versionPattern)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
@@ -21,7 +34,7 @@ func validateMigrationFiles(dir string) (*ValidationResults, error) {
 	}
 
 	// Find all migration files
-	files, err := findMigrationFiles(dir)
+	files, err := findMigrationFiles(dir, re)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find migration files: %w", err)
 	}
@@ -32,21 +45,24 @@ func validateMigrationFiles(dir string) (*ValidationResults, error) {
 	}
 
 	// Validate each file
-	var allErrors []string
-	var allWarnings []string
-
 	for _, filePath := range files {
 		result := validateSingleFile(filePath)
+		if _, _, err := imig.ParseVersion(re, filepath.Base(filePath)); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			result.Valid = false
+		}
 		results.AddResult(result)
-
-		allErrors = append(allErrors, result.Errors...)
-		allWarnings = append(allWarnings, result.Warnings...)
 	}
 
-	// Generate summary
-	errorCount := len(allErrors)
-	warningCount := len(allWarnings)
-	fileCount := len(files)
+	summarizeResults(results)
+	return results, nil
+}
+
+// summarizeResults sets the summary line from the current results.
+func summarizeResults(results *ValidationResults) {
+	errorCount := results.ErrorCount()
+	warningCount := results.WarningCount()
+	fileCount := len(results.Results)
 
 	if errorCount == 0 && warningCount == 0 {
 		results.Summary = fmt.Sprintf("✅ All %d migration files are valid", fileCount)
@@ -54,17 +70,13 @@ func validateMigrationFiles(dir string) (*ValidationResults, error) {
 		results.Summary = fmt.Sprintf("Validation completed for %d files: %d errors, %d warnings",
 			fileCount, errorCount, warningCount)
 	}
-
-	return results, nil
 }
 
-// findMigrationFiles discovers migration files in the specified directory
-func findMigrationFiles(dir string) ([]string, error) {
+// findMigrationFiles discovers the migration files in the specified directory whose names match
+// the version pattern, ordered by version like the migrator runs them
+func findMigrationFiles(dir string, versionPattern *regexp.Regexp) ([]string, error) {
 	var files []string
 
-	// Migration file pattern: 001_name.yaml, 002_name.yml, etc.
-	migrationPattern := regexp.MustCompile(`^\d{3}_.*\.ya?ml$`)
-
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -77,7 +89,7 @@ func findMigrationFiles(dir string) ([]string, error) {
 
 		// Check if filename matches migration pattern
 		filename := d.Name()
-		if migrationPattern.MatchString(filename) {
+		if versionPattern.MatchString(filename) {
 			files = append(files, path)
 		}
 
@@ -88,8 +100,18 @@ func findMigrationFiles(dir string) ([]string, error) {
 		return nil, err
 	}
 
-	// Sort files by name to ensure consistent order
-	sort.Strings(files)
+	// Sort by version, then by name, to ensure consistent order
+	version := func(path string) int {
+		v, _, _ := imig.ParseVersion(versionPattern, filepath.Base(path))
+		return v
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		vi, vj := version(files[i]), version(files[j])
+		if vi != vj {
+			return vi < vj
+		}
+		return files[i] < files[j]
+	})
 
 	return files, nil
 }
@@ -117,6 +139,17 @@ func validateSingleFile(filePath string) ValidationResult {
 		return result
 	}
 
+	// Merge shared fragments so the structure check sees the effective migration
+	if _, hasIncludes := migration["includes"]; hasIncludes {
+		merged, err := task.ResolveIncludes(filePath)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Include error: %v", err))
+			result.Valid = false
+			return result
+		}
+		migration = merged
+	}
+
 	// Validate migration structure
 	validateMigrationStructure(migration, &result)
 
@@ -0,0 +1,227 @@
+package validation
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/task"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Lint rules, the categories of a LintFinding, in report order.
+const (
+	// LintMissingDown: an up step that changes something has no down step to roll it back.
+	LintMissingDown = "missing-down"
+	// LintHardcodedCredential: a request field holds a literal value matched by a masking
+	// pattern (password, token, API key, ...) instead of a template reference.
+	LintHardcodedCredential = "hardcoded-credential"
+	// LintUncheckedWrite: a non-idempotent up request (POST, PATCH) accepts any response status.
+	LintUncheckedWrite = "unchecked-write"
+	// LintDuplicateURL: an up request repeats the method and URL of an earlier migration.
+	LintDuplicateURL = "duplicate-url"
+)
+
+var lintRules = []string{LintMissingDown, LintHardcodedCredential, LintUncheckedWrite, LintDuplicateURL}
+
+// LintFinding is a warning of the lint command.
+type LintFinding struct {
+	File    string `json:"file"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var LintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Report anti-patterns in migration files as categorized warnings",
+	Long: `Check the migration files for practices that are valid but risky. Each warning is
+categorized by rule:
+- missing-down: an up step other than GET/HEAD/OPTIONS has no down step
+- hardcoded-credential: a URL, header, query or body holds a literal password, token, API key
+  or secret (matched by the log masking patterns) instead of a template reference
+- unchecked-write: a POST or PATCH up request without result_code (or ndjson.assert), so any
+  status, 4xx and 5xx included, counts as applied
+- duplicate-url: an up request repeats the method and URL (and for POST the body) of an
+  earlier migration
+
+Files that fail validation are not linted and fail the command. With --max-warnings N the
+command also fails when there are more than N warnings, for use as a CI gate.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := viper.GetViper()
+		dir, versionPattern, _ := migrationDirFromConfig(v.GetString("config"))
+		results, err := validateMigrationFiles(dir, versionPattern)
+		if err != nil {
+			return fmt.Errorf("lint failed: %w", err)
+		}
+		findings := lintMigrations(results)
+		if err := writeLintReport(cmd.OutOrStdout(), dir, results, findings); err != nil {
+			return err
+		}
+		if results.HasErrors() {
+			return fmt.Errorf("validation failed with %d error(s); run validate for details", results.ErrorCount())
+		}
+		if maxWarnings := v.GetInt("lint_max_warnings"); maxWarnings >= 0 && len(findings) > maxWarnings {
+			return fmt.Errorf("lint found %d warning(s), more than --max-warnings %d", len(findings), maxWarnings)
+		}
+		return nil
+	},
+}
+
+// lintMigrations runs the lint rules on the valid files of results, in version order. The
+// findings of a file are ordered by rule; a file the runtime cannot load gets an error instead.
+func lintMigrations(results *ValidationResults) []LintFinding {
+	var findings []LintFinding
+	seen := map[string]string{} // request signature -> file of its first up request
+	for i := range results.Results {
+		r := &results.Results[i]
+		if !r.Valid {
+			continue
+		}
+		var t task.Task
+		if err := t.LoadFromFile(r.File); err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("Lint: failed to load migration: %v", err))
+			r.Valid = false
+			continue
+		}
+		add := func(rule, format string, a ...interface{}) {
+			findings = append(findings, LintFinding{File: r.File, Rule: rule, Message: fmt.Sprintf(format, a...)})
+		}
+		method := lintUpMethod(t.Up.Request)
+
+		if strings.TrimSpace(t.Down.URL) == "" && !isReadOnlyMethod(method) {
+			add(LintMissingDown, "up %s request has no down step to roll it back", method)
+		}
+		for _, f := range lintCredentialFields(&t) {
+			if name := hardcodedCredential(f); name != "" {
+				add(LintHardcodedCredential, "%s holds a hard-coded %s; reference an env value or secret instead", f.where, name)
+			}
+		}
+		if (method == http.MethodPost || method == http.MethodPatch) && len(t.Up.Response.ResultCode) == 0 &&
+			(t.Up.Response.NDJSON == nil || len(t.Up.Response.NDJSON.Assert) == 0) {
+			add(LintUncheckedWrite, "up %s request has no result_code: any response status counts as applied", method)
+		}
+		if strings.TrimSpace(t.Up.Request.URL) != "" {
+			sig := requestSignature(method, t.Up.Request)
+			if first, ok := seen[sig]; ok {
+				add(LintDuplicateURL, "up %s %s repeats the request of %s", method, t.Up.Request.URL, filepath.Base(first))
+			} else {
+				seen[sig] = r.File
+			}
+		}
+	}
+	return findings
+}
+
+// lintUpMethod returns the HTTP method an up request is sent with.
+func lintUpMethod(req task.RequestSpec) string {
+	method := strings.ToUpper(strings.TrimSpace(req.Method))
+	switch {
+	case method != "":
+		return method
+	case req.GraphQL != nil:
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// isReadOnlyMethod reports whether requests of method do not change the target.
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// lintCredentialFields lists the request fields checked for hard-coded credentials: those of the
+// up, probe, down and down.find requests.
+func lintCredentialFields(t *task.Task) []templateField {
+	fields := upFields(t, strictContext{})
+	if f := t.Down.Find; f != nil {
+		fields = append(fields, requestFields("down.find.request", f.Request, nil)...)
+	}
+	if strings.TrimSpace(t.Down.URL) != "" {
+		fields = append(fields, downFields(t)...)
+	}
+	return fields
+}
+
+// hardcodedCredential returns the name of the masking pattern matching a literal value of f,
+// or "" when there is none. f is matched as "<last key>=<value>", so that e.g. a body_json
+// field "password" is checked like the text password=<value>; templated values are references.
+func hardcodedCredential(f templateField) string {
+	key := f.where[strings.LastIndex(f.where, ".")+1:]
+	if i := strings.Index(key, "["); i >= 0 {
+		key = key[:i]
+	}
+	text := key + "=" + f.value
+	for _, p := range common.DefaultSensitivePatterns {
+		for _, m := range p.Regex.FindAllStringSubmatch(text, -1) {
+			value := m[0]
+			if len(m) > 2 {
+				value = m[2]
+			}
+			if strings.Contains(value, "{{") {
+				continue
+			}
+			// "Authorization: Bearer <x>": the bearer_token and basic_auth patterns check <x>
+			if strings.EqualFold(value, "bearer") || strings.EqualFold(value, "basic") {
+				continue
+			}
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// requestSignature identifies the effect of an up request for the duplicate-url rule: its
+// method, URL and queries, and for POST, which creates a new resource each time, its headers
+// and body too.
+func requestSignature(method string, req task.RequestSpec) string {
+	parts := []string{method, strings.TrimSpace(req.URL)}
+	for _, f := range requestFields("up.request", req, nil) {
+		if method == http.MethodPost || strings.HasPrefix(f.where, "up.request.queries.") {
+			parts = append(parts, f.where+"="+f.value)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// writeLintReport prints the findings grouped by file, the files skipped for validation errors,
+// and a count per rule.
+func writeLintReport(w io.Writer, dir string, results *ValidationResults, findings []LintFinding) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Linting migration files in: %s\n\n", dir)
+	for _, r := range results.Results {
+		if !r.Valid {
+			fmt.Fprintf(&b, "❌ %s: not linted, it fails validation\n\n", filepath.Base(r.File))
+		}
+	}
+	counts := map[string]int{}
+	files := 0
+	for i, f := range findings {
+		if i == 0 || findings[i-1].File != f.File {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "📄 %s\n", filepath.Base(f.File))
+			files++
+		}
+		fmt.Fprintf(&b, "   ⚠️  [%s] %s\n", f.Rule, f.Message)
+		counts[f.Rule]++
+	}
+	if len(findings) == 0 {
+		fmt.Fprintf(&b, "✅ No lint warnings in %d migration files\n", len(results.Results))
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	var byRule []string
+	for _, rule := range lintRules {
+		if counts[rule] > 0 {
+			byRule = append(byRule, fmt.Sprintf("%s: %d", rule, counts[rule]))
+		}
+	}
+	fmt.Fprintf(&b, "\n%d warning(s) in %d of %d files (%s)\n", len(findings), files, len(results.Results), strings.Join(byRule, ", "))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const lintDown = "down:\n  name: undo\n  method: DELETE\n  url: \"{{.env.api}}/items/1\"\n"
+
+// lintRulesOf returns the "file rule" pairs of the findings of files.
+func lintRulesOf(t *testing.T, files map[string]string) []string {
+	t.Helper()
+	results := writeStrictMigrations(t, files)
+	if results.HasErrors() {
+		t.Fatalf("unexpected validation errors: %+v", results.Results)
+	}
+	var got []string
+	for _, f := range lintMigrations(results) {
+		got = append(got, filepath.Base(f.File)+" "+f.Rule)
+	}
+	return got
+}
+
+func TestLint_MissingDown(t *testing.T) {
+	got := lintRulesOf(t, map[string]string{
+		"001_create.yaml": "up:\n  name: create\n  request: { method: PUT, url: \"{{.env.api}}/items/1\" }\n  response: { result_code: [\"200\"] }\n",
+		"002_read.yaml":   "up:\n  name: read\n  request: { method: GET, url: \"{{.env.api}}/items\" }\n",
+		"003_undo.yaml":   "up:\n  name: create\n  request: { method: PUT, url: \"{{.env.api}}/items/3\" }\n  response: { result_code: [\"200\"] }\n" + lintDown,
+	})
+	if strings.Join(got, ",") != "001_create.yaml missing-down" {
+		t.Fatalf("unexpected findings: %v", got)
+	}
+}
+
+func TestLint_HardcodedCredential(t *testing.T) {
+	literal := "up:\n  name: login\n  request:\n    method: PUT\n    url: \"{{.env.api}}/session\"\n" +
+		"    headers: [{ name: Authorization, value: \"Bearer abc123\" }]\n" +
+		"    body_json: { user: admin, password: hunter2 }\n" +
+		"  response: { result_code: [\"200\"] }\n" +
+		"down:\n  name: logout\n  method: DELETE\n  url: \"{{.env.api}}/session?api_key=k-42\"\n"
+	templated := "up:\n  name: login\n  request:\n    method: PUT\n    url: \"{{.env.api}}/login\"\n" +
+		"    headers: [{ name: Authorization, value: \"Bearer {{.auth.main}}\" }]\n" +
+		"    body: '{\"user\": \"admin\", \"password\": \"{{.env.password}}\", \"token_type\": \"bearer\"}'\n" +
+		"  response: { result_code: [\"200\"] }\n" +
+		"down:\n  name: logout\n  method: DELETE\n  url: \"{{.env.api}}/session?api_key={{.env.key}}\"\n"
+	results := writeStrictMigrations(t, map[string]string{"001_literal.yaml": literal, "002_templated.yaml": templated})
+	var messages []string
+	for _, f := range lintMigrations(results) {
+		if f.Rule != LintHardcodedCredential {
+			t.Fatalf("unexpected finding: %+v", f)
+		}
+		if filepath.Base(f.File) != "001_literal.yaml" {
+			t.Fatalf("templated credentials reported: %+v", f)
+		}
+		messages = append(messages, f.Message)
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{
+		"up.request.headers.Authorization holds a hard-coded bearer_token",
+		"up.request.body_json.password holds a hard-coded password",
+		"down.url holds a hard-coded api_key",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q in:\n%s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "hunter2") || strings.Contains(joined, "abc123") {
+		t.Fatalf("findings must not repeat the credentials:\n%s", joined)
+	}
+}
+
+func TestLint_UncheckedWrite(t *testing.T) {
+	got := lintRulesOf(t, map[string]string{
+		"001_post.yaml":    "up:\n  name: create\n  request: { method: POST, url: \"{{.env.api}}/a\", body: \"{}\" }\n" + lintDown,
+		"002_checked.yaml": "up:\n  name: create\n  request: { method: POST, url: \"{{.env.api}}/b\", body: \"{}\" }\n  response: { result_code: [\"201\"] }\n" + lintDown,
+		"003_put.yaml":     "up:\n  name: update\n  request: { method: PUT, url: \"{{.env.api}}/c\" }\n" + lintDown,
+		"004_stream.yaml": "up:\n  name: export\n  request: { method: POST, url: \"{{.env.api}}/d\", body: \"{}\" }\n" +
+			"  response: { mode: ndjson, ndjson: { assert: { status: done } } }\n" + lintDown,
+	})
+	if strings.Join(got, ",") != "001_post.yaml unchecked-write" {
+		t.Fatalf("unexpected findings: %v", got)
+	}
+}
+
+func TestLint_DuplicateURL(t *testing.T) {
+	put := "up:\n  name: set\n  request: { method: PUT, url: \"{{.env.api}}/settings\", body: '{\"v\": %s}' }\n  response: { result_code: [\"200\"] }\n" + lintDown
+	post := "up:\n  name: add\n  request: { method: POST, url: \"{{.env.api}}/users\", body: '{\"name\": \"%s\"}' }\n  response: { result_code: [\"201\"] }\n" + lintDown
+	got := lintRulesOf(t, map[string]string{
+		"001_set.yaml":   strings.Replace(put, "%s", "1", 1),
+		"002_reset.yaml": strings.Replace(put, "%s", "2", 1),
+		"003_alice.yaml": strings.Replace(post, "%s", "alice", 1),
+		"004_bob.yaml":   strings.Replace(post, "%s", "bob", 1),
+		"005_alice.yaml": strings.Replace(post, "%s", "alice", 1),
+	})
+	if strings.Join(got, ",") != "002_reset.yaml duplicate-url,005_alice.yaml duplicate-url" {
+		t.Fatalf("unexpected findings: %v", got)
+	}
+}
+
+func TestLintCmd_MaxWarnings(t *testing.T) {
+	dir := t.TempDir()
+	mig := "up:\n  name: create\n  request: { method: POST, url: \"http://example.com/a\", body: \"{}\" }\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_create.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfg, []byte("migrate_dir: "+dir+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("config", cfg)
+	var out bytes.Buffer
+	LintCmd.SetOut(&out)
+	t.Cleanup(func() {
+		viper.Set("config", "")
+		LintCmd.SetOut(nil)
+		viper.Set("lint_max_warnings", -1)
+	})
+
+	viper.Set("lint_max_warnings", -1)
+	if err := LintCmd.RunE(LintCmd, nil); err != nil {
+		t.Fatalf("no limit: unexpected error: %v", err)
+	}
+	for _, want := range []string{"📄 001_create.yaml", "[missing-down]", "[unchecked-write]", "2 warning(s) in 1 of 1 files (missing-down: 1, unchecked-write: 1)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+
+	viper.Set("lint_max_warnings", 2)
+	if err := LintCmd.RunE(LintCmd, nil); err != nil {
+		t.Fatalf("at the limit: unexpected error: %v", err)
+	}
+	viper.Set("lint_max_warnings", 1)
+	if err := LintCmd.RunE(LintCmd, nil); err == nil || !strings.Contains(err.Error(), "lint found 2 warning(s), more than --max-warnings 1") {
+		t.Fatalf("expected the max-warnings gate to fail, got %v", err)
+	}
+}
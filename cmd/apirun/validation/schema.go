@@ -0,0 +1,44 @@
+package validation
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// migrationSchema is the JSON Schema of the migration file format. It is maintained by hand
+// alongside the task structs; schema_test.go checks that the two stay in sync.
+//
+//go:embed migration.schema.json
+var migrationSchema []byte
+
+var schemaOut string
+
+var SchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of migration files",
+	Long: `Print the JSON Schema describing the migration YAML format, for editor autocompletion
+(e.g. a yaml-language-server "# yaml-language-server: $schema=..." comment) and CI checks.
+It covers the keys and value types; run validate for the checks that need the whole
+migration directory or the config.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := strings.TrimSpace(schemaOut)
+		if out == "" || out == "-" {
+			_, err := cmd.OutOrStdout().Write(migrationSchema)
+			return err
+		}
+		if err := os.WriteFile(filepath.Clean(out), migrationSchema, 0o644); err != nil { // #nosec G306 -- the schema is public
+			return fmt.Errorf("failed to write schema: %w", err)
+		}
+		fmt.Printf("Wrote the migration schema to %s\n", out)
+		return nil
+	},
+}
+
+func init() {
+	SchemaCmd.Flags().StringVar(&schemaOut, "out", "", "write the schema to this file instead of stdout")
+}
@@ -0,0 +1,253 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/task"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+func loadMigrationSchema(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var s map[string]interface{}
+	if err := json.Unmarshal(migrationSchema, &s); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	return s
+}
+
+// compileMigrationSchema compiles the schema, which checks it against the draft-07 meta-schema.
+func compileMigrationSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(migrationSchema))
+	if err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	const url = "https://github.com/loykin/apirun/migration.schema.json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		t.Fatalf("add schema: %v", err)
+	}
+	sch, err := c.Compile(url)
+	if err != nil {
+		t.Fatalf("schema does not compile: %v", err)
+	}
+	return sch
+}
+
+// validateYAML validates a YAML document against sch; the document goes through JSON so that
+// its values have the types the validator expects.
+func validateYAML(t *testing.T, sch *jsonschema.Schema, data []byte) error {
+	t.Helper()
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("yaml to json: %v", err)
+	}
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	return sch.Validate(inst)
+}
+
+// resolve follows a local $ref ("#/definitions/name").
+func resolve(root, s map[string]interface{}) (map[string]interface{}, error) {
+	ref, ok := s["$ref"].(string)
+	if !ok {
+		return s, nil
+	}
+	name, ok := strings.CutPrefix(ref, "#/definitions/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	def, ok := root["definitions"].(map[string]interface{})[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("$ref %q does not resolve", ref)
+	}
+	return def, nil
+}
+
+func asList(v interface{}) []interface{} {
+	if l, ok := v.([]interface{}); ok {
+		return l
+	}
+	if v == nil {
+		return nil
+	}
+	return []interface{}{v}
+}
+
+func TestMigrationSchema_IsValidSchema(t *testing.T) {
+	s := loadMigrationSchema(t)
+	if s["$schema"] != "http://json-schema.org/draft-07/schema#" {
+		t.Fatalf("unexpected $schema %v", s["$schema"])
+	}
+	compileMigrationSchema(t)
+}
+
+// The schema must cover every key the task structs decode, or editors would flag valid files.
+func TestMigrationSchema_CoversTaskFields(t *testing.T) {
+	root := loadMigrationSchema(t)
+	var walk func(tp reflect.Type, s map[string]interface{}, where string)
+	walk = func(tp reflect.Type, s map[string]interface{}, where string) {
+		s, err := resolve(root, s)
+		if err != nil {
+			t.Fatalf("%s: %v", where, err)
+		}
+		for tp.Kind() == reflect.Pointer || tp.Kind() == reflect.Slice || tp.Kind() == reflect.Map {
+			if tp.Kind() == reflect.Slice {
+				items, _ := s["items"].(map[string]interface{})
+				if s, err = resolve(root, items); err != nil || s == nil {
+					t.Fatalf("%s: no items schema", where)
+				}
+			} else if tp.Kind() == reflect.Map {
+				// env_from and header_from values are either a string or an object
+				values, _ := s["additionalProperties"].(map[string]interface{})
+				for _, alt := range asList(values["anyOf"]) {
+					if m := alt.(map[string]interface{}); m["properties"] != nil {
+						s = m
+					}
+				}
+			}
+			tp = tp.Elem()
+		}
+		if tp.Kind() != reflect.Struct || tp.PkgPath() != reflect.TypeOf(task.Task{}).PkgPath() && tp.Name() != "RetryPolicy" {
+			return
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		for i := 0; i < tp.NumField(); i++ {
+			f := tp.Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			sub, ok := props[name].(map[string]interface{})
+			if !ok {
+				t.Errorf("%s.%s (%s.%s) is missing from the schema", where, name, tp.Name(), f.Name)
+				continue
+			}
+			walk(f.Type, sub, where+"."+name)
+		}
+	}
+	walk(reflect.TypeOf(task.Task{}), root, "#")
+}
+
+func TestMigrationSchema_ValidatesMigrations(t *testing.T) {
+	sch := compileMigrationSchema(t)
+	sample := `depends_on: []
+tags: [seed]
+up:
+  name: create user
+  env: { role: admin, retries: 3 }
+  request:
+    auth_name: main
+    method: POST
+    url: "{{.env.api}}/users"
+    headers:
+      - { name: Content-Type, value: application/json }
+      - { name: Idempotency-Key, value: "{{.run_id}}", methods: [POST] }
+    queries:
+      - { name: dry, value: false }
+    body_json: { name: alice, roles: ["{{.env.role}}"] }
+    retry: { max_attempts: 3, initial_backoff: 500ms }
+  response:
+    result_code: ["201", 200, "2xx"]
+    env_from: { user_id: id, count: { path: total, default: 0, type: int } }
+    header_from:
+      location: Location
+      etag: { header: ETag, regex: 'W/"(.*)"' }
+    env_missing: fail
+    store_body: true
+    on_match: { path: status, equals: done, action: "skip_to:5" }
+    mode: ndjson
+    ndjson: { match: { type: result }, assert: { ok: true } }
+down:
+  name: delete user
+  method: DELETE
+  url: "{{.env.api}}/users/{{.env.user_id}}"
+  ignore_codes: [404]
+  find:
+    request: { method: GET, url: "{{.env.api}}/users" }
+    response: { result_code: ["200"], env_from: { user_id: "items.#(name==alice).id" } }
+    paginate: { next: next_cursor, cursor_param: cursor }
+`
+	if err := validateYAML(t, sch, []byte(sample)); err != nil {
+		t.Fatalf("sample does not validate: %v", err)
+	}
+	// The sample is a migration the runtime accepts too
+	var tk task.Task
+	if err := tk.LoadFromFile(writeTemp(t, sample)); err != nil {
+		t.Fatalf("runtime rejects the sample: %v", err)
+	}
+
+	invalid := map[string]string{
+		"unknown key":        "up:\n  requst: {}\n",
+		"bad method":         "up:\n  request: { method: FETCH }\n",
+		"bad env_missing":    "up:\n  response: { env_missing: ignore }\n",
+		"headers as map":     "up:\n  request:\n    headers: { Accept: json }\n",
+		"bad header method":  "up:\n  request:\n    headers: [{ name: A, value: b, methods: [FETCH] }]\n",
+		"bad on_match":       "up:\n  response: { on_match: { path: a, action: skip_all } }\n",
+		"ignore code range":  "down:\n  ignore_codes: [1000]\n",
+		"paginate w/o next":  "down:\n  find: { paginate: { max_pages: 2 } }\n",
+		"result_code scalar": "up:\n  response: { result_code: 200 }\n",
+		"entry without code": "up:\n  response: { result_code: [{ extract: false }] }\n",
+		"bad mode":           "up:\n  response: { mode: sse }\n",
+	}
+	mapping := "up:\n  response: { result_code: [\"2xx\", { code: 409, extract: false }, { code: \"5xx\" }] }\n"
+	if err := validateYAML(t, sch, []byte(mapping)); err != nil {
+		t.Fatalf("result_code mappings do not validate: %v", err)
+	}
+
+	for name, y := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := validateYAML(t, sch, []byte(y)); err == nil {
+				t.Fatalf("expected schema violations")
+			}
+		})
+	}
+}
+
+// The example migrations shipped with the repository must all validate.
+func TestMigrationSchema_ValidatesExamples(t *testing.T) {
+	sch := compileMigrationSchema(t)
+	files, _ := filepath.Glob("../../../examples/*/*/*.yaml")
+	checked := 0
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil || (doc["up"] == nil && doc["down"] == nil) {
+			continue // not a migration (configs, stage files)
+		}
+		checked++
+		if err := validateYAML(t, sch, data); err != nil {
+			t.Errorf("%s: %v", f, err)
+		}
+	}
+	if checked == 0 {
+		t.Fatalf("no example migrations found")
+	}
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "001_sample.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,282 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/loykin/apirun/internal/task"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// strictContext is what --strict renders the migration templates against.
+type strictContext struct {
+	// base is the env from the config file (may be nil)
+	base *env.Env
+	// authNames are the configured auth providers, referenced as {{.auth.<name>}}
+	authNames []string
+	// renderBody is the config-level render_body default (nil = render)
+	renderBody *bool
+}
+
+// templateField is one templated value of a migration and where it came from.
+type templateField struct {
+	where string
+	value string
+}
+
+// missingKeyRegex extracts the missing reference from a template execution error.
+var missingKeyRegex = regexp.MustCompile(`at <(\.[^>]*)>: map has no entry for key "([^"]*)"`)
+
+// checkTemplates simulates the env flow across the ordered migrations of results without
+// making HTTP calls, rendering every templated field against the env it would see at run time.
+// Values only known after a response (env_from, the kept up body) are placeholders. Undefined
+// references become errors of the file's result; unavailable secrets become warnings.
+// Files that already failed validation are skipped.
+func checkTemplates(results *ValidationResults, sc strictContext) {
+	tasks := make([]*task.Task, len(results.Results))
+	producers := map[string]int{} // env_from key -> index of the first migration producing it
+	for i := range results.Results {
+		r := &results.Results[i]
+		if !r.Valid {
+			continue
+		}
+		var t task.Task
+		if err := t.LoadFromFile(r.File); err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("Strict: failed to load migration: %v", err))
+			r.Valid = false
+			continue
+		}
+		tasks[i] = &t
+		for _, k := range t.Up.Response.ExtractedKeys() {
+			if _, ok := producers[k]; !ok {
+				producers[k] = i
+			}
+		}
+	}
+
+	// Keys extracted by the up steps so far; later up steps see all of them
+	produced := map[string]bool{}
+	for i, t := range tasks {
+		if t == nil {
+			continue
+		}
+		r := &results.Results[i]
+		hint := func(direction, key string) string {
+			p, ok := producers[key]
+			switch {
+			case !ok:
+				return ""
+			case p > i:
+				return fmt.Sprintf(" (only produced by later migration %s)", filepath.Base(results.Results[p].File))
+			case direction == "down" && p != i:
+				return fmt.Sprintf(" (produced by %s, but down steps only see their own version's env_from)", filepath.Base(results.Results[p].File))
+			}
+			return ""
+		}
+
+		upEnv := sc.taskEnv(t.Up.Env)
+		for k := range produced {
+			setPlaceholder(upEnv, k)
+		}
+		checkFields(r, upEnv, upFields(t, sc), func(key string) string { return hint("up", key) })
+		for _, k := range t.Up.Response.ExtractedKeys() {
+			produced[k] = true
+		}
+
+		if strings.TrimSpace(t.Down.URL) == "" && t.Down.Find == nil {
+			continue
+		}
+		downEnv := sc.taskEnv(t.Down.Env)
+		for _, k := range t.Up.Response.ExtractedKeys() {
+			setPlaceholder(downEnv, k)
+		}
+		if t.Up.Response.StoreBody {
+			setPlaceholder(downEnv, task.UpBodyEnvKey)
+		}
+		downHint := func(key string) string { return hint("down", key) }
+		if f := t.Down.Find; f != nil {
+			checkFields(r, downEnv, requestFields("down.find.request", f.Request, sc.renderBody), downHint)
+			for _, k := range f.Response.ExtractedKeys() {
+				setPlaceholder(downEnv, k)
+			}
+		}
+		checkFields(r, downEnv, downFields(t), downHint)
+	}
+}
+
+// taskEnv returns the env of a step: the config env with the step's own env as Local and
+// placeholders for the auth tokens.
+func (sc strictContext) taskEnv(local *env.Env) *env.Env {
+	e := sc.base.Clone()
+	if local != nil {
+		for k, v := range local.Local {
+			e.Local[k] = v
+		}
+	}
+	for _, name := range sc.authNames {
+		e.Auth[name] = env.Str(task.PlaceholderValue("auth:" + name))
+	}
+	return e
+}
+
+// setPlaceholder makes key resolvable in e unless the step already defines it.
+func setPlaceholder(e *env.Env, key string) {
+	if _, ok := e.Lookup(key); !ok {
+		e.Local[key] = env.Str(task.PlaceholderValue(key))
+	}
+}
+
+// upFields lists the templated fields of the up step.
+func upFields(t *task.Task, sc strictContext) []templateField {
+	fields := requestFields("up.request", t.Up.Request, sc.renderBody)
+	for _, rc := range t.Up.Response.ResultCode {
+		fields = append(fields, templateField{"up.response.result_code", rc})
+	}
+	if p := t.Up.Probe; p != nil {
+		fields = append(fields, requestFields("up.probe.request", p.Request, sc.renderBody)...)
+		fields = append(fields, stringMapFields("up.probe.assert", p.Assert)...)
+	}
+	return fields
+}
+
+// downFields lists the templated fields of the main down request.
+func downFields(t *task.Task) []templateField {
+	fields := []templateField{{"down.url", t.Down.URL}}
+	for _, h := range t.Down.Headers {
+		fields = append(fields, templateField{"down.headers." + h.Name, h.Value})
+	}
+	for _, q := range t.Down.Queries {
+		fields = append(fields, templateField{"down.queries." + q.Name, q.Value})
+	}
+	return append(fields, templateField{"down.body", t.Down.Body})
+}
+
+// requestFields lists the templated fields of a request. The body is skipped when body
+// rendering is disabled; the contents of body_file are not checked, only its path.
+func requestFields(prefix string, req task.RequestSpec, renderBodyDefault *bool) []templateField {
+	fields := []templateField{{prefix + ".url", req.URL}}
+	for _, h := range req.Headers {
+		fields = append(fields, templateField{prefix + ".headers." + h.Name, h.Value})
+	}
+	for _, q := range req.Queries {
+		fields = append(fields, templateField{prefix + ".queries." + q.Name, q.Value})
+	}
+	render := true
+	if req.RenderBody != nil {
+		render = *req.RenderBody
+	} else if renderBodyDefault != nil {
+		render = *renderBodyDefault
+	}
+	switch {
+	case req.BodyJSON != nil:
+		fields = append(fields, nestedFields(prefix+".body_json", req.BodyJSON)...)
+	case req.GraphQL != nil:
+		fields = append(fields, nestedFields(prefix+".graphql.variables", req.GraphQL.Variables)...)
+	case req.Form != nil:
+		fields = append(fields, stringMapFields(prefix+".form", req.Form)...)
+	case req.Multipart != nil:
+		fields = append(fields, stringMapFields(prefix+".multipart.fields", req.Multipart.Fields)...)
+		for i, f := range req.Multipart.Files {
+			fields = append(fields, templateField{fmt.Sprintf("%s.multipart.files[%d].path", prefix, i), f.Path})
+		}
+	case strings.TrimSpace(req.BodyFile) != "":
+		fields = append(fields, templateField{prefix + ".body_file", req.BodyFile})
+	case render:
+		fields = append(fields, templateField{prefix + ".body", req.Body})
+	}
+	return fields
+}
+
+// stringMapFields lists the values of m in key order.
+func stringMapFields(where string, m map[string]string) []templateField {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]templateField, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, templateField{where + "." + k, m[k]})
+	}
+	return fields
+}
+
+// nestedFields lists the string values inside a decoded YAML value, in a stable order.
+func nestedFields(where string, v interface{}) []templateField {
+	switch val := v.(type) {
+	case string:
+		return []templateField{{where, val}}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var out []templateField
+		for _, k := range keys {
+			out = append(out, nestedFields(where+"."+k, val[k])...)
+		}
+		return out
+	case []interface{}:
+		var out []templateField
+		for i, item := range val {
+			out = append(out, nestedFields(fmt.Sprintf("%s[%d]", where, i), item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// checkFields renders each field against e and records every undefined reference (rendering
+// is repeated with the missing value stubbed so that all of them are reported).
+func checkFields(r *ValidationResult, e *env.Env, fields []templateField, hint func(key string) string) {
+	for _, f := range fields {
+		if !strings.Contains(f.value, "{{") {
+			continue
+		}
+		fe := e.Clone()
+	render:
+		for {
+			_, err := fe.RenderGoTemplateErr(f.value)
+			if err == nil {
+				break
+			}
+			if errors.Is(err, env.ErrSecretResolution) {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("Strict: %s could not be fully rendered: %v", f.where, err))
+				break
+			}
+			m := missingKeyRegex.FindStringSubmatch(err.Error())
+			if m == nil {
+				r.Errors = append(r.Errors, fmt.Sprintf("Strict: %s template error: %v", f.where, err))
+				break
+			}
+			ref, key := m[1], m[2]
+			switch {
+			case strings.HasPrefix(ref, ".env."):
+				r.Errors = append(r.Errors, fmt.Sprintf("Strict: %s references undefined variable %q%s", f.where, ref, hint(key)))
+				fe.Local[key] = env.Str(task.PlaceholderValue(key))
+			case strings.HasPrefix(ref, ".auth."):
+				r.Errors = append(r.Errors, fmt.Sprintf("Strict: %s references unknown auth %q", f.where, key))
+				fe.Auth[key] = env.Str(task.PlaceholderValue("auth:" + key))
+			case strings.HasPrefix(ref, ".ctx."):
+				// Context values only exist when embedding with Migrator.ContextKeys
+				r.Warnings = append(r.Warnings, fmt.Sprintf("Strict: %s references context value %q, which is only set by library callers (Migrator.ContextKeys)", f.where, ref))
+				if fe.Ctx == nil {
+					fe.Ctx = env.Map{}
+				}
+				fe.Ctx[key] = env.Str(task.PlaceholderValue("ctx:" + key))
+			default:
+				// Only the .env, .auth and .ctx namespaces exist, so this can never resolve
+				r.Errors = append(r.Errors, fmt.Sprintf("Strict: %s references %q, use .env.%s or .auth.%s", f.where, ref, key, key))
+				break render
+			}
+		}
+	}
+	if len(r.Errors) > 0 {
+		r.Valid = false
+	}
+}
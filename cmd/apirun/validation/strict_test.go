@@ -0,0 +1,177 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func writeStrictMigrations(t *testing.T, files map[string]string) *ValidationResults {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	results, err := validateMigrationFiles(dir, "")
+	if err != nil {
+		t.Fatalf("validateMigrationFiles: %v", err)
+	}
+	return results
+}
+
+func strictErrors(results *ValidationResults, file string) string {
+	for _, r := range results.Results {
+		if filepath.Base(r.File) == file {
+			return strings.Join(r.Errors, "\n")
+		}
+	}
+	return ""
+}
+
+func TestCheckTemplates_EnvFlow(t *testing.T) {
+	results := writeStrictMigrations(t, map[string]string{
+		"001_user.yaml": `up:
+  name: create user
+  request:
+    method: POST
+    url: "{{.env.api_base}}/users?token={{.auth.admin}}"
+  response:
+    result_code: ["201"]
+    env_from:
+      user_id: id
+down:
+  name: delete user
+  method: DELETE
+  url: "{{.env.api_base}}/users/{{.env.user_id}}"
+`,
+		"002_group.yaml": `up:
+  name: add member
+  request:
+    method: POST
+    url: "{{.env.api_base}}/groups/{{.env.group_id}}/members"
+    body: '{"user":"{{.env.user_id}}","note":"{{.env.note}}"}'
+  response:
+    result_code: ["201"]
+down:
+  name: remove member
+  method: DELETE
+  url: "{{.env.api_base}}/members/{{.env.user_id}}"
+`,
+		"003_tag.yaml": `up:
+  name: create group
+  request:
+    method: POST
+    url: "{{.env.api_base}}/groups?token={{.auth.nope}}"
+  response:
+    result_code: ["201"]
+    env_from:
+      group_id: id
+down:
+  name: delete tag
+  method: DELETE
+  url: "{{.env.api_base}}/groups/{{.env.group_id}}/tags/{{.env.tag_id}}"
+  find:
+    request:
+      method: GET
+      url: "{{.env.api_base}}/groups/{{.env.group_id}}/tags"
+    response:
+      env_from:
+        tag_id: "0.id"
+`,
+	})
+	base := env.New()
+	_ = base.SetString("global", "api_base", "http://localhost")
+	checkTemplates(results, strictContext{base: base, authNames: []string{"admin"}})
+
+	if errs := strictErrors(results, "001_user.yaml"); errs != "" {
+		t.Errorf("Expected 001 to pass strict checks, got: %s", errs)
+	}
+
+	errs := strictErrors(results, "002_group.yaml")
+	for _, want := range []string{
+		`up.request.url references undefined variable ".env.group_id" (only produced by later migration 003_tag.yaml)`,
+		`up.request.body references undefined variable ".env.note"`,
+		`down.url references undefined variable ".env.user_id" (produced by 001_user.yaml, but down steps only see their own version's env_from)`,
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("Expected error %q, got: %s", want, errs)
+		}
+	}
+	if strings.Contains(errs, "up.request.body references undefined variable \".env.user_id\"") {
+		t.Errorf("user_id from an earlier migration should be available to later up steps: %s", errs)
+	}
+
+	errs = strictErrors(results, "003_tag.yaml")
+	if !strings.Contains(errs, `up.request.url references unknown auth "nope"`) {
+		t.Errorf("Expected unknown auth error, got: %s", errs)
+	}
+	if strings.Contains(errs, "down.") {
+		t.Errorf("Down step should see its own env_from and the find env_from, got: %s", errs)
+	}
+
+	summarizeResults(results)
+	if !results.HasErrors() || !strings.Contains(results.Summary, "4 errors") {
+		t.Errorf("Expected the summary to count the strict errors, got: %s", results.Summary)
+	}
+}
+
+func TestCheckTemplates_FlatReference(t *testing.T) {
+	results := writeStrictMigrations(t, map[string]string{
+		"001_test.yaml": `up:
+  name: create user
+  env:
+    user: alice
+  request:
+    method: POST
+    url: "http://localhost/users/{{.user}}"
+`,
+	})
+	checkTemplates(results, strictContext{})
+
+	errs := strictErrors(results, "001_test.yaml")
+	if !strings.Contains(errs, `up.request.url references ".user", use .env.user or .auth.user`) {
+		t.Errorf("Expected flat reference error, got: %s", errs)
+	}
+}
+
+func TestCheckTemplates_RenderBodyDisabled(t *testing.T) {
+	results := writeStrictMigrations(t, map[string]string{
+		"001_test.yaml": `up:
+  name: raw body
+  request:
+    method: POST
+    url: "http://localhost/templates"
+    body: '{"template":"{{.env.not_rendered}}"}'
+`,
+	})
+	off := false
+	checkTemplates(results, strictContext{renderBody: &off})
+
+	if errs := strictErrors(results, "001_test.yaml"); errs != "" {
+		t.Errorf("Expected unrendered body to be skipped, got: %s", errs)
+	}
+}
+
+func TestCheckTemplates_ContextReference(t *testing.T) {
+	results := writeStrictMigrations(t, map[string]string{
+		"001_test.yaml": `up:
+  name: create item
+  request:
+    method: POST
+    url: "http://localhost/tenants/{{.ctx.tenant_id}}/items"
+`,
+	})
+	checkTemplates(results, strictContext{})
+
+	if errs := strictErrors(results, "001_test.yaml"); errs != "" {
+		t.Errorf("Expected no errors, got: %s", errs)
+	}
+	if warns := strings.Join(results.Results[0].Warnings, "\n"); !strings.Contains(warns, `context value ".ctx.tenant_id"`) {
+		t.Errorf("Expected a context value warning, got: %s", warns)
+	}
+}
@@ -3,6 +3,8 @@ package validation
 import (
 	"fmt"
 	"strings"
+
+	"github.com/loykin/apirun/internal/task"
 )
 
 // validateMigrationStructure validates the overall structure of a migration file
@@ -35,10 +37,24 @@ func validateMigrationStructure(migration map[string]interface{}, result *Valida
 		result.Warnings = append(result.Warnings, "No 'down' section found - consider adding for rollback capability")
 	}
 
+	if tags, hasTags := migration["tags"]; hasTags {
+		list, ok := tags.([]interface{})
+		if !ok {
+			result.Errors = append(result.Errors, "'tags' must be a list of strings")
+		} else {
+			for i, tag := range list {
+				if s, ok := tag.(string); !ok || strings.TrimSpace(s) == "" {
+					result.Errors = append(result.Errors, fmt.Sprintf("tags[%d] must be a non-empty string", i))
+				}
+			}
+		}
+	}
+
 	// Check for unexpected root level keys
 	allowedKeys := map[string]bool{
 		"up":   true,
 		"down": true,
+		"tags": true,
 	}
 
 	for key := range migration {
@@ -96,6 +112,16 @@ func validateUpSection(up map[string]interface{}, result *ValidationResult) {
 		result.Warnings = append(result.Warnings, "No 'response' validation found in 'up' section - consider adding for better error handling")
 	}
 
+	// Validate probe section (optional, read-only request used by plan-diff)
+	if probe, exists := up["probe"]; exists {
+		probeMap, ok := probe.(map[string]interface{})
+		if !ok {
+			result.Errors = append(result.Errors, "'up.probe' section must be a map/object")
+		} else {
+			validateProbeSection(probeMap, result)
+		}
+	}
+
 	// Validate find section (optional)
 	if find, exists := up["find"]; exists {
 		findMap, ok := find.(map[string]interface{})
@@ -107,6 +133,35 @@ func validateUpSection(up map[string]interface{}, result *ValidationResult) {
 	}
 }
 
+// validateProbeSection validates the optional 'up.probe' section; the probe must be read-only
+func validateProbeSection(probe map[string]interface{}, result *ValidationResult) {
+	request, ok := probe["request"].(map[string]interface{})
+	if !ok {
+		result.Errors = append(result.Errors, "'up.probe' requires a 'request' map/object")
+		return
+	}
+	if url, ok := request["url"].(string); !ok || strings.TrimSpace(url) == "" {
+		result.Errors = append(result.Errors, "'up.probe.request.url' must be a non-empty string")
+	}
+	if method, exists := request["method"]; exists {
+		if methodStr, ok := method.(string); !ok || (!strings.EqualFold(methodStr, "GET") && !strings.EqualFold(methodStr, "HEAD")) {
+			result.Errors = append(result.Errors, fmt.Sprintf("'up.probe.request.method' must be GET or HEAD, got '%v'", method))
+		}
+	}
+	if response, exists := probe["response"]; exists {
+		if responseMap, ok := response.(map[string]interface{}); ok {
+			validateResponseSection(responseMap, result, "up.probe")
+		} else {
+			result.Errors = append(result.Errors, "'up.probe.response' must be a map/object")
+		}
+	}
+	if assert, exists := probe["assert"]; exists {
+		if _, ok := assert.(map[string]interface{}); !ok {
+			result.Errors = append(result.Errors, "'up.probe.assert' must be a map of path to expected value")
+		}
+	}
+}
+
 // validateDownSection validates the 'down' section of a migration
 func validateDownSection(down map[string]interface{}, result *ValidationResult) {
 	// Down section has similar structure to up but is optional
@@ -154,6 +209,26 @@ func validateDownSection(down map[string]interface{}, result *ValidationResult)
 		}
 	}
 
+	// Validate ignore_codes (optional)
+	if codes, exists := down["ignore_codes"]; exists {
+		list, ok := codes.([]interface{})
+		if !ok {
+			result.Errors = append(result.Errors, "'ignore_codes' field in 'down' section must be a list of status codes")
+		}
+		for i, code := range list {
+			if c, ok := code.(int); !ok || c < 100 || c > 599 {
+				result.Errors = append(result.Errors, fmt.Sprintf("'down.ignore_codes[%d]' must be an HTTP status code", i))
+			}
+		}
+	}
+
+	// Validate from_up (optional)
+	if fromUp, exists := down["from_up"]; exists {
+		if _, ok := fromUp.(bool); !ok {
+			result.Errors = append(result.Errors, "'from_up' field in 'down' section must be true or false")
+		}
+	}
+
 	// Validate find section (optional)
 	if find, exists := down["find"]; exists {
 		findMap, ok := find.(map[string]interface{})
@@ -167,8 +242,12 @@ func validateDownSection(down map[string]interface{}, result *ValidationResult)
 
 // validateRequestSection validates HTTP request configuration
 func validateRequestSection(request map[string]interface{}, result *ValidationResult, prefix string) {
-	// Check for required fields
+	// Check for required fields (GraphQL requests default to POST)
 	requiredFields := []string{"method", "url"}
+	if graphql, exists := request["graphql"]; exists {
+		requiredFields = []string{"url"}
+		validateGraphQLSection(graphql, result, prefix)
+	}
 	for _, field := range requiredFields {
 		if _, exists := request[field]; !exists {
 			result.Errors = append(result.Errors, fmt.Sprintf("Missing required field in '%s.request': '%s'", prefix, field))
@@ -201,8 +280,18 @@ func validateRequestSection(request map[string]interface{}, result *ValidationRe
 
 	// Validate headers (optional)
 	if headers, exists := request["headers"]; exists {
-		if _, ok := headers.(map[string]interface{}); !ok {
-			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.headers' must be a map/object", prefix))
+		switch hs := headers.(type) {
+		case map[string]interface{}:
+		case []interface{}:
+			// The runtime format: a list of {name, value} entries
+			for i, h := range hs {
+				entry, ok := h.(map[string]interface{})
+				if name, _ := entry["name"].(string); !ok || strings.TrimSpace(name) == "" {
+					result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.headers[%d]' must be a {name, value} entry", prefix, i))
+				}
+			}
+		default:
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.headers' must be a list of {name, value} entries or a map/object", prefix))
 		}
 	}
 
@@ -216,6 +305,102 @@ func validateRequestSection(request map[string]interface{}, result *ValidationRe
 			result.Warnings = append(result.Warnings, fmt.Sprintf("'%s.request.body' should be a string or object", prefix))
 		}
 	}
+
+	// Validate body_json (optional, structured body sent as JSON)
+	if bodyJSON, exists := request["body_json"]; exists {
+		switch bodyJSON.(type) {
+		case map[string]interface{}, []interface{}:
+			// Valid types
+		default:
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.body_json' must be a map/object or list", prefix))
+		}
+		for _, other := range []string{"body", "body_file", "graphql"} {
+			if _, exists := request[other]; exists {
+				result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.body_json' cannot be combined with '%s'", prefix, other))
+			}
+		}
+	}
+
+	// Validate form (optional, urlencoded body)
+	if form, exists := request["form"]; exists {
+		if _, ok := form.(map[string]interface{}); !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.form' must be a map/object", prefix))
+		}
+		for _, other := range []string{"body", "body_file", "body_json", "graphql", "multipart"} {
+			if _, exists := request[other]; exists {
+				result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.form' cannot be combined with '%s'", prefix, other))
+			}
+		}
+	}
+
+	// Validate multipart (optional, fields and file parts)
+	if mp, exists := request["multipart"]; exists {
+		validateMultipartSection(mp, result, prefix)
+		for _, other := range []string{"body", "body_file", "body_json", "graphql"} {
+			if _, exists := request[other]; exists {
+				result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.multipart' cannot be combined with '%s'", prefix, other))
+			}
+		}
+	}
+
+	// Validate content_type (optional)
+	if contentType, exists := request["content_type"]; exists {
+		if ct, ok := contentType.(string); !ok || strings.TrimSpace(ct) == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.content_type' must be a non-empty string", prefix))
+		}
+	}
+}
+
+// validateMultipartSection validates the fields and file parts of a multipart body
+func validateMultipartSection(multipart interface{}, result *ValidationResult, prefix string) {
+	mp, ok := multipart.(map[string]interface{})
+	if !ok {
+		result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.multipart' must be a map/object", prefix))
+		return
+	}
+	if fields, exists := mp["fields"]; exists {
+		if _, ok := fields.(map[string]interface{}); !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.multipart.fields' must be a map/object", prefix))
+		}
+	}
+	files, exists := mp["files"]
+	if !exists {
+		return
+	}
+	list, ok := files.([]interface{})
+	if !ok {
+		result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.multipart.files' must be a list", prefix))
+		return
+	}
+	for i, f := range list {
+		file, ok := f.(map[string]interface{})
+		if !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.multipart.files[%d]' must be a map/object", prefix, i))
+			continue
+		}
+		for _, key := range []string{"field", "path"} {
+			if v, ok := file[key].(string); !ok || strings.TrimSpace(v) == "" {
+				result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.multipart.files[%d].%s' must be a non-empty string", prefix, i, key))
+			}
+		}
+	}
+}
+
+// validateGraphQLSection validates the query/variables of a GraphQL request
+func validateGraphQLSection(graphql interface{}, result *ValidationResult, prefix string) {
+	gq, ok := graphql.(map[string]interface{})
+	if !ok {
+		result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.graphql' must be a map/object", prefix))
+		return
+	}
+	if query, ok := gq["query"].(string); !ok || strings.TrimSpace(query) == "" {
+		result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.graphql.query' must be a non-empty string", prefix))
+	}
+	if vars, exists := gq["variables"]; exists {
+		if _, ok := vars.(map[string]interface{}); !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.request.graphql.variables' must be a map/object", prefix))
+		}
+	}
 }
 
 // validateResponseSection validates HTTP response validation configuration
@@ -226,20 +411,72 @@ func validateResponseSection(response map[string]interface{}, result *Validation
 		case []interface{}:
 			// Array of status codes
 			for i, code := range rc {
-				switch code.(type) {
-				case string, int:
-					// Valid types
+				switch c := code.(type) {
+				case string:
+					if err := task.ValidateResultCode(c); err != nil {
+						result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code[%d]' %v", prefix, i, err))
+					}
+				case int:
+					// Valid type
+				case map[string]interface{}:
+					// {code, extract} entry
+					switch cc := c["code"].(type) {
+					case string:
+						if err := task.ValidateResultCode(cc); err != nil {
+							result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code[%d].code' %v", prefix, i, err))
+						}
+					case int:
+					default:
+						result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code[%d].code' must be a string or integer", prefix, i))
+					}
+					if extract, ok := c["extract"]; ok {
+						if _, isBool := extract.(bool); !isBool {
+							result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code[%d].extract' must be a boolean", prefix, i))
+						}
+					}
 				default:
-					result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code[%d]' must be a string or integer", prefix, i))
+					result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code[%d]' must be a string, integer or {code, extract} mapping", prefix, i))
 				}
 			}
-		case string, int:
+		case string:
+			if err := task.ValidateResultCode(rc); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code' %v", prefix, err))
+			}
+		case int:
 			// Single status code
 		default:
 			result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.result_code' must be a string, integer, or array", prefix))
 		}
 	}
 
+	if onMatch, exists := response["on_match"]; exists {
+		om, ok := onMatch.(map[string]interface{})
+		switch {
+		case prefix != "up":
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.on_match' is only supported in up.response", prefix))
+		case !ok:
+			result.Errors = append(result.Errors, "'up.response.on_match' must be a map/object")
+		default:
+			path, _ := om["path"].(string)
+			action, _ := om["action"].(string)
+			spec := task.OnMatchSpec{Path: path, Action: action}
+			if err := spec.Validate(); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("'up.response' %v", err))
+			}
+		}
+	}
+
+	if mode, exists := response["mode"]; exists {
+		if m, ok := mode.(string); !ok || !strings.EqualFold(strings.TrimSpace(m), task.ResponseModeNDJSON) {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.mode' must be %q", prefix, task.ResponseModeNDJSON))
+		}
+	}
+	if _, exists := response["ndjson"]; exists {
+		if m, _ := response["mode"].(string); !strings.EqualFold(strings.TrimSpace(m), task.ResponseModeNDJSON) {
+			result.Errors = append(result.Errors, fmt.Sprintf("'%s.response.ndjson' requires mode: %s", prefix, task.ResponseModeNDJSON))
+		}
+	}
+
 	// Validate other response validation fields
 	optionalFields := []string{"body_contains", "header_contains", "json_path"}
 	for _, field := range optionalFields {
@@ -265,4 +502,20 @@ func validateFindSection(find map[string]interface{}, result *ValidationResult)
 	if !hasMethod {
 		result.Warnings = append(result.Warnings, "No extraction method specified in 'find' section (json_path, regex, xpath, header)")
 	}
+
+	if paginate, exists := find["paginate"]; exists {
+		pm, ok := paginate.(map[string]interface{})
+		if !ok {
+			result.Errors = append(result.Errors, "'find.paginate' must be a map/object")
+			return
+		}
+		if next, _ := pm["next"].(string); strings.TrimSpace(next) == "" {
+			result.Errors = append(result.Errors, "'find.paginate' requires a 'next' expression")
+		}
+		if mp, exists := pm["max_pages"]; exists {
+			if n, ok := mp.(int); !ok || n < 0 {
+				result.Errors = append(result.Errors, "'find.paginate.max_pages' must be a non-negative integer")
+			}
+		}
+	}
 }
@@ -10,6 +10,8 @@ import (
 	"github.com/spf13/viper"
 )
 
+var validateStrict bool
+
 var ValidateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate migration files for syntax and structure",
@@ -17,43 +19,31 @@ var ValidateCmd = &cobra.Command{
 required fields, and structural correctness. This command checks:
 - YAML syntax validity
 - Required fields (up section)
-- Migration file naming convention
+- Migration file naming convention (version_pattern, default 001_name.yaml)
 - Duplicate version numbers
-- Request structure completeness`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		v := viper.GetViper()
-		configPath := v.GetString("config")
-
-		dir := ""
-		if strings.TrimSpace(configPath) != "" {
-			var doc config.ConfigDoc
-			if err := doc.Load(configPath); err != nil {
-				fmt.Printf("Warning: failed to load config file '%s': %v\n", configPath, err)
-				fmt.Println("Using default migration directory...")
-			} else {
-				mDir := strings.TrimSpace(doc.MigrateDir)
-				if mDir != "" {
-					dir = mDir
-				}
-			}
-		}
+- Request structure completeness
 
-		if strings.TrimSpace(dir) == "" {
-			dir = "./config/migration"
-		}
-
-		// Normalize to absolute path
-		if abs, err := filepath.Abs(dir); err == nil {
-			dir = abs
+With --strict it also renders every templated field against the config env, following
+the env_from values earlier migrations produce (without making HTTP calls), and reports
+references that would not resolve at run time.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, versionPattern, doc := migrationDirFromConfig(viper.GetViper().GetString("config"))
+		var sc strictContext
+		if doc != nil {
+			sc = strictContextFromConfig(doc)
 		}
 
 		fmt.Printf("Validating migration files in: %s\n\n", dir)
 
 		// Perform validation using the new modular approach
-		results, err := validateMigrationFiles(dir)
+		results, err := validateMigrationFiles(dir, versionPattern)
 		if err != nil {
 			return fmt.Errorf("validation failed: %w", err)
 		}
+		if validateStrict && len(results.Results) > 0 {
+			checkTemplates(results, sc)
+			summarizeResults(results)
+		}
 
 		// Print results
 		printValidationResults(results)
@@ -66,3 +56,52 @@ required fields, and structural correctness. This command checks:
 		return nil
 	},
 }
+
+// migrationDirFromConfig returns the absolute migration directory and version pattern of the
+// config file at configPath, with the loaded config; without a (loadable) config, the default
+// ./config/migration and a nil config.
+func migrationDirFromConfig(configPath string) (string, string, *config.ConfigDoc) {
+	dir := ""
+	versionPattern := ""
+	var loaded *config.ConfigDoc
+	if strings.TrimSpace(configPath) != "" {
+		var doc config.ConfigDoc
+		if err := doc.Load(configPath); err != nil {
+			fmt.Printf("Warning: failed to load config file '%s': %v\n", configPath, err)
+			fmt.Println("Using default migration directory...")
+		} else {
+			mDir := strings.TrimSpace(doc.MigrateDir)
+			if mDir != "" {
+				dir = mDir
+			}
+			versionPattern = doc.VersionPattern
+			loaded = &doc
+		}
+	}
+
+	if strings.TrimSpace(dir) == "" {
+		dir = "./config/migration"
+	}
+
+	// Normalize to absolute path
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir, versionPattern, loaded
+}
+
+// strictContextFromConfig returns the env and auth names --strict renders templates against.
+func strictContextFromConfig(doc *config.ConfigDoc) strictContext {
+	base, _ := doc.GetEnv()
+	sc := strictContext{base: base, renderBody: doc.RenderBody}
+	for _, a := range doc.Auth {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			sc.authNames = append(sc.authNames, name)
+		}
+	}
+	return sc
+}
+
+func init() {
+	ValidateCmd.Flags().BoolVar(&validateStrict, "strict", false, "also render templates against the config env and report undefined variables")
+}
@@ -3,8 +3,11 @@ package validation
 import (
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+
+	imig "github.com/loykin/apirun/internal/migration"
 )
 
 func TestValidateMigrationFiles(t *testing.T) {
@@ -68,7 +71,7 @@ down:
 	}
 
 	// Run validation
-	results, err := validateMigrationFiles(tmpDir)
+	results, err := validateMigrationFiles(tmpDir, "")
 	if err != nil {
 		t.Fatalf("Validation failed: %v", err)
 	}
@@ -166,6 +169,133 @@ func TestValidateSingleFile_ValidFile(t *testing.T) {
 	}
 }
 
+func TestValidateSingleFile_ResultCodePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `up:
+  name: test migration
+  request:
+    method: GET
+    url: "https://api.example.com/test"
+  response:
+    result_code: ["2xx", "200-299", "40x", "{{.code}}", "2xxx", "300-200"]
+`
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	result := validateSingleFile(filePath)
+	if result.Valid {
+		t.Errorf("Expected file with invalid result_code patterns to be invalid")
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("Expected 2 errors for invalid patterns, got: %v", result.Errors)
+	}
+	if !strings.Contains(result.Errors[0], "result_code[4]") || !strings.Contains(result.Errors[1], "result_code[5]") {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_ResultCodeMapping(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `up:
+  name: test migration
+  request:
+    method: POST
+    url: "https://api.example.com/test"
+  response:
+    result_code: ["2xx", { code: 409, extract: false }, { code: "5xx" }, { code: 410, extract: "no" }, { extract: false }]
+`
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	result := validateSingleFile(filePath)
+	if len(result.Errors) != 2 {
+		t.Fatalf("Expected 2 errors for invalid mappings, got: %v", result.Errors)
+	}
+	if !strings.Contains(result.Errors[0], "result_code[3].extract") || !strings.Contains(result.Errors[1], "result_code[4].code") {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_GraphQLRequest(t *testing.T) {
+	tmpDir := t.TempDir()
+	valid := `up:
+  name: create via graphql
+  request:
+    url: "https://api.example.com/graphql"
+    graphql:
+      query: "mutation($n: String!) { create(name: $n) { id } }"
+      variables:
+        n: "{{.env.name}}"
+  response:
+    result_code: ["200"]
+`
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	if err := os.WriteFile(filePath, []byte(valid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if result := validateSingleFile(filePath); len(result.Errors) > 0 {
+		t.Fatalf("Expected graphql request without method to be valid, got: %v", result.Errors)
+	}
+
+	invalid := `up:
+  name: broken graphql
+  request:
+    url: "https://api.example.com/graphql"
+    graphql:
+      variables: []
+`
+	if err := os.WriteFile(filePath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if len(result.Errors) != 2 || !strings.Contains(result.Errors[0], "graphql.query") || !strings.Contains(result.Errors[1], "graphql.variables") {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_BodyJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	valid := `up:
+  name: patch user
+  request:
+    method: PATCH
+    url: "https://api.example.com/users/1"
+    content_type: application/merge-patch+json
+    body_json:
+      name: "{{.env.name}}"
+      tags: [a, b]
+`
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	if err := os.WriteFile(filePath, []byte(valid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if result := validateSingleFile(filePath); len(result.Errors) > 0 {
+		t.Fatalf("Expected body_json request to be valid, got: %v", result.Errors)
+	}
+
+	invalid := `up:
+  name: broken body_json
+  request:
+    method: POST
+    url: "https://api.example.com/users"
+    body: "{}"
+    body_json: "not a map"
+    content_type: ""
+`
+	if err := os.WriteFile(filePath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if len(result.Errors) != 3 || !strings.Contains(result.Errors[0], "must be a map/object or list") ||
+		!strings.Contains(result.Errors[1], "cannot be combined with 'body'") || !strings.Contains(result.Errors[2], "content_type") {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+}
+
 func TestValidateSingleFile_InvalidFile(t *testing.T) {
 	// Create temporary file with invalid content
 	tmpDir, err := os.MkdirTemp("", "apirun_invalid_test")
@@ -201,10 +331,11 @@ func TestFindMigrationFiles(t *testing.T) {
 	}
 	defer func() { _ = os.RemoveAll(tmpDir) }()
 
-	// Create valid migration files
+	// Create valid migration files, in version order; versions need no leading zeros, as for the migrator
 	validFiles := []string{
 		"001_first.yaml",
 		"002_second.yml",
+		"3_no_leading_zeros.yaml",
 		"010_tenth.yaml",
 	}
 
@@ -218,7 +349,7 @@ func TestFindMigrationFiles(t *testing.T) {
 	// Create files that should be ignored
 	ignoredFiles := []string{
 		"invalid_name.yaml",
-		"1_no_leading_zeros.yaml",
+		"v1_prefixed.yaml",
 		"001_valid.txt",
 		"README.md",
 	}
@@ -231,7 +362,7 @@ func TestFindMigrationFiles(t *testing.T) {
 	}
 
 	// Find migration files
-	files, err := findMigrationFiles(tmpDir)
+	files, err := findMigrationFiles(tmpDir, regexp.MustCompile(imig.DefaultVersionPattern))
 	if err != nil {
 		t.Fatalf("Failed to find migration files: %v", err)
 	}
@@ -241,10 +372,11 @@ func TestFindMigrationFiles(t *testing.T) {
 		t.Errorf("Expected %d files, got %d", len(validFiles), len(files))
 	}
 
-	// Check that files are sorted
-	for i := 0; i < len(files)-1; i++ {
-		if files[i] >= files[i+1] {
-			t.Error("Files are not properly sorted")
+	// Check that files are sorted by version
+	for i := range files {
+		if i < len(validFiles) && !strings.HasSuffix(files[i], validFiles[i]) {
+			t.Errorf("Files are not sorted by version: %v", files)
+			break
 		}
 	}
 
@@ -301,3 +433,231 @@ func TestValidationResult_Methods(t *testing.T) {
 		t.Errorf("Expected 1 warning, got %d", results.WarningCount())
 	}
 }
+
+func TestValidateSingleFile_Includes(t *testing.T) {
+	tmpDir := t.TempDir()
+	shared := `up:
+  request:
+    method: POST
+    url: "https://api.example.com/users"
+  response:
+    result_code: ["201"]
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "_shared.yaml"), []byte(shared), 0644); err != nil {
+		t.Fatalf("Failed to write shared file: %v", err)
+	}
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	if err := os.WriteFile(filePath, []byte("includes: [_shared.yaml]\nup:\n  name: create user\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if result := validateSingleFile(filePath); len(result.Errors) > 0 {
+		t.Fatalf("Expected merged migration to be valid, got: %v", result.Errors)
+	}
+
+	if err := os.WriteFile(filePath, []byte("includes: [_missing.yaml]\nup:\n  name: create user\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if result.Valid || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], `cannot resolve include "_missing.yaml"`) {
+		t.Errorf("Expected unresolved include error, got: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_FindPaginate(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `up:
+  name: create user
+  request:
+    method: POST
+    url: "https://api.example.com/users"
+down:
+  name: delete user
+  method: DELETE
+  url: "https://api.example.com/users/{{.user_id}}"
+  find:
+    request:
+      method: GET
+      url: "https://api.example.com/users"
+    response:
+      env_from:
+        user_id: 'items.#(name=="alice").id'
+    paginate:
+      max_pages: -1
+`
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if result.Valid {
+		t.Fatalf("Expected paginate without next to be invalid")
+	}
+	joined := strings.Join(result.Errors, "\n")
+	if !strings.Contains(joined, "'next'") || !strings.Contains(joined, "max_pages") {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_Tags(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	content := "tags: [seed, \"\"]\nup:\n  name: create user\n  request:\n    method: POST\n    url: \"https://api.example.com/users\"\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if result.Valid || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "tags[1]") {
+		t.Errorf("Expected an error for the empty tag only, got: %v", result.Errors)
+	}
+	for _, w := range result.Warnings {
+		if strings.Contains(w, "'tags'") {
+			t.Errorf("tags should be an expected root key, got warning: %s", w)
+		}
+	}
+}
+
+func TestValidateSingleFile_Probe(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	valid := "up:\n  name: create user\n  request:\n    method: POST\n    url: \"https://api.example.com/users\"\n" +
+		"  probe:\n    request:\n      url: \"https://api.example.com/users/alice\"\n    assert:\n      name: alice\n"
+	if err := os.WriteFile(filePath, []byte(valid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if result := validateSingleFile(filePath); len(result.Errors) > 0 {
+		t.Fatalf("Expected a GET probe to be valid, got: %v", result.Errors)
+	}
+
+	invalid := "up:\n  name: create user\n  request:\n    method: POST\n    url: \"https://api.example.com/users\"\n" +
+		"  probe:\n    request:\n      method: DELETE\n      url: \"https://api.example.com/users/alice\"\n"
+	if err := os.WriteFile(filePath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if result.Valid || len(result.Errors) == 0 || !strings.Contains(result.Errors[0], "up.probe.request.method") {
+		t.Errorf("Expected a read-only probe error, got: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_FormAndMultipart(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	valid := "up:\n  name: upload\n  request:\n    method: POST\n    url: \"https://api.example.com/upload\"\n" +
+		"    multipart:\n      fields:\n        name: alice\n      files:\n        - field: avatar\n          path: ./avatar.png\n"
+	if err := os.WriteFile(filePath, []byte(valid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if result := validateSingleFile(filePath); len(result.Errors) > 0 {
+		t.Fatalf("Expected multipart request to be valid, got: %v", result.Errors)
+	}
+
+	invalid := "up:\n  name: login\n  request:\n    method: POST\n    url: \"https://api.example.com/token\"\n" +
+		"    body: x\n    form:\n      grant_type: password\n    multipart:\n      files:\n        - field: avatar\n"
+	if err := os.WriteFile(filePath, []byte(invalid), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	joined := strings.Join(result.Errors, "\n")
+	for _, want := range []string{"'up.request.form' cannot be combined with 'body'", "'up.request.form' cannot be combined with 'multipart'", "multipart.files[0].path"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected error %q, got: %v", want, result.Errors)
+		}
+	}
+}
+
+func TestValidateSingleFile_DownIgnoreCodes(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	content := "up:\n  name: create\n  request:\n    method: POST\n    url: \"https://api.example.com/items\"\n" +
+		"down:\n  name: delete\n  method: DELETE\n  url: \"https://api.example.com/items/1\"\n  ignore_codes: [404, \"410\", 1000]\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	joined := strings.Join(result.Errors, "\n")
+	for _, want := range []string{"'down.ignore_codes[1]'", "'down.ignore_codes[2]'"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected error %q, got: %v", want, result.Errors)
+		}
+	}
+	if strings.Contains(joined, "'down.ignore_codes[0]'") {
+		t.Errorf("Expected 404 to be accepted, got: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_OnMatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	content := "up:\n  name: check\n  request:\n    method: GET\n    url: \"https://api.example.com/feature\"\n" +
+		"  response:\n    result_code: [\"200\"]\n    on_match: { path: enabled, equals: 'true', action: 'skip_to:x' }\n" +
+		"down:\n  name: noop\n  method: GET\n  url: \"https://api.example.com/feature\"\n" +
+		"  response:\n    on_match: { path: enabled, action: skip_next }\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	joined := strings.Join(result.Errors, "\n")
+	for _, want := range []string{"invalid skip_to version", "'down.response.on_match' is only supported in up.response"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected error %q, got: %v", want, result.Errors)
+		}
+	}
+}
+
+func TestValidateSingleFile_HeaderList(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	content := "up:\n  name: create\n  request:\n    method: POST\n    url: \"https://api.example.com/users\"\n" +
+		"    headers:\n      - { name: Content-Type, value: application/json }\n      - { value: orphan }\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "'up.request.headers[1]' must be a {name, value} entry") {
+		t.Fatalf("Expected only the entry without name to be rejected, got: %v", result.Errors)
+	}
+}
+
+func TestValidateSingleFile_ResponseMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "001_test.yaml")
+	content := "up:\n  name: export\n  request:\n    method: POST\n    url: \"https://api.example.com/exports\"\n" +
+		"  response:\n    mode: stream\n" +
+		"down:\n  name: noop\n  method: GET\n  url: \"https://api.example.com/exports\"\n" +
+		"  response:\n    ndjson: { match: { type: result } }\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	result := validateSingleFile(filePath)
+	joined := strings.Join(result.Errors, "\n")
+	for _, want := range []string{"'up.response.mode' must be \"ndjson\"", "'down.response.ndjson' requires mode: ndjson"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected error %q, got: %v", want, result.Errors)
+		}
+	}
+}
+
+func TestValidateMigrationDir_VersionPattern(t *testing.T) {
+	dir := t.TempDir()
+	mig := "up:\n  name: step\n  request:\n    method: GET\n    url: http://example.com\n  response:\n    result_code: [\"200\"]\ndown:\n  name: undo\n  method: DELETE\n  url: http://example.com\n"
+	for _, name := range []string{"V20240115__create.yaml", "V20231201__init.yaml", "001_other.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	results, err := ValidateMigrationDir(dir, `^V(\d+)__.*\.ya?ml$`)
+	if err != nil {
+		t.Fatalf("ValidateMigrationDir: %v", err)
+	}
+	var got []string
+	for _, r := range results.Results {
+		got = append(got, filepath.Base(r.File))
+	}
+	if strings.Join(got, ",") != "V20231201__init.yaml,V20240115__create.yaml" || results.HasErrors() {
+		t.Fatalf("unexpected results: %+v", results.Results)
+	}
+
+	if _, err := ValidateMigrationDir(dir, `^V\d+__`); err == nil {
+		t.Fatalf("expected an error for a pattern without a capture group")
+	}
+}
@@ -1,11 +1,13 @@
 package apirun
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -15,9 +17,28 @@ import (
 // The filename format is: YYYYMMDDHHMMSS_slug.yaml (UTC).
 // The function returns the full created path.
 // It never overwrites an existing file (returns error if exists).
+//
+// By default the file gets a built-in skeleton. TemplatePath, or Kind (a template file named
+// <Kind>.yaml in TemplatesDir), selects a house-style template instead. Templates are rendered
+// with [[ ]] delimiters so that apirun's own {{ }} templates are copied as-is; available fields
+// are [[.Name]], [[.Slug]], [[.Version]] (the timestamp prefix) and [[.Timestamp]] (RFC3339).
 type CreateOptions struct {
 	Name string
 	Dir  string
+	// TemplatePath is a template file used instead of the built-in skeleton.
+	TemplatePath string
+	// Kind selects <TemplatesDir>/<Kind>.yaml; it can't be combined with TemplatePath.
+	Kind string
+	// TemplatesDir holds the Kind templates (default: <Dir>/templates).
+	TemplatesDir string
+}
+
+// TemplateData is the data a custom create template is rendered with.
+type TemplateData struct {
+	Name      string
+	Slug      string
+	Version   string
+	Timestamp string
 }
 
 // CreateMigration generates a new migration YAML file with a basic task template
@@ -38,8 +59,13 @@ func CreateMigration(opts CreateOptions) (string, error) {
 	if slug == "" {
 		slug = "task"
 	}
+	tmplPath, err := opts.templateFile()
+	if err != nil {
+		return "", err
+	}
 	// Timestamp-based filename (UTC)
-	ts := time.Now().UTC().Format("20060102150405")
+	now := time.Now().UTC()
+	ts := now.Format("20060102150405")
 	fname := fmt.Sprintf("%s_%s.yaml", ts, slug)
 	path := filepath.Join(opts.Dir, fname)
 	// Do not overwrite existing files
@@ -47,6 +73,12 @@ func CreateMigration(opts CreateOptions) (string, error) {
 		return "", fmt.Errorf("file already exists: %s", path)
 	}
 	content := templateContent(slug)
+	if tmplPath != "" {
+		data := TemplateData{Name: name, Slug: slug, Version: ts, Timestamp: now.Format(time.RFC3339)}
+		if content, err = renderCreateTemplate(tmplPath, data); err != nil {
+			return "", err
+		}
+	}
 	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
@@ -58,6 +90,46 @@ func (m *Migrator) CreateMigration(name string) (string, error) {
 	return CreateMigration(CreateOptions{Name: name, Dir: m.Dir})
 }
 
+// templateFile resolves the custom template selected by TemplatePath or Kind ("" for the built-in one).
+func (opts CreateOptions) templateFile() (string, error) {
+	tmplPath := strings.TrimSpace(opts.TemplatePath)
+	kind := strings.TrimSpace(opts.Kind)
+	switch {
+	case tmplPath != "" && kind != "":
+		return "", fmt.Errorf("template path and kind are mutually exclusive")
+	case kind != "":
+		if strings.ContainsAny(kind, `/\`) || strings.Contains(kind, "..") {
+			return "", fmt.Errorf("invalid template kind %q", kind)
+		}
+		dir := strings.TrimSpace(opts.TemplatesDir)
+		if dir == "" {
+			dir = filepath.Join(opts.Dir, "templates")
+		}
+		tmplPath = filepath.Join(dir, kind+".yaml")
+		if _, err := os.Stat(tmplPath); err != nil {
+			return "", fmt.Errorf("template kind %q: %w", kind, err)
+		}
+	}
+	return tmplPath, nil
+}
+
+// renderCreateTemplate renders a custom create template file with [[ ]] delimiters.
+func renderCreateTemplate(path string, data TemplateData) (string, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	t, err := template.New(filepath.Base(path)).Delims("[[", "]]").Parse(string(b))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", path, err)
+	}
+	return buf.String(), nil
+}
+
 var nonWord = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
 
 func slugify(s string) string {
@@ -50,3 +50,68 @@ func TestCreateMigration_ErrorOnEmptyDir(t *testing.T) {
 		t.Fatalf("expected error when Dir is empty")
 	}
 }
+
+func TestCreateMigration_CustomTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(t.TempDir(), "house.yaml")
+	body := "# [[.Name]] ([[.Version]])\nup:\n  name: [[.Slug]]\n  request:\n    method: POST\n    url: \"{{.env.api}}/users\"\ndown:\n  name: [[.Slug]]-rollback\n"
+	if err := os.WriteFile(tmpl, []byte(body), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	p, err := CreateMigration(CreateOptions{Name: "Create User", Dir: dir, TemplatePath: tmpl})
+	if err != nil {
+		t.Fatalf("CreateMigration: %v", err)
+	}
+	m := regexp.MustCompile(`^([0-9]{14})_create_user\.yaml$`).FindStringSubmatch(filepath.Base(p))
+	if filepath.Dir(p) != dir || m == nil {
+		t.Fatalf("unexpected path: %s", p)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("read created file: %v", err)
+	}
+	want := "# Create User (" + m[1] + ")\nup:\n  name: create_user\n  request:\n    method: POST\n    url: \"{{.env.api}}/users\"\ndown:\n  name: create_user-rollback\n"
+	if string(b) != want {
+		t.Fatalf("unexpected content:\n%s", b)
+	}
+}
+
+func TestCreateMigration_Kind(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "create-user.yaml"), []byte("up:\n  name: [[.Slug]]\n"), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	p, err := CreateMigration(CreateOptions{Name: "alice", Dir: dir, Kind: "create-user"})
+	if err != nil {
+		t.Fatalf("CreateMigration: %v", err)
+	}
+	if b, _ := os.ReadFile(p); string(b) != "up:\n  name: alice\n" {
+		t.Fatalf("unexpected content: %q", b)
+	}
+
+	for name, opts := range map[string]CreateOptions{
+		"unknown kind":        {Dir: dir, Kind: "nope"},
+		"kind with path":      {Dir: dir, Kind: "../create-user"},
+		"kind and template":   {Dir: dir, Kind: "create-user", TemplatePath: "x.yaml"},
+		"missing template":    {Dir: dir, TemplatePath: filepath.Join(dir, "missing.yaml")},
+		"unknown placeholder": {Dir: dir, TemplatePath: writeTemplate(t, "[[.Owner]]")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := CreateMigration(opts); err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
+
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "tmpl.yaml")
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	return p
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"log"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// migrations holds the migration files and the request bodies they reference via body_file.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// This example runs migrations that are compiled into the binary with go:embed,
+// so no migration directory has to be shipped next to it.
+//
+// Run from the repository root:
+//
+//	go run ./examples/embedded_fs
+//
+// Dir is a path within the embedded FS. Since an FS cannot hold the migration
+// history database, apirun.db is created in the current working directory.
+func main() {
+	ctx := context.Background()
+
+	base := env.Env{Global: env.FromStringMap(map[string]string{"title": "hello from embed.FS"})}
+
+	m := apirun.Migrator{Env: &base, FS: migrations, Dir: "migrations"}
+	vres, err := m.MigrateUp(ctx, 0)
+	if err != nil {
+		log.Fatalf("migrate up failed: %v", err)
+	}
+	for _, vr := range vres {
+		if vr != nil && vr.Result != nil {
+			fmt.Printf("v%03d: status=%d env=%v\n", vr.Version, vr.Result.StatusCode, vr.Result.ExtractedEnv)
+		}
+	}
+	fmt.Println("migrations completed successfully (embedded FS)")
+}
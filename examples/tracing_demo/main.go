@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// This example traces a migration run with OpenTelemetry: the run, every step and every HTTP
+// request become spans, printed to stdout as JSON lines as they finish.
+//
+// Run from the repository root:
+//
+//	go run ./examples/tracing_demo
+func main() {
+	ctx := context.Background()
+
+	// A local stand-in for the API the migrations talk to
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/items":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"item-1"}`))
+		case strings.HasPrefix(r.URL.Path, "/items/item-1"), r.URL.Path == "/tags/traced":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	storeDir, err := os.MkdirTemp("", "apirun-tracing-demo")
+	if err != nil {
+		log.Fatalf("temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(storeDir) }()
+
+	storeConfig := apirun.StoreConfig{}
+	storeConfig.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(storeDir, apirun.StoreDBFileName)}
+
+	m := apirun.Migrator{
+		Dir:         "examples/tracing_demo/migration",
+		Env:         &env.Env{Global: env.FromStringMap(map[string]string{"api_base": srv.URL})},
+		StoreConfig: &storeConfig,
+		// Any trace.TracerProvider works here, e.g. one from the OpenTelemetry SDK (see README.md)
+		TracerProvider: &stdoutProvider{out: os.Stdout},
+	}
+
+	fmt.Println("--- migrate up ---")
+	if _, err := m.MigrateUp(ctx, 0); err != nil {
+		log.Fatalf("migrate up failed: %v", err)
+	}
+	fmt.Println("--- migrate down ---")
+	if _, err := m.MigrateDown(ctx, 0); err != nil {
+		log.Fatalf("migrate down failed: %v", err)
+	}
+}
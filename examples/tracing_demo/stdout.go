@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// stdoutProvider is a tiny TracerProvider that writes every finished span as one JSON line.
+// It only exists to keep this example free of the OpenTelemetry SDK; in an application use
+// sdktrace.NewTracerProvider with the stdouttrace (or OTLP) exporter instead, see README.md.
+type stdoutProvider struct {
+	noop.TracerProvider
+	mu  sync.Mutex
+	out io.Writer
+	ids int
+}
+
+type stdoutTracer struct {
+	noop.Tracer
+	p *stdoutProvider
+}
+
+type stdoutSpan struct {
+	noop.Span `json:"-"`
+	p         *stdoutProvider
+	ID        int               `json:"id"`
+	Parent    int               `json:"parent,omitempty"`
+	Name      string            `json:"name"`
+	Start     time.Time         `json:"start"`
+	Millis    int64             `json:"duration_ms"`
+	Attrs     map[string]string `json:"attributes"`
+	Status    string            `json:"status,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+func (p *stdoutProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return stdoutTracer{p: p}
+}
+
+func (t stdoutTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.p.mu.Lock()
+	t.p.ids++
+	s := &stdoutSpan{p: t.p, ID: t.p.ids, Name: name, Start: time.Now(), Attrs: map[string]string{}}
+	t.p.mu.Unlock()
+	if parent, ok := trace.SpanFromContext(ctx).(*stdoutSpan); ok {
+		s.Parent = parent.ID
+	}
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (s *stdoutSpan) IsRecording() bool                    { return true }
+func (s *stdoutSpan) TracerProvider() trace.TracerProvider { return s.p }
+
+func (s *stdoutSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.Attrs[string(a.Key)] = a.Value.Emit()
+	}
+}
+
+func (s *stdoutSpan) SetStatus(code codes.Code, description string) {
+	s.Status = code.String()
+	if code == codes.Error {
+		s.Error = description
+	}
+}
+
+func (s *stdoutSpan) End(...trace.SpanEndOption) {
+	s.Millis = time.Since(s.Start).Milliseconds()
+	b, _ := json.Marshal(s)
+	s.p.mu.Lock()
+	defer s.p.mu.Unlock()
+	_, _ = fmt.Fprintln(s.p.out, string(b))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// This example registers an env_from extractor for XML responses: paths such as
+// "item/id" select the text of an element by its path from the document root.
+//
+// Run from the repository root:
+//
+//	go run ./examples/xml_extractor
+func main() {
+	ctx := context.Background()
+	apirun.RegisterExtractor("application/xml", extractXML)
+
+	// A local stand-in for an API that answers in XML
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/items":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`<?xml version="1.0"?><item><id>item-7</id><owner><name>alice</name></owner></item>`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/items/item-7":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	storeDir, err := os.MkdirTemp("", "apirun-xml-extractor")
+	if err != nil {
+		log.Fatalf("temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(storeDir) }()
+
+	storeConfig := apirun.StoreConfig{}
+	storeConfig.DriverConfig = &apirun.SqliteConfig{Path: filepath.Join(storeDir, apirun.StoreDBFileName)}
+
+	m := apirun.Migrator{
+		Dir:         "examples/xml_extractor/migration",
+		Env:         &env.Env{Global: env.FromStringMap(map[string]string{"api_base": srv.URL})},
+		StoreConfig: &storeConfig,
+	}
+	results, err := m.MigrateUp(ctx, 0)
+	if err != nil {
+		log.Fatalf("migrate up failed: %v", err)
+	}
+	for _, r := range results {
+		fmt.Printf("version %d extracted %v\n", r.Version, r.Result.ExtractedEnv)
+	}
+	if _, err := m.MigrateDown(ctx, 0); err != nil {
+		log.Fatalf("migrate down failed: %v", err)
+	}
+	fmt.Println("rolled back")
+}
+
+// extractXML returns, for each key of spec, the text of the first element at its slash-separated
+// path ("item/id"). Keys whose element is absent are left out.
+func extractXML(body []byte, spec map[string]string) (map[string]string, error) {
+	texts := map[string]string{}
+	var path []string
+	var text strings.Builder
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			p := strings.Join(path, "/")
+			if _, seen := texts[p]; !seen {
+				texts[p] = strings.TrimSpace(text.String())
+			}
+			path = path[:len(path)-1]
+			text.Reset()
+		}
+	}
+	out := map[string]string{}
+	for key, p := range spec {
+		if v, ok := texts[strings.Trim(p, "/")]; ok {
+			out[key] = v
+		}
+	}
+	return out, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/loykin/apirun/internal/util"
 	"github.com/loykin/apirun/pkg/env"
@@ -13,6 +14,12 @@ type Auth struct {
 	Type    string       `mapstructure:"type"`
 	Name    string       `mapstructure:"name"`
 	Methods MethodConfig `mapstructure:"methods"`
+	// Method, when set, acquires the token directly instead of building a provider from Type
+	// and Methods through the registry (programmatic use only).
+	Method Method `mapstructure:"-"`
+	// DependsOn names another auth entry whose token must be acquired first, so that its
+	// value can be used in this entry's Methods templates (e.g. token: "{{.auth.service}}").
+	DependsOn string `mapstructure:"depends_on"`
 }
 
 type MethodConfig interface {
@@ -31,22 +38,37 @@ func NewAuthSpecFromMap(m map[string]interface{}) MethodConfig { return mapConfi
 // Behavior:
 // - Uses Type as the provider key (e.g., "basic", "oauth2", "pocketbase").
 // - Uses the single Methods configuration (since Type is already selected globally).
-// - Renders any Go templates in the method config using only flat key/value pairs from the environment:
-//   - First from process environment variables if present (so CLI can inject secrets),
-//   - Then leaves unchanged any placeholders if not resolvable (RenderAnyTemplate keeps originals when missing).
-//
+// - Renders Go templates in the method config against e (see below).
 // - Calls the provider registry to acquire the token value.
 // - If Name is set, storage by name is handled by the migration layer using the returned value.
+//
+// Every string of the config is rendered, including nested maps such as the oauth2 grant_config,
+// so token_url: "{{.env.api_base}}/oauth/token" works. Map specs and struct-based Methods are
+// rendered alike, from ToMap. Placeholders that cannot be resolved are left unchanged
+// (RenderAnyTemplate keeps originals when missing).
 func (a *Auth) Acquire(ctx context.Context, e *env.Env) (string, error) {
+	val, _, err := a.AcquireWithExpiry(ctx, e)
+	return val, err
+}
+
+// AcquireWithExpiry behaves like Acquire but also returns the token expiry when the provider
+// reports one (zero time otherwise).
+func (a *Auth) AcquireWithExpiry(ctx context.Context, e *env.Env) (string, time.Time, error) {
 	if a == nil {
-		return "", nil
+		return "", time.Time{}, nil
+	}
+	if err := a.acquireDependency(e); err != nil {
+		return "", time.Time{}, err
+	}
+	if a.Method != nil {
+		return acquireFromMethod(ctx, a.Type, a.Method)
 	}
 	pt := strings.TrimSpace(a.Type)
 	if pt == "" {
-		return "", fmt.Errorf("auth: missing type")
+		return "", time.Time{}, fmt.Errorf("auth: missing type")
 	}
 	if a.Methods == nil {
-		return "", fmt.Errorf("auth: methods not provided")
+		return "", time.Time{}, fmt.Errorf("auth: methods not provided")
 	}
 	cfg := a.Methods.ToMap()
 	// Render templates in cfg using the provided env (global/local/auth)
@@ -55,9 +77,73 @@ func (a *Auth) Acquire(ctx context.Context, e *env.Env) (string, error) {
 	if rendered == nil {
 		rendered = cfg
 	}
-	val, err := AcquireAndStoreWithName(ctx, pt, rendered)
-	if err != nil {
-		return "", err
+	return AcquireWithExpiry(ctx, pt, rendered)
+}
+
+// acquireDependency resolves the token a depends on, so that its acquisition error is reported
+// instead of rendering an empty value into a's templates.
+func (a *Auth) acquireDependency(e *env.Env) error {
+	dep := strings.TrimSpace(a.DependsOn)
+	if dep == "" {
+		return nil
+	}
+	var v env.Val
+	if e != nil {
+		v = e.Auth[dep]
+	}
+	if v == nil {
+		return fmt.Errorf("auth %s: depends_on %q is not configured", a.Name, dep)
+	}
+	if lazy, ok := v.(*env.VarLazy); ok {
+		if _, err := lazy.Value(); err != nil {
+			return fmt.Errorf("auth %s: depends_on %q: %w", a.Name, dep, err)
+		}
+	}
+	return nil
+}
+
+// Order returns the auth entries sorted so that every entry comes after the one it depends on,
+// keeping the configured order otherwise. Unknown dependencies and cycles are errors.
+func Order(auths []Auth) ([]Auth, error) {
+	byName := make(map[string]int, len(auths))
+	for i, a := range auths {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			byName[name] = i
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(auths))
+	out := make([]Auth, 0, len(auths))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("auth: depends_on cycle: %s", strings.Join(append(path, auths[i].Name), " -> "))
+		}
+		state[i] = visiting
+		if dep := strings.TrimSpace(auths[i].DependsOn); dep != "" {
+			j, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("auth %s: depends_on %q does not name a configured auth", auths[i].Name, dep)
+			}
+			if err := visit(j, append(path, auths[i].Name)); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		out = append(out, auths[i])
+		return nil
+	}
+	for i := range auths {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
 	}
-	return val, nil
+	return out, nil
 }
@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/loykin/apirun/internal/auth/oauth2"
 	"github.com/loykin/apirun/pkg/env"
 )
 
@@ -74,3 +78,67 @@ func TestAuth_Acquire_ProviderError(t *testing.T) {
 		t.Fatalf("expected provider error")
 	}
 }
+
+func TestOrder_DependenciesFirst(t *testing.T) {
+	got, err := Order([]Auth{
+		{Name: "scoped", DependsOn: "service"},
+		{Name: "other"},
+		{Name: "service", DependsOn: "root"},
+		{Name: "root"},
+	})
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	var names []string
+	for _, a := range got {
+		names = append(names, a.Name)
+	}
+	if strings.Join(names, ",") != "root,service,scoped,other" {
+		t.Fatalf("unexpected order: %v", names)
+	}
+
+	if _, err := Order([]Auth{{Name: "a", DependsOn: "b"}, {Name: "b", DependsOn: "c"}, {Name: "c", DependsOn: "a"}}); err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if _, err := Order([]Auth{{Name: "a", DependsOn: "nope"}}); err == nil {
+		t.Fatalf("expected an unknown dependency error")
+	}
+}
+
+func TestAuth_Acquire_RendersOAuth2TokenURLFromEnv(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path != "/oauth/token" || r.Form.Get("client_secret") != "s3" {
+			http.Error(w, "unexpected token request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"tok-1","token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+
+	base := env.Env{Global: env.FromStringMap(map[string]string{"api_base": srv.URL, "client_secret": "s3"})}
+	specs := map[string]MethodConfig{
+		"map": NewAuthSpecFromMap(map[string]interface{}{
+			"grant_type": "client_credentials",
+			"grant_config": map[string]interface{}{
+				"client_id":     "svc",
+				"client_secret": "{{.env.client_secret}}",
+				"token_url":     "{{.env.api_base}}/oauth/token",
+			},
+		}),
+		"struct": oauth2.ClientCredentialsConfig{ClientID: "svc", ClientSec: "{{.env.client_secret}}", TokenURL: "{{.env.api_base}}/oauth/token"},
+	}
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			a := &Auth{Type: "oauth2", Name: "api", Methods: spec}
+			v, err := a.Acquire(context.Background(), &base)
+			if err != nil {
+				t.Fatalf("Acquire error: %v", err)
+			}
+			if v != "tok-1" {
+				t.Fatalf("unexpected token: %q", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTokenExpirySkew is how long before expiry a cached token is considered stale.
+const DefaultTokenExpirySkew = 30 * time.Second
+
+type cachedToken struct {
+	value     string
+	expiresAt time.Time
+}
+
+// TokenCache stores acquired tokens keyed by auth name so repeated migrations reuse them
+// instead of hitting the token endpoint again. It is safe for concurrent use.
+type TokenCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedToken
+	now     func() time.Time
+}
+
+// NewTokenCache returns an empty TokenCache.
+func NewTokenCache() *TokenCache {
+	return &TokenCache{entries: map[string]cachedToken{}, now: time.Now}
+}
+
+// Get returns the cached token for name if present and not within skew of its expiry.
+// Tokens stored with a zero expiry never go stale.
+func (c *TokenCache) Get(name string, skew time.Duration) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok || e.value == "" {
+		return "", false
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	if !e.expiresAt.IsZero() && !now().Add(skew).Before(e.expiresAt) {
+		return "", false
+	}
+	return e.value, true
+}
+
+// Put stores a token for name with its expiry (zero = no known expiry).
+func (c *TokenCache) Put(name, value string, expiresAt time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]cachedToken{}
+	}
+	c.entries[name] = cachedToken{value: value, expiresAt: expiresAt}
+}
+
+// Invalidate drops the cached token for name.
+func (c *TokenCache) Invalidate(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenCache_GetPutAndSkew(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewTokenCache()
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.Get("a", time.Second); ok {
+		t.Fatalf("expected miss on empty cache")
+	}
+
+	c.Put("a", "tok-a", now.Add(time.Minute))
+	if v, ok := c.Get("a", 30*time.Second); !ok || v != "tok-a" {
+		t.Fatalf("expected fresh token, got %q ok=%v", v, ok)
+	}
+	// Within skew of expiry -> stale
+	if _, ok := c.Get("a", 90*time.Second); ok {
+		t.Fatalf("expected token within skew to be stale")
+	}
+
+	// Zero expiry never goes stale
+	c.Put("b", "tok-b", time.Time{})
+	now = now.Add(24 * time.Hour)
+	if v, ok := c.Get("b", time.Hour); !ok || v != "tok-b" {
+		t.Fatalf("expected non-expiring token, got %q ok=%v", v, ok)
+	}
+
+	c.Invalidate("b")
+	if _, ok := c.Get("b", 0); ok {
+		t.Fatalf("expected miss after invalidate")
+	}
+}
+
+func TestTokenCache_NilSafe(t *testing.T) {
+	var c *TokenCache
+	c.Put("x", "y", time.Time{})
+	if _, ok := c.Get("x", 0); ok {
+		t.Fatalf("nil cache must always miss")
+	}
+	c.Invalidate("x")
+}
@@ -4,4 +4,7 @@ const (
 	AuthTypeBasic      = "basic"
 	AuthTypeOAuth2     = "oauth2"
 	AuthTypePocketBase = "pocketbase"
+	AuthTypeVault      = "vault"
+	AuthTypeNegotiate  = "negotiate"
+	AuthTypeNTLM       = "ntlm"
 )
@@ -0,0 +1,16 @@
+package ntlm
+
+import (
+	"context"
+	"fmt"
+)
+
+type Adapter struct{ C Config }
+
+func (m Adapter) Acquire(ctx context.Context) (string, error) {
+	c := CredentialsFrom(ctx)
+	if c == nil {
+		return "", fmt.Errorf("negotiate: no credentials store in context (NTLM auth is only available in migrator runs)")
+	}
+	return c.Acquire(m.C)
+}
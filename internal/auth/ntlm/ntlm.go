@@ -0,0 +1,119 @@
+package ntlm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/loykin/apirun/internal/util"
+)
+
+// HandlePrefix starts the values returned by Credentials.Acquire. They are opaque handles to
+// credentials kept in memory, not tokens: Transport finds one in the Authorization header of a
+// request and replaces it with the NTLM handshake.
+const HandlePrefix = "apirun-ntlm:"
+
+// Config holds the explicit credentials for NTLM authentication.
+type Config struct {
+	// Domain of the account; may be left empty when Username is given as DOMAIN\user.
+	Domain   string `mapstructure:"domain"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// Workstation name sent in the handshake (optional).
+	Workstation string `mapstructure:"workstation"`
+}
+
+// ToMap returns a spec map for the negotiate provider.
+func (c Config) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"domain":      c.Domain,
+		"username":    c.Username,
+		"password":    c.Password,
+		"workstation": c.Workstation,
+	}
+}
+
+// user returns the account name in the DOMAIN\user form the handshake expects.
+func (c Config) user() string {
+	if c.Domain == "" {
+		return c.Username
+	}
+	return c.Domain + `\` + c.Username
+}
+
+// Credentials keeps the NTLM credentials acquired during the runs of one migrator. Handles are
+// only known to the Credentials that issued them, so they never outlive the migrator.
+type Credentials struct {
+	mu      sync.Mutex
+	handles map[string]Config
+	// http1 holds the HTTP/1.1 clone of each base transport used for the handshake
+	http1 map[*http.Transport]*http.Transport
+}
+
+// NewCredentials returns an empty Credentials.
+func NewCredentials() *Credentials {
+	return &Credentials{handles: map[string]Config{}, http1: map[*http.Transport]*http.Transport{}}
+}
+
+type credentialsKey struct{}
+
+// WithCredentials returns a context carrying c; the negotiate provider registers the acquired
+// credentials in it.
+func WithCredentials(ctx context.Context, c *Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, c)
+}
+
+// CredentialsFrom returns the Credentials carried by ctx, or nil.
+func CredentialsFrom(ctx context.Context) *Credentials {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(credentialsKey{}).(*Credentials)
+	return c
+}
+
+// Acquire keeps the credentials and returns the handle that requests reference them by.
+func (c *Credentials) Acquire(pc Config) (string, error) {
+	cfg := Config{
+		Domain:      strings.TrimSpace(pc.Domain),
+		Username:    strings.TrimSpace(pc.Username),
+		Password:    pc.Password,
+		Workstation: strings.TrimSpace(pc.Workstation),
+	}
+	if domain, user, ok := strings.Cut(cfg.Username, `\`); ok && cfg.Domain == "" {
+		cfg.Domain, cfg.Username = domain, user
+	}
+	if _, ok := util.TrimEmptyCheck(cfg.Username); !ok || cfg.Password == "" {
+		return "", fmt.Errorf("negotiate: username and password are required")
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("negotiate: failed to create credentials handle: %w", err)
+	}
+	h := HandlePrefix + hex.EncodeToString(id)
+	c.mu.Lock()
+	c.handles[h] = cfg
+	c.mu.Unlock()
+	return h, nil
+}
+
+// lookup returns the credentials referenced by the last word of an Authorization header value,
+// so "{{.auth.name}}" and "NTLM {{.auth.name}}" both work. ok is false when the value holds no
+// handle.
+func (c *Credentials) lookup(header string) (cfg Config, ok bool, err error) {
+	fields := strings.Fields(header)
+	if len(fields) == 0 || !strings.HasPrefix(fields[len(fields)-1], HandlePrefix) {
+		return Config{}, false, nil
+	}
+	c.mu.Lock()
+	cfg, found := c.handles[fields[len(fields)-1]]
+	c.mu.Unlock()
+	if !found {
+		return Config{}, true, fmt.Errorf("negotiate: unknown credentials handle (handles are only valid in the migrator that acquired them)")
+	}
+	return cfg, true, nil
+}
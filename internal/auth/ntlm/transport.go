@@ -0,0 +1,72 @@
+package ntlm
+
+import (
+	"net/http"
+	"slices"
+
+	ntlmssp "github.com/Azure/go-ntlmssp"
+)
+
+// Transport performs the NTLM handshake for requests whose Authorization header carries a
+// handle issued by Credentials; other requests go to Base unchanged. Servers offering either
+// "NTLM" or "Negotiate" are answered. The handshake authenticates the connection, so it runs
+// over HTTP/1.1 with keep-alive even when Base would negotiate HTTP/2 or close connections.
+type Transport struct {
+	Base        http.RoundTripper
+	Credentials *Credentials
+}
+
+// WrapTransport returns base wrapped in a Transport resolving handles issued by c.
+func (c *Credentials) WrapTransport(base http.RoundTripper) http.RoundTripper {
+	return &Transport{Base: base, Credentials: c}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Credentials == nil {
+		return t.base().RoundTrip(req)
+	}
+	cred, ok, err := t.Credentials.lookup(req.Header.Get("Authorization"))
+	if !ok {
+		return t.base().RoundTrip(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The negotiator takes the credentials from basic auth and never sends them as such
+	r := req.Clone(req.Context())
+	r.SetBasicAuth(cred.user(), cred.Password)
+	n := ntlmssp.Negotiator{RoundTripper: t.Credentials.http1Transport(t.base()), WorkstationName: cred.Workstation}
+	return n.RoundTrip(r)
+}
+
+// http1Transport returns the HTTP/1.1 keep-alive variant of base. Its connection pool is shared
+// by all NTLM requests through base, so authenticated connections are reused.
+func (c *Credentials) http1Transport(base http.RoundTripper) http.RoundTripper {
+	ht, ok := base.(*http.Transport)
+	if !ok {
+		return base
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h1, ok := c.http1[ht]; ok {
+		return h1
+	}
+	h1 := ht.Clone()
+	h1.ForceAttemptHTTP2 = false
+	h1.DisableKeepAlives = false
+	h1.Protocols = new(http.Protocols)
+	h1.Protocols.SetHTTP1(true)
+	if h1.TLSClientConfig != nil {
+		// A base that already negotiated HTTP/2 advertises it over ALPN
+		h1.TLSClientConfig.NextProtos = slices.DeleteFunc(slices.Clone(h1.TLSClientConfig.NextProtos), func(p string) bool { return p == "h2" })
+	}
+	c.http1[ht] = h1
+	return h1
+}
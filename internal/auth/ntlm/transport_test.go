@@ -0,0 +1,222 @@
+package ntlm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// challengeMessage builds a minimal CHALLENGE (type 2) message offering unicode NTLM.
+func challengeMessage() []byte {
+	b := make([]byte, 48)
+	copy(b, ntlmSignature)
+	binary.LittleEndian.PutUint32(b[8:], 2)
+	binary.LittleEndian.PutUint32(b[16:], 48)
+	binary.LittleEndian.PutUint32(b[20:], 0x00000201) // NEGOTIATE_UNICODE | NEGOTIATE_NTLM
+	copy(b[24:], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	binary.LittleEndian.PutUint16(b[40:], 4)
+	binary.LittleEndian.PutUint16(b[42:], 4)
+	binary.LittleEndian.PutUint32(b[44:], 48)
+	return append(b, 0, 0, 0, 0) // MsvAvEOL
+}
+
+// field returns the UTF-16 string a (len, maxlen, offset) field of msg points at.
+func field(msg []byte, at int) string {
+	n := int(binary.LittleEndian.Uint16(msg[at:]))
+	off := int(binary.LittleEndian.Uint32(msg[at+4:]))
+	u := make([]uint16, n/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(msg[off+2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// ntlmServer is a mock server that requires the NTLM handshake under scheme and accepts the
+// AUTHENTICATE message of the expected account. It records each leg.
+type ntlmServer struct {
+	t      *testing.T
+	scheme string
+	user   string
+	domain string
+
+	mu      sync.Mutex
+	remotes []string
+	protos  []string
+	bodies  []string
+	authOK  bool
+}
+
+func (s *ntlmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remotes = append(s.remotes, r.RemoteAddr)
+	s.protos = append(s.protos, r.Proto)
+	s.bodies = append(s.bodies, string(body))
+
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	raw, err := base64.StdEncoding.DecodeString(token)
+	if scheme != s.scheme || err != nil || len(raw) < 12 || !bytes.Equal(raw[:8], ntlmSignature) {
+		w.Header().Set("WWW-Authenticate", s.scheme)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch binary.LittleEndian.Uint32(raw[8:]) {
+	case 1:
+		w.Header().Set("WWW-Authenticate", s.scheme+" "+base64.StdEncoding.EncodeToString(challengeMessage()))
+		w.WriteHeader(http.StatusUnauthorized)
+	case 3:
+		if domain, user := field(raw, 28), field(raw, 36); domain != s.domain || user != s.user {
+			s.t.Errorf("authenticate message names %q\\%q", domain, user)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.authOK = true
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestCredentials_Acquire(t *testing.T) {
+	c := NewCredentials()
+	h, err := c.Acquire(Config{Username: `CORP\alice`, Password: "s3cret"})
+	if err != nil || !strings.HasPrefix(h, HandlePrefix) {
+		t.Fatalf("unexpected handle %q, err %v", h, err)
+	}
+	if strings.Contains(h, "s3cret") || strings.Contains(h, "alice") {
+		t.Fatalf("handle must not reveal the credentials: %q", h)
+	}
+	cfg, ok, err := c.lookup("NTLM " + h)
+	if !ok || err != nil || cfg.Domain != "CORP" || cfg.Username != "alice" {
+		t.Fatalf("lookup = %+v %v %v", cfg, ok, err)
+	}
+	if _, ok, _ := c.lookup("Bearer abc"); ok {
+		t.Fatal("a plain token must not be taken for a handle")
+	}
+	if _, ok, err := NewCredentials().lookup(h); !ok || err == nil {
+		t.Fatal("expected a handle to be unknown to other Credentials")
+	}
+	if _, err := c.Acquire(Config{Username: "alice"}); err == nil {
+		t.Fatal("expected an error without a password")
+	}
+
+	if _, err := (Adapter{C: Config{Username: "alice", Password: "p"}}).Acquire(context.Background()); err == nil {
+		t.Fatal("expected the adapter to fail without Credentials in the context")
+	}
+	h, err = Adapter{C: Config{Username: "alice", Password: "p"}}.Acquire(WithCredentials(context.Background(), c))
+	if _, ok, lerr := c.lookup(h); err != nil || !ok || lerr != nil {
+		t.Fatalf("expected the adapter to register in the context Credentials, got %q, %v, %v", h, err, lerr)
+	}
+}
+
+func TestTransport_Handshake(t *testing.T) {
+	for _, scheme := range []string{"NTLM", "Negotiate"} {
+		t.Run(scheme, func(t *testing.T) {
+			s := &ntlmServer{t: t, scheme: scheme, user: "alice", domain: "CORP"}
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+
+			c := NewCredentials()
+			h, err := c.Acquire(Config{Username: `CORP\alice`, Password: "s3cret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"name":"x"}`))
+			req.Header.Set("Authorization", h)
+			client := &http.Client{Transport: c.WrapTransport(http.DefaultTransport.(*http.Transport).Clone())}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if resp.StatusCode != http.StatusOK || !s.authOK {
+				t.Fatalf("expected the handshake to succeed, got status %d", resp.StatusCode)
+			}
+			last := len(s.remotes) - 1
+			if s.remotes[last] != s.remotes[last-1] {
+				t.Fatalf("challenge and authenticate legs must share a connection: %v", s.remotes)
+			}
+			if s.bodies[last] != `{"name":"x"}` {
+				t.Fatalf("the final leg must carry the request body, got %q", s.bodies[last])
+			}
+		})
+	}
+}
+
+func TestTransport_HTTP1KeepAlive(t *testing.T) {
+	s := &ntlmServer{t: t, scheme: "NTLM", user: "alice", domain: "CORP"}
+	srv := httptest.NewUnstartedServer(s)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	// A base that would negotiate HTTP/2 and close every connection
+	base := srv.Client().Transport.(*http.Transport).Clone()
+	base.ForceAttemptHTTP2 = true
+	base.DisableKeepAlives = true
+	c := NewCredentials()
+	h, _ := c.Acquire(Config{Domain: "CORP", Username: "alice", Password: "s3cret"})
+	client := &http.Client{Transport: c.WrapTransport(base)}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Authorization", "NTLM "+h)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the handshake to succeed, got status %d", resp.StatusCode)
+		}
+	}
+	for i, p := range s.protos {
+		if p != "HTTP/1.1" {
+			t.Fatalf("NTLM leg %d used %s, want HTTP/1.1", i, p)
+		}
+		if s.remotes[i] != s.remotes[0] {
+			t.Fatalf("expected every leg on one kept-alive connection, got %v", s.remotes)
+		}
+	}
+	if base.Protocols != nil || !base.DisableKeepAlives {
+		t.Fatal("the base transport must be left unchanged")
+	}
+}
+
+func TestTransport_PassThrough(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	resp, err := (&http.Client{Transport: NewCredentials().WrapTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if got != "Bearer abc" {
+		t.Fatalf("requests without a handle must pass through, got Authorization %q", got)
+	}
+
+	// A handle of another migrator is rejected instead of being sent as a token
+	h, _ := NewCredentials().Acquire(Config{Username: "alice", Password: "p"})
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", h)
+	if _, err := (&http.Client{Transport: NewCredentials().WrapTransport(nil)}).Do(req); err == nil || !strings.Contains(err.Error(), "unknown credentials handle") {
+		t.Fatalf("expected an unknown handle error, got %v", err)
+	}
+}
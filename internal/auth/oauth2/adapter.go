@@ -1,6 +1,9 @@
 package oauth2
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Adapter struct {
 	M Method
@@ -9,3 +12,13 @@ type Adapter struct {
 func (a Adapter) Acquire(ctx context.Context) (string, error) {
 	return a.M.Acquire(ctx)
 }
+
+// AcquireWithExpiry delegates to the wrapped grant when it reports expiry; otherwise the
+// returned expiry is zero (unknown).
+func (a Adapter) AcquireWithExpiry(ctx context.Context) (string, time.Time, error) {
+	if em, ok := a.M.(ExpiringMethod); ok {
+		return em.AcquireWithExpiry(ctx)
+	}
+	v, err := a.M.Acquire(ctx)
+	return v, time.Time{}, err
+}
@@ -4,8 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
-	acommon "github.com/loykin/apirun/internal/auth/common"
+	"github.com/loykin/apirun/internal/httpc"
 	"github.com/loykin/apirun/internal/util"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
@@ -40,22 +41,24 @@ type clientCredentialsMethod struct {
 }
 
 func (m clientCredentialsMethod) Acquire(ctx context.Context) (string, error) {
+	v, _, err := m.AcquireWithExpiry(ctx)
+	return v, err
+}
+
+// AcquireWithExpiry acquires a token and reports its expiry as parsed from expires_in
+// (zero time when the server did not provide one).
+func (m clientCredentialsMethod) AcquireWithExpiry(ctx context.Context) (string, time.Time, error) {
 	fields := util.TrimSpaceFields(m.c.ClientID, m.c.ClientSec, m.c.TokenURL)
 	clientID, clientSecret, tokenURL := fields[0], fields[1], fields[2]
 	if tokenURL == "" {
-		return "", fmt.Errorf("oauth2: token_url is required for client_credentials grant")
+		return "", time.Time{}, fmt.Errorf("oauth2: token_url is required for client_credentials grant")
 	}
 	if clientID == "" || clientSecret == "" {
-		return "", fmt.Errorf("oauth2: client_id and client_secret are required for client_credentials grant")
+		return "", time.Time{}, fmt.Errorf("oauth2: client_id and client_secret are required for client_credentials grant")
 	}
-	// If a TLS config is provided, inject a custom HTTP client into the context
-	if cfg := acommon.GetTLSConfig(); cfg != nil {
-		tr := &http.Transport{TLSClientConfig: cfg}
-		hc := &http.Client{Transport: tr}
-		if ctx == nil {
-			ctx = context.Background()
-		}
-		ctx = context.WithValue(ctx, oauth2.HTTPClient, hc)
+	// Send through the run's transport, which carries its TLS settings, when ctx has one
+	if tr := httpc.SharedTransport(ctx); tr != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: tr})
 	}
 	cc := &clientcredentials.Config{
 		ClientID:     clientID,
@@ -66,7 +69,8 @@ func (m clientCredentialsMethod) Acquire(ctx context.Context) (string, error) {
 	}
 	tok, err := cc.Token(ctx)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
-	return normalizeOAuth2Token(tok)
+	v, err := normalizeOAuth2Token(tok)
+	return v, tok.Expiry, err
 }
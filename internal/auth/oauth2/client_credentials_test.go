@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestAcquireClientCredentials_Success(t *testing.T) {
@@ -56,3 +57,24 @@ func TestInternalClientCredentialsConfig_ToMap(t *testing.T) {
 		t.Fatalf("scopes not preserved: %+v", sub2["scopes"])
 	}
 }
+
+func TestAcquireClientCredentials_WithExpiry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"t-exp","token_type":"Bearer","expires_in":120}`))
+	}))
+	defer srv.Close()
+
+	m := Adapter{M: clientCredentialsMethod{c: ClientCredentialsConfig{ClientID: "svc", ClientSec: "secret", TokenURL: srv.URL + "/token"}}}
+	before := time.Now()
+	v, exp, err := m.AcquireWithExpiry(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != "t-exp" {
+		t.Fatalf("unexpected value: %q", v)
+	}
+	if exp.Before(before.Add(110*time.Second)) || exp.After(time.Now().Add(121*time.Second)) {
+		t.Fatalf("unexpected expiry %v", exp)
+	}
+}
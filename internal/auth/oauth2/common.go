@@ -2,6 +2,7 @@ package oauth2
 
 import (
 	"context"
+	"time"
 )
 
 // Method is the local interface implemented by oauth2 auth methods.
@@ -9,3 +10,9 @@ import (
 type Method interface {
 	Acquire(ctx context.Context) (value string, err error)
 }
+
+// ExpiringMethod is implemented by grants that know when their token expires.
+// It matches the parent auth.ExpiringMethod signature.
+type ExpiringMethod interface {
+	AcquireWithExpiry(ctx context.Context) (value string, expiresAt time.Time, err error)
+}
@@ -71,3 +71,20 @@ func TestInternalPasswordConfig_ToMap(t *testing.T) {
 		t.Fatalf("scopes not preserved: %+v", sub2["scopes"])
 	}
 }
+
+func TestAcquirePassword_WithExpiry_NoExpiresIn(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tokenResp{AccessToken: "t-pw", TokenType: "Bearer"})
+	}))
+	defer srv.Close()
+
+	m := passwordMethod{c: PasswordConfig{ClientID: "c", Username: "u", Password: "p", TokenURL: srv.URL + "/token"}}
+	v, exp, err := m.AcquireWithExpiry(context.Background())
+	if err != nil || v != "t-pw" {
+		t.Fatalf("unexpected result: %q err=%v", v, err)
+	}
+	if !exp.IsZero() {
+		t.Fatalf("expected zero expiry without expires_in, got %v", exp)
+	}
+}
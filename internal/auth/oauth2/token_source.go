@@ -0,0 +1,36 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	golangoauth2 "golang.org/x/oauth2"
+)
+
+// TokenSourceMethod acquires tokens from an existing oauth2.TokenSource, for callers that
+// already manage OAuth2 credentials themselves.
+type TokenSourceMethod struct {
+	TS golangoauth2.TokenSource
+}
+
+func (m TokenSourceMethod) Acquire(ctx context.Context) (string, error) {
+	v, _, err := m.AcquireWithExpiry(ctx)
+	return v, err
+}
+
+// AcquireWithExpiry returns the access token of ts.Token() and its expiry (zero when unknown).
+func (m TokenSourceMethod) AcquireWithExpiry(_ context.Context) (string, time.Time, error) {
+	if m.TS == nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: token source is nil")
+	}
+	tok, err := m.TS.Token()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: token source: %w", err)
+	}
+	v, err := normalizeOAuth2Token(tok)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return v, tok.Expiry, nil
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"strings"
 
-	acommon "github.com/loykin/apirun/internal/auth/common"
 	"github.com/loykin/apirun/internal/httpc"
 	"github.com/loykin/apirun/internal/util"
 )
@@ -36,7 +35,7 @@ func AcquirePocketBase(ctx context.Context, pc Config) (string, error) {
 	}
 	loginURL := strings.TrimRight(baseURL, "/") + "/api/admins/auth-with-password"
 	body := map[string]string{"identity": email, "password": password}
-	h := httpc.Httpc{TlsConfig: acommon.GetTLSConfig()}
+	h := httpc.Httpc{Shared: httpc.SharedTransport(ctx)}
 	client := h.New()
 	resp, err := client.R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(body).Post(loginURL)
 	if err != nil {
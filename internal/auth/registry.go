@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/loykin/apirun/internal/auth/basic"
 	acommon "github.com/loykin/apirun/internal/auth/common"
+	"github.com/loykin/apirun/internal/auth/ntlm"
 	"github.com/loykin/apirun/internal/auth/oauth2"
 	"github.com/loykin/apirun/internal/auth/pocketbase"
+	"github.com/loykin/apirun/internal/auth/vault"
 	"github.com/loykin/apirun/internal/common"
 )
 
@@ -21,6 +24,13 @@ type Method interface {
 	Acquire(ctx context.Context) (value string, err error)
 }
 
+// ExpiringMethod is an optional extension of Method for providers that know when the
+// acquired token expires (e.g., OAuth2 expires_in). A zero expiresAt means "unknown".
+// Tokens acquired through it are cached and only re-acquired close to expiry.
+type ExpiringMethod interface {
+	AcquireWithExpiry(ctx context.Context) (value string, expiresAt time.Time, err error)
+}
+
 // Factory builds a Method instance from a loosely-typed spec map.
 // Decoding into a concrete config struct is the typical responsibility of a Factory.
 type Factory func(spec map[string]interface{}) (Method, error)
@@ -47,30 +57,61 @@ func Register(typ string, f Factory) {
 // AcquireAndStoreWithName builds a Method from the provider type and spec and acquires a token.
 // Note: name is no longer required and thus removed from the API since tokens are not stored globally anymore.
 func AcquireAndStoreWithName(ctx context.Context, typ string, spec map[string]interface{}) (string, error) {
+	token, _, err := AcquireWithExpiry(ctx, typ, spec)
+	return token, err
+}
+
+// AcquireWithExpiry builds a Method from the provider type and spec and acquires a token together
+// with its expiry. Providers that don't implement ExpiringMethod report a zero expiry.
+func AcquireWithExpiry(ctx context.Context, typ string, spec map[string]interface{}) (string, time.Time, error) {
 	logger := common.GetLogger().WithComponent("auth-registry")
 	logger.Debug("acquiring authentication token", "provider_type", typ)
 
+	m, err := NewMethod(typ, spec)
+	if err != nil {
+		logger.Error("failed to create auth method", "error", err, "provider_type", typ)
+		return "", time.Time{}, err
+	}
+	return acquireFromMethod(ctx, typ, m)
+}
+
+// NewMethod builds the Method registered for typ from spec without acquiring a token, which
+// checks that the provider exists and that spec decodes.
+func NewMethod(typ string, spec map[string]interface{}) (Method, error) {
 	f, ok := providers[normalizeKey(typ)]
 	if !ok {
-		logger.Error("unsupported auth provider type", "provider_type", typ)
-		return "", fmt.Errorf("auth: unsupported provider type: %s", typ)
+		return nil, fmt.Errorf("auth: unsupported provider type: %s", typ)
 	}
 	m, err := f(spec)
 	if err != nil {
-		logger.Error("failed to create auth method", "error", err, "provider_type", typ)
-		return "", fmt.Errorf("failed to create auth method for provider %q: %w", typ, err)
+		return nil, fmt.Errorf("failed to create auth method for provider %q: %w", typ, err)
 	}
+	return m, nil
+}
+
+// acquireFromMethod acquires a token from m, with its expiry when m implements ExpiringMethod.
+func acquireFromMethod(ctx context.Context, typ string, m Method) (string, time.Time, error) {
+	logger := common.GetLogger().WithComponent("auth-registry")
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	token, err := m.Acquire(ctx)
+	var (
+		token     string
+		expiresAt time.Time
+		err       error
+	)
+	if em, ok := m.(ExpiringMethod); ok {
+		token, expiresAt, err = em.AcquireWithExpiry(ctx)
+	} else {
+		token, err = m.Acquire(ctx)
+	}
 	if err != nil {
 		logger.Error("failed to acquire auth token", "error", err, "provider_type", typ)
-		return "", fmt.Errorf("failed to acquire auth token from provider %q: %w", typ, err)
+		return "", time.Time{}, fmt.Errorf("failed to acquire auth token from provider %q: %w", typ, err)
 	}
 
-	logger.Info("authentication token acquired successfully", "provider_type", typ)
-	return token, nil
+	logger.Info("authentication token acquired successfully", "provider_type", typ, "expires_at", expiresAt)
+	return token, expiresAt, nil
 }
 
 // Built-in provider registrations
@@ -105,4 +146,24 @@ func init() {
 		}
 		return pocketbase.Adapter{C: c}, nil
 	})
+
+	// vault
+	Register(acommon.AuthTypeVault, func(spec map[string]interface{}) (Method, error) {
+		var c vault.Config
+		if err := mapstructure.Decode(spec, &c); err != nil {
+			return nil, fmt.Errorf("failed to decode Vault auth configuration: %w", err)
+		}
+		return vault.Adapter{C: c}, nil
+	})
+
+	// negotiate (NTLM with explicit credentials; "ntlm" is an alias)
+	negotiate := func(spec map[string]interface{}) (Method, error) {
+		var c ntlm.Config
+		if err := mapstructure.Decode(spec, &c); err != nil {
+			return nil, fmt.Errorf("failed to decode negotiate auth configuration: %w", err)
+		}
+		return ntlm.Adapter{C: c}, nil
+	}
+	Register(acommon.AuthTypeNegotiate, negotiate)
+	Register(acommon.AuthTypeNTLM, negotiate)
 }
@@ -3,7 +3,10 @@ package auth
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/loykin/apirun/internal/auth/ntlm"
 )
 
 type testMethod struct {
@@ -163,4 +166,13 @@ func TestRegistry_BuiltinsRegisteredAndBuildable(t *testing.T) {
 	if err != nil || m3 == nil {
 		t.Fatalf("pocketbase factory build failed: m=%#v err=%v", m3, err)
 	}
+
+	// negotiate and its ntlm alias
+	for _, typ := range []string{"negotiate", "ntlm"} {
+		ctx := ntlm.WithCredentials(context.Background(), ntlm.NewCredentials())
+		v, err := AcquireAndStoreWithName(ctx, typ, map[string]interface{}{"username": `CORP\u`, "password": "p"})
+		if err != nil || !strings.HasPrefix(v, ntlm.HandlePrefix) {
+			t.Fatalf("%s acquire failed: v=%q err=%v", typ, v, err)
+		}
+	}
 }
@@ -0,0 +1,9 @@
+package vault
+
+import "context"
+
+type Adapter struct{ C Config }
+
+func (m Adapter) Acquire(ctx context.Context) (string, error) {
+	return AcquireVault(ctx, m.C)
+}
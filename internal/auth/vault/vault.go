@@ -0,0 +1,92 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/loykin/apirun/internal/httpc"
+	"github.com/loykin/apirun/internal/util"
+)
+
+// AuthMethodBearer makes AcquireVault return the secret as "Bearer <value>".
+const AuthMethodBearer = "bearer"
+
+// Config holds configuration for reading a credential from a HashiCorp Vault KV secret.
+type Config struct {
+	// Address of the Vault server, e.g. https://vault:8200 (default: $VAULT_ADDR).
+	Address string `mapstructure:"address"`
+	// Token authenticates the read; ${VAR} references are expanded (default: $VAULT_TOKEN).
+	Token string `mapstructure:"token"`
+	// Path of the secret below /v1/, e.g. secret/data/myapp (KV v2) or secret/myapp (KV v1).
+	Path string `mapstructure:"path"`
+	// Field of the secret whose value is returned.
+	Field string `mapstructure:"field"`
+	// AuthMethod is "" to return the value as-is or "bearer" to prefix it with "Bearer ".
+	AuthMethod string `mapstructure:"auth_method"`
+}
+
+// ToMap returns a spec map for the vault provider.
+func (c Config) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"address":     c.Address,
+		"token":       c.Token,
+		"path":        c.Path,
+		"field":       c.Field,
+		"auth_method": c.AuthMethod,
+	}
+}
+
+// AcquireVault reads the secret at pc.Path and returns the value of pc.Field. Both KV v1 and
+// KV v2 responses are understood.
+func AcquireVault(ctx context.Context, pc Config) (string, error) {
+	address, hasAddress := util.TrimEmptyCheck(os.ExpandEnv(util.TrimWithDefault(pc.Address, "${VAULT_ADDR}")))
+	token, hasToken := util.TrimEmptyCheck(os.ExpandEnv(util.TrimWithDefault(pc.Token, "${VAULT_TOKEN}")))
+	secretPath, hasPath := util.TrimEmptyCheck(pc.Path)
+	field, hasField := util.TrimEmptyCheck(pc.Field)
+	if !hasAddress || !hasToken || !hasPath || !hasField {
+		return "", fmt.Errorf("vault: address, token, path and field are required")
+	}
+	method := util.TrimAndLower(pc.AuthMethod)
+	if method != "" && method != AuthMethodBearer {
+		return "", fmt.Errorf("vault: unsupported auth_method %q (use %q or leave empty)", pc.AuthMethod, AuthMethodBearer)
+	}
+
+	readURL := strings.TrimRight(address, "/") + "/v1/" + strings.TrimLeft(secretPath, "/")
+	h := httpc.Httpc{Shared: httpc.SharedTransport(ctx)}
+	client := h.New()
+	resp, err := client.R().SetContext(ctx).SetHeader("X-Vault-Token", token).Get(readURL)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode() < 200 || resp.StatusCode() >= 300 {
+		return "", fmt.Errorf("vault: reading %s returned %d", secretPath, resp.StatusCode())
+	}
+	var sr struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body(), &sr); err != nil {
+		return "", fmt.Errorf("vault: invalid JSON response: %w", err)
+	}
+	data := sr.Data
+	// KV v2 nests the secret under data.data next to data.metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, v2 := data["metadata"]; v2 {
+			data = inner
+		}
+	}
+	raw, ok := data[field]
+	if !ok || raw == nil {
+		return "", fmt.Errorf("vault: field %q not found in secret %s", field, secretPath)
+	}
+	value, ok := raw.(string)
+	if !ok {
+		value = fmt.Sprint(raw)
+	}
+	if method == AuthMethodBearer {
+		return "Bearer " + value, nil
+	}
+	return value, nil
+}
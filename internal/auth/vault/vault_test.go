@@ -0,0 +1,97 @@
+package vault_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/auth"
+	"github.com/loykin/apirun/internal/auth/vault"
+)
+
+// vaultServer serves a KV v2 secret at secret/data/app and a KV v1 secret at kv/app,
+// both only for the token "s.root".
+func vaultServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.root" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			_, _ = w.Write([]byte(`{"data":{"data":{"api_key":"k-v2","port":8080},"metadata":{"version":3}}}`))
+		case "/v1/kv/app":
+			_, _ = w.Write([]byte(`{"data":{"api_key":"k-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVault_ReadsKVSecrets(t *testing.T) {
+	srv := vaultServer(t)
+	t.Setenv("APIRUN_TEST_VAULT_TOKEN", "s.root")
+	cases := []struct {
+		name string
+		cfg  vault.Config
+		want string
+	}{
+		{"kv v2", vault.Config{Address: srv.URL, Token: "s.root", Path: "secret/data/app", Field: "api_key"}, "k-v2"},
+		{"kv v1 as bearer", vault.Config{Address: srv.URL, Token: "s.root", Path: "/kv/app", Field: "api_key", AuthMethod: "Bearer"}, "Bearer k-v1"},
+		{"token from env", vault.Config{Address: srv.URL + "/", Token: "${APIRUN_TEST_VAULT_TOKEN}", Path: "secret/data/app", Field: "api_key"}, "k-v2"},
+		{"non-string field", vault.Config{Address: srv.URL, Token: "s.root", Path: "secret/data/app", Field: "port"}, "8080"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := auth.AcquireAndStoreWithName(context.Background(), "vault", tc.cfg.ToMap())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v != tc.want {
+				t.Fatalf("got %q, want %q", v, tc.want)
+			}
+		})
+	}
+}
+
+func TestVault_DefaultsFromEnv(t *testing.T) {
+	srv := vaultServer(t)
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "s.root")
+	v, err := vault.AcquireVault(context.Background(), vault.Config{Path: "kv/app", Field: "api_key"})
+	if err != nil || v != "k-v1" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+}
+
+func TestVault_Errors(t *testing.T) {
+	srv := vaultServer(t)
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+	cases := map[string]struct {
+		cfg  vault.Config
+		want string
+	}{
+		"missing token":  {vault.Config{Address: srv.URL, Path: "kv/app", Field: "api_key"}, "required"},
+		"denied":         {vault.Config{Address: srv.URL, Token: "s.other", Path: "kv/app", Field: "api_key"}, "returned 403"},
+		"missing secret": {vault.Config{Address: srv.URL, Token: "s.root", Path: "kv/none", Field: "api_key"}, "returned 404"},
+		"missing field":  {vault.Config{Address: srv.URL, Token: "s.root", Path: "kv/app", Field: "password"}, `field "password" not found`},
+		"bad method":     {vault.Config{Address: srv.URL, Token: "s.root", Path: "kv/app", Field: "api_key", AuthMethod: "basic"}, "unsupported auth_method"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := vault.AcquireVault(context.Background(), tc.cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
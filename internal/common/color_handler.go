@@ -33,6 +33,9 @@ type ColorHandler struct {
 	groups   []string
 	masker   *Masker
 	useColor bool
+	// timeFormat is the layout of record timestamps (empty = RFC 3339); utc converts them.
+	timeFormat string
+	utc        bool
 }
 
 // NewColorHandler creates a new color handler
@@ -98,7 +101,15 @@ func (h *ColorHandler) Handle(_ context.Context, r slog.Record) error {
 	// Format timestamp with fixed width (25 chars for RFC3339 + timezone)
 	timestampStr := ""
 	if !r.Time.IsZero() {
-		timestampStr = r.Time.Format(time.RFC3339)
+		t := r.Time
+		if h.utc {
+			t = t.UTC()
+		}
+		layout := h.timeFormat
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		timestampStr = t.Format(layout)
 	}
 	buf = append(buf, h.colorize(Gray, fmt.Sprintf("%-25s", timestampStr))...)
 	buf = append(buf, " "...) // Add space after timestamp
@@ -292,24 +303,28 @@ func (h *ColorHandler) maskAttributes(attrs []slog.Attr) []slog.Attr {
 // WithAttrs returns a new ColorHandler with the given attributes added
 func (h *ColorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &ColorHandler{
-		opts:     h.opts,
-		writer:   h.writer,
-		attrs:    append(h.attrs, attrs...),
-		groups:   h.groups,
-		masker:   h.masker,
-		useColor: h.useColor,
+		opts:       h.opts,
+		writer:     h.writer,
+		attrs:      append(h.attrs, attrs...),
+		groups:     h.groups,
+		masker:     h.masker,
+		useColor:   h.useColor,
+		timeFormat: h.timeFormat,
+		utc:        h.utc,
 	}
 }
 
 // WithGroup returns a new ColorHandler with the given group name added
 func (h *ColorHandler) WithGroup(name string) slog.Handler {
 	return &ColorHandler{
-		opts:     h.opts,
-		writer:   h.writer,
-		attrs:    h.attrs,
-		groups:   append(h.groups, name),
-		masker:   h.masker,
-		useColor: h.useColor,
+		opts:       h.opts,
+		writer:     h.writer,
+		attrs:      h.attrs,
+		groups:     append(h.groups, name),
+		masker:     h.masker,
+		useColor:   h.useColor,
+		timeFormat: h.timeFormat,
+		utc:        h.utc,
 	}
 }
 
@@ -318,6 +333,13 @@ func (h *ColorHandler) SetMasker(masker *Masker) {
 	h.masker = masker
 }
 
+// SetTimeFormat sets the layout of record timestamps (empty = RFC 3339) and whether they are
+// converted to UTC.
+func (h *ColorHandler) SetTimeFormat(layout string, utc bool) {
+	h.timeFormat = layout
+	h.utc = utc
+}
+
 // SetColorEnabled enables or disables colors
 func (h *ColorHandler) SetColorEnabled(enabled bool) {
 	h.useColor = enabled
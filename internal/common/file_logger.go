@@ -0,0 +1,199 @@
+package common
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxLogSize is used when FileLogOptions.MaxSize is not set (100 MB).
+const defaultMaxLogSize int64 = 100 * 1024 * 1024
+
+// backupTimeFormat is appended to rotated file names; it sorts lexically in time order.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// FileLogOptions controls where and how a file logger rotates its output.
+type FileLogOptions struct {
+	// MaxSize is the size in bytes after which the file is rotated (0 = 100 MB).
+	MaxSize int64
+	// MaxBackups is the number of rotated files to keep (0 = keep all).
+	MaxBackups int
+	// MaxAge removes rotated files older than this duration (0 = no age limit).
+	MaxAge time.Duration
+	// JSON writes JSON lines instead of text records.
+	JSON bool
+	// TimeFormat and UTC format record timestamps as for LogOptions.
+	TimeFormat string
+	UTC        bool
+}
+
+// NewFileLogger creates a structured logger that appends to path and rotates it
+// according to opts. Masking is enabled the same way as for the stdout loggers.
+func NewFileLogger(level LogLevel, path string, opts FileLogOptions) (*Logger, error) {
+	w, err := newRotatingFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	hopts := &slog.HandlerOptions{
+		Level:       level.ToSlogLevel(),
+		ReplaceAttr: timeAttrReplacer(opts.TimeFormat, opts.UTC),
+	}
+
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, hopts)
+	} else {
+		handler = slog.NewTextHandler(w, hopts)
+	}
+
+	return &Logger{
+		Logger: slog.New(handler),
+		level:  level,
+		masker: NewMasker(),
+		closer: w,
+	}, nil
+}
+
+// rotatingFile is an io.WriteCloser that rotates the underlying file once it grows past maxSize.
+// Writes are serialized, so a single instance can be shared by concurrent loggers.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, opts FileLogOptions) (*rotatingFile, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("log file path is required")
+	}
+	if opts.MaxSize < 0 || opts.MaxBackups < 0 || opts.MaxAge < 0 {
+		return nil, fmt.Errorf("log file rotation limits must not be negative")
+	}
+	r := &rotatingFile{path: path, maxSize: opts.MaxSize, maxBackups: opts.MaxBackups, maxAge: opts.MaxAge}
+	if r.maxSize == 0 {
+		r.maxSize = defaultMaxLogSize
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when p would push the file past maxSize.
+// A single record larger than maxSize is still written whole to a fresh file.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file; further writes fail.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	r.file = nil
+	backup := r.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		// Keep logging to the original path, so that one failed rotation does not close the log
+		if oerr := r.open(); oerr != nil {
+			return oerr
+		}
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune removes rotated files beyond maxBackups or older than maxAge. Errors are ignored so
+// that a stale backup never stops logging.
+func (r *rotatingFile) prune() {
+	if r.maxBackups == 0 && r.maxAge == 0 {
+		return
+	}
+	backups := r.backups()
+	// Newest first: the timestamp suffix sorts lexically in time order.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, b := range backups {
+		remove := r.maxBackups > 0 && i >= r.maxBackups
+		if !remove && r.maxAge > 0 {
+			if info, err := os.Stat(b); err == nil && info.ModTime().Before(cutoff) {
+				remove = true
+			}
+		}
+		if remove {
+			_ = os.Remove(b)
+		}
+	}
+}
+
+// backups lists rotated files of r.path, recognised by their timestamp suffix.
+func (r *rotatingFile) backups() []string {
+	dir, base := filepath.Split(r.path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), base+".")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			out = append(out, filepath.Join(dir, e.Name()))
+		}
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewFileLogger_WritesAndMasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "apirun.log")
+	logger, err := NewFileLogger(LogLevelInfo, path, FileLogOptions{JSON: true})
+	if err != nil {
+		t.Fatalf("NewFileLogger: %v", err)
+	}
+	logger.WithComponent("test").Info("login", "user", "alice", "password", "s3cret")
+	logger.Debug("hidden")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one record, got %q", data)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("expected JSON record: %v", err)
+	}
+	if rec["component"] != "test" || rec["user"] != "alice" {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+	if strings.Contains(lines[0], "s3cret") {
+		t.Fatalf("password was not masked: %s", lines[0])
+	}
+}
+
+func TestNewFileLogger_InvalidOptions(t *testing.T) {
+	if _, err := NewFileLogger(LogLevelInfo, " ", FileLogOptions{}); err == nil {
+		t.Fatal("expected error for empty path")
+	}
+	path := filepath.Join(t.TempDir(), "a.log")
+	if _, err := NewFileLogger(LogLevelInfo, path, FileLogOptions{MaxBackups: -1}); err == nil {
+		t.Fatal("expected error for negative max backups")
+	}
+}
+
+func TestRotatingFile_RotatesAndKeepsMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := newRotatingFile(path, FileLogOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("newRotatingFile: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	if got := len(w.backups()); got != 2 {
+		t.Fatalf("expected 2 backups, got %d", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != 10 {
+		t.Fatalf("expected current file to hold the last record, got %v %v", info, err)
+	}
+}
+
+// A failed rotation reports the error and keeps writing to the original path
+func TestRotatingFile_ReopensAfterFailedRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := newRotatingFile(path, FileLogOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatalf("newRotatingFile: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if _, err := w.Write([]byte("0123456789")); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	// Renaming a file that is gone fails
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("lost")); err == nil || !strings.Contains(err.Error(), "rotate log file") {
+		t.Fatalf("expected the rotation error, got %v", err)
+	}
+	if _, err := w.Write([]byte("kept")); err != nil {
+		t.Fatalf("write after a failed rotation: %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "kept" {
+		t.Fatalf("expected the log to be reopened at %s, got %q %v", path, b, err)
+	}
+}
+
+func TestRotatingFile_PrunesByMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	stale := path + "." + time.Now().Add(-48*time.Hour).Format(backupTimeFormat)
+	if err := os.WriteFile(stale, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unrelated := filepath.Join(dir, "app.log.keep")
+	if err := os.WriteFile(unrelated, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := newRotatingFile(path, FileLogOptions{MaxSize: 4, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("newRotatingFile: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+	_, _ = w.Write([]byte("abcd"))
+	_, _ = w.Write([]byte("efgh"))
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale backup to be removed, got %v", err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Fatalf("unrelated file must be kept: %v", err)
+	}
+	if got := len(w.backups()); got != 1 {
+		t.Fatalf("expected the fresh backup to remain, got %d", got)
+	}
+}
+
+func TestFileLogger_ConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	logger, err := NewFileLogger(LogLevelInfo, path, FileLogOptions{MaxSize: 2048, JSON: true})
+	if err != nil {
+		t.Fatalf("NewFileLogger: %v", err)
+	}
+
+	const goroutines, perGoroutine = 10, 50
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			l := logger.WithVersion(id)
+			for j := 0; j < perGoroutine; j++ {
+				l.Info("concurrent message", "n", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, _ := filepath.Glob(path + "*")
+	total := 0
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("read %s: %v", f, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("interleaved record in %s: %q", f, line)
+			}
+			total++
+		}
+	}
+	if total != goroutines*perGoroutine {
+		t.Fatalf("expected %d records, got %d", goroutines*perGoroutine, total)
+	}
+}
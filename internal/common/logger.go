@@ -1,6 +1,7 @@
 package common
 
 import (
+	"io"
 	"log/slog"
 	"os"
 	"sync"
@@ -53,59 +54,98 @@ type Logger struct {
 	*slog.Logger
 	level  LogLevel
 	masker *Masker
+	// closer releases the output of file loggers; nil for stdout loggers.
+	closer io.Closer
+}
+
+// LogOptions configures a logger created by NewLoggerWithOptions.
+type LogOptions struct {
+	// Format is the output format: "text" (default), "json" or "color".
+	Format string
+	// TimeFormat is the Go time layout of record timestamps, e.g. "2006-01-02 15:04:05.000"
+	// (empty = the handler default, RFC 3339).
+	TimeFormat string
+	// UTC converts record timestamps to UTC instead of the local time zone.
+	UTC bool
+	// Output receives the log records (nil = stdout).
+	Output io.Writer
 }
 
 // NewLogger creates a new structured logger with the specified level
 func NewLogger(level LogLevel) *Logger {
-	opts := &slog.HandlerOptions{
-		Level: level.ToSlogLevel(),
-	}
-
-	handler := slog.NewTextHandler(os.Stdout, opts)
-	logger := slog.New(handler)
-
-	return &Logger{
-		Logger: logger,
-		level:  level,
-		masker: NewMasker(),
-	}
+	return NewLoggerWithOptions(level, LogOptions{})
 }
 
 // NewJSONLogger creates a structured logger with JSON output
 func NewJSONLogger(level LogLevel) *Logger {
-	opts := &slog.HandlerOptions{
-		Level: level.ToSlogLevel(),
-	}
-
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	logger := slog.New(handler)
-
-	return &Logger{
-		Logger: logger,
-		level:  level,
-		masker: NewMasker(),
-	}
+	return NewLoggerWithOptions(level, LogOptions{Format: "json"})
 }
 
 // NewColorLogger creates a structured logger with colorized output
 func NewColorLogger(level LogLevel) *Logger {
-	opts := &slog.HandlerOptions{
+	return NewLoggerWithOptions(level, LogOptions{Format: "color"})
+}
+
+// NewLoggerWithOptions creates a structured logger with the format, timestamps and output of
+// opts. An unknown format falls back to text.
+func NewLoggerWithOptions(level LogLevel, opts LogOptions) *Logger {
+	w := opts.Output
+	if w == nil {
+		w = os.Stdout
+	}
+	hopts := &slog.HandlerOptions{
 		Level: level.ToSlogLevel(),
 	}
 
-	handler := NewColorHandler(os.Stdout, opts)
-	logger := slog.New(handler)
-
-	colorLogger := &Logger{
-		Logger: logger,
+	l := &Logger{
 		level:  level,
 		masker: NewMasker(),
 	}
+	switch opts.Format {
+	case "json":
+		hopts.ReplaceAttr = timeAttrReplacer(opts.TimeFormat, opts.UTC)
+		l.Logger = slog.New(slog.NewJSONHandler(w, hopts))
+	case "color":
+		handler := NewColorHandler(w, hopts)
+		handler.SetTimeFormat(opts.TimeFormat, opts.UTC)
+		// Set masker on the color handler
+		handler.SetMasker(l.masker)
+		l.Logger = slog.New(handler)
+	default:
+		hopts.ReplaceAttr = timeAttrReplacer(opts.TimeFormat, opts.UTC)
+		l.Logger = slog.New(slog.NewTextHandler(w, hopts))
+	}
+	return l
+}
 
-	// Set masker on the color handler
-	handler.SetMasker(colorLogger.masker)
+// timeAttrReplacer returns a slog ReplaceAttr function that formats the record time with
+// layout and converts it to UTC when utc is set; nil when both are unset.
+func timeAttrReplacer(layout string, utc bool) func([]string, slog.Attr) slog.Attr {
+	if layout == "" && !utc {
+		return nil
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 || a.Key != slog.TimeKey || a.Value.Kind() != slog.KindTime {
+			return a
+		}
+		t := a.Value.Time()
+		if utc {
+			t = t.UTC()
+		}
+		if layout == "" {
+			return slog.Time(a.Key, t)
+		}
+		return slog.String(a.Key, t.Format(layout))
+	}
+}
 
-	return colorLogger
+// Close releases the log file of a logger created by NewFileLogger. It is a no-op for
+// stdout loggers and for loggers derived with the With* helpers.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
 }
 
 // Level returns the current log level
@@ -203,6 +243,18 @@ func (l *Logger) WithStore(storeType string) *Logger {
 	}
 }
 
+// WithRunID returns a logger with migration run context; an empty id adds nothing.
+func (l *Logger) WithRunID(runID string) *Logger {
+	if runID == "" {
+		return l
+	}
+	return &Logger{
+		Logger: l.Logger.With("run_id", runID),
+		level:  l.level,
+		masker: l.masker,
+	}
+}
+
 // WithRequest returns a logger with HTTP request context
 func (l *Logger) WithRequest(method, url string) *Logger {
 	return &Logger{
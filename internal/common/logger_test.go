@@ -2,9 +2,12 @@ package common
 
 import (
 	"bytes"
+	"encoding/json"
 	"log/slog"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewLogger(t *testing.T) {
@@ -121,3 +124,39 @@ func TestLogFunctions(t *testing.T) {
 		t.Error("expected error message in output")
 	}
 }
+
+func TestNewLoggerWithOptions_TimeFormat(t *testing.T) {
+	const layout = "2006-01-02T15:04:05.000Z07:00"
+	stamp := regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z`)
+	for _, format := range []string{"text", "json", "color"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := NewLoggerWithOptions(LogLevelInfo, LogOptions{Format: format, TimeFormat: layout, UTC: true, Output: &buf})
+			logger.Debug("hidden")
+			logger.Info("hello", "password", "s3cret")
+
+			out := buf.String()
+			if strings.Contains(out, "hidden") {
+				t.Fatalf("debug record logged at info level: %s", out)
+			}
+			if !stamp.MatchString(out) {
+				t.Fatalf("timestamp not in %q UTC format: %s", layout, out)
+			}
+			if strings.Contains(out, "s3cret") {
+				t.Fatalf("password not masked: %s", out)
+			}
+		})
+	}
+}
+
+func TestNewLoggerWithOptions_DefaultTime(t *testing.T) {
+	var buf bytes.Buffer
+	NewLoggerWithOptions(LogLevelInfo, LogOptions{Format: "json", Output: &buf}).Info("hello")
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, rec["time"].(string)); err != nil {
+		t.Fatalf("default timestamp %v is not RFC 3339: %v", rec["time"], err)
+	}
+}
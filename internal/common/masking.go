@@ -3,8 +3,10 @@ package common
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // SensitivePattern represents a pattern to detect and mask sensitive information
@@ -116,7 +118,7 @@ func (m *Masker) MaskString(input string) string {
 		return input
 	}
 
-	result := input
+	result := maskRegisteredSecrets(input)
 	for _, pattern := range m.patterns {
 		result = pattern.Regex.ReplaceAllString(result, pattern.Replacement)
 	}
@@ -225,6 +227,42 @@ func MaskSensitiveData(input string) string {
 	return globalMasker.MaskString(input)
 }
 
+// registeredSecrets holds literal secret values (e.g. resolved by template functions).
+// They are masked by every enabled Masker, including the ones owned by loggers.
+var registeredSecrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// RegisterSecret registers a literal value that is replaced with ***MASKED*** wherever it appears.
+// Empty and already registered values are ignored.
+func RegisterSecret(value string) {
+	if value == "" {
+		return
+	}
+	registeredSecrets.mu.Lock()
+	defer registeredSecrets.mu.Unlock()
+	for _, v := range registeredSecrets.values {
+		if v == value {
+			return
+		}
+	}
+	registeredSecrets.values = append(registeredSecrets.values, value)
+	// Replace longer secrets first so a secret containing another one is masked as a whole
+	sort.SliceStable(registeredSecrets.values, func(i, j int) bool {
+		return len(registeredSecrets.values[i]) > len(registeredSecrets.values[j])
+	})
+}
+
+func maskRegisteredSecrets(input string) string {
+	registeredSecrets.mu.RLock()
+	defer registeredSecrets.mu.RUnlock()
+	for _, v := range registeredSecrets.values {
+		input = strings.ReplaceAll(input, v, "***MASKED***")
+	}
+	return input
+}
+
 // EnableMasking enables/disables global masking
 func EnableMasking(enabled bool) {
 	globalMasker.SetEnabled(enabled)
@@ -49,6 +49,14 @@ const (
 	DefaultWaitInterval = 2 * time.Second
 	DefaultWaitStatus   = http.StatusOK // Use standard library constant
 	DefaultWaitMethod   = "GET"
+
+	// Wait backoff defaults (wait.backoff)
+	DefaultWaitBackoffMax        = 30 * time.Second
+	DefaultWaitBackoffMultiplier = 2.0
+
+	// Wait types
+	WaitTypeHTTP = "http"
+	WaitTypeGRPC = "grpc"
 )
 
 // HTTP Client Pool Constants - optimized for API migration workloads
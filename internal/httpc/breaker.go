@@ -0,0 +1,166 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+// ErrCircuitOpen is returned, wrapped, for requests to a host whose circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreakerConfig configures failing fast for hosts that keep failing. Zero-valued fields
+// fall back to the defaults.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests to a host that opens its
+	// circuit (default 5). Network errors and 5xx responses count as failures.
+	FailureThreshold int `yaml:"failure_threshold" mapstructure:"failure_threshold"`
+	// Window only counts failures within this period of the first one of a streak (0 = no limit).
+	Window time.Duration `yaml:"window" mapstructure:"window"`
+	// Cooldown is how long an open circuit fails requests fast before a single trial request is
+	// let through (default 30s). The circuit closes when the trial succeeds and reopens otherwise.
+	Cooldown time.Duration `yaml:"cooldown" mapstructure:"cooldown"`
+}
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// CircuitBreaker tracks consecutive request failures per host. Share one value between the
+// clients whose requests should trip the same circuits.
+type CircuitBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+}
+
+// hostCircuit is the state of one host: closed while openUntil is zero.
+type hostCircuit struct {
+	failures int
+	since    time.Time // first failure of the current streak
+	// openUntil is when the cooldown ends; after it one trial request is let through
+	openUntil time.Time
+	trial     bool
+}
+
+// NewCircuitBreaker returns a breaker with all circuits closed.
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	b := &CircuitBreaker{
+		threshold: cfg.FailureThreshold,
+		window:    cfg.Window,
+		cooldown:  cfg.Cooldown,
+		now:       time.Now,
+		hosts:     make(map[string]*hostCircuit),
+	}
+	if b.threshold <= 0 {
+		b.threshold = defaultBreakerFailureThreshold
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultBreakerCooldown
+	}
+	return b
+}
+
+// WrapTransport returns a RoundTripper that fails fast with ErrCircuitOpen for hosts whose
+// circuit is open and records the outcome of every request it lets through.
+func (b *CircuitBreaker) WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return breakerTransport{breaker: b, next: next}
+}
+
+type breakerTransport struct {
+	breaker *CircuitBreaker
+	next    http.RoundTripper
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.breaker.allow(host); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		// A cancelled or timed out caller says nothing about the host
+		t.breaker.release(host)
+		return resp, err
+	}
+	t.breaker.record(host, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
+
+// allow returns an error when requests to host must fail fast. Once the cooldown has passed it
+// lets a single trial request through.
+func (b *CircuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.hosts[host]
+	if c == nil || c.openUntil.IsZero() {
+		return nil
+	}
+	if wait := c.openUntil.Sub(b.now()); wait > 0 {
+		return fmt.Errorf("%w for %s after %d consecutive failures, retry in %s", ErrCircuitOpen, host, c.failures, wait.Round(time.Millisecond))
+	}
+	if c.trial {
+		return fmt.Errorf("%w for %s, a trial request is in progress", ErrCircuitOpen, host)
+	}
+	c.trial = true
+	return nil
+}
+
+// release forgets a trial request that ended without an outcome.
+func (b *CircuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.hosts[host]; c != nil {
+		c.trial = false
+	}
+}
+
+// record updates the circuit of host with the outcome of a request.
+func (b *CircuitBreaker) record(host string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	logger := common.GetLogger().WithComponent("httpc")
+	c := b.hosts[host]
+	if !failed {
+		if c != nil && !c.openUntil.IsZero() {
+			logger.Info("circuit breaker closed", "host", host)
+		}
+		delete(b.hosts, host)
+		return
+	}
+	now := b.now()
+	if c == nil {
+		c = &hostCircuit{}
+		b.hosts[host] = c
+	}
+	if c.trial {
+		c.trial = false
+		c.failures++
+		c.openUntil = now.Add(b.cooldown)
+		logger.Warn("circuit breaker trial request failed, reopening", "host", host, "cooldown", b.cooldown)
+		return
+	}
+	if c.failures > 0 && b.window > 0 && now.Sub(c.since) > b.window {
+		c.failures = 0
+	}
+	if c.failures == 0 {
+		c.since = now
+	}
+	c.failures++
+	if c.failures >= b.threshold && c.openUntil.IsZero() {
+		c.openUntil = now.Add(b.cooldown)
+		logger.Warn("circuit breaker opened", "host", host, "consecutive_failures", c.failures, "cooldown", b.cooldown)
+	}
+}
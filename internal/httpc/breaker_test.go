@@ -0,0 +1,163 @@
+package httpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// flakyServer answers 503 while down is set and counts the requests that reached it.
+type flakyServer struct {
+	calls atomic.Int32
+	down  atomic.Bool
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.calls.Add(1)
+	if s.down.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// fakeClock is a settable time source for the breaker.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newBreakerClient(b *CircuitBreaker) *resty.Client {
+	c := (&Httpc{Retry: &RetryPolicy{MaxAttempts: 1}}).New()
+	c.SetTransport(b.WrapTransport(c.GetClient().Transport))
+	return c
+}
+
+func TestCircuitBreaker_TripsAndRecovers(t *testing.T) {
+	srv := &flakyServer{}
+	srv.down.Store(true)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	clock := &fakeClock{t: time.Now()}
+	b := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, Cooldown: time.Minute})
+	b.now = clock.now
+	c := newBreakerClient(b)
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.R().Get(ts.URL)
+		if err != nil || resp.StatusCode() != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: expected 503, got resp=%v err=%v", i, resp, err)
+		}
+	}
+
+	// Open: fail fast without reaching the server
+	if _, err := c.R().Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if got := srv.calls.Load(); got != 3 {
+		t.Fatalf("open circuit must not reach the server, got %d calls", got)
+	}
+
+	// A failed trial after the cooldown reopens the circuit
+	clock.advance(time.Minute)
+	if resp, err := c.R().Get(ts.URL); err != nil || resp.StatusCode() != http.StatusServiceUnavailable {
+		t.Fatalf("expected the trial request to reach the server, got resp=%v err=%v", resp, err)
+	}
+	if _, err := c.R().Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the circuit to reopen, got %v", err)
+	}
+
+	// A successful trial closes it
+	srv.down.Store(false)
+	clock.advance(time.Minute)
+	for i := 0; i < 2; i++ {
+		if resp, err := c.R().Get(ts.URL); err != nil || resp.StatusCode() != http.StatusOK {
+			t.Fatalf("request %d after recovery: resp=%v err=%v", i, resp, err)
+		}
+	}
+	if got := srv.calls.Load(); got != 6 {
+		t.Fatalf("expected 6 calls to reach the server, got %d", got)
+	}
+}
+
+func TestCircuitBreaker_WindowResetsFailures(t *testing.T) {
+	srv := &flakyServer{}
+	srv.down.Store(true)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	clock := &fakeClock{t: time.Now()}
+	b := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, Window: time.Second})
+	b.now = clock.now
+	c := newBreakerClient(b)
+
+	// Failures further apart than the window never trip the breaker
+	for i := 0; i < 4; i++ {
+		if _, err := c.R().Get(ts.URL); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		clock.advance(2 * time.Second)
+	}
+	if got := srv.calls.Load(); got != 4 {
+		t.Fatalf("expected every request to reach the server, got %d", got)
+	}
+
+	// Two within the window do
+	_, _ = c.R().Get(ts.URL)
+	_, _ = c.R().Get(ts.URL)
+	if _, err := c.R().Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsStreakAndHostsAreIndependent(t *testing.T) {
+	bad := &flakyServer{}
+	bad.down.Store(true)
+	tsBad := httptest.NewServer(bad)
+	defer tsBad.Close()
+	good := &flakyServer{}
+	tsGood := httptest.NewServer(good)
+	defer tsGood.Close()
+
+	b := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2})
+	c := newBreakerClient(b)
+
+	_, _ = c.R().Get(tsBad.URL)
+	bad.down.Store(false)
+	_, _ = c.R().Get(tsBad.URL)
+	bad.down.Store(true)
+	if _, err := c.R().Get(tsBad.URL); err != nil {
+		t.Fatalf("a success must reset the failure streak, got %v", err)
+	}
+	_, _ = c.R().Get(tsBad.URL)
+	if _, err := c.R().Get(tsBad.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if resp, err := c.R().Get(tsGood.URL); err != nil || resp.StatusCode() != http.StatusOK {
+		t.Fatalf("another host must not be affected, got resp=%v err=%v", resp, err)
+	}
+}
+
+func TestCircuitBreaker_NotRetried(t *testing.T) {
+	srv := &flakyServer{}
+	srv.down.Store(true)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	b := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2})
+	c := (&Httpc{Retry: &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}).New()
+	c.SetTransport(b.WrapTransport(c.GetClient().Transport))
+
+	if _, err := c.R().Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected retries to stop at the open circuit, got %v", err)
+	}
+	if got := srv.calls.Load(); got != 2 {
+		t.Fatalf("expected 2 calls before the circuit opened, got %d", got)
+	}
+}
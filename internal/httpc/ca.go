@@ -0,0 +1,41 @@
+package httpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// AppendCAFile returns a copy of pool (a new pool when nil) with the PEM certificates of file
+// added. It fails when the file cannot be read or holds no certificate.
+func AppendCAFile(pool *x509.CertPool, file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file) // #nosec G304 -- the CA file path comes from the operator's configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %q: %w", file, err)
+	}
+	if pool == nil {
+		pool = x509.NewCertPool()
+	} else {
+		pool = pool.Clone()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA file %q contains no PEM certificates", file)
+	}
+	return pool, nil
+}
+
+// WithRootCAs returns cfg verifying server certificates against pool instead of the system
+// roots. cfg is cloned, not modified; a nil pool returns cfg as is.
+func WithRootCAs(cfg *tls.Config, pool *x509.CertPool) *tls.Config {
+	if pool == nil {
+		return cfg
+	}
+	if cfg == nil {
+		cfg = &tls.Config{} // #nosec G402 -- the TLS versions keep Go's defaults
+	} else {
+		cfg = cfg.Clone()
+	}
+	cfg.RootCAs = pool
+	return cfg
+}
@@ -0,0 +1,64 @@
+package httpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeServerCA writes the self-signed certificate of srv as a PEM CA file.
+func writeServerCA(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	return p
+}
+
+func TestHTTPClient_RootCAs_TrustsPrivateCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	noRetry := &RetryPolicy{MaxAttempts: 1}
+
+	if _, err := doGet(t, context.Background(), srv.URL, &Httpc{Retry: noRetry}); err == nil {
+		t.Fatalf("expected an unknown authority error without the CA")
+	}
+
+	pool, err := AppendCAFile(nil, writeServerCA(t, srv))
+	if err != nil {
+		t.Fatalf("AppendCAFile: %v", err)
+	}
+	// The TLS settings are kept; only the roots change
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	status, err := doGet(t, context.Background(), srv.URL, &Httpc{TlsConfig: base, RootCAs: pool, Retry: noRetry})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("GET with CA: %d, %v", status, err)
+	}
+	if base.RootCAs != nil {
+		t.Fatalf("WithRootCAs modified the shared TLS config")
+	}
+}
+
+func TestAppendCAFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := AppendCAFile(nil, filepath.Join(dir, "missing.pem")); err == nil || !strings.Contains(err.Error(), "failed to read CA file") {
+		t.Fatalf("missing file: %v", err)
+	}
+	bad := filepath.Join(dir, "bad.pem")
+	if err := os.WriteFile(bad, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AppendCAFile(nil, bad); err == nil || !strings.Contains(err.Error(), "contains no PEM certificates") {
+		t.Fatalf("malformed file: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+)
+
+type sharedTransportKey struct{}
+
+// WithSharedTransport returns a context whose HTTP clients send through transport (see
+// Httpc.Shared), so that the requests of one run reuse its keep-alive connections.
+func WithSharedTransport(ctx context.Context, transport *http.Transport) context.Context {
+	if transport == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sharedTransportKey{}, transport)
+}
+
+// SharedTransport returns the transport attached by WithSharedTransport, or nil.
+func SharedTransport(ctx context.Context) *http.Transport {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(sharedTransportKey{}).(*http.Transport)
+	return t
+}
@@ -2,6 +2,7 @@ package httpc
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"net/http"
 	"time"
@@ -13,17 +14,51 @@ import (
 
 type Httpc struct {
 	TlsConfig *tls.Config
+	// RootCAs, when set, replaces the system roots that server certificates are verified against
+	// (see AppendCAFile).
+	RootCAs *x509.CertPool
+	// Retry overrides the default retry policy for transient failures (nil = DefaultRetryPolicy).
+	Retry *RetryPolicy
+	// Transport tunes the underlying http.Transport (nil = built-in defaults).
+	Transport *TransportConfig
+	// Shared, when set, is used as the client's transport instead of building one, so that
+	// clients share its connection pool. TlsConfig, RootCAs and Transport are then ignored.
+	Shared *http.Transport
 }
 
-// New returns a resty.Client configured according to the receiver's TLS settings.
-// Note: when MinVersion/MaxVersion are zero, Go's http defaults are used (no forced TLS1.3).
-func (h *Httpc) New() *resty.Client {
-	logger := common.GetLogger().WithComponent("httpc")
-	logger.Debug("creating new HTTP client")
+// TransportConfig tunes the http.Transport of a client. Zero-valued fields keep the defaults.
+type TransportConfig struct {
+	// ForceAttemptHTTP2 negotiates HTTP/2 over TLS even though the transport has a custom
+	// dialer and TLS config, which otherwise leave it on HTTP/1.1.
+	ForceAttemptHTTP2 bool `yaml:"force_attempt_http2" mapstructure:"force_attempt_http2"`
+	// MaxIdleConns caps idle (keep-alive) connections across all hosts.
+	MaxIdleConns int `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`
+	// IdleConnTimeout closes idle connections after this long.
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout" mapstructure:"idle_conn_timeout"`
+	// DisableKeepAlives uses a new connection for every request.
+	DisableKeepAlives bool `yaml:"disable_keep_alives" mapstructure:"disable_keep_alives"`
+}
 
-	c := resty.New()
+// apply copies the configured fields onto transport. A nil receiver leaves it unchanged.
+func (t *TransportConfig) apply(transport *http.Transport) {
+	if t == nil {
+		return
+	}
+	transport.ForceAttemptHTTP2 = t.ForceAttemptHTTP2
+	if t.MaxIdleConns > 0 {
+		transport.MaxIdleConns = t.MaxIdleConns
+	}
+	if t.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = t.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = t.DisableKeepAlives
+}
 
-	// Configure optimized HTTP transport with connection pooling
+// NewTransport returns an http.Transport with pooled keep-alive connections, tuned by Transport
+// and using the receiver's TLS settings.
+// Note: when MinVersion/MaxVersion are zero, Go's http defaults are used (no forced TLS1.3).
+func (h *Httpc) NewTransport() *http.Transport {
+	logger := common.GetLogger().WithComponent("httpc")
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   constants.DefaultHTTPDialTimeout,
@@ -37,39 +72,66 @@ func (h *Httpc) New() *resty.Client {
 		DisableCompression:    false, // Enable compression for efficiency
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	h.Transport.apply(transport)
 
-	// Set the optimized transport and request timeout
-	c.SetTransport(transport).
-		SetTimeout(constants.DefaultHTTPRequestTimeout)
-
-	logger.Debug("HTTP client configured with optimized connection pool",
-		"max_idle_conns", constants.DefaultHTTPMaxIdleConns,
+	logger.Debug("HTTP transport configured with optimized connection pool",
+		"max_idle_conns", transport.MaxIdleConns,
 		"max_idle_conns_per_host", constants.DefaultHTTPMaxIdleConnsPerHost,
 		"max_conns_per_host", constants.DefaultHTTPMaxConnsPerHost,
-		"dial_timeout", constants.DefaultHTTPDialTimeout,
-		"request_timeout", constants.DefaultHTTPRequestTimeout)
+		"idle_conn_timeout", transport.IdleConnTimeout,
+		"disable_keep_alives", transport.DisableKeepAlives,
+		"force_attempt_http2", transport.ForceAttemptHTTP2,
+		"dial_timeout", constants.DefaultHTTPDialTimeout)
+
+	cfg := WithRootCAs(h.TlsConfig, h.RootCAs)
+	if cfg != nil {
+		transport.TLSClientConfig = cfg
+		logger.Debug("applying TLS configuration to HTTP transport",
+			"insecure_skip_verify", cfg.InsecureSkipVerify,
+			"custom_root_cas", cfg.RootCAs != nil,
+			"min_version", cfg.MinVersion,
+			"max_version", cfg.MaxVersion)
+	} else {
+		logger.Debug("using default TLS configuration")
+	}
+	return transport
+}
+
+// New returns a resty.Client that sends through Shared or, without it, a new transport built by
+// NewTransport.
+func (h *Httpc) New() *resty.Client {
+	logger := common.GetLogger().WithComponent("httpc")
+	logger.Debug("creating new HTTP client")
+
+	c := resty.New()
+
+	transport := h.Shared
+	if transport == nil {
+		transport = h.NewTransport()
+	}
+	c.SetTransport(transport).
+		SetTimeout(constants.DefaultHTTPRequestTimeout)
 
 	// Configure retry policy for resilient HTTP operations
-	c.SetRetryCount(3).
-		SetRetryWaitTime(1 * time.Second).
-		SetRetryMaxWaitTime(5 * time.Second).
-		AddRetryCondition(func(r *resty.Response, err error) bool {
-			// Retry on network errors
-			if err != nil {
-				logger.Debug("retrying due to network error", "error", err)
-				return true
+	policy := h.Retry.withDefaults()
+	c.SetRetryCount(policy.MaxAttempts - 1).
+		SetRetryWaitTime(policy.InitialBackoff).
+		SetRetryMaxWaitTime(policy.MaxBackoff).
+		SetRetryAfter(func(_ *resty.Client, r *resty.Response) (time.Duration, error) {
+			attempt := 1
+			if r != nil && r.Request != nil {
+				attempt = r.Request.Attempt
 			}
-			// Retry on 5xx server errors (like 502 Bad Gateway)
-			if r.StatusCode() >= 500 {
-				logger.Debug("retrying due to server error", "status_code", r.StatusCode())
-				return true
-			}
-			// Retry on specific 4xx errors that might be transient
-			if r.StatusCode() == http.StatusTooManyRequests || r.StatusCode() == http.StatusRequestTimeout {
-				logger.Debug("retrying due to transient client error", "status_code", r.StatusCode())
-				return true
+			delay := policy.backoff(attempt)
+			logger.Debug("retrying HTTP request", "attempt", attempt, "delay", delay)
+			return delay, nil
+		}).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			status := 0
+			if r != nil {
+				status = r.StatusCode()
 			}
-			return false
+			return policy.shouldRetry(status, err)
 		})
 
 	// Add request/response logging middleware
@@ -90,23 +152,11 @@ func (h *Httpc) New() *resty.Client {
 		return nil
 	})
 
-	// Log retry information through the AfterResponse middleware
 	logger.Debug("HTTP client configured with retry policy",
-		"max_retries", 3,
-		"initial_wait", "1s",
-		"max_wait", "5s")
-
-	cfg := h.TlsConfig
-	if cfg != nil {
-		// Apply TLS config to our optimized transport
-		transport.TLSClientConfig = cfg
-		logger.Debug("applying TLS configuration to HTTP client",
-			"insecure_skip_verify", cfg.InsecureSkipVerify,
-			"min_version", cfg.MinVersion,
-			"max_version", cfg.MaxVersion)
-	} else {
-		logger.Debug("using default TLS configuration")
-	}
+		"max_attempts", policy.MaxAttempts,
+		"initial_backoff", policy.InitialBackoff,
+		"max_backoff", policy.MaxBackoff,
+		"multiplier", policy.Multiplier)
 
 	logger.Debug("HTTP client created with optimized transport and connection pooling")
 	return c
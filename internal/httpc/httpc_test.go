@@ -7,6 +7,9 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/constants"
 )
 
 // helper to perform a simple GET using our client
@@ -87,3 +90,48 @@ func TestHTTPClient_Auto_DefaultMode(t *testing.T) {
 		t.Fatalf("default client to http server expected 204, got code=%d err=%v", code, err)
 	}
 }
+
+func TestHTTPClient_TransportConfigApplied(t *testing.T) {
+	h := &Httpc{Transport: &TransportConfig{ForceAttemptHTTP2: true, MaxIdleConns: 7, IdleConnTimeout: 15 * time.Second, DisableKeepAlives: true}}
+	tr, _ := h.New().GetClient().Transport.(*http.Transport)
+	if tr == nil {
+		t.Fatalf("expected *http.Transport")
+	}
+	if !tr.ForceAttemptHTTP2 || tr.MaxIdleConns != 7 || tr.IdleConnTimeout != 15*time.Second || !tr.DisableKeepAlives {
+		t.Fatalf("transport not configured: http2=%v idle=%d timeout=%v no_keepalive=%v",
+			tr.ForceAttemptHTTP2, tr.MaxIdleConns, tr.IdleConnTimeout, tr.DisableKeepAlives)
+	}
+
+	// Zero values keep the defaults
+	tr, _ = (&Httpc{Transport: &TransportConfig{}}).New().GetClient().Transport.(*http.Transport)
+	if tr.ForceAttemptHTTP2 || tr.MaxIdleConns != constants.DefaultHTTPMaxIdleConns ||
+		tr.IdleConnTimeout != constants.DefaultHTTPIdleConnTimeout || tr.DisableKeepAlives {
+		t.Fatalf("expected default transport settings, got http2=%v idle=%d timeout=%v no_keepalive=%v",
+			tr.ForceAttemptHTTP2, tr.MaxIdleConns, tr.IdleConnTimeout, tr.DisableKeepAlives)
+	}
+}
+
+func TestHTTPClient_ForceAttemptHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: true}
+	for _, force := range []bool{false, true} {
+		h := &Httpc{TlsConfig: tlsCfg, Transport: &TransportConfig{ForceAttemptHTTP2: force}}
+		resp, err := h.New().R().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("force=%v: %v", force, err)
+		}
+		want := 1
+		if force {
+			want = 2
+		}
+		if got := resp.RawResponse.ProtoMajor; got != want {
+			t.Fatalf("force=%v: expected HTTP/%d, got %s", force, want, resp.RawResponse.Proto)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryPolicy configures how HTTP requests are retried on transient failures.
+// Zero-valued fields fall back to the corresponding DefaultRetryPolicy values.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one (1 disables retries).
+	MaxAttempts int `yaml:"max_attempts" mapstructure:"max_attempts"`
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration `yaml:"initial_backoff" mapstructure:"initial_backoff"`
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration `yaml:"max_backoff" mapstructure:"max_backoff"`
+	// Multiplier grows the delay after each attempt (exponential backoff).
+	Multiplier float64 `yaml:"multiplier" mapstructure:"multiplier"`
+	// RetryableStatusCodes lists response codes that trigger a retry. Network errors always retry.
+	RetryableStatusCodes []int `yaml:"retryable_status_codes" mapstructure:"retryable_status_codes"`
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2.0,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// withDefaults returns a copy of the policy with zero-valued fields filled from DefaultRetryPolicy.
+// A nil receiver yields the default policy.
+func (p *RetryPolicy) withDefaults() *RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p == nil {
+		return def
+	}
+	out := *p
+	if out.MaxAttempts <= 0 {
+		out.MaxAttempts = def.MaxAttempts
+	}
+	if out.InitialBackoff <= 0 {
+		out.InitialBackoff = def.InitialBackoff
+	}
+	if out.MaxBackoff <= 0 {
+		out.MaxBackoff = def.MaxBackoff
+	}
+	if out.MaxBackoff < out.InitialBackoff {
+		out.MaxBackoff = out.InitialBackoff
+	}
+	if out.Multiplier < 1 {
+		out.Multiplier = def.Multiplier
+	}
+	if len(out.RetryableStatusCodes) == 0 {
+		out.RetryableStatusCodes = def.RetryableStatusCodes
+	}
+	return &out
+}
+
+// backoff returns the delay to wait after the given attempt (1-based).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := time.Duration(float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1)))
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+// shouldRetry reports whether a response/error pair is considered transient.
+func (p *RetryPolicy) shouldRetry(status int, err error) bool {
+	if err != nil {
+		// Cancellation, an over-limit body and an open circuit are not transient; everything
+		// else at the transport level is.
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, resty.ErrResponseBodyTooLarge) && !errors.Is(err, ErrCircuitOpen)
+	}
+	for _, c := range p.RetryableStatusCodes {
+		if c == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package httpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_RetriesOnRetryableStatus(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	h := &Httpc{Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Multiplier: 2, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}}
+	code, err := doGet(t, context.Background(), srv.URL, h)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("expected 200 after retries, got code=%d err=%v", code, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryPolicy_StopsAtMaxAttempts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	h := &Httpc{Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	code, err := doGet(t, context.Background(), srv.URL, h)
+	if err != nil || code != http.StatusBadGateway {
+		t.Fatalf("expected final 502, got code=%d err=%v", code, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestRetryPolicy_NonRetryableStatusNotRetried(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	h := &Httpc{Retry: &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}}
+	if code, _ := doGet(t, context.Background(), srv.URL, h); code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestRetryPolicy_HonorsContextDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	h := &Httpc{Retry: &RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: time.Second}}
+	start := time.Now()
+	_, _ = doGet(t, ctx, srv.URL, h)
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Fatalf("expected retry loop to stop at context deadline, took %v", elapsed)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := (&RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Multiplier: 2}).withDefaults()
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, w, got)
+		}
+	}
+}
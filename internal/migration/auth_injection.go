@@ -0,0 +1,56 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AuthInjection adds an auth header to every up, down and find request, so migrations need not
+// set it themselves. A header of the same name set by the request still takes precedence.
+type AuthInjection struct {
+	// HeaderName is the injected header; it defaults to "Authorization".
+	HeaderName string
+	// ValueTemplate is rendered like a header value, e.g. "Bearer {{.auth.main}}". It defaults to
+	// "{{.auth.<AuthName>}}", the acquired value as is.
+	ValueTemplate string
+	// AuthName is the acquired auth the header carries. It is required when ValueTemplate is empty.
+	AuthName string
+}
+
+// header returns the injected header name and its value template.
+func (a *AuthInjection) header() (string, string, error) {
+	name := strings.TrimSpace(a.HeaderName)
+	if name == "" {
+		name = "Authorization"
+	}
+	tmpl := a.ValueTemplate
+	if strings.TrimSpace(tmpl) == "" {
+		authName := strings.TrimSpace(a.AuthName)
+		if authName == "" {
+			return "", "", errors.New("auth injection: auth name or value template is required")
+		}
+		tmpl = fmt.Sprintf("{{.auth.%s}}", authName)
+	}
+	return name, tmpl, nil
+}
+
+// requestDefaultHeaders returns DefaultHeaders with the AuthInjection header added, which
+// replaces a default header of the same (case-insensitive) name.
+func (m *Migrator) requestDefaultHeaders() (map[string]string, error) {
+	if m.AuthInjection == nil {
+		return m.DefaultHeaders, nil
+	}
+	name, tmpl, err := m.AuthInjection.header()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(m.DefaultHeaders)+1)
+	for k, v := range m.DefaultHeaders {
+		if !strings.EqualFold(k, name) {
+			out[k] = v
+		}
+	}
+	out[name] = tmpl
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestMigrator_AuthInjection(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got[r.Method+" "+r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	migs := map[string]string{
+		"001_injected.yaml": fmt.Sprintf("up:\n  request:\n    method: POST\n    url: %[1]s/a\n"+
+			"down:\n  method: DELETE\n  url: %[1]s/a\n  find:\n    request:\n      method: GET\n      url: %[1]s/find\n    response:\n      result_code: ['200']\n", srv.URL),
+		"002_override.yaml": fmt.Sprintf("up:\n  request:\n    method: POST\n    url: %[1]s/b\n    headers:\n      - { name: authorization, value: 'Basic own' }\n"+
+			"down:\n  method: DELETE\n  url: %[1]s/b\n", srv.URL),
+	}
+	for name, body := range migs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write mig: %v", err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	base := env.New()
+	base.Auth = env.FromStringMap(map[string]string{"main": "tok"})
+	m := &Migrator{Dir: dir, Env: base, Store: *st, DelayBetweenMigrations: time.Millisecond,
+		DefaultHeaders: map[string]string{"authorization": "Bearer default", "X-Trace": "1"},
+		AuthInjection:  &AuthInjection{ValueTemplate: "Bearer {{.auth.main}}"}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	want := map[string]string{
+		"POST /a":   "Bearer tok",
+		"GET /find": "Bearer tok",
+		"DELETE /a": "Bearer tok",
+		"POST /b":   "Basic own", // per-request header wins
+		"DELETE /b": "Bearer tok",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for req, w := range want {
+		if got[req] != w {
+			t.Errorf("%s: Authorization = %q, want %q", req, got[req], w)
+		}
+	}
+}
+
+func TestAuthInjection_Header(t *testing.T) {
+	name, tmpl, err := (&AuthInjection{AuthName: "main"}).header()
+	if err != nil || name != "Authorization" || tmpl != "{{.auth.main}}" {
+		t.Fatalf("defaults: %q %q %v", name, tmpl, err)
+	}
+	name, tmpl, err = (&AuthInjection{HeaderName: "X-Api-Key", ValueTemplate: "{{.auth.key}}"}).header()
+	if err != nil || name != "X-Api-Key" || tmpl != "{{.auth.key}}" {
+		t.Fatalf("custom: %q %q %v", name, tmpl, err)
+	}
+	if _, _, err := (&AuthInjection{}).header(); err == nil {
+		t.Fatalf("expected an error without auth name or template")
+	}
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/loykin/apirun/internal/store"
+)
+
+// DirectionBaseline is the direction of the runs recorded by Baseline.
+const DirectionBaseline = "baseline"
+
+// Baseline marks the migrations up to and including targetVersion as applied without sending
+// their requests, for adopting apirun on a system whose state already matches them. Each
+// version is recorded with its checksum and a run with direction "baseline" (status 0).
+// It fails, recording nothing, when one of those versions is already applied. In dry-run
+// mode it only returns the versions it would mark.
+func (m *Migrator) Baseline(ctx context.Context, targetVersion int) ([]int, error) {
+	logger := m.logger()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if targetVersion <= 0 {
+		return nil, fmt.Errorf("baseline requires a target version above 0, got %d", targetVersion)
+	}
+	unlock, err := m.lockRun()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range applied {
+		if v <= targetVersion {
+			return nil, fmt.Errorf("cannot baseline up to version %d: version %d is already applied", targetVersion, v)
+		}
+	}
+	baseline := planUp(files, 0, targetVersion)
+	if len(baseline) == 0 {
+		return nil, fmt.Errorf("no migration files up to version %d to baseline", targetVersion)
+	}
+
+	versions := make([]int, 0, len(baseline))
+	for _, f := range baseline {
+		if !m.DryRun {
+			err := m.Store.WithTx(func(tx *store.Store) error {
+				if err := applyVersion(tx, f); err != nil {
+					return err
+				}
+				if err := tx.RecordRunWithDetails(f.index, DirectionBaseline, 0, nil, nil, false, store.RunDetails{RunID: m.RunID}); err != nil {
+					return fmt.Errorf("record baseline run %d: %w", f.index, err)
+				}
+				return nil
+			})
+			if err != nil {
+				return versions, err
+			}
+		}
+		logger.Info("baselined migration", "version", f.index, "file", f.name)
+		versions = append(versions, f.index)
+	}
+	return versions, nil
+}
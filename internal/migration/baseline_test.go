@@ -0,0 +1,92 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestBaseline_MarksAppliedWithoutRequests(t *testing.T) {
+	var hits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		mig := fmt.Sprintf("up:\n  request:\n    method: POST\n    url: %s/v%d\n", srv.URL, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d_v.yaml", i)), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New()}
+	ctx := context.Background()
+
+	versions, err := m.Baseline(ctx, 2)
+	if err != nil {
+		t.Fatalf("Baseline: %v", err)
+	}
+	if !reflect.DeepEqual(versions, []int{1, 2}) || len(hits) != 0 {
+		t.Fatalf("expected versions [1 2] and no requests, got %v and hits %v", versions, hits)
+	}
+	applied, _ := st.ListApplied()
+	if !reflect.DeepEqual(applied, []int{1, 2}) {
+		t.Fatalf("expected applied [1 2], got %v", applied)
+	}
+	runs, _ := st.ListRuns()
+	if len(runs) != 2 || runs[0].Direction != DirectionBaseline || runs[1].Direction != DirectionBaseline || runs[0].Failed {
+		t.Fatalf("expected two baseline runs, got %+v", runs)
+	}
+	if drifts, err := m.VerifyChecksums(ctx); err != nil || len(drifts) != 0 {
+		t.Fatalf("expected baselined checksums to match, got %v %v", drifts, err)
+	}
+
+	// Baselining over applied versions is refused
+	if _, err := m.Baseline(ctx, 3); err == nil || !strings.Contains(err.Error(), "already applied") {
+		t.Fatalf("expected an already applied error, got %v", err)
+	}
+
+	// Up only runs the versions beyond the baseline
+	if _, err := m.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if !reflect.DeepEqual(hits, []string{"/v3"}) {
+		t.Fatalf("expected only v3 to run, got %v", hits)
+	}
+}
+
+func TestBaseline_DryRunAndInvalidTarget(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "001_v.yaml"), []byte("up:\n  request: { method: GET, url: http://127.0.0.1:1 }\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	ctx := context.Background()
+
+	dry := &Migrator{Dir: dir, Store: *st, Env: env.New(), DryRun: true}
+	if versions, err := dry.Baseline(ctx, 1); err != nil || !reflect.DeepEqual(versions, []int{1}) {
+		t.Fatalf("dry-run Baseline: %v %v", versions, err)
+	}
+	if cur, _ := st.CurrentVersion(); cur != 0 {
+		t.Fatalf("expected dry-run baseline to record nothing, got version %d", cur)
+	}
+
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New()}
+	if _, err := m.Baseline(ctx, 0); err == nil {
+		t.Fatalf("expected an error for target 0")
+	}
+}
@@ -0,0 +1,32 @@
+package migration
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// savedBody returns the response body recorded with a run: nil unless SaveResponseBody is set,
+// and cut to MaxResponseBodyBytes with a truncation marker when it is longer.
+func (m *Migrator) savedBody(version int, direction, body string) *string {
+	if !m.SaveResponseBody {
+		return nil
+	}
+	limit := m.MaxResponseBodyBytes
+	if limit <= 0 || int64(len(body)) <= limit {
+		return &body
+	}
+	out := truncateBody(body, int(limit))
+	m.logger().WithVersion(version).Warn("response body truncated before saving",
+		"direction", direction, "bytes", len(body), "limit", limit)
+	return &out
+}
+
+// truncateBody cuts body to at most limit bytes, without splitting a UTF-8 sequence, and appends
+// a marker with the original size.
+func truncateBody(body string, limit int) string {
+	cut := limit
+	for cut > 0 && cut < len(body) && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...[truncated, %d bytes total]", body[:cut], len(body))
+}
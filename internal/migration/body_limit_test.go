@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestMigrateUp_MaxResponseBodyBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small" {
+			_, _ = w.Write([]byte(`{"id":"1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"2","pad":"` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i, path := range []string{"small", "large"} {
+		mig := fmt.Sprintf("up:\n  name: %[1]s\n  request:\n    method: GET\n    url: %[2]s/%[1]s\n  response:\n    env_from: { %[1]s_id: id }\n", path, srv.URL)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d_%s.yaml", i+1, path)), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write mig: %v", err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Env: env.New(), Store: *st, SaveResponseBody: true, MaxResponseBodyBytes: 16, DelayBetweenMigrations: time.Millisecond}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	runs, err := st.ListRuns()
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	if runs[0].Body == nil || *runs[0].Body != `{"id":"1"}` {
+		t.Fatalf("body under the limit must be kept as is, got %v", runs[0].Body)
+	}
+	if runs[1].Body == nil {
+		t.Fatalf("body over the limit must still be saved")
+	}
+	if got := *runs[1].Body; got != `{"id":"2","pad":...[truncated, 119 bytes total]` {
+		t.Fatalf("body over the limit must be truncated, got %q", got)
+	}
+	// Extraction used the whole body
+	if runs[1].Env["large_id"] != "2" {
+		t.Fatalf("extracted env = %v", runs[1].Env)
+	}
+}
+
+func TestTruncateBody_KeepsUTF8(t *testing.T) {
+	if got := truncateBody("añb", 2); got != "a...[truncated, 4 bytes total]" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/internal/task"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestMigrateUp_CircuitBreakerStopsRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := fmt.Sprintf("up:\n  name: flaky\n  request:\n    method: GET\n    url: %s/x\n"+
+		"    retry:\n      max_attempts: 5\n      initial_backoff: 1ms\n      max_backoff: 1ms\n", srv.URL)
+	if err := os.WriteFile(filepath.Join(dir, "001_flaky.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write mig: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Env: env.New(), Store: *st, DelayBetweenMigrations: time.Millisecond,
+		CircuitBreaker: &task.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}}
+	_, err := m.MigrateUp(context.Background(), 0)
+	if !errors.Is(err, task.ErrCircuitOpen) {
+		t.Fatalf("expected the open circuit to end the migration, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected 2 requests before the circuit opened, got %d", got)
+	}
+
+	// The breaker outlives the run: a second run fails fast
+	if _, err := m.MigrateUp(context.Background(), 0); !errors.Is(err, task.ErrCircuitOpen) {
+		t.Fatalf("expected a fast failure, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("open circuit must not reach the server, got %d calls", got)
+	}
+}
@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/auth"
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestMigrateUp_CAFile(t *testing.T) {
+	var calls int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	body := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "\n    retry: { max_attempts: 1 }\n  response:\n    result_code: ['200']\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_tls.yaml"), []byte(body), 0o600); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	badCA := filepath.Join(t.TempDir(), "bad.pem")
+	if err := os.WriteFile(badCA, []byte("-----BEGIN CERTIFICATE-----\nnope\n"), 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	// A malformed CA file fails before any request is sent
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), CAFile: badCA}
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "contains no PEM certificates") {
+		t.Fatalf("expected a CA file error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("requests sent with a malformed CA file: %d", n)
+	}
+
+	// Without the CA the server certificate is not trusted
+	m = &Migrator{Dir: dir, Store: *st, Env: env.New()}
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil {
+		t.Fatalf("expected a certificate error without the CA")
+	}
+
+	m = &Migrator{Dir: dir, Store: *st, Env: env.New(), CAFile: caFile}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp with CA: %v", err)
+	}
+	if applied, err := st.ListApplied(); err != nil || len(applied) != 1 {
+		t.Fatalf("applied = %v, %v; want [1]", applied, err)
+	}
+}
+
+func TestMigrateUp_CAFileNotSharedBetweenMigrators(t *testing.T) {
+	// The first request of the TLS run is held until the other run has finished
+	release := make(chan struct{})
+	var calls int32
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			// The next request opens a new connection and so consults the TLS settings again
+			w.Header().Set("Connection", "close")
+		}
+		if r.URL.Path == "/api/admins/auth-with-password" {
+			_, _ = w.Write([]byte(`{"token":"tok"}`))
+			return
+		}
+		if r.URL.Path == "/second" && r.Header.Get("Authorization") != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer tlsSrv.Close()
+	plainSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer plainSrv.Close()
+
+	post := func(url, headers string) string {
+		return "up:\n  request:\n    method: POST\n    url: " + url + "\n" + headers + "    retry: { max_attempts: 1 }\n  response:\n    result_code: ['200']\n"
+	}
+	tlsDir, plainDir := t.TempDir(), t.TempDir()
+	writeMigrations(t, tlsDir, map[string]string{
+		"001_first.yaml": post(tlsSrv.URL, ""),
+		// Acquires the auth token on first use, after the other run
+		"002_second.yaml": post(tlsSrv.URL+"/second", "    headers:\n      - { name: Authorization, value: '{{.auth.pb}}' }\n"),
+	})
+	writeMigrations(t, plainDir, map[string]string{"001_plain.yaml": post(plainSrv.URL, "")})
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	tlsMigrator, _ := newTestMigrator(t, tlsDir)
+	tlsMigrator.CAFile = caFile
+	pb := auth.NewAuthSpecFromMap(map[string]interface{}{"base_url": tlsSrv.URL, "email": "a@b.c", "password": "p"})
+	tlsMigrator.Auth = []auth.Auth{{Type: "pocketbase", Name: "pb", Methods: pb}}
+	plainMigrator, _ := newTestMigrator(t, plainDir)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tlsMigrator.MigrateUp(context.Background(), 0)
+		done <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := plainMigrator.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp without a CA: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("MigrateUp with CA after a concurrent run: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// newlyApplied returns the versions in after that are not in before, newest first.
+func newlyApplied(before, after []int) []int {
+	seen := make(map[int]bool, len(before))
+	for _, v := range before {
+		seen[v] = true
+	}
+	var out []int
+	for _, v := range after {
+		if !seen[v] {
+			out = append(out, v)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	return out
+}
+
+// rollbackCancelled runs the down steps of the versions applied since before, newest first,
+// after the up run was cancelled with runErr. The cancelled ctx is detached from its
+// cancellation so the down requests can still be sent. The returned error wraps runErr and,
+// when the rollback stops early, the rollback failure as well.
+func (m *Migrator) rollbackCancelled(ctx context.Context, before []int, runErr error) error {
+	logger := m.logger()
+	ctx = context.WithoutCancel(ctx)
+	after, err := m.appliedVersions()
+	if err != nil {
+		return errors.Join(runErr, fmt.Errorf("rollback after cancellation: %w", err))
+	}
+	versions := newlyApplied(before, after)
+	if len(versions) == 0 {
+		return runErr
+	}
+	logger.Warn("up run cancelled, rolling back the versions it applied", "versions", versions)
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return errors.Join(runErr, fmt.Errorf("rollback after cancellation: %w", err))
+	}
+	fileByVer := mapFilesByVersion(files)
+	for _, v := range versions {
+		f, ok := fileByVer[v]
+		if !ok {
+			return errors.Join(runErr, fmt.Errorf("rollback after cancellation: %w", &ErrMissingMigrationFile{Version: v}))
+		}
+		if err := m.ensureAuth(ctx); err != nil {
+			return errors.Join(runErr, fmt.Errorf("rollback after cancellation: failed to ensure authentication: %w", err))
+		}
+		if _, err := m.runDownForVersion(ctx, v, f); err != nil {
+			logger.Error("rollback after cancellation failed", "version", v, "error", err)
+			return errors.Join(runErr, fmt.Errorf("rollback after cancellation stopped at version %d: %w", v, err))
+		}
+	}
+	logger.Info("rolled back cancelled up run", "versions", versions)
+	return fmt.Errorf("%w (rolled back versions %v)", runErr, versions)
+}
@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/internal/task"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// cancelAfterFirst sets up two migrations and a Migrator whose context is cancelled once
+// version 1 has run. downStatus is returned for DELETE requests.
+func cancelAfterFirst(t *testing.T, downStatus int) (*Migrator, *store.Store, context.Context, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(downStatus)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	for v := 1; v <= 2; v++ {
+		mig := fmt.Sprintf("up:\n  name: v%d\n  request:\n    method: POST\n    url: %s/v%d\n"+
+			"down:\n  name: undo v%d\n  method: DELETE\n  url: %s/v%d\n  response:\n    result_code: ['200']\n", v, srv.URL, v, v, srv.URL, v)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d_v%d.yaml", v, v)), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write mig: %v", err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	t.Cleanup(func() { _ = st.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	m := &Migrator{Dir: dir, Env: env.New(), Store: *st, RollbackOnCancel: true, DelayBetweenMigrations: time.Millisecond,
+		AfterStep: func(_ context.Context, step StepInfo, _ *task.ExecResult, _ error) error {
+			if step.Direction == "up" && step.Version == 1 {
+				cancel()
+			}
+			return nil
+		}}
+	return m, st, ctx, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+func TestMigrateUp_RollbackOnCancel(t *testing.T) {
+	m, st, ctx, calls := cancelAfterFirst(t, http.StatusOK)
+
+	_, err := m.MigrateUp(ctx, 0)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "rolled back versions [1]") {
+		t.Fatalf("expected a cancelled run rolled back, got %v", err)
+	}
+	if got := fmt.Sprint(calls()); got != "[POST /v1 DELETE /v1]" {
+		t.Fatalf("unexpected requests: %s", got)
+	}
+	if applied, _ := st.ListApplied(); len(applied) != 0 {
+		t.Fatalf("expected nothing applied after rollback, got %v", applied)
+	}
+}
+
+func TestMigrateUp_RollbackOnCancelFailure(t *testing.T) {
+	m, st, ctx, _ := cancelAfterFirst(t, http.StatusConflict)
+
+	_, err := m.MigrateUp(ctx, 0)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "rollback after cancellation stopped at version 1") {
+		t.Fatalf("expected the rollback failure to be reported, got %v", err)
+	}
+	// A failed rollback leaves the version applied, so the store still matches the system
+	if applied, _ := st.ListApplied(); fmt.Sprint(applied) != "[1]" {
+		t.Fatalf("applied = %v", applied)
+	}
+}
+
+func TestMigrateUp_CancelWithoutRollbackKeepsApplied(t *testing.T) {
+	m, st, ctx, calls := cancelAfterFirst(t, http.StatusOK)
+	m.RollbackOnCancel = false
+
+	if _, err := m.MigrateUp(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got := fmt.Sprint(calls()); got != "[POST /v1]" {
+		t.Fatalf("unexpected requests: %s", got)
+	}
+	if applied, _ := st.ListApplied(); fmt.Sprint(applied) != "[1]" {
+		t.Fatalf("applied = %v", applied)
+	}
+}
+
+func TestNewlyApplied(t *testing.T) {
+	if got := newlyApplied([]int{1, 2}, []int{1, 2, 3, 5, 4}); fmt.Sprint(got) != "[5 4 3]" {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/loykin/apirun/internal/store"
+)
+
+// ErrChecksumMismatch is wrapped by MigrateUp errors when StrictChecksums is set and an applied
+// migration file changed after it was applied.
+var ErrChecksumMismatch = errors.New("applied migration file changed")
+
+// ChecksumDrift reports an applied version whose file no longer matches the checksum recorded
+// when it was applied.
+type ChecksumDrift struct {
+	Version  int    `json:"version"`
+	File     string `json:"file"`
+	Stored   string `json:"stored"`
+	Computed string `json:"computed"`
+}
+
+// checksum returns the SHA-256 of the raw (unrendered) file content. Line endings are
+// normalized so that a checkout with CRLF endings does not count as a change.
+func (f vfile) checksum() (string, error) {
+	var data []byte
+	var err error
+	if f.fsys != nil {
+		data, err = fs.ReadFile(f.fsys, f.path)
+	} else {
+		data, err = os.ReadFile(filepath.Clean(f.path))
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyChecksums compares the checksums recorded for applied versions with their current
+// files. Versions applied before checksums were recorded, or whose file is gone, are skipped.
+func (m *Migrator) VerifyChecksums(ctx context.Context) ([]ChecksumDrift, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	return m.checksumDrift(files)
+}
+
+func (m *Migrator) checksumDrift(files []vfile) ([]ChecksumDrift, error) {
+	stored, err := m.Store.ListChecksums()
+	if err != nil {
+		return nil, err
+	}
+	drifts := []ChecksumDrift{}
+	for _, f := range files {
+		want, ok := stored[f.index]
+		if !ok || want == "" {
+			continue
+		}
+		got, err := f.checksum()
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", f.name, err)
+		}
+		if got != want {
+			drifts = append(drifts, ChecksumDrift{Version: f.index, File: f.name, Stored: want, Computed: got})
+		}
+	}
+	return drifts, nil
+}
+
+// checkChecksums warns about applied files that changed, or fails with StrictChecksums.
+func (m *Migrator) checkChecksums(files []vfile) error {
+	drifts, err := m.checksumDrift(files)
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+	logger := m.logger()
+	versions := make([]int, 0, len(drifts))
+	for _, d := range drifts {
+		versions = append(versions, d.Version)
+		logger.Warn("applied migration file changed since it was applied", "version", d.Version, "file", d.File)
+	}
+	if m.StrictChecksums {
+		return fmt.Errorf("%w: versions %v (restore the files or redo the versions)", ErrChecksumMismatch, versions)
+	}
+	return nil
+}
+
+// applyVersion records f as applied in st together with its file checksum.
+func applyVersion(st *store.Store, f vfile) error {
+	if err := st.Apply(f.index); err != nil {
+		return fmt.Errorf("record apply %d: %w", f.index, err)
+	}
+	sum, err := f.checksum()
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", f.name, err)
+	}
+	if err := st.SetChecksum(f.index, sum); err != nil {
+		return fmt.Errorf("record checksum %d: %w", f.index, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateUp_RecordsChecksumsAndDetectsDrift(t *testing.T) {
+	srv := &concurrencyServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "")
+	m, st := newDAGMigrator(t, dir, 0)
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	sums, err := st.ListChecksums()
+	if err != nil || len(sums) != 2 || len(sums[1]) != 64 {
+		t.Fatalf("expected sha256 checksums for both versions, got %v (%v)", sums, err)
+	}
+
+	// Unchanged files and a new, not yet applied file: no drift
+	writeDAGMigration(t, dir, "003_c.yaml", ts.URL, "")
+	if drifts, err := m.VerifyChecksums(context.Background()); err != nil || len(drifts) != 0 {
+		t.Fatalf("expected no drift, got %v (%v)", drifts, err)
+	}
+	// CRLF line endings alone do not count as a change
+	data, _ := os.ReadFile(filepath.Join(dir, "001_a.yaml"))
+	crlf := []byte{}
+	for _, b := range data {
+		if b == '\n' {
+			crlf = append(crlf, '\r')
+		}
+		crlf = append(crlf, b)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "001_a.yaml"), crlf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if drifts, _ := m.VerifyChecksums(context.Background()); len(drifts) != 0 {
+		t.Fatalf("line ending change reported as drift: %v", drifts)
+	}
+
+	// Edit an applied file
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL+"/v2", "")
+	drifts, err := m.VerifyChecksums(context.Background())
+	if err != nil || len(drifts) != 1 || drifts[0].Version != 2 || drifts[0].File != "002_b.yaml" || drifts[0].Stored == drifts[0].Computed {
+		t.Fatalf("expected drift of version 2, got %+v (%v)", drifts, err)
+	}
+
+	// By default drift is only a warning and the new version is applied
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp with drift: %v", err)
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1, 2, 3}) {
+		t.Fatalf("unexpected applied versions: %v", applied)
+	}
+
+	// Strict mode refuses to run
+	writeDAGMigration(t, dir, "004_d.yaml", ts.URL, "")
+	m.StrictChecksums = true
+	if _, err := m.MigrateUp(context.Background(), 0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch error, got %v", err)
+	}
+	if applied, _ := st.ListApplied(); len(applied) != 3 {
+		t.Fatalf("strict mode must not apply anything, got %v", applied)
+	}
+
+	// Redo accepts the edited file
+	if _, err := m.Redo(context.Background(), 2); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if drifts, _ := m.VerifyChecksums(context.Background()); len(drifts) != 0 {
+		t.Fatalf("expected redo to record the new checksum, got %v", drifts)
+	}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("strict MigrateUp after redo: %v", err)
+	}
+}
+
+func TestVerifyChecksums_SkipsVersionsWithoutChecksum(t *testing.T) {
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", "http://x", "")
+	m, st := newDAGMigrator(t, dir, 0)
+	// Applied before checksums were recorded
+	if err := st.Apply(1); err != nil {
+		t.Fatal(err)
+	}
+	if drifts, err := m.VerifyChecksums(context.Background()); err != nil || len(drifts) != 0 {
+		t.Fatalf("expected no drift for legacy version, got %v (%v)", drifts, err)
+	}
+}
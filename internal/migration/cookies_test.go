@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sessionServer sets a session cookie on POST /login and answers 401 on /protected without it.
+func sessionServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s-42", Path: "/"})
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/protected":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "s-42" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func writeSessionMigrations(t *testing.T, dir, base string) {
+	t.Helper()
+	login := "up:\n  name: login\n  request:\n    method: POST\n    url: " + base + "/login\n  response:\n    result_code: ['200']\n"
+	protected := "up:\n  name: protected\n  request:\n    method: GET\n    url: " + base + "/protected\n  response:\n    result_code: ['200']\n"
+	writeMigrations(t, dir, map[string]string{"001_login.yaml": login, "002_protected.yaml": protected})
+}
+
+func TestEnableCookies_SessionCookieReachesLaterSteps(t *testing.T) {
+	srv := sessionServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	writeSessionMigrations(t, dir, srv.URL)
+	m, _ := newTestMigrator(t, dir)
+	m.EnableCookies = true
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp with cookies: %v", err)
+	}
+
+	// The jar does not outlive the run: a later call without a login is rejected
+	protected := "up:\n  name: protected again\n  request:\n    method: GET\n    url: " + srv.URL + "/protected\n  response:\n    result_code: ['200']\n"
+	writeMigrations(t, dir, map[string]string{"003_protected_again.yaml": protected})
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil {
+		t.Fatalf("expected the second run to start without the session cookie")
+	}
+}
+
+func TestEnableCookies_OffByDefault(t *testing.T) {
+	srv := sessionServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	writeSessionMigrations(t, dir, srv.URL)
+	m, st := newTestMigrator(t, dir)
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil {
+		t.Fatalf("expected the protected step to fail without EnableCookies")
+	}
+	if v, _ := st.CurrentVersion(); v != 1 {
+		t.Fatalf("current version = %d, want 1", v)
+	}
+}
@@ -0,0 +1,239 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/loykin/apirun/internal/task"
+)
+
+// loadDependencies returns, per version, the versions it depends on. A file without depends_on
+// depends on every lower version, which keeps the default order strictly sequential.
+func loadDependencies(files []vfile) (map[int][]int, error) {
+	exists := make(map[int]bool, len(files))
+	for _, f := range files {
+		exists[f.index] = true
+	}
+	deps := make(map[int][]int, len(files))
+	for i, f := range files {
+		var t task.Task
+		if err := f.load(&t); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+		if t.DependsOn == nil {
+			for _, prev := range files[:i] {
+				deps[f.index] = append(deps[f.index], prev.index)
+			}
+			continue
+		}
+		for _, d := range t.DependsOn {
+			if d >= f.index {
+				return nil, fmt.Errorf("%s: depends_on %d must reference a lower version", f.name, d)
+			}
+			if !exists[d] {
+				return nil, fmt.Errorf("%s: depends_on %d: no such migration", f.name, d)
+			}
+		}
+		deps[f.index] = append([]int{}, t.DependsOn...)
+	}
+	return deps, nil
+}
+
+// dagJob is the part of a migration step that may run concurrently with other steps.
+type dagJob func(ctx context.Context) (*ExecWithVersion, error)
+
+// runDAG runs the versions in order (the preferred start order) with at most limit running at
+// once. waitFor[v] lists versions that must complete successfully before v starts. prepare is
+// called from the calling goroutine right before a version starts and returns its job.
+// After the first failure no new versions are started; running ones are awaited.
+// Results are returned in start order.
+func runDAG(ctx context.Context, order []int, waitFor map[int][]int, limit int, prepare func(v int) (dagJob, error)) ([]*ExecWithVersion, error) {
+	remaining := make(map[int]int, len(order))
+	dependents := map[int][]int{}
+	for _, v := range order {
+		remaining[v] = len(waitFor[v])
+		for _, d := range waitFor[v] {
+			dependents[d] = append(dependents[d], v)
+		}
+	}
+	type outcome struct {
+		slot int
+		v    int
+		res  *ExecWithVersion
+		err  error
+	}
+	done := make(chan outcome)
+	started := make(map[int]bool, len(order))
+	var slots []*ExecWithVersion
+	running := 0
+	var firstErr error
+	for {
+		for firstErr == nil && running < limit {
+			v, ok := nextReady(order, remaining, started)
+			if !ok {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				firstErr = err
+				break
+			}
+			started[v] = true
+			job, err := prepare(v)
+			if err != nil {
+				firstErr = err
+				break
+			}
+			slots = append(slots, nil)
+			running++
+			go func(slot, v int) {
+				res, err := job(ctx)
+				done <- outcome{slot: slot, v: v, res: res, err: err}
+			}(len(slots)-1, v)
+		}
+		if running == 0 {
+			break
+		}
+		o := <-done
+		running--
+		slots[o.slot] = o.res
+		if o.err != nil {
+			if firstErr == nil {
+				firstErr = o.err
+			}
+			continue
+		}
+		for _, d := range dependents[o.v] {
+			remaining[d]--
+		}
+	}
+	results := make([]*ExecWithVersion, 0, len(slots))
+	for _, r := range slots {
+		if r != nil {
+			results = append(results, r)
+		}
+	}
+	return results, firstErr
+}
+
+// nextReady returns the first version in order that has not started and has no outstanding dependencies.
+func nextReady(order []int, remaining map[int]int, started map[int]bool) (int, bool) {
+	for _, v := range order {
+		if !started[v] && remaining[v] == 0 {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// migrateUpParallel applies the pending files (see pendingUp), running versions whose
+// dependencies are satisfied concurrently (up to MaxParallel).
+func (m *Migrator) migrateUpParallel(ctx context.Context, files []vfile, pending []vfile) ([]*ExecWithVersion, error) {
+	logger := m.logger()
+	deps, err := loadDependencies(files)
+	if err != nil {
+		return nil, err
+	}
+	fileByVer := mapFilesByVersion(files)
+	order := make([]int, 0, len(pending))
+	inPlan := map[int]bool{}
+	for _, f := range pending {
+		order = append(order, f.index)
+		inPlan[f.index] = true
+	}
+	waitFor := make(map[int][]int, len(order))
+	for _, v := range order {
+		for _, d := range deps[v] {
+			if inPlan[d] {
+				waitFor[v] = append(waitFor[v], d)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	sessionStored := map[string]string{}
+	prepare := func(v int) (dagJob, error) {
+		f := fileByVer[v]
+		logger.Info("applying migration", "version", v, "file", f.name)
+		// Refresh lazy auth values so tokens close to expiry are re-acquired
+		if err := m.ensureAuth(ctx); err != nil {
+			return nil, fmt.Errorf("failed to ensure authentication: %w", err)
+		}
+		mu.Lock()
+		snapshot := make(map[string]string, len(sessionStored))
+		for k, val := range sessionStored {
+			snapshot[k] = val
+		}
+		mu.Unlock()
+		t, err := m.loadUpTask(f, snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s failed: %w", f.name, err)
+		}
+		return func(ctx context.Context) (*ExecWithVersion, error) {
+			vr, toStore, err := m.execUpTask(ctx, f, t, true)
+			mu.Lock()
+			for k, val := range toStore {
+				sessionStored[k] = val
+			}
+			mu.Unlock()
+			if err != nil {
+				return vr, fmt.Errorf("migration %s failed: %w", f.name, err)
+			}
+			if m.DryRun {
+				return vr, nil
+			}
+			if vr.Result != nil && vr.Result.Skip != nil {
+				m.logger().Warn("on_match is ignored when max_parallel > 1", "version", f.index, "file", f.name)
+			}
+			// Configurable delay to allow backend consistency before dependents start
+			if delay := m.getDelayBetweenMigrations(); delay > 0 {
+				if err := contextSleep(ctx, delay); err != nil {
+					return vr, err
+				}
+			}
+			return vr, nil
+		}, nil
+	}
+	return runDAG(ctx, order, waitFor, m.MaxParallel, prepare)
+}
+
+// migrateDownParallel rolls back the given versions, starting a version only after every
+// version depending on it has been rolled back. Up to MaxParallel downs run concurrently.
+func (m *Migrator) migrateDownParallel(ctx context.Context, files []vfile, toRollback []int) ([]*ExecWithVersion, error) {
+	logger := m.logger()
+	deps, err := loadDependencies(files)
+	if err != nil {
+		return nil, err
+	}
+	fileByVer := mapFilesByVersion(files)
+	inPlan := make(map[int]bool, len(toRollback))
+	for _, v := range toRollback {
+		inPlan[v] = true
+	}
+	waitFor := make(map[int][]int, len(toRollback))
+	for _, v := range toRollback {
+		for _, d := range deps[v] {
+			if inPlan[d] {
+				waitFor[d] = append(waitFor[d], v)
+			}
+		}
+	}
+	prepare := func(v int) (dagJob, error) {
+		f, ok := fileByVer[v]
+		if !ok {
+			return nil, &ErrMissingMigrationFile{Version: v}
+		}
+		logger.Info("rolling back migration", "version", v, "file", f.name)
+		if err := m.ensureAuth(ctx); err != nil {
+			return nil, fmt.Errorf("failed to ensure authentication for down migration: %w", err)
+		}
+		t, err := m.loadDownTask(v, f)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) (*ExecWithVersion, error) {
+			return m.execDownTask(ctx, v, f, t)
+		}, nil
+	}
+	return runDAG(ctx, toRollback, waitFor, m.MaxParallel, prepare)
+}
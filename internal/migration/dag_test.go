@@ -0,0 +1,226 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store"
+)
+
+// writeDAGMigration writes a migration whose up POSTs to /up/<name> and down DELETEs /down/<name>.
+func writeDAGMigration(t *testing.T, dir, file, base, dependsOn string) {
+	t.Helper()
+	name := strings.TrimSuffix(file, ".yaml")
+	body := ""
+	if dependsOn != "" {
+		body = "depends_on: " + dependsOn + "\n"
+	}
+	body += "up:\n  request:\n    method: POST\n    url: " + base + "/up/" + name + "\n  response:\n    result_code: ['200']\n" +
+		"down:\n  method: DELETE\n  url: " + base + "/down/" + name + "\n"
+	writeMigrations(t, dir, map[string]string{file: body})
+}
+
+// concurrencyServer records request paths in arrival order and the peak number of in-flight requests.
+type concurrencyServer struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	paths    []string
+	hold     time.Duration
+	fail     map[string]bool
+}
+
+func (c *concurrencyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.peak {
+		c.peak = c.inFlight
+	}
+	c.paths = append(c.paths, r.URL.Path)
+	fail := c.fail[r.URL.Path]
+	c.mu.Unlock()
+	time.Sleep(c.hold)
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *concurrencyServer) indexOf(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, p := range c.paths {
+		if p == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func newDAGMigrator(t *testing.T, dir string, maxParallel int) (*Migrator, *store.Store) {
+	t.Helper()
+	m, st := newTestMigrator(t, dir)
+	m.MaxParallel = maxParallel
+	return m, st
+}
+
+func TestLoadDependencies_DefaultsAndValidation(t *testing.T) {
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", "http://x", "")
+	writeDAGMigration(t, dir, "002_b.yaml", "http://x", "[]")
+	writeDAGMigration(t, dir, "003_c.yaml", "http://x", "")
+	writeDAGMigration(t, dir, "004_d.yaml", "http://x", "[2]")
+	files, err := listMigrationFiles(nil, dir, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	deps, err := loadDependencies(files)
+	if err != nil {
+		t.Fatalf("loadDependencies: %v", err)
+	}
+	want := map[int][]int{2: {}, 3: {1, 2}, 4: {2}}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("unexpected deps: %v", deps)
+	}
+
+	writeDAGMigration(t, dir, "005_e.yaml", "http://x", "[6]")
+	files, _ = listMigrationFiles(nil, dir, "")
+	if _, err := loadDependencies(files); err == nil || !strings.Contains(err.Error(), "lower version") {
+		t.Fatalf("expected lower version error, got %v", err)
+	}
+	writeDAGMigration(t, dir, "005_e.yaml", "http://x", "[0]")
+	if _, err := loadDependencies(files); err == nil || !strings.Contains(err.Error(), "no such migration") {
+		t.Fatalf("expected missing migration error, got %v", err)
+	}
+}
+
+func TestMigrateUp_MaxParallel_RunsIndependentConcurrently(t *testing.T) {
+	srv := &concurrencyServer{hold: 150 * time.Millisecond}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "[]")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "[]")
+	writeDAGMigration(t, dir, "003_c.yaml", ts.URL, "[]")
+	writeDAGMigration(t, dir, "004_d.yaml", ts.URL, "[1, 2, 3]")
+	m, st := newDAGMigrator(t, dir, 2)
+
+	results, err := m.MigrateUp(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	if srv.peak != 2 {
+		t.Fatalf("expected peak concurrency 2, got %d", srv.peak)
+	}
+	last := srv.indexOf("/up/004_d")
+	for _, dep := range []string{"/up/001_a", "/up/002_b", "/up/003_c"} {
+		if i := srv.indexOf(dep); i < 0 || i > last {
+			t.Fatalf("expected %s before /up/004_d, got order %v", dep, srv.paths)
+		}
+	}
+	applied, _ := st.ListApplied()
+	if !reflect.DeepEqual(applied, []int{1, 2, 3, 4}) {
+		t.Fatalf("expected all versions applied, got %v", applied)
+	}
+}
+
+func TestMigrateUp_MaxParallel_WithoutDependsOnStaysSequential(t *testing.T) {
+	srv := &concurrencyServer{hold: 20 * time.Millisecond}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "")
+	writeDAGMigration(t, dir, "003_c.yaml", ts.URL, "")
+	m, _ := newDAGMigrator(t, dir, 4)
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if srv.peak != 1 {
+		t.Fatalf("expected sequential execution, got peak %d", srv.peak)
+	}
+	if !reflect.DeepEqual(srv.paths, []string{"/up/001_a", "/up/002_b", "/up/003_c"}) {
+		t.Fatalf("unexpected order: %v", srv.paths)
+	}
+}
+
+func TestMigrateUp_MaxParallel_FailedSiblingIsRetriedOnNextRun(t *testing.T) {
+	srv := &concurrencyServer{fail: map[string]bool{"/up/002_b": true}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "[]")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "[]")
+	writeDAGMigration(t, dir, "003_c.yaml", ts.URL, "[1]")
+	m, st := newDAGMigrator(t, dir, 3)
+
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil {
+		t.Fatalf("expected failure from version 2")
+	}
+	applied, _ := st.ListApplied()
+	for _, v := range applied {
+		if v == 2 {
+			t.Fatalf("failed version must not be recorded as applied: %v", applied)
+		}
+	}
+
+	srv.mu.Lock()
+	srv.fail = nil
+	srv.mu.Unlock()
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("second MigrateUp: %v", err)
+	}
+	applied, _ = st.ListApplied()
+	if !reflect.DeepEqual(applied, []int{1, 2, 3}) {
+		t.Fatalf("expected all versions applied after retry, got %v", applied)
+	}
+}
+
+func TestMigrateDown_MaxParallel_RespectsReverseDependencies(t *testing.T) {
+	srv := &concurrencyServer{hold: 100 * time.Millisecond}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "[]")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "[1]")
+	writeDAGMigration(t, dir, "003_c.yaml", ts.URL, "[1]")
+	m, st := newDAGMigrator(t, dir, 3)
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	srv.mu.Lock()
+	srv.paths, srv.peak = nil, 0
+	srv.mu.Unlock()
+
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if srv.peak != 2 {
+		t.Fatalf("expected versions 2 and 3 to roll back concurrently, got peak %d", srv.peak)
+	}
+	if srv.indexOf("/down/001_a") != 2 {
+		t.Fatalf("expected version 1 to roll back last, got %v", srv.paths)
+	}
+	if applied, _ := st.ListApplied(); len(applied) != 0 {
+		t.Fatalf("expected nothing applied, got %v", applied)
+	}
+}
@@ -0,0 +1,27 @@
+package migration
+
+import (
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// beginEnvFiles replaces Env, for one MigrateUp, MigrateDown, MigrateTo, Redo, PlanDiff or Smoke
+// call, with a copy that also holds the values of EnvFiles under its own Global values, and
+// returns a func restoring Env. A nested call (e.g. MigrateTo calling MigrateUp) keeps the copy.
+func (m *Migrator) beginEnvFiles() (func(), error) {
+	if len(m.EnvFiles) == 0 || m.envFilesLoaded {
+		return func() {}, nil
+	}
+	vals, err := env.LoadFiles(m.EnvFiles...)
+	if err != nil {
+		return nil, err
+	}
+	base := m.Env
+	merged := base.Clone()
+	for k, v := range vals {
+		if _, ok := merged.Global[k]; !ok {
+			merged.Global[k] = env.Str(v)
+		}
+	}
+	m.Env, m.envFilesLoaded = merged, true
+	return func() { m.Env, m.envFilesLoaded = base, false }, nil
+}
@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// Later env files override earlier ones, and values set in Env.Global override both
+func TestMigrateUp_EnvFilesPrecedence(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	body := "up:\n  request:\n    method: POST\n    url: '{{.env.api}}/realms/{{.env.realm}}/{{.env.client}}'\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_realm.yaml"), []byte(body), 0o600); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+	files := t.TempDir()
+	base := filepath.Join(files, "base.json")
+	prod := filepath.Join(files, "prod.env")
+	_ = os.WriteFile(base, []byte(`{"api":"http://unused","realm":"base","client":"app"}`), 0o600)
+	_ = os.WriteFile(prod, []byte("api="+srv.URL+"\nrealm=prod\n"), 0o600)
+
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	e := env.New()
+	_ = e.SetString("global", "client", "explicit")
+	m := &Migrator{Dir: dir, Store: *st, Env: e, EnvFiles: []string{base, prod}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/realms/prod/explicit" {
+		t.Fatalf("paths = %v, want [/realms/prod/explicit]", paths)
+	}
+	// The values of the files only live for the run
+	if m.Env != e || e.GetString("global", "api") != "" {
+		t.Fatalf("env files leaked into the base env: %v", e.Global)
+	}
+
+	m.EnvFiles = []string{filepath.Join(files, "missing.yaml")}
+	if _, err := m.MigrateDown(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "failed to read env file") {
+		t.Fatalf("expected an env file error, got %v", err)
+	}
+	if applied, _ := st.ListApplied(); len(applied) != 1 {
+		t.Fatalf("applied = %v after a failed env file load, want [1]", applied)
+	}
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestEnvPrecedence_MigrationEnvAgainstConfigEnv(t *testing.T) {
+	cases := []struct {
+		name       string
+		precedence []string
+		want       string
+	}{
+		{"default local wins", nil, "/migration/config/migration"},
+		{"global wins", []string{"global", "local"}, "/config/config/migration"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Path
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			mig := "up:\n  name: ns\n  env:\n    tier: migration\n  request:\n    method: GET\n" +
+				"    url: " + srv.URL + "/{{.env.tier}}/{{.global.tier}}/{{.local.tier}}\n  response:\n    result_code: ['200']\n"
+			if err := os.WriteFile(filepath.Join(dir, "001_ns.yaml"), []byte(mig), 0o600); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+			defer func() { _ = st.Close() }()
+
+			base := env.New()
+			_ = base.SetString("global", "tier", "config")
+			m := &Migrator{Dir: dir, Store: *st, Env: base, EnvPrecedence: c.precedence}
+			if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+				t.Fatalf("MigrateUp: %v", err)
+			}
+			if got != c.want {
+				t.Fatalf("request path = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnvPrecedence_InvalidOrderFailsTheRun(t *testing.T) {
+	dir := t.TempDir()
+	mig := "up:\n  name: ns\n  request:\n    method: GET\n    url: http://127.0.0.1:1/x\n  response:\n    result_code: ['200']\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_ns.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), EnvPrecedence: []string{"global"}}
+	_, err := m.MigrateUp(context.Background(), 0)
+	if err == nil || !strings.Contains(err.Error(), "must list both") {
+		t.Fatalf("expected a precedence error, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FailedVersionsError is implemented by errors that report which migration versions failed.
+type FailedVersionsError interface {
+	error
+	FailedVersions() []int
+}
+
+// VersionError is the failure of a single migration version.
+type VersionError struct {
+	Version int
+	Err     error
+}
+
+func (e *VersionError) Error() string { return e.Err.Error() }
+
+func (e *VersionError) Unwrap() error { return e.Err }
+
+// MigrationErrors aggregates the failed versions of a ContinueOnError run, in execution order.
+type MigrationErrors struct {
+	Errors []*VersionError
+}
+
+func (e *MigrationErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, ve := range e.Errors {
+		msgs = append(msgs, ve.Error())
+	}
+	return fmt.Sprintf("%d migration(s) failed (versions %v): %s", len(e.Errors), e.FailedVersions(), strings.Join(msgs, "; "))
+}
+
+// FailedVersions returns the failed versions in execution order.
+func (e *MigrationErrors) FailedVersions() []int {
+	out := make([]int, 0, len(e.Errors))
+	for _, ve := range e.Errors {
+		out = append(out, ve.Version)
+	}
+	return out
+}
+
+// Unwrap exposes the individual failures to errors.Is and errors.As.
+func (e *MigrationErrors) Unwrap() []error {
+	out := make([]error, 0, len(e.Errors))
+	for _, ve := range e.Errors {
+		out = append(out, ve)
+	}
+	return out
+}
+
+var _ FailedVersionsError = (*MigrationErrors)(nil)
+
+// FailedVersions returns the versions reported by err when it (or an error it wraps)
+// implements FailedVersionsError, and nil otherwise.
+func FailedVersions(err error) []int {
+	var fv FailedVersionsError
+	if errors.As(err, &fv) {
+		return fv.FailedVersions()
+	}
+	return nil
+}
+
+// ErrVersionGap is reported when the store has applied versions without a migration file, e.g.
+// because files were deleted or Dir points at the wrong directory.
+var ErrVersionGap = errors.New("applied migration versions have no migration file")
+
+// ErrMissingMigrationFile reports an applied version whose migration file is missing.
+// It wraps ErrVersionGap.
+type ErrMissingMigrationFile struct {
+	Version int
+}
+
+func (e *ErrMissingMigrationFile) Error() string {
+	return fmt.Sprintf("no migration file for version %d", e.Version)
+}
+
+func (e *ErrMissingMigrationFile) Unwrap() error { return ErrVersionGap }
+
+// checkVersionGap reports ErrVersionGap when cur is above the newest migration file.
+func checkVersionGap(files []vfile, cur int) error {
+	latest := 0
+	for _, f := range files {
+		if f.index > latest {
+			latest = f.index
+		}
+	}
+	if cur > latest {
+		return fmt.Errorf("%w: current version %d is above the newest migration file (version %d)", ErrVersionGap, cur, latest)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestMigrationErrors_ReportsVersionsAndUnwraps(t *testing.T) {
+	sentinel := errors.New("boom")
+	err := fmt.Errorf("run: %w", &MigrationErrors{Errors: []*VersionError{
+		{Version: 2, Err: fmt.Errorf("migration 002_b.yaml failed: %w", sentinel)},
+		{Version: 4, Err: errors.New("migration 004_d.yaml failed: bad status")},
+	}})
+	if got := FailedVersions(err); !reflect.DeepEqual(got, []int{2, 4}) {
+		t.Fatalf("unexpected failed versions: %v", got)
+	}
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("expected errors.Is to reach the step error")
+	}
+	if !strings.Contains(err.Error(), "versions [2 4]") || !strings.Contains(err.Error(), "004_d.yaml") {
+		t.Fatalf("unexpected message: %v", err)
+	}
+	if FailedVersions(errors.New("plain")) != nil {
+		t.Fatalf("plain errors report no versions")
+	}
+}
+
+func TestMigrateUp_ContinueOnError_RunsAllAndRetriesFailures(t *testing.T) {
+	srv := &concurrencyServer{fail: map[string]bool{"/up/002_b": true, "/up/004_d": true}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	for _, f := range []string{"001_a.yaml", "002_b.yaml", "003_c.yaml", "004_d.yaml", "005_e.yaml"} {
+		writeDAGMigration(t, dir, f, ts.URL, "")
+	}
+	m, st := newDAGMigrator(t, dir, 0)
+	m.ContinueOnError = true
+
+	results, err := m.MigrateUp(context.Background(), 0)
+	var fv FailedVersionsError
+	if !errors.As(err, &fv) || !reflect.DeepEqual(fv.FailedVersions(), []int{2, 4}) {
+		t.Fatalf("expected aggregate error for versions 2 and 4, got %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected every version to be attempted, got %d results", len(results))
+	}
+	for _, r := range results {
+		if r.Failed != (r.Version == 2 || r.Version == 4) {
+			t.Fatalf("unexpected failed flag for version %d: %v", r.Version, r.Failed)
+		}
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1}) {
+		t.Fatalf("store must only advance over leading successes, got %v", applied)
+	}
+	runs, _ := st.ListRuns()
+	failedRuns := 0
+	for _, r := range runs {
+		if r.Failed {
+			failedRuns++
+		}
+	}
+	if len(runs) != 5 || failedRuns != 2 {
+		t.Fatalf("expected 5 recorded runs with 2 failures, got %d/%d", len(runs), failedRuns)
+	}
+
+	// The re-run starts at the first failure
+	srv.mu.Lock()
+	srv.fail, srv.paths = nil, nil
+	srv.mu.Unlock()
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("second MigrateUp: %v", err)
+	}
+	if !reflect.DeepEqual(srv.paths, []string{"/up/002_b", "/up/003_c", "/up/004_d", "/up/005_e"}) {
+		t.Fatalf("unexpected retried versions: %v", srv.paths)
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1, 2, 3, 4, 5}) {
+		t.Fatalf("expected all versions applied after retry, got %v", applied)
+	}
+}
+
+func TestMigrateUp_FailFastByDefault(t *testing.T) {
+	srv := &concurrencyServer{fail: map[string]bool{"/up/001_a": true}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "")
+	m, _ := newDAGMigrator(t, dir, 0)
+
+	_, err := m.MigrateUp(context.Background(), 0)
+	if err == nil || FailedVersions(err) != nil {
+		t.Fatalf("expected a plain step error, got %v", err)
+	}
+	if !reflect.DeepEqual(srv.paths, []string{"/up/001_a"}) {
+		t.Fatalf("expected execution to stop at the first failure, got %v", srv.paths)
+	}
+}
+
+func TestMissingMigrationFile_TypedErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"001_a.yaml", "002_b.yaml"} {
+		mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "\ndown:\n  method: DELETE\n  url: " + srv.URL + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New()}
+	ctx := context.Background()
+	if _, err := m.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "002_b.yaml")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	// Up runs and plans: the current version is above the newest file
+	for name, run := range map[string]func() error{
+		"MigrateUp": func() error { _, err := m.MigrateUp(ctx, 0); return err },
+		"PlanUp":    func() error { _, err := m.PlanUp(ctx, 0); return err },
+	} {
+		if err := run(); !errors.Is(err, ErrVersionGap) {
+			t.Fatalf("%s: expected ErrVersionGap, got %v", name, err)
+		}
+	}
+
+	// Down runs, plans and redo: the applied version 2 has no file
+	for name, run := range map[string]func() error{
+		"MigrateDown": func() error { _, err := m.MigrateDown(ctx, 0); return err },
+		"PlanDown":    func() error { _, err := m.PlanDown(ctx, 0); return err },
+		"Redo":        func() error { _, err := m.Redo(ctx, 0); return err },
+	} {
+		err := run()
+		var missing *ErrMissingMigrationFile
+		if !errors.As(err, &missing) || missing.Version != 2 || !errors.Is(err, ErrVersionGap) {
+			t.Fatalf("%s: expected ErrMissingMigrationFile{2}, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/loykin/apirun/internal/task"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// StepInfo describes the migration step a hook is invoked for.
+// Env is the task env the request templates are rendered with (global, auth and stored values merged).
+type StepInfo struct {
+	Version   int
+	Direction string
+	Name      string
+	Env       *env.Env
+}
+
+// BeforeStepFunc runs before a step sends its request. A non-nil error aborts the step.
+type BeforeStepFunc func(ctx context.Context, step StepInfo) error
+
+// AfterStepFunc runs once a step has executed. res is nil when no response was received.
+type AfterStepFunc func(ctx context.Context, step StepInfo, res *task.ExecResult, err error) error
+
+// runBeforeStep invokes the BeforeStep hook when configured.
+func (m *Migrator) runBeforeStep(ctx context.Context, step StepInfo) error {
+	if m.BeforeStep == nil {
+		return nil
+	}
+	if err := m.BeforeStep(ctx, step); err != nil {
+		return fmt.Errorf("before-step hook aborted %s of version %d: %w", step.Direction, step.Version, err)
+	}
+	return nil
+}
+
+// runAfterStep invokes the AfterStep hook when configured. Hook errors are logged and only
+// returned when FailOnAfterHookError is set.
+func (m *Migrator) runAfterStep(ctx context.Context, step StepInfo, res *task.ExecResult, stepErr error) error {
+	if m.AfterStep == nil {
+		return nil
+	}
+	err := m.AfterStep(ctx, step, res, stepErr)
+	if err == nil {
+		return nil
+	}
+	logger := m.logger().WithVersion(step.Version)
+	logger.Warn("after-step hook failed", "direction", step.Direction, "name", step.Name, "error", err)
+	if m.FailOnAfterHookError {
+		return fmt.Errorf("after-step hook failed for %s of version %d: %w", step.Direction, step.Version, err)
+	}
+	return nil
+}
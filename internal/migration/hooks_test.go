@@ -0,0 +1,136 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loykin/apirun/internal/task"
+)
+
+// writeHookMigrations creates two migrations; the first extracts "uid" which the second up and
+// the first down (through stored env) use in their URLs.
+func writeHookMigrations(t *testing.T, dir, base string) {
+	t.Helper()
+	m1 := "up:\n  name: create\n  request:\n    method: POST\n    url: " + base + "/users/{{.env.tenant}}\n  response:\n    result_code: ['200']\n    env_from:\n      uid: id\n" +
+		"down:\n  name: delete\n  method: DELETE\n  url: " + base + "/users/{{.env.uid}}\n"
+	m2 := "up:\n  name: patch\n  request:\n    method: PATCH\n    url: " + base + "/users/{{.env.uid}}\n  response:\n    result_code: ['200']\n" +
+		"down:\n  name: unpatch\n  method: DELETE\n  url: " + base + "/patch\n"
+	writeMigrations(t, dir, map[string]string{"001_create.yaml": m1, "002_patch.yaml": m2})
+}
+
+func TestHooks_InvocationOrderAndRenderedEnv(t *testing.T) {
+	var events []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events = append(events, "request "+r.Method+" "+r.URL.Path)
+		_, _ = w.Write([]byte(`{"id":"u-1"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writeHookMigrations(t, dir, srv.URL)
+	m, _ := newTestMigrator(t, dir)
+	_ = m.Env.SetString("global", "tenant", "acme")
+	m.BeforeStep = func(ctx context.Context, step StepInfo) error {
+		uid, _ := step.Env.Lookup("uid")
+		tenant, _ := step.Env.Lookup("tenant")
+		events = append(events, fmt.Sprintf("before %s v%d %s tenant=%s uid=%s", step.Direction, step.Version, step.Name, tenant, uid))
+		return nil
+	}
+	m.AfterStep = func(ctx context.Context, step StepInfo, res *task.ExecResult, err error) error {
+		events = append(events, fmt.Sprintf("after %s v%d %s status=%d err=%v", step.Direction, step.Version, step.Name, res.StatusCode, err))
+		return nil
+	}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	want := []string{
+		"before up v1 create tenant=acme uid=",
+		"request POST /users/acme",
+		"after up v1 create status=200 err=<nil>",
+		"before up v2 patch tenant=acme uid=u-1",
+		"request PATCH /users/u-1",
+		"after up v2 patch status=200 err=<nil>",
+		"before down v2 unpatch tenant=acme uid=",
+		"request DELETE /patch",
+		"after down v2 unpatch status=200 err=<nil>",
+		"before down v1 delete tenant=acme uid=u-1",
+		"request DELETE /users/u-1",
+		"after down v1 delete status=200 err=<nil>",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("unexpected events:\n%q", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("event %d: want %q, got %q", i, want[i], events[i])
+		}
+	}
+}
+
+func TestHooks_BeforeStepErrorAbortsStep(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(`{"id":"u-1"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writeHookMigrations(t, dir, srv.URL)
+	m, st := newTestMigrator(t, dir)
+	_ = m.Env.SetString("global", "tenant", "acme")
+	m.BeforeStep = func(ctx context.Context, step StepInfo) error {
+		if step.Version == 2 {
+			return errors.New("maintenance window closed")
+		}
+		return nil
+	}
+	_, err := m.MigrateUp(context.Background(), 0)
+	if err == nil {
+		t.Fatalf("expected MigrateUp to fail")
+	}
+	if hits != 1 {
+		t.Fatalf("expected only version 1 to send a request, got %d hits", hits)
+	}
+	if cur, _ := st.CurrentVersion(); cur != 1 {
+		t.Fatalf("expected current version 1, got %d", cur)
+	}
+}
+
+func TestHooks_AfterStepErrorFailsOnlyWhenConfigured(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"u-1"}`))
+	}))
+	defer srv.Close()
+
+	run := func(failOnErr bool) (int, error) {
+		dir := t.TempDir()
+		writeHookMigrations(t, dir, srv.URL)
+		m, st := newTestMigrator(t, dir)
+		_ = m.Env.SetString("global", "tenant", "acme")
+		m.FailOnAfterHookError = failOnErr
+		m.AfterStep = func(ctx context.Context, step StepInfo, res *task.ExecResult, err error) error {
+			return errors.New("metrics endpoint unavailable")
+		}
+		_, err := m.MigrateUp(context.Background(), 0)
+		cur, _ := st.CurrentVersion()
+		return cur, err
+	}
+
+	cur, err := run(false)
+	if err != nil || cur != 2 {
+		t.Fatalf("expected hook error to be ignored, got cur=%d err=%v", cur, err)
+	}
+	cur, err = run(true)
+	if err == nil || cur != 0 {
+		t.Fatalf("expected hook error to fail the first step, got cur=%d err=%v", cur, err)
+	}
+}
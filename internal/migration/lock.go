@@ -0,0 +1,21 @@
+package migration
+
+// lockRun takes the store's run lock for one MigrateUp, MigrateDown, MigrateTo, Redo or Baseline
+// call unless m already holds it (e.g. MigrateTo calling MigrateUp) or runs dry, and returns the
+// func releasing it.
+func (m *Migrator) lockRun() (func(), error) {
+	if m.runLocked || m.DryRun {
+		return func() {}, nil
+	}
+	unlock, err := m.Store.Lock()
+	if err != nil {
+		return nil, err
+	}
+	m.runLocked = true
+	return func() {
+		m.runLocked = false
+		if err := unlock(); err != nil {
+			m.logger().Warn("failed to release the migration lock", "error", err)
+		}
+	}, nil
+}
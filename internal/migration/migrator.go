@@ -3,24 +3,60 @@ package migration
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"sort"
+	"io/fs"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
 	"time"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/loykin/apirun/internal/auth"
-	acommon "github.com/loykin/apirun/internal/auth/common"
+	"github.com/loykin/apirun/internal/auth/ntlm"
 	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/httpc"
 	"github.com/loykin/apirun/internal/store"
 	"github.com/loykin/apirun/internal/task"
 	"github.com/loykin/apirun/pkg/env"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Migrator struct {
-	Dir              string
+	// Dir is the migration directory; when FS is set it is a path within FS.
+	Dir string
+	// VersionPattern is the regular expression migration file names must match; its first capture
+	// group is the version ("" = DefaultVersionPattern).
+	VersionPattern string
+	// EnvPrecedence orders the env layers for {{.env.x}} and lookups, the first winning:
+	// "local" (the migration's env block and stored env_from values) and "global" (the base Env),
+	// each once. nil keeps env.DefaultPrecedence, local over global. {{.local.x}} and
+	// {{.global.x}} always read one layer.
+	EnvPrecedence []string
+	// EnvFiles are YAML, JSON or dotenv files (by extension) whose values are added to the global
+	// env for each run, later files overriding earlier ones; values set in Env.Global win.
+	EnvFiles []string
+	// FS, when set, is used instead of the local filesystem to read migration files and body_file references.
+	FS               fs.FS
 	Store            store.Store
 	Env              *env.Env
 	Auth             []auth.Auth
 	SaveResponseBody bool
+	// RecordDir, when set, receives a JSON file per executed up/down step (see StepRecording)
+	// with its requests and full responses, masked, whatever SaveResponseBody says. Dry runs
+	// write nothing. It is independent of the store.
+	RecordDir string
+	// MaxResponseBodyBytes bounds response bodies (0 = unbounded). Saved bodies longer than it are
+	// truncated with a marker; a body more than task.ResponseLimitMargin over it is not read and
+	// fails the step.
+	MaxResponseBodyBytes int64
+	// SaveResponseHeaders records the response headers named in an up step's header_from with
+	// its run in the history (values are masked like URLs).
+	SaveResponseHeaders bool
+	// EnableCookies shares a cookie jar between the up, down, find and probe requests of a run, so
+	// that a session cookie set by a login step is sent by later steps. The jar lives for one
+	// MigrateUp, MigrateDown or Redo call; cookies are not kept across calls or in the store.
+	EnableCookies bool
 	// RenderBodyDefault controls default templating for RequestSpec bodies when not set per-request.
 	// nil means default to true (render). When false, bodies with templates like {{...}} are sent as-is, unrendered.
 	RenderBodyDefault *bool
@@ -31,9 +67,119 @@ type Migrator struct {
 	DryRunFrom int
 	// TLSConfig applies to all HTTP requests executed by tasks during migrations.
 	TLSConfig *tls.Config
+	// CAFile is a PEM bundle of the CAs server certificates are verified against instead of the
+	// system roots, added to RootCAs. A file that cannot be loaded fails the run before any request.
+	CAFile string
+	// RootCAs replaces the system roots (and the RootCAs of TLSConfig) when set.
+	RootCAs *x509.CertPool
+	// Transport tunes the HTTP transport of task requests and notifications (nil = defaults).
+	Transport *task.TransportConfig
 	// DelayBetweenMigrations configures the delay between migration executions for backend consistency.
 	// If not set, defaults to 1 second. Set to 0 to disable delays.
 	DelayBetweenMigrations time.Duration
+	// RetryPolicy is the default retry policy for requests that don't declare their own `retry` block.
+	// nil keeps the HTTP client's built-in default policy.
+	RetryPolicy *task.RetryPolicy
+	// TokenCache holds tokens acquired for Auth entries keyed by auth name. When nil, a cache is
+	// created on first use and lives for the duration of this Migrator value.
+	TokenCache *auth.TokenCache
+	// TokenExpirySkew re-acquires cached tokens this long before they expire (0 = 30s default).
+	TokenExpirySkew time.Duration
+	// BeforeStep and AfterStep are called around every up/down step that sends requests
+	// (not in dry-run mode). A BeforeStep error aborts the step.
+	BeforeStep BeforeStepFunc
+	AfterStep  AfterStepFunc
+	// FailOnAfterHookError fails the step when AfterStep returns an error; otherwise it is only logged.
+	FailOnAfterHookError bool
+	// MaxParallel runs up to N migrations concurrently when their depends_on allow it.
+	// 0 or 1 keeps strictly sequential execution. Hooks may then be called concurrently.
+	MaxParallel int
+	// ContinueOnError keeps applying later versions when a sequential up step fails. The store
+	// version only advances over the leading successes, so a re-run retries from the first failure;
+	// MigrateUp then returns a *MigrationErrors. Ignored when MaxParallel > 1.
+	ContinueOnError bool
+	// StrictChecksums makes MigrateUp fail when an applied migration file changed since it was
+	// applied; otherwise the change is only logged as a warning.
+	StrictChecksums bool
+	// RollbackOnCancel makes MigrateUp, when its context is cancelled mid-run, run the down steps
+	// of the versions it applied (newest first) before returning. The rollback can fail too.
+	RollbackOnCancel bool
+	// Notify, when set, posts a summary once MigrateUp/MigrateDown finishes (success or failure).
+	Notify *NotifyConfig
+	// DefaultHeaders are sent with every up, down and find request. Values are templated like
+	// per-request headers, which take precedence on a (case-insensitive) name collision.
+	DefaultHeaders map[string]string
+	// AuthInjection, when set, adds an auth header (e.g. "Authorization: Bearer <token>") to every
+	// up, down and find request; it replaces a DefaultHeaders entry of the same name.
+	AuthInjection *AuthInjection
+	// Tags, when set, limits up runs to the migrations tagged with at least one of them. The
+	// run stops at the first pending migration without a selected tag unless TagsAllowGaps is
+	// set, in which case such migrations are skipped and stay pending.
+	Tags          []string
+	TagsAllowGaps bool
+	// TracerProvider, when set, traces MigrateUp/MigrateDown runs: a span per run, a child span per
+	// executed step and one per HTTP request. nil disables tracing.
+	TracerProvider trace.TracerProvider
+	// RequestMiddleware and ResponseMiddleware run for every up, down, find and probe request
+	// (see task.Middleware for their ordering). Auth providers and notifications do not use them.
+	RequestMiddleware  []func(*resty.Request)
+	ResponseMiddleware []func(*resty.Response)
+	// ContextKeys maps template names to context keys: before each step, ctx.Value(key) is
+	// rendered as {{.ctx.<name>}}. A missing value renders empty, or fails the step when its
+	// response (for down steps, its find response) sets env_missing: fail.
+	ContextKeys map[string]interface{}
+	// RunID is the correlation id of the run: it is rendered as {{.run_id}}, added to the log
+	// lines and recorded with every run record. When empty, MigrateUp, MigrateDown, MigrateTo and
+	// Redo generate a UUID for each call.
+	RunID string
+	// CircuitBreaker, when set, makes up, down, find and probe requests to a host that failed
+	// repeatedly fail fast for a cooldown period. It is independent of the per-request retries.
+	CircuitBreaker *task.CircuitBreakerConfig
+	// Breaker holds the circuit state. When nil and CircuitBreaker is set, a breaker is created on
+	// first use and lives for the duration of this Migrator value.
+	Breaker *task.CircuitBreaker
+	// NTLMCredentials keeps the credentials acquired by negotiate auth entries. When nil, it is
+	// created on first use and lives for the duration of this Migrator value.
+	NTLMCredentials *ntlm.Credentials
+	// runLocked is set while a call holds the store's run lock (see lockRun)
+	runLocked bool
+	// globals holds the scope: global env_from values of the current run (see beginRunGlobals)
+	globals *runGlobals
+	// envFilesLoaded is set while Env holds the values of EnvFiles (see beginEnvFiles)
+	envFilesLoaded bool
+}
+
+// withRequestOptions attaches the request/response middleware, the NTLM credentials and handshake
+// transport, the circuit breaker, the response size limit, a new cache for body_file URLs and,
+// with EnableCookies, a new cookie jar to ctx for the task requests.
+func (m *Migrator) withRequestOptions(ctx context.Context) context.Context {
+	if m.EnableCookies {
+		jar, _ := cookiejar.New(nil) // never fails without options
+		ctx = task.WithCookieJar(ctx, jar)
+	}
+	ctx = task.WithMiddleware(ctx, task.Middleware{Request: m.RequestMiddleware, Response: m.ResponseMiddleware})
+	ctx = task.WithBodyFileCache(ctx)
+	if m.NTLMCredentials == nil {
+		m.NTLMCredentials = ntlm.NewCredentials()
+	}
+	ctx = ntlm.WithCredentials(ctx, m.NTLMCredentials)
+	ctx = task.WithTransport(ctx, m.NTLMCredentials.WrapTransport)
+	if m.Breaker == nil && m.CircuitBreaker != nil {
+		m.Breaker = task.NewCircuitBreaker(*m.CircuitBreaker)
+	}
+	if m.Breaker != nil {
+		// Outermost, so that it sees the outcome of the NTLM handshake
+		ctx = task.WithTransport(ctx, m.Breaker.WrapTransport)
+	}
+	return task.WithResponseLimit(ctx, m.MaxResponseBodyBytes)
+}
+
+// getTokenExpirySkew returns the configured skew or the default value
+func (m *Migrator) getTokenExpirySkew() time.Duration {
+	if m.TokenExpirySkew > 0 {
+		return m.TokenExpirySkew
+	}
+	return auth.DefaultTokenExpirySkew
 }
 
 // getDelayBetweenMigrations returns the configured delay or default value
@@ -58,27 +204,54 @@ func contextSleep(ctx context.Context, duration time.Duration) error {
 	}
 }
 
+// applyContextValues sets e.Ctx from the ContextKeys values carried by ctx. Missing values are
+// set empty, or reported as an error when failMissing is true.
+func (m *Migrator) applyContextValues(ctx context.Context, e *env.Env, failMissing bool) error {
+	if len(m.ContextKeys) == 0 || e == nil {
+		return nil
+	}
+	e.Ctx = env.Map{}
+	for name, key := range m.ContextKeys {
+		v := ctx.Value(key)
+		if v == nil {
+			if failMissing {
+				return fmt.Errorf("context value %q is missing (env_missing: fail)", name)
+			}
+			e.Ctx[name] = env.Str("")
+			continue
+		}
+		e.Ctx[name] = env.Str(fmt.Sprint(v))
+	}
+	return nil
+}
+
 // initTaskAndEnv loads task from file and initializes env for up/down, merges stored/session env as needed.
 func (m *Migrator) initTaskAndEnv(t *task.Task, f vfile, ver int, sessionStored map[string]string, mode string) error {
-	if err := t.LoadFromFile(f.path); err != nil {
+	if err := f.load(t); err != nil {
 		return fmt.Errorf("failed to load %s: %w", f.name, err)
 	}
+	if err := env.ValidatePrecedence(m.EnvPrecedence); err != nil {
+		return err
+	}
+	defaultHeaders, err := m.requestDefaultHeaders()
+	if err != nil {
+		return err
+	}
 	if mode == "up" {
+		t.Up.Request.FS = f.fsys
+		t.Up.Request.DefaultHeaders = defaultHeaders
 		// prepare up env
 		t.Up.Env = m.prepareTaskEnv(t.Up.Env)
 		// Merge stored env from previously applied versions
-		var applied []int
-		if m.DryRun {
-			for i := 1; i <= m.DryRunFrom; i++ {
-				applied = append(applied, i)
-			}
-		} else if list, err := m.Store.ListApplied(); err == nil {
-			applied = list
-		}
+		applied, _ := m.appliedVersions()
 		if len(applied) > 0 {
 			for _, av := range applied {
 				if m2, _ := m.Store.LoadStoredEnv(av); len(m2) > 0 {
 					for k, val := range m2 {
+						// A kept up body belongs to the down step of its own version only
+						if k == task.UpBodyEnvKey || k == skippedEnvKey {
+							continue
+						}
 						if _, exists := t.Up.Env.Local[k]; !exists {
 							t.Up.Env.Local[k] = env.Str(val)
 						}
@@ -99,6 +272,17 @@ func (m *Migrator) initTaskAndEnv(t *task.Task, f vfile, ver int, sessionStored
 			val := *m.RenderBodyDefault
 			t.Up.Request.RenderBody = &val
 		}
+		// Apply global retry policy if request didn't set its own
+		if t.Up.Request.Retry == nil {
+			t.Up.Request.Retry = m.RetryPolicy
+		}
+		if p := t.Up.Probe; p != nil {
+			p.Request.FS = f.fsys
+			p.Request.DefaultHeaders = defaultHeaders
+			if p.Request.Retry == nil {
+				p.Request.Retry = m.RetryPolicy
+			}
+		}
 		return nil
 	}
 	// down mode
@@ -117,16 +301,29 @@ func (m *Migrator) initTaskAndEnv(t *task.Task, f vfile, ver int, sessionStored
 			}
 		}
 	}
+	t.Down.DefaultHeaders = defaultHeaders
+	if t.Down.Find != nil {
+		t.Down.Find.Request.FS = f.fsys
+		t.Down.Find.Request.DefaultHeaders = defaultHeaders
+	}
 	// Apply global default for body rendering on optional Find.Request if not set
 	if t.Down.Find != nil && t.Down.Find.Request.RenderBody == nil && m.RenderBodyDefault != nil {
 		val := *m.RenderBodyDefault
 		t.Down.Find.Request.RenderBody = &val
 	}
+	// Apply global retry policy to down and its optional Find request if not set
+	if t.Down.Retry == nil {
+		t.Down.Retry = m.RetryPolicy
+	}
+	if t.Down.Find != nil && t.Down.Find.Request.Retry == nil {
+		t.Down.Find.Request.Retry = m.RetryPolicy
+	}
 	return nil
 }
 
 // prepareTaskEnv returns a per-task environment initialized from the Migrator base env.
-// It guarantees non-nil Env and maps for Auth/Global/Local. Global/Auth are copied from m.Env.Clone().
+// It guarantees non-nil Env and maps for Auth/Global/Local. Global/Auth are copied from m.Env.Clone(),
+// Local is the base Local overlaid with the task's own env.
 func (m *Migrator) prepareTaskEnv(current *env.Env) *env.Env {
 	// Start with a concrete env instance
 	if current == nil {
@@ -136,6 +333,7 @@ func (m *Migrator) prepareTaskEnv(current *env.Env) *env.Env {
 	cl := (*env.Env)(nil)
 	if m != nil {
 		cl = m.Env.Clone()
+		m.applyGlobals(cl)
 	} else {
 		cl = env.New()
 	}
@@ -149,17 +347,55 @@ func (m *Migrator) prepareTaskEnv(current *env.Env) *env.Env {
 	} else if current.Auth == nil {
 		current.Auth = env.Map{}
 	}
-	if cl.Local != nil {
-		current.Local = cl.Local
-	} else if current.Local == nil {
-		current.Local = env.Map{}
+	// The task's own env (its `env:` block) overrides base Local values
+	local := cl.Local
+	if local == nil {
+		local = env.Map{}
+	}
+	for k, v := range current.Local {
+		local[k] = v
+	}
+	current.Local = local
+	if m != nil {
+		current.RunID = m.RunID
+		current.Precedence = m.EnvPrecedence
 	}
 	return current
 }
 
+// clientTLSConfig returns TLSConfig with the CAs of RootCAs and CAFile as its root CAs.
+func (m *Migrator) clientTLSConfig() (*tls.Config, error) {
+	pool := m.RootCAs
+	if strings.TrimSpace(m.CAFile) != "" {
+		var err error
+		if pool, err = httpc.AppendCAFile(pool, m.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return httpc.WithRootCAs(m.TLSConfig, pool), nil
+}
+
+// withClientTransport attaches one transport, built from the TLS and transport settings, to ctx
+// so that the requests of a run share its keep-alive connections. A transport already attached
+// by an enclosing run is kept. The returned func closes the idle connections of a new transport.
+func (m *Migrator) withClientTransport(ctx context.Context) (context.Context, func(), error) {
+	if httpc.SharedTransport(ctx) != nil {
+		return ctx, func() {}, nil
+	}
+	cfg, err := m.clientTLSConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	h := httpc.Httpc{TlsConfig: cfg, Transport: m.Transport}
+	transport := h.NewTransport()
+	return httpc.WithSharedTransport(ctx, transport), transport.CloseIdleConnections, nil
+}
+
 // ensureAuth wires lazy acquisition for configured auth entries instead of acquiring immediately.
 // It prepares Env.AuthAcquire and pre-fills Env.Auth with empty values for referenced names so that
 // templates like {{.auth.name}} trigger acquisition on demand. Existing non-empty Env.Auth values are kept.
+// Lazy values consult the token cache first and only hit the provider when the cached token is missing
+// or within TokenExpirySkew of expiry. It is called before each migration so expiring tokens get refreshed.
 func (m *Migrator) ensureAuth(ctx context.Context) error {
 	if m == nil || m.Auth == nil || len(m.Auth) == 0 {
 		return nil
@@ -171,26 +407,49 @@ func (m *Migrator) ensureAuth(ctx context.Context) error {
 	if m.Env.Auth == nil {
 		m.Env.Auth = env.Map{}
 	}
-	for i := range m.Auth {
-		a := m.Auth[i]
+	if m.TokenCache == nil {
+		m.TokenCache = auth.NewTokenCache()
+	}
+	cache := m.TokenCache
+	skew := m.getTokenExpirySkew()
+	// Install dependencies first; a chained entry resolves its depends_on token when acquired
+	ordered, err := auth.Order(m.Auth)
+	if err != nil {
+		return err
+	}
+	for i := range ordered {
+		a := ordered[i]
 		name := a.Name
 		if name == "" {
 			continue
 		}
-		// Keep non-empty preset if already provided; otherwise set lazy
-		if v, ok := m.Env.Auth[name]; ok {
-			if v != nil && v.String() != "" {
+		// Keep non-empty preset if already provided (lazy values installed here are refreshed)
+		if v, ok := m.Env.Auth[name]; ok && v != nil {
+			if _, lazy := v.(*env.VarLazy); !lazy && v.String() != "" {
 				continue
 			}
 		}
+		// Dry runs never contact auth providers; render a placeholder instead
+		if m.DryRun {
+			m.Env.Auth[name] = env.Str(task.PlaceholderValue("auth:" + name))
+			continue
+		}
 		// Directly call provider from the lazy resolver without intermediate procs map
 		authCfg := a
 		m.Env.Auth[name] = m.Env.MakeLazy(func(e *env.Env) (string, error) {
+			if tok, ok := cache.Get(name, skew); ok {
+				return tok, nil
+			}
 			cctx := ctx
 			if cctx == nil {
 				cctx = context.Background()
 			}
-			return authCfg.Acquire(cctx, e)
+			tok, expiresAt, err := authCfg.AcquireWithExpiry(cctx, e)
+			if err != nil {
+				return "", err
+			}
+			cache.Put(name, tok, expiresAt)
+			return tok, nil
 		})
 	}
 	return nil
@@ -199,129 +458,371 @@ func (m *Migrator) ensureAuth(ctx context.Context) error {
 // MigrateUp applies migrations greater than the current store version up to targetVersion.
 // If targetVersion <= 0, it applies all pending migrations.
 // It records each applied version in the store after successful execution.
-func (m *Migrator) runUpForFile(ctx context.Context, f vfile, sessionStored map[string]string) (*ExecWithVersion, map[string]string, error) {
+func (m *Migrator) runUpForFile(ctx context.Context, f vfile, sessionStored map[string]string, apply bool) (*ExecWithVersion, map[string]string, error) {
+	t, err := m.loadUpTask(f, sessionStored)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.execUpTask(ctx, f, t, apply)
+}
+
+// loadUpTask loads the up task of f with its env prepared. It touches shared migrator state
+// (env, store reads) and is therefore not run concurrently.
+func (m *Migrator) loadUpTask(f vfile, sessionStored map[string]string) (*task.Task, error) {
 	var t task.Task
 	if err := m.initTaskAndEnv(&t, f, f.index, sessionStored, "up"); err != nil {
-		return nil, nil, fmt.Errorf("failed to initialize task for migration version %d: %w", f.index, err)
+		return nil, fmt.Errorf("failed to initialize task for migration version %d: %w", f.index, err)
 	}
-	res, err := t.Up.Execute(ctx, "", "")
-	ewv := &ExecWithVersion{Version: f.index, Result: res}
-	if res != nil {
-		save := m.SaveResponseBody
-		var bodyPtr *string
-		if save {
-			b := res.ResponseBody
-			bodyPtr = &b
+	return &t, nil
+}
+
+// execUpTask executes (or, in dry-run mode, renders) a loaded up task and records the run. With
+// apply a successful step also marks the version applied.
+func (m *Migrator) execUpTask(ctx context.Context, f vfile, t *task.Task, apply bool) (*ExecWithVersion, map[string]string, error) {
+	if err := m.applyContextValues(ctx, t.Up.Env, t.Up.Response.FailsOnMissing()); err != nil {
+		return nil, nil, fmt.Errorf("migration version %d: %w", f.index, err)
+	}
+	if m.DryRun {
+		req, err := t.Up.Plan("", "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("migration version %d render failed: %w", f.index, err)
 		}
+		// Later versions may reference env_from values of this one; make them resolvable
 		toStore := map[string]string{}
+		for _, k := range t.Up.Response.ExtractedKeys() {
+			toStore[k] = task.PlaceholderValue(k)
+		}
+		return &ExecWithVersion{Version: f.index, Requests: []*task.RenderedRequest{req}}, toStore, nil
+	}
+	step := StepInfo{Version: f.index, Direction: "up", Name: t.Up.Name, Env: t.Up.Env}
+	if err := m.runBeforeStep(ctx, step); err != nil {
+		return nil, nil, err
+	}
+	sctx, span := m.startStepSpan(ctx, step)
+	sctx, rec := m.startRecording(sctx, step)
+	res, err := t.Up.Execute(sctx, "", "")
+	m.finishRecording(rec, err)
+	ewv := &ExecWithVersion{Version: f.index, Result: res}
+	if herr := m.runAfterStep(ctx, step, res, err); herr != nil && err == nil {
+		err = herr
+	}
+	endStepSpan(span, res, err)
+	ewv.Failed = err != nil
+	var toStore map[string]string
+	if res != nil {
+		toStore = map[string]string{}
 		if res.ExtractedEnv != nil {
 			toStore = res.ExtractedEnv
 		}
-		if !m.DryRun {
-			_ = m.Store.RecordRun(f.index, "up", res.StatusCode, bodyPtr, toStore, err != nil)
-			_ = m.Store.InsertStoredEnv(f.index, toStore)
-		}
+	}
+	if rerr := m.recordUp(f, t, res, err, toStore, apply); rerr != nil {
 		if err != nil {
-			return ewv, toStore, fmt.Errorf("migration version %d execution failed: %w", f.index, err)
+			m.logger().Error("failed to record the failed migration", "version", f.index, "error", rerr)
+		} else {
+			ewv.Failed = true
+			return ewv, toStore, fmt.Errorf("migration version %d: %w", f.index, rerr)
 		}
-		return ewv, toStore, nil
 	}
 	if err != nil {
+		if res != nil {
+			return ewv, toStore, fmt.Errorf("migration version %d execution failed: %w", f.index, err)
+		}
 		return ewv, nil, fmt.Errorf("migration version %d failed with no result: %w", f.index, err)
 	}
-	return ewv, nil, nil
+	m.promoteGlobals(t.Up.Response, toStore)
+	return ewv, toStore, nil
+}
+
+// recordUp records the run and stored env of an executed up step and, with apply and no
+// execErr, marks the version applied. The request has already been made; the transaction
+// only keeps the bookkeeping consistent, so a version is never applied without its run.
+func (m *Migrator) recordUp(f vfile, t *task.Task, res *task.ExecResult, execErr error, toStore map[string]string, apply bool) error {
+	var bodyPtr *string
+	var details store.RunDetails
+	var stored map[string]string
+	if res != nil {
+		bodyPtr = m.savedBody(f.index, "up", res.ResponseBody)
+		details = m.runDetails(res)
+		if m.SaveResponseHeaders {
+			details.Headers = selectedHeaders(res, t.Up.Response)
+		}
+		stored = m.storedEnv(f.index, t, res, execErr, toStore)
+	}
+	return m.Store.WithTx(func(tx *store.Store) error {
+		if apply && execErr == nil {
+			if err := applyVersion(tx, f); err != nil {
+				return err
+			}
+		}
+		if res == nil {
+			return nil
+		}
+		if err := tx.RecordRunWithDetails(f.index, "up", res.StatusCode, bodyPtr, toStore, execErr != nil, details); err != nil {
+			return err
+		}
+		return tx.InsertStoredEnv(f.index, stored)
+	})
+}
+
+// storedEnv returns the env persisted for version: the extracted values plus, when the up
+// response sets store_body and the step succeeded, the raw response body for the down step.
+func (m *Migrator) storedEnv(version int, t *task.Task, res *task.ExecResult, err error, extracted map[string]string) map[string]string {
+	if !t.Up.Response.StoreBody || err != nil {
+		return extracted
+	}
+	out := make(map[string]string, len(extracted)+1)
+	for k, v := range extracted {
+		out[k] = v
+	}
+	out[task.UpBodyEnvKey] = res.ResponseBody
+	m.logger().WithVersion(version).Debug("keeping up response body for down", "bytes", len(res.ResponseBody))
+	return out
+}
+
+// runDetails extracts the request metadata recorded with a run; secrets in the URL are masked.
+func (m *Migrator) runDetails(res *task.ExecResult) store.RunDetails {
+	return store.RunDetails{
+		RunID:      m.RunID,
+		Method:     res.Method,
+		URL:        common.GetGlobalMasker().MaskString(res.URL),
+		StartedAt:  res.StartedAt,
+		DurationMs: res.DurationMs,
+	}
+}
+
+// selectedHeaders returns the response headers named in resp.HeaderFrom, masked.
+func selectedHeaders(res *task.ExecResult, resp task.ResponseSpec) map[string]string {
+	if len(resp.HeaderFrom) == 0 || res.ResponseHeaders == nil {
+		return nil
+	}
+	out := map[string]string{}
+	for _, h := range resp.HeaderFrom {
+		name := http.CanonicalHeaderKey(strings.TrimSpace(h.Header))
+		if v := res.ResponseHeaders.Get(name); v != "" {
+			out[name] = common.GetGlobalMasker().MaskString(v)
+		}
+	}
+	return out
 }
 
 // MigrateDown rolls back down to targetVersion (not including target): it will
 // run downs for all applied versions > targetVersion in reverse order.
 // Each successful down removes that version from the store.
 func (m *Migrator) runDownForVersion(ctx context.Context, ver int, f vfile) (*ExecWithVersion, error) {
+	t, err := m.loadDownTask(ver, f)
+	if err != nil {
+		return nil, err
+	}
+	return m.execDownTask(ctx, ver, f, t)
+}
+
+// loadDownTask loads the down task of version ver with its env prepared (not run concurrently).
+func (m *Migrator) loadDownTask(ver int, f vfile) (*task.Task, error) {
 	var t task.Task
 	if err := m.initTaskAndEnv(&t, f, ver, nil, "down"); err != nil {
 		return nil, err
 	}
-	res, err := t.Down.Execute(ctx)
+	return &t, nil
+}
+
+// execDownTask executes (or renders) a loaded down task, records the run and removes the version.
+func (m *Migrator) execDownTask(ctx context.Context, ver int, f vfile, t *task.Task) (*ExecWithVersion, error) {
+	if m.wasSkipped(ver) {
+		return m.removeSkipped(ver, f)
+	}
+	if err := m.applyContextValues(ctx, t.Down.Env, t.Down.Find != nil && t.Down.Find.Response.FailsOnMissing()); err != nil {
+		return nil, fmt.Errorf("down %s: %w", f.name, err)
+	}
+	if m.DryRun {
+		reqs, err := t.Down.Plan()
+		if err != nil {
+			return nil, fmt.Errorf("down %s render failed: %w", f.name, err)
+		}
+		return &ExecWithVersion{Version: ver, Requests: reqs}, nil
+	}
+	step := StepInfo{Version: ver, Direction: "down", Name: t.Down.Name, Env: t.Down.Env}
+	if err := m.runBeforeStep(ctx, step); err != nil {
+		return nil, err
+	}
+	sctx, span := m.startStepSpan(ctx, step)
+	sctx, rec := m.startRecording(sctx, step)
+	res, err := t.Down.Execute(sctx)
+	m.finishRecording(rec, err)
 	ewv := &ExecWithVersion{Version: ver, Result: res}
+	if herr := m.runAfterStep(ctx, step, res, err); herr != nil && err == nil {
+		err = herr
+	}
+	endStepSpan(span, res, err)
+	ewv.Failed = err != nil
+	if rerr := m.recordDown(ver, res, err); rerr != nil {
+		if err != nil {
+			m.logger().Error("failed to record the failed down migration", "version", ver, "error", rerr)
+		} else {
+			ewv.Failed = true
+			return ewv, fmt.Errorf("down %s: %w", f.name, rerr)
+		}
+	}
+	if err != nil {
+		return ewv, fmt.Errorf("down %s failed: %w", f.name, err)
+	}
+	return ewv, nil
+}
+
+// recordDown records the run of an executed down step and, without execErr, removes the
+// version and its stored env, all in one store transaction.
+func (m *Migrator) recordDown(ver int, res *task.ExecResult, execErr error) error {
+	var bodyPtr *string
 	if res != nil {
-		save := m.SaveResponseBody
-		var bodyPtr *string
-		if save {
-			b := res.ResponseBody
-			bodyPtr = &b
+		bodyPtr = m.savedBody(ver, "down", res.ResponseBody)
+	}
+	return m.Store.WithTx(func(tx *store.Store) error {
+		if res != nil {
+			if err := tx.RecordRunWithDetails(ver, "down", res.StatusCode, bodyPtr, nil, execErr != nil, m.runDetails(res)); err != nil {
+				return err
+			}
 		}
-		if !m.DryRun {
-			_ = m.Store.RecordRun(ver, "down", res.StatusCode, bodyPtr, nil, err != nil)
+		if execErr != nil {
+			return nil
 		}
+		if err := tx.Remove(ver); err != nil {
+			return fmt.Errorf("record remove %d: %w", ver, err)
+		}
+		return tx.DeleteStoredEnv(ver)
+	})
+}
+
+// MigrateUp runs migrateUp and then sends the Notify summary, if configured.
+func (m *Migrator) MigrateUp(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
+	defer m.beginRun()()
+	defer m.beginRunGlobals()()
+	restoreEnv, err := m.beginEnvFiles()
+	if err != nil {
+		return nil, err
 	}
+	defer restoreEnv()
+	unlock, err := m.lockRun()
 	if err != nil {
-		return ewv, fmt.Errorf("down %s failed: %w", f.name, err)
+		return nil, err
 	}
-	if !m.DryRun {
-		if err := m.Store.Remove(ver); err != nil {
-			return ewv, fmt.Errorf("record remove %d: %w", ver, err)
+	defer unlock()
+	ctx, closeTransport, err := m.withClientTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTransport()
+	startTime := time.Now()
+	ctx, span := m.startRunSpan(m.withRequestOptions(ctx), "up", targetVersion)
+	rollback := m.RollbackOnCancel && !m.DryRun
+	var before []int
+	if rollback {
+		var err error
+		if before, err = m.appliedVersions(); err != nil {
+			endRunSpan(span, nil, err)
+			return nil, err
 		}
-		_ = m.Store.DeleteStoredEnv(ver)
 	}
-	return ewv, nil
+	results, err := m.migrateUp(ctx, targetVersion)
+	if rollback && err != nil && ctx.Err() != nil {
+		err = m.rollbackCancelled(ctx, before, err)
+	}
+	endRunSpan(span, results, err)
+	m.notify(ctx, "up", results, err, time.Since(startTime))
+	return results, err
 }
 
-func (m *Migrator) MigrateUp(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
-	logger := common.GetLogger().WithComponent("migrator")
+func (m *Migrator) migrateUp(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
+	logger := m.logger()
 	startTime := time.Now()
 	logger.Info("starting migration up",
 		"target_version", targetVersion,
 		"dir", m.Dir,
 		"dry_run", m.DryRun)
 
-	// Apply TLS settings for task HTTP requests and auth providers
-	task.SetTLSConfig(m.TLSConfig)
-	acommon.SetTLSConfig(m.TLSConfig)
 	// Perform automatic auth once if configured
 	if err := m.ensureAuth(ctx); err != nil {
 		logger.Error("failed to ensure authentication", "error", err)
 		return nil, fmt.Errorf("failed to ensure authentication: %w", err)
 	}
-	files, err := listMigrationFiles(m.Dir)
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
 	if err != nil {
 		logger.Error("failed to list migration files", "error", err, "dir", m.Dir)
 		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
 	}
 	logger.Debug("found migration files", "count", len(files), "files", files)
 
-	var cur int
-	if m.DryRun {
-		cur = m.DryRunFrom
-		logger.Debug("dry run mode enabled", "dry_run_from", cur)
-	} else {
-		var err error
-		cur, err = m.Store.CurrentVersion()
-		if err != nil {
-			logger.Error("failed to get current migration version from store", "error", err)
-			return nil, fmt.Errorf("failed to get current migration version from store: %w", err)
+	cur, err := m.currentVersion()
+	if err != nil {
+		logger.Error("failed to get current migration version from store", "error", err)
+		return nil, err
+	}
+	logger.Debug("current migration version", "version", cur, "dry_run", m.DryRun)
+	if !m.DryRun {
+		if err := m.checkChecksums(files); err != nil {
+			return nil, err
 		}
 	}
-	logger.Debug("current migration version", "version", cur)
 	// plan versions to run
-	plan := planUp(files, cur, targetVersion)
+	plan, err := m.pendingUp(files, cur, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	if m.MaxParallel > 1 {
+		results, err := m.migrateUpParallel(ctx, files, plan)
+		if err != nil {
+			return results, err
+		}
+		logger.Info("migration up completed",
+			"applied_count", len(results),
+			"duration_ms", time.Since(startTime).Milliseconds(),
+			"dry_run", m.DryRun,
+			"max_parallel", m.MaxParallel)
+		return results, nil
+	}
 
 	results := make([]*ExecWithVersion, 0, len(plan))
 	// sessionStored accumulates stored env created during this run to be available to later versions
 	sessionStored := map[string]string{}
-	for _, f := range plan {
+	var failures []*VersionError
+	for i := 0; i < len(plan); i++ {
+		f := plan[i]
 		logger.Info("applying migration",
 			"version", f.index,
 			"file", f.name)
-		vr, toStore, err := m.runUpForFile(ctx, f, sessionStored)
+		// Refresh lazy auth values so tokens close to expiry are re-acquired
+		if err := m.ensureAuth(ctx); err != nil {
+			return results, fmt.Errorf("failed to ensure authentication: %w", err)
+		}
+		// After a failure later successes are not recorded, so the store version never skips it
+		vr, toStore, err := m.runUpForFile(ctx, f, sessionStored, len(failures) == 0)
+		if vr == nil && err != nil && m.ContinueOnError {
+			vr = &ExecWithVersion{Version: f.index, Failed: true}
+		}
 		results = append(results, vr)
 		for k, v := range toStore {
 			sessionStored[k] = v
 		}
 		if err != nil {
-			return results, fmt.Errorf("migration %s failed: %w", f.name, err)
+			err = fmt.Errorf("migration %s failed: %w", f.name, err)
+			if !m.ContinueOnError || ctx.Err() != nil {
+				return results, err
+			}
+			logger.Warn("migration failed, continuing with next version", "version", f.index, "file", f.name, "error", err)
+			failures = append(failures, &VersionError{Version: f.index, Err: err})
+			continue
 		}
 		if !m.DryRun {
-			if err := m.Store.Apply(f.index); err != nil {
-				return results, fmt.Errorf("record apply %d: %w", f.index, err)
+			// An on_match directive records the versions it skips as applied without running them
+			if vr.Result != nil && vr.Result.Skip != nil {
+				n := skipCount(plan, i, vr.Result.Skip)
+				for _, sf := range plan[i+1 : i+1+n] {
+					if len(failures) == 0 {
+						if err := m.skipVersion(sf); err != nil {
+							return results, err
+						}
+					}
+					results = append(results, &ExecWithVersion{Version: sf.index, Skipped: true})
+				}
+				i += n
 			}
 			// Configurable delay to allow backend consistency before next migration
 			delay := m.getDelayBetweenMigrations()
@@ -335,6 +836,15 @@ func (m *Migrator) MigrateUp(ctx context.Context, targetVersion int) ([]*ExecWit
 	}
 
 	duration := time.Since(startTime)
+	if len(failures) > 0 {
+		merr := &MigrationErrors{Errors: failures}
+		logger.Error("migration up completed with failures",
+			"executed_count", len(results),
+			"failed_versions", merr.FailedVersions(),
+			"duration_ms", duration.Milliseconds(),
+			"dry_run", m.DryRun)
+		return results, merr
+	}
 	logger.Info("migration up completed",
 		"applied_count", len(results),
 		"duration_ms", duration.Milliseconds(),
@@ -342,77 +852,91 @@ func (m *Migrator) MigrateUp(ctx context.Context, targetVersion int) ([]*ExecWit
 	return results, nil
 }
 
+// MigrateDown runs migrateDown and then sends the Notify summary, if configured.
 func (m *Migrator) MigrateDown(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
-	logger := common.GetLogger().WithComponent("migrator")
+	defer m.beginRun()()
+	defer m.beginRunGlobals()()
+	restoreEnv, err := m.beginEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer restoreEnv()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	ctx, closeTransport, err := m.withClientTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTransport()
+	startTime := time.Now()
+	ctx, span := m.startRunSpan(m.withRequestOptions(ctx), "down", targetVersion)
+	results, err := m.migrateDown(ctx, targetVersion)
+	endRunSpan(span, results, err)
+	m.notify(ctx, "down", results, err, time.Since(startTime))
+	return results, err
+}
+
+func (m *Migrator) migrateDown(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
+	logger := m.logger()
 	startTime := time.Now()
 	logger.Info("starting migration down",
 		"target_version", targetVersion,
 		"dir", m.Dir,
 		"dry_run", m.DryRun)
 
-	// Apply TLS settings for task HTTP requests and auth providers
-	task.SetTLSConfig(m.TLSConfig)
-	acommon.SetTLSConfig(m.TLSConfig)
 	// Perform automatic auth once if configured
 	if err := m.ensureAuth(ctx); err != nil {
 		return nil, fmt.Errorf("failed to ensure authentication for down migration: %w", err)
 	}
-	files, err := listMigrationFiles(m.Dir)
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list migration files in directory %q for down migration: %w", m.Dir, err)
 	}
 
-	var cur int
-	if m.DryRun {
-		cur = m.DryRunFrom
-	} else {
-		var err error
-		cur, err = m.Store.CurrentVersion()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get current migration version from store for down migration: %w", err)
-		}
+	cur, err := m.currentVersion()
+	if err != nil {
+		return nil, fmt.Errorf("down migration: %w", err)
 	}
 	if targetVersion < 0 {
 		targetVersion = 0
 	}
-	if targetVersion > cur {
-		return nil, fmt.Errorf("target version %d is above current %d", targetVersion, cur)
-	}
-
 	// map versions to files
 	fileByVer := mapFilesByVersion(files)
-
 	// collect applied versions to rollback: (target, cur]
-	var applied []int
-	if m.DryRun {
-		// simulate applied 1..cur
-		for i := 1; i <= cur; i++ {
-			applied = append(applied, i)
-		}
-	} else {
-		var err error
-		applied, err = m.Store.ListApplied()
-		if err != nil {
-			return nil, err
-		}
+	toRollback, err := m.rollbackVersions(cur, targetVersion)
+	if err != nil {
+		return nil, err
 	}
-	toRollback := make([]int, 0)
-	for _, v := range applied {
-		if v > targetVersion {
-			toRollback = append(toRollback, v)
+
+	if m.MaxParallel > 1 {
+		results, err := m.migrateDownParallel(ctx, files, toRollback)
+		if err != nil {
+			return results, err
 		}
+		logger.Info("migration down completed",
+			"rolled_back_count", len(results),
+			"duration_ms", time.Since(startTime).Milliseconds(),
+			"dry_run", m.DryRun,
+			"max_parallel", m.MaxParallel)
+		return results, nil
 	}
-	sort.Sort(sort.Reverse(sort.IntSlice(toRollback)))
 
 	results := make([]*ExecWithVersion, 0, len(toRollback))
 	for _, v := range toRollback {
 		f, ok := fileByVer[v]
 		if !ok {
-			return results, fmt.Errorf("no migration file for version %d", v)
+			return results, &ErrMissingMigrationFile{Version: v}
 		}
 		logger.Info("rolling back migration",
 			"version", v,
 			"file", f.name)
+		// Refresh lazy auth values so tokens close to expiry are re-acquired
+		if err := m.ensureAuth(ctx); err != nil {
+			return results, fmt.Errorf("failed to ensure authentication for down migration: %w", err)
+		}
 		vr, err := m.runDownForVersion(ctx, v, f)
 		results = append(results, vr)
 		if err != nil {
@@ -427,3 +951,140 @@ func (m *Migrator) MigrateDown(ctx context.Context, targetVersion int) ([]*ExecW
 		"dry_run", m.DryRun)
 	return results, nil
 }
+
+// MigrateTo moves the store to exactly targetVersion, applying up migrations when the current
+// version is lower and rolling back when it is higher. targetVersion 0 rolls everything back.
+func (m *Migrator) MigrateTo(ctx context.Context, targetVersion int) ([]*ExecWithVersion, error) {
+	defer m.beginRun()()
+	defer m.beginRunGlobals()()
+	restoreEnv, err := m.beginEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer restoreEnv()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	logger := m.logger()
+	if targetVersion < 0 {
+		return nil, fmt.Errorf("target version %d must not be negative", targetVersion)
+	}
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	highest := 0
+	if len(files) > 0 {
+		highest = files[len(files)-1].index
+	}
+	if targetVersion > highest {
+		return nil, fmt.Errorf("target version %d is above the highest available migration %d", targetVersion, highest)
+	}
+
+	cur, err := m.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("migrating to target version", "current_version", cur, "target_version", targetVersion)
+
+	switch {
+	case targetVersion > cur:
+		return m.MigrateUp(ctx, targetVersion)
+	case targetVersion < cur:
+		return m.MigrateDown(ctx, targetVersion)
+	default:
+		logger.Info("already at target version", "version", cur)
+		return []*ExecWithVersion{}, nil
+	}
+}
+
+// Redo rolls back an applied version and applies it again, e.g. after editing its file during
+// development. version <= 0 redoes the highest applied version; other versions are left untouched.
+// The down runs with the version's stored env, and the up extracts and stores env afresh.
+func (m *Migrator) Redo(ctx context.Context, version int) ([]*ExecWithVersion, error) {
+	defer m.beginRun()()
+	defer m.beginRunGlobals()()
+	restoreEnv, err := m.beginEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer restoreEnv()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	logger := m.logger()
+	ctx, closeTransport, err := m.withClientTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTransport()
+	ctx = m.withRequestOptions(ctx)
+
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	if version <= 0 {
+		for _, v := range applied {
+			if v > version {
+				version = v
+			}
+		}
+		if version <= 0 {
+			return nil, fmt.Errorf("no applied migration to redo")
+		}
+	} else if !containsVersion(applied, version) {
+		return nil, fmt.Errorf("version %d is not applied", version)
+	}
+	f, ok := mapFilesByVersion(files)[version]
+	if !ok {
+		return nil, &ErrMissingMigrationFile{Version: version}
+	}
+
+	if err := m.ensureAuth(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ensure authentication for down migration: %w", err)
+	}
+	t, err := m.loadDownTask(version, f)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(t.Down.Method) == "" && strings.TrimSpace(t.Down.URL) == "" {
+		return nil, fmt.Errorf("cannot redo version %d: %s has no down section", version, f.name)
+	}
+	logger.Info("redoing migration", "version", version, "file", f.name, "dry_run", m.DryRun)
+
+	results := make([]*ExecWithVersion, 0, 2)
+	vr, err := m.execDownTask(ctx, version, f, t)
+	results = append(results, vr)
+	if err != nil {
+		return results, err
+	}
+	if err := m.ensureAuth(ctx); err != nil {
+		return results, fmt.Errorf("failed to ensure authentication: %w", err)
+	}
+	vr, _, err = m.runUpForFile(ctx, f, nil, true)
+	results = append(results, vr)
+	if err != nil {
+		return results, fmt.Errorf("migration %s failed: %w", f.name, err)
+	}
+	logger.Info("migration redo completed", "version", version, "dry_run", m.DryRun)
+	return results, nil
+}
+
+func containsVersion(versions []int, v int) bool {
+	for _, x := range versions {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
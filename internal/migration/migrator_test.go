@@ -10,8 +10,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/loykin/apirun/internal/auth"
 	"github.com/loykin/apirun/internal/store"
@@ -104,10 +106,17 @@ func TestMigrator_RecordsFailedFlag_OnEnvMissingFail(t *testing.T) {
 	defer func() { _ = st.Close() }()
 
 	// Run MigrateUp; expect error and migration_runs.failed=true
-	_, err := (&Migrator{Dir: dir, Env: &base, Store: *st}).MigrateUp(ctx, 0)
+	results, err := (&Migrator{Dir: dir, Env: &base, Store: *st}).MigrateUp(ctx, 0)
 	if err == nil {
 		t.Fatalf("expected migrate up to fail due to env_missing=fail")
 	}
+	// The failed version still returns its result, with the failure reason
+	if len(results) != 1 || results[0].Result == nil {
+		t.Fatalf("expected the result of the failed version, got %+v", results)
+	}
+	if res := results[0].Result; res.StatusCode != 200 || res.Error == nil || res.Error.Reason != task.ReasonEnvMissing {
+		t.Fatalf("expected env_missing error details with status 200, got %+v", res)
+	}
 	// Inspect migration_runs
 	row := st.DB.QueryRow(`SELECT status_code, failed FROM migration_runs ORDER BY id DESC LIMIT 1`)
 	var code int
@@ -193,6 +202,25 @@ type migrationRecords struct {
 	del    requestRecord
 }
 
+// writeMigrations writes each migration body into dir under its file name, e.g. 001_create.yaml.
+func writeMigrations(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+// newTestMigrator returns a Migrator over the migrations in dir with an empty env, a short delay
+// between migrations and a SQLite store in dir, which is closed when the test ends.
+func newTestMigrator(t *testing.T, dir string) (*Migrator, *store.Store) {
+	t.Helper()
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	t.Cleanup(func() { _ = st.Close() })
+	return &Migrator{Dir: dir, Store: *st, Env: env.New(), DelayBetweenMigrations: time.Millisecond}, st
+}
+
 func setupTestMigrations(t *testing.T, dir string, serverURL string) {
 	// Migration 001: create resource, extract id into rid
 	mig1 := fmt.Sprintf(`up:
@@ -557,3 +585,404 @@ func TestMigrateUp_PropagatesAuthHeader(t *testing.T) {
 		t.Fatalf("expected server hit once, got %d", hit)
 	}
 }
+
+// A chained auth entry is acquired with the token of the entry it depends on
+func TestMigrateUp_ChainedAuth(t *testing.T) {
+	var tokenCalls []string
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		id, secret := r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		tokenCalls = append(tokenCalls, id)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case id == "svc" && secret == "s3cret":
+			_, _ = w.Write([]byte(`{"access_token":"svc-token","token_type":"Bearer"}`))
+		case id == "scoped" && secret == "svc-token":
+			_, _ = w.Write([]byte(`{"access_token":"scoped-token","token_type":"Bearer"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+		}
+	}))
+	defer tokens.Close()
+	var gotAuth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: GET\n    url: " + api.URL + "\n    headers:\n      - { name: Authorization, value: 'Bearer {{.auth.scoped}}' }\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_ok.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	clientCredentials := func(id, secret string) auth.MethodConfig {
+		return auth.NewAuthSpecFromMap(map[string]interface{}{
+			"grant_type":   "client_credentials",
+			"grant_config": map[string]interface{}{"client_id": id, "client_secret": secret, "token_url": tokens.URL},
+		})
+	}
+	// The dependent entry is listed first; ensureAuth orders it after its dependency
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), Auth: []auth.Auth{
+		{Type: "oauth2", Name: "scoped", DependsOn: "service", Methods: clientCredentials("scoped", "{{.auth.service}}")},
+		{Type: "oauth2", Name: "service", Methods: clientCredentials("svc", "s3cret")},
+	}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if gotAuth != "Bearer scoped-token" {
+		t.Fatalf("unexpected Authorization %q", gotAuth)
+	}
+	if strings.Join(tokenCalls, ",") != "svc,scoped" {
+		t.Fatalf("unexpected token requests: %v", tokenCalls)
+	}
+}
+
+func TestEnsureAuth_ChainErrors(t *testing.T) {
+	spec := auth.NewAuthSpecFromMap(map[string]interface{}{})
+	cases := map[string][]auth.Auth{
+		"cycle":         {{Type: "basic", Name: "a", DependsOn: "b", Methods: spec}, {Type: "basic", Name: "b", DependsOn: "a", Methods: spec}},
+		"self":          {{Type: "basic", Name: "a", DependsOn: "a", Methods: spec}},
+		"unknown entry": {{Type: "basic", Name: "a", DependsOn: "missing", Methods: spec}},
+	}
+	for name, auths := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := &Migrator{Env: env.New(), Auth: auths}
+			if err := m.ensureAuth(context.Background()); err == nil || !strings.Contains(err.Error(), "depends_on") {
+				t.Fatalf("expected a depends_on error, got %v", err)
+			}
+		})
+	}
+
+	// A failed dependency is reported instead of being rendered empty
+	auth.Register("failing-chain", func(map[string]interface{}) (auth.Method, error) { return nil, fmt.Errorf("down") })
+	m := &Migrator{Env: env.New(), Auth: []auth.Auth{
+		{Type: "failing-chain", Name: "a", Methods: spec},
+		{Type: "basic", Name: "b", DependsOn: "a", Methods: auth.NewAuthSpecFromMap(map[string]interface{}{"username": "u", "password": "{{.auth.a}}"})},
+	}}
+	if err := m.ensureAuth(context.Background()); err != nil {
+		t.Fatalf("ensureAuth: %v", err)
+	}
+	if _, err := m.Env.Auth["b"].(*env.VarLazy).Value(); err == nil || !strings.Contains(err.Error(), `depends_on "a"`) {
+		t.Fatalf("expected the dependency error, got %v", err)
+	}
+}
+
+// Retries happen inside the HTTP client; only the final outcome must be recorded in migration_runs.
+func TestMigrateUp_RetryPolicy_RecordsFinalOutcomeOnce(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  name: flaky\n  request:\n    method: GET\n    url: " + srv.URL + "\n  response:\n    result_code: ['200']\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_flaky.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), RetryPolicy: &task.RetryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       time.Millisecond,
+		MaxBackoff:           time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusBadGateway},
+	}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if hits != 3 {
+		t.Fatalf("expected 3 attempts, got %d", hits)
+	}
+	runs, err := st.ListRuns()
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(runs) != 1 || runs[0].StatusCode != http.StatusOK || runs[0].Failed {
+		t.Fatalf("expected a single successful run, got %+v", runs)
+	}
+}
+
+func TestDecodeTaskYAML_RetryBlock(t *testing.T) {
+	yaml := strings.NewReader("up:\n  request:\n    method: GET\n    url: http://example.com\n    retry:\n      max_attempts: 5\n      initial_backoff: 200ms\n      max_backoff: 2s\n      multiplier: 1.5\n      retryable_status_codes: [502, 503]\n")
+	var tk task.Task
+	if err := tk.DecodeYAML(yaml); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	r := tk.Up.Request.Retry
+	if r == nil {
+		t.Fatalf("expected retry block to be decoded")
+	}
+	if r.MaxAttempts != 5 || r.InitialBackoff != 200*time.Millisecond || r.MaxBackoff != 2*time.Second || r.Multiplier != 1.5 {
+		t.Fatalf("unexpected retry policy: %+v", r)
+	}
+	if len(r.RetryableStatusCodes) != 2 || r.RetryableStatusCodes[0] != 502 {
+		t.Fatalf("unexpected retryable codes: %v", r.RetryableStatusCodes)
+	}
+}
+
+type expiringMethod struct {
+	calls *int
+	ttl   time.Duration
+}
+
+func (e expiringMethod) Acquire(ctx context.Context) (string, error) {
+	v, _, err := e.AcquireWithExpiry(ctx)
+	return v, err
+}
+
+func (e expiringMethod) AcquireWithExpiry(_ context.Context) (string, time.Time, error) {
+	*e.calls++
+	return fmt.Sprintf("tok-%d", *e.calls), time.Now().Add(e.ttl), nil
+}
+
+func TestMigrateUp_TokenCache_ReusesAndRefreshes(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	run := func(ttl time.Duration) (int, []string) {
+		seen = nil
+		calls := 0
+		typ := fmt.Sprintf("expiring-%d", ttl)
+		auth.Register(typ, func(map[string]interface{}) (auth.Method, error) {
+			return expiringMethod{calls: &calls, ttl: ttl}, nil
+		})
+		dir := t.TempDir()
+		for i := 1; i <= 3; i++ {
+			mig := "up:\n  name: t\n  request:\n    method: GET\n    url: " + srv.URL + "\n    headers:\n      - { name: Authorization, value: '{{.auth.api}}' }\n  response:\n    result_code: ['200']\n"
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d_t.yaml", i)), []byte(mig), 0o600); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		}
+		st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+		defer func() { _ = st.Close() }()
+		m := &Migrator{Dir: dir, Store: *st, Env: env.New(), DelayBetweenMigrations: time.Millisecond,
+			Auth: []auth.Auth{{Type: typ, Name: "api", Methods: auth.NewAuthSpecFromMap(map[string]interface{}{})}}}
+		if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+			t.Fatalf("MigrateUp: %v", err)
+		}
+		return calls, seen
+	}
+
+	// Long-lived token: acquired once and reused by every migration
+	calls, hdrs := run(time.Hour)
+	if calls != 1 || len(hdrs) != 3 || hdrs[2] != "tok-1" {
+		t.Fatalf("expected a single acquisition reused 3 times, got calls=%d headers=%v", calls, hdrs)
+	}
+
+	// Token expiring within the default skew: re-acquired for each migration
+	calls, hdrs = run(time.Second)
+	if calls != 3 || hdrs[2] != "tok-3" {
+		t.Fatalf("expected re-acquisition per migration, got calls=%d headers=%v", calls, hdrs)
+	}
+}
+
+func TestMigrateUpDown_RecordsRequestDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/users\n  response:\n    result_code: ['200']\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/users/1\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_users.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New()}
+	ups, err := m.MigrateUp(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(ups) != 1 || ups[0].Result.Method != http.MethodPost || ups[0].Result.URL != srv.URL+"/users" || ups[0].Result.StartedAt.IsZero() {
+		t.Fatalf("unexpected up result: %+v", ups[0].Result)
+	}
+	downs, err := m.MigrateDown(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(downs) != 1 || downs[0].Result.Method != http.MethodDelete || downs[0].Result.URL != srv.URL+"/users/1" {
+		t.Fatalf("unexpected down result: %+v", downs[0].Result)
+	}
+
+	runs, err := st.ListRuns()
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("ListRuns: %v %+v", err, runs)
+	}
+	if runs[0].Method != http.MethodPost || runs[0].URL != srv.URL+"/users" || runs[0].StartedAt == "" || runs[0].DurationMs == nil {
+		t.Fatalf("unexpected up run details: %+v", runs[0])
+	}
+	if runs[1].Method != http.MethodDelete || runs[1].URL != srv.URL+"/users/1" || runs[1].DurationMs == nil {
+		t.Fatalf("unexpected down run details: %+v", runs[1])
+	}
+}
+
+func TestMigrateUpDown_DefaultHeaders(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.Header.Get("X-Request-ID")+" "+r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/users\n    headers:\n      - { name: Authorization, value: 'Bearer {{.auth.svc}}' }\n" +
+		"  response:\n    result_code: ['200']\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/users/1\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_users.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	base := env.New()
+	base.Global = env.FromStringMap(map[string]string{"rid": "run-7"})
+	base.Auth = env.FromStringMap(map[string]string{"svc": "tok"})
+	m := &Migrator{Dir: dir, Store: *st, Env: base, DelayBetweenMigrations: time.Millisecond,
+		DefaultHeaders: map[string]string{"X-Request-ID": "{{.env.rid}}", "Authorization": "Bearer default"}}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	want := []string{"POST run-7 Bearer tok", "DELETE run-7 Bearer default"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected headers: %q", got)
+	}
+}
+
+func TestMigrateUpDown_StoreBodyAvailableToDownAndPurged(t *testing.T) {
+	var downHit bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			_, _ = w.Write([]byte(`{"id":"u1","meta":{"links":["a","b"]}}`))
+		case r.URL.Path == "/users/u1/links/b":
+			downHit = true
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m1 := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/users\n  response:\n    result_code: ['200']\n    store_body: true\n    env_from:\n      uid: id\n" +
+		"down:\n  find:\n    from_up_body: true\n    response:\n      env_from:\n        link: meta.links.1\n" +
+		"  method: DELETE\n  url: " + srv.URL + "/users/{{.env.uid}}/links/{{.env.link}}\n"
+	m2 := "up:\n  request:\n    method: PUT\n    url: " + srv.URL + "/check/{{.env.uid}}\n  response:\n    result_code: ['200']\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/check\n"
+	for name, body := range map[string]string{"001_users.yaml": m1, "002_check.yaml": m2} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	leaked := false
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), DelayBetweenMigrations: time.Millisecond,
+		BeforeStep: func(_ context.Context, step StepInfo) error {
+			if step.Direction == "up" && step.Version == 2 {
+				_, leaked = step.Env.Lookup(task.UpBodyEnvKey)
+			}
+			return nil
+		}}
+	if _, err := m.MigrateUp(context.Background(), 1); err != nil {
+		t.Fatalf("MigrateUp v1: %v", err)
+	}
+	stored, err := st.LoadStoredEnv(1)
+	if err != nil || stored[task.UpBodyEnvKey] != `{"id":"u1","meta":{"links":["a","b"]}}` || stored["uid"] != "u1" {
+		t.Fatalf("expected up body kept in stored env, got %v %v", stored, err)
+	}
+	runs, _ := st.ListRuns()
+	if len(runs) != 1 || runs[0].Body != nil || runs[0].Env[task.UpBodyEnvKey] != "" {
+		t.Fatalf("expected the body only in stored env, not in run history: %+v", runs)
+	}
+	// A later version, run in a separate invocation, must not see v1's body
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp v2: %v", err)
+	}
+	if leaked {
+		t.Fatal("expected up_body to stay private to version 1")
+	}
+
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if !downHit {
+		t.Fatal("expected down to find its values in the up body")
+	}
+	if stored, _ := st.LoadStoredEnv(1); len(stored) != 0 {
+		t.Fatalf("expected stored env purged after down, got %v", stored)
+	}
+}
+
+// header_from values flow into later migrations and the down step like env_from values, and
+// SaveResponseHeaders records the selected headers with the run.
+func TestMigrateUp_HeaderFrom_FlowsToLaterStepsAndHistory(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodPost && r.URL.Path == "/users" {
+			w.Header().Set("Location", "/users/u-42")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig1 := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/users\n  response:\n    result_code: ['201']\n" +
+		"    header_from:\n      user_id: { header: Location, regex: '/users/([^/]+)$' }\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/users/{{.env.user_id}}\n"
+	mig2 := "up:\n  request:\n    method: PUT\n    url: " + srv.URL + "/users/{{.env.user_id}}/roles\n  response:\n    result_code: ['200']\n" +
+		"down:\n  method: DELETE\n  url: " + srv.URL + "/roles\n"
+	for name, body := range map[string]string{"001_create.yaml": mig1, "002_roles.yaml": mig2} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), DelayBetweenMigrations: time.Millisecond, SaveResponseHeaders: true}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	want := []string{"POST /users", "PUT /users/u-42/roles", "DELETE /roles", "DELETE /users/u-42"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("requests = %v, want %v", paths, want)
+	}
+
+	runs, err := st.ListRuns()
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(runs) == 0 || runs[0].Headers["Location"] != "/users/u-42" {
+		t.Fatalf("expected the Location header in the first run, got %+v", runs)
+	}
+	if len(runs) > 1 && runs[1].Headers != nil {
+		t.Fatalf("expected no headers for a run without header_from, got %v", runs[1].Headers)
+	}
+}
@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/httpc"
+)
+
+// NotifyConfig describes a webhook that receives a summary after MigrateUp/MigrateDown.
+type NotifyConfig struct {
+	URL string
+	// Headers are sent with the notification; values are rendered against the migrator env.
+	Headers map[string]string
+	// Template renders the JSON payload from a NotifySummary (text/template, with a json
+	// function for quoting values). Empty sends the summary itself as JSON.
+	Template string
+}
+
+// NotifySummary is the outcome of one MigrateUp/MigrateDown run.
+type NotifySummary struct {
+	RunID     string `json:"run_id,omitempty"`
+	Direction string `json:"direction"`
+	Success   bool   `json:"success"`
+	// Completed counts versions applied (up) or rolled back (down); Versions lists them in order.
+	Completed  int    `json:"completed"`
+	Versions   []int  `json:"versions"`
+	Failed     int    `json:"failed"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+func newNotifySummary(direction string, results []*ExecWithVersion, runErr error, elapsed time.Duration, dryRun bool) NotifySummary {
+	s := NotifySummary{Direction: direction, Success: runErr == nil, Versions: []int{}, DurationMs: elapsed.Milliseconds(), DryRun: dryRun}
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if r.Failed {
+			s.Failed++
+			continue
+		}
+		s.Completed++
+		s.Versions = append(s.Versions, r.Version)
+	}
+	if runErr != nil {
+		s.Error = common.GetGlobalMasker().MaskString(runErr.Error())
+	}
+	return s
+}
+
+// payload renders the notification body for s.
+func (n *NotifyConfig) payload(s NotifySummary) ([]byte, error) {
+	if strings.TrimSpace(n.Template) == "" {
+		return json.Marshal(s)
+	}
+	tmpl, err := template.New("notify").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(n.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s); err != nil {
+		return nil, fmt.Errorf("notify template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// notify posts the run summary to the configured webhook. Failures are logged as warnings
+// and never change the outcome of the migration.
+func (m *Migrator) notify(ctx context.Context, direction string, results []*ExecWithVersion, runErr error, elapsed time.Duration) {
+	n := m.Notify
+	if n == nil || strings.TrimSpace(n.URL) == "" {
+		return
+	}
+	logger := common.GetLogger().WithComponent("notify").WithRunID(m.RunID)
+	summary := newNotifySummary(direction, results, runErr, elapsed, m.DryRun)
+	summary.RunID = m.RunID
+	body, err := n.payload(summary)
+	if err != nil {
+		logger.Warn("failed to build migration notification", "error", err)
+		return
+	}
+	hdrs := map[string]string{"Content-Type": "application/json"}
+	for k, v := range n.Headers {
+		if m.Env != nil {
+			v = m.Env.RenderGoTemplate(v)
+		}
+		hdrs[k] = v
+	}
+	url := common.GetGlobalMasker().MaskString(n.URL)
+	// Send through the run's transport, even when the run was cancelled; the client timeout
+	// still bounds the request
+	h := httpc.Httpc{Shared: httpc.SharedTransport(ctx)}
+	resp, err := h.New().R().SetContext(context.WithoutCancel(ctx)).SetHeaders(hdrs).SetBody(body).Post(n.URL)
+	if err != nil {
+		logger.Warn("failed to send migration notification", "url", url, "error", err)
+		return
+	}
+	if resp.StatusCode() < 200 || resp.StatusCode() >= 300 {
+		logger.Warn("migration notification rejected", "url", url, "status_code", resp.StatusCode())
+		return
+	}
+	logger.Debug("migration notification sent", "url", url, "direction", direction)
+}
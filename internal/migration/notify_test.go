@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// notifyServer serves migration requests under /api and records notifications posted to /hook.
+func notifyServer(t *testing.T, hook func(r *http.Request, body []byte)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hook":
+			b, _ := io.ReadAll(r.Body)
+			hook(r, b)
+		case "/api/fail":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeNotifyMigrations(t *testing.T, dir, base string, paths ...string) {
+	t.Helper()
+	files := map[string]string{}
+	for i, p := range paths {
+		files[fmt.Sprintf("%03d_m.yaml", i+1)] = "up:\n  request:\n    method: POST\n    url: " + base + p + "\n  response:\n    result_code: ['200']\n" +
+			"down:\n  method: DELETE\n  url: " + base + p + "\n"
+	}
+	writeMigrations(t, dir, files)
+}
+
+func TestNotify_PostsDefaultSummaryAfterUpAndDown(t *testing.T) {
+	var got []NotifySummary
+	srv := notifyServer(t, func(r *http.Request, body []byte) {
+		if r.Header.Get("X-Token") != "secret-tok" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected notification headers: %v", r.Header)
+		}
+		var s NotifySummary
+		if err := json.Unmarshal(body, &s); err != nil {
+			t.Errorf("decode summary %q: %v", body, err)
+		}
+		got = append(got, s)
+	})
+	dir := t.TempDir()
+	writeNotifyMigrations(t, dir, srv.URL, "/api/a", "/api/b")
+	m, _ := newTestMigrator(t, dir)
+	m.Env.Global = env.FromStringMap(map[string]string{"tok": "secret-tok"})
+	m.Notify = &NotifyConfig{URL: srv.URL + "/hook", Headers: map[string]string{"X-Token": "{{.env.tok}}"}}
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(got))
+	}
+	if got[0].Direction != "up" || !got[0].Success || got[0].Completed != 2 || !reflect.DeepEqual(got[0].Versions, []int{1, 2}) || got[0].Failed != 0 {
+		t.Fatalf("unexpected up summary: %+v", got[0])
+	}
+	if got[1].Direction != "down" || got[1].Completed != 1 || !reflect.DeepEqual(got[1].Versions, []int{2}) {
+		t.Fatalf("unexpected down summary: %+v", got[1])
+	}
+}
+
+func TestNotify_TemplateReportsFailure(t *testing.T) {
+	var payload string
+	srv := notifyServer(t, func(_ *http.Request, body []byte) { payload = string(body) })
+	dir := t.TempDir()
+	writeNotifyMigrations(t, dir, srv.URL, "/api/a", "/api/fail")
+	m, _ := newTestMigrator(t, dir)
+	m.Notify = &NotifyConfig{URL: srv.URL + "/hook",
+		Template: `{"text":{{json (printf "%s: %d ok, %d failed" .Direction .Completed .Failed)}},"ok":{{.Success}}}`}
+
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil {
+		t.Fatalf("expected migration failure")
+	}
+	if want := `{"text":"up: 1 ok, 1 failed","ok":false}`; payload != want {
+		t.Fatalf("unexpected payload: %s", payload)
+	}
+}
+
+func TestNotify_FailureDoesNotChangeResult(t *testing.T) {
+	srv := notifyServer(t, func(*http.Request, []byte) {})
+	dir := t.TempDir()
+	writeNotifyMigrations(t, dir, srv.URL, "/api/a")
+	m, _ := newTestMigrator(t, dir)
+	m.Notify = &NotifyConfig{URL: srv.URL + "/api/fail"}
+
+	res, err := m.MigrateUp(context.Background(), 0)
+	if err != nil || len(res) != 1 {
+		t.Fatalf("notification failure must not fail the run: res=%d err=%v", len(res), err)
+	}
+	m.Notify = &NotifyConfig{URL: srv.URL + "/hook", Template: "{{.Missing"}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("invalid template must not fail the run: %v", err)
+	}
+}
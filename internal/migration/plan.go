@@ -0,0 +1,184 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/loykin/apirun/internal/task"
+)
+
+// PlannedMigration is a version a run would execute, with its file and step name.
+type PlannedMigration struct {
+	Version int    `json:"version"`
+	File    string `json:"file"`
+	Name    string `json:"name,omitempty"`
+}
+
+// MigrationPlan lists, in execution order, the versions an up or down run would execute.
+type MigrationPlan struct {
+	Direction      string             `json:"direction"`
+	CurrentVersion int                `json:"current_version"`
+	TargetVersion  int                `json:"target_version"`
+	Migrations     []PlannedMigration `json:"migrations"`
+}
+
+// Versions returns the planned versions in execution order.
+func (p *MigrationPlan) Versions() []int {
+	out := make([]int, 0, len(p.Migrations))
+	for _, pm := range p.Migrations {
+		out = append(out, pm.Version)
+	}
+	return out
+}
+
+// Plan returns the pending up migrations, as MigrateUp(ctx, 0) would apply them.
+func (m *Migrator) Plan(ctx context.Context) (*MigrationPlan, error) {
+	return m.PlanUp(ctx, 0)
+}
+
+// PlanUp returns the versions MigrateUp(ctx, targetVersion) would apply. It only reads the
+// migration files and the store: no request is sent and nothing is recorded.
+func (m *Migrator) PlanUp(ctx context.Context, targetVersion int) (*MigrationPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	cur, err := m.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := m.pendingUp(files, cur, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	plan := &MigrationPlan{Direction: "up", CurrentVersion: cur, TargetVersion: targetVersion, Migrations: []PlannedMigration{}}
+	for _, f := range pending {
+		var t task.Task
+		if err := f.load(&t); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+		plan.Migrations = append(plan.Migrations, PlannedMigration{Version: f.index, File: f.name, Name: t.Up.Name})
+	}
+	return plan, nil
+}
+
+// PlanDown returns the versions MigrateDown(ctx, targetVersion) would roll back, newest first.
+func (m *Migrator) PlanDown(ctx context.Context, targetVersion int) (*MigrationPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	cur, err := m.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	if targetVersion < 0 {
+		targetVersion = 0
+	}
+	versions, err := m.rollbackVersions(cur, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	fileByVer := mapFilesByVersion(files)
+	plan := &MigrationPlan{Direction: "down", CurrentVersion: cur, TargetVersion: targetVersion, Migrations: []PlannedMigration{}}
+	for _, v := range versions {
+		f, ok := fileByVer[v]
+		if !ok {
+			return nil, &ErrMissingMigrationFile{Version: v}
+		}
+		var t task.Task
+		if err := f.load(&t); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+		plan.Migrations = append(plan.Migrations, PlannedMigration{Version: v, File: f.name, Name: t.Down.Name})
+	}
+	return plan, nil
+}
+
+// currentVersion returns the store's current version, or DryRunFrom in dry-run mode.
+func (m *Migrator) currentVersion() (int, error) {
+	if m.DryRun {
+		return m.DryRunFrom, nil
+	}
+	cur, err := m.Store.CurrentVersion()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current migration version from store: %w", err)
+	}
+	return cur, nil
+}
+
+// appliedVersions lists the applied versions; in dry-run mode 1..DryRunFrom are treated as applied.
+func (m *Migrator) appliedVersions() ([]int, error) {
+	if m.DryRun {
+		var applied []int
+		for i := 1; i <= m.DryRunFrom; i++ {
+			applied = append(applied, i)
+		}
+		return applied, nil
+	}
+	applied, err := m.Store.ListApplied()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// pendingUp returns the files an up run to targetVersion (<= 0 = all) executes, ascending.
+// Sequential runs apply versions above cur; with MaxParallel > 1 or Tags every version not yet
+// applied is pending, so a version left behind by a failed sibling or skipped by an earlier
+// tag selection is picked up again. Tags then narrow the result (see selectTagged). A cur above
+// the newest file is reported as ErrVersionGap.
+func (m *Migrator) pendingUp(files []vfile, cur, targetVersion int) ([]vfile, error) {
+	if err := checkVersionGap(files, cur); err != nil {
+		return nil, err
+	}
+	if m.MaxParallel <= 1 && len(m.Tags) == 0 {
+		return planUp(files, cur, targetVersion), nil
+	}
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	isApplied := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+	limit := targetVersion
+	if limit <= 0 {
+		limit = 1<<31 - 1
+	}
+	var pending []vfile
+	for _, f := range files {
+		if f.index <= limit && !isApplied[f.index] {
+			pending = append(pending, f)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].index < pending[j].index })
+	return m.selectTagged(pending)
+}
+
+// rollbackVersions returns the applied versions above targetVersion, newest first.
+func (m *Migrator) rollbackVersions(cur, targetVersion int) ([]int, error) {
+	if targetVersion > cur {
+		return nil, fmt.Errorf("target version %d is above current %d", targetVersion, cur)
+	}
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+	toRollback := make([]int, 0)
+	for _, v := range applied {
+		if v > targetVersion {
+			toRollback = append(toRollback, v)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(toRollback)))
+	return toRollback, nil
+}
@@ -0,0 +1,121 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/loykin/apirun/internal/task"
+)
+
+// Statuses of a PlanDiffEntry.
+const (
+	// DiffWouldApply: the migration has no probe, or its probe shows the change is missing.
+	DiffWouldApply = "would-apply"
+	// DiffAlreadySatisfied: the probe shows the change is already in place.
+	DiffAlreadySatisfied = "already-satisfied"
+	// DiffUnknown: the probe could not be sent or rendered.
+	DiffUnknown = "unknown"
+)
+
+// PlanDiffEntry is a pending up migration with what its probe reported.
+type PlanDiffEntry struct {
+	Version    int    `json:"version"`
+	File       string `json:"file"`
+	Name       string `json:"name,omitempty"`
+	Status     string `json:"status"`
+	Probed     bool   `json:"probed"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// PlanDiff compares the pending up migrations with a live environment.
+type PlanDiff struct {
+	CurrentVersion int             `json:"current_version"`
+	TargetVersion  int             `json:"target_version"`
+	Migrations     []PlanDiffEntry `json:"migrations"`
+}
+
+// Count returns the number of entries with the given status.
+func (d *PlanDiff) Count(status string) int {
+	n := 0
+	for _, e := range d.Migrations {
+		if e.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// PlanDiff returns the migrations MigrateUp(ctx, targetVersion) would apply, each marked by
+// sending its up.probe: a read-only GET/HEAD request whose result tells whether the change is
+// already in place. Only probes are sent (auth runs as for an up run); no up request is sent
+// and nothing is recorded. Env extracted by a satisfied probe is visible to later probes.
+func (m *Migrator) PlanDiff(ctx context.Context, targetVersion int) (*PlanDiff, error) {
+	logger := m.logger()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	restoreEnv, err := m.beginEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer restoreEnv()
+	ctx, closeTransport, err := m.withClientTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTransport()
+	ctx = m.withRequestOptions(ctx)
+	if err := m.ensureAuth(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ensure authentication: %w", err)
+	}
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	cur, err := m.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := m.pendingUp(files, cur, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &PlanDiff{CurrentVersion: cur, TargetVersion: targetVersion, Migrations: []PlanDiffEntry{}}
+	sessionStored := map[string]string{}
+	for _, f := range pending {
+		t, err := m.loadUpTask(f, sessionStored)
+		if err != nil {
+			return nil, err
+		}
+		entry := PlanDiffEntry{Version: f.index, File: f.name, Name: t.Up.Name, Status: DiffWouldApply}
+		if t.Up.Probe == nil {
+			diff.Migrations = append(diff.Migrations, entry)
+			continue
+		}
+		entry.Probed = true
+		var res *task.ProbeResult
+		err = m.applyContextValues(ctx, t.Up.Env, t.Up.Probe.Response.FailsOnMissing())
+		if err == nil {
+			res, err = t.Up.RunProbe(ctx)
+		}
+		switch {
+		case err != nil:
+			entry.Status = DiffUnknown
+			entry.Reason = err.Error()
+		case res.Satisfied:
+			entry.Status = DiffAlreadySatisfied
+			entry.StatusCode = res.StatusCode
+			for k, v := range res.ExtractedEnv {
+				sessionStored[k] = v
+			}
+		default:
+			entry.StatusCode = res.StatusCode
+			entry.Reason = res.Reason
+		}
+		logger.Debug("probed migration", "version", f.index, "status", entry.Status, "status_code", entry.StatusCode, "reason", entry.Reason)
+		diff.Migrations = append(diff.Migrations, entry)
+	}
+	return diff, nil
+}
@@ -0,0 +1,193 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func writePlanMigration(t *testing.T, dir, file, name, url string) {
+	t.Helper()
+	body := "up:\n  name: " + name + "\n  request:\n    method: POST\n    url: " + url + "\n  response:\n    result_code: ['200']\n" +
+		"down:\n  name: undo " + name + "\n  method: DELETE\n  url: " + url + "\n"
+	writeMigrations(t, dir, map[string]string{file: body})
+}
+
+func TestPlan_MatchesWhatMigrateUpApplies(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writePlanMigration(t, dir, "001_a.yaml", "create a", srv.URL)
+	writePlanMigration(t, dir, "002_b.yaml", "create b", srv.URL)
+	writePlanMigration(t, dir, "003_c.yaml", "create c", srv.URL)
+	m, st := newTestMigrator(t, dir)
+	if err := st.Apply(1); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	plan, err := m.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	want := []PlannedMigration{{Version: 2, File: "002_b.yaml", Name: "create b"}, {Version: 3, File: "003_c.yaml", Name: "create c"}}
+	if plan.Direction != "up" || plan.CurrentVersion != 1 || !reflect.DeepEqual(plan.Migrations, want) {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("Plan must not send requests")
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1}) {
+		t.Fatalf("Plan must not record versions, got %v", applied)
+	}
+
+	upTo2, err := m.PlanUp(context.Background(), 2)
+	if err != nil || !reflect.DeepEqual(upTo2.Versions(), []int{2}) {
+		t.Fatalf("PlanUp(2) = %v, %v", upTo2, err)
+	}
+
+	results, err := m.MigrateUp(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	var ran []int
+	for _, r := range results {
+		ran = append(ran, r.Version)
+	}
+	if !reflect.DeepEqual(ran, plan.Versions()) {
+		t.Fatalf("MigrateUp ran %v, plan said %v", ran, plan.Versions())
+	}
+}
+
+func TestPlanDown_ReverseOrderAndTargetValidation(t *testing.T) {
+	dir := t.TempDir()
+	writePlanMigration(t, dir, "001_a.yaml", "a", "http://127.0.0.1:1")
+	writePlanMigration(t, dir, "002_b.yaml", "b", "http://127.0.0.1:1")
+	writePlanMigration(t, dir, "003_c.yaml", "c", "http://127.0.0.1:1")
+	m, st := newTestMigrator(t, dir)
+	for _, v := range []int{1, 2, 3} {
+		if err := st.Apply(v); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+
+	plan, err := m.PlanDown(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("PlanDown: %v", err)
+	}
+	want := []PlannedMigration{{Version: 3, File: "003_c.yaml", Name: "undo c"}, {Version: 2, File: "002_b.yaml", Name: "undo b"}}
+	if plan.Direction != "down" || plan.TargetVersion != 1 || !reflect.DeepEqual(plan.Migrations, want) {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if _, err := m.PlanDown(context.Background(), 5); err == nil || !strings.Contains(err.Error(), "above current") {
+		t.Fatalf("expected target above current error, got %v", err)
+	}
+}
+
+func TestPlan_DryRunAndParallelSemantics(t *testing.T) {
+	dir := t.TempDir()
+	writePlanMigration(t, dir, "001_a.yaml", "a", "http://x")
+	writePlanMigration(t, dir, "002_b.yaml", "b", "http://x")
+	writePlanMigration(t, dir, "003_c.yaml", "c", "http://x")
+	seq, st := newTestMigrator(t, dir)
+	// Version 2 was left behind by a failed parallel run
+	_ = st.Apply(1)
+	_ = st.Apply(3)
+
+	if plan, err := seq.Plan(context.Background()); err != nil || len(plan.Migrations) != 0 {
+		t.Fatalf("sequential runs only apply versions above current, got %v, %v", plan, err)
+	}
+	par := &Migrator{Dir: dir, Store: *st, MaxParallel: 2}
+	if plan, err := par.Plan(context.Background()); err != nil || !reflect.DeepEqual(plan.Versions(), []int{2}) {
+		t.Fatalf("parallel runs pick up unapplied versions, got %v, %v", plan, err)
+	}
+	dry := &Migrator{Dir: dir, Store: *st, DryRun: true, DryRunFrom: 1}
+	if plan, err := dry.Plan(context.Background()); err != nil || !reflect.DeepEqual(plan.Versions(), []int{2, 3}) {
+		t.Fatalf("dry run plans from DryRunFrom, got %v, %v", plan, err)
+	}
+}
+
+func TestPlanDiff_ProbesPendingMigrations(t *testing.T) {
+	var upCalls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			atomic.AddInt32(&upCalls, 1)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		switch r.URL.Path {
+		case "/realms/demo":
+			_, _ = w.Write([]byte(`{"id":"r-1"}`))
+		case "/realms/r-1/clients/app":
+			_, _ = w.Write([]byte(`{"enabled":false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writePlanMigration(t, dir, "001_base.yaml", "base", srv.URL)
+	files := map[string]string{
+		"002_realm.yaml": "up:\n  name: realm\n  request: { method: POST, url: " + srv.URL + "/realms }\n" +
+			"  probe:\n    request: { url: " + srv.URL + "/realms/demo }\n    response:\n      env_from: { realm_id: id }\n",
+		"003_client.yaml": "up:\n  name: client\n  request: { method: POST, url: " + srv.URL + "/clients }\n" +
+			"  probe:\n    request: { url: '" + srv.URL + "/realms/{{.env.realm_id}}/clients/app' }\n    assert: { enabled: 'true' }\n",
+		"004_role.yaml": "up:\n  name: role\n  request: { method: POST, url: " + srv.URL + "/roles }\n" +
+			"  probe:\n    request: { url: " + srv.URL + "/roles/admin }\n",
+		"005_group.yaml": "up:\n  name: group\n  request: { method: POST, url: " + srv.URL + "/groups }\n" +
+			"  probe:\n    request: { url: 'http://127.0.0.1:1/groups', retry: { max_attempts: 1 } }\n",
+	}
+	writeMigrations(t, dir, files)
+	m, st := newTestMigrator(t, dir)
+	if err := st.Apply(1); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	diff, err := m.PlanDiff(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("PlanDiff: %v", err)
+	}
+	if diff.CurrentVersion != 1 || len(diff.Migrations) != 4 {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	got := map[int]PlanDiffEntry{}
+	for _, e := range diff.Migrations {
+		got[e.Version] = e
+	}
+	if e := got[2]; e.Status != DiffAlreadySatisfied || !e.Probed || e.StatusCode != http.StatusOK {
+		t.Fatalf("v2: %+v", e)
+	}
+	// The realm_id extracted by the v2 probe renders the v3 probe URL
+	if e := got[3]; e.Status != DiffWouldApply || e.StatusCode != http.StatusOK || !strings.Contains(e.Reason, "assert enabled") {
+		t.Fatalf("v3: %+v", e)
+	}
+	if e := got[4]; e.Status != DiffWouldApply || e.StatusCode != http.StatusNotFound {
+		t.Fatalf("v4: %+v", e)
+	}
+	if e := got[5]; e.Status != DiffUnknown || e.Reason == "" {
+		t.Fatalf("v5: %+v", e)
+	}
+	if diff.Count(DiffWouldApply) != 2 {
+		t.Fatalf("Count(would-apply) = %d, want 2", diff.Count(DiffWouldApply))
+	}
+	if atomic.LoadInt32(&upCalls) != 0 {
+		t.Fatalf("PlanDiff must only send probes, got %d up requests", upCalls)
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1}) {
+		t.Fatalf("PlanDiff must not record versions, got %v", applied)
+	}
+
+	upTo2, err := m.PlanDiff(context.Background(), 2)
+	if err != nil || len(upTo2.Migrations) != 1 || upTo2.Migrations[0].Version != 2 {
+		t.Fatalf("PlanDiff(2) = %+v, %v", upTo2, err)
+	}
+}
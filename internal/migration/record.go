@@ -0,0 +1,140 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/task"
+)
+
+// StepRecording is the content of a RecordDir file: the HTTP exchanges of one up or down step.
+// Sensitive header values and secrets in URLs and bodies are masked with the global Masker.
+type StepRecording struct {
+	Version   int    `json:"version"`
+	Direction string `json:"direction"`
+	Name      string `json:"name,omitempty"`
+	RunID     string `json:"run_id,omitempty"`
+	// Error is the step error, if any; a request that got no response has no exchange.
+	Error string `json:"error,omitempty"`
+	// Exchanges lists every response received in order: find requests, pages and retried
+	// attempts each add one.
+	Exchanges []RecordedExchange `json:"exchanges"`
+}
+
+// RecordedExchange is one request with the response it got.
+type RecordedExchange struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request as sent; multipart bodies are not recorded.
+type RecordedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// RecordedResponse is a full response, whatever SaveResponseBody says.
+type RecordedResponse struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// recordingFileName returns the RecordDir file name of a step, e.g. "3_up.json".
+func recordingFileName(version int, direction string) string {
+	return fmt.Sprintf("%d_%s.json", version, direction)
+}
+
+// startRecording returns ctx with a response middleware collecting the exchanges of the step into
+// the returned recording, or ctx and nil when RecordDir is not set.
+func (m *Migrator) startRecording(ctx context.Context, step StepInfo) (context.Context, *StepRecording) {
+	if strings.TrimSpace(m.RecordDir) == "" {
+		return ctx, nil
+	}
+	rec := &StepRecording{Version: step.Version, Direction: step.Direction, Name: step.Name, RunID: m.RunID}
+	ctx = task.AppendMiddleware(ctx, task.Middleware{Response: []func(*resty.Response){func(r *resty.Response) {
+		rec.Exchanges = append(rec.Exchanges, recordExchange(r))
+	}}})
+	return ctx, rec
+}
+
+// finishRecording writes rec to RecordDir. Write failures are logged and do not fail the step.
+func (m *Migrator) finishRecording(rec *StepRecording, stepErr error) {
+	if rec == nil {
+		return
+	}
+	if stepErr != nil {
+		rec.Error = common.GetGlobalMasker().MaskString(stepErr.Error())
+	}
+	if rec.Exchanges == nil {
+		rec.Exchanges = []RecordedExchange{}
+	}
+	path := filepath.Join(m.RecordDir, recordingFileName(rec.Version, rec.Direction))
+	if err := writeRecording(path, rec); err != nil {
+		m.logger().WithVersion(rec.Version).Warn("failed to write step recording",
+			"direction", rec.Direction, "path", path, "error", err)
+	}
+}
+
+func writeRecording(path string, rec *StepRecording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func recordExchange(r *resty.Response) RecordedExchange {
+	masker := common.GetGlobalMasker()
+	ex := RecordedExchange{Response: RecordedResponse{
+		StatusCode: r.StatusCode(),
+		Headers:    maskedHeaders(masker, r.Header()),
+		Body:       masker.MaskString(string(r.Body())),
+		DurationMs: r.Time().Milliseconds(),
+	}}
+	if req := r.Request; req != nil {
+		ex.Request = RecordedRequest{
+			Method:  req.Method,
+			URL:     masker.MaskString(req.URL),
+			Headers: maskedHeaders(masker, req.Header),
+			Body:    masker.MaskString(requestBody(req.Body)),
+		}
+	}
+	return ex
+}
+
+// requestBody returns the body of a task request; task requests set it as a string or bytes.
+func requestBody(body interface{}) string {
+	switch b := body.(type) {
+	case string:
+		return b
+	case []byte:
+		return string(b)
+	default:
+		return ""
+	}
+}
+
+func maskedHeaders(masker *common.Masker, h http.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(h))
+	for k, vs := range h {
+		out[k] = fmt.Sprint(masker.MaskValue(k, strings.Join(vs, ", ")))
+	}
+	return out
+}
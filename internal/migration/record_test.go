@@ -0,0 +1,139 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func readRecording(t *testing.T, path string) StepRecording {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	var rec StepRecording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return rec
+}
+
+func TestRecordDir_WritesMaskedFixturePerStep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Path", r.URL.Path)
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"u-1","access_token":"tok-123"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"patched":true}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m1 := "up:\n  name: create\n  request:\n    method: POST\n    url: " + srv.URL + "/users\n" +
+		"    headers:\n      - { name: Authorization, value: 'Bearer s3cr3t' }\n" +
+		"    body: '{\"name\":\"{{.env.tenant}}\"}'\n  response:\n    result_code: ['201']\n    env_from:\n      uid: id\n"
+	m2 := "up:\n  name: patch\n  request:\n    method: PATCH\n    url: " + srv.URL + "/users/{{.env.uid}}\n" +
+		"    queries:\n      - { name: dry, value: 'no' }\n  response:\n    result_code: ['200']\n"
+	for name, body := range map[string]string{"001_create.yaml": m1, "002_patch.yaml": m2} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	base := env.New()
+	_ = base.SetString("global", "tenant", "acme")
+	recDir := filepath.Join(t.TempDir(), "fixtures")
+	m := &Migrator{Dir: dir, Store: *st, Env: base, RecordDir: recDir, RunID: "run-1"}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	entries, err := os.ReadDir(recDir)
+	if err != nil {
+		t.Fatalf("read record dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "1_up.json,2_up.json" {
+		t.Fatalf("recorded files = %v", names)
+	}
+
+	rec := readRecording(t, filepath.Join(recDir, "1_up.json"))
+	if rec.Version != 1 || rec.Direction != "up" || rec.Name != "create" || rec.RunID != "run-1" || rec.Error != "" {
+		t.Fatalf("unexpected step fields: %+v", rec)
+	}
+	if len(rec.Exchanges) != 1 {
+		t.Fatalf("exchanges = %d, want 1", len(rec.Exchanges))
+	}
+	ex := rec.Exchanges[0]
+	if ex.Request.Method != http.MethodPost || ex.Request.URL != srv.URL+"/users" || ex.Request.Body != `{"name":"acme"}` {
+		t.Fatalf("unexpected request: %+v", ex.Request)
+	}
+	if got := ex.Request.Headers["Authorization"]; got != "***MASKED***" {
+		t.Fatalf("Authorization header = %q, want masked", got)
+	}
+	// The body is recorded even though SaveResponseBody is off, with secrets masked
+	if ex.Response.StatusCode != http.StatusCreated || !strings.Contains(ex.Response.Body, `"id":"u-1"`) ||
+		strings.Contains(ex.Response.Body, "tok-123") {
+		t.Fatalf("unexpected response: %+v", ex.Response)
+	}
+	if got := ex.Response.Headers["X-Request-Path"]; got != "/users" {
+		t.Fatalf("response header X-Request-Path = %q", got)
+	}
+
+	rec = readRecording(t, filepath.Join(recDir, "2_up.json"))
+	if len(rec.Exchanges) != 1 {
+		t.Fatalf("exchanges = %d, want 1", len(rec.Exchanges))
+	}
+	ex = rec.Exchanges[0]
+	if ex.Request.Method != http.MethodPatch || ex.Request.URL != srv.URL+"/users/u-1?dry=no" {
+		t.Fatalf("unexpected request: %+v", ex.Request)
+	}
+	if ex.Response.StatusCode != http.StatusOK || ex.Response.Body != `{"patched":true}` {
+		t.Fatalf("unexpected response: %+v", ex.Response)
+	}
+
+	runs, err := st.ListRuns()
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	for _, r := range runs {
+		if r.Body != nil {
+			t.Fatalf("run of version %d saved a body without SaveResponseBody", r.Version)
+		}
+	}
+}
+
+func TestRecordDir_DryRunWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	m1 := "up:\n  name: create\n  request:\n    method: POST\n    url: http://127.0.0.1:1/users\n  response:\n    result_code: ['201']\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_create.yaml"), []byte(m1), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	recDir := filepath.Join(t.TempDir(), "fixtures")
+	m := &Migrator{Dir: dir, Store: *st, Env: env.New(), RecordDir: recDir, DryRun: true}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := os.Stat(recDir); !os.IsNotExist(err) {
+		t.Fatalf("record dir created in a dry run: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"sync"
+
+	"github.com/loykin/apirun/internal/task"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// runGlobals holds the env_from values with scope: global extracted during one run. Parallel
+// up steps write them while later steps are prepared, hence the lock.
+type runGlobals struct {
+	mu   sync.Mutex
+	vals map[string]string
+}
+
+// beginRunGlobals starts an empty run-global env for one MigrateUp, MigrateDown, MigrateTo or
+// Redo call unless one is running (e.g. MigrateTo calling MigrateUp), and returns a func that
+// drops it again, so that the values never outlive the run.
+func (m *Migrator) beginRunGlobals() func() {
+	if m.globals != nil {
+		return func() {}
+	}
+	m.globals = &runGlobals{vals: map[string]string{}}
+	return func() { m.globals = nil }
+}
+
+// promoteGlobals puts the values extracted by an up response for its env_from names with
+// scope: global into the run-global env.
+func (m *Migrator) promoteGlobals(resp task.ResponseSpec, extracted map[string]string) {
+	g := m.globals
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, k := range resp.GlobalKeys() {
+		if v, ok := extracted[k]; ok {
+			g.vals[k] = v
+		}
+	}
+}
+
+// applyGlobals adds the run-global values to e.Global, over the base values.
+func (m *Migrator) applyGlobals(e *env.Env) {
+	g := m.globals
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.vals) == 0 {
+		return
+	}
+	if e.Global == nil {
+		e.Global = env.Map{}
+	}
+	for k, v := range g.vals {
+		e.Global[k] = env.Str(v)
+	}
+}
@@ -0,0 +1,63 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// A scope: global value is in the global env of every later step of the run, and only there
+func TestMigrateUp_GlobalScopedEnvFrom(t *testing.T) {
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("max_parallel=%d", parallel), func(t *testing.T) {
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+				_, _ = w.Write([]byte(`{"access_token":"t-1","id":"s-1"}`))
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			m1 := "up:\n  request:\n    method: POST\n    url: " + srv.URL + "/login\n  response:\n    env_from:\n      token: { path: access_token, scope: global }\n      sid: id\n"
+			m2 := "depends_on: [1]\nup:\n  request:\n    method: GET\n    url: " + srv.URL + "/items/{{.env.token}}\n"
+			for name, body := range map[string]string{"001_login.yaml": m1, "002_items.yaml": m2} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+			st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+			defer func() { _ = st.Close() }()
+
+			base := env.New()
+			var global, globalSid string
+			m := &Migrator{Dir: dir, Env: base, Store: *st, MaxParallel: parallel, DelayBetweenMigrations: time.Millisecond,
+				BeforeStep: func(_ context.Context, step StepInfo) error {
+					if step.Version == 2 {
+						global, globalSid = step.Env.GetString("global", "token"), step.Env.GetString("global", "sid")
+					}
+					return nil
+				}}
+			if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+				t.Fatalf("MigrateUp: %v", err)
+			}
+			if global != "t-1" || globalSid != "" {
+				t.Fatalf("global env of version 2: token=%q sid=%q; want t-1 and none", global, globalSid)
+			}
+			if len(paths) != 2 || paths[1] != "/items/t-1" {
+				t.Fatalf("unexpected requests %v", paths)
+			}
+			// The value lives for the run only; the caller's env is untouched
+			if got := base.GetString("global", "token"); got != "" || m.globals != nil {
+				t.Fatalf("global value outlived the run: %q", got)
+			}
+		})
+	}
+}
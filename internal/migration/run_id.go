@@ -0,0 +1,21 @@
+package migration
+
+import (
+	"github.com/google/uuid"
+	"github.com/loykin/apirun/internal/common"
+)
+
+// beginRun gives m a run id for one MigrateUp/MigrateDown call unless it already has one, and
+// returns a func that clears a generated id again so the next call gets a fresh one.
+func (m *Migrator) beginRun() func() {
+	if m.RunID != "" {
+		return func() {}
+	}
+	m.RunID = uuid.NewString()
+	return func() { m.RunID = "" }
+}
+
+// logger returns the migrator logger, scoped to the current run id.
+func (m *Migrator) logger() *common.Logger {
+	return common.GetLogger().WithComponent("migrator").WithRunID(m.RunID)
+}
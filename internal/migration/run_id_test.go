@@ -0,0 +1,137 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+// runIDServer records the X-Run-Id header of every request it receives.
+type runIDServer struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (s *runIDServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ids = append(s.ids, r.Header.Get("X-Run-Id"))
+	s.mu.Unlock()
+	_, _ = w.Write([]byte(`{}`))
+}
+
+func writeRunIDMigrations(t *testing.T, dir, url string) {
+	t.Helper()
+	files := map[string]string{}
+	for v := 1; v <= 2; v++ {
+		files[fmt.Sprintf("%03d_v%d.yaml", v, v)] = fmt.Sprintf("up:\n  name: v%[1]d\n  request:\n    method: POST\n    url: %[2]s/v%[1]d\n    headers:\n      - { name: X-Run-Id, value: '{{.run_id}}' }\n"+
+			"down:\n  name: undo v%[1]d\n  method: DELETE\n  url: %[2]s/v%[1]d\n  headers:\n    - { name: X-Run-Id, value: '{{.run_id}}' }\n", v, url)
+	}
+	writeMigrations(t, dir, files)
+}
+
+// captureLogs routes the default logger to a JSON buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := common.GetLogger()
+	common.SetDefaultLogger(&common.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))})
+	t.Cleanup(func() { common.SetDefaultLogger(prev) })
+	return &buf
+}
+
+func TestMigrateUp_RunIDSharedAcrossSteps(t *testing.T) {
+	srv := &runIDServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	dir := t.TempDir()
+	writeRunIDMigrations(t, dir, ts.URL)
+	m, st := newTestMigrator(t, dir)
+	logs := captureLogs(t)
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(srv.ids) != 2 || srv.ids[0] == "" || srv.ids[0] != srv.ids[1] {
+		t.Fatalf("both steps must render the same run id, got %q", srv.ids)
+	}
+	runID := srv.ids[0]
+	if m.RunID != "" {
+		t.Fatalf("a generated run id must not outlive the call, got %q", m.RunID)
+	}
+	runs, err := st.ListRuns()
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	for _, r := range runs {
+		if r.RunID != runID {
+			t.Fatalf("run of version %d recorded run id %q, want %q", r.Version, r.RunID, runID)
+		}
+	}
+
+	var migratorLines int
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var rec map[string]interface{}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		if c := rec["component"]; c != "migrator" && c != "task-up" {
+			continue
+		}
+		migratorLines++
+		if rec["run_id"] != runID {
+			t.Fatalf("log line without the run id: %s", line)
+		}
+	}
+	if migratorLines == 0 {
+		t.Fatalf("no migrator log lines captured")
+	}
+
+	// The next call is a new run with a new id
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(srv.ids) != 4 || srv.ids[2] == runID || srv.ids[2] != srv.ids[3] {
+		t.Fatalf("down steps must share a fresh run id, got %q", srv.ids)
+	}
+}
+
+func TestMigrate_CallerSuppliedRunID(t *testing.T) {
+	srv := &runIDServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	dir := t.TempDir()
+	writeRunIDMigrations(t, dir, ts.URL)
+	m, st := newTestMigrator(t, dir)
+	m.RunID = "deploy-42"
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	for _, id := range srv.ids {
+		if id != "deploy-42" {
+			t.Fatalf("requests must carry the supplied run id, got %q", srv.ids)
+		}
+	}
+	runs, err := st.ListRuns()
+	if err != nil || len(runs) != 4 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	for _, r := range runs {
+		if r.RunID != "deploy-42" {
+			t.Fatalf("%s run of version %d recorded run id %q", r.Direction, r.Version, r.RunID)
+		}
+	}
+	if m.RunID != "deploy-42" {
+		t.Fatalf("a supplied run id must be kept, got %q", m.RunID)
+	}
+}
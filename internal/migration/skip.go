@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/internal/task"
+)
+
+// DirectionSkipped is the direction of the runs recorded for versions skipped by an up
+// response's on_match directive, and for their removal by a down run.
+const DirectionSkipped = "skipped"
+
+// skippedEnvKey marks, in the stored env of a version, that on_match skipped it. The down
+// step of such a version is not sent, since its up step never ran.
+const skippedEnvKey = "apirun_skipped"
+
+// skipCount returns how many of the versions after plan[i] the action skips.
+func skipCount(plan []vfile, i int, skip *task.SkipAction) int {
+	if skip.Next {
+		if i+1 < len(plan) {
+			return 1
+		}
+		return 0
+	}
+	n := 0
+	for j := i + 1; j < len(plan) && plan[j].index < skip.To; j++ {
+		n++
+	}
+	return n
+}
+
+// skipVersion records f as applied without running it.
+func (m *Migrator) skipVersion(f vfile) error {
+	err := m.Store.WithTx(func(tx *store.Store) error {
+		if err := applyVersion(tx, f); err != nil {
+			return err
+		}
+		if err := tx.RecordRunWithDetails(f.index, DirectionSkipped, 0, nil, nil, false, store.RunDetails{RunID: m.RunID}); err != nil {
+			return fmt.Errorf("record skipped run %d: %w", f.index, err)
+		}
+		if err := tx.InsertStoredEnv(f.index, map[string]string{skippedEnvKey: "true"}); err != nil {
+			return fmt.Errorf("mark version %d skipped: %w", f.index, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.logger().Info("skipped migration", "version", f.index, "file", f.name)
+	return nil
+}
+
+// wasSkipped reports whether version ver was applied by skipVersion.
+func (m *Migrator) wasSkipped(ver int) bool {
+	stored, _ := m.Store.LoadStoredEnv(ver)
+	return stored[skippedEnvKey] != ""
+}
+
+// removeSkipped is the down step of a skipped version: it is removed without a request.
+func (m *Migrator) removeSkipped(ver int, f vfile) (*ExecWithVersion, error) {
+	ewv := &ExecWithVersion{Version: ver, Skipped: true}
+	if m.DryRun {
+		return ewv, nil
+	}
+	err := m.Store.WithTx(func(tx *store.Store) error {
+		if err := tx.RecordRunWithDetails(ver, DirectionSkipped, 0, nil, nil, false, store.RunDetails{RunID: m.RunID}); err != nil {
+			return err
+		}
+		if err := tx.Remove(ver); err != nil {
+			return fmt.Errorf("record remove %d: %w", ver, err)
+		}
+		return tx.DeleteStoredEnv(ver)
+	})
+	if err != nil {
+		return ewv, err
+	}
+	m.logger().Info("removed skipped migration without running its down step", "version", ver, "file", f.name)
+	return ewv, nil
+}
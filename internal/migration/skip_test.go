@@ -0,0 +1,128 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// skipServer reports {"exists": true} for GET /feature and records every request path.
+func skipServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"exists":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+func writeSkipMigrations(t *testing.T, dir, url, equals, action string) {
+	t.Helper()
+	files := map[string]string{
+		"001_check.yaml": "up:\n  name: check\n  request:\n    method: GET\n    url: " + url + "/feature\n" +
+			"  response:\n    on_match: { path: exists, equals: '" + equals + "', action: '" + action + "' }\n" +
+			"down:\n  name: noop\n  method: GET\n  url: " + url + "/feature\n",
+	}
+	for v := 2; v <= 4; v++ {
+		files[fmt.Sprintf("%03d_v%d.yaml", v, v)] = fmt.Sprintf("up:\n  name: v%d\n  request:\n    method: POST\n    url: %s/v%d\n"+
+			"down:\n  name: undo v%d\n  method: DELETE\n  url: %s/v%d\n", v, url, v, v, url, v)
+	}
+	writeMigrations(t, dir, files)
+}
+
+func TestMigrateUp_OnMatchSkips(t *testing.T) {
+	cases := []struct {
+		action  string
+		skipped []int
+		sent    []string
+	}{
+		{"skip_next", []int{2}, []string{"GET /feature", "POST /v3", "POST /v4"}},
+		{"skip_to:4", []int{2, 3}, []string{"GET /feature", "POST /v4"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.action, func(t *testing.T) {
+			srv, calls := skipServer(t)
+			dir := t.TempDir()
+			writeSkipMigrations(t, dir, srv.URL, "{{.env.want}}", tc.action)
+			m, st := newTestMigrator(t, dir)
+			m.Env.Local = env.FromStringMap(map[string]string{"want": "true"})
+
+			results, err := m.MigrateUp(context.Background(), 0)
+			if err != nil {
+				t.Fatalf("migrate up: %v", err)
+			}
+			if got := calls(); fmt.Sprint(got) != fmt.Sprint(tc.sent) {
+				t.Fatalf("sent %v, want %v", got, tc.sent)
+			}
+			var skipped []int
+			for _, r := range results {
+				if r.Skipped {
+					skipped = append(skipped, r.Version)
+				}
+			}
+			if fmt.Sprint(skipped) != fmt.Sprint(tc.skipped) {
+				t.Fatalf("skipped %v, want %v", skipped, tc.skipped)
+			}
+			// Skipped versions are applied, keeping the sequence consistent
+			if applied, _ := st.ListApplied(); fmt.Sprint(applied) != "[1 2 3 4]" {
+				t.Fatalf("applied = %v", applied)
+			}
+			runs, err := st.ListRuns()
+			if err != nil {
+				t.Fatalf("ListRuns: %v", err)
+			}
+			var skippedRuns []int
+			for _, r := range runs {
+				if r.Direction == DirectionSkipped {
+					skippedRuns = append(skippedRuns, r.Version)
+				}
+			}
+			if fmt.Sprint(skippedRuns) != fmt.Sprint(tc.skipped) {
+				t.Fatalf("skipped runs %v, want %v", skippedRuns, tc.skipped)
+			}
+
+			// Going down, skipped versions are removed without their down request
+			if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+				t.Fatalf("migrate down: %v", err)
+			}
+			for _, c := range calls() {
+				for _, v := range tc.skipped {
+					if c == fmt.Sprintf("DELETE /v%d", v) {
+						t.Fatalf("down request sent for skipped version %d", v)
+					}
+				}
+			}
+			if cur, _ := st.CurrentVersion(); cur != 0 {
+				t.Fatalf("expected version 0 after down, got %d", cur)
+			}
+		})
+	}
+}
+
+func TestMigrateUp_OnMatchNoMatchRunsAll(t *testing.T) {
+	srv, calls := skipServer(t)
+	dir := t.TempDir()
+	writeSkipMigrations(t, dir, srv.URL, "false", "skip_to:4")
+	m, _ := newTestMigrator(t, dir)
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if got := calls(); len(got) != 4 {
+		t.Fatalf("expected every migration to run, sent %v", got)
+	}
+}
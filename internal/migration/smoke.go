@@ -0,0 +1,225 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/loykin/apirun/internal/task"
+)
+
+// Defaults of SmokeOptions.
+const (
+	DefaultSmokeURLVar      = "base_url"
+	DefaultSmokeReadOnlyTag = "readonly"
+)
+
+// Modes of a SmokeStep.
+const (
+	// SmokeRequest: the up request was sent, being a GET/HEAD or tagged read-only.
+	SmokeRequest = "request"
+	// SmokeProbe: the up.probe was sent instead of the up request.
+	SmokeProbe = "probe"
+	// SmokeSkipped: the up request may change the target and there is no probe.
+	SmokeSkipped = "skipped"
+)
+
+// SmokeOptions configures Smoke.
+type SmokeOptions struct {
+	// Targets are the base URLs to check; each is set as the global env value URLVar.
+	Targets []string
+	// URLVar is the global env name migrations read the base URL from ("" = "base_url").
+	URLVar string
+	// ReadOnlyTag marks migrations whose up request is sent whatever its method
+	// ("" = "readonly").
+	ReadOnlyTag string
+	// Parallel bounds the targets checked at once (0 = all of them).
+	Parallel int
+	// TargetVersion is the highest version checked (0 = all).
+	TargetVersion int
+}
+
+// SmokeStep is the outcome of one migration against one target.
+type SmokeStep struct {
+	Version    int    `json:"version"`
+	Mode       string `json:"mode"`
+	Passed     bool   `json:"passed"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// SmokeTarget is the outcome of all migrations against one target.
+type SmokeTarget struct {
+	URL    string      `json:"url"`
+	Passed bool        `json:"passed"`
+	Steps  []SmokeStep `json:"steps"`
+}
+
+// SmokeMigration names a migration of a SmokeReport.
+type SmokeMigration struct {
+	Version int    `json:"version"`
+	File    string `json:"file"`
+	Name    string `json:"name,omitempty"`
+}
+
+// SmokeReport is the result of Smoke: a step per migration and target, in order.
+type SmokeReport struct {
+	Migrations []SmokeMigration `json:"migrations"`
+	Targets    []SmokeTarget    `json:"targets"`
+}
+
+// Failed returns the number of targets with a failed step.
+func (r *SmokeReport) Failed() int {
+	n := 0
+	for _, t := range r.Targets {
+		if !t.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// Smoke checks that the migrations up to opts.TargetVersion can run against each target without
+// changing it. Against every target, concurrently, the migrations are walked in version order: a
+// GET/HEAD up request, or the up request of a migration tagged opts.ReadOnlyTag, is sent as is;
+// other migrations send their up.probe; the rest are skipped. A step passes when it succeeds as
+// an up step would (a probe, satisfied or not) with a status below 500. Values extracted by
+// passing steps are visible to later steps of the same target. The store is neither read nor
+// written.
+func (m *Migrator) Smoke(ctx context.Context, opts SmokeOptions) (*SmokeReport, error) {
+	if len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("no smoke test targets")
+	}
+	if opts.URLVar == "" {
+		opts.URLVar = DefaultSmokeURLVar
+	}
+	if opts.ReadOnlyTag == "" {
+		opts.ReadOnlyTag = DefaultSmokeReadOnlyTag
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	restoreEnv, err := m.beginEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer restoreEnv()
+	ctx, closeTransport, err := m.withClientTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTransport()
+	ctx = m.withRequestOptions(ctx)
+	if err := m.ensureAuth(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ensure authentication: %w", err)
+	}
+	files, err := listMigrationFiles(m.FS, m.Dir, m.VersionPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files in directory %q: %w", m.Dir, err)
+	}
+	report := &SmokeReport{Migrations: []SmokeMigration{}, Targets: make([]SmokeTarget, len(opts.Targets))}
+	var selected []vfile
+	for _, f := range files {
+		if opts.TargetVersion > 0 && f.index > opts.TargetVersion {
+			break
+		}
+		var t task.Task
+		if err := f.load(&t); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+		selected = append(selected, f)
+		report.Migrations = append(report.Migrations, SmokeMigration{Version: f.index, File: f.name, Name: t.Up.Name})
+	}
+
+	parallel := opts.Parallel
+	if parallel <= 0 || parallel > len(opts.Targets) {
+		parallel = len(opts.Targets)
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, url := range opts.Targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Targets[i] = m.smokeTarget(ctx, selected, url, opts)
+		}()
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// smokeTarget runs the steps of files against url.
+func (m *Migrator) smokeTarget(ctx context.Context, files []vfile, url string, opts SmokeOptions) SmokeTarget {
+	logger := m.logger()
+	// A copy with its own env; as in a dry run from version 0 no stored env is read
+	tm := *m
+	tm.Env = m.Env.Clone()
+	tm.DryRun, tm.DryRunFrom = true, 0
+	_ = tm.Env.SetString("global", opts.URLVar, url)
+
+	target := SmokeTarget{URL: url, Passed: true, Steps: make([]SmokeStep, 0, len(files))}
+	sessionStored := map[string]string{}
+	for _, f := range files {
+		step := tm.smokeStep(ctx, f, opts.ReadOnlyTag, sessionStored)
+		logger.Debug("smoke tested migration", "target", url, "version", f.index, "mode", step.Mode, "passed", step.Passed, "reason", step.Reason)
+		if !step.Passed {
+			target.Passed = false
+		}
+		target.Steps = append(target.Steps, step)
+	}
+	return target
+}
+
+// smokeStep runs the read-only part of the migration f, adding what it extracts to sessionStored.
+func (m *Migrator) smokeStep(ctx context.Context, f vfile, readOnlyTag string, sessionStored map[string]string) SmokeStep {
+	step := SmokeStep{Version: f.index, Mode: SmokeSkipped, Passed: true}
+	t, err := m.loadUpTask(f, sessionStored)
+	if err == nil {
+		err = m.applyContextValues(ctx, t.Up.Env, t.Up.Response.FailsOnMissing())
+	}
+	if err != nil {
+		step.Passed, step.Reason = false, err.Error()
+		return step
+	}
+	method := strings.ToUpper(strings.TrimSpace(t.Up.Request.Method))
+	switch {
+	case method == http.MethodGet || method == http.MethodHead || hasAnyTag(t.Tags, map[string]bool{readOnlyTag: true}):
+		step.Mode = SmokeRequest
+		res, err := t.Up.Execute(ctx, "", "")
+		if res != nil {
+			step.StatusCode = res.StatusCode
+		}
+		if err != nil {
+			step.Passed, step.Reason = false, err.Error()
+			return step
+		}
+		if res.StatusCode >= http.StatusInternalServerError {
+			// Also without a result_code restricting the accepted statuses
+			step.Passed, step.Reason = false, fmt.Sprintf("status %d", res.StatusCode)
+			return step
+		}
+		for k, v := range res.ExtractedEnv {
+			sessionStored[k] = v
+		}
+	case t.Up.Probe != nil:
+		step.Mode = SmokeProbe
+		res, err := t.Up.RunProbe(ctx)
+		if err != nil {
+			step.Passed, step.Reason = false, err.Error()
+			return step
+		}
+		step.StatusCode = res.StatusCode
+		if res.StatusCode >= http.StatusInternalServerError {
+			step.Passed, step.Reason = false, res.Reason
+			return step
+		}
+		for k, v := range res.ExtractedEnv {
+			sessionStored[k] = v
+		}
+	}
+	return step
+}
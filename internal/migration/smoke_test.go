@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestSmoke_ReadOnlyTagAndSessionEnv(t *testing.T) {
+	var posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/search":
+			posts++
+			_, _ = w.Write([]byte(`{"id":"u-1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/users/u-1":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"001_search.yaml": "tags: [safe]\nup:\n  request: { method: POST, url: '{{.env.api}}/search' }\n" +
+			"  response:\n    env_from: { user_id: id }\n",
+		"002_user.yaml":  "up:\n  request: { method: GET, url: '{{.env.api}}/users/{{.env.user_id}}' }\n",
+		"003_write.yaml": "up:\n  request: { method: PUT, url: '{{.env.api}}/users/{{.env.user_id}}' }\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	m := &Migrator{Dir: dir, Env: env.New()}
+
+	report, err := m.Smoke(context.Background(), SmokeOptions{Targets: []string{srv.URL}, URLVar: "api", ReadOnlyTag: "safe"})
+	if err != nil {
+		t.Fatalf("Smoke: %v", err)
+	}
+	if len(report.Targets) != 1 || !report.Targets[0].Passed || report.Failed() != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	steps := report.Targets[0].Steps
+	// The user_id extracted by the tagged v1 POST renders the v2 URL
+	if steps[0].Mode != SmokeRequest || steps[1].Mode != SmokeRequest || steps[1].StatusCode != http.StatusOK {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+	if steps[2].Mode != SmokeSkipped || posts != 1 {
+		t.Fatalf("v3 = %+v, posts = %d", steps[2], posts)
+	}
+	if _, err := os.Stat(filepath.Join(dir, store.DbFileName)); !os.IsNotExist(err) {
+		t.Fatalf("smoke created a store: %v", err)
+	}
+
+	if _, err := m.Smoke(context.Background(), SmokeOptions{}); err == nil {
+		t.Fatalf("expected an error without targets")
+	}
+}
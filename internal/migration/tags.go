@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/loykin/apirun/internal/task"
+)
+
+// selectTagged narrows pending (ascending) to the versions carrying one of m.Tags; all of
+// pending is returned when no tags are set. Without TagsAllowGaps the selection ends at the
+// first pending version that does not match, so an up run never records a version above an
+// unapplied one. With TagsAllowGaps non-matching versions are skipped and stay pending.
+func (m *Migrator) selectTagged(pending []vfile) ([]vfile, error) {
+	want := map[string]bool{}
+	for _, tag := range m.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			want[tag] = true
+		}
+	}
+	if len(want) == 0 {
+		return pending, nil
+	}
+	logger := m.logger()
+	selected := make([]vfile, 0, len(pending))
+	for _, f := range pending {
+		var t task.Task
+		if err := f.load(&t); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+		if hasAnyTag(t.Tags, want) {
+			selected = append(selected, f)
+			continue
+		}
+		if !m.TagsAllowGaps {
+			logger.Info("stopping at migration without a selected tag", "version", f.index, "file", f.name, "tags", m.Tags)
+			break
+		}
+		logger.Info("skipping migration without a selected tag", "version", f.index, "file", f.name, "tags", m.Tags)
+	}
+	return selected, nil
+}
+
+// hasAnyTag reports whether one of tags is in want.
+func hasAnyTag(tags []string, want map[string]bool) bool {
+	for _, tag := range tags {
+		if want[strings.TrimSpace(tag)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/loykin/apirun/internal/store"
+)
+
+func writeTaggedMigration(t *testing.T, dir, file, tags, url string) {
+	t.Helper()
+	body := "tags: " + tags + "\nup:\n  name: " + file + "\n  request:\n    method: POST\n    url: " + url + "\n  response:\n    result_code: ['200']\n"
+	writeMigrations(t, dir, map[string]string{file: body})
+}
+
+// newTaggedMigrator writes 001 [schema], 002 [seed], 003 [schema, cleanup], 004 [seed] and
+// returns a Migrator over them.
+func newTaggedMigrator(t *testing.T) (*Migrator, *store.Store) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+	writeTaggedMigration(t, dir, "001_a.yaml", "[schema]", srv.URL)
+	writeTaggedMigration(t, dir, "002_b.yaml", "[seed]", srv.URL)
+	writeTaggedMigration(t, dir, "003_c.yaml", "[schema, cleanup]", srv.URL)
+	writeTaggedMigration(t, dir, "004_d.yaml", "[seed]", srv.URL)
+	return newTestMigrator(t, dir)
+}
+
+func ranVersions(results []*ExecWithVersion) []int {
+	var ran []int
+	for _, r := range results {
+		ran = append(ran, r.Version)
+	}
+	return ran
+}
+
+func TestMigrateUp_TagsStopAtFirstNonMatching(t *testing.T) {
+	m, st := newTaggedMigrator(t)
+	m.Tags = []string{"schema"}
+
+	results, err := m.MigrateUp(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if ran := ranVersions(results); !reflect.DeepEqual(ran, []int{1}) {
+		t.Fatalf("expected only 001 before the untagged 002, ran %v", ran)
+	}
+
+	// Selecting both tags continues in order
+	m.Tags = []string{"seed", "schema"}
+	results, err = m.MigrateUp(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if ran := ranVersions(results); !reflect.DeepEqual(ran, []int{2, 3, 4}) {
+		t.Fatalf("expected 002-004, ran %v", ran)
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1, 2, 3, 4}) {
+		t.Fatalf("applied = %v", applied)
+	}
+}
+
+func TestMigrateUp_TagsAllowGaps(t *testing.T) {
+	m, st := newTaggedMigrator(t)
+	m.Tags = []string{"seed"}
+	m.TagsAllowGaps = true
+
+	plan, err := m.Plan(context.Background())
+	if err != nil || !reflect.DeepEqual(plan.Versions(), []int{2, 4}) {
+		t.Fatalf("Plan = %v, %v; want [2 4]", plan, err)
+	}
+	results, err := m.MigrateUp(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if ran := ranVersions(results); !reflect.DeepEqual(ran, []int{2, 4}) {
+		t.Fatalf("expected 002 and 004, ran %v", ran)
+	}
+
+	// Without gaps allowed, the skipped 001 now blocks a seed run
+	m.TagsAllowGaps = false
+	writeTaggedMigration(t, m.Dir, "005_e.yaml", "[seed]", "http://127.0.0.1:1")
+	if plan, err := m.Plan(context.Background()); err != nil || len(plan.Migrations) != 0 {
+		t.Fatalf("expected nothing to run past the skipped 001, got %v, %v", plan, err)
+	}
+
+	// A run selecting the skipped versions picks them up below the current version
+	m.Tags = []string{"schema"}
+	m.TagsAllowGaps = true
+	results, err = m.MigrateUp(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if ran := ranVersions(results); !reflect.DeepEqual(ran, []int{1, 3}) {
+		t.Fatalf("expected the skipped 001 and 003, ran %v", ran)
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1, 2, 3, 4}) {
+		t.Fatalf("applied = %v", applied)
+	}
+}
@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"errors"
+
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/task"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRunSpan starts the parent span of a MigrateUp/MigrateDown run. Without a TracerProvider
+// it returns ctx unchanged and a nil span, so untraced runs do no tracing work at all.
+func (m *Migrator) startRunSpan(ctx context.Context, direction string, targetVersion int) (context.Context, trace.Span) {
+	if m.TracerProvider == nil {
+		return ctx, nil
+	}
+	return m.TracerProvider.Tracer(task.TracerName).Start(ctx, "apirun.migrate."+direction,
+		trace.WithAttributes(
+			attribute.String("apirun.direction", direction),
+			attribute.Int("apirun.target_version", targetVersion),
+			attribute.Bool("apirun.dry_run", m.DryRun),
+		))
+}
+
+// endRunSpan records the run outcome on span and ends it; a nil span is a no-op.
+func endRunSpan(span trace.Span, results []*ExecWithVersion, err error) {
+	if span == nil {
+		return
+	}
+	s := newNotifySummary("", results, err, 0, false)
+	span.SetAttributes(attribute.Int("apirun.completed", s.Completed), attribute.Int("apirun.failed", s.Failed))
+	endSpan(span, err)
+}
+
+// startStepSpan starts a child span for one executed up/down step, or returns nil when untraced.
+// HTTP requests sent with the returned context get their own spans below it.
+func (m *Migrator) startStepSpan(ctx context.Context, step StepInfo) (context.Context, trace.Span) {
+	if m.TracerProvider == nil {
+		return ctx, nil
+	}
+	return m.TracerProvider.Tracer(task.TracerName).Start(ctx, "apirun.step."+step.Direction,
+		trace.WithAttributes(
+			attribute.Int("apirun.version", step.Version),
+			attribute.String("apirun.direction", step.Direction),
+			attribute.String("apirun.name", step.Name),
+		))
+}
+
+// endStepSpan records the step's final status code and error on span and ends it.
+func endStepSpan(span trace.Span, res *task.ExecResult, err error) {
+	if span == nil {
+		return
+	}
+	if res != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	}
+	endSpan(span, err)
+}
+
+// endSpan sets the span status from err (masked, as in logs) and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		msg := common.GetGlobalMasker().MaskString(err.Error())
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
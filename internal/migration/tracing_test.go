@@ -0,0 +1,149 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// spanRecorder is a minimal recording TracerProvider; spans keep their parent, attributes and status.
+type spanRecorder struct {
+	noop.TracerProvider
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedTracer struct {
+	noop.Tracer
+	rec *spanRecorder
+}
+
+type recordedSpan struct {
+	noop.Span
+	rec    *spanRecorder
+	name   string
+	parent *recordedSpan
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+	ended  bool
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordedTracer{rec: r}
+}
+
+func (t recordedTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &recordedSpan{rec: t.rec, name: name, attrs: map[attribute.Key]attribute.Value{}}
+	s.parent, _ = trace.SpanFromContext(ctx).(*recordedSpan)
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+	t.rec.mu.Lock()
+	t.rec.spans = append(t.rec.spans, s)
+	t.rec.mu.Unlock()
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (s *recordedSpan) IsRecording() bool                    { return true }
+func (s *recordedSpan) TracerProvider() trace.TracerProvider { return s.rec }
+func (s *recordedSpan) SetStatus(code codes.Code, _ string)  { s.status = code }
+func (s *recordedSpan) End(...trace.SpanEndOption)           { s.ended = true }
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+// tree renders the spans as "parent > child" lines with a few attributes, in start order.
+func (r *spanRecorder) tree() []string {
+	var out []string
+	for _, s := range r.spans {
+		line := s.name
+		for p := s.parent; p != nil; p = p.parent {
+			line = p.name + " > " + line
+		}
+		if v, ok := s.attrs["apirun.version"]; ok {
+			line += fmt.Sprintf(" v=%d", v.AsInt64())
+		}
+		if v, ok := s.attrs["http.response.status_code"]; ok {
+			line += fmt.Sprintf(" status=%d", v.AsInt64())
+		}
+		if s.status == codes.Error {
+			line += " error"
+		}
+		if !s.ended {
+			line += " (not ended)"
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func TestTracing_SpansForRunStepsAndRequests(t *testing.T) {
+	srv := &concurrencyServer{fail: map[string]bool{"/up/002_b": true}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "")
+	writeDAGMigration(t, dir, "002_b.yaml", ts.URL, "")
+	m, _ := newDAGMigrator(t, dir, 0)
+	rec := &spanRecorder{}
+	m.TracerProvider = rec
+
+	if _, err := m.MigrateUp(context.Background(), 0); err == nil {
+		t.Fatal("expected version 2 to fail")
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	want := []string{
+		"apirun.migrate.up error",
+		"apirun.migrate.up > apirun.step.up v=1 status=200",
+		"apirun.migrate.up > apirun.step.up > HTTP POST status=200",
+		"apirun.migrate.up > apirun.step.up v=2 status=400 error",
+		"apirun.migrate.up > apirun.step.up > HTTP POST status=400 error",
+		"apirun.migrate.down",
+		"apirun.migrate.down > apirun.step.down v=1 status=200",
+		"apirun.migrate.down > apirun.step.down > HTTP DELETE status=200",
+	}
+	got := rec.tree()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected spans:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if n := rec.spans[0].attrs["apirun.completed"].AsInt64(); n != 1 {
+		t.Fatalf("expected one completed version on the up run span, got %d", n)
+	}
+}
+
+func TestTracing_DisabledWithoutProvider(t *testing.T) {
+	srv := &concurrencyServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	writeDAGMigration(t, dir, "001_a.yaml", ts.URL, "")
+	m, _ := newDAGMigrator(t, dir, 0)
+	var spanSeen bool
+	m.BeforeStep = func(ctx context.Context, _ StepInfo) error {
+		spanSeen = trace.SpanFromContext(ctx).SpanContext().IsValid() || trace.SpanFromContext(ctx).IsRecording()
+		return nil
+	}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if spanSeen {
+		t.Fatal("expected no span in the step context without a TracerProvider")
+	}
+	if ctx, span := m.startRunSpan(context.Background(), "up", 0); span != nil || ctx != context.Background() {
+		t.Fatal("expected startRunSpan to be a no-op without a TracerProvider")
+	}
+}
@@ -0,0 +1,43 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMigrateUp_RequestsReuseOneConnection(t *testing.T) {
+	var conns, calls int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{}
+	for i := 1; i <= 2; i++ {
+		files[fmt.Sprintf("%03d_item.yaml", i)] = fmt.Sprintf("up:\n  request:\n    method: POST\n    url: %s/items/%d\n  response:\n    result_code: ['200']\n", srv.URL, i)
+	}
+	writeMigrations(t, dir, files)
+	m, _ := newTestMigrator(t, dir)
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("requests = %d, want 2", n)
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("connections = %d, want the two requests to share 1", n)
+	}
+}
@@ -2,10 +2,14 @@ package migration
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/loykin/apirun/internal/task"
 )
@@ -33,16 +37,71 @@ func mapFilesByVersion(files []vfile) map[int]vfile {
 	return m
 }
 
-var versionFileRegex = regexp.MustCompile(`^(\d+)_.*\.(ya?ml)$`)
+// DefaultVersionPattern matches migration files named <version>_<name>.yaml, e.g. 001_init.yaml
+// or 20240115_create.yaml.
+const DefaultVersionPattern = `^(\d+)_.*\.ya?ml$`
+
+var defaultVersionRegex = regexp.MustCompile(DefaultVersionPattern)
+
+// CompileVersionPattern compiles a migration file name pattern ("" = DefaultVersionPattern). Its
+// first capture group is the version, which must be an integer.
+func CompileVersionPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return defaultVersionRegex, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version pattern %q: %w", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("version pattern %q needs a capture group for the version", pattern)
+	}
+	return re, nil
+}
+
+// ParseVersion returns the version re captures from a file name; ok is false for names re does
+// not match. A captured value that is not an integer is an error.
+func ParseVersion(re *regexp.Regexp, name string) (version int, ok bool, err error) {
+	m := re.FindStringSubmatch(name)
+	if len(m) < 2 {
+		return 0, false, nil
+	}
+	v, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false, fmt.Errorf("migration file %s: version %q is not an integer", name, m[1])
+	}
+	return v, true, nil
+}
 
 type vfile struct {
 	index int
 	name  string
 	path  string
+	// fsys is the filesystem path is relative to; nil means the local filesystem.
+	fsys fs.FS
 }
 
-func listMigrationFiles(dir string) ([]vfile, error) {
-	entries, err := os.ReadDir(dir)
+// load decodes the migration file into t.
+func (f vfile) load(t *task.Task) error {
+	if f.fsys != nil {
+		return t.LoadFromFS(f.fsys, f.path)
+	}
+	return t.LoadFromFile(f.path)
+}
+
+// listMigrationFiles lists versioned migration files in dir, read from fsys when it is non-nil.
+// pattern selects the files and captures their version ("" = DefaultVersionPattern).
+func listMigrationFiles(fsys fs.FS, dir, pattern string) ([]vfile, error) {
+	re, err := CompileVersionPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fs.DirEntry
+	if fsys != nil {
+		entries, err = fs.ReadDir(fsys, path.Clean(dir))
+	} else {
+		entries, err = os.ReadDir(dir)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -52,23 +111,32 @@ func listMigrationFiles(dir string) ([]vfile, error) {
 			continue
 		}
 		name := e.Name()
-		m := versionFileRegex.FindStringSubmatch(name)
-		if len(m) == 0 {
-			continue
-		}
-		var idx int
-		_, err := fmt.Sscanf(m[1], "%d", &idx)
+		idx, ok, err := ParseVersion(re, name)
 		if err != nil {
+			return nil, err
+		}
+		if !ok {
 			continue
 		}
-		files = append(files, vfile{index: idx, name: name, path: filepath.Join(dir, name)})
+		if fsys != nil {
+			files = append(files, vfile{index: idx, name: name, path: path.Join(dir, name), fsys: fsys})
+		} else {
+			files = append(files, vfile{index: idx, name: name, path: filepath.Join(dir, name)})
+		}
 	}
 	sort.Slice(files, func(i, j int) bool { return files[i].index < files[j].index })
 	return files, nil
 }
 
 // ExecWithVersion pairs ExecResult with version number.
+// In dry-run mode no request is sent: Result is nil and Requests holds the rendered requests instead.
 type ExecWithVersion struct {
-	Version int
-	Result  *task.ExecResult
+	Version  int
+	Result   *task.ExecResult
+	Requests []*task.RenderedRequest
+	// Failed reports that this version's step failed; Result then holds the failing response.
+	Failed bool
+	// Skipped reports that an on_match directive skipped this version: it was recorded (or,
+	// going down, removed) without sending its request.
+	Skipped bool
 }
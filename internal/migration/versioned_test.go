@@ -4,12 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/loykin/apirun/internal/auth"
 	"github.com/loykin/apirun/internal/store"
 	"github.com/loykin/apirun/pkg/env"
 )
@@ -185,6 +191,56 @@ func TestMigrateUp_StoresEnv_Persisted(t *testing.T) {
 	}
 }
 
+// A failing run record rolls back the apply of the same version, so a rerun makes the request again.
+func TestMigrateUp_RecordFailureLeavesVersionUnapplied(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"id":"abc"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i, name := range []string{"001_a.yaml", "002_b.yaml"} {
+		m := fmt.Sprintf("up:\n  name: m%d\n  request:\n    method: POST\n    url: %s/m%d\n  response:\n    result_code: [\"200\"]\n    env_from:\n      rid: id\n", i+1, srv.URL, i+1)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(m), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	if _, err := st.DB.Exec(`CREATE TRIGGER fail_run BEFORE INSERT ON migration_runs WHEN NEW.version = 2
+		BEGIN SELECT RAISE(ABORT, 'record failed'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	ctx := context.Background()
+	_, err := (&Migrator{Dir: dir, Env: env.New(), Store: *st, DelayBetweenMigrations: time.Millisecond}).MigrateUp(ctx, 0)
+	if err == nil || !strings.Contains(err.Error(), "record failed") {
+		t.Fatalf("expected the run record failure, got %v", err)
+	}
+	applied, err := st.ListApplied()
+	if err != nil {
+		t.Fatalf("ListApplied: %v", err)
+	}
+	if !reflect.DeepEqual(applied, []int{1}) {
+		t.Fatalf("applied = %v, want [1]", applied)
+	}
+	if stored, _ := st.LoadStoredEnv(2); len(stored) != 0 {
+		t.Fatalf("stored env of version 2 was kept: %v", stored)
+	}
+
+	if _, err := st.DB.Exec(`DROP TRIGGER fail_run`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	if _, err := (&Migrator{Dir: dir, Env: env.New(), Store: *st, DelayBetweenMigrations: time.Millisecond}).MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("rerun: %v", err)
+	}
+	if v, _ := st.CurrentVersion(); v != 2 || calls != 3 {
+		t.Fatalf("after rerun: version %d, %d requests; want 2 and 3", v, calls)
+	}
+}
+
 // Verify that stored_env entries are used by Down templating and are deleted after Down
 func TestMigrateDown_UsesStoredEnvAndCleans(t *testing.T) {
 	var delPath string
@@ -245,6 +301,44 @@ func TestMigrateDown_UsesStoredEnvAndCleans(t *testing.T) {
 	}
 }
 
+func TestMigrateDown_IgnoreCodes_RecordsSuccessfulRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	mig := "up:\n  name: create\n  request:\n    method: POST\n    url: " + srv.URL + "/items\n" +
+		"down:\n  name: delete\n  method: DELETE\n  url: " + srv.URL + "/items/1\n  ignore_codes: [404, 410]\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_item.yaml"), []byte(mig), 0o600); err != nil {
+		t.Fatalf("write mig: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	m := &Migrator{Dir: dir, Env: env.New(), Store: *st}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if cur, _ := st.CurrentVersion(); cur != 0 {
+		t.Fatalf("expected version 0 after down, got %d", cur)
+	}
+	runs, err := st.ListRuns()
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	last := runs[len(runs)-1]
+	if last.Direction != "down" || last.StatusCode != http.StatusGone || last.Failed {
+		t.Fatalf("expected a successful down run with status 410, got %+v", last)
+	}
+}
+
 // Additional tests to cover planning and store options behavior introduced/refactored recently.
 func TestMigrateUp_TargetVersionPlanning(t *testing.T) {
 	calls := make(map[string]int)
@@ -307,6 +401,224 @@ func TestMigrateUp_TargetVersionPlanning(t *testing.T) {
 	}
 }
 
+func TestMigrateTo_DispatchesUpDownAndNoop(t *testing.T) {
+	calls := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method+" "+r.URL.Path]++
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		m := fmt.Sprintf("up:\n  name: v%d\n  env: { }\n  request:\n    method: POST\n    url: %s/v%d\n  response:\n    result_code: [\"200\"]\n"+
+			"down:\n  name: v%d-down\n  env: { }\n  method: DELETE\n  url: %s/v%d\n", i, srv.URL, i, i, srv.URL, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d_v%d.yaml", i, i)), []byte(m), 0o600); err != nil {
+			t.Fatalf("write m%d: %v", i, err)
+		}
+	}
+
+	ctx := context.Background()
+	base := env.Env{Global: env.FromStringMap(map[string]string{})}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	mig := &Migrator{Dir: dir, Env: &base, Store: *st}
+
+	steps := []struct {
+		target  int
+		applied int
+	}{
+		{target: 2, applied: 2},
+		{target: 2, applied: 0},
+		{target: 3, applied: 1},
+		{target: 1, applied: 2},
+		{target: 0, applied: 1},
+	}
+	for _, s := range steps {
+		res, err := mig.MigrateTo(ctx, s.target)
+		if err != nil {
+			t.Fatalf("migrate to %d: %v", s.target, err)
+		}
+		if len(res) != s.applied {
+			t.Fatalf("migrate to %d: expected %d results, got %d", s.target, s.applied, len(res))
+		}
+		cur, err := st.CurrentVersion()
+		if err != nil {
+			t.Fatalf("CurrentVersion: %v", err)
+		}
+		if cur != s.target {
+			t.Fatalf("expected current version %d, got %d", s.target, cur)
+		}
+	}
+	if calls["POST /v3"] != 1 || calls["DELETE /v3"] != 1 || calls["DELETE /v1"] != 1 {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+}
+
+func TestMigrateTo_TargetAboveHighest_Errors(t *testing.T) {
+	dir := t.TempDir()
+	m1 := "up:\n  name: v1\n  env: { }\n  request:\n    method: GET\n    url: http://127.0.0.1:1/v1\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_v1.yaml"), []byte(m1), 0o600); err != nil {
+		t.Fatalf("write m1: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	base := env.Env{Global: env.FromStringMap(map[string]string{})}
+	if _, err := (&Migrator{Dir: dir, Env: &base, Store: *st}).MigrateTo(context.Background(), 2); err == nil {
+		t.Fatalf("expected error for target above highest migration")
+	}
+	if _, err := (&Migrator{Dir: dir, Env: &base, Store: *st}).MigrateTo(context.Background(), -1); err == nil {
+		t.Fatalf("expected error for negative target")
+	}
+}
+
+func TestRedo_RollsBackAndReappliesWithStoredEnv(t *testing.T) {
+	var calls []string
+	nextID := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodPost {
+			nextID++
+			_, _ = fmt.Fprintf(w, `{"id":"u%d"}`, nextID)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for i := 1; i <= 2; i++ {
+		m := fmt.Sprintf("up:\n  request:\n    method: POST\n    url: %s/v%d\n  response:\n    result_code: [\"200\"]\n    env_from:\n      id%d: id\n"+
+			"down:\n  method: DELETE\n  url: %s/v%d/{{.env.id%d}}\n", srv.URL, i, i, srv.URL, i, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d_v%d.yaml", i, i)), []byte(m), 0o600); err != nil {
+			t.Fatalf("write m%d: %v", i, err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	mig := &Migrator{Dir: dir, Env: env.New(), Store: *st, DelayBetweenMigrations: time.Millisecond}
+	ctx := context.Background()
+	if _, err := mig.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	calls = nil
+	res, err := mig.Redo(ctx, 0)
+	if err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if len(res) != 2 || !reflect.DeepEqual(calls, []string{"DELETE /v2/u2", "POST /v2"}) {
+		t.Fatalf("unexpected redo of latest: results=%d calls=%v", len(res), calls)
+	}
+	if got, _ := st.LoadStoredEnv(2); got["id2"] != "u3" {
+		t.Fatalf("expected re-extracted id2=u3, got %v", got)
+	}
+
+	calls = nil
+	if _, err := mig.Redo(ctx, 1); err != nil {
+		t.Fatalf("Redo 1: %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"DELETE /v1/u1", "POST /v1"}) {
+		t.Fatalf("unexpected redo of version 1: %v", calls)
+	}
+	if applied, _ := st.ListApplied(); !reflect.DeepEqual(applied, []int{1, 2}) {
+		t.Fatalf("expected versions 1 and 2 applied, got %v", applied)
+	}
+}
+
+func TestRedo_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m1 := "up:\n  request:\n    method: GET\n    url: " + srv.URL + "/v1\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_v1.yaml"), []byte(m1), 0o600); err != nil {
+		t.Fatalf("write m1: %v", err)
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	mig := &Migrator{Dir: dir, Env: env.New(), Store: *st, DelayBetweenMigrations: time.Millisecond}
+	ctx := context.Background()
+
+	if _, err := mig.Redo(ctx, 0); err == nil || !strings.Contains(err.Error(), "no applied migration") {
+		t.Fatalf("expected no applied migration error, got %v", err)
+	}
+	if _, err := mig.MigrateUp(ctx, 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := mig.Redo(ctx, 2); err == nil || !strings.Contains(err.Error(), "not applied") {
+		t.Fatalf("expected not applied error, got %v", err)
+	}
+	if _, err := mig.Redo(ctx, 0); err == nil || !strings.Contains(err.Error(), "has no down section") {
+		t.Fatalf("expected missing down error, got %v", err)
+	}
+	if applied, _ := st.ListApplied(); len(applied) != 1 {
+		t.Fatalf("failed redo must leave version applied, got %v", applied)
+	}
+}
+
+func TestMigrateUpDown_DryRun_ReportsRenderedRequestsWithoutHTTP(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m1 := "up:\n  name: v1\n  request:\n    method: POST\n    url: " + srv.URL + "/users\n    body: '{\"name\":\"{{.env.name}}\"}'\n  response:\n    result_code: [\"201\"]\n    env_from:\n      user_id: id\n" +
+		"down:\n  name: v1-down\n  method: DELETE\n  url: " + srv.URL + "/users/{{.env.user_id}}\n"
+	m2 := "up:\n  name: v2\n  request:\n    method: PUT\n    url: " + srv.URL + "/users/{{.env.user_id}}\n    headers:\n      - { name: Authorization, value: \"Bearer {{.auth.svc}}\" }\n"
+	if err := os.WriteFile(filepath.Join(dir, "001_v1.yaml"), []byte(m1), 0o600); err != nil {
+		t.Fatalf("write m1: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "002_v2.yaml"), []byte(m2), 0o600); err != nil {
+		t.Fatalf("write m2: %v", err)
+	}
+
+	ctx := context.Background()
+	base := env.Env{Global: env.FromStringMap(map[string]string{"name": "alice"})}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+	mig := &Migrator{Dir: dir, Env: &base, Store: *st, DryRun: true,
+		Auth: []auth.Auth{{Type: "unregistered-dry-run", Name: "svc", Methods: auth.NewAuthSpecFromMap(map[string]interface{}{})}}}
+
+	res, err := mig.MigrateUp(ctx, 0)
+	if err != nil {
+		t.Fatalf("dry-run up: %v", err)
+	}
+	if len(res) != 2 || res[0].Result != nil || len(res[0].Requests) != 1 || len(res[1].Requests) != 1 {
+		t.Fatalf("unexpected dry-run results: %+v", res)
+	}
+	if got := res[0].Requests[0].Body; got != `{"name":"alice"}` {
+		t.Fatalf("unexpected rendered body: %s", got)
+	}
+	if got := res[1].Requests[0].URL; got != srv.URL+"/users/${user_id}" {
+		t.Fatalf("expected env_from placeholder in url, got %s", got)
+	}
+	if got := res[1].Requests[0].Headers["Authorization"]; got != "***MASKED***" {
+		t.Fatalf("expected masked Authorization header, got %q", got)
+	}
+
+	mig.DryRunFrom = 1
+	down, err := mig.MigrateDown(ctx, 0)
+	if err != nil {
+		t.Fatalf("dry-run down: %v", err)
+	}
+	if len(down) != 1 || len(down[0].Requests) != 1 || down[0].Requests[0].Method != "DELETE" {
+		t.Fatalf("unexpected dry-run down results: %+v", down)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no HTTP calls in dry-run, got %d", calls)
+	}
+	if cur, _ := st.CurrentVersion(); cur != 0 {
+		t.Fatalf("expected store untouched, got version %d", cur)
+	}
+}
+
 func TestMigrate_StoreOptions_ExplicitSQLitePath(t *testing.T) {
 	calls := 0
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -380,3 +692,120 @@ func TestOpenStore_SQLiteCustomPath(t *testing.T) {
 		t.Fatalf("expected custom sqlite db at %s: %v", customPath, err)
 	}
 }
+
+// Migrations and body_file references are read from Migrator.FS when it is set.
+func TestMigrateUpDown_FromFS(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.Method+" "+r.URL.Path+" "+string(b))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"42"}`))
+	}))
+	defer srv.Close()
+
+	fsys := fstest.MapFS{
+		"migrations/001_create.yaml": {Data: []byte("up:\n  request:\n    method: POST\n    url: " + srv.URL + "/items\n" +
+			"    body_file: migrations/bodies/item.json\n  response:\n    result_code: ['200']\n    env_from:\n      id: id\n" +
+			"down:\n  method: DELETE\n  url: " + srv.URL + "/items/{{.env.id}}\n")},
+		"migrations/bodies/item.json": {Data: []byte(`{"name":"{{.env.name}}"}`)},
+		"migrations/notes.txt":        {Data: []byte("ignored")},
+	}
+	st := openTestStore(t, filepath.Join(t.TempDir(), store.DbFileName))
+	defer func() { _ = st.Close() }()
+	base := env.New()
+	base.Global = env.FromStringMap(map[string]string{"name": "widget"})
+	m := &Migrator{Dir: "migrations", FS: fsys, Store: *st, Env: base, DelayBetweenMigrations: time.Millisecond}
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	want := []string{`POST /items {"name":"widget"}`, "DELETE /items/42 "}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Fatalf("unexpected requests: %q", bodies)
+	}
+}
+
+func TestMigrateUp_VersionPattern_TimestampOrder(t *testing.T) {
+	var order []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"V20240115__create.yaml", "V20231201__init.yaml", "V20240102__seed.yml", "001_ignored.yaml"} {
+		mig := fmt.Sprintf("up:\n  name: %[1]s\n  request:\n    method: POST\n    url: %[2]s/%[1]s\n  response:\n    result_code: [\"200\"]\n", name, srv.URL)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	st := openTestStore(t, filepath.Join(dir, store.DbFileName))
+	defer func() { _ = st.Close() }()
+
+	m := &Migrator{Dir: dir, Env: env.New(), Store: *st, VersionPattern: `^V(\d+)__.*\.ya?ml$`, DelayBetweenMigrations: time.Millisecond}
+	if _, err := m.MigrateUp(context.Background(), 20240102); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if want := []string{"V20231201__init.yaml", "V20240102__seed.yml"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	applied, err := st.ListApplied()
+	if err != nil {
+		t.Fatalf("ListApplied: %v", err)
+	}
+	if want := []int{20231201, 20240102, 20240115}; !reflect.DeepEqual(applied, want) {
+		t.Fatalf("applied = %v, want %v", applied, want)
+	}
+}
+
+func TestListMigrationFiles_DefaultPatternOrdersTimestampsNumerically(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20240115_create.yaml", "9_legacy.yaml", "20231201_init.yaml", "notes.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("up: {}\n"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	files, err := listMigrationFiles(nil, dir, "")
+	if err != nil {
+		t.Fatalf("listMigrationFiles: %v", err)
+	}
+	var got []int
+	for _, f := range files {
+		got = append(got, f.index)
+	}
+	if want := []int{9, 20231201, 20240115}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("versions = %v, want %v", got, want)
+	}
+}
+
+func TestCompileVersionPattern(t *testing.T) {
+	if _, err := CompileVersionPattern(`^V\d+__.*$`); err == nil || !strings.Contains(err.Error(), "capture group") {
+		t.Fatalf("expected an error for a pattern without a capture group, got %v", err)
+	}
+	if _, err := CompileVersionPattern(`^(\d+`); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+	re, err := CompileVersionPattern(`^([a-z]+)_.*\.yaml$`)
+	if err != nil {
+		t.Fatalf("CompileVersionPattern: %v", err)
+	}
+	if _, _, err := ParseVersion(re, "abc_x.yaml"); err == nil {
+		t.Fatalf("expected an error for a version that is not an integer")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "abc_x.yaml"), []byte("up: {}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	m := &Migrator{Dir: dir, VersionPattern: `^([a-z]+)_.*\.yaml$`}
+	if _, err := m.Plan(context.Background()); err == nil || !strings.Contains(err.Error(), "not an integer") {
+		t.Fatalf("expected Plan to report the bad version, got %v", err)
+	}
+}
@@ -42,6 +42,11 @@ func DefaultRetryConfig() *Config {
 	}
 }
 
+// NoRetryConfig returns a configuration that runs an operation once and returns its error as is.
+func NoRetryConfig() *Config {
+	return &Config{}
+}
+
 // isRetryableError checks if an error should trigger a retry
 func (rc *Config) isRetryableError(err error) bool {
 	if err == nil {
@@ -84,6 +89,9 @@ func WithRetry(ctx context.Context, config *Config, operation RetryableOperation
 	if config == nil {
 		config = DefaultRetryConfig()
 	}
+	if config.MaxRetries <= 0 {
+		return operation()
+	}
 
 	logger := common.GetLogger().WithComponent("store-retry")
 
@@ -359,6 +359,21 @@ func TestWithRetry_NilConfig(t *testing.T) {
 	}
 }
 
+func TestWithRetry_NoRetryConfig(t *testing.T) {
+	callCount := 0
+	opErr := errors.New("connection refused")
+	err := WithRetry(context.Background(), NoRetryConfig(), func() error {
+		callCount++
+		return opErr
+	})
+	if err != opErr {
+		t.Errorf("Expected the operation error as is, got %v", err)
+	}
+	if callCount != 1 {
+		t.Errorf("Expected operation to be called once, got %d", callCount)
+	}
+}
+
 // Mock sql.Result for testing
 type mockResult struct{}
 
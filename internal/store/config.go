@@ -1,7 +1,10 @@
 package store
 
 import (
+	"time"
+
 	"github.com/loykin/apirun/internal/store/connector"
+	"github.com/loykin/apirun/internal/store/mongo"
 	"github.com/loykin/apirun/internal/store/postgresql"
 	"github.com/loykin/apirun/internal/store/sqlite"
 )
@@ -10,14 +13,26 @@ import (
 const (
 	DriverSqlite     = "sqlite"
 	DriverPostgresql = "postgresql"
+	DriverMongo      = "mongo"
 )
 
 type Config struct {
 	Driver       string `mapstructure:"driver"`
 	TableNames   connector.TableNames
 	DriverConfig DriverConfig
+	// StoredEnv bounds the env stored per version (zero values use the defaults).
+	StoredEnv StoredEnvLimits
+	// Lock serializes concurrent runs against the store with a database lock when the backend
+	// has one (PostgreSQL: an advisory lock keyed by the schema_migrations table). nil enables it;
+	// SQLite needs none, its file lock already serializes writers.
+	Lock *bool
+	// LockTimeout bounds the wait for the lock (0 = DefaultLockTimeout).
+	LockTimeout time.Duration
 }
 
+// DefaultLockTimeout is the LockTimeout used when none is configured.
+const DefaultLockTimeout = connector.DefaultLockTimeout
+
 type DriverConfig interface {
 	ToMap() map[string]interface{}
 }
@@ -25,4 +40,20 @@ type DriverConfig interface {
 // Type aliases for external use - no need to import subpackages directly
 type SqliteConfig = sqlite.Config
 type PostgresConfig = postgresql.Config
+type MongoConfig = mongo.Config
 type TableNames = connector.TableNames
+
+// RunDetails is the request metadata recorded alongside a migration run.
+type RunDetails = connector.RunDetails
+
+// AppliedInfo is an applied version with the time it was applied.
+type AppliedInfo = connector.AppliedInfo
+
+// Run is a single migration_runs record.
+type Run = connector.Run
+
+// RunFilter narrows ListRunsFiltered results.
+type RunFilter = connector.RunFilter
+
+// StoredEnvLimits bounds the env stored per version and its insert batches.
+type StoredEnvLimits = connector.StoredEnvLimits
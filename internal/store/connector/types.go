@@ -1,6 +1,12 @@
 package connector
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
 
 // Run represents a single execution record from the migration_runs table.
 // Body may be nil when not saved; Env may be empty when not recorded.
@@ -13,6 +19,118 @@ type Run struct {
 	Env        map[string]string
 	Failed     bool
 	RanAt      string // RFC3339Nano for sqlite; Postgres converted to RFC3339Nano
+	// Request metadata; empty (nil for DurationMs) for runs recorded before it was tracked.
+	Method     string
+	URL        string
+	StartedAt  string // RFC3339Nano
+	DurationMs *int64
+	// Headers are the response headers saved with the run (SaveResponseHeaders), nil otherwise.
+	Headers map[string]string
+	// RunID is the id of the MigrateUp/MigrateDown call that recorded the run ("" before it was tracked).
+	RunID string
+}
+
+// RunDetails carries the request metadata recorded alongside a migration run.
+// Zero values are stored as NULL.
+type RunDetails struct {
+	Method     string
+	URL        string
+	StartedAt  time.Time
+	DurationMs int64
+	Headers    map[string]string
+	RunID      string
+}
+
+// RunFilter narrows the migration run history returned by ListRunsFiltered.
+// Zero values do not filter.
+type RunFilter struct {
+	Version    int    // only runs of this version (0 = any)
+	Direction  string // "up", "down", "baseline" or "skipped" ("" = any)
+	FailedOnly bool
+	Limit      int       // keep only the newest Limit matching runs (0 = all)
+	Since      time.Time // only runs recorded at or after Since (zero = any)
+}
+
+// RunPruner is implemented by backends that can delete old run records. It is optional, so
+// that custom drivers written before it keep working; Store.PruneRuns fails without it.
+type RunPruner interface {
+	// PruneRuns deletes the runs recorded before before (zero = any age), except the newest
+	// keepLastPerVersion runs of each version, and returns how many were deleted.
+	PruneRuns(th TableNames, before time.Time, keepLastPerVersion int) (int64, error)
+}
+
+// Transactor is implemented by backends that can make several writes atomically. It is optional
+// like RunPruner; without it Store.WithTx makes the writes one by one.
+type Transactor interface {
+	// WithTx calls fn with a Connector whose calls run in one transaction, committed when fn
+	// returns nil and rolled back otherwise.
+	WithTx(fn func(tx Connector) error) error
+}
+
+// DefaultLockTimeout is how long Locker.Lock waits for the run lock when no timeout is configured.
+const DefaultLockTimeout = 10 * time.Minute
+
+// Locker is implemented by backends that can serialize the migration runs of several processes.
+// It is optional like RunPruner; without it concurrent runs are not serialized by the store.
+type Locker interface {
+	// Lock waits at most timeout for the run lock of the tables th and returns the function
+	// releasing it.
+	Lock(th TableNames, timeout time.Duration) (unlock func() error, err error)
+}
+
+// Defaults of StoredEnvLimits.
+const (
+	DefaultMaxStoredEnvEntries = 100000
+	// DefaultStoredEnvBatchSize keeps a statement (3 parameters per row) under SQLite's
+	// historic limit of 999 bound parameters.
+	DefaultStoredEnvBatchSize = 300
+)
+
+// StoredEnvLimits bounds how InsertStoredEnv writes the env of one version. Zero (or negative)
+// fields use the defaults.
+type StoredEnvLimits struct {
+	// MaxEntries caps the entries stored for one version; larger maps fail instead of being
+	// partly stored. It guards the store against runaway extractions.
+	MaxEntries int
+	// BatchSize is the number of rows per INSERT statement. All batches of a version are
+	// written in one transaction.
+	BatchSize int
+}
+
+// StoredEnvLimiter is implemented by backends whose InsertStoredEnv honours StoredEnvLimits. It
+// is optional like RunPruner; Store.Connect passes Config.StoredEnv to backends that have it.
+type StoredEnvLimiter interface {
+	SetStoredEnvLimits(l StoredEnvLimits)
+}
+
+// Batches checks kv against MaxEntries and returns its names, sorted, split into batches of
+// BatchSize.
+func (l StoredEnvLimits) Batches(kv map[string]string) ([][]string, error) {
+	maxEntries, size := l.MaxEntries, l.BatchSize
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxStoredEnvEntries
+	}
+	if size <= 0 {
+		size = DefaultStoredEnvBatchSize
+	}
+	if len(kv) > maxEntries {
+		return nil, fmt.Errorf("cannot store %d environment variables for one version (limit: %d)", len(kv), maxEntries)
+	}
+	names := slices.Sorted(maps.Keys(kv))
+	var batches [][]string
+	for len(names) > 0 {
+		n := min(size, len(names))
+		batches = append(batches, names[:n])
+		names = names[n:]
+	}
+	return batches, nil
+}
+
+// AppliedInfo is an applied migration version and when it was applied.
+// AppliedAt is the zero time for versions applied before it was recorded.
+type AppliedInfo struct {
+	Version   int
+	AppliedAt time.Time
 }
 
 // TableNames represents database table names
@@ -31,14 +149,22 @@ type Connector interface {
 	IsApplied(th TableNames, v int) (bool, error)
 	CurrentVersion(th TableNames) (int, error)
 	ListApplied(th TableNames) ([]int, error)
+	// ListAppliedWithTime returns the applied versions with their applied_at time, ordered by version
+	ListAppliedWithTime(th TableNames) ([]AppliedInfo, error)
 	Remove(th TableNames, v int) error
+	// SetChecksum records the migration file checksum of an applied version
+	SetChecksum(th TableNames, v int, checksum string) error
+	// ListChecksums returns the recorded checksums by version (versions without one are omitted)
+	ListChecksums(th TableNames) (map[int]string, error)
 	SetVersion(th TableNames, target int) error
-	RecordRun(th TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool) error
+	RecordRun(th TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, details RunDetails) error
 	LoadEnv(th TableNames, version int, direction string) (map[string]string, error)
 	InsertStoredEnv(th TableNames, version int, kv map[string]string) error
 	LoadStoredEnv(th TableNames, version int) (map[string]string, error)
 	DeleteStoredEnv(th TableNames, version int) error
 	// ListRuns returns migration run history ordered by id ASC
 	ListRuns(th TableNames) ([]Run, error)
+	// ListRunsFiltered returns the runs matching f, filtered in SQL and ordered by id ASC
+	ListRunsFiltered(th TableNames, f RunFilter) ([]Run, error)
 	Close() error
 }
@@ -0,0 +1,269 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/loykin/apirun/internal/store/connector"
+)
+
+// DumpFormatVersion is the version of the document written by Export. Import rejects
+// documents of other versions.
+const DumpFormatVersion = 1
+
+// Dump is the JSON document written by Export: the contents of the three store tables.
+// Nullable columns keep their NULLs: a nil Body is written as null, not "". Empty Env and
+// Headers maps are stored as NULL, as RecordRun does.
+type Dump struct {
+	FormatVersion int             `json:"format_version"`
+	ExportedAt    time.Time       `json:"exported_at"`
+	Driver        string          `json:"driver"`
+	Applied       []DumpApplied   `json:"schema_migrations"`
+	Runs          []DumpRun       `json:"migration_runs"`
+	StoredEnv     []DumpStoredEnv `json:"stored_env"`
+}
+
+// DumpApplied is a schema_migrations row. AppliedAt is empty when it was not recorded.
+type DumpApplied struct {
+	Version   int     `json:"version"`
+	AppliedAt string  `json:"applied_at,omitempty"`
+	Checksum  *string `json:"checksum"`
+}
+
+// DumpRun is a migration_runs row. IDs are not kept: Import renumbers the runs in order.
+type DumpRun struct {
+	Version    int               `json:"version"`
+	Direction  string            `json:"direction"`
+	StatusCode int               `json:"status_code"`
+	Body       *string           `json:"body"`
+	Env        map[string]string `json:"env"`
+	Failed     bool              `json:"failed"`
+	RanAt      string            `json:"ran_at"`
+	Method     string            `json:"method,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	StartedAt  string            `json:"started_at,omitempty"`
+	DurationMs *int64            `json:"duration_ms"`
+	Headers    map[string]string `json:"headers"`
+	RunID      string            `json:"run_id,omitempty"`
+}
+
+// DumpStoredEnv is a stored_env row.
+type DumpStoredEnv struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+}
+
+// Export writes the applied versions, the run history and the stored env as a versioned JSON
+// document, e.g. to back up the store or move it to another backend with Import.
+func (s *Store) Export(w io.Writer) error {
+	d, err := s.dump()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+func (s *Store) dump() (*Dump, error) {
+	d := &Dump{FormatVersion: DumpFormatVersion, ExportedAt: time.Now().UTC(), Driver: s.Driver,
+		Applied: []DumpApplied{}, Runs: []DumpRun{}, StoredEnv: []DumpStoredEnv{}}
+	applied, err := s.ListAppliedWithTime()
+	if err != nil {
+		return nil, fmt.Errorf("export applied versions: %w", err)
+	}
+	checksums, err := s.ListChecksums()
+	if err != nil {
+		return nil, fmt.Errorf("export checksums: %w", err)
+	}
+	for _, a := range applied {
+		row := DumpApplied{Version: a.Version}
+		if !a.AppliedAt.IsZero() {
+			row.AppliedAt = a.AppliedAt.UTC().Format(time.RFC3339Nano)
+		}
+		if c, ok := checksums[a.Version]; ok {
+			row.Checksum = &c
+		}
+		d.Applied = append(d.Applied, row)
+	}
+	runs, err := s.ListRuns()
+	if err != nil {
+		return nil, fmt.Errorf("export runs: %w", err)
+	}
+	for _, r := range runs {
+		d.Runs = append(d.Runs, DumpRun{Version: r.Version, Direction: r.Direction, StatusCode: r.StatusCode,
+			Body: r.Body, Env: r.Env, Failed: r.Failed, RanAt: r.RanAt, Method: r.Method, URL: r.URL,
+			StartedAt: r.StartedAt, DurationMs: r.DurationMs, Headers: r.Headers, RunID: r.RunID})
+	}
+	if s.DB == nil {
+		// Not database/sql based (e.g. mongo): stored env is kept for applied versions only
+		for _, a := range applied {
+			kv, err := s.LoadStoredEnv(a.Version)
+			if err != nil {
+				return nil, fmt.Errorf("export stored env: %w", err)
+			}
+			for _, name := range slices.Sorted(maps.Keys(kv)) {
+				d.StoredEnv = append(d.StoredEnv, DumpStoredEnv{Version: a.Version, Name: name, Value: kv[name]})
+			}
+		}
+		return d, nil
+	}
+	// stored_env has no connector method listing all of it; the query is portable
+	rows, err := s.DB.Query(fmt.Sprintf("SELECT version, name, value FROM %s ORDER BY version, name", s.safeTableNames().StoredEnv))
+	if err != nil {
+		return nil, fmt.Errorf("export stored env: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var e DumpStoredEnv
+		if err := rows.Scan(&e.Version, &e.Name, &e.Value); err != nil {
+			return nil, fmt.Errorf("export stored env: %w", err)
+		}
+		d.StoredEnv = append(d.StoredEnv, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("export stored env: %w", err)
+	}
+	return d, nil
+}
+
+// Import loads a document written by Export into this store, which must be empty. Rows are
+// written in one transaction, so a failed import leaves the store empty. Only the built-in
+// sqlite and postgresql drivers are supported.
+func (s *Store) Import(r io.Reader) error {
+	if s.Driver != DriverSqlite && s.Driver != DriverPostgresql {
+		return fmt.Errorf("import is not supported by store driver %q", s.Driver)
+	}
+	var d Dump
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return fmt.Errorf("import: invalid document: %w", err)
+	}
+	if d.FormatVersion != DumpFormatVersion {
+		return fmt.Errorf("import: unsupported format_version %d (want %d)", d.FormatVersion, DumpFormatVersion)
+	}
+	if err := s.checkEmpty(); err != nil {
+		return err
+	}
+
+	tn := s.safeTableNames()
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, a := range d.Applied {
+		appliedAt, err := s.importTime(a.AppliedAt)
+		if err != nil {
+			return fmt.Errorf("import version %d: %w", a.Version, err)
+		}
+		q := s.conv(fmt.Sprintf("INSERT INTO %s(version, applied_at, checksum) VALUES(?, ?, ?)", tn.SchemaMigrations))
+		if _, err := tx.Exec(q, a.Version, appliedAt, a.Checksum); err != nil {
+			return fmt.Errorf("import version %d: %w", a.Version, err)
+		}
+	}
+	for i, run := range d.Runs {
+		if err := s.importRun(tx, tn, run); err != nil {
+			return fmt.Errorf("import run %d (version %d): %w", i+1, run.Version, err)
+		}
+	}
+	for _, e := range d.StoredEnv {
+		q := s.conv(fmt.Sprintf("INSERT INTO %s(version, name, value) VALUES(?, ?, ?)", tn.StoredEnv))
+		if _, err := tx.Exec(q, e.Version, e.Name, e.Value); err != nil {
+			return fmt.Errorf("import stored env %s of version %d: %w", e.Name, e.Version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) importRun(tx *sql.Tx, tn connector.TableNames, run DumpRun) error {
+	ranAt, err := s.importTime(run.RanAt)
+	if err != nil {
+		return err
+	}
+	if ranAt == nil {
+		return fmt.Errorf("ran_at is required")
+	}
+	startedAt, err := s.importTime(run.StartedAt)
+	if err != nil {
+		return err
+	}
+	envJSON, err := nullableJSON(run.Env)
+	if err != nil {
+		return err
+	}
+	headersJSON, err := nullableJSON(run.Headers)
+	if err != nil {
+		return err
+	}
+	var failed interface{} = run.Failed
+	if s.Driver == DriverSqlite {
+		failed = 0
+		if run.Failed {
+			failed = 1
+		}
+	}
+	q := s.conv(fmt.Sprintf("INSERT INTO %s(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?)", tn.MigrationRuns))
+	_, err = tx.Exec(q, run.Version, run.Direction, run.StatusCode, run.Body, envJSON, failed, ranAt,
+		nullIfEmpty(run.Method), nullIfEmpty(run.URL), startedAt, run.DurationMs, headersJSON, nullIfEmpty(run.RunID))
+	return err
+}
+
+// checkEmpty fails when the store already holds applied versions, runs or stored env.
+func (s *Store) checkEmpty() error {
+	tn := s.safeTableNames()
+	for _, table := range []string{tn.SchemaMigrations, tn.MigrationRuns, tn.StoredEnv} {
+		var n int
+		if err := s.DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&n); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		if n > 0 {
+			return fmt.Errorf("import: the store is not empty (table %s has %d rows); import into a fresh store", table, n)
+		}
+	}
+	return nil
+}
+
+// importTime converts an exported RFC3339 timestamp to the column format of the driver;
+// an empty value is NULL. SQLite keeps the text as exported.
+func (s *Store) importTime(v string) (interface{}, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q: %w", v, err)
+	}
+	if s.Driver == DriverPostgresql {
+		return t, nil
+	}
+	return v, nil
+}
+
+// nullableJSON encodes m, keeping an empty map NULL.
+func nullableJSON(m map[string]string) (*string, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	out := string(b)
+	return &out, nil
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
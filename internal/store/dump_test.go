@@ -0,0 +1,106 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	src := openTempStore(t)
+	for _, v := range []int{1, 2} {
+		if err := src.Apply(v); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	if err := src.SetChecksum(1, "abc"); err != nil {
+		t.Fatalf("SetChecksum: %v", err)
+	}
+	empty, body := "", `{"id":7}`
+	if err := src.RecordRunWithDetails(1, "up", 201, &body, map[string]string{"id": "7"}, false, RunDetails{
+		Method: "POST", URL: "http://x/items", StartedAt: time.Now(), DurationMs: 12, Headers: map[string]string{"Location": "/items/7"},
+	}); err != nil {
+		t.Fatalf("RecordRun: %v", err)
+	}
+	if err := src.RecordRun(2, "up", 500, &empty, nil, true); err != nil {
+		t.Fatalf("RecordRun: %v", err)
+	}
+	if err := src.RecordRun(2, "baseline", 0, nil, nil, false); err != nil {
+		t.Fatalf("RecordRun: %v", err)
+	}
+	if err := src.InsertStoredEnv(1, map[string]string{"id": "7", "up_body": body}); err != nil {
+		t.Fatalf("InsertStoredEnv: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || doc["format_version"] != float64(DumpFormatVersion) {
+		t.Fatalf("unexpected document: %v %s", err, buf.String())
+	}
+
+	// Import into another sqlite store with custom table names
+	dst := &Store{}
+	dst.SetTableNames("sm_copy", "runs_copy", "env_copy")
+	if err := dst.Connect(Config{Driver: DriverSqlite, DriverConfig: &SqliteConfig{Path: filepath.Join(t.TempDir(), DbFileName)}}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer func() { _ = dst.Close() }()
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	srcRuns, _ := src.ListRuns()
+	dstRuns, err := dst.ListRuns()
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if !reflect.DeepEqual(srcRuns, dstRuns) {
+		t.Fatalf("runs differ:\n src %+v\n dst %+v", srcRuns, dstRuns)
+	}
+	if dstRuns[1].Body == nil || *dstRuns[1].Body != "" || dstRuns[2].Body != nil {
+		t.Fatalf("body nullability not kept: %+v", dstRuns)
+	}
+	srcApplied, _ := src.ListAppliedWithTime()
+	dstApplied, _ := dst.ListAppliedWithTime()
+	if !reflect.DeepEqual(srcApplied, dstApplied) {
+		t.Fatalf("applied differ: %v vs %v", srcApplied, dstApplied)
+	}
+	if sums, _ := dst.ListChecksums(); !reflect.DeepEqual(sums, map[int]string{1: "abc"}) {
+		t.Fatalf("checksums = %v", sums)
+	}
+	if env, _ := dst.LoadStoredEnv(1); env["id"] != "7" || env["up_body"] != body {
+		t.Fatalf("stored env = %v", env)
+	}
+
+	// A second import would duplicate the history
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("expected a non-empty store error, got %v", err)
+	}
+}
+
+func TestImport_RejectsInvalidDocuments(t *testing.T) {
+	cases := map[string]string{
+		"not json":       "nope",
+		"format version": `{"format_version": 99}`,
+		"bad timestamp":  `{"format_version": 1, "migration_runs": [{"version": 1, "direction": "up", "ran_at": "yesterday"}]}`,
+	}
+	for name, doc := range cases {
+		t.Run(name, func(t *testing.T) {
+			st := openTempStore(t)
+			if err := st.Import(strings.NewReader(doc)); err == nil {
+				t.Fatalf("expected an error")
+			}
+			// Nothing is written by a failed import
+			if runs, _ := st.ListRuns(); len(runs) != 0 {
+				t.Fatalf("runs = %v", runs)
+			}
+		})
+	}
+}
@@ -0,0 +1,499 @@
+package mongo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loykin/apirun/internal/common"
+	"github.com/loykin/apirun/internal/store/connector"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	driver "go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Store keeps the migration state in MongoDB. Each table of the SQL backends is a collection of
+// the same name whose documents have the table's columns as fields; the run ids are taken from a
+// counter document in the "<migration_runs>_seq" collection. MongoDB is not database/sql based,
+// so Connect returns a nil *sql.DB.
+//
+// Store does not implement connector.Transactor: transactions need a replica set, so the writes
+// of one version are made one by one, as with a custom driver.
+type Store struct {
+	URI       string
+	Database  string
+	client    *driver.Client
+	db        *driver.Database
+	storedEnv connector.StoredEnvLimits
+}
+
+// appliedDoc is a schema_migrations document.
+type appliedDoc struct {
+	Version   int       `bson:"version"`
+	AppliedAt time.Time `bson:"applied_at"`
+	Checksum  *string   `bson:"checksum,omitempty"`
+}
+
+// runDoc is a migration_runs document. Env and headers are kept as JSON text like the SQL
+// env_json and headers_json columns, since their keys may hold '.' or '$'.
+type runDoc struct {
+	ID          int        `bson:"id"`
+	Version     int        `bson:"version"`
+	Direction   string     `bson:"direction"`
+	StatusCode  int        `bson:"status_code"`
+	Body        *string    `bson:"body"`
+	EnvJSON     *string    `bson:"env_json"`
+	Failed      bool       `bson:"failed"`
+	RanAt       time.Time  `bson:"ran_at"`
+	Method      string     `bson:"method,omitempty"`
+	URL         string     `bson:"url,omitempty"`
+	StartedAt   *time.Time `bson:"started_at,omitempty"`
+	DurationMs  *int64     `bson:"duration_ms,omitempty"`
+	HeadersJSON *string    `bson:"headers_json,omitempty"`
+	RunID       string     `bson:"run_id,omitempty"`
+}
+
+// storedEnvDoc is a stored_env document.
+type storedEnvDoc struct {
+	Version int    `bson:"version"`
+	Name    string `bson:"name"`
+	Value   string `bson:"value"`
+}
+
+// NewStore creates a new MongoDB store
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Load loads configuration into the MongoDB store
+func (m *Store) Load(config map[string]interface{}) error {
+	if uri, ok := config["uri"].(string); ok {
+		m.URI = strings.TrimSpace(uri)
+	}
+	if db, ok := config["database"].(string); ok {
+		m.Database = strings.TrimSpace(db)
+	}
+	return nil
+}
+
+// Validate checks that a connection URI is configured
+func (m *Store) Validate() error {
+	if m.URI == "" {
+		return fmt.Errorf("mongo store: uri is required")
+	}
+	return nil
+}
+
+// Connect opens the client and pings the server. It returns a nil *sql.DB.
+func (m *Store) Connect() (*sql.DB, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := driver.Connect(options.Client().ApplyURI(m.URI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(context.Background(), nil); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	name := m.Database
+	if name == "" {
+		name = DefaultDatabase
+	}
+	m.client = client
+	m.db = client.Database(name)
+
+	logger := common.GetLogger().WithStore("mongo")
+	logger.Info("MongoDB connection established successfully", "database", name)
+	return nil, nil
+}
+
+// Close disconnects the client
+func (m *Store) Close() error {
+	if m.client != nil {
+		return m.client.Disconnect(context.Background())
+	}
+	return nil
+}
+
+// Ensure creates the indexes of the collections; MongoDB creates the collections themselves on
+// first write. The unique indexes back the (version) and (version, name) keys of the SQL schema.
+func (m *Store) Ensure(th connector.TableNames) error {
+	ctx := context.Background()
+	indexes := []struct {
+		collection string
+		models     []driver.IndexModel
+	}{
+		{th.SchemaMigrations, []driver.IndexModel{
+			{Keys: bson.D{{Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		}},
+		{th.MigrationRuns, []driver.IndexModel{
+			{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "version", Value: 1}, {Key: "id", Value: 1}}},
+		}},
+		{th.StoredEnv, []driver.IndexModel{
+			{Keys: bson.D{{Key: "version", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		}},
+	}
+	for _, ix := range indexes {
+		for _, model := range ix.models {
+			if _, err := m.db.Collection(ix.collection).Indexes().CreateOne(ctx, model); err != nil {
+				return fmt.Errorf("failed to create an index of MongoDB collection %s: %w", ix.collection, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply records a version as applied, keeping the applied_at of an already applied version
+func (m *Store) Apply(th connector.TableNames, v int) error {
+	_, err := m.db.Collection(th.SchemaMigrations).UpdateOne(context.Background(),
+		bson.M{"version": v},
+		bson.M{"$setOnInsert": bson.M{"applied_at": time.Now().UTC()}},
+		options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to apply migration version %d: %w", v, err)
+	}
+	return nil
+}
+
+// IsApplied checks if a specific migration version has been applied
+func (m *Store) IsApplied(th connector.TableNames, v int) (bool, error) {
+	n, err := m.db.Collection(th.SchemaMigrations).CountDocuments(context.Background(), bson.M{"version": v})
+	if err != nil {
+		return false, fmt.Errorf("failed to check if migration version %d is applied: %w", v, err)
+	}
+	return n > 0, nil
+}
+
+// CurrentVersion returns the highest applied version, 0 when none is applied
+func (m *Store) CurrentVersion(th connector.TableNames) (int, error) {
+	var doc appliedDoc
+	err := m.db.Collection(th.SchemaMigrations).FindOne(context.Background(), bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})).Decode(&doc)
+	if errors.Is(err, driver.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current migration version: %w", err)
+	}
+	return doc.Version, nil
+}
+
+// ListApplied returns the applied versions in ascending order
+func (m *Store) ListApplied(th connector.TableNames) ([]int, error) {
+	applied, err := m.ListAppliedWithTime(th)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, len(applied))
+	for i, a := range applied {
+		versions[i] = a.Version
+	}
+	return versions, nil
+}
+
+// ListAppliedWithTime returns the applied versions with the time each was applied, ascending
+func (m *Store) ListAppliedWithTime(th connector.TableNames) ([]connector.AppliedInfo, error) {
+	docs, err := m.findApplied(th, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	out := make([]connector.AppliedInfo, len(docs))
+	for i, d := range docs {
+		out[i] = connector.AppliedInfo{Version: d.Version, AppliedAt: d.AppliedAt}
+	}
+	return out, nil
+}
+
+func (m *Store) findApplied(th connector.TableNames, filter bson.M) ([]appliedDoc, error) {
+	ctx := context.Background()
+	cur, err := m.db.Collection(th.SchemaMigrations).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []appliedDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Remove removes a migration version
+func (m *Store) Remove(th connector.TableNames, v int) error {
+	if _, err := m.db.Collection(th.SchemaMigrations).DeleteOne(context.Background(), bson.M{"version": v}); err != nil {
+		return fmt.Errorf("failed to remove migration version %d: %w", v, err)
+	}
+	return nil
+}
+
+// SetChecksum records the migration file checksum of an applied version
+func (m *Store) SetChecksum(th connector.TableNames, v int, checksum string) error {
+	_, err := m.db.Collection(th.SchemaMigrations).UpdateOne(context.Background(),
+		bson.M{"version": v}, bson.M{"$set": bson.M{"checksum": checksum}})
+	if err != nil {
+		return fmt.Errorf("failed to set checksum of migration version %d: %w", v, err)
+	}
+	return nil
+}
+
+// ListChecksums returns the recorded checksums by version
+func (m *Store) ListChecksums(th connector.TableNames) (map[int]string, error) {
+	docs, err := m.findApplied(th, bson.M{"checksum": bson.M{"$type": "string"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration checksums: %w", err)
+	}
+	out := make(map[int]string, len(docs))
+	for _, d := range docs {
+		out[d.Version] = *d.Checksum
+	}
+	return out, nil
+}
+
+// SetVersion sets the schema to a specific version by removing all versions above the target
+func (m *Store) SetVersion(th connector.TableNames, target int) error {
+	current, err := m.CurrentVersion(th)
+	if err != nil {
+		return err
+	}
+	if target > current {
+		return fmt.Errorf("target version %d is greater than current version %d", target, current)
+	}
+	if target == current {
+		return nil
+	}
+	_, err = m.db.Collection(th.SchemaMigrations).DeleteMany(context.Background(), bson.M{"version": bson.M{"$gt": target}})
+	if err != nil {
+		return fmt.Errorf("failed to set version to %d: %w", target, err)
+	}
+	return nil
+}
+
+// nextRunID increments and returns the run id counter of th.MigrationRuns.
+func (m *Store) nextRunID(th connector.TableNames) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := m.db.Collection(th.MigrationRuns+"_seq").FindOneAndUpdate(context.Background(),
+		bson.M{"_id": "id"}, bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// RecordRun appends a migration run to the runs collection
+func (m *Store) RecordRun(th connector.TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, details connector.RunDetails) error {
+	doc := runDoc{Version: version, Direction: direction, StatusCode: status, Body: body, Failed: failed,
+		RanAt: time.Now().UTC(), Method: details.Method, URL: details.URL, RunID: details.RunID}
+	if len(env) > 0 {
+		b, err := json.Marshal(env)
+		if err != nil {
+			return fmt.Errorf("failed to marshal environment for MongoDB migration run record (version %d, direction %s): %w", version, direction, err)
+		}
+		s := string(b)
+		doc.EnvJSON = &s
+	}
+	if !details.StartedAt.IsZero() {
+		startedAt, durationMs := details.StartedAt.UTC(), details.DurationMs
+		doc.StartedAt, doc.DurationMs = &startedAt, &durationMs
+	}
+	if len(details.Headers) > 0 {
+		b, err := json.Marshal(details.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal response headers for MongoDB migration run record (version %d, direction %s): %w", version, direction, err)
+		}
+		h := string(b)
+		doc.HeadersJSON = &h
+	}
+	id, err := m.nextRunID(th)
+	if err == nil {
+		doc.ID = id
+		_, err = m.db.Collection(th.MigrationRuns).InsertOne(context.Background(), doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record MongoDB migration run (version %d, direction %s, status %d): %w", version, direction, status, err)
+	}
+	return nil
+}
+
+// LoadEnv loads the env of the latest run of a version and direction
+func (m *Store) LoadEnv(th connector.TableNames, version int, direction string) (map[string]string, error) {
+	var doc runDoc
+	err := m.db.Collection(th.MigrationRuns).FindOne(context.Background(),
+		bson.M{"version": version, "direction": direction},
+		options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}})).Decode(&doc)
+	if errors.Is(err, driver.ErrNoDocuments) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load env for version %d direction %s: %w", version, direction, err)
+	}
+	env := map[string]string{}
+	if doc.EnvJSON != nil && *doc.EnvJSON != "" {
+		_ = json.Unmarshal([]byte(*doc.EnvJSON), &env)
+	}
+	return env, nil
+}
+
+// SetStoredEnvLimits sets the cap and batch size used by InsertStoredEnv.
+func (m *Store) SetStoredEnvLimits(l connector.StoredEnvLimits) {
+	m.storedEnv = l
+}
+
+// InsertStoredEnv upserts the stored env of a version by (version, name), one bulk write per
+// batch. Without a transaction a failing batch leaves the earlier batches stored.
+func (m *Store) InsertStoredEnv(th connector.TableNames, version int, kv map[string]string) error {
+	if len(kv) == 0 {
+		return nil
+	}
+	batches, err := m.storedEnv.Batches(kv)
+	if err != nil {
+		return err
+	}
+	coll := m.db.Collection(th.StoredEnv)
+	for _, names := range batches {
+		models := make([]driver.WriteModel, 0, len(names))
+		for _, name := range names {
+			models = append(models, driver.NewUpdateOneModel().
+				SetFilter(bson.M{"version": version, "name": name}).
+				SetUpdate(bson.M{"$set": bson.M{"value": kv[name]}}).
+				SetUpsert(true))
+		}
+		if _, err := coll.BulkWrite(context.Background(), models); err != nil {
+			return fmt.Errorf("failed to insert stored environment for MongoDB version %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// LoadStoredEnv loads stored environment variables for a specific version
+func (m *Store) LoadStoredEnv(th connector.TableNames, version int) (map[string]string, error) {
+	ctx := context.Background()
+	cur, err := m.db.Collection(th.StoredEnv).Find(ctx, bson.M{"version": version})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored env for version %d: %w", version, err)
+	}
+	var docs []storedEnvDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to load stored env for version %d: %w", version, err)
+	}
+	env := make(map[string]string, len(docs))
+	for _, d := range docs {
+		env[d.Name] = d.Value
+	}
+	return env, nil
+}
+
+// DeleteStoredEnv deletes stored environment variables for a specific version
+func (m *Store) DeleteStoredEnv(th connector.TableNames, version int) error {
+	if _, err := m.db.Collection(th.StoredEnv).DeleteMany(context.Background(), bson.M{"version": version}); err != nil {
+		return fmt.Errorf("failed to delete stored env for version %d: %w", version, err)
+	}
+	return nil
+}
+
+// PruneRuns deletes the runs recorded before before (zero = any age), except the newest
+// keepLastPerVersion runs of each version.
+func (m *Store) PruneRuns(th connector.TableNames, before time.Time, keepLastPerVersion int) (int64, error) {
+	ctx := context.Background()
+	coll := m.db.Collection(th.MigrationRuns)
+	cur, err := coll.Find(ctx, bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "version", Value: 1}, {Key: "id", Value: -1}}).
+		SetProjection(bson.M{"version": 1, "id": 1, "ran_at": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune MongoDB migration runs: %w", err)
+	}
+	var docs []runDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return 0, fmt.Errorf("failed to prune MongoDB migration runs: %w", err)
+	}
+	var ids []int
+	rank := 0
+	for i, d := range docs {
+		if i == 0 || docs[i-1].Version != d.Version {
+			rank = 0
+		}
+		rank++
+		if rank > keepLastPerVersion && (before.IsZero() || d.RanAt.Before(before)) {
+			ids = append(ids, d.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res, err := coll.DeleteMany(ctx, bson.M{"id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune MongoDB migration runs: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+// ListRuns returns the migration run history ordered by id ASC
+func (m *Store) ListRuns(th connector.TableNames) ([]connector.Run, error) {
+	return m.ListRunsFiltered(th, connector.RunFilter{})
+}
+
+// ListRunsFiltered returns the runs matching f ordered by id ASC; with f.Limit only the newest
+// matching runs are returned.
+func (m *Store) ListRunsFiltered(th connector.TableNames, f connector.RunFilter) ([]connector.Run, error) {
+	filter := bson.M{}
+	if f.Version > 0 {
+		filter["version"] = f.Version
+	}
+	if f.Direction != "" {
+		filter["direction"] = f.Direction
+	}
+	if f.FailedOnly {
+		filter["failed"] = true
+	}
+	if !f.Since.IsZero() {
+		filter["ran_at"] = bson.M{"$gte": f.Since.UTC()}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}})
+	if f.Limit > 0 {
+		opts = options.Find().SetSort(bson.D{{Key: "id", Value: -1}}).SetLimit(int64(f.Limit))
+	}
+
+	ctx := context.Background()
+	cur, err := m.db.Collection(th.MigrationRuns).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MongoDB migration runs: %w", err)
+	}
+	var docs []runDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to list MongoDB migration runs: %w", err)
+	}
+
+	runs := make([]connector.Run, 0, len(docs))
+	for _, d := range docs {
+		run := connector.Run{ID: d.ID, Version: d.Version, Direction: d.Direction, StatusCode: d.StatusCode,
+			Body: d.Body, Failed: d.Failed, RanAt: d.RanAt.UTC().Format(time.RFC3339Nano), Method: d.Method,
+			URL: d.URL, DurationMs: d.DurationMs, RunID: d.RunID}
+		if d.EnvJSON != nil && *d.EnvJSON != "" {
+			_ = json.Unmarshal([]byte(*d.EnvJSON), &run.Env)
+		}
+		if run.Env == nil {
+			run.Env = make(map[string]string)
+		}
+		if d.StartedAt != nil {
+			run.StartedAt = d.StartedAt.UTC().Format(time.RFC3339Nano)
+		}
+		if d.HeadersJSON != nil && *d.HeadersJSON != "" {
+			_ = json.Unmarshal([]byte(*d.HeadersJSON), &run.Headers)
+		}
+		runs = append(runs, run)
+	}
+	if f.Limit > 0 {
+		slices.Reverse(runs)
+	}
+	return runs, nil
+}
@@ -0,0 +1,229 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun/internal/store/connector"
+	tc "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var testTables = connector.TableNames{SchemaMigrations: "schema_migrations", MigrationRuns: "migration_runs", StoredEnv: "stored_env"}
+
+// startMongo runs a MongoDB container for the test and returns a connected store; the test is
+// skipped where containers cannot run.
+func startMongo(t *testing.T) *Store {
+	t.Helper()
+	ctx := context.Background()
+	startCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+
+	req := tc.ContainerRequest{
+		Image:        "mongo:7",
+		ExposedPorts: []string{"27017/tcp"},
+		WaitingFor:   wait.ForListeningPort("27017/tcp"),
+	}
+	c, err := tc.GenericContainer(startCtx, tc.GenericContainerRequest{ContainerRequest: req, Started: true})
+	if err != nil {
+		t.Skipf("skipping MongoDB container test: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Terminate(ctx) })
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		t.Fatalf("container host: %v", err)
+	}
+	port, err := c.MappedPort(ctx, "27017/tcp")
+	if err != nil {
+		t.Fatalf("container port: %v", err)
+	}
+	s := NewStore()
+	cfg := &Config{URI: fmt.Sprintf("mongodb://%s:%s", host, port.Port()), Database: "apirun_test"}
+	if err := s.Load(cfg.ToMap()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	db, err := s.Connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if db != nil {
+		t.Fatalf("expected a nil *sql.DB, got %v", db)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.Ensure(testTables); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	// Ensure is called on every connect
+	if err := s.Ensure(testTables); err != nil {
+		t.Fatalf("second ensure: %v", err)
+	}
+	return s
+}
+
+func TestConfig_ToMapAndLoad(t *testing.T) {
+	cfg := &Config{URI: " mongodb://db:27017 ", Database: " app "}
+	s := NewStore()
+	if err := s.Load(cfg.ToMap()); err != nil {
+		t.Fatal(err)
+	}
+	if s.URI != "mongodb://db:27017" || s.Database != "app" {
+		t.Fatalf("unexpected store config: uri=%q database=%q", s.URI, s.Database)
+	}
+}
+
+func TestConnect_RequiresURI(t *testing.T) {
+	s := NewStore()
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "uri is required") {
+		t.Fatalf("expected a missing uri error, got %v", err)
+	}
+	if _, err := s.Connect(); err == nil || !strings.Contains(err.Error(), "uri is required") {
+		t.Fatalf("expected connect to fail without uri, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close of an unconnected store: %v", err)
+	}
+}
+
+func TestMongo_Versions(t *testing.T) {
+	s := startMongo(t)
+	if v, err := s.CurrentVersion(testTables); err != nil || v != 0 {
+		t.Fatalf("empty store: version=%d err=%v", v, err)
+	}
+	for _, v := range []int{1, 3, 2, 3} {
+		if err := s.Apply(testTables, v); err != nil {
+			t.Fatalf("apply %d: %v", v, err)
+		}
+	}
+	applied, err := s.ListApplied(testTables)
+	if err != nil || fmt.Sprint(applied) != "[1 2 3]" {
+		t.Fatalf("applied=%v err=%v", applied, err)
+	}
+	if v, _ := s.CurrentVersion(testTables); v != 3 {
+		t.Fatalf("current version = %d, want 3", v)
+	}
+	if ok, _ := s.IsApplied(testTables, 2); !ok {
+		t.Fatal("version 2 should be applied")
+	}
+	withTime, err := s.ListAppliedWithTime(testTables)
+	if err != nil || len(withTime) != 3 || withTime[0].AppliedAt.IsZero() {
+		t.Fatalf("applied with time=%v err=%v", withTime, err)
+	}
+
+	if err := s.SetChecksum(testTables, 2, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if sums, err := s.ListChecksums(testTables); err != nil || len(sums) != 1 || sums[2] != "abc" {
+		t.Fatalf("checksums=%v err=%v", sums, err)
+	}
+
+	if err := s.SetVersion(testTables, 5); err == nil {
+		t.Fatal("expected an error for a target above the current version")
+	}
+	if err := s.SetVersion(testTables, 1); err != nil {
+		t.Fatal(err)
+	}
+	if applied, _ := s.ListApplied(testTables); fmt.Sprint(applied) != "[1]" {
+		t.Fatalf("after SetVersion(1): %v", applied)
+	}
+	if err := s.Remove(testTables, 1); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.IsApplied(testTables, 1); ok {
+		t.Fatal("version 1 should be removed")
+	}
+}
+
+func TestMongo_Runs(t *testing.T) {
+	s := startMongo(t)
+	body := `{"id":1}`
+	started := time.Now().Add(-time.Second)
+	details := connector.RunDetails{Method: "POST", URL: "http://x/users", StartedAt: started, DurationMs: 12,
+		Headers: map[string]string{"X-Trace.Id": "t1"}, RunID: "run-1"}
+	if err := s.RecordRun(testTables, 1, "up", 201, &body, map[string]string{"user.id": "1"}, false, details); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordRun(testTables, 1, "down", 500, nil, nil, true, connector.RunDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordRun(testTables, 2, "up", 200, nil, map[string]string{"k": "v"}, false, connector.RunDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := s.ListRuns(testTables)
+	if err != nil || len(runs) != 3 {
+		t.Fatalf("runs=%v err=%v", runs, err)
+	}
+	r := runs[0]
+	if r.ID != 1 || runs[2].ID != 3 || r.Body == nil || *r.Body != body || r.Env["user.id"] != "1" || r.Method != "POST" ||
+		r.StartedAt == "" || r.DurationMs == nil || *r.DurationMs != 12 || r.Headers["X-Trace.Id"] != "t1" || r.RunID != "run-1" {
+		t.Fatalf("unexpected first run: %+v", r)
+	}
+	if runs[1].Body != nil || !runs[1].Failed || runs[1].Env == nil || runs[1].DurationMs != nil {
+		t.Fatalf("unexpected second run: %+v", runs[1])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, r.RanAt); err != nil {
+		t.Fatalf("ran_at %q: %v", r.RanAt, err)
+	}
+
+	failed, _ := s.ListRunsFiltered(testTables, connector.RunFilter{FailedOnly: true})
+	if len(failed) != 1 || failed[0].Direction != "down" {
+		t.Fatalf("failed runs: %+v", failed)
+	}
+	last, _ := s.ListRunsFiltered(testTables, connector.RunFilter{Limit: 2})
+	if len(last) != 2 || last[0].ID != 2 || last[1].ID != 3 {
+		t.Fatalf("newest runs: %+v", last)
+	}
+	if env, err := s.LoadEnv(testTables, 2, "up"); err != nil || env["k"] != "v" {
+		t.Fatalf("env=%v err=%v", env, err)
+	}
+	if env, err := s.LoadEnv(testTables, 9, "up"); err != nil || len(env) != 0 {
+		t.Fatalf("env of an unknown version=%v err=%v", env, err)
+	}
+
+	n, err := s.PruneRuns(testTables, time.Time{}, 1)
+	if err != nil || n != 1 {
+		t.Fatalf("pruned=%d err=%v", n, err)
+	}
+	if runs, _ := s.ListRuns(testTables); len(runs) != 2 || runs[0].ID != 2 {
+		t.Fatalf("runs after prune: %+v", runs)
+	}
+}
+
+func TestMongo_StoredEnv(t *testing.T) {
+	s := startMongo(t)
+	s.SetStoredEnvLimits(connector.StoredEnvLimits{MaxEntries: 3, BatchSize: 2})
+	if err := s.InsertStoredEnv(testTables, 1, map[string]string{"a": "1", "b": "2", "c": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	// Upserted by (version, name)
+	if err := s.InsertStoredEnv(testTables, 1, map[string]string{"a": "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertStoredEnv(testTables, 2, map[string]string{"a": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	env, err := s.LoadStoredEnv(testTables, 1)
+	if err != nil || len(env) != 3 || env["a"] != "10" || env["c"] != "3" {
+		t.Fatalf("stored env=%v err=%v", env, err)
+	}
+	err = s.InsertStoredEnv(testTables, 3, map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"})
+	if err == nil || !strings.Contains(err.Error(), "limit: 3") {
+		t.Fatalf("expected the entry limit error, got %v", err)
+	}
+	if env, _ := s.LoadStoredEnv(testTables, 3); len(env) != 0 {
+		t.Fatalf("over-limit env must not be stored: %v", env)
+	}
+	if err := s.DeleteStoredEnv(testTables, 1); err != nil {
+		t.Fatal(err)
+	}
+	if env, _ := s.LoadStoredEnv(testTables, 1); len(env) != 0 {
+		t.Fatalf("deleted env: %v", env)
+	}
+	if env, _ := s.LoadStoredEnv(testTables, 2); env["a"] != "x" {
+		t.Fatalf("env of another version: %v", env)
+	}
+}
@@ -2,6 +2,7 @@ package postgresql
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/loykin/apirun/internal/store/connector"
 )
@@ -75,6 +76,23 @@ func (a *Adapter) ListApplied(th connector.TableNames) ([]int, error) {
 	return a.store.ListApplied(postgresTh)
 }
 
+func (a *Adapter) ListAppliedWithTime(th connector.TableNames) ([]connector.AppliedInfo, error) {
+	postgresTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	applied, err := a.store.ListAppliedWithTime(postgresTh)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]connector.AppliedInfo, len(applied))
+	for i, ai := range applied {
+		out[i] = connector.AppliedInfo(ai)
+	}
+	return out, nil
+}
+
 func (a *Adapter) Remove(th connector.TableNames, v int) error {
 	postgresTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
@@ -84,6 +102,24 @@ func (a *Adapter) Remove(th connector.TableNames, v int) error {
 	return a.store.Remove(postgresTh, v)
 }
 
+func (a *Adapter) SetChecksum(th connector.TableNames, v int, checksum string) error {
+	postgresTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.SetChecksum(postgresTh, v, checksum)
+}
+
+func (a *Adapter) ListChecksums(th connector.TableNames) (map[int]string, error) {
+	postgresTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.ListChecksums(postgresTh)
+}
+
 func (a *Adapter) SetVersion(th connector.TableNames, target int) error {
 	postgresTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
@@ -93,13 +129,13 @@ func (a *Adapter) SetVersion(th connector.TableNames, target int) error {
 	return a.store.SetVersion(postgresTh, target)
 }
 
-func (a *Adapter) RecordRun(th connector.TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool) error {
+func (a *Adapter) RecordRun(th connector.TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, details connector.RunDetails) error {
 	postgresTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
 		MigrationRuns:    th.MigrationRuns,
 		StoredEnv:        th.StoredEnv,
 	}
-	return a.store.RecordRun(postgresTh, version, direction, status, body, env, failed)
+	return a.store.RecordRun(postgresTh, version, direction, status, body, env, failed, RunDetails(details))
 }
 
 func (a *Adapter) LoadEnv(th connector.TableNames, version int, direction string) (map[string]string, error) {
@@ -139,12 +175,16 @@ func (a *Adapter) DeleteStoredEnv(th connector.TableNames, version int) error {
 }
 
 func (a *Adapter) ListRuns(th connector.TableNames) ([]connector.Run, error) {
+	return a.ListRunsFiltered(th, connector.RunFilter{})
+}
+
+func (a *Adapter) ListRunsFiltered(th connector.TableNames, f connector.RunFilter) ([]connector.Run, error) {
 	postgresTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
 		MigrationRuns:    th.MigrationRuns,
 		StoredEnv:        th.StoredEnv,
 	}
-	postgresRuns, err := a.store.ListRuns(postgresTh)
+	postgresRuns, err := a.store.ListRunsFiltered(postgresTh, RunFilter{Version: f.Version, Direction: f.Direction, FailedOnly: f.FailedOnly, Limit: f.Limit, Since: f.Since})
 	if err != nil {
 		return nil, err
 	}
@@ -161,11 +201,43 @@ func (a *Adapter) ListRuns(th connector.TableNames) ([]connector.Run, error) {
 			Env:        r.Env,
 			Failed:     r.Failed,
 			RanAt:      r.RanAt,
+			Method:     r.Method,
+			URL:        r.URL,
+			StartedAt:  r.StartedAt,
+			DurationMs: r.DurationMs,
+			Headers:    r.Headers,
+			RunID:      r.RunID,
 		}
 	}
 	return runs, nil
 }
 
+func (a *Adapter) PruneRuns(th connector.TableNames, before time.Time, keepLastPerVersion int) (int64, error) {
+	postgresTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.PruneRuns(postgresTh, before, keepLastPerVersion)
+}
+
+func (a *Adapter) SetStoredEnvLimits(l connector.StoredEnvLimits) {
+	a.store.SetStoredEnvLimits(l)
+}
+
+func (a *Adapter) WithTx(fn func(tx connector.Connector) error) error {
+	return a.store.WithTx(func(s *Store) error { return fn(&Adapter{store: s}) })
+}
+
+func (a *Adapter) Lock(th connector.TableNames, timeout time.Duration) (func() error, error) {
+	postgresTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.Lock(postgresTh, timeout)
+}
+
 func (a *Adapter) Close() error {
 	return a.store.Close()
 }
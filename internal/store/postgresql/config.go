@@ -2,6 +2,7 @@ package postgresql
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/loykin/apirun/internal/constants"
 	"github.com/loykin/apirun/internal/util"
@@ -15,7 +16,12 @@ type Config struct {
 	Password string `mapstructure:"password"`
 	DBName   string `mapstructure:"dbname"`
 	SSLMode  string `mapstructure:"sslmode"`
-	dsn      string
+	// Connection pool limits; zero uses the defaults (10 open, 3 idle, 3m lifetime). Raise
+	// MaxOpenConns when many stages or parallel migrations share one database.
+	MaxOpenConns    int           `mapstructure:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+	dsn             string
 }
 
 func (p *Config) ToMap() map[string]interface{} {
@@ -37,7 +43,17 @@ func (p *Config) ToMap() map[string]interface{} {
 		)
 	}
 	p.dsn = dsn
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"dsn": dsn,
 	}
+	if p.MaxOpenConns > 0 {
+		m["max_open_conns"] = p.MaxOpenConns
+	}
+	if p.MaxIdleConns > 0 {
+		m["max_idle_conns"] = p.MaxIdleConns
+	}
+	if p.ConnMaxLifetime > 0 {
+		m["conn_max_lifetime"] = p.ConnMaxLifetime
+	}
+	return m
 }
@@ -55,18 +55,40 @@ func (p *Dialect) ConvertTimeFromStorage(val interface{}) string {
 	return ""
 }
 
+// PoolConfig holds the connection pool limits; zero values use the defaults.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// apply configures the pool of db, falling back to the defaults for unset limits.
+func (c PoolConfig) apply(db *sql.DB) {
+	maxOpen := c.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = constants.DefaultPostgresMaxConnections
+	}
+	maxIdle := c.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = constants.DefaultPostgresMaxIdleConns
+	}
+	lifetime := c.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = constants.DefaultMaxConnLifetime
+	}
+	db.SetMaxOpenConns(maxOpen)                         // Maximum number of open connections
+	db.SetMaxIdleConns(maxIdle)                         // Maximum number of idle connections
+	db.SetConnMaxLifetime(lifetime)                     // Maximum amount of time a connection may be reused
+	db.SetConnMaxIdleTime(constants.DefaultMaxIdleTime) // Maximum amount of time a connection may be idle
+}
+
 // Connect establishes a connection to PostgreSQL with connection pooling
-func (p *Dialect) Connect(dsn string) (*sql.DB, error) {
+func (p *Dialect) Connect(dsn string, pool PoolConfig) (*sql.DB, error) {
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open PostgreSQL connection: %w", err)
 	}
-
-	// Configure connection pool settings
-	db.SetMaxOpenConns(constants.DefaultPostgresMaxConnections) // Maximum number of open connections
-	db.SetMaxIdleConns(constants.DefaultPostgresMaxIdleConns)   // Maximum number of idle connections
-	db.SetConnMaxLifetime(constants.DefaultMaxConnLifetime)     // Maximum amount of time a connection may be reused
-	db.SetConnMaxIdleTime(constants.DefaultMaxIdleTime)         // Maximum amount of time a connection may be idle
+	pool.apply(db)
 
 	if err := db.Ping(); err != nil {
 		_ = db.Close()
@@ -84,6 +106,18 @@ func (p *Dialect) GetEnsureStatements(schemaMigrations, migrationRuns, storedEnv
 	}
 }
 
+// GetRunDetailColumns returns the nullable migration_runs columns added after the original
+// schema. Ensure adds any of them that an existing table lacks.
+func (p *Dialect) GetRunDetailColumns() []string {
+	return []string{"method TEXT NULL", "url TEXT NULL", "started_at TIMESTAMPTZ NULL", "duration_ms BIGINT NULL", "headers_json TEXT NULL", "run_id TEXT NULL"}
+}
+
+// GetSchemaMigrationColumns returns the nullable schema_migrations columns added after the
+// original schema. Ensure adds any of them that an existing table lacks.
+func (p *Dialect) GetSchemaMigrationColumns() []string {
+	return []string{"checksum TEXT NULL", "applied_at TIMESTAMPTZ NULL"}
+}
+
 // GetDriverName returns the driver name for logging
 func (p *Dialect) GetDriverName() string {
 	return "postgresql"
@@ -4,6 +4,9 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/loykin/apirun/internal/constants"
 )
 
 func TestNewDialect(t *testing.T) {
@@ -194,7 +197,7 @@ func TestDialect_Connect(t *testing.T) {
 	dialect := NewDialect()
 
 	// Test with invalid DSN - should fail
-	_, err := dialect.Connect("invalid-dsn")
+	_, err := dialect.Connect("invalid-dsn", PoolConfig{})
 	if err == nil {
 		t.Error("Connect() should fail with invalid DSN")
 	}
@@ -237,3 +240,27 @@ func TestDialect_GetDriverName(t *testing.T) {
 		t.Errorf("GetDriverName() = %v, want %v", got, want)
 	}
 }
+
+func TestPoolConfig_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		pool     PoolConfig
+		wantOpen int
+	}{
+		{name: "defaults", pool: PoolConfig{}, wantOpen: constants.DefaultPostgresMaxConnections},
+		{name: "configured", pool: PoolConfig{MaxOpenConns: 40, MaxIdleConns: 8, ConnMaxLifetime: time.Minute}, wantOpen: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New: %v", err)
+			}
+			defer func() { _ = db.Close() }()
+			tt.pool.apply(db)
+			if got := db.Stats().MaxOpenConnections; got != tt.wantOpen {
+				t.Errorf("MaxOpenConnections = %d, want %d", got, tt.wantOpen)
+			}
+		})
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/loykin/apirun/internal/common"
 	"github.com/loykin/apirun/internal/retry"
+	"github.com/loykin/apirun/internal/store/connector"
 )
 
 // Run represents a single execution record from the migration_runs table.
@@ -22,6 +25,38 @@ type Run struct {
 	Env        map[string]string
 	Failed     bool
 	RanAt      string
+	Method     string
+	URL        string
+	StartedAt  string
+	DurationMs *int64
+	Headers    map[string]string
+	RunID      string
+}
+
+// AppliedInfo is an applied version and when it was applied; AppliedAt is zero for
+// versions applied before the time was recorded.
+type AppliedInfo struct {
+	Version   int
+	AppliedAt time.Time
+}
+
+// RunDetails carries the request metadata recorded alongside a migration run.
+type RunDetails struct {
+	Method     string
+	URL        string
+	StartedAt  time.Time
+	DurationMs int64
+	Headers    map[string]string
+	RunID      string
+}
+
+// RunFilter narrows ListRunsFiltered; zero values do not filter.
+type RunFilter struct {
+	Version    int
+	Direction  string
+	FailedOnly bool
+	Limit      int
+	Since      time.Time
 }
 
 // TableNames represents database table names
@@ -35,7 +70,20 @@ type Store struct {
 	db          *sql.DB
 	dialect     *Dialect
 	DSN         string
+	Pool        PoolConfig
 	retryConfig *retry.Config
+	storedEnv   connector.StoredEnvLimits
+	// tx is set on the copies passed to WithTx callbacks
+	tx *sql.Tx
+	// lockDB holds the connection of the run lock, apart from the pool of db (see Lock)
+	lockDB *sql.DB
+}
+
+// queryer is the part of *sql.DB and *sql.Tx the store queries use.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // NewStore creates a new PostgreSQL store
@@ -51,16 +99,32 @@ func (p *Store) Load(config map[string]interface{}) error {
 	if dsn, ok := config["dsn"].(string); ok && dsn != "" {
 		p.DSN = dsn
 	}
+	if v, ok := config["max_open_conns"].(int); ok {
+		p.Pool.MaxOpenConns = v
+	}
+	if v, ok := config["max_idle_conns"].(int); ok {
+		p.Pool.MaxIdleConns = v
+	}
+	if v, ok := config["conn_max_lifetime"].(time.Duration); ok {
+		p.Pool.ConnMaxLifetime = v
+	}
 	return nil
 }
 
 // Connect establishes a connection to PostgreSQL using the adapter
 func (p *Store) Connect() (*sql.DB, error) {
-	db, err := p.dialect.Connect(p.DSN)
+	db, err := p.dialect.Connect(p.DSN, p.Pool)
 	if err != nil {
 		return nil, err
 	}
-	p.db = db
+	// The lock connection is only dialed by the first Lock
+	lockDB, err := sql.Open("pgx", p.DSN)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open the PostgreSQL connection for the migration lock: %w", err)
+	}
+	PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1, ConnMaxLifetime: p.Pool.ConnMaxLifetime}.apply(lockDB)
+	p.db, p.lockDB = db, lockDB
 
 	logger := common.GetLogger().WithStore("postgresql")
 	logger.Info("PostgreSQL database connection established successfully")
@@ -74,12 +138,89 @@ func (p *Store) Validate() error {
 
 // Close closes the database connection
 func (p *Store) Close() error {
+	if p.lockDB != nil {
+		_ = p.lockDB.Close()
+	}
 	if p.db != nil {
 		return p.db.Close()
 	}
 	return nil
 }
 
+// WithTx calls fn with a copy of p whose statements run in one transaction, committed when fn
+// returns nil and rolled back otherwise. Within a transaction fn gets p itself.
+func (p *Store) WithTx(fn func(tx *Store) error) error {
+	if p.tx != nil {
+		return fn(p)
+	}
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin PostgreSQL transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	txs := *p
+	txs.tx = tx
+	// A failed statement aborts the transaction, so statements in it are not retried.
+	txs.retryConfig = retry.NoRetryConfig()
+	if err := fn(&txs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit PostgreSQL transaction: %w", err)
+	}
+	return nil
+}
+
+// Lock takes a session-level advisory lock keyed by the schema_migrations table name, waiting at
+// most timeout, and returns the function releasing it. The lock holds its connection until it is
+// released; the connection, opened by Connect, is apart from the pool, so that a pool of one
+// connection (max_open_conns: 1) still serves the statements of the run.
+func (p *Store) Lock(th TableNames, timeout time.Duration) (func() error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if p.lockDB == nil {
+		return nil, fmt.Errorf("failed to take the migration lock: PostgreSQL store is not connected")
+	}
+	conn, err := p.lockDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a PostgreSQL connection for the migration lock: %w", err)
+	}
+	key := lockKey(th)
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		// A cancelled wait discards the connection, so the lock cannot be left behind
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %s waiting for the migration lock on %s: another run holds it", timeout, th.SchemaMigrations)
+		}
+		return nil, fmt.Errorf("failed to acquire the migration lock: %w", err)
+	}
+	logger := common.GetLogger().WithStore("postgresql")
+	logger.Debug("migration lock acquired", "table", th.SchemaMigrations)
+	return func() error {
+		defer func() { _ = conn.Close() }()
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			return fmt.Errorf("failed to release the migration lock: %w", err)
+		}
+		logger.Debug("migration lock released", "table", th.SchemaMigrations)
+		return nil
+	}, nil
+}
+
+// lockKey is the advisory lock key of the tables th.
+func lockKey(th TableNames) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("apirun:" + th.SchemaMigrations))
+	return int64(h.Sum64())
+}
+
+// conn returns the transaction of a WithTx copy, else the database.
+func (p *Store) conn() queryer {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.db
+}
+
 // Ensure creates the necessary tables using PostgreSQL-specific schema
 func (p *Store) Ensure(th TableNames) error {
 	logger := common.GetLogger().WithStore("postgresql")
@@ -88,17 +229,34 @@ func (p *Store) Ensure(th TableNames) error {
 	stmts := p.dialect.GetEnsureStatements(th.SchemaMigrations, th.MigrationRuns, th.StoredEnv)
 	for i, q := range stmts {
 		logger.Debug("executing schema creation statement", "table_index", i+1, "sql", q)
-		if _, err := p.db.Exec(q); err != nil {
+		if _, err := p.conn().Exec(q); err != nil {
 			logger.Error("failed to create table in schema setup", "error", err, "table_index", i+1, "sql", q)
 			return fmt.Errorf("failed to create table %d in PostgreSQL schema setup: %w", i+1, err)
 		}
 	}
+	// Upgrade tables created by older versions in place
+	upgrades := []struct {
+		table   string
+		columns []string
+	}{
+		{th.MigrationRuns, p.dialect.GetRunDetailColumns()},
+		{th.SchemaMigrations, p.dialect.GetSchemaMigrationColumns()},
+	}
+	for _, u := range upgrades {
+		for _, col := range u.columns {
+			q := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", u.table, col)
+			if _, err := p.conn().Exec(q); err != nil {
+				logger.Error("failed to upgrade table", "error", err, "sql", q)
+				return fmt.Errorf("failed to add column %q to %s: %w", col, u.table, err)
+			}
+		}
+	}
 
 	logger.Info("PostgreSQL database schema ensured successfully")
 	return nil
 }
 
-// Apply inserts a migration version into the schema_migrations table
+// Apply inserts a migration version into the schema_migrations table, recording when it was applied
 func (p *Store) Apply(th TableNames, v int) error {
 	logger := common.GetLogger().WithStore(p.dialect.GetDriverName()).WithVersion(v)
 	logger.Debug("applying migration version")
@@ -106,16 +264,17 @@ func (p *Store) Apply(th TableNames, v int) error {
 	var q string
 	upsertClause := p.dialect.GetUpsertClause()
 	if upsertClause != "" {
-		q = fmt.Sprintf("INSERT %s INTO %s(version) VALUES(%s)",
-			upsertClause, th.SchemaMigrations, p.dialect.GetPlaceholder(1))
+		q = fmt.Sprintf("INSERT %s INTO %s(version, applied_at) VALUES(%s, %s)",
+			upsertClause, th.SchemaMigrations, p.dialect.GetPlaceholder(1), p.dialect.GetPlaceholder(2))
 	} else {
-		q = fmt.Sprintf("INSERT INTO %s(version) VALUES(%s) ON CONFLICT DO NOTHING",
-			th.SchemaMigrations, p.dialect.GetPlaceholder(1))
+		q = fmt.Sprintf("INSERT INTO %s(version, applied_at) VALUES(%s, %s) ON CONFLICT DO NOTHING",
+			th.SchemaMigrations, p.dialect.GetPlaceholder(1), p.dialect.GetPlaceholder(2))
 	}
+	appliedAt := p.dialect.ConvertTimeToStorage(time.Now().UTC())
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
-		return p.db.Exec(q, v)
+		return p.conn().Exec(q, v, appliedAt)
 	})
 	if err != nil {
 		logger.Error("failed to apply migration version", "error", err)
@@ -137,7 +296,7 @@ func (p *Store) IsApplied(th TableNames, v int) (bool, error) {
 	var scanErr error
 	ctx := context.Background()
 	err := retry.WithRetry(ctx, p.retryConfig, func() error {
-		scanErr = p.db.QueryRow(q, v).Scan(&result)
+		scanErr = p.conn().QueryRow(q, v).Scan(&result)
 		return scanErr
 	})
 
@@ -161,7 +320,7 @@ func (p *Store) CurrentVersion(th TableNames) (int, error) {
 	var version int
 	ctx := context.Background()
 	err := retry.WithRetry(ctx, p.retryConfig, func() error {
-		return p.db.QueryRow(q).Scan(&version)
+		return p.conn().QueryRow(q).Scan(&version)
 	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get current version: %w", err)
@@ -175,7 +334,7 @@ func (p *Store) ListApplied(th TableNames) ([]int, error) {
 
 	ctx := context.Background()
 	rows, err := retry.WithRetryQuery(ctx, p.retryConfig, func() (*sql.Rows, error) {
-		return p.db.Query(q)
+		return p.conn().Query(q)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
@@ -197,13 +356,88 @@ func (p *Store) ListApplied(th TableNames) ([]int, error) {
 	return versions, nil
 }
 
+// ListAppliedWithTime returns the applied migration versions with the time each was applied
+func (p *Store) ListAppliedWithTime(th TableNames) ([]AppliedInfo, error) {
+	q := fmt.Sprintf("SELECT version, applied_at FROM %s ORDER BY version", th.SchemaMigrations)
+
+	ctx := context.Background()
+	rows, err := retry.WithRetryQuery(ctx, p.retryConfig, func() (*sql.Rows, error) {
+		return p.conn().Query(q)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var applied []AppliedInfo
+	for rows.Next() {
+		var version int
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		info := AppliedInfo{Version: version}
+		if appliedAt.Valid {
+			info.AppliedAt = appliedAt.Time.UTC()
+		}
+		applied = append(applied, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// SetChecksum stores the migration file checksum of applied version v
+func (p *Store) SetChecksum(th TableNames, v int, checksum string) error {
+	q := fmt.Sprintf("UPDATE %s SET checksum = %s WHERE version = %s", th.SchemaMigrations, p.dialect.GetPlaceholder(1), p.dialect.GetPlaceholder(2))
+
+	ctx := context.Background()
+	_, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
+		return p.conn().Exec(q, checksum, v)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record checksum of migration version %d: %w", v, err)
+	}
+	return nil
+}
+
+// ListChecksums returns the recorded checksums by version; versions without one are omitted
+func (p *Store) ListChecksums(th TableNames) (map[int]string, error) {
+	q := fmt.Sprintf("SELECT version, checksum FROM %s WHERE checksum IS NOT NULL", th.SchemaMigrations)
+
+	ctx := context.Background()
+	rows, err := retry.WithRetryQuery(ctx, p.retryConfig, func() (*sql.Rows, error) {
+		return p.conn().Query(q)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PostgreSQL migration checksums: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	sums := map[int]string{}
+	for rows.Next() {
+		var version int
+		var sum string
+		if err := rows.Scan(&version, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan PostgreSQL migration checksum: %w", err)
+		}
+		sums[version] = sum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating PostgreSQL migration checksums: %w", err)
+	}
+	return sums, nil
+}
+
 // Remove removes a migration version from the schema_migrations table
 func (p *Store) Remove(th TableNames, v int) error {
 	q := fmt.Sprintf("DELETE FROM %s WHERE version = %s", th.SchemaMigrations, p.dialect.GetPlaceholder(1))
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
-		return p.db.Exec(q, v)
+		return p.conn().Exec(q, v)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to remove migration version %d: %w", v, err)
@@ -230,7 +464,7 @@ func (p *Store) SetVersion(th TableNames, target int) error {
 
 	ctx := context.Background()
 	_, err = retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
-		return p.db.Exec(q, target)
+		return p.conn().Exec(q, target)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to set version to %d: %w", target, err)
@@ -247,7 +481,7 @@ func (p *Store) LoadEnv(th TableNames, version int, direction string) (map[strin
 	var scanErr error
 	ctx := context.Background()
 	err := retry.WithRetry(ctx, p.retryConfig, func() error {
-		scanErr = p.db.QueryRow(q, version, direction).Scan(&envJSON)
+		scanErr = p.conn().QueryRow(q, version, direction).Scan(&envJSON)
 		return scanErr
 	})
 	if scanErr == sql.ErrNoRows {
@@ -274,7 +508,7 @@ func (p *Store) LoadStoredEnv(th TableNames, version int) (map[string]string, er
 
 	ctx := context.Background()
 	rows, err := retry.WithRetryQuery(ctx, p.retryConfig, func() (*sql.Rows, error) {
-		return p.db.Query(q, version)
+		return p.conn().Query(q, version)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to load stored env for version %d: %w", version, err)
@@ -302,7 +536,7 @@ func (p *Store) DeleteStoredEnv(th TableNames, version int) error {
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
-		return p.db.Exec(q, version)
+		return p.conn().Exec(q, version)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to delete stored env for version %d: %w", version, err)
@@ -310,8 +544,33 @@ func (p *Store) DeleteStoredEnv(th TableNames, version int) error {
 	return nil
 }
 
+// PruneRuns deletes the runs recorded before before (zero = any age), except the newest
+// keepLastPerVersion runs of each version, in a single DELETE.
+func (p *Store) PruneRuns(th TableNames, before time.Time, keepLastPerVersion int) (int64, error) {
+	q := fmt.Sprintf("DELETE FROM %[1]s WHERE id IN (SELECT id FROM (SELECT id, ran_at, ROW_NUMBER() OVER (PARTITION BY version ORDER BY id DESC) AS rn FROM %[1]s) AS ranked WHERE rn > $1", th.MigrationRuns)
+	args := []interface{}{keepLastPerVersion}
+	if !before.IsZero() {
+		q += " AND ran_at < $2"
+		args = append(args, before)
+	}
+	q += ")"
+
+	ctx := context.Background()
+	res, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
+		return p.conn().Exec(q, args...)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune PostgreSQL migration runs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune PostgreSQL migration runs: %w", err)
+	}
+	return n, nil
+}
+
 // RecordRun records a migration run with PostgreSQL-specific time handling
-func (p *Store) RecordRun(th TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool) error {
+func (p *Store) RecordRun(th TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, details RunDetails) error {
 	var envJSON *string
 	if len(env) > 0 {
 		b, err := json.Marshal(env)
@@ -324,16 +583,33 @@ func (p *Store) RecordRun(th TableNames, version int, direction string, status i
 
 	ranAt := p.dialect.ConvertTimeToStorage(time.Now().UTC())
 	failedVal := p.dialect.ConvertBoolToStorage(failed)
+	method, url := nullIfEmpty(details.Method), nullIfEmpty(details.URL)
+	var startedAt, durationMs interface{}
+	if !details.StartedAt.IsZero() {
+		startedAt = p.dialect.ConvertTimeToStorage(details.StartedAt.UTC())
+		durationMs = details.DurationMs
+	}
+	var headersJSON *string
+	if len(details.Headers) > 0 {
+		b, err := json.Marshal(details.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal response headers for PostgreSQL migration run record (version %d, direction %s): %w", version, direction, err)
+		}
+		h := string(b)
+		headersJSON = &h
+	}
 
-	q := fmt.Sprintf("INSERT INTO %s(version, direction, status_code, body, env_json, failed, ran_at) VALUES(%s,%s,%s,%s,%s,%s,%s)",
+	q := fmt.Sprintf("INSERT INTO %s(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id) VALUES(%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)",
 		th.MigrationRuns,
 		p.dialect.GetPlaceholder(1), p.dialect.GetPlaceholder(2), p.dialect.GetPlaceholder(3),
 		p.dialect.GetPlaceholder(4), p.dialect.GetPlaceholder(5), p.dialect.GetPlaceholder(6),
-		p.dialect.GetPlaceholder(7))
+		p.dialect.GetPlaceholder(7), p.dialect.GetPlaceholder(8), p.dialect.GetPlaceholder(9),
+		p.dialect.GetPlaceholder(10), p.dialect.GetPlaceholder(11), p.dialect.GetPlaceholder(12),
+		p.dialect.GetPlaceholder(13))
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
-		return p.db.Exec(q, version, direction, status, body, envJSON, failedVal, ranAt)
+		return p.conn().Exec(q, version, direction, status, body, envJSON, failedVal, ranAt, method, url, startedAt, durationMs, headersJSON, nullIfEmpty(details.RunID))
 	})
 	if err != nil {
 		return fmt.Errorf("failed to record PostgreSQL migration run (version %d, direction %s, status %d): %w", version, direction, status, err)
@@ -341,35 +617,42 @@ func (p *Store) RecordRun(th TableNames, version int, direction string, status i
 	return nil
 }
 
-// InsertStoredEnv inserts stored environment variables
-const maxStoredEnvEntries = 10000
-const maxCapacity = maxStoredEnvEntries * 3
+// SetStoredEnvLimits sets the cap and batch size used by InsertStoredEnv.
+func (p *Store) SetStoredEnvLimits(l connector.StoredEnvLimits) {
+	p.storedEnv = l
+}
 
+// InsertStoredEnv inserts stored environment variables in batched multi-row statements within
+// one transaction, so a failing batch leaves none of kv stored.
 func (p *Store) InsertStoredEnv(th TableNames, version int, kv map[string]string) error {
-	c := len(kv)
-	if c == 0 {
+	if len(kv) == 0 {
 		return nil
 	}
-	if c > maxStoredEnvEntries {
-		return fmt.Errorf("stored environment map too large: %d entries (limit: %d)", c, maxStoredEnvEntries)
-	}
-	valuesClauses := make([]string, 0, c)
-	args := make([]interface{}, 0, maxCapacity)
-	argIndex := 1
-
-	for name, value := range kv {
-		valuesClauses = append(valuesClauses, fmt.Sprintf("(%s,%s,%s)",
-			p.dialect.GetPlaceholder(argIndex), p.dialect.GetPlaceholder(argIndex+1), p.dialect.GetPlaceholder(argIndex+2)))
-		args = append(args, version, name, value)
-		argIndex += 3
+	batches, err := p.storedEnv.Batches(kv)
+	if err != nil {
+		return err
 	}
 
-	q := fmt.Sprintf("INSERT INTO %s(version, name, value) VALUES %s ON CONFLICT (version, name) DO UPDATE SET value = EXCLUDED.value",
-		th.StoredEnv, strings.Join(valuesClauses, ","))
-
 	ctx := context.Background()
-	_, err := retry.WithRetryExec(ctx, p.retryConfig, func() (sql.Result, error) {
-		return p.db.Exec(q, args...)
+	err = retry.WithRetry(ctx, p.retryConfig, func() error {
+		return p.WithTx(func(tx *Store) error {
+			for _, names := range batches {
+				valuesClauses := make([]string, 0, len(names))
+				args := make([]interface{}, 0, len(names)*3)
+				for _, name := range names {
+					n := len(args)
+					valuesClauses = append(valuesClauses, fmt.Sprintf("(%s,%s,%s)",
+						p.dialect.GetPlaceholder(n+1), p.dialect.GetPlaceholder(n+2), p.dialect.GetPlaceholder(n+3)))
+					args = append(args, version, name, kv[name])
+				}
+				q := fmt.Sprintf("INSERT INTO %s(version, name, value) VALUES %s ON CONFLICT (version, name) DO UPDATE SET value = EXCLUDED.value",
+					th.StoredEnv, strings.Join(valuesClauses, ","))
+				if _, err := tx.conn().Exec(q, args...); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 	})
 	if err != nil {
 		return fmt.Errorf("failed to insert stored environment for PostgreSQL version %d: %w", version, err)
@@ -379,11 +662,43 @@ func (p *Store) InsertStoredEnv(th TableNames, version int, kv map[string]string
 
 // ListRuns returns migration run history with PostgreSQL-specific type handling
 func (p *Store) ListRuns(th TableNames) ([]Run, error) {
-	q := fmt.Sprintf("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM %s ORDER BY id ASC", th.MigrationRuns)
+	return p.ListRunsFiltered(th, RunFilter{})
+}
+
+// ListRunsFiltered returns the runs matching f ordered by id ASC; with f.Limit only the newest
+// matching runs are returned.
+func (p *Store) ListRunsFiltered(th TableNames, f RunFilter) ([]Run, error) {
+	var where []string
+	var args []interface{}
+	if f.Version > 0 {
+		args = append(args, f.Version)
+		where = append(where, fmt.Sprintf("version = $%d", len(args)))
+	}
+	if f.Direction != "" {
+		args = append(args, f.Direction)
+		where = append(where, fmt.Sprintf("direction = $%d", len(args)))
+	}
+	if f.FailedOnly {
+		where = append(where, "failed = TRUE")
+	}
+	if !f.Since.IsZero() {
+		args = append(args, f.Since)
+		where = append(where, fmt.Sprintf("ran_at >= $%d", len(args)))
+	}
+	q := fmt.Sprintf("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM %s", th.MigrationRuns)
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		q += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+	} else {
+		q += " ORDER BY id ASC"
+	}
 
 	ctx := context.Background()
 	rows, err := retry.WithRetryQuery(ctx, p.retryConfig, func() (*sql.Rows, error) {
-		return p.db.Query(q)
+		return p.conn().Query(q, args...)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list PostgreSQL migration runs: %w", err)
@@ -397,8 +712,13 @@ func (p *Store) ListRuns(th TableNames) ([]Run, error) {
 		var envJSON sql.NullString
 		var ranAt time.Time
 		var failed bool
+		var method, url sql.NullString
+		var startedAt sql.NullTime
+		var durationMs sql.NullInt64
+		var headersJSON, runID sql.NullString
 
-		err := rows.Scan(&run.ID, &run.Version, &run.Direction, &run.StatusCode, &body, &envJSON, &failed, &ranAt)
+		err := rows.Scan(&run.ID, &run.Version, &run.Direction, &run.StatusCode, &body, &envJSON, &failed, &ranAt,
+			&method, &url, &startedAt, &durationMs, &headersJSON, &runID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan PostgreSQL migration run: %w", err)
 		}
@@ -418,6 +738,18 @@ func (p *Store) ListRuns(th TableNames) ([]Run, error) {
 
 		run.Failed = failed
 		run.RanAt = p.dialect.ConvertTimeFromStorage(&ranAt)
+		run.Method = method.String
+		run.URL = url.String
+		if startedAt.Valid {
+			run.StartedAt = p.dialect.ConvertTimeFromStorage(&startedAt.Time)
+		}
+		if durationMs.Valid {
+			run.DurationMs = &durationMs.Int64
+		}
+		if headersJSON.Valid && headersJSON.String != "" {
+			_ = json.Unmarshal([]byte(headersJSON.String), &run.Headers)
+		}
+		run.RunID = runID.String
 
 		runs = append(runs, run)
 	}
@@ -425,5 +757,16 @@ func (p *Store) ListRuns(th TableNames) ([]Run, error) {
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating PostgreSQL migration runs: %w", err)
 	}
+	if f.Limit > 0 {
+		slices.Reverse(runs)
+	}
 	return runs, nil
 }
+
+// nullIfEmpty maps an empty string to NULL for optional text columns.
+func nullIfEmpty(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/loykin/apirun/internal/retry"
+	"github.com/loykin/apirun/internal/store/connector"
 )
 
 func TestNewStore(t *testing.T) {
@@ -72,6 +75,18 @@ func TestStore_Validate(t *testing.T) {
 	}
 }
 
+func TestStore_LoadPool(t *testing.T) {
+	cfg := Config{DSN: "postgres://h/db", MaxOpenConns: 40, MaxIdleConns: 8, ConnMaxLifetime: time.Minute}
+	store := NewStore()
+	if err := store.Load(cfg.ToMap()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := PoolConfig{MaxOpenConns: 40, MaxIdleConns: 8, ConnMaxLifetime: time.Minute}
+	if store.Pool != want {
+		t.Errorf("Pool = %+v, want %+v", store.Pool, want)
+	}
+}
+
 func TestStore_Connect(t *testing.T) {
 	store := NewStore()
 	store.DSN = "invalid-dsn"
@@ -125,6 +140,11 @@ func TestStore_Ensure(t *testing.T) {
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migration_runs").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS stored_env").WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, col := range []string{"method", "url", "started_at", "duration_ms", "headers_json", "run_id"} {
+		mock.ExpectExec("ALTER TABLE migration_runs ADD COLUMN IF NOT EXISTS " + col).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS applied_at").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err = store.Ensure(th)
 	if err != nil {
@@ -156,8 +176,8 @@ func TestStore_Apply(t *testing.T) {
 			name:    "successful apply",
 			version: 1,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO schema_migrations\\(version\\) VALUES\\(\\$1\\) ON CONFLICT DO NOTHING").
-					WithArgs(1).
+				mock.ExpectExec("INSERT INTO schema_migrations\\(version, applied_at\\) VALUES\\(\\$1, \\$2\\) ON CONFLICT DO NOTHING").
+					WithArgs(1, sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			wantErr: false,
@@ -166,8 +186,8 @@ func TestStore_Apply(t *testing.T) {
 			name:    "database error",
 			version: 2,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO schema_migrations\\(version\\) VALUES\\(\\$1\\) ON CONFLICT DO NOTHING").
-					WithArgs(2).
+				mock.ExpectExec("INSERT INTO schema_migrations\\(version, applied_at\\) VALUES\\(\\$1, \\$2\\) ON CONFLICT DO NOTHING").
+					WithArgs(2, sqlmock.AnyArg()).
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -759,8 +779,8 @@ func TestStore_RecordRun(t *testing.T) {
 			env:       map[string]string{"KEY": "value"},
 			failed:    false,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7\\)").
-					WithArgs(1, "up", 200, strPtr("response body"), strPtr(`{"KEY":"value"}`), false, sqlmock.AnyArg()).
+				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10,\\$11,\\$12,\\$13\\)").
+					WithArgs(1, "up", 200, strPtr("response body"), strPtr(`{"KEY":"value"}`), false, sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			wantErr: false,
@@ -774,8 +794,8 @@ func TestStore_RecordRun(t *testing.T) {
 			env:       map[string]string{},
 			failed:    true,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7\\)").
-					WithArgs(2, "down", 404, nil, nil, true, sqlmock.AnyArg()).
+				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10,\\$11,\\$12,\\$13\\)").
+					WithArgs(2, "down", 404, nil, nil, true, sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil).
 					WillReturnResult(sqlmock.NewResult(2, 1))
 			},
 			wantErr: false,
@@ -789,8 +809,8 @@ func TestStore_RecordRun(t *testing.T) {
 			env:       map[string]string{},
 			failed:    true,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7\\)").
-					WithArgs(3, "up", 500, nil, nil, true, sqlmock.AnyArg()).
+				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id\\) VALUES\\(\\$1,\\$2,\\$3,\\$4,\\$5,\\$6,\\$7,\\$8,\\$9,\\$10,\\$11,\\$12,\\$13\\)").
+					WithArgs(3, "up", 500, nil, nil, true, sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil).
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -800,7 +820,7 @@ func TestStore_RecordRun(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setup()
-			err := store.RecordRun(th, tt.version, tt.direction, tt.status, tt.body, tt.env, tt.failed)
+			err := store.RecordRun(th, tt.version, tt.direction, tt.status, tt.body, tt.env, tt.failed, RunDetails{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RecordRun() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -841,9 +861,11 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 			version: 2,
 			kv:      map[string]string{"KEY1": "value1"},
 			setup: func() {
+				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO stored_env\\(version, name, value\\) VALUES \\(\\$1,\\$2,\\$3\\) ON CONFLICT \\(version, name\\) DO UPDATE SET value = EXCLUDED.value").
 					WithArgs(2, "KEY1", "value1").
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -852,9 +874,10 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 			version: 3,
 			kv:      map[string]string{"KEY1": "value1", "KEY2": "value2"},
 			setup: func() {
-				// Note: the order of map iteration is not guaranteed, so we match on any args
+				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO stored_env\\(version, name, value\\) VALUES.*ON CONFLICT \\(version, name\\) DO UPDATE SET value = EXCLUDED.value").
 					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -863,8 +886,10 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 			version: 4,
 			kv:      map[string]string{"KEY1": "value1"},
 			setup: func() {
+				mock.ExpectBegin()
 				mock.ExpectExec("INSERT INTO stored_env\\(version, name, value\\) VALUES.*ON CONFLICT \\(version, name\\) DO UPDATE SET value = EXCLUDED.value").
 					WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -887,11 +912,12 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 
 func TestStore_InsertStoredEnv_TooLarge(t *testing.T) {
 	store := NewStore()
+	store.SetStoredEnvLimits(connector.StoredEnvLimits{MaxEntries: 100})
 	th := TableNames{StoredEnv: "stored_env"}
 
 	// Create map larger than limit
 	largeKV := make(map[string]string)
-	for i := 0; i < 10001; i++ {
+	for i := 0; i < 101; i++ {
 		largeKV[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
 	}
 
@@ -899,7 +925,7 @@ func TestStore_InsertStoredEnv_TooLarge(t *testing.T) {
 	if err == nil {
 		t.Error("InsertStoredEnv() should fail with too large map")
 	}
-	if err != nil && !strings.Contains(err.Error(), "stored environment map too large") {
+	if err != nil && !strings.Contains(err.Error(), "(limit: 100)") {
 		t.Errorf("InsertStoredEnv() error should mention size limit, got: %v", err)
 	}
 }
@@ -915,6 +941,7 @@ func TestStore_ListRuns(t *testing.T) {
 	th := TableNames{MigrationRuns: "migration_runs"}
 
 	testTime := time.Now().UTC()
+	durationMs := int64(42)
 
 	tests := []struct {
 		name    string
@@ -925,10 +952,10 @@ func TestStore_ListRuns(t *testing.T) {
 		{
 			name: "multiple runs",
 			setup: func() {
-				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM migration_runs ORDER BY id ASC").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at"}).
-						AddRow(1, 1, "up", 200, "body1", `{"key":"value"}`, false, testTime).
-						AddRow(2, 2, "down", 404, nil, nil, true, testTime))
+				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM migration_runs ORDER BY id ASC").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at", "method", "url", "started_at", "duration_ms", "headers_json", "run_id"}).
+						AddRow(1, 1, "up", 200, "body1", `{"key":"value"}`, false, testTime, "POST", "http://example.com/users", testTime, int64(42), nil, "run-1").
+						AddRow(2, 2, "down", 404, nil, nil, true, testTime, nil, nil, nil, nil, nil, nil))
 			},
 			want: []Run{
 				{
@@ -940,6 +967,11 @@ func TestStore_ListRuns(t *testing.T) {
 					Env:        map[string]string{"key": "value"},
 					Failed:     false,
 					RanAt:      testTime.Format(time.RFC3339Nano),
+					Method:     "POST",
+					URL:        "http://example.com/users",
+					StartedAt:  testTime.Format(time.RFC3339Nano),
+					DurationMs: &durationMs,
+					RunID:      "run-1",
 				},
 				{
 					ID:         2,
@@ -957,8 +989,8 @@ func TestStore_ListRuns(t *testing.T) {
 		{
 			name: "no runs",
 			setup: func() {
-				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM migration_runs ORDER BY id ASC").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at"}))
+				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM migration_runs ORDER BY id ASC").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at", "method", "url", "started_at", "duration_ms", "headers_json", "run_id"}))
 			},
 			want:    nil,
 			wantErr: false,
@@ -966,7 +998,7 @@ func TestStore_ListRuns(t *testing.T) {
 		{
 			name: "database error",
 			setup: func() {
-				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM migration_runs ORDER BY id ASC").
+				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM migration_runs ORDER BY id ASC").
 					WillReturnError(errors.New("database error"))
 			},
 			want:    nil,
@@ -997,3 +1029,161 @@ func TestStore_ListRuns(t *testing.T) {
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestStore_PruneRuns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	store := &Store{db: db, dialect: NewDialect()}
+	th := TableNames{MigrationRuns: "migration_runs"}
+	ranked := regexp.QuoteMeta("DELETE FROM migration_runs WHERE id IN (SELECT id FROM (SELECT id, ran_at, ROW_NUMBER() OVER (PARTITION BY version ORDER BY id DESC) AS rn FROM migration_runs) AS ranked WHERE rn > $1")
+	before := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(ranked+regexp.QuoteMeta(" AND ran_at < $2)")).WithArgs(3, before).WillReturnResult(sqlmock.NewResult(0, 5))
+	if n, err := store.PruneRuns(th, before, 3); err != nil || n != 5 {
+		t.Fatalf("PruneRuns(before, 3) = %d, %v", n, err)
+	}
+	mock.ExpectExec(ranked + regexp.QuoteMeta(")") + "$").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	if n, err := store.PruneRuns(th, time.Time{}, 1); err != nil || n != 2 {
+		t.Fatalf("PruneRuns(zero, 1) = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestStore_Lock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	store := &Store{db: db, dialect: NewDialect(), lockDB: db}
+	th := TableNames{SchemaMigrations: "app_schema_migrations"}
+	key := lockKey(th)
+	if key == lockKey(TableNames{SchemaMigrations: "schema_migrations"}) {
+		t.Fatalf("tables with another prefix share the lock key")
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(key).WillReturnResult(sqlmock.NewResult(0, 0))
+	unlock, err := store.Lock(th, time.Second)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+
+	// A lock held elsewhere makes the wait time out
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(key).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := store.Lock(th, 50*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Fatalf("expected a lock timeout, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+
+	if _, err := (&Store{}).Lock(th, time.Second); err == nil || !strings.Contains(err.Error(), "not connected") {
+		t.Fatalf("expected an unconnected store to fail, got %v", err)
+	}
+}
+
+// The lock is held on a connection of its own, so a pool of one connection still runs the
+// statements of the locked run instead of waiting for the lock to be released.
+func TestStore_Lock_OneConnectionPool(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	lockDB, lockMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+
+	store := &Store{db: db, dialect: NewDialect(), lockDB: lockDB}
+	th := TableNames{SchemaMigrations: "schema_migrations"}
+	lockMock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	lockMock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WillReturnResult(sqlmock.NewResult(1, 1))
+	lockMock.ExpectClose()
+	mock.ExpectClose()
+
+	unlock, err := store.Lock(th, time.Second)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- store.Apply(th, 1) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Apply() under the lock: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Apply() waited for the connection held by the lock")
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for _, m := range []sqlmock.Sqlmock{mock, lockMock} {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	}
+}
+
+// Statements in a WithTx transaction are not retried: the first failure aborts the transaction
+// and is returned as is, while outside one the store's retry policy applies.
+func TestStore_WithTx_DoesNotRetryStatements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	locked := errors.New("deadlock detected")
+	store := &Store{db: db, dialect: NewDialect(), retryConfig: &retry.Config{MaxRetries: 3, InitialDelay: time.Millisecond,
+		MaxDelay: time.Millisecond, BackoffFactor: 1, RetryableErrors: []string{"deadlock detected"}}}
+	th := TableNames{SchemaMigrations: "schema_migrations", MigrationRuns: "migration_runs", StoredEnv: "stored_env"}
+
+	mock.ExpectExec("INSERT INTO schema_migrations").WillReturnError(locked)
+	mock.ExpectExec("INSERT INTO schema_migrations").WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := store.Apply(th, 1); err != nil {
+		t.Fatalf("Apply() outside a transaction should be retried, got %v", err)
+	}
+
+	ops := map[string]struct {
+		stmt string
+		call func(tx *Store) error
+	}{
+		"Apply":           {"INSERT INTO schema_migrations", func(tx *Store) error { return tx.Apply(th, 2) }},
+		"InsertStoredEnv": {"INSERT INTO stored_env", func(tx *Store) error { return tx.InsertStoredEnv(th, 2, map[string]string{"k": "v"}) }},
+		"RecordRun": {"INSERT INTO migration_runs", func(tx *Store) error {
+			return tx.RecordRun(th, 2, "up", 200, nil, nil, false, RunDetails{})
+		}},
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectExec(op.stmt).WillReturnError(locked)
+			mock.ExpectRollback()
+			err := store.WithTx(op.call)
+			if !errors.Is(err, locked) || strings.Contains(err.Error(), "attempts") {
+				t.Fatalf("expected the statement error without retries, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/loykin/apirun/internal/store/connector"
+)
+
+// Backend is the interface a store driver implements; the built-in sqlite, postgresql and
+// mongo backends are Backends too. Connect may return a nil *sql.DB for backends that are not
+// database/sql based.
+type Backend = connector.Connector
+
+// DriverFactory builds a Backend from the config map of a registered driver
+// (the ToMap of Config.DriverConfig, nil when none is set).
+type DriverFactory func(cfg map[string]interface{}) (Backend, error)
+
+// Options is a DriverConfig holding the settings of a registered driver as a plain map.
+type Options map[string]interface{}
+
+// ToMap returns the options as is.
+func (o Options) ToMap() map[string]interface{} { return o }
+
+// In-memory registry of store driver factories keyed by normalized name.
+var drivers = map[string]DriverFactory{}
+
+// RegisterDriver registers a store driver factory under name (normalized to lower-case).
+// The built-in sqlite, postgresql and mongo drivers cannot be replaced.
+func RegisterDriver(name string, f DriverFactory) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" || f == nil || key == DriverSqlite || key == DriverPostgresql || key == DriverMongo {
+		return
+	}
+	drivers[key] = f
+}
+
+// lookupDriver returns the factory registered under name.
+func lookupDriver(name string) (DriverFactory, bool) {
+	f, ok := drivers[strings.ToLower(strings.TrimSpace(name))]
+	return f, ok
+}
+
+// IsRegisteredDriver reports whether a custom driver is registered under name.
+func IsRegisteredDriver(name string) bool {
+	_, ok := lookupDriver(name)
+	return ok
+}
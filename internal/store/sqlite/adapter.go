@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/loykin/apirun/internal/store/connector"
 )
@@ -75,6 +76,23 @@ func (a *Adapter) ListApplied(th connector.TableNames) ([]int, error) {
 	return a.store.ListApplied(sqliteTh)
 }
 
+func (a *Adapter) ListAppliedWithTime(th connector.TableNames) ([]connector.AppliedInfo, error) {
+	sqliteTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	applied, err := a.store.ListAppliedWithTime(sqliteTh)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]connector.AppliedInfo, len(applied))
+	for i, ai := range applied {
+		out[i] = connector.AppliedInfo(ai)
+	}
+	return out, nil
+}
+
 func (a *Adapter) Remove(th connector.TableNames, v int) error {
 	sqliteTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
@@ -84,6 +102,24 @@ func (a *Adapter) Remove(th connector.TableNames, v int) error {
 	return a.store.Remove(sqliteTh, v)
 }
 
+func (a *Adapter) SetChecksum(th connector.TableNames, v int, checksum string) error {
+	sqliteTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.SetChecksum(sqliteTh, v, checksum)
+}
+
+func (a *Adapter) ListChecksums(th connector.TableNames) (map[int]string, error) {
+	sqliteTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.ListChecksums(sqliteTh)
+}
+
 func (a *Adapter) SetVersion(th connector.TableNames, target int) error {
 	sqliteTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
@@ -93,13 +129,13 @@ func (a *Adapter) SetVersion(th connector.TableNames, target int) error {
 	return a.store.SetVersion(sqliteTh, target)
 }
 
-func (a *Adapter) RecordRun(th connector.TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool) error {
+func (a *Adapter) RecordRun(th connector.TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, details connector.RunDetails) error {
 	sqliteTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
 		MigrationRuns:    th.MigrationRuns,
 		StoredEnv:        th.StoredEnv,
 	}
-	return a.store.RecordRun(sqliteTh, version, direction, status, body, env, failed)
+	return a.store.RecordRun(sqliteTh, version, direction, status, body, env, failed, RunDetails(details))
 }
 
 func (a *Adapter) LoadEnv(th connector.TableNames, version int, direction string) (map[string]string, error) {
@@ -139,12 +175,16 @@ func (a *Adapter) DeleteStoredEnv(th connector.TableNames, version int) error {
 }
 
 func (a *Adapter) ListRuns(th connector.TableNames) ([]connector.Run, error) {
+	return a.ListRunsFiltered(th, connector.RunFilter{})
+}
+
+func (a *Adapter) ListRunsFiltered(th connector.TableNames, f connector.RunFilter) ([]connector.Run, error) {
 	sqliteTh := TableNames{
 		SchemaMigrations: th.SchemaMigrations,
 		MigrationRuns:    th.MigrationRuns,
 		StoredEnv:        th.StoredEnv,
 	}
-	sqliteRuns, err := a.store.ListRuns(sqliteTh)
+	sqliteRuns, err := a.store.ListRunsFiltered(sqliteTh, RunFilter{Version: f.Version, Direction: f.Direction, FailedOnly: f.FailedOnly, Limit: f.Limit, Since: f.Since})
 	if err != nil {
 		return nil, err
 	}
@@ -161,11 +201,34 @@ func (a *Adapter) ListRuns(th connector.TableNames) ([]connector.Run, error) {
 			Env:        r.Env,
 			Failed:     r.Failed,
 			RanAt:      r.RanAt,
+			Method:     r.Method,
+			URL:        r.URL,
+			StartedAt:  r.StartedAt,
+			DurationMs: r.DurationMs,
+			Headers:    r.Headers,
+			RunID:      r.RunID,
 		}
 	}
 	return runs, nil
 }
 
+func (a *Adapter) PruneRuns(th connector.TableNames, before time.Time, keepLastPerVersion int) (int64, error) {
+	sqliteTh := TableNames{
+		SchemaMigrations: th.SchemaMigrations,
+		MigrationRuns:    th.MigrationRuns,
+		StoredEnv:        th.StoredEnv,
+	}
+	return a.store.PruneRuns(sqliteTh, before, keepLastPerVersion)
+}
+
+func (a *Adapter) SetStoredEnvLimits(l connector.StoredEnvLimits) {
+	a.store.SetStoredEnvLimits(l)
+}
+
+func (a *Adapter) WithTx(fn func(tx connector.Connector) error) error {
+	return a.store.WithTx(func(s *Store) error { return fn(&Adapter{store: s}) })
+}
+
 func (a *Adapter) Close() error {
 	return a.store.Close()
 }
@@ -8,10 +8,15 @@ const (
 
 type Config struct {
 	Path string
+	// Memory keeps the database in memory instead of a file, e.g. for tests. Path then names
+	// the database: stores opened with the same name share it while any of them is open.
+	// Without a name every store gets a database of its own.
+	Memory bool
 }
 
 func (c *Config) ToMap() map[string]interface{} {
 	return map[string]interface{}{
-		"path": c.Path,
+		"path":   c.Path,
+		"memory": c.Memory,
 	}
 }
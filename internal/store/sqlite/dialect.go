@@ -3,6 +3,7 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/loykin/apirun/internal/constants"
@@ -74,10 +75,20 @@ func (s *Dialect) Connect(dsn string) (*sql.DB, error) {
 	db.SetMaxIdleConns(constants.DefaultSQLiteMaxIdleConns)   // Keep one idle connection
 	db.SetConnMaxLifetime(constants.DefaultSQLiteLifetime)    // Longer lifetime for SQLite
 	db.SetConnMaxIdleTime(constants.DefaultSQLiteIdleTime)    // Longer idle time for SQLite
+	if isMemoryDSN(dsn) {
+		// An in-memory database only lives while a connection to it is open: never recycle it
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	}
 
 	return db, nil
 }
 
+// isMemoryDSN reports whether dsn opens an in-memory database.
+func isMemoryDSN(dsn string) bool {
+	return dsn == ":memory:" || strings.Contains(dsn, "mode=memory")
+}
+
 // GetEnsureStatements returns SQLite-specific table creation statements
 func (s *Dialect) GetEnsureStatements(schemaMigrations, migrationRuns, storedEnv string) []string {
 	return []string{
@@ -87,6 +98,18 @@ func (s *Dialect) GetEnsureStatements(schemaMigrations, migrationRuns, storedEnv
 	}
 }
 
+// GetRunDetailColumns returns the nullable migration_runs columns added after the original
+// schema. Ensure adds any of them that an existing table lacks.
+func (s *Dialect) GetRunDetailColumns() []string {
+	return []string{"method TEXT NULL", "url TEXT NULL", "started_at TEXT NULL", "duration_ms INTEGER NULL", "headers_json TEXT NULL", "run_id TEXT NULL"}
+}
+
+// GetSchemaMigrationColumns returns the nullable schema_migrations columns added after the
+// original schema. Ensure adds any of them that an existing table lacks.
+func (s *Dialect) GetSchemaMigrationColumns() []string {
+	return []string{"checksum TEXT NULL", "applied_at TEXT NULL"}
+}
+
 // GetDriverName returns the driver name for logging
 func (s *Dialect) GetDriverName() string {
 	return "sqlite"
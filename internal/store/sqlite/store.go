@@ -6,11 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/loykin/apirun/internal/common"
 	"github.com/loykin/apirun/internal/retry"
+	"github.com/loykin/apirun/internal/store/connector"
 )
 
 // Run represents a single execution record from the migration_runs table.
@@ -23,6 +26,38 @@ type Run struct {
 	Env        map[string]string
 	Failed     bool
 	RanAt      string
+	Method     string
+	URL        string
+	StartedAt  string
+	DurationMs *int64
+	Headers    map[string]string
+	RunID      string
+}
+
+// AppliedInfo is an applied version and when it was applied; AppliedAt is zero for
+// versions applied before the time was recorded.
+type AppliedInfo struct {
+	Version   int
+	AppliedAt time.Time
+}
+
+// RunDetails carries the request metadata recorded alongside a migration run.
+type RunDetails struct {
+	Method     string
+	URL        string
+	StartedAt  time.Time
+	DurationMs int64
+	Headers    map[string]string
+	RunID      string
+}
+
+// RunFilter narrows ListRunsFiltered; zero values do not filter.
+type RunFilter struct {
+	Version    int
+	Direction  string
+	FailedOnly bool
+	Limit      int
+	Since      time.Time
 }
 
 // TableNames represents database table names
@@ -37,6 +72,16 @@ type Store struct {
 	dialect     *Dialect
 	DSN         string
 	retryConfig *retry.Config
+	storedEnv   connector.StoredEnvLimits
+	// tx is set on the copies passed to WithTx callbacks
+	tx *sql.Tx
+}
+
+// queryer is the part of *sql.DB and *sql.Tx the store queries use.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // NewStore creates a new SQLite store
@@ -53,12 +98,23 @@ func (s *Store) Load(config map[string]interface{}) error {
 		s.DSN = dsn
 		return nil
 	}
-	if path, ok := config["path"].(string); ok && path != "" {
+	path, _ := config["path"].(string)
+	if memory, _ := config["memory"].(bool); memory {
+		if path == "" {
+			path = fmt.Sprintf("apirun-memory-%d", memoryDBSeq.Add(1))
+		}
+		s.DSN = fmt.Sprintf("file:%s?mode=memory&cache=shared&_busy_timeout=%d&%s", path, busyTimeoutMS, foreignKeysParam)
+		return nil
+	}
+	if path != "" {
 		s.DSN = fmt.Sprintf("file:%s?_busy_timeout=%d&%s", path, busyTimeoutMS, foreignKeysParam)
 	}
 	return nil
 }
 
+// memoryDBSeq numbers the unnamed in-memory databases so each store gets its own.
+var memoryDBSeq atomic.Int64
+
 // Connect establishes a connection to SQLite using the adapter
 func (s *Store) Connect() (*sql.DB, error) {
 	if s.DSN == "" {
@@ -90,6 +146,38 @@ func (s *Store) Close() error {
 	return nil
 }
 
+// WithTx calls fn with a copy of s whose statements run in one transaction, committed when fn
+// returns nil and rolled back otherwise. Within a transaction fn gets s itself.
+func (s *Store) WithTx(fn func(tx *Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin SQLite transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	txs := *s
+	txs.tx = tx
+	// A failed statement aborts the transaction, so statements in it are not retried.
+	txs.retryConfig = retry.NoRetryConfig()
+	if err := fn(&txs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit SQLite transaction: %w", err)
+	}
+	return nil
+}
+
+// conn returns the transaction of a WithTx copy, else the database.
+func (s *Store) conn() queryer {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
 // Ensure creates the necessary tables using SQLite-specific schema
 func (s *Store) Ensure(th TableNames) error {
 	logger := common.GetLogger().WithStore("sqlite")
@@ -98,16 +186,61 @@ func (s *Store) Ensure(th TableNames) error {
 	stmts := s.dialect.GetEnsureStatements(th.SchemaMigrations, th.MigrationRuns, th.StoredEnv)
 	for i, q := range stmts {
 		logger.Debug("executing schema creation statement", "table_index", i+1, "sql", q)
-		if _, err := s.db.Exec(q); err != nil {
+		if _, err := s.conn().Exec(q); err != nil {
 			logger.Error("failed to create table in schema setup", "error", err, "table_index", i+1, "sql", q)
 			return fmt.Errorf("failed to create table %d in schema setup: %w", i+1, err)
 		}
 	}
+	if err := s.ensureColumns(th.MigrationRuns, s.dialect.GetRunDetailColumns()); err != nil {
+		logger.Error("failed to upgrade migration runs table", "error", err)
+		return err
+	}
+	if err := s.ensureColumns(th.SchemaMigrations, s.dialect.GetSchemaMigrationColumns()); err != nil {
+		logger.Error("failed to upgrade schema migrations table", "error", err)
+		return err
+	}
 	logger.Info("SQLite database schema ensured successfully")
 	return nil
 }
 
-// Apply inserts a migration version into the schema_migrations table
+// ensureColumns adds the columns missing from tables created by older versions.
+func (s *Store) ensureColumns(table string, columns []string) error {
+	rows, err := s.conn().Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s columns: %w", table, err)
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			ctype   string
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan %s columns: %w", table, err)
+		}
+		existing[name] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating %s columns: %w", table, err)
+	}
+	for _, col := range columns {
+		if existing[strings.Fields(col)[0]] {
+			continue
+		}
+		if _, err := s.conn().Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, col)); err != nil {
+			return fmt.Errorf("failed to add column %q to %s: %w", col, table, err)
+		}
+	}
+	return nil
+}
+
+// Apply inserts a migration version into the schema_migrations table, recording when it was applied
 func (s *Store) Apply(th TableNames, v int) error {
 	logger := common.GetLogger().WithStore(s.dialect.GetDriverName()).WithVersion(v)
 	logger.Debug("applying migration version")
@@ -115,16 +248,17 @@ func (s *Store) Apply(th TableNames, v int) error {
 	var q string
 	upsertClause := s.dialect.GetUpsertClause()
 	if upsertClause != "" {
-		q = fmt.Sprintf("INSERT %s INTO %s(version) VALUES(%s)",
-			upsertClause, th.SchemaMigrations, s.dialect.GetPlaceholder())
+		q = fmt.Sprintf("INSERT %s INTO %s(version, applied_at) VALUES(%s, %s)",
+			upsertClause, th.SchemaMigrations, s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder())
 	} else {
-		q = fmt.Sprintf("INSERT INTO %s(version) VALUES(%s) ON CONFLICT DO NOTHING",
-			th.SchemaMigrations, s.dialect.GetPlaceholder())
+		q = fmt.Sprintf("INSERT INTO %s(version, applied_at) VALUES(%s, %s) ON CONFLICT DO NOTHING",
+			th.SchemaMigrations, s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder())
 	}
+	appliedAt := s.dialect.ConvertTimeToStorage(time.Now().UTC())
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
-		return s.db.Exec(q, v)
+		return s.conn().Exec(q, v, appliedAt)
 	})
 	if err != nil {
 		logger.Error("failed to apply migration version", "error", err)
@@ -146,7 +280,7 @@ func (s *Store) IsApplied(th TableNames, v int) (bool, error) {
 	var scanErr error
 	ctx := context.Background()
 	err := retry.WithRetry(ctx, s.retryConfig, func() error {
-		scanErr = s.db.QueryRow(q, v).Scan(&result)
+		scanErr = s.conn().QueryRow(q, v).Scan(&result)
 		return scanErr
 	})
 	if errors.Is(scanErr, sql.ErrNoRows) {
@@ -169,7 +303,7 @@ func (s *Store) CurrentVersion(th TableNames) (int, error) {
 	var version int
 	ctx := context.Background()
 	err := retry.WithRetry(ctx, s.retryConfig, func() error {
-		return s.db.QueryRow(q).Scan(&version)
+		return s.conn().QueryRow(q).Scan(&version)
 	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get current version: %w", err)
@@ -183,7 +317,7 @@ func (s *Store) ListApplied(th TableNames) ([]int, error) {
 
 	ctx := context.Background()
 	rows, err := retry.WithRetryQuery(ctx, s.retryConfig, func() (*sql.Rows, error) {
-		return s.db.Query(q)
+		return s.conn().Query(q)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
@@ -205,13 +339,89 @@ func (s *Store) ListApplied(th TableNames) ([]int, error) {
 	return versions, nil
 }
 
+// ListAppliedWithTime returns the applied migration versions with the time each was applied
+func (s *Store) ListAppliedWithTime(th TableNames) ([]AppliedInfo, error) {
+	q := fmt.Sprintf("SELECT version, applied_at FROM %s ORDER BY version", th.SchemaMigrations)
+
+	ctx := context.Background()
+	rows, err := retry.WithRetryQuery(ctx, s.retryConfig, func() (*sql.Rows, error) {
+		return s.conn().Query(q)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var applied []AppliedInfo
+	for rows.Next() {
+		var version int
+		var appliedAt sql.NullString
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		info := AppliedInfo{Version: version}
+		if appliedAt.Valid {
+			// Rows written by other tools may hold another format; leave those zero
+			info.AppliedAt, _ = time.Parse(time.RFC3339Nano, appliedAt.String)
+		}
+		applied = append(applied, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// SetChecksum stores the migration file checksum of applied version v
+func (s *Store) SetChecksum(th TableNames, v int, checksum string) error {
+	q := fmt.Sprintf("UPDATE %s SET checksum = ? WHERE version = ?", th.SchemaMigrations)
+
+	ctx := context.Background()
+	_, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
+		return s.conn().Exec(q, checksum, v)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record checksum of migration version %d: %w", v, err)
+	}
+	return nil
+}
+
+// ListChecksums returns the recorded checksums by version; versions without one are omitted
+func (s *Store) ListChecksums(th TableNames) (map[int]string, error) {
+	q := fmt.Sprintf("SELECT version, checksum FROM %s WHERE checksum IS NOT NULL", th.SchemaMigrations)
+
+	ctx := context.Background()
+	rows, err := retry.WithRetryQuery(ctx, s.retryConfig, func() (*sql.Rows, error) {
+		return s.conn().Query(q)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration checksums: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	sums := map[int]string{}
+	for rows.Next() {
+		var version int
+		var sum string
+		if err := rows.Scan(&version, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan migration checksum: %w", err)
+		}
+		sums[version] = sum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating migration checksums: %w", err)
+	}
+	return sums, nil
+}
+
 // Remove removes a migration version from the schema_migrations table
 func (s *Store) Remove(th TableNames, v int) error {
 	q := fmt.Sprintf("DELETE FROM %s WHERE version = %s", th.SchemaMigrations, s.dialect.GetPlaceholder())
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
-		return s.db.Exec(q, v)
+		return s.conn().Exec(q, v)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to remove migration version %d: %w", v, err)
@@ -238,7 +448,7 @@ func (s *Store) SetVersion(th TableNames, target int) error {
 
 	ctx := context.Background()
 	_, err = retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
-		return s.db.Exec(q, target)
+		return s.conn().Exec(q, target)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to set version to %d: %w", target, err)
@@ -255,7 +465,7 @@ func (s *Store) LoadEnv(th TableNames, version int, direction string) (map[strin
 	var scanErr error
 	ctx := context.Background()
 	err := retry.WithRetry(ctx, s.retryConfig, func() error {
-		scanErr = s.db.QueryRow(q, version, direction).Scan(&envJSON)
+		scanErr = s.conn().QueryRow(q, version, direction).Scan(&envJSON)
 		return scanErr
 	})
 	if errors.Is(scanErr, sql.ErrNoRows) {
@@ -282,7 +492,7 @@ func (s *Store) LoadStoredEnv(th TableNames, version int) (map[string]string, er
 
 	ctx := context.Background()
 	rows, err := retry.WithRetryQuery(ctx, s.retryConfig, func() (*sql.Rows, error) {
-		return s.db.Query(q, version)
+		return s.conn().Query(q, version)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to load stored env for version %d: %w", version, err)
@@ -310,7 +520,7 @@ func (s *Store) DeleteStoredEnv(th TableNames, version int) error {
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
-		return s.db.Exec(q, version)
+		return s.conn().Exec(q, version)
 	})
 	if err != nil {
 		return fmt.Errorf("failed to delete stored env for version %d: %w", version, err)
@@ -318,8 +528,34 @@ func (s *Store) DeleteStoredEnv(th TableNames, version int) error {
 	return nil
 }
 
+// PruneRuns deletes the runs recorded before before (zero = any age), except the newest
+// keepLastPerVersion runs of each version, in a single DELETE.
+func (s *Store) PruneRuns(th TableNames, before time.Time, keepLastPerVersion int) (int64, error) {
+	q := fmt.Sprintf("DELETE FROM %[1]s WHERE id IN (SELECT id FROM (SELECT id, ran_at, ROW_NUMBER() OVER (PARTITION BY version ORDER BY id DESC) AS rn FROM %[1]s) AS ranked WHERE rn > ?", th.MigrationRuns)
+	args := []interface{}{keepLastPerVersion}
+	if !before.IsZero() {
+		q += " AND julianday(ran_at) < julianday(?)"
+		args = append(args, s.dialect.ConvertTimeToStorage(before.UTC()))
+	}
+	q += ")"
+
+	ctx := context.Background()
+	res, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
+		return s.conn().Exec(q, args...)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune migration runs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune migration runs: %w", err)
+	}
+	common.GetLogger().WithStore("sqlite").Info("pruned migration runs", "deleted", n, "before", before, "keep_last", keepLastPerVersion)
+	return n, nil
+}
+
 // RecordRun records a migration run with SQLite-specific type handling
-func (s *Store) RecordRun(th TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool) error {
+func (s *Store) RecordRun(th TableNames, version int, direction string, status int, body *string, env map[string]string, failed bool, details RunDetails) error {
 	logger := common.GetLogger().WithStore("sqlite").WithVersion(version)
 	logger.Debug("recording migration run", "direction", direction, "status", status, "failed", failed)
 
@@ -336,16 +572,33 @@ func (s *Store) RecordRun(th TableNames, version int, direction string, status i
 
 	ranAt := s.dialect.ConvertTimeToStorage(time.Now().UTC())
 	failedVal := s.dialect.ConvertBoolToStorage(failed)
+	method, url := nullIfEmpty(details.Method), nullIfEmpty(details.URL)
+	var startedAt, durationMs interface{}
+	if !details.StartedAt.IsZero() {
+		startedAt = s.dialect.ConvertTimeToStorage(details.StartedAt.UTC())
+		durationMs = details.DurationMs
+	}
+	var headersJSON *string
+	if len(details.Headers) > 0 {
+		b, err := json.Marshal(details.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal response headers for migration run record (version %d, direction %s): %w", version, direction, err)
+		}
+		h := string(b)
+		headersJSON = &h
+	}
 
-	q := fmt.Sprintf("INSERT INTO %s(version, direction, status_code, body, env_json, failed, ran_at) VALUES(%s,%s,%s,%s,%s,%s,%s)",
+	q := fmt.Sprintf("INSERT INTO %s(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id) VALUES(%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)",
 		th.MigrationRuns,
 		s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(),
 		s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(),
+		s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(),
+		s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(), s.dialect.GetPlaceholder(),
 		s.dialect.GetPlaceholder())
 
 	ctx := context.Background()
 	_, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
-		return s.db.Exec(q, version, direction, status, body, envJSON, failedVal, ranAt)
+		return s.conn().Exec(q, version, direction, status, body, envJSON, failedVal, ranAt, method, url, startedAt, durationMs, headersJSON, nullIfEmpty(details.RunID))
 	})
 	if err != nil {
 		logger.Error("failed to record migration run", "error", err)
@@ -356,10 +609,14 @@ func (s *Store) RecordRun(th TableNames, version int, direction string, status i
 	return nil
 }
 
-// InsertStoredEnv inserts stored environment variables
+// SetStoredEnvLimits sets the cap and batch size used by InsertStoredEnv.
+func (s *Store) SetStoredEnvLimits(l connector.StoredEnvLimits) {
+	s.storedEnv = l
+}
+
+// InsertStoredEnv inserts stored environment variables in batched multi-row statements within
+// one transaction, so a failing batch leaves none of kv stored.
 func (s *Store) InsertStoredEnv(th TableNames, version int, kv map[string]string) error {
-	const maxStoredEnvVars = 10000
-	const maxCapacity = maxStoredEnvVars * 3
 	logger := common.GetLogger().WithStore("sqlite").WithVersion(version)
 	logger.Debug("inserting stored environment variables", "count", len(kv))
 
@@ -367,46 +624,84 @@ func (s *Store) InsertStoredEnv(th TableNames, version int, kv map[string]string
 		return nil
 	}
 
-	if len(kv) > maxStoredEnvVars {
-		err := fmt.Errorf("cannot store more than %d environment variables (got: %d)", maxStoredEnvVars, len(kv))
-		logger.Error("too many environment variables", "max", maxStoredEnvVars, "got", len(kv), "error", err)
+	batches, err := s.storedEnv.Batches(kv)
+	if err != nil {
+		logger.Error("too many environment variables", "got", len(kv), "error", err)
 		return err
 	}
 
-	valuesClauses := make([]string, 0, len(kv))
-	args := make([]interface{}, 0, maxCapacity)
-
-	for name, value := range kv {
-		valuesClauses = append(valuesClauses, "(?,?,?)")
-		args = append(args, version, name, value)
-	}
-
-	q := fmt.Sprintf("INSERT OR REPLACE INTO %s(version, name, value) VALUES %s",
-		th.StoredEnv, strings.Join(valuesClauses, ","))
-
 	ctx := context.Background()
-	_, err := retry.WithRetryExec(ctx, s.retryConfig, func() (sql.Result, error) {
-		return s.db.Exec(q, args...)
+	err = retry.WithRetry(ctx, s.retryConfig, func() error {
+		return s.WithTx(func(tx *Store) error {
+			for _, names := range batches {
+				valuesClauses := make([]string, 0, len(names))
+				args := make([]interface{}, 0, len(names)*3)
+				for _, name := range names {
+					valuesClauses = append(valuesClauses, "(?,?,?)")
+					args = append(args, version, name, kv[name])
+				}
+				q := fmt.Sprintf("INSERT OR REPLACE INTO %s(version, name, value) VALUES %s",
+					th.StoredEnv, strings.Join(valuesClauses, ","))
+				if _, err := tx.conn().Exec(q, args...); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 	})
 	if err != nil {
 		logger.Error("failed to insert stored environment", "error", err)
 		return fmt.Errorf("failed to insert stored environment for version %d: %w", version, err)
 	}
 
-	logger.Info("stored environment variables inserted successfully")
+	logger.Info("stored environment variables inserted successfully", "batches", len(batches))
 	return nil
 }
 
 // ListRuns returns migration run history with SQLite-specific type handling
 func (s *Store) ListRuns(th TableNames) ([]Run, error) {
-	logger := common.GetLogger().WithStore("sqlite")
-	logger.Debug("listing migration runs")
+	return s.ListRunsFiltered(th, RunFilter{})
+}
 
-	q := fmt.Sprintf("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM %s ORDER BY id ASC", th.MigrationRuns)
+// ListRunsFiltered returns the runs matching f ordered by id ASC; with f.Limit only the newest
+// matching runs are returned.
+func (s *Store) ListRunsFiltered(th TableNames, f RunFilter) ([]Run, error) {
+	logger := common.GetLogger().WithStore("sqlite")
+	logger.Debug("listing migration runs", "version", f.Version, "direction", f.Direction, "failed_only", f.FailedOnly, "limit", f.Limit)
+
+	var where []string
+	var args []interface{}
+	if f.Version > 0 {
+		where = append(where, "version = ?")
+		args = append(args, f.Version)
+	}
+	if f.Direction != "" {
+		where = append(where, "direction = ?")
+		args = append(args, f.Direction)
+	}
+	if f.FailedOnly {
+		where = append(where, "failed = ?")
+		args = append(args, s.dialect.ConvertBoolToStorage(true))
+	}
+	if !f.Since.IsZero() {
+		// ran_at is RFC3339 text whose fraction length varies, so compare it as a time
+		where = append(where, "julianday(ran_at) >= julianday(?)")
+		args = append(args, s.dialect.ConvertTimeToStorage(f.Since.UTC()))
+	}
+	q := fmt.Sprintf("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM %s", th.MigrationRuns)
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	if f.Limit > 0 {
+		q += " ORDER BY id DESC LIMIT ?"
+		args = append(args, f.Limit)
+	} else {
+		q += " ORDER BY id ASC"
+	}
 
 	ctx := context.Background()
 	rows, err := retry.WithRetryQuery(ctx, s.retryConfig, func() (*sql.Rows, error) {
-		return s.db.Query(q)
+		return s.conn().Query(q, args...)
 	})
 	if err != nil {
 		logger.Error("failed to query migration runs", "error", err)
@@ -421,8 +716,12 @@ func (s *Store) ListRuns(th TableNames) ([]Run, error) {
 		var envJSON sql.NullString
 		var ranAt string
 		var failed int64
+		var method, url, startedAt sql.NullString
+		var durationMs sql.NullInt64
+		var headersJSON, runID sql.NullString
 
-		err := rows.Scan(&run.ID, &run.Version, &run.Direction, &run.StatusCode, &body, &envJSON, &failed, &ranAt)
+		err := rows.Scan(&run.ID, &run.Version, &run.Direction, &run.StatusCode, &body, &envJSON, &failed, &ranAt,
+			&method, &url, &startedAt, &durationMs, &headersJSON, &runID)
 		if err != nil {
 			logger.Error("failed to scan migration run", "error", err)
 			return nil, fmt.Errorf("failed to scan migration run: %w", err)
@@ -443,6 +742,16 @@ func (s *Store) ListRuns(th TableNames) ([]Run, error) {
 
 		run.Failed = s.dialect.ConvertBoolFromStorage(failed)
 		run.RanAt = s.dialect.ConvertTimeFromStorage(ranAt)
+		run.Method = method.String
+		run.URL = url.String
+		run.StartedAt = startedAt.String
+		if durationMs.Valid {
+			run.DurationMs = &durationMs.Int64
+		}
+		if headersJSON.Valid && headersJSON.String != "" {
+			_ = json.Unmarshal([]byte(headersJSON.String), &run.Headers)
+		}
+		run.RunID = runID.String
 
 		runs = append(runs, run)
 	}
@@ -452,6 +761,17 @@ func (s *Store) ListRuns(th TableNames) ([]Run, error) {
 		return nil, fmt.Errorf("error iterating migration runs: %w", err)
 	}
 
+	if f.Limit > 0 {
+		slices.Reverse(runs)
+	}
 	logger.Debug("migration runs listed successfully", "count", len(runs))
 	return runs, nil
 }
+
+// nullIfEmpty maps an empty string to NULL for optional text columns.
+func nullIfEmpty(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
@@ -7,8 +7,11 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/loykin/apirun/internal/retry"
+	"github.com/loykin/apirun/internal/store/connector"
 )
 
 func TestNewStore(t *testing.T) {
@@ -62,6 +65,11 @@ func TestStore_Load(t *testing.T) {
 			config: map[string]interface{}{"dsn": "custom-dsn", "path": "/tmp/test.db"},
 			want:   "custom-dsn",
 		},
+		{
+			name:   "named memory database",
+			config: map[string]interface{}{"path": "tests", "memory": true},
+			want:   "file:tests?mode=memory&cache=shared&_busy_timeout=5000&_fk=1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -78,6 +86,15 @@ func TestStore_Load(t *testing.T) {
 	}
 }
 
+func TestStore_Load_UnnamedMemoryDatabasesAreDistinct(t *testing.T) {
+	a, b := NewStore(), NewStore()
+	_ = a.Load(map[string]interface{}{"memory": true})
+	_ = b.Load(map[string]interface{}{"memory": true})
+	if !strings.Contains(a.DSN, "mode=memory") || a.DSN == b.DSN {
+		t.Fatalf("expected two distinct in-memory DSNs, got %q and %q", a.DSN, b.DSN)
+	}
+}
+
 func TestStore_Validate(t *testing.T) {
 	store := NewStore()
 	err := store.Validate()
@@ -158,6 +175,20 @@ func TestStore_Ensure(t *testing.T) {
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migration_runs").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS stored_env").WillReturnResult(sqlmock.NewResult(0, 0))
+	// Existing table from an older version lacks the run detail columns
+	cols := sqlmock.NewRows([]string{"cid", "name", "type", "notnull", "dflt_value", "pk"})
+	for i, name := range []string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at"} {
+		cols.AddRow(i, name, "TEXT", 0, nil, 0)
+	}
+	mock.ExpectQuery("PRAGMA table_info\\(migration_runs\\)").WillReturnRows(cols)
+	for _, col := range []string{"method", "url", "started_at", "duration_ms", "headers_json", "run_id"} {
+		mock.ExpectExec("ALTER TABLE migration_runs ADD COLUMN " + col).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	// ... and schema_migrations lacks the checksum and applied_at columns
+	mock.ExpectQuery("PRAGMA table_info\\(schema_migrations\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"cid", "name", "type", "notnull", "dflt_value", "pk"}).AddRow(0, "version", "INTEGER", 0, nil, 1))
+	mock.ExpectExec("ALTER TABLE schema_migrations ADD COLUMN checksum").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE schema_migrations ADD COLUMN applied_at").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err = store.Ensure(th)
 	if err != nil {
@@ -189,8 +220,8 @@ func TestStore_Apply(t *testing.T) {
 			name:    "successful apply",
 			version: 1,
 			setup: func() {
-				mock.ExpectExec("INSERT OR IGNORE INTO schema_migrations\\(version\\) VALUES\\(\\?\\)").
-					WithArgs(1).
+				mock.ExpectExec("INSERT OR IGNORE INTO schema_migrations\\(version, applied_at\\) VALUES\\(\\?, \\?\\)").
+					WithArgs(1, sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			wantErr: false,
@@ -199,8 +230,8 @@ func TestStore_Apply(t *testing.T) {
 			name:    "database error",
 			version: 2,
 			setup: func() {
-				mock.ExpectExec("INSERT OR IGNORE INTO schema_migrations\\(version\\) VALUES\\(\\?\\)").
-					WithArgs(2).
+				mock.ExpectExec("INSERT OR IGNORE INTO schema_migrations\\(version, applied_at\\) VALUES\\(\\?, \\?\\)").
+					WithArgs(2, sqlmock.AnyArg()).
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -792,8 +823,8 @@ func TestStore_RecordRun(t *testing.T) {
 			env:       map[string]string{"KEY": "value"},
 			failed:    false,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").
-					WithArgs(1, "up", 200, strPtr("response body"), strPtr(`{"KEY":"value"}`), 0, sqlmock.AnyArg()).
+				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").
+					WithArgs(1, "up", 200, strPtr("response body"), strPtr(`{"KEY":"value"}`), 0, sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			wantErr: false,
@@ -807,8 +838,8 @@ func TestStore_RecordRun(t *testing.T) {
 			env:       map[string]string{},
 			failed:    true,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").
-					WithArgs(2, "down", 404, nil, nil, 1, sqlmock.AnyArg()).
+				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").
+					WithArgs(2, "down", 404, nil, nil, 1, sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil).
 					WillReturnResult(sqlmock.NewResult(2, 1))
 			},
 			wantErr: false,
@@ -822,8 +853,8 @@ func TestStore_RecordRun(t *testing.T) {
 			env:       map[string]string{},
 			failed:    true,
 			setup: func() {
-				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").
-					WithArgs(3, "up", 500, nil, nil, 1, sqlmock.AnyArg()).
+				mock.ExpectExec("INSERT INTO migration_runs\\(version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id\\) VALUES\\(\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?,\\?\\)").
+					WithArgs(3, "up", 500, nil, nil, 1, sqlmock.AnyArg(), nil, nil, nil, nil, nil, nil).
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -833,7 +864,7 @@ func TestStore_RecordRun(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setup()
-			err := store.RecordRun(th, tt.version, tt.direction, tt.status, tt.body, tt.env, tt.failed)
+			err := store.RecordRun(th, tt.version, tt.direction, tt.status, tt.body, tt.env, tt.failed, RunDetails{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RecordRun() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -874,9 +905,11 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 			version: 2,
 			kv:      map[string]string{"KEY1": "value1"},
 			setup: func() {
+				mock.ExpectBegin()
 				mock.ExpectExec("INSERT OR REPLACE INTO stored_env\\(version, name, value\\) VALUES \\(\\?,\\?,\\?\\)").
 					WithArgs(2, "KEY1", "value1").
 					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -885,9 +918,10 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 			version: 3,
 			kv:      map[string]string{"KEY1": "value1", "KEY2": "value2"},
 			setup: func() {
-				// Note: the order of map iteration is not guaranteed, so we match on any args
+				mock.ExpectBegin()
 				mock.ExpectExec("INSERT OR REPLACE INTO stored_env\\(version, name, value\\) VALUES.*").
 					WillReturnResult(sqlmock.NewResult(1, 2))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -896,8 +930,10 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 			version: 4,
 			kv:      map[string]string{"KEY1": "value1"},
 			setup: func() {
+				mock.ExpectBegin()
 				mock.ExpectExec("INSERT OR REPLACE INTO stored_env\\(version, name, value\\) VALUES.*").
 					WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -920,11 +956,12 @@ func TestStore_InsertStoredEnv(t *testing.T) {
 
 func TestStore_InsertStoredEnv_TooLarge(t *testing.T) {
 	store := NewStore()
+	store.SetStoredEnvLimits(connector.StoredEnvLimits{MaxEntries: 100})
 	th := TableNames{StoredEnv: "stored_env"}
 
 	// Create map larger than limit
 	largeKV := make(map[string]string)
-	for i := 0; i < 10001; i++ {
+	for i := 0; i < 101; i++ {
 		largeKV[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
 	}
 
@@ -932,7 +969,7 @@ func TestStore_InsertStoredEnv_TooLarge(t *testing.T) {
 	if err == nil {
 		t.Error("InsertStoredEnv() should fail with too large map")
 	}
-	if err != nil && !strings.Contains(err.Error(), "cannot store more than") {
+	if err != nil && !strings.Contains(err.Error(), "(limit: 100)") {
 		t.Errorf("InsertStoredEnv() error should mention size limit, got: %v", err)
 	}
 }
@@ -948,6 +985,7 @@ func TestStore_ListRuns(t *testing.T) {
 	th := TableNames{MigrationRuns: "migration_runs"}
 
 	testTime := "2023-12-25T10:30:45.123456789Z"
+	durationMs := int64(42)
 
 	tests := []struct {
 		name    string
@@ -958,10 +996,10 @@ func TestStore_ListRuns(t *testing.T) {
 		{
 			name: "multiple runs",
 			setup: func() {
-				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM migration_runs ORDER BY id ASC").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at"}).
-						AddRow(1, 1, "up", 200, "body1", `{"key":"value"}`, int64(0), testTime).
-						AddRow(2, 2, "down", 404, nil, nil, int64(1), testTime))
+				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM migration_runs ORDER BY id ASC").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at", "method", "url", "started_at", "duration_ms", "headers_json", "run_id"}).
+						AddRow(1, 1, "up", 200, "body1", `{"key":"value"}`, int64(0), testTime, "POST", "http://example.com/users", testTime, int64(42), nil, "run-1").
+						AddRow(2, 2, "down", 404, nil, nil, int64(1), testTime, nil, nil, nil, nil, nil, nil))
 			},
 			want: []Run{
 				{
@@ -973,6 +1011,11 @@ func TestStore_ListRuns(t *testing.T) {
 					Env:        map[string]string{"key": "value"},
 					Failed:     false,
 					RanAt:      testTime,
+					Method:     "POST",
+					URL:        "http://example.com/users",
+					StartedAt:  testTime,
+					DurationMs: &durationMs,
+					RunID:      "run-1",
 				},
 				{
 					ID:         2,
@@ -990,8 +1033,8 @@ func TestStore_ListRuns(t *testing.T) {
 		{
 			name: "no runs",
 			setup: func() {
-				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM migration_runs ORDER BY id ASC").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at"}))
+				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM migration_runs ORDER BY id ASC").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "direction", "status_code", "body", "env_json", "failed", "ran_at", "method", "url", "started_at", "duration_ms", "headers_json", "run_id"}))
 			},
 			want:    nil,
 			wantErr: false,
@@ -999,7 +1042,7 @@ func TestStore_ListRuns(t *testing.T) {
 		{
 			name: "database error",
 			setup: func() {
-				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at FROM migration_runs ORDER BY id ASC").
+				mock.ExpectQuery("SELECT id, version, direction, status_code, body, env_json, failed, ran_at, method, url, started_at, duration_ms, headers_json, run_id FROM migration_runs ORDER BY id ASC").
 					WillReturnError(errors.New("database error"))
 			},
 			want:    nil,
@@ -1030,3 +1073,49 @@ func TestStore_ListRuns(t *testing.T) {
 func strPtr(s string) *string {
 	return &s
 }
+
+// Statements in a WithTx transaction are not retried: the first failure aborts the transaction
+// and is returned as is, while outside one the store's retry policy applies.
+func TestStore_WithTx_DoesNotRetryStatements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	locked := errors.New("database is locked")
+	store := &Store{db: db, dialect: NewDialect(), retryConfig: &retry.Config{MaxRetries: 3, InitialDelay: time.Millisecond,
+		MaxDelay: time.Millisecond, BackoffFactor: 1, RetryableErrors: []string{"database is locked"}}}
+	th := TableNames{SchemaMigrations: "schema_migrations", MigrationRuns: "migration_runs", StoredEnv: "stored_env"}
+
+	mock.ExpectExec("INSERT OR IGNORE INTO schema_migrations").WillReturnError(locked)
+	mock.ExpectExec("INSERT OR IGNORE INTO schema_migrations").WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := store.Apply(th, 1); err != nil {
+		t.Fatalf("Apply() outside a transaction should be retried, got %v", err)
+	}
+
+	ops := map[string]struct {
+		stmt string
+		call func(tx *Store) error
+	}{
+		"Apply":           {"INSERT OR IGNORE INTO schema_migrations", func(tx *Store) error { return tx.Apply(th, 2) }},
+		"InsertStoredEnv": {"INSERT OR REPLACE INTO stored_env", func(tx *Store) error { return tx.InsertStoredEnv(th, 2, map[string]string{"k": "v"}) }},
+		"RecordRun": {"INSERT INTO migration_runs", func(tx *Store) error {
+			return tx.RecordRun(th, 2, "up", 200, nil, nil, false, RunDetails{})
+		}},
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectExec(op.stmt).WillReturnError(locked)
+			mock.ExpectRollback()
+			err := store.WithTx(op.call)
+			if !errors.Is(err, locked) || strings.Contains(err.Error(), "attempts") {
+				t.Fatalf("expected the statement error without retries, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/loykin/apirun/internal/constants"
 	"github.com/loykin/apirun/internal/store/connector"
+	"github.com/loykin/apirun/internal/store/mongo"
 	"github.com/loykin/apirun/internal/store/postgresql"
 	"github.com/loykin/apirun/internal/store/sqlite"
 
@@ -23,6 +25,9 @@ type Store struct {
 	TableName connector.TableNames
 	Driver    string
 	connector connector.Connector
+	// noLock and lockTimeout are Config.Lock (negated) and Config.LockTimeout
+	noLock      bool
+	lockTimeout time.Duration
 }
 
 // Connect selects a connector based on Driver, loads config, connects, assigns DB/connector
@@ -42,8 +47,30 @@ func (s *Store) Connect(config Config) error {
 			_ = conn.Load(config.DriverConfig.ToMap())
 		}
 		s.Driver = DriverPostgresql
+	case DriverMongo:
+		conn = mongo.NewStore()
+		if config.DriverConfig != nil {
+			_ = conn.Load(config.DriverConfig.ToMap())
+		}
+		s.Driver = DriverMongo
 	default:
-		return fmt.Errorf("unknown store driver: %s", s.Driver)
+		factory, ok := lookupDriver(config.Driver)
+		if !ok {
+			return fmt.Errorf("unknown store driver: %s", config.Driver)
+		}
+		var cfg map[string]interface{}
+		if config.DriverConfig != nil {
+			cfg = config.DriverConfig.ToMap()
+		}
+		b, err := factory(cfg)
+		if err != nil {
+			return fmt.Errorf("store driver %s: %w", config.Driver, err)
+		}
+		if b == nil {
+			return fmt.Errorf("store driver %s: factory returned no backend", config.Driver)
+		}
+		conn = b
+		s.Driver = strings.ToLower(strings.TrimSpace(config.Driver))
 	}
 	db, err := conn.Connect()
 	if err != nil {
@@ -51,6 +78,11 @@ func (s *Store) Connect(config Config) error {
 	}
 	s.DB = db
 	s.connector = conn
+	s.noLock = config.Lock != nil && !*config.Lock
+	s.lockTimeout = config.LockTimeout
+	if l, ok := conn.(connector.StoredEnvLimiter); ok {
+		l.SetStoredEnvLimits(config.StoredEnv)
+	}
 	// ensure schema via connector
 	if err := s.EnsureSchema(); err != nil {
 		_ = s.Close()
@@ -122,6 +154,36 @@ func (s *Store) Close() error {
 	return nil
 }
 
+// WithTx calls fn with a Store whose writes are made in one transaction: committed when fn
+// returns nil, rolled back otherwise. fn must make its store calls through tx; with a custom
+// driver that does not implement connector.Transactor it gets s and the writes are not atomic.
+func (s *Store) WithTx(fn func(tx *Store) error) error {
+	t, ok := s.connector.(connector.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	return t.WithTx(func(c connector.Connector) error {
+		tx := *s
+		tx.connector = c
+		return fn(&tx)
+	})
+}
+
+// Lock takes the run lock of the store's tables, waiting at most Config.LockTimeout, and returns
+// the function releasing it. Without the lock (disabled, or a backend that is not a
+// connector.Locker) it returns at once.
+func (s *Store) Lock() (func() error, error) {
+	l, ok := s.connector.(connector.Locker)
+	if !ok || s.noLock {
+		return func() error { return nil }, nil
+	}
+	timeout := s.lockTimeout
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	return l.Lock(s.safeTableNames(), timeout)
+}
+
 // Apply records a version as applied (idempotent).
 func (s *Store) Apply(v int) error {
 	return s.connector.Apply(s.safeTableNames(), v)
@@ -158,16 +220,36 @@ func (s *Store) ListApplied() ([]int, error) {
 	return s.connector.ListApplied(s.safeTableNames())
 }
 
+// ListAppliedWithTime returns the applied versions with the time each was applied, ascending.
+func (s *Store) ListAppliedWithTime() ([]AppliedInfo, error) {
+	return s.connector.ListAppliedWithTime(s.safeTableNames())
+}
+
 func (s *Store) Remove(v int) error {
 	return s.connector.Remove(s.safeTableNames(), v)
 }
 
+// SetChecksum records the migration file checksum of applied version v.
+func (s *Store) SetChecksum(v int, checksum string) error {
+	return s.connector.SetChecksum(s.safeTableNames(), v, checksum)
+}
+
+// ListChecksums returns the recorded migration file checksums by version.
+func (s *Store) ListChecksums() (map[int]string, error) {
+	return s.connector.ListChecksums(s.safeTableNames())
+}
+
 func (s *Store) SetVersion(target int) error {
 	return s.connector.SetVersion(s.safeTableNames(), target)
 }
 
 func (s *Store) RecordRun(version int, direction string, status int, body *string, env map[string]string, failed bool) error {
-	return s.connector.RecordRun(s.safeTableNames(), version, direction, status, body, env, failed)
+	return s.RecordRunWithDetails(version, direction, status, body, env, failed, RunDetails{})
+}
+
+// RecordRunWithDetails records a migration run together with the request metadata of the executed step.
+func (s *Store) RecordRunWithDetails(version int, direction string, status int, body *string, env map[string]string, failed bool, details RunDetails) error {
+	return s.connector.RecordRun(s.safeTableNames(), version, direction, status, body, env, failed, details)
 }
 
 func (s *Store) LoadEnv(version int, direction string) (map[string]string, error) {
@@ -190,3 +272,26 @@ func (s *Store) DeleteStoredEnv(version int) error {
 func (s *Store) ListRuns() ([]connector.Run, error) {
 	return s.connector.ListRuns(s.safeTableNames())
 }
+
+// ListRunsFiltered returns the migration_runs records matching f, filtered by the database.
+func (s *Store) ListRunsFiltered(f RunFilter) ([]connector.Run, error) {
+	return s.connector.ListRunsFiltered(s.safeTableNames(), f)
+}
+
+// PruneRuns deletes old migration_runs records: those recorded before before (zero = any age),
+// except the newest keepLastPerVersion runs of each version. It returns how many runs were
+// deleted. Applied versions and stored env are left untouched. At least one of before and
+// keepLastPerVersion must be set, so that a call cannot clear the whole history by accident.
+func (s *Store) PruneRuns(before time.Time, keepLastPerVersion int) (int64, error) {
+	if keepLastPerVersion < 0 {
+		return 0, fmt.Errorf("prune: keep must not be negative, got %d", keepLastPerVersion)
+	}
+	if before.IsZero() && keepLastPerVersion == 0 {
+		return 0, fmt.Errorf("prune: set a cutoff time, a number of runs to keep per version, or both")
+	}
+	p, ok := s.connector.(connector.RunPruner)
+	if !ok {
+		return 0, fmt.Errorf("prune is not supported by store driver %q", s.Driver)
+	}
+	return p.PruneRuns(s.safeTableNames(), before, keepLastPerVersion)
+}
@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -39,9 +42,12 @@ func waitForPostgresDSN(dsn string, timeout time.Duration) error {
 	return lastErr
 }
 
-// Integration test with PostgreSQL via testcontainers
-func TestPostgresStore_BasicCRUD(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+// startPostgres runs a Postgres container for the test and returns its DSN; the test is skipped
+// where containers cannot run.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	startCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
 	defer cancel()
 
 	req := tc.ContainerRequest{
@@ -57,36 +63,37 @@ func TestPostgresStore_BasicCRUD(t *testing.T) {
 			wait.ForLog("database system is ready to accept connections"),
 		),
 	}
-	pg, err := tc.GenericContainer(ctx, tc.GenericContainerRequest{ContainerRequest: req, Started: true})
+	pg, err := tc.GenericContainer(startCtx, tc.GenericContainerRequest{ContainerRequest: req, Started: true})
 	if err != nil {
 		// Skip on CI envs that cannot run containers, rather than failing whole suite
 		t.Skipf("skipping Postgres container test: %v", err)
-		return
 	}
-	defer func() { _ = pg.Terminate(ctx) }()
+	t.Cleanup(func() { _ = pg.Terminate(ctx) })
 
 	host, err := pg.Host(ctx)
 	if err != nil {
-		_ = pg.Terminate(ctx)
 		t.Fatalf("container host: %v", err)
 	}
 	port, err := pg.MappedPort(ctx, "5432/tcp")
 	if err != nil {
-		_ = pg.Terminate(ctx)
 		t.Fatalf("container port: %v", err)
 	}
 	dsn := fmt.Sprintf("postgres://test:test@%s:%s/apirun_test?sslmode=disable", host, port.Port())
 
 	// Ensure DB is accepting connections before opening the store
 	if err := waitForPostgresDSN(dsn, TestDefaultTimeoutSeconds*time.Second); err != nil {
-		_ = pg.Terminate(ctx)
 		t.Fatalf("postgres not ready: %v", err)
 	}
+	return dsn
+}
+
+// Integration test with PostgreSQL via testcontainers
+func TestPostgresStore_BasicCRUD(t *testing.T) {
+	dsn := startPostgres(t)
 
 	var st Store
 	cfg := Config{Driver: DriverPostgresql, DriverConfig: &PostgresConfig{DSN: dsn}}
 	if err := st.Connect(cfg); err != nil {
-		_ = pg.Terminate(ctx)
 		t.Fatalf("Connect(Postgres): %v", err)
 	}
 	defer func() { _ = st.Close() }()
@@ -127,6 +134,10 @@ func TestPostgresStore_BasicCRUD(t *testing.T) {
 	if cur != 3 {
 		t.Fatalf("CurrentVersion=%d, want 3", cur)
 	}
+	infos, err := st.ListAppliedWithTime()
+	if err != nil || len(infos) != 3 || infos[0].Version != 1 || infos[0].AppliedAt.IsZero() {
+		t.Fatalf("ListAppliedWithTime unexpected: %+v err=%v", infos, err)
+	}
 
 	// Stored env CRUD
 	kv := map[string]string{"rid": "42", "user": "bob"}
@@ -205,6 +216,11 @@ func TestPostgresStore_BasicCRUD(t *testing.T) {
 	if !re.MatchString(r1.RanAt) || !re.MatchString(r2.RanAt) {
 		t.Fatalf("ran_at not RFC3339-like: r1=%q r2=%q", r1.RanAt, r2.RanAt)
 	}
+	// Filtering happens in SQL with numbered placeholders
+	failed, err := st.ListRunsFiltered(RunFilter{Version: 2, Direction: "up", FailedOnly: true, Limit: 1})
+	if err != nil || len(failed) != 1 || failed[0].ID != r2.ID {
+		t.Fatalf("ListRunsFiltered: %v %#v", err, failed)
+	}
 	// LoadEnv should return the env saved for version 1 up
 	m2, err := st.LoadEnv(1, "up")
 	if err != nil {
@@ -214,10 +230,120 @@ func TestPostgresStore_BasicCRUD(t *testing.T) {
 		t.Fatalf("LoadEnv mismatch: %#v", m2)
 	}
 
+	recent, err := st.ListRunsFiltered(RunFilter{Since: time.Now().Add(-time.Hour)})
+	if err != nil || len(recent) != len(runs) {
+		t.Fatalf("ListRunsFiltered(Since): %v %d runs, want %d", err, len(recent), len(runs))
+	}
+	// Keeping one run per version leaves the newest run of versions 1 and 2
+	if _, err := st.PruneRuns(time.Time{}, 1); err != nil {
+		t.Fatalf("PruneRuns: %v", err)
+	}
+	if left, err := st.ListRuns(); err != nil || len(left) != 2 || left[0].ID != r1.ID || left[1].ID != r2.ID {
+		t.Fatalf("runs after prune: %v %#v", err, left)
+	}
+
 	// Small sleep to ensure async operations flushed (timestamps etc.)
 	time.Sleep(100 * time.Millisecond)
 }
 
+// Two stores on the same tables, as two CI jobs would open them, hold the run lock in turn
+func TestPostgresStore_LockSerializesRuns(t *testing.T) {
+	dsn := startPostgres(t)
+	open := func(cfg Config) *Store {
+		cfg.Driver, cfg.DriverConfig = DriverPostgresql, &PostgresConfig{DSN: dsn}
+		st := &Store{}
+		if err := st.Connect(cfg); err != nil {
+			t.Fatalf("Connect(Postgres): %v", err)
+		}
+		t.Cleanup(func() { _ = st.Close() })
+		return st
+	}
+
+	var holders atomic.Int32
+	var overlap atomic.Bool
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, st := range []*Store{open(Config{}), open(Config{})} {
+		wg.Add(1)
+		go func(st *Store) {
+			defer wg.Done()
+			unlock, err := st.Lock()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if holders.Add(1) > 1 {
+				overlap.Store(true)
+			}
+			time.Sleep(300 * time.Millisecond)
+			holders.Add(-1)
+			errs <- unlock()
+		}(st)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("lock: %v", err)
+		}
+	}
+	if overlap.Load() {
+		t.Fatalf("the lock was held by both stores at once")
+	}
+
+	// A waiter gives up after its timeout; with the lock disabled nothing waits
+	holder := open(Config{})
+	unlock, err := holder.Lock()
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer func() { _ = unlock() }()
+	if _, err := open(Config{LockTimeout: 200 * time.Millisecond}).Lock(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a lock timeout, got %v", err)
+	}
+	off := false
+	release, err := open(Config{Lock: &off, LockTimeout: 200 * time.Millisecond}).Lock()
+	if err != nil {
+		t.Fatalf("disabled lock: %v", err)
+	}
+	_ = release()
+}
+
+// With max_open_conns: 1 the lock does not take the only pooled connection, so a locked run
+// can still record its versions
+func TestPostgresStore_LockWithOneConnection(t *testing.T) {
+	dsn := startPostgres(t)
+	st := &Store{}
+	if err := st.Connect(Config{Driver: DriverPostgresql, DriverConfig: &PostgresConfig{DSN: dsn, MaxOpenConns: 1}, LockTimeout: 5 * time.Second}); err != nil {
+		t.Fatalf("Connect(Postgres): %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	unlock, err := st.Lock()
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		if err := st.Apply(1); err != nil {
+			done <- err
+			return
+		}
+		done <- st.RecordRun(1, "up", 200, nil, nil, false)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("writes under the lock: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the locked run waited for the connection held by the lock")
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+}
+
 func TestPostgresConfig_ToMapAndLoadAndValidate(t *testing.T) {
 	// ToMap should build DSN from components when DSN empty
 	pc := &PostgresConfig{Host: "h", Port: 0, User: "u", Password: "p", DBName: "d", SSLMode: ""}
@@ -2,10 +2,14 @@ package store
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 // helper to open a store in a temporary file path
@@ -191,6 +195,70 @@ func TestStoredEnv_CRUD(t *testing.T) {
 	}
 }
 
+func TestStoredEnv_LargeMapInBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DbFileName)
+	st := &Store{}
+	cfg := Config{Driver: DriverSqlite, DriverConfig: &SqliteConfig{Path: path},
+		StoredEnv: StoredEnvLimits{MaxEntries: 30000, BatchSize: 250}}
+	if err := st.Connect(cfg); err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	in := make(map[string]string, 25000)
+	for i := 0; i < 25000; i++ {
+		in[fmt.Sprintf("k%05d", i)] = fmt.Sprintf("v%d", i)
+	}
+	if err := st.InsertStoredEnv(1, in); err != nil {
+		t.Fatalf("InsertStoredEnv: %v", err)
+	}
+	m, err := st.LoadStoredEnv(1)
+	if err != nil {
+		t.Fatalf("LoadStoredEnv: %v", err)
+	}
+	if !reflect.DeepEqual(m, in) {
+		t.Fatalf("stored %d entries, want %d", len(m), len(in))
+	}
+
+	for i := 25000; len(in) <= 30000; i++ {
+		in[fmt.Sprintf("k%05d", i)] = "x"
+	}
+	if err := st.InsertStoredEnv(2, in); err == nil || !strings.Contains(err.Error(), "(limit: 30000)") {
+		t.Fatalf("expected the configured cap to reject %d entries, got %v", len(in), err)
+	}
+}
+
+func TestStoredEnv_MidBatchErrorRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DbFileName)
+	st := &Store{}
+	cfg := Config{Driver: DriverSqlite, DriverConfig: &SqliteConfig{Path: path}, StoredEnv: StoredEnvLimits{BatchSize: 10}}
+	if err := st.Connect(cfg); err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	// Names are inserted in sorted order, so the poisoned name fails the last of 10 batches
+	trigger := fmt.Sprintf(`CREATE TRIGGER poison BEFORE INSERT ON %s WHEN NEW.name = 'k99'
+		BEGIN SELECT RAISE(ABORT, 'poisoned'); END`, st.safeTableNames().StoredEnv)
+	if _, err := st.DB.Exec(trigger); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	in := map[string]string{}
+	for i := 0; i < 100; i++ {
+		in[fmt.Sprintf("k%02d", i)] = "v"
+	}
+	if err := st.InsertStoredEnv(1, in); err == nil || !strings.Contains(err.Error(), "poisoned") {
+		t.Fatalf("expected the poisoned batch to fail, got %v", err)
+	}
+	m, err := st.LoadStoredEnv(1)
+	if err != nil {
+		t.Fatalf("LoadStoredEnv: %v", err)
+	}
+	if len(m) != 0 {
+		t.Fatalf("earlier batches were kept: %d entries", len(m))
+	}
+}
+
 // Test conv() converts '?' placeholders into $1, $2... when isPostgres is true
 func TestConv_Postgres(t *testing.T) {
 	st := &Store{Driver: DriverPostgresql}
@@ -270,6 +338,15 @@ func TestStoreConnect_UnknownDriver(t *testing.T) {
 	}
 }
 
+// The mongo driver is dispatched to the MongoDB backend, which requires a URI
+func TestStoreConnect_MongoRequiresURI(t *testing.T) {
+	var st Store
+	err := st.Connect(Config{Driver: DriverMongo, DriverConfig: &MongoConfig{Database: "app"}})
+	if err == nil || !strings.Contains(err.Error(), "mongo store: uri is required") {
+		t.Fatalf("expected the mongo uri error, got %v", err)
+	}
+}
+
 // Cover Close() path when connector is nil but DB is set (closes DB)
 func TestStoreClose_DBOnly(t *testing.T) {
 	// Open a lightweight in-memory sqlite DB directly and attach to Store
@@ -304,6 +381,14 @@ func TestDriverConfig_ToMapHelpers(t *testing.T) {
 	}
 }
 
+// SQLite allows a single writer; one pooled connection avoids "database is locked"
+func TestSqliteStore_SingleConnection(t *testing.T) {
+	st := openTempStore(t)
+	if got := st.DB.Stats().MaxOpenConnections; got != 1 {
+		t.Fatalf("MaxOpenConnections = %d, want 1", got)
+	}
+}
+
 func TestSafeTableNames_DefaultsWhenEmpty(t *testing.T) {
 	var s Store
 	tn := s.safeTableNames()
@@ -379,3 +464,269 @@ func TestListRuns_Sqlite(t *testing.T) {
 		}
 	}
 }
+
+func TestListRunsFiltered_Sqlite(t *testing.T) {
+	st := openTempStore(t)
+	record := []struct {
+		version   int
+		direction string
+		failed    bool
+	}{
+		{1, "up", false}, {2, "up", true}, {2, "up", false}, {3, "up", true}, {2, "down", false}, {3, "up", true},
+	}
+	for i, r := range record {
+		if err := st.RecordRun(r.version, r.direction, 200, nil, nil, r.failed); err != nil {
+			t.Fatalf("RecordRun #%d: %v", i, err)
+		}
+	}
+	ids := func(f RunFilter) []int {
+		t.Helper()
+		runs, err := st.ListRunsFiltered(f)
+		if err != nil {
+			t.Fatalf("ListRunsFiltered(%+v): %v", f, err)
+		}
+		out := []int{}
+		for _, r := range runs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	tests := []struct {
+		name   string
+		filter RunFilter
+		want   []int
+	}{
+		{"no filter", RunFilter{}, []int{1, 2, 3, 4, 5, 6}},
+		{"version", RunFilter{Version: 2}, []int{2, 3, 5}},
+		{"direction", RunFilter{Direction: "down"}, []int{5}},
+		{"failed only", RunFilter{FailedOnly: true}, []int{2, 4, 6}},
+		{"combined", RunFilter{Version: 2, Direction: "up", FailedOnly: true}, []int{2}},
+		{"limit keeps newest, oldest first", RunFilter{Limit: 2}, []int{5, 6}},
+		{"limit with filter", RunFilter{FailedOnly: true, Limit: 2}, []int{4, 6}},
+		{"no match", RunFilter{Version: 9}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got ids %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecksums_Sqlite(t *testing.T) {
+	st := openTempStore(t)
+	for _, v := range []int{1, 2} {
+		if err := st.Apply(v); err != nil {
+			t.Fatalf("Apply(%d): %v", v, err)
+		}
+	}
+	if err := st.SetChecksum(2, "abc"); err != nil {
+		t.Fatalf("SetChecksum: %v", err)
+	}
+	sums, err := st.ListChecksums()
+	if err != nil || !reflect.DeepEqual(sums, map[int]string{2: "abc"}) {
+		t.Fatalf("ListChecksums = %v, %v", sums, err)
+	}
+	// Removing the version drops its checksum
+	if err := st.Remove(2); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if sums, _ := st.ListChecksums(); len(sums) != 0 {
+		t.Fatalf("expected no checksums after remove, got %v", sums)
+	}
+}
+
+func TestListAppliedWithTime_Sqlite(t *testing.T) {
+	st := openTempStore(t)
+	before := time.Now().UTC().Add(-time.Second)
+	if err := st.Apply(2); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	// A version recorded before applied_at existed has no time
+	if _, err := st.DB.Exec("INSERT INTO schema_migrations(version) VALUES(1)"); err != nil {
+		t.Fatalf("insert legacy version: %v", err)
+	}
+	infos, err := st.ListAppliedWithTime()
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("ListAppliedWithTime: %v %#v", err, infos)
+	}
+	if infos[0].Version != 1 || !infos[0].AppliedAt.IsZero() {
+		t.Fatalf("legacy version should have no applied_at: %#v", infos[0])
+	}
+	if infos[1].Version != 2 || infos[1].AppliedAt.Before(before) || infos[1].AppliedAt.After(time.Now().UTC()) {
+		t.Fatalf("unexpected applied_at for version 2: %#v", infos[1])
+	}
+	// Re-applying an applied version keeps its original time
+	if err := st.Apply(2); err != nil {
+		t.Fatalf("Apply again: %v", err)
+	}
+	again, _ := st.ListAppliedWithTime()
+	if !again[1].AppliedAt.Equal(infos[1].AppliedAt) {
+		t.Fatalf("applied_at changed on re-apply: %v -> %v", infos[1].AppliedAt, again[1].AppliedAt)
+	}
+}
+
+func TestRecordRunWithDetails_Sqlite(t *testing.T) {
+	st := openTempStore(t)
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	details := RunDetails{Method: "POST", URL: "http://example.com/users", StartedAt: started, DurationMs: 42}
+	if err := st.RecordRunWithDetails(1, "up", 201, nil, nil, false, details); err != nil {
+		t.Fatalf("RecordRunWithDetails: %v", err)
+	}
+	runs, err := st.ListRuns()
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListRuns: %v %#v", err, runs)
+	}
+	r := runs[0]
+	if r.Method != "POST" || r.URL != "http://example.com/users" {
+		t.Fatalf("unexpected method/url: %#v", r)
+	}
+	if r.StartedAt != started.Format(time.RFC3339Nano) {
+		t.Fatalf("unexpected started_at: %q", r.StartedAt)
+	}
+	if r.DurationMs == nil || *r.DurationMs != 42 {
+		t.Fatalf("unexpected duration_ms: %v", r.DurationMs)
+	}
+}
+
+// A database created before run details were tracked is upgraded in place by EnsureSchema.
+func TestEnsureSchema_UpgradesLegacyRunsTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DbFileName)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE migration_runs (id INTEGER PRIMARY KEY AUTOINCREMENT, version INTEGER NOT NULL, direction TEXT NOT NULL, status_code INTEGER NOT NULL, body TEXT NULL, env_json TEXT NULL, failed INTEGER NOT NULL DEFAULT 0, ran_at TEXT NOT NULL)"); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO migration_runs(version, direction, status_code, failed, ran_at) VALUES(1, 'up', 200, 0, '2024-01-01T00:00:00Z')"); err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+	_ = db.Close()
+
+	st := &Store{}
+	if err := st.Connect(Config{Driver: DriverSqlite, DriverConfig: &SqliteConfig{Path: path}}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	// Running Ensure again must be a no-op on the upgraded table
+	if err := st.EnsureSchema(); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+	if err := st.RecordRunWithDetails(2, "up", 200, nil, nil, false, RunDetails{Method: "GET", URL: "http://x", StartedAt: time.Now(), DurationMs: 5}); err != nil {
+		t.Fatalf("RecordRunWithDetails: %v", err)
+	}
+	runs, err := st.ListRuns()
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("ListRuns: %v %#v", err, runs)
+	}
+	if runs[0].Method != "" || runs[0].StartedAt != "" || runs[0].DurationMs != nil {
+		t.Fatalf("legacy row should have empty details: %#v", runs[0])
+	}
+	if runs[1].Method != "GET" || runs[1].DurationMs == nil || *runs[1].DurationMs != 5 {
+		t.Fatalf("new row details mismatch: %#v", runs[1])
+	}
+}
+
+// setRanAt backdates run id to age before now, written with the given layout.
+func setRanAt(t *testing.T, st *Store, id int, age time.Duration, layout string) {
+	t.Helper()
+	at := time.Now().UTC().Add(-age).Format(layout)
+	if _, err := st.DB.Exec("UPDATE migration_runs SET ran_at = ? WHERE id = ?", at, id); err != nil {
+		t.Fatalf("backdate run %d: %v", id, err)
+	}
+}
+
+func TestPruneRuns_Sqlite(t *testing.T) {
+	st := openTempStore(t)
+	day := 24 * time.Hour
+	// version 1: five runs, oldest first; version 2: two old runs
+	ages := []struct {
+		version int
+		age     time.Duration
+	}{{1, 40 * day}, {2, 50 * day}, {1, 35 * day}, {2, 45 * day}, {1, 20 * day}, {1, 10 * day}, {1, day}}
+	for i, a := range ages {
+		if err := st.RecordRun(a.version, "up", 200, nil, nil, false); err != nil {
+			t.Fatalf("RecordRun #%d: %v", i, err)
+		}
+		// Mix timestamp precisions: ran_at must be compared as a time, not as text
+		layout := time.RFC3339Nano
+		if i%2 == 0 {
+			layout = time.RFC3339
+		}
+		setRanAt(t, st, i+1, a.age, layout)
+	}
+	for _, v := range []int{1, 2} {
+		if err := st.Apply(v); err != nil {
+			t.Fatalf("Apply(%d): %v", v, err)
+		}
+	}
+	if err := st.InsertStoredEnv(1, map[string]string{"id": "42"}); err != nil {
+		t.Fatalf("InsertStoredEnv: %v", err)
+	}
+	ids := func() []int {
+		t.Helper()
+		runs, err := st.ListRuns()
+		if err != nil {
+			t.Fatalf("ListRuns: %v", err)
+		}
+		out := []int{}
+		for _, r := range runs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	steps := []struct {
+		name        string
+		before      time.Time
+		keep        int
+		wantDeleted int64
+		want        []int
+	}{
+		// Older than 30 days and not among the 3 newest of their version: v1's 40d and 35d runs
+		{"age and keep", time.Now().Add(-30 * day), 3, 2, []int{2, 4, 5, 6, 7}},
+		// Newest run per version only
+		{"keep only", time.Time{}, 1, 3, []int{4, 7}},
+		// Everything older than 30 days, however few runs remain
+		{"age only", time.Now().Add(-30 * day), 0, 1, []int{7}},
+	}
+	for _, s := range steps {
+		n, err := st.PruneRuns(s.before, s.keep)
+		if err != nil {
+			t.Fatalf("%s: PruneRuns: %v", s.name, err)
+		}
+		if got := ids(); n != s.wantDeleted || !reflect.DeepEqual(got, s.want) {
+			t.Fatalf("%s: deleted %d, kept %v; want %d, %v", s.name, n, got, s.wantDeleted, s.want)
+		}
+	}
+
+	// Applied versions and stored env are untouched
+	if applied, err := st.ListApplied(); err != nil || !reflect.DeepEqual(applied, []int{1, 2}) {
+		t.Fatalf("applied after prune: %v, %v", applied, err)
+	}
+	if env, err := st.LoadStoredEnv(1); err != nil || env["id"] != "42" {
+		t.Fatalf("stored env after prune: %v, %v", env, err)
+	}
+
+	if _, err := st.PruneRuns(time.Time{}, 0); err == nil {
+		t.Fatalf("expected an error when neither a cutoff nor a keep count is set")
+	}
+	if _, err := st.PruneRuns(time.Now(), -1); err == nil {
+		t.Fatalf("expected an error for a negative keep count")
+	}
+}
+
+func TestListRunsFiltered_Since_Sqlite(t *testing.T) {
+	st := openTempStore(t)
+	for i, age := range []time.Duration{72 * time.Hour, 2 * time.Hour, time.Minute} {
+		if err := st.RecordRun(1, "up", 200, nil, nil, false); err != nil {
+			t.Fatalf("RecordRun #%d: %v", i, err)
+		}
+		setRanAt(t, st, i+1, age, time.RFC3339Nano)
+	}
+	runs, err := st.ListRunsFiltered(RunFilter{Since: time.Now().Add(-24 * time.Hour)})
+	if err != nil || len(runs) != 2 || runs[0].ID != 2 || runs[1].ID != 3 {
+		t.Fatalf("ListRunsFiltered(Since): %v %+v", err, runs)
+	}
+}
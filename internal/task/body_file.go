@@ -0,0 +1,69 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+type bodyFileCacheKey struct{}
+
+// bodyFileCache keeps the body_file contents fetched from URLs, by URL.
+type bodyFileCache struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// WithBodyFileCache returns a context whose task requests fetch each body_file URL once and
+// reuse its content afterwards. Without it a body_file URL is fetched for every request.
+func WithBodyFileCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bodyFileCacheKey{}, &bodyFileCache{files: map[string][]byte{}})
+}
+
+type planOnlyKey struct{}
+
+// planContext is the context requests are rendered with for a plan: body_file URLs are not
+// fetched, the body only names them.
+func planContext() context.Context {
+	return context.WithValue(context.Background(), planOnlyKey{}, true)
+}
+
+// isBodyFileURL reports whether a rendered body_file refers to an http(s) URL.
+func isBodyFileURL(p string) bool {
+	lower := strings.ToLower(strings.TrimSpace(p))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fetchBodyFile downloads a body_file URL with the task HTTP client (TLS, transport and
+// middleware carried by ctx), from the cache of ctx when it was already fetched.
+func fetchBodyFile(ctx context.Context, url string, retry *RetryPolicy) ([]byte, error) {
+	url = strings.TrimSpace(url)
+	if planOnly, _ := ctx.Value(planOnlyKey{}).(bool); planOnly {
+		return []byte(fmt.Sprintf("<body_file %s>", url)), nil
+	}
+	cache, _ := ctx.Value(bodyFileCacheKey{}).(*bodyFileCache)
+	if cache != nil {
+		// Held while fetching, so that concurrent steps download a file only once
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if data, ok := cache.files[url]; ok {
+			return data, nil
+		}
+	}
+	shown := common.GetGlobalMasker().MaskString(url)
+	resp, err := buildRequest(ctx, map[string]string{}, map[string]string{}, "", retry, nil).Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("body_file: fetch %s: %w", shown, describeBodyLimit(ctx, err))
+	}
+	if status := resp.StatusCode(); status < 200 || status >= 300 {
+		return nil, fmt.Errorf("body_file: fetch %s: status %d", shown, status)
+	}
+	data := resp.Body()
+	if cache != nil {
+		cache.files[url] = data
+	}
+	return data, nil
+}
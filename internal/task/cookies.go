@@ -0,0 +1,26 @@
+package task
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type cookieJarKey struct{}
+
+// WithCookieJar returns a context whose task requests share jar: cookies set by a response are
+// sent with later requests to the same site. Without it every request starts with an empty jar.
+func WithCookieJar(ctx context.Context, jar http.CookieJar) context.Context {
+	if jar == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, cookieJarKey{}, jar)
+}
+
+// applyCookieJar sets the cookie jar carried by ctx on client.
+func applyCookieJar(ctx context.Context, client *resty.Client) {
+	if jar, ok := ctx.Value(cookieJarKey{}).(http.CookieJar); ok {
+		client.SetCookieJar(jar)
+	}
+}
@@ -2,6 +2,7 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -18,6 +19,46 @@ type Down struct {
 	Queries []Query   `yaml:"queries"`
 	Body    string    `yaml:"body"`
 	Find    *FindSpec `yaml:"find"`
+	// Retry optionally overrides the retry policy for the main down request.
+	Retry *RetryPolicy `yaml:"retry"`
+	// Sign optionally signs the main down request (see SignSpec).
+	Sign *SignSpec `yaml:"sign"`
+	// IgnoreCodes are statuses of the main down request treated as success, e.g. 404/410 when
+	// the resource is already gone, so that teardown is idempotent.
+	IgnoreCodes []int `yaml:"ignore_codes"`
+	// FromUp derives the main down request from up.request when the file is loaded (see
+	// applyFromUp), so that e.g. `down: { from_up: true, method: DELETE }` undoes a PUT.
+	FromUp bool `yaml:"from_up"`
+	// DefaultHeaders are added unless a header with the same name is set in Headers.
+	DefaultHeaders map[string]string `yaml:"-"`
+}
+
+// applyFromUp fills the main down request from up.request when FromUp is set: its URL,
+// headers, queries and sign block are used unless the down step sets them. The method defaults to
+// DELETE; the body is never copied. Templates render later against the down env.
+func (d *Down) applyFromUp(up RequestSpec) error {
+	if !d.FromUp {
+		return nil
+	}
+	if strings.TrimSpace(up.URL) == "" {
+		return fmt.Errorf("from_up requires up.request.url")
+	}
+	if strings.TrimSpace(d.Method) == "" {
+		d.Method = "DELETE"
+	}
+	if strings.TrimSpace(d.URL) == "" {
+		d.URL = up.URL
+	}
+	if d.Headers == nil {
+		d.Headers = append([]Header(nil), up.Headers...)
+	}
+	if d.Queries == nil {
+		d.Queries = append([]Query(nil), up.Queries...)
+	}
+	if d.Sign == nil {
+		d.Sign = up.Sign
+	}
+	return nil
 }
 
 // FindSpec is an optional preliminary step for Down execution.
@@ -29,54 +70,118 @@ type Down struct {
 type FindSpec struct {
 	Request  RequestSpec  `yaml:"request"`
 	Response ResponseSpec `yaml:"response"`
+	// Paginate optionally follows further pages of a list endpoint until the match is found.
+	Paginate *PaginateSpec `yaml:"paginate"`
+	// FromUpBody extracts Response.EnvFrom from the up response body kept by store_body instead
+	// of sending Request. The request, if any, is sent only when no body was kept or the body
+	// lacks one of the values.
+	FromUpBody bool `yaml:"from_up_body"`
+}
+
+// findFromUpBody runs the find step against the stored up response body when FromUpBody is
+// set, merging the extracted env into d.Env.Local. It reports false, leaving the find to its
+// request, when no body was stored or, with a request to fall back to, the body lacks a value.
+func (d *Down) findFromUpBody() (bool, error) {
+	if d.Find == nil || !d.Find.FromUpBody {
+		return false, nil
+	}
+	body, _ := d.Env.Lookup(UpBodyEnvKey)
+	if body == "" {
+		if !d.hasFind() {
+			return false, fmt.Errorf("down.find.from_up_body: no up response body was stored (set store_body in up.response) and find has no request to fall back to")
+		}
+		return false, nil
+	}
+	if d.hasFind() && !d.Find.matches([]byte(body)) {
+		return false, nil
+	}
+	extracted, err := d.Find.Response.ExtractEnvFor("", []byte(body))
+	if err != nil {
+		return false, fmt.Errorf("down.find.from_up_body: %w", err)
+	}
+	d.mergeFound(extracted)
+	return true, nil
+}
+
+// mergeFound sets the values extracted by the find step in d.Env.Local.
+func (d *Down) mergeFound(extracted map[string]string) {
+	if len(extracted) == 0 {
+		return
+	}
+	if d.Env.Local == nil {
+		d.Env.Local = env.Map{}
+	}
+	for k, v := range extracted {
+		_ = d.Env.SetString("local", k, v)
+	}
 }
 
 // runFind executes the optional preliminary Find step. On success it merges
 // extracted env into d.Env.Local and returns (nil, nil). On validation error
 // it returns an ExecResult with the status code and an error. On transport
-// errors it returns (nil, error).
+// errors it returns (nil, error). With Paginate set, further pages are requested
+// until one yields every env_from value; env is extracted from that page, or from
+// the last page requested when none does.
 func (d *Down) runFind(ctx context.Context) (*ExecResult, error) {
-	fhdrs, fqueries, fbody, ferr := d.Find.Request.Render(d.Env)
-	if ferr != nil {
-		return nil, fmt.Errorf("down.find body template error: %v", ferr)
-	}
-	fmethod := strings.ToUpper(strings.TrimSpace(d.Find.Request.Method))
-	furl := strings.TrimSpace(d.Find.Request.URL)
-	if strings.Contains(furl, "{{") {
-		furl = d.Env.RenderGoTemplate(furl)
-	}
-	if fmethod == "" || furl == "" {
-		return nil, fmt.Errorf("down.find: method/url not specified")
-	}
-	freq := buildRequest(ctx, fhdrs, fqueries, fbody)
-	fresp, ferr := execByMethod(freq, fmethod, furl)
+	fmethod, furl, fhdrs, fqueries, fbody, ferr := d.renderFind(ctx)
 	if ferr != nil {
 		return nil, ferr
 	}
-	if err := d.Find.Response.ValidateStatus(fresp.StatusCode(), d.Env); err != nil {
-		return &ExecResult{StatusCode: fresp.StatusCode(), ExtractedEnv: map[string]string{}}, err
-	}
-	// Extract and merge env (may error if env_missing=fail)
-	extracted, eerr := d.Find.Response.ExtractEnv(fresp.Body())
-	if eerr != nil {
-		return &ExecResult{StatusCode: fresp.StatusCode(), ExtractedEnv: extracted}, eerr
+	sg, err := d.Find.Request.Sign.signer(d.Env)
+	if err != nil {
+		return nil, fmt.Errorf("down.find.request %w", err)
 	}
-	if len(extracted) > 0 {
-		if d.Env.Local == nil {
-			d.Env.Local = env.Map{}
+	for page := 1; ; page++ {
+		freq := buildRequest(ctx, fhdrs, fqueries, fbody, d.Find.Request.Retry, sg)
+		gql := d.Find.Request.GraphQL != nil
+		d.Find.Response.prepare(freq, gql)
+		fresp, step, ferr := execTimed(freq, fmethod, furl)
+		if ferr != nil {
+			return nil, ferr
+		}
+		if err := d.Find.Response.ValidateStatus(fresp.StatusCode(), d.Env); err != nil {
+			return step.result(fresp.StatusCode(), map[string]string{}, "").fail(ReasonUnexpectedStatus, err, d.Find.Response.responseBody(ctx, fresp, gql)), err
+		}
+		body, extractFrom, contentType, err := d.Find.Response.readDocument(ctx, fresp, gql, d.Env)
+		var rerr *ndjsonReadError
+		if errors.As(err, &rerr) {
+			return nil, err
 		}
-		for k, v := range extracted {
-			_ = d.Env.SetString("local", k, v)
+		if err != nil {
+			return step.result(fresp.StatusCode(), map[string]string{}, "").fail(ReasonAssertion, err, body), err
 		}
+		if p := d.Find.Paginate; p != nil && page < p.maxPages() && !d.Find.matches(extractFrom) {
+			next, nerr := p.next(extractFrom)
+			if nerr != nil {
+				err := fmt.Errorf("down.find.paginate: %w", nerr)
+				return step.result(fresp.StatusCode(), map[string]string{}, "").fail(ReasonAssertion, err, body), err
+			}
+			if next != "" {
+				if furl, fqueries, nerr = p.nextRequest(furl, fqueries, next); nerr != nil {
+					return step.result(fresp.StatusCode(), map[string]string{}, "").fail(ReasonAssertion, nerr, body), nerr
+				}
+				continue
+			}
+		}
+		// Extract and merge env from the body and headers (may error if env_missing=fail)
+		extracted, eerr := d.Find.Response.extractResponse(fresp.StatusCode(), d.Env, contentType, extractFrom, fresp.Header())
+		if eerr != nil {
+			return step.result(fresp.StatusCode(), extracted, "").fail(extractionReason(eerr), eerr, body), eerr
+		}
+		d.mergeFound(extracted)
+		return nil, nil
 	}
-	return nil, nil
 }
 
 // Execute performs optional Find step then the main HTTP call for Down.
-// Any 2xx status on the final call is considered success.
+// Any 2xx status on the final call, or one listed in IgnoreCodes, is considered success.
 func (d *Down) Execute(ctx context.Context) (*ExecResult, error) {
-	// 1) Optional find step
-	if d.Find != nil && d.Find.Request.Method != "" && d.Find.Request.URL != "" {
+	// 1) Optional find step, from the stored up body or a request
+	found, err := d.findFromUpBody()
+	if err != nil {
+		return nil, err
+	}
+	if !found && d.hasFind() {
 		if res, err := d.runFind(ctx); err != nil {
 			// When validation fails we must return an ExecResult with status and error
 			return res, err
@@ -84,31 +189,128 @@ func (d *Down) Execute(ctx context.Context) (*ExecResult, error) {
 	}
 
 	// 2) Main down call
+	method, url, hdrs, queries, body, rerr := d.render(ctx)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	sg, err := d.Sign.signer(d.Env)
+	if err != nil {
+		return nil, fmt.Errorf("down %w", err)
+	}
+	req := buildRequest(ctx, hdrs, queries, body, d.Retry, sg)
+	resp, step, err := execTimed(req, method, url)
+	if err != nil {
+		return nil, err
+	}
+	status := resp.StatusCode()
+	bodyBytes := resp.Body()
+	if (status < 200 || status >= 300) && !d.ignores(status) {
+		err := fmt.Errorf("down failed with status %d", status)
+		return step.result(status, map[string]string{}, string(bodyBytes)).fail(ReasonUnexpectedStatus, err, bodyBytes), err
+	}
+	return step.result(status, map[string]string{}, string(bodyBytes)), nil
+}
+
+func (d *Down) ignores(status int) bool {
+	for _, c := range d.IgnoreCodes {
+		if c == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateIgnoreCodes checks that every ignore_codes entry is an HTTP status code.
+func (d *Down) ValidateIgnoreCodes() error {
+	for i, c := range d.IgnoreCodes {
+		if c < 100 || c > 599 {
+			return fmt.Errorf("ignore_codes[%d]: %d is not an HTTP status code", i, c)
+		}
+	}
+	return nil
+}
+
+// Plan renders the requests this Down would send (the optional find step first) without
+// performing any HTTP call. Values the find step would extract are not available, unless it
+// reads them from the stored up body (FromUpBody).
+func (d *Down) Plan() ([]*RenderedRequest, error) {
+	var out []*RenderedRequest
+	found, err := d.findFromUpBody()
+	if err != nil {
+		return nil, err
+	}
+	if !found && d.hasFind() {
+		method, url, hdrs, queries, body, err := d.renderFind(planContext())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, newRenderedRequest("down.find", method, url, hdrs, queries, body))
+		// Stand in for values the find step would have extracted so the main request still renders
+		for _, k := range d.Find.Response.ExtractedKeys() {
+			if _, ok := d.Env.Lookup(k); !ok {
+				_ = d.Env.SetString("local", k, PlaceholderValue(k))
+			}
+		}
+	}
+	method, url, hdrs, queries, body, err := d.render(planContext())
+	if err != nil {
+		return nil, err
+	}
+	return append(out, newRenderedRequest("down", method, url, hdrs, queries, body)), nil
+}
+
+func (d *Down) hasFind() bool {
+	return d.Find != nil && (d.Find.Request.Method != "" || d.Find.Request.GraphQL != nil) && d.Find.Request.URL != ""
+}
+
+// renderFind resolves method, URL, headers, queries and body of the find request.
+func (d *Down) renderFind(ctx context.Context) (string, string, map[string]string, map[string]string, string, error) {
+	if d.Find.Request.Multipart != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down.find: multipart bodies are only supported in up requests")
+	}
+	fhdrs, fqueries, fbody, ferr := d.Find.Request.renderFor(ctx, d.Env, graphQLMethod(d.Find.Request.GraphQL, d.Find.Request.Method))
+	if ferr != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down.find body template error: %v", ferr)
+	}
+	fmethod := strings.ToUpper(strings.TrimSpace(graphQLMethod(d.Find.Request.GraphQL, d.Find.Request.Method)))
+	furl := strings.TrimSpace(d.Find.Request.URL)
+	furl, err := renderValue(d.Env, furl)
+	if err != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down.find url: %w", err)
+	}
+	if fmethod == "" || furl == "" {
+		return "", "", nil, nil, "", fmt.Errorf("down.find: method/url not specified")
+	}
+	return fmethod, furl, fhdrs, fqueries, fbody, nil
+}
+
+// render resolves method, URL, headers, queries and body of the main down request.
+func (d *Down) render(ctx context.Context) (string, string, map[string]string, map[string]string, string, error) {
 	method := strings.ToUpper(strings.TrimSpace(d.Method))
 	url := strings.TrimSpace(d.URL)
-	if strings.Contains(url, "{{") {
-		url = d.Env.RenderGoTemplate(url)
+	url, err := renderValue(d.Env, url)
+	if err != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down url: %w", err)
 	}
 	if method == "" || url == "" {
-		return nil, fmt.Errorf("down: method/url not specified")
+		return "", "", nil, nil, "", fmt.Errorf("down: method/url not specified")
 	}
 
-	hdrs := renderHeaders(d.Env, d.Headers)
-	queries := renderQueries(d.Env, d.Queries)
-	body, berr := renderBody(d.Env, d.Body)
-	if berr != nil {
-		return nil, fmt.Errorf("down body template error: %v", berr)
+	hdrs, err := renderHeaders(d.Env, d.Headers, method)
+	if err != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down %w", err)
 	}
-
-	req := buildRequest(ctx, hdrs, queries, body)
-	resp, err := execByMethod(req, method, url)
+	if err := applyDefaultHeaders(d.Env, d.DefaultHeaders, hdrs); err != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down %w", err)
+	}
+	queries, err := renderQueries(d.Env, d.Queries)
 	if err != nil {
-		return nil, err
+		return "", "", nil, nil, "", fmt.Errorf("down %w", err)
 	}
-	status := resp.StatusCode()
-	bodyBytes := resp.Body()
-	if status < 200 || status >= 300 {
-		return &ExecResult{StatusCode: status, ExtractedEnv: map[string]string{}, ResponseBody: string(bodyBytes)}, fmt.Errorf("down failed with status %d", status)
+	body, berr := renderBody(d.Env, d.Body)
+	if berr != nil {
+		return "", "", nil, nil, "", fmt.Errorf("down body template error: %v", berr)
 	}
-	return &ExecResult{StatusCode: status, ExtractedEnv: map[string]string{}, ResponseBody: string(bodyBytes)}, nil
+	return method, url, hdrs, queries, body, nil
 }
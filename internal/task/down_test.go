@@ -2,6 +2,7 @@ package task
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -54,7 +55,7 @@ func TestDown_Execute_WithFindAndTemplatingAndAuthFromEnv(t *testing.T) {
 			},
 			Response: ResponseSpec{
 				ResultCode: []string{"200"},
-				EnvFrom:    map[string]string{"user_id": "0.id"},
+				EnvFrom:    map[string]EnvFrom{"user_id": {Path: "0.id"}},
 			},
 		},
 		Method: http.MethodDelete,
@@ -105,6 +106,100 @@ func TestDown_Execute_FinalNon2xx_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestDown_Execute_IgnoreCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"id":"7"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	find := &FindSpec{
+		Request:  RequestSpec{Method: http.MethodGet, URL: srv.URL + "/items"},
+		Response: ResponseSpec{EnvFrom: map[string]EnvFrom{"id": {Path: "id"}}},
+	}
+	for _, f := range []*FindSpec{nil, find} {
+		d := Down{Env: env.New(), Method: http.MethodDelete, URL: srv.URL + "/items/1", Find: f}
+		if _, err := d.Execute(context.Background()); err == nil {
+			t.Fatalf("expected a 404 to fail the down without ignore_codes (find=%v)", f != nil)
+		}
+		d.IgnoreCodes = []int{404, 410}
+		res, err := d.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected the ignored 404 to succeed (find=%v): %v", f != nil, err)
+		}
+		if res == nil || res.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected the 404 to be reported, got %+v", res)
+		}
+	}
+}
+
+func TestTaskDecode_IgnoreCodesValidation(t *testing.T) {
+	var tk Task
+	err := tk.DecodeYAML(strings.NewReader("down:\n  method: DELETE\n  url: http://x\n  ignore_codes: [404, 1000]\n"))
+	if err == nil || !strings.Contains(err.Error(), "down.ignore_codes[1]") {
+		t.Fatalf("expected an ignore_codes error, got %v", err)
+	}
+}
+
+func TestTaskDecode_DownFromUp(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Tenant"))
+	}))
+	defer srv.Close()
+
+	doc := `up:
+  request:
+    method: PUT
+    url: "{{.env.base}}/realms/{{.env.realm}}"
+    headers: [{ name: X-Tenant, value: "{{.env.tenant}}" }]
+    queries: [{ name: force, value: "true" }]
+    body: '{"realm":"{{.env.realm}}"}'
+down:
+  from_up: true
+`
+	var tk Task
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if tk.Down.Method != http.MethodDelete || tk.Down.Body != "" {
+		t.Fatalf("expected a DELETE without body, got %q %q", tk.Down.Method, tk.Down.Body)
+	}
+	// At run time the down env holds the config env and the stored env of the version
+	tk.Down.Env = env.New()
+	tk.Down.Env.Local["base"] = env.Str(srv.URL)
+	tk.Down.Env.Local["realm"] = env.Str("demo")
+	tk.Down.Env.Local["tenant"] = env.Str("acme")
+	if _, err := tk.Down.Execute(context.Background()); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+
+	// Explicit down fields override the derived ones
+	override := strings.Replace(doc, "  from_up: true\n", "  from_up: true\n  method: POST\n  url: \"{{.env.base}}/realms/{{.env.realm}}/disable\"\n  headers: []\n", 1)
+	tk = Task{}
+	if err := tk.DecodeYAML(strings.NewReader(override)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	tk.Down.Env = env.New()
+	tk.Down.Env.Local["base"] = env.Str(srv.URL)
+	tk.Down.Env.Local["realm"] = env.Str("demo")
+	if _, err := tk.Down.Execute(context.Background()); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+
+	want := []string{"DELETE /realms/demo?force=true acme", "POST /realms/demo/disable?force=true "}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+
+	if err := (&Task{}).DecodeYAML(strings.NewReader("up:\n  name: x\ndown:\n  from_up: true\n")); err == nil || !strings.Contains(err.Error(), "down.from_up") {
+		t.Fatalf("expected a missing up url error, got %v", err)
+	}
+}
+
 func TestDown_Execute_DoesNotOverrideExplicitAuthorizationHeader(t *testing.T) {
 	calls := 0
 
@@ -140,6 +235,35 @@ func TestDown_Execute_DoesNotOverrideExplicitAuthorizationHeader(t *testing.T) {
 	}
 }
 
+func TestDown_Execute_DefaultHeadersOnFindAndMainRequest(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.Header.Get("X-Request-ID")+" "+r.Header.Get("User-Agent"))
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	defaults := map[string]string{"X-Request-ID": "{{.env.rid}}", "User-Agent": "apirun"}
+	d := Down{
+		Env:            &env.Env{Local: env.FromStringMap(map[string]string{"rid": "r1"})},
+		Method:         http.MethodDelete,
+		URL:            srv.URL + "/x",
+		Headers:        []Header{{Name: "User-Agent", Value: "custom"}},
+		DefaultHeaders: defaults,
+		Find: &FindSpec{
+			Request: RequestSpec{Method: http.MethodGet, URL: srv.URL + "/find", DefaultHeaders: defaults},
+		},
+	}
+	if _, err := d.Execute(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []string{"GET r1 apirun", "DELETE r1 custom"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected headers: %v", got)
+	}
+}
+
 func TestDown_Find_ValidationFailure_ReturnsExecResultAndError(t *testing.T) {
 	calls := struct{ find, del int }{}
 
@@ -227,7 +351,7 @@ func TestExecByMethod_SupportedAndUnsupported(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	req := buildRequest(context.Background(), map[string]string{}, map[string]string{}, "")
+	req := buildRequest(context.Background(), map[string]string{}, map[string]string{}, "", nil, nil)
 	// Supported methods
 	cases := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
 	for _, m := range cases {
@@ -275,7 +399,7 @@ func TestDown_Find_EnvMissingPolicy(t *testing.T) {
 					Request: RequestSpec{Method: http.MethodGet, URL: srv.URL + "/search"},
 					Response: ResponseSpec{
 						ResultCode: []string{"200"},
-						EnvFrom:    map[string]string{"rid": "id", "missing": "nope"},
+						EnvFrom:    map[string]EnvFrom{"rid": {Path: "id"}, "missing": {Path: "nope"}},
 						EnvMissing: policy,
 					},
 				},
@@ -305,3 +429,85 @@ func TestDown_Find_EnvMissingPolicy(t *testing.T) {
 	tRun("fail-policy", "fail", true, 0)
 	tRun("skip-default", "", false, 1)
 }
+
+func TestDown_Find_FromUpBodyAndFallback(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"project":{"id":"p-found"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	down := func(upBody string, withRequest bool) *Down {
+		d := &Down{
+			Env:    &env.Env{Local: env.FromStringMap(map[string]string{})},
+			Method: http.MethodDelete,
+			URL:    srv.URL + "/projects/{{.env.project_id}}",
+			Find: &FindSpec{
+				FromUpBody: true,
+				Response:   ResponseSpec{EnvFrom: map[string]EnvFrom{"project_id": {Path: "project.id"}}, EnvMissing: "fail"},
+			},
+		}
+		if upBody != "" {
+			_ = d.Env.SetString("local", UpBodyEnvKey, upBody)
+		}
+		if withRequest {
+			d.Find.Request = RequestSpec{Method: http.MethodGet, URL: srv.URL + "/projects/by-name/demo"}
+		}
+		return d
+	}
+	cases := []struct {
+		name        string
+		upBody      string
+		withRequest bool
+		want        string
+	}{
+		{"from the stored body", `{"project":{"id":"p-up"}}`, true, "DELETE /projects/p-up"},
+		{"no stored body", "", true, "GET /projects/by-name/demo|DELETE /projects/p-found"},
+		{"body lacks the value", `{"other":1}`, true, "GET /projects/by-name/demo|DELETE /projects/p-found"},
+	}
+	for _, tc := range cases {
+		got = nil
+		if _, err := down(tc.upBody, tc.withRequest).Execute(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if strings.Join(got, "|") != tc.want {
+			t.Fatalf("%s: requests = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// Without a request to fall back to, the body decides
+	got = nil
+	if _, err := down(`{"other":1}`, false).Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "from_up_body") {
+		t.Fatalf("expected the missing value to fail, got %v", err)
+	}
+	if _, err := down("", false).Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "store_body") {
+		t.Fatalf("expected a missing body error, got %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("unexpected requests: %q", got)
+	}
+
+	// Plan reads the stored body too, so the main request renders with the real value
+	reqs, err := down(`{"project":{"id":"p-up"}}`, true).Plan()
+	if err != nil || len(reqs) != 1 || !strings.HasSuffix(reqs[0].URL, "/projects/p-up") {
+		t.Fatalf("plan = %+v, %v", reqs, err)
+	}
+}
+
+func TestTaskDecode_FindFromUpBodyValidation(t *testing.T) {
+	doc := "up:\n  request: { method: POST, url: http://x }\n  response: { store_body: %s }\ndown:\n  method: DELETE\n  url: http://x\n  find:\n    from_up_body: true\n    response:\n      %s\n"
+	for _, tc := range []struct{ storeBody, response, wantErr string }{
+		{"true", "env_from: { id: id }", ""},
+		{"false", "env_from: { id: id }", "requires store_body"},
+		{"true", "header_from: { loc: Location }", "header_from cannot be used"},
+	} {
+		var tk Task
+		err := tk.DecodeYAML(strings.NewReader(fmt.Sprintf(doc, tc.storeBody, tc.response)))
+		if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Fatalf("store_body %s, %s: err = %v, want %q", tc.storeBody, tc.response, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package task
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+// Reasons reported by ExecError.Reason.
+const (
+	// ReasonUnexpectedStatus means the response status is not an accepted result code.
+	ReasonUnexpectedStatus = "unexpected_status"
+	// ReasonAssertion means the response did not satisfy the step: GraphQL errors, an env_from
+	// or header_from value that cannot be extracted or has the wrong type, a failing on_match
+	// or pagination, an ndjson response without a matching line or failing its assertions.
+	ReasonAssertion = "assertion_failed"
+	// ReasonEnvMissing means an env_from or header_from value is absent with env_missing: fail.
+	ReasonEnvMissing = "env_missing"
+)
+
+// maxBodySnippet bounds ExecError.BodySnippet, in bytes.
+const maxBodySnippet = 512
+
+// ExecError describes why a step whose request got a response failed (ExecResult.Error).
+type ExecError struct {
+	// Reason classifies the failure: ReasonUnexpectedStatus, ReasonAssertion or ReasonEnvMissing.
+	Reason string
+	// Message is the text of the error returned with the result.
+	Message string
+	// StatusCode is the status of the rejected response.
+	StatusCode int
+	// BodySnippet is the start of the rejected response body, with secrets masked.
+	BodySnippet string
+}
+
+// Error returns Message.
+func (e *ExecError) Error() string { return e.Message }
+
+// fail sets the Error of r for err, classified as reason, and returns r.
+func (r *ExecResult) fail(reason string, err error, body []byte) *ExecResult {
+	r.Error = &ExecError{
+		Reason:      reason,
+		Message:     err.Error(),
+		StatusCode:  r.StatusCode,
+		BodySnippet: bodySnippet(body),
+	}
+	return r
+}
+
+// extractionReason classifies an env_from or header_from extraction error.
+func extractionReason(err error) string {
+	var missing *missingValueError
+	if errors.As(err, &missing) {
+		return ReasonEnvMissing
+	}
+	return ReasonAssertion
+}
+
+// missingValueError reports an env_from or header_from value absent with env_missing: fail.
+type missingValueError struct{ msg string }
+
+func (e *missingValueError) Error() string { return e.msg }
+
+// bodySnippet masks body and cuts it to maxBodySnippet bytes on a rune boundary.
+func bodySnippet(body []byte) string {
+	s := common.GetGlobalMasker().MaskString(string(body))
+	if len(s) <= maxBodySnippet {
+		return s
+	}
+	cut := maxBodySnippet
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
@@ -0,0 +1,51 @@
+package task
+
+import (
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Extractor extracts env_from values from a response body of a registered content type.
+// spec maps each env_from key to its path, whose syntax is up to the extractor. Keys missing
+// from the result count as missing: their default or the env_missing policy applies.
+type Extractor func(body []byte, spec map[string]string) (map[string]string, error)
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{}
+)
+
+// RegisterExtractor registers fn for responses whose Content-Type has the media type
+// contentType (e.g. "application/xml"; parameters such as charset are ignored). Responses of
+// other types use the built-in JSON extraction; registering "application/json" replaces it.
+func RegisterExtractor(contentType string, fn Extractor) {
+	key := mediaType(contentType)
+	if key == "" || fn == nil {
+		return
+	}
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[key] = fn
+}
+
+// lookupExtractor returns the extractor registered for the media type of contentType.
+func lookupExtractor(contentType string) (Extractor, bool) {
+	key := mediaType(contentType)
+	if key == "" {
+		return nil, false
+	}
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	fn, ok := extractors[key]
+	return fn, ok
+}
+
+// mediaType returns the lower-case media type of a Content-Type value, without parameters.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
@@ -0,0 +1,91 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// extractKeyValues reads "name=value" lines; spec paths are names.
+func extractKeyValues(body []byte, spec map[string]string) (map[string]string, error) {
+	lines := map[string]string{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			lines[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	out := map[string]string{}
+	for key, p := range spec {
+		if v, ok := lines[p]; ok {
+			out[key] = v
+		}
+	}
+	return out, nil
+}
+
+func TestExtractEnvFor_RegisteredExtractor(t *testing.T) {
+	RegisterExtractor("Text/X-Test-KV", extractKeyValues)
+	body := []byte("id = 42\nname = alice\n")
+	viewer := "viewer"
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{
+		"id":    {Path: "id", Type: EnvTypeInt},
+		"name":  {Path: "name"},
+		"role":  {Path: "role", Default: &viewer},
+		"other": {Path: "missing"},
+	}}
+	got, err := r.ExtractEnvFor("text/x-test-kv; charset=utf-8", body)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := map[string]string{"id": "42", "name": "alice", "role": "viewer"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	r.EnvMissing = "fail"
+	if _, err := r.ExtractEnvFor("text/x-test-kv", body); err == nil || !strings.Contains(err.Error(), "missing env_from for key 'other'") {
+		t.Fatalf("expected a missing key error, got %v", err)
+	}
+
+	// Other content types keep the JSON extraction
+	got, err = ResponseSpec{EnvFrom: map[string]EnvFrom{"id": {Path: "id"}}}.ExtractEnvFor("application/json", []byte(`{"id":"7"}`))
+	if err != nil || got["id"] != "7" {
+		t.Fatalf("json fallback: got %v, %v", got, err)
+	}
+}
+
+func TestExtractEnvFor_ExtractorError(t *testing.T) {
+	RegisterExtractor("text/x-test-broken", func([]byte, map[string]string) (map[string]string, error) {
+		return nil, errors.New("cannot parse")
+	})
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{"id": {Path: "id"}}}
+	_, err := r.ExtractEnvFor("text/x-test-broken", []byte("x"))
+	if err == nil || err.Error() != "env_from: text/x-test-broken extractor: cannot parse" {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+func TestUp_Execute_RegisteredExtractor(t *testing.T) {
+	RegisterExtractor("text/x-test-up", extractKeyValues)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/x-test-up")
+		_, _ = w.Write([]byte("id=abc\n"))
+	}))
+	defer srv.Close()
+
+	u := Up{Env: env.New(), Request: RequestSpec{Method: http.MethodGet, URL: srv.URL},
+		Response: ResponseSpec{EnvFrom: map[string]EnvFrom{"item_id": {Path: "id"}}, EnvMissing: "fail"}}
+	res, err := u.Execute(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.ExtractedEnv["item_id"] != "abc" {
+		t.Fatalf("extracted = %v", res.ExtractedEnv)
+	}
+}
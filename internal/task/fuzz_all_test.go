@@ -40,7 +40,7 @@ func FuzzTask(f *testing.F) {
 		if len(bodyJSON) > 1<<16 {
 			bodyJSON = bodyJSON[:1<<16]
 		}
-		r3 := ResponseSpec{EnvFrom: map[string]string{envKey: gjsonPath}}
+		r3 := ResponseSpec{EnvFrom: map[string]EnvFrom{envKey: {Path: gjsonPath}}}
 		_, _ = r3.ExtractEnv([]byte(bodyJSON))
 	})
 }
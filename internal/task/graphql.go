@@ -0,0 +1,74 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/loykin/apirun/pkg/env"
+	"github.com/tidwall/gjson"
+)
+
+// GraphQLSpec turns a request into a GraphQL operation: the body becomes
+// {"query":...,"variables":...} and the response is checked for a top-level "errors" array.
+// env_from paths are then evaluated against the "data" object.
+type GraphQLSpec struct {
+	// Query is sent verbatim (GraphQL braces are not templates); pass dynamic values via Variables.
+	Query         string                 `yaml:"query"`
+	Variables     map[string]interface{} `yaml:"variables"`
+	OperationName string                 `yaml:"operation_name"`
+}
+
+// body renders the variables (string values are templates) and builds the JSON request body.
+func (g *GraphQLSpec) body(e *env.Env) (string, error) {
+	if strings.TrimSpace(g.Query) == "" {
+		return "", fmt.Errorf("graphql: query is required")
+	}
+	payload := map[string]interface{}{"query": g.Query}
+	if len(g.Variables) > 0 {
+		vars, err := renderNestedValue(e, g.Variables)
+		if err != nil {
+			return "", fmt.Errorf("graphql variables: %w", err)
+		}
+		payload["variables"] = vars
+	}
+	if g.OperationName != "" {
+		payload["operationName"] = g.OperationName
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("graphql: encode request: %w", err)
+	}
+	return string(b), nil
+}
+
+// graphQLMethod defaults the HTTP method of GraphQL requests to POST.
+func graphQLMethod(g *GraphQLSpec, method string) string {
+	if g != nil && strings.TrimSpace(method) == "" {
+		return http.MethodPost
+	}
+	return method
+}
+
+// graphQLData returns the "data" object of a GraphQL response, or an error listing the
+// messages of a non-empty top-level "errors" array (GraphQL reports errors with HTTP 200).
+func graphQLData(body []byte) ([]byte, error) {
+	parsed := gjson.ParseBytes(body)
+	if errs := parsed.Get("errors"); errs.IsArray() && len(errs.Array()) > 0 {
+		var msgs []string
+		for _, e := range errs.Array() {
+			if msg := e.Get("message"); msg.Exists() {
+				msgs = append(msgs, msg.String())
+			} else {
+				msgs = append(msgs, e.Raw)
+			}
+		}
+		return nil, fmt.Errorf("graphql errors: %s", strings.Join(msgs, "; "))
+	}
+	data := parsed.Get("data")
+	if !data.Exists() {
+		return nil, nil
+	}
+	return []byte(data.Raw), nil
+}
@@ -0,0 +1,114 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// graphQLServer answers createUser mutations and reports an error for duplicate names.
+func graphQLServer(t *testing.T, seen *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		*seen = req
+		vars, _ := req["variables"].(map[string]interface{})
+		input, _ := vars["input"].(map[string]interface{})
+		if input["name"] == "taken" {
+			_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"name already taken"},{"message":"second"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"createUser":{"id":"u1","name":"` + input["name"].(string) + `"}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestUp_Execute_GraphQL_BuildsEnvelopeAndExtractsFromData(t *testing.T) {
+	var seen map[string]interface{}
+	srv := graphQLServer(t, &seen)
+
+	up := Up{
+		Env: &env.Env{Local: env.FromStringMap(map[string]string{"name": "alice"})},
+		Request: RequestSpec{URL: srv.URL, GraphQL: &GraphQLSpec{
+			Query:     "mutation($input: UserInput!) { createUser(input: $input) { id name } }",
+			Variables: map[string]interface{}{"input": map[string]interface{}{"name": "{{.env.name}}", "admin": false}},
+		}},
+		Response: ResponseSpec{ResultCode: []string{"200"}, EnvFrom: map[string]EnvFrom{"user_id": {Path: "createUser.id"}, "user": {Path: "$.createUser.name"}}},
+	}
+	res, err := up.Execute(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Method != http.MethodPost {
+		t.Fatalf("expected POST by default, got %q", res.Method)
+	}
+	if res.ExtractedEnv["user_id"] != "u1" || res.ExtractedEnv["user"] != "alice" {
+		t.Fatalf("unexpected extracted env: %v", res.ExtractedEnv)
+	}
+	if !strings.HasPrefix(seen["query"].(string), "mutation($input") {
+		t.Fatalf("unexpected query: %v", seen["query"])
+	}
+	input := seen["variables"].(map[string]interface{})["input"].(map[string]interface{})
+	if input["name"] != "alice" || input["admin"] != false {
+		t.Fatalf("unexpected variables: %v", seen["variables"])
+	}
+}
+
+func TestUp_Execute_GraphQL_ErrorsFailStepDespite200(t *testing.T) {
+	var seen map[string]interface{}
+	srv := graphQLServer(t, &seen)
+
+	up := Up{
+		Env: env.New(),
+		Request: RequestSpec{URL: srv.URL, GraphQL: &GraphQLSpec{
+			Query:     "mutation($input: UserInput!) { createUser(input: $input) { id } }",
+			Variables: map[string]interface{}{"input": map[string]interface{}{"name": "taken"}},
+		}},
+		Response: ResponseSpec{ResultCode: []string{"200"}, EnvFrom: map[string]EnvFrom{"user_id": {Path: "createUser.id"}}},
+	}
+	res, err := up.Execute(context.Background(), "", "")
+	if err == nil || !strings.Contains(err.Error(), "name already taken; second") {
+		t.Fatalf("expected graphql errors, got %v", err)
+	}
+	if res == nil || res.StatusCode != 200 {
+		t.Fatalf("expected result with status 200, got %+v", res)
+	}
+}
+
+func TestRequest_Render_GraphQL_RejectsBody(t *testing.T) {
+	req := RequestSpec{Body: "{}", GraphQL: &GraphQLSpec{Query: "{ a }"}}
+	if _, _, _, err := req.Render(env.New()); err == nil {
+		t.Fatalf("expected error when combining graphql with body")
+	}
+	req = RequestSpec{GraphQL: &GraphQLSpec{}}
+	if _, _, _, err := req.Render(env.New()); err == nil || !strings.Contains(err.Error(), "query is required") {
+		t.Fatalf("expected missing query error, got %v", err)
+	}
+}
+
+func TestDecodeYAML_GraphQLRequest(t *testing.T) {
+	y := "up:\n  request:\n    url: http://x/graphql\n    graphql:\n      operation_name: Create\n      query: 'mutation Create { a }'\n      variables:\n        ids: [1, 2]\n"
+	var tk Task
+	if err := tk.DecodeYAML(strings.NewReader(y)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	_, _, body, err := tk.Up.Request.Render(env.New())
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if body != `{"operationName":"Create","query":"mutation Create { a }","variables":{"ids":[1,2]}}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
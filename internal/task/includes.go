@@ -0,0 +1,155 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includesKey lists shared fragment files (relative to the including file) merged into a migration.
+const includesKey = "includes"
+
+// includeReader reads files referenced by `includes:` relative to the including file.
+type includeReader struct {
+	read func(name string) ([]byte, error)
+	dir  func(name string) string
+	join func(elem ...string) string
+	abs  func(name string) bool
+}
+
+var osIncludeReader = includeReader{
+	read: func(name string) ([]byte, error) {
+		// #nosec G304 -- include paths come from the migration files themselves
+		return os.ReadFile(filepath.Clean(name))
+	},
+	dir:  filepath.Dir,
+	join: filepath.Join,
+	abs:  filepath.IsAbs,
+}
+
+func fsIncludeReader(fsys fs.FS) includeReader {
+	return includeReader{
+		read: func(name string) ([]byte, error) { return fs.ReadFile(fsys, path.Clean(name)) },
+		dir:  path.Dir,
+		join: path.Join,
+		abs:  path.IsAbs,
+	}
+}
+
+// ResolveIncludes reads the migration file at name and deep-merges the files listed under its
+// `includes:` key into it. Included files may include further files; maps are merged key by key,
+// anything else (scalars, lists) is taken from the including file. The result has no includes key.
+func ResolveIncludes(name string) (map[string]interface{}, error) {
+	return osIncludeReader.resolve(name, nil)
+}
+
+// decodeWithIncludes decodes the migration file at name into t, merging its includes first.
+// Files without includes are decoded as-is.
+func (t *Task) decodeWithIncludes(r includeReader, name string) error {
+	data, err := r.read(name)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc[includesKey] == nil {
+		return t.decodeYAMLTo(bytes.NewReader(data))
+	}
+	merged, err := r.merge(name, doc, []string{name})
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to encode merged migration %s: %w", name, err)
+	}
+	return t.decodeYAMLTo(bytes.NewReader(out))
+}
+
+// resolve loads name and merges its includes; stack holds the files being resolved, to detect cycles.
+func (r includeReader) resolve(name string, stack []string) (map[string]interface{}, error) {
+	data, err := r.read(name)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	return r.merge(name, doc, append(stack, name))
+}
+
+// merge applies the includes of doc (read from name) underneath doc itself.
+func (r includeReader) merge(name string, doc map[string]interface{}, stack []string) (map[string]interface{}, error) {
+	raw, ok := doc[includesKey]
+	delete(doc, includesKey)
+	if !ok || raw == nil {
+		return doc, nil
+	}
+	includes, err := includeList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	base := map[string]interface{}{}
+	for _, inc := range includes {
+		p := inc
+		if !r.abs(p) {
+			p = r.join(r.dir(name), inc)
+		}
+		for _, s := range stack {
+			if s == p {
+				return nil, fmt.Errorf("%s: include cycle through %q", name, inc)
+			}
+		}
+		frag, err := r.resolve(p, stack)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot resolve include %q: %w", name, inc, err)
+		}
+		base = deepMerge(base, frag)
+	}
+	return deepMerge(base, doc), nil
+}
+
+// includeList validates the value of the includes key: a list of non-empty file paths.
+func includeList(raw interface{}) ([]string, error) {
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("includes must be a list of file paths")
+	}
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		s, ok := it.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return nil, fmt.Errorf("includes must be a list of file paths, got %v", it)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// deepMerge returns base with override merged on top: nested maps are merged recursively,
+// other values from override replace those in base.
+func deepMerge(base, override map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		bm, bok := out[k].(map[string]interface{})
+		om, ook := v.(map[string]interface{})
+		if bok && ook {
+			out[k] = deepMerge(bm, om)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
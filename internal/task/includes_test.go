@@ -0,0 +1,120 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const sharedFragment = `up:
+  request:
+    headers:
+      - name: X-Tenant
+        value: acme
+      - name: Accept
+        value: application/json
+    queries:
+      - name: source
+        value: shared
+  response:
+    result_code: ["200", "201"]
+down:
+  method: DELETE
+  url: "{{.env.base}}/shared"
+`
+
+const includingMigration = `includes: [_shared.yaml]
+up:
+  name: create user
+  request:
+    method: POST
+    url: "{{.env.base}}/users"
+  response:
+    result_code: ["201"]
+`
+
+func TestLoadFromFile_MergesIncludesLocalWins(t *testing.T) {
+	dir := t.TempDir()
+	writeTaskFile(t, dir, "_shared.yaml", sharedFragment)
+	p := writeTaskFile(t, dir, "001_create.yaml", includingMigration)
+
+	var tk Task
+	if err := tk.LoadFromFile(p); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if tk.Up.Name != "create user" || tk.Up.Request.Method != "POST" {
+		t.Fatalf("local fields lost: %+v", tk.Up)
+	}
+	if len(tk.Up.Request.Headers) != 2 || tk.Up.Request.Headers[0].Name != "X-Tenant" {
+		t.Fatalf("expected shared headers, got %+v", tk.Up.Request.Headers)
+	}
+	if len(tk.Up.Request.Queries) != 1 || tk.Up.Request.Queries[0].Value != "shared" {
+		t.Fatalf("expected shared queries, got %+v", tk.Up.Request.Queries)
+	}
+	if len(tk.Up.Response.ResultCode) != 1 || tk.Up.Response.ResultCode[0] != "201" {
+		t.Fatalf("expected local result_code to win, got %v", tk.Up.Response.ResultCode)
+	}
+	if tk.Down.Method != "DELETE" || tk.Down.URL != "{{.env.base}}/shared" {
+		t.Fatalf("expected shared down section, got %+v", tk.Down)
+	}
+}
+
+func TestLoadFromFile_NestedIncludesAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTaskFile(t, dir, filepath.Join("shared", "base.yaml"), "up:\n  request:\n    method: GET\n")
+	writeTaskFile(t, dir, filepath.Join("shared", "users.yaml"), "includes: [base.yaml]\nup:\n  request:\n    url: http://x/users\n")
+	p := writeTaskFile(t, dir, "001_list.yaml", "includes: [shared/users.yaml]\nup:\n  name: list\n")
+
+	var tk Task
+	if err := tk.LoadFromFile(p); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if tk.Up.Request.Method != "GET" || tk.Up.Request.URL != "http://x/users" {
+		t.Fatalf("nested include not merged: %+v", tk.Up.Request)
+	}
+
+	tests := []struct {
+		name, content, want string
+	}{
+		{"missing", "includes: [_nope.yaml]\nup: {}\n", `cannot resolve include "_nope.yaml"`},
+		{"not a list", "includes: _shared.yaml\nup: {}\n", "must be a list"},
+		{"cycle", "includes: [002_cycle.yaml]\nup: {}\n", "include cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeTaskFile(t, dir, "002_cycle.yaml", tt.content)
+			var tk Task
+			if err := tk.LoadFromFile(p); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadFromFS_ResolvesIncludesWithinFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/_shared.yaml":    {Data: []byte(sharedFragment)},
+		"migrations/001_create.yaml": {Data: []byte(includingMigration)},
+	}
+	var tk Task
+	if err := tk.LoadFromFS(fsys, "migrations/001_create.yaml"); err != nil {
+		t.Fatalf("LoadFromFS: %v", err)
+	}
+	if len(tk.Up.Request.Headers) != 2 || tk.Down.Method != "DELETE" {
+		t.Fatalf("includes not merged from FS: %+v", tk)
+	}
+}
+
+func writeTaskFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
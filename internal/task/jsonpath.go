@@ -0,0 +1,345 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath support for env_from.
+//
+// Expressions starting with "$." or "$[" are evaluated as JSONPath; everything else keeps using
+// gjson paths. The supported subset covers what migrations typically need:
+//
+//	$.a.b           child access (also $['a']['b'])
+//	$.items[0]      array index (negative indexes count from the end)
+//	$.items[*].id   wildcard over array elements or object values
+//	$..id           recursive descent
+//	$.items[?(@.name == 'x')].id   filter with ==, !=, <, <=, >, >= or bare existence (@.field)
+
+type jsonPathStepKind int
+
+const (
+	stepChild jsonPathStepKind = iota
+	stepIndex
+	stepWildcard
+	stepRecursive
+	stepFilter
+)
+
+type jsonPathStep struct {
+	kind   jsonPathStepKind
+	name   string
+	index  int
+	filter *jsonPathFilter
+	// next is the step applied to every node found by a recursive descent.
+	next *jsonPathStep
+}
+
+type jsonPathFilter struct {
+	path    []jsonPathStep
+	op      string
+	literal interface{}
+}
+
+// isJSONPath reports whether an env_from expression should be evaluated as JSONPath.
+func isJSONPath(expr string) bool {
+	return strings.HasPrefix(expr, "$.") || strings.HasPrefix(expr, "$[")
+}
+
+// evalJSONPath evaluates expr against a JSON document. It returns the matched value
+// (a single value, or a slice when the expression matches more than one node) and whether
+// anything matched at all.
+func evalJSONPath(body []byte, expr string) (interface{}, bool, error) {
+	steps, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, false, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false, nil
+	}
+	nodes := applyJSONPath([]interface{}{doc}, steps)
+	switch len(nodes) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		return nodes[0], true, nil
+	default:
+		return nodes, true, nil
+	}
+}
+
+func parseJSONPath(expr string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with '$'", expr)
+	}
+	steps, err := parseJSONPathSteps(expr[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+	}
+	return steps, nil
+}
+
+func parseJSONPathSteps(s string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	for len(s) > 0 {
+		var st jsonPathStep
+		var err error
+		switch {
+		case strings.HasPrefix(s, ".."):
+			var inner jsonPathStep
+			rest := s[2:]
+			if strings.HasPrefix(rest, "[") {
+				inner, rest, err = parseJSONPathBracket(rest)
+			} else {
+				inner, rest, err = parseJSONPathDot(rest)
+			}
+			if err != nil {
+				return nil, err
+			}
+			st = jsonPathStep{kind: stepRecursive, next: &inner}
+			s = rest
+		case s[0] == '.':
+			st, s, err = parseJSONPathDot(s[1:])
+		case s[0] == '[':
+			st, s, err = parseJSONPathBracket(s)
+		default:
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, st)
+	}
+	return steps, nil
+}
+
+func parseJSONPathDot(s string) (jsonPathStep, string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	if name == "" {
+		return jsonPathStep{}, "", fmt.Errorf("empty member name")
+	}
+	if name == "*" {
+		return jsonPathStep{kind: stepWildcard}, s[end:], nil
+	}
+	return jsonPathStep{kind: stepChild, name: name}, s[end:], nil
+}
+
+func parseJSONPathBracket(s string) (jsonPathStep, string, error) {
+	// s starts with '['
+	body := s[1:]
+	switch {
+	case strings.HasPrefix(body, "?("):
+		end := strings.Index(body, ")]")
+		if end < 0 {
+			return jsonPathStep{}, "", fmt.Errorf("unterminated filter")
+		}
+		f, err := parseJSONPathFilter(body[2:end])
+		if err != nil {
+			return jsonPathStep{}, "", err
+		}
+		return jsonPathStep{kind: stepFilter, filter: f}, body[end+2:], nil
+	case strings.HasPrefix(body, "'") || strings.HasPrefix(body, `"`):
+		q := body[0]
+		end := strings.IndexByte(body[1:], q)
+		if end < 0 || !strings.HasPrefix(body[end+2:], "]") {
+			return jsonPathStep{}, "", fmt.Errorf("unterminated quoted member")
+		}
+		return jsonPathStep{kind: stepChild, name: body[1 : end+1]}, body[end+3:], nil
+	}
+	end := strings.IndexByte(body, ']')
+	if end < 0 {
+		return jsonPathStep{}, "", fmt.Errorf("unterminated '['")
+	}
+	inner := strings.TrimSpace(body[:end])
+	if inner == "*" {
+		return jsonPathStep{kind: stepWildcard}, body[end+1:], nil
+	}
+	idx, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonPathStep{}, "", fmt.Errorf("invalid index %q", inner)
+	}
+	return jsonPathStep{kind: stepIndex, index: idx}, body[end+1:], nil
+}
+
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseJSONPathFilter(s string) (*jsonPathFilter, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "@") {
+		return nil, fmt.Errorf("filter must reference '@': %q", s)
+	}
+	left, op, right := s, "", ""
+	for i := 1; i < len(s) && op == ""; i++ {
+		for _, o := range jsonPathOperators {
+			if strings.HasPrefix(s[i:], o) {
+				left, op, right = strings.TrimSpace(s[:i]), o, strings.TrimSpace(s[i+len(o):])
+				break
+			}
+		}
+	}
+	path, err := parseJSONPathSteps(left[1:])
+	if err != nil {
+		return nil, err
+	}
+	f := &jsonPathFilter{path: path, op: op}
+	if op == "" {
+		return f, nil
+	}
+	switch {
+	case len(right) >= 2 && (right[0] == '\'' || right[0] == '"') && right[len(right)-1] == right[0]:
+		f.literal = right[1 : len(right)-1]
+	case right == "true" || right == "false":
+		f.literal = right == "true"
+	case right == "null":
+		f.literal = nil
+	default:
+		n, err := strconv.ParseFloat(right, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter literal %q", right)
+		}
+		f.literal = n
+	}
+	return f, nil
+}
+
+func applyJSONPath(nodes []interface{}, steps []jsonPathStep) []interface{} {
+	for _, st := range steps {
+		var next []interface{}
+		for _, n := range nodes {
+			next = append(next, applyJSONPathStep(n, st)...)
+		}
+		nodes = next
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+func applyJSONPathStep(n interface{}, st jsonPathStep) []interface{} {
+	switch st.kind {
+	case stepChild:
+		if obj, ok := n.(map[string]interface{}); ok {
+			if v, ok := obj[st.name]; ok {
+				return []interface{}{v}
+			}
+		}
+	case stepIndex:
+		if arr, ok := n.([]interface{}); ok {
+			i := st.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				return []interface{}{arr[i]}
+			}
+		}
+	case stepWildcard:
+		return jsonPathChildren(n)
+	case stepRecursive:
+		out := applyJSONPathStep(n, *st.next)
+		for _, c := range jsonPathChildren(n) {
+			out = append(out, applyJSONPathStep(c, st)...)
+		}
+		return out
+	case stepFilter:
+		var out []interface{}
+		for _, c := range jsonPathChildren(n) {
+			if st.filter.match(c) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// jsonPathChildren returns array elements or object values (in key order for determinism).
+func jsonPathChildren(n interface{}) []interface{} {
+	switch v := n.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, 0, len(v))
+		for _, k := range keys {
+			out = append(out, v[k])
+		}
+		return out
+	}
+	return nil
+}
+
+func (f *jsonPathFilter) match(n interface{}) bool {
+	found := applyJSONPath([]interface{}{n}, f.path)
+	if len(found) == 0 {
+		return false
+	}
+	if f.op == "" {
+		return true
+	}
+	v := found[0]
+	if num, ok := v.(json.Number); ok {
+		fv, err := num.Float64()
+		if err != nil {
+			return false
+		}
+		v = fv
+	}
+	switch lit := f.literal.(type) {
+	case float64:
+		fv, ok := v.(float64)
+		if !ok {
+			return f.op == "!="
+		}
+		return compareOrdered(fv, lit, f.op)
+	case string:
+		sv, ok := v.(string)
+		if !ok {
+			return f.op == "!="
+		}
+		return compareOrdered(sv, lit, f.op)
+	default:
+		// bool or null: only equality makes sense
+		switch f.op {
+		case "==":
+			return v == f.literal
+		case "!=":
+			return v != f.literal
+		}
+	}
+	return false
+}
+
+func compareOrdered[T float64 | string](a, b T, op string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
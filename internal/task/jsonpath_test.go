@@ -0,0 +1,110 @@
+package task
+
+import (
+	"strings"
+	"testing"
+)
+
+const jsonPathTestBody = `{
+  "items": [
+    {"id": 11, "name": "alpha", "tags": ["a", "b"], "owner": {"name": "kim"}},
+    {"id": 22, "name": "beta", "active": true, "owner": {"name": "lee"}},
+    {"id": 33, "name": "gamma", "score": 7.5}
+  ],
+  "meta": {"total": 3, "page": {"next": "/items?page=2"}}
+}`
+
+func TestExtractEnv_JSONPath_Arrays(t *testing.T) {
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{
+		"first_id": {Path: "$.items[0].id"},
+		"last_id":  {Path: "$.items[-1].id"},
+		"all_ids":  {Path: "$.items[*].id"},
+		"tag":      {Path: "$.items[0].tags[1]"},
+		"beta_id":  {Path: "$.items[?(@.name == 'beta')].id"},
+		"big":      {Path: "$.items[?(@.id > 20)].name"},
+		"active":   {Path: "$.items[?(@.active)].name"},
+	}}
+	got, err := r.ExtractEnv([]byte(jsonPathTestBody))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"first_id": "11",
+		"last_id":  "33",
+		"all_ids":  "[11,22,33]",
+		"tag":      "b",
+		"beta_id":  "22",
+		"big":      `["beta","gamma"]`,
+		"active":   "beta",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s: expected %q, got %q", k, v, got[k])
+		}
+	}
+}
+
+func TestExtractEnv_JSONPath_NestedObjects(t *testing.T) {
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{
+		"next":   {Path: "$.meta.page.next"},
+		"total":  {Path: "$['meta']['total']"},
+		"owner":  {Path: "$.items[1].owner.name"},
+		"owners": {Path: "$..owner.name"},
+		"page":   {Path: "$.meta.page"},
+	}}
+	got, err := r.ExtractEnv([]byte(jsonPathTestBody))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["next"] != "/items?page=2" || got["total"] != "3" || got["owner"] != "lee" {
+		t.Fatalf("unexpected values: %v", got)
+	}
+	if got["owners"] != `["kim","lee"]` {
+		t.Fatalf("unexpected recursive result: %q", got["owners"])
+	}
+	if got["page"] != `{"next":"/items?page=2"}` {
+		t.Fatalf("unexpected object result: %q", got["page"])
+	}
+}
+
+func TestExtractEnv_JSONPath_NoMatch(t *testing.T) {
+	body := []byte(jsonPathTestBody)
+
+	// skip (default): missing keys are ignored
+	skip := ResponseSpec{EnvFrom: map[string]EnvFrom{"x": {Path: "$.items[9].id"}, "y": {Path: "$.items[?(@.name == 'zeta')].id"}}}
+	got, err := skip.ExtractEnv(body)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected no values and no error, got %v err=%v", got, err)
+	}
+
+	// fail: the error must include the offending expression
+	fail := ResponseSpec{EnvMissing: "fail", EnvFrom: map[string]EnvFrom{"id": {Path: "$.items[0].id"}, "x": {Path: "$.items[?(@.name == 'zeta')].id"}}}
+	got, err = fail.ExtractEnv(body)
+	if err == nil {
+		t.Fatalf("expected error for unmatched JSONPath")
+	}
+	if !strings.Contains(err.Error(), "$.items[?(@.name == 'zeta')].id") {
+		t.Fatalf("expected error to include expression, got %v", err)
+	}
+	if got["id"] != "11" {
+		t.Fatalf("expected already extracted values preserved, got %v", got)
+	}
+}
+
+func TestExtractEnv_JSONPath_BackwardCompatibleDottedPaths(t *testing.T) {
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{"id": {Path: "items.0.id"}, "next": {Path: "meta.page.next"}}}
+	got, err := r.ExtractEnv([]byte(jsonPathTestBody))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got["id"] != "11" || got["next"] != "/items?page=2" {
+		t.Fatalf("unexpected values: %v", got)
+	}
+}
+
+func TestExtractEnv_JSONPath_InvalidExpression(t *testing.T) {
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{"x": {Path: "$.items[abc]"}}}
+	if _, err := r.ExtractEnv([]byte(jsonPathTestBody)); err == nil || !strings.Contains(err.Error(), "$.items[abc]") {
+		t.Fatalf("expected invalid expression error, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package task
+
+import (
+	"context"
+	"slices"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Middleware holds hooks applied to every HTTP request sent by up, down, find and probe
+// requests. Request hooks run before each attempt (retries included), in order, after apirun
+// has set the headers, auth and body; Response hooks run on each response, in order, before
+// the status is validated.
+type Middleware struct {
+	Request  []func(*resty.Request)
+	Response []func(*resty.Response)
+}
+
+type middlewareKey struct{}
+
+// WithMiddleware returns a context whose task requests run mw.
+func WithMiddleware(ctx context.Context, mw Middleware) context.Context {
+	if len(mw.Request) == 0 && len(mw.Response) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, middlewareKey{}, mw)
+}
+
+// AppendMiddleware returns a context whose task requests run the middleware carried by ctx
+// followed by mw.
+func AppendMiddleware(ctx context.Context, mw Middleware) context.Context {
+	cur, _ := ctx.Value(middlewareKey{}).(Middleware)
+	return WithMiddleware(ctx, Middleware{
+		Request:  slices.Concat(cur.Request, mw.Request),
+		Response: slices.Concat(cur.Response, mw.Response),
+	})
+}
+
+// applyMiddleware registers the middleware carried by ctx on client.
+func applyMiddleware(ctx context.Context, client *resty.Client) {
+	mw, ok := ctx.Value(middlewareKey{}).(Middleware)
+	if !ok {
+		return
+	}
+	for _, fn := range mw.Request {
+		if fn == nil {
+			continue
+		}
+		fn := fn
+		client.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+			fn(r)
+			return nil
+		})
+	}
+	for _, fn := range mw.Response {
+		if fn == nil {
+			continue
+		}
+		fn := fn
+		client.OnAfterResponse(func(_ *resty.Client, r *resty.Response) error {
+			fn(r)
+			return nil
+		})
+	}
+}
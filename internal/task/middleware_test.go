@@ -0,0 +1,50 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestMiddleware_RunsPerAttempt(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Attempt") == "" {
+			t.Errorf("middleware header missing")
+		}
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var attempts []string
+	var statuses []int
+	ctx := WithMiddleware(context.Background(), Middleware{
+		Request: []func(*resty.Request){nil, func(r *resty.Request) {
+			attempts = append(attempts, r.Method)
+			r.SetHeader("X-Attempt", "1")
+		}},
+		Response: []func(*resty.Response){func(r *resty.Response) { statuses = append(statuses, r.StatusCode()) }},
+	})
+	u := Up{Env: env.New(), Request: RequestSpec{Method: http.MethodPut, URL: srv.URL, Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: 1, MaxBackoff: 1}}}
+	if _, err := u.Execute(ctx, "", ""); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !reflect.DeepEqual(attempts, []string{"PUT", "PUT"}) || !reflect.DeepEqual(statuses, []int{503, 200}) {
+		t.Fatalf("attempts=%v statuses=%v", attempts, statuses)
+	}
+
+	// An empty middleware leaves the context untouched
+	if WithMiddleware(context.Background(), Middleware{}) != context.Background() {
+		t.Fatalf("expected an empty middleware to leave the context untouched")
+	}
+}
@@ -0,0 +1,209 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/internal/httpc"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// MultipartSpec builds a multipart/form-data body from plain fields and file parts.
+type MultipartSpec struct {
+	// Fields are plain form fields; values are templated.
+	Fields map[string]string `yaml:"fields"`
+	// Files are streamed from disk (or RequestSpec.FS) when the request is sent.
+	Files []MultipartFile `yaml:"files"`
+}
+
+// MultipartFile is a file part of a multipart body.
+type MultipartFile struct {
+	// Field is the form field name of the part.
+	Field string `yaml:"field"`
+	// Path locates the file like body_file does; it is templated.
+	Path string `yaml:"path"`
+	// FileName is the file name sent with the part (default: the base name of Path).
+	FileName string `yaml:"filename"`
+	// ContentType of the part (default: application/octet-stream).
+	ContentType string `yaml:"content_type"`
+}
+
+// renderedMultipart is a MultipartSpec with its templates resolved, ready to be streamed.
+type renderedMultipart struct {
+	fields   [][2]string // name, value; sorted by name
+	files    []MultipartFile
+	fsys     fs.FS
+	boundary string
+}
+
+// renderMultipart resolves the field values and file paths of r.Multipart. The files are only
+// checked for existence here; they are read when the request body is streamed.
+func (r RequestSpec) renderMultipart(e *env.Env) (*renderedMultipart, error) {
+	m := r.Multipart
+	out := &renderedMultipart{fsys: r.FS, boundary: multipart.NewWriter(io.Discard).Boundary()}
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		val, err := renderValue(e, m.Fields[name])
+		if err != nil {
+			return nil, fmt.Errorf("multipart field %s: %w", name, err)
+		}
+		out.fields = append(out.fields, [2]string{name, val})
+	}
+	for i, f := range m.Files {
+		if strings.TrimSpace(f.Field) == "" || strings.TrimSpace(f.Path) == "" {
+			return nil, fmt.Errorf("multipart files[%d]: field and path are required", i)
+		}
+		p, err := renderValue(e, f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("multipart files[%d] path: %w", i, err)
+		}
+		f.Path = p
+		if strings.TrimSpace(f.FileName) == "" {
+			f.FileName = path.Base(filepath.ToSlash(p))
+		}
+		if strings.TrimSpace(f.ContentType) == "" {
+			f.ContentType = "application/octet-stream"
+		}
+		if _, err := out.stat(p); err != nil {
+			return nil, fmt.Errorf("multipart files[%d]: %w", i, err)
+		}
+		out.files = append(out.files, f)
+	}
+	return out, nil
+}
+
+func (m *renderedMultipart) stat(p string) (fs.FileInfo, error) {
+	if m.fsys != nil {
+		return fs.Stat(m.fsys, path.Clean(p))
+	}
+	return os.Stat(filepath.Clean(p))
+}
+
+func (m *renderedMultipart) open(p string) (io.ReadCloser, error) {
+	if m.fsys != nil {
+		return m.fsys.Open(path.Clean(p))
+	}
+	return os.Open(filepath.Clean(p))
+}
+
+// contentType is the Content-Type header of the body, including its boundary.
+func (m *renderedMultipart) contentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// reader returns a new reader streaming the encoded body; files are copied part by part, so
+// they are never held in memory as a whole. Nothing is written before the first Read, and
+// closing the reader (the HTTP transport does when the request ends) stops the writer.
+func (m *renderedMultipart) reader() io.ReadCloser {
+	return &multipartReader{body: m}
+}
+
+// multipartReader pipes the output of renderedMultipart.write, started on the first Read.
+type multipartReader struct {
+	body *renderedMultipart
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (r *multipartReader) start() {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		r.pr = pr
+		go func() {
+			pw.CloseWithError(r.body.write(pw))
+		}()
+	})
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.start()
+	return r.pr.Read(p)
+}
+
+func (r *multipartReader) Close() error {
+	r.once.Do(func() {})
+	if r.pr != nil {
+		return r.pr.Close()
+	}
+	return nil
+}
+
+func (m *renderedMultipart) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, kv := range m.fields {
+		if err := mw.WriteField(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	for _, f := range m.files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.Field), escapeQuotes(f.FileName)))
+		h.Set("Content-Type", f.ContentType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		src, err := m.open(f.Path)
+		if err != nil {
+			return fmt.Errorf("multipart file %s: %w", f.Path, err)
+		}
+		_, err = io.Copy(part, src)
+		_ = src.Close()
+		if err != nil {
+			return fmt.Errorf("multipart file %s: %w", f.Path, err)
+		}
+	}
+	return mw.Close()
+}
+
+// describe summarizes the body for dry-run output without reading the files.
+func (m *renderedMultipart) describe() string {
+	var b strings.Builder
+	for _, kv := range m.fields {
+		fmt.Fprintf(&b, "%s=%s\n", kv[0], kv[1])
+	}
+	for _, f := range m.files {
+		fmt.Fprintf(&b, "%s=@%s (%s, %s)\n", f.Field, f.Path, f.FileName, f.ContentType)
+	}
+	return b.String()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string { return quoteEscaper.Replace(s) }
+
+// buildMultipartRequest is buildRequest for a multipart body. The body is streamed anew for
+// every attempt, so retries resend the complete payload.
+func buildMultipartRequest(ctx context.Context, headers map[string]string, queries map[string]string, body *renderedMultipart, retry *RetryPolicy, sg *awsV4Signer) *resty.Request {
+	h := httpc.Httpc{Retry: retry, Shared: httpc.SharedTransport(ctx)}
+	client := h.New()
+	client.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+		r.SetBody(body.reader())
+		return nil
+	})
+	applyTransport(ctx, client)
+	applyMiddleware(ctx, client)
+	applyResponseLimit(ctx, client)
+	applyCookieJar(ctx, client)
+	applySigner(client, sg)
+	replaceHeader(headers, "Content-Type", body.contentType())
+	return client.R().SetContext(ctx).SetHeaders(headers).SetQueryParams(queries)
+}
@@ -0,0 +1,183 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestUp_Execute_FormBody(t *testing.T) {
+	var ct string
+	var got map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct = r.Header.Get("Content-Type")
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		got = r.PostForm
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	u := Up{
+		Env: &env.Env{Local: env.FromStringMap(map[string]string{"user": "alice"})},
+		Request: RequestSpec{Method: http.MethodPost, URL: srv.URL, Form: map[string]string{
+			"username":   "{{.env.user}}",
+			"note":       "a&b=c d",
+			"grant_type": "client_credentials",
+		}},
+	}
+	if _, err := u.Execute(context.Background(), "", ""); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if ct != "application/x-www-form-urlencoded" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if got["username"][0] != "alice" || got["note"][0] != "a&b=c d" || got["grant_type"][0] != "client_credentials" {
+		t.Fatalf("unexpected form values: %v", got)
+	}
+
+	plan, err := u.Plan("", "")
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Body != "grant_type=client_credentials&note=a%26b%3Dc+d&username=alice" {
+		t.Fatalf("unexpected planned body: %q", plan.Body)
+	}
+}
+
+func TestUp_Execute_MultipartBody(t *testing.T) {
+	dir := t.TempDir()
+	avatar := strings.Repeat("0123456789", 100000) // 1MB, larger than any single pipe write
+	if err := os.WriteFile(filepath.Join(dir, "avatar.png"), []byte(avatar), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary=") {
+			t.Errorf("unexpected Content-Type %q", r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("name") != "alice" || r.FormValue("role") != "admin" {
+			t.Errorf("unexpected fields: %v", r.MultipartForm.Value)
+		}
+		for field, want := range map[string][3]string{
+			"avatar": {"me.png", "image/png", avatar},
+			"notes":  {"notes.txt", "application/octet-stream", "hello"},
+		} {
+			f, h, err := r.FormFile(field)
+			if err != nil {
+				t.Errorf("file %s: %v", field, err)
+				continue
+			}
+			data, _ := io.ReadAll(f)
+			_ = f.Close()
+			if h.Filename != want[0] || h.Header.Get("Content-Type") != want[1] || string(data) != want[2] {
+				t.Errorf("file %s: got %s %s (%d bytes)", field, h.Filename, h.Header.Get("Content-Type"), len(data))
+			}
+		}
+		// The first attempt fails, so the retry must stream the whole body again
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	u := Up{
+		Env: &env.Env{Local: env.FromStringMap(map[string]string{"name": "alice", "dir": dir})},
+		Request: RequestSpec{
+			Method:  http.MethodPost,
+			URL:     srv.URL,
+			Headers: []Header{{Name: "content-type", Value: "application/json"}},
+			Multipart: &MultipartSpec{
+				Fields: map[string]string{"name": "{{.env.name}}", "role": "admin"},
+				Files: []MultipartFile{
+					{Field: "avatar", Path: "{{.env.dir}}/avatar.png", FileName: "me.png", ContentType: "image/png"},
+					{Field: "notes", Path: filepath.Join(dir, "notes.txt")},
+				},
+			},
+			Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: 1, MaxBackoff: 1},
+		},
+		Response: ResponseSpec{ResultCode: []string{"201"}},
+	}
+	res, err := u.Execute(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if res.StatusCode != http.StatusCreated || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("status=%d attempts=%d", res.StatusCode, attempts)
+	}
+
+	plan, err := u.Plan("", "")
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Headers["Content-Type"] != "multipart/form-data" || !strings.Contains(plan.Body, "avatar=@"+dir+"/avatar.png (me.png, image/png)") {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestUp_Execute_MultipartFromFS(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("doc")
+		if err != nil {
+			t.Errorf("file: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		got = string(data)
+	}))
+	defer srv.Close()
+
+	u := Up{Env: env.New(), Request: RequestSpec{
+		Method:    http.MethodPost,
+		URL:       srv.URL,
+		FS:        fstest.MapFS{"files/doc.txt": {Data: []byte("from fs")}},
+		Multipart: &MultipartSpec{Files: []MultipartFile{{Field: "doc", Path: "files/doc.txt"}}},
+	}}
+	if _, err := u.Execute(context.Background(), "", ""); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got != "from fs" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestUp_Execute_MultipartErrors(t *testing.T) {
+	cases := map[string]RequestSpec{
+		"missing file":       {Multipart: &MultipartSpec{Files: []MultipartFile{{Field: "f", Path: filepath.Join(t.TempDir(), "nope")}}}},
+		"missing field":      {Multipart: &MultipartSpec{Files: []MultipartFile{{Path: "x"}}}},
+		"with body":          {Body: "x", Multipart: &MultipartSpec{}},
+		"form and body":      {BodyJSON: map[string]interface{}{}, Form: map[string]string{"a": "b"}},
+		"form and multipart": {Form: map[string]string{"a": "b"}, Multipart: &MultipartSpec{}},
+	}
+	for name, req := range cases {
+		t.Run(name, func(t *testing.T) {
+			req.Method, req.URL = http.MethodPost, "http://127.0.0.1:1"
+			u := Up{Env: env.New(), Request: req}
+			if _, err := u.Execute(context.Background(), "", ""); err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
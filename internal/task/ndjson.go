@@ -0,0 +1,202 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/pkg/env"
+	"github.com/tidwall/gjson"
+)
+
+// ResponseModeNDJSON reads the response body as newline-delimited JSON (see NDJSONSpec).
+const ResponseModeNDJSON = "ndjson"
+
+// NDJSONSpec configures the ndjson response mode, for endpoints of long-running operations that
+// stream a JSON status object per line and end with the result. The body is read line by line
+// (within the maximum response body size) and the selected line, the last one or the last one
+// matching Match, stands for the body: env_from, on_match and probe assertions read it.
+type NDJSONSpec struct {
+	// Match selects the last line whose values at the paths equal the given (templated) values,
+	// e.g. {type: result}; an empty value only requires the path to exist.
+	Match map[string]string `yaml:"match"`
+	// Assert fails the step unless the selected line has these (templated) values, e.g.
+	// {status: succeeded}; an empty value only requires the path to exist.
+	Assert map[string]string `yaml:"assert"`
+}
+
+// validate checks that no Match or Assert path is empty.
+func (n *NDJSONSpec) validate() error {
+	for path := range n.Match {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("match has an empty path")
+		}
+	}
+	for path := range n.Assert {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("assert has an empty path")
+		}
+	}
+	return nil
+}
+
+// isNDJSON reports whether Mode is "ndjson".
+func (r ResponseSpec) isNDJSON() bool {
+	return strings.EqualFold(strings.TrimSpace(r.Mode), ResponseModeNDJSON)
+}
+
+// ndjsonMaxLine bounds one line of an ndjson stream read without a maximum response body size.
+const ndjsonMaxLine = 16 << 20
+
+// ndjsonReadError is a failure to read an ndjson stream, as opposed to a line failing the checks.
+type ndjsonReadError struct{ err error }
+
+func (e *ndjsonReadError) Error() string { return "read ndjson response: " + e.err.Error() }
+func (e *ndjsonReadError) Unwrap() error { return e.err }
+
+// streams reports whether the response body is read as a stream, without buffering it: in ndjson
+// mode, except for GraphQL responses (gql), whose data object is unwrapped from the whole body.
+func (r ResponseSpec) streams(gql bool) bool { return r.isNDJSON() && !gql }
+
+// prepare makes req leave the response body unread when it streams, for readDocument.
+func (r ResponseSpec) prepare(req *resty.Request, gql bool) {
+	if r.streams(gql) {
+		req.SetDoNotParseResponse(true)
+	}
+}
+
+// readDocument returns the body of resp and what the response checks and extraction read, with
+// its content type: the GraphQL data object when gql is set, then the document of that (see
+// document). A streamed body is read line by line, and the selected line stands for the body
+// too. Failures to read it are an *ndjsonReadError.
+func (r ResponseSpec) readDocument(ctx context.Context, resp *resty.Response, gql bool, e *env.Env) (body, doc []byte, contentType string, err error) {
+	if r.streams(gql) {
+		raw := resp.RawBody()
+		defer func() { _ = raw.Close() }()
+		maxLine := ndjsonMaxLine
+		if limit := responseLimit(ctx); limit > 0 && limit < int64(maxLine) {
+			maxLine = int(limit)
+		}
+		line, err := r.selectLine(limitBody(ctx, raw), maxLine, e)
+		return line, line, "", err
+	}
+	body, contentType = resp.Body(), resp.Header().Get("Content-Type")
+	doc = body
+	if gql {
+		if doc, err = graphQLData(body); err != nil {
+			return body, nil, "", err
+		}
+		contentType = ""
+	}
+	doc, contentType, err = r.document(doc, contentType, e)
+	return body, doc, contentType, err
+}
+
+// responseBody returns the body of resp for error reports, reading a streamed one within the
+// maximum response body size of ctx.
+func (r ResponseSpec) responseBody(ctx context.Context, resp *resty.Response, gql bool) []byte {
+	if !r.streams(gql) {
+		return resp.Body()
+	}
+	raw := resp.RawBody()
+	defer func() { _ = raw.Close() }()
+	body, _ := io.ReadAll(limitBody(ctx, raw))
+	return body
+}
+
+// document returns what the response checks and extraction read, with its content type: body
+// itself, or in ndjson mode the selected line (as JSON), which must pass the NDJSON assertions.
+// Errors are assertion failures.
+func (r ResponseSpec) document(body []byte, contentType string, e *env.Env) ([]byte, string, error) {
+	if !r.isNDJSON() {
+		return body, contentType, nil
+	}
+	line, err := r.selectLine(bytes.NewReader(body), max(len(body), bufio.MaxScanTokenSize), e)
+	return line, "", err
+}
+
+// selectLine scans the ndjson stream rd, whose lines are at most maxLine bytes, and returns the
+// last line matching NDJSON.Match once it passes NDJSON.Assert.
+func (r ResponseSpec) selectLine(rd io.Reader, maxLine int, e *env.Env) ([]byte, error) {
+	spec := r.NDJSON
+	if spec == nil {
+		spec = &NDJSONSpec{}
+	}
+	match, err := renderExpected(e, spec.Match)
+	if err != nil {
+		return nil, fmt.Errorf("ndjson match %w", err)
+	}
+	want, err := renderExpected(e, spec.Assert)
+	if err != nil {
+		return nil, fmt.Errorf("ndjson assert %w", err)
+	}
+
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 0, min(maxLine, bufio.MaxScanTokenSize)), maxLine)
+	var selected []byte
+	n, lines := 0, 0
+	for sc.Scan() {
+		n++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !gjson.ValidBytes(line) || !gjson.ParseBytes(line).IsObject() {
+			// A read error ends the stream with what was read of the line
+			if sc.Err() != nil {
+				break
+			}
+			return nil, fmt.Errorf("ndjson line %d is not a JSON object", n)
+		}
+		lines++
+		if checkAssertions(line, match) == "" {
+			// The scanner reuses its buffer for the next line
+			selected = append(selected[:0], line...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line %d is longer than %d bytes: %w", n+1, maxLine, err)
+		}
+		return nil, &ndjsonReadError{err}
+	}
+	switch {
+	case lines == 0:
+		return nil, fmt.Errorf("ndjson response has no JSON lines")
+	case selected == nil:
+		return nil, fmt.Errorf("ndjson: none of the %d lines matches %s", lines, describeExpected(match))
+	}
+	if reason := checkAssertions(selected, want); reason != "" {
+		return nil, fmt.Errorf("ndjson %s", reason)
+	}
+	return selected, nil
+}
+
+// renderExpected renders the templated values of path assertions.
+func renderExpected(e *env.Env, values map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(values))
+	for path, v := range values {
+		rendered, err := renderValue(e, v)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", path, err)
+		}
+		out[path] = rendered
+	}
+	return out, nil
+}
+
+// describeExpected renders path assertions as "a=1, b=2", in path order.
+func describeExpected(values map[string]string) string {
+	parts := make([]string, 0, len(values))
+	for path, v := range values {
+		parts = append(parts, path+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,173 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// ndjsonServer streams lines as NDJSON, flushing after each one like a long-running operation.
+func ndjsonServer(t *testing.T, lines ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		for _, line := range lines {
+			_, _ = w.Write([]byte(line + "\n"))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestUp_Execute_NDJSON_ExtractsFromLastLine(t *testing.T) {
+	srv := ndjsonServer(t,
+		`{"status":"running","progress":10}`,
+		`{"status":"running","progress":80}`,
+		``,
+		`{"status":"succeeded","progress":100,"result":{"id":"job-7"}}`,
+	)
+	up := Up{
+		Env:     env.New(),
+		Request: RequestSpec{Method: http.MethodPost, URL: srv.URL},
+		Response: ResponseSpec{
+			ResultCode: []string{"200"},
+			Mode:       ResponseModeNDJSON,
+			NDJSON:     &NDJSONSpec{Assert: map[string]string{"status": "succeeded"}},
+			EnvFrom:    map[string]EnvFrom{"job_id": {Path: "result.id"}, "progress": {Path: "$.progress"}},
+			EnvMissing: "fail",
+		},
+	}
+	res, err := up.Execute(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ExtractedEnv["job_id"] != "job-7" || res.ExtractedEnv["progress"] != "100" {
+		t.Fatalf("unexpected extracted env: %v", res.ExtractedEnv)
+	}
+	// Only the selected line is kept as the response body
+	if want := `{"status":"succeeded","progress":100,"result":{"id":"job-7"}}`; res.ResponseBody != want {
+		t.Fatalf("expected the last line as the body, got %q", res.ResponseBody)
+	}
+}
+
+func TestUp_Execute_NDJSON_MatchSelectsLine(t *testing.T) {
+	srv := ndjsonServer(t,
+		`{"type":"status","state":"queued"}`,
+		`{"type":"result","id":"r1","state":"done"}`,
+		`{"type":"status","state":"closing"}`,
+	)
+	up := Up{
+		Env:     &env.Env{Local: env.FromStringMap(map[string]string{"kind": "result"})},
+		Request: RequestSpec{Method: http.MethodGet, URL: srv.URL},
+		Response: ResponseSpec{
+			Mode:    ResponseModeNDJSON,
+			NDJSON:  &NDJSONSpec{Match: map[string]string{"type": "{{.env.kind}}"}, Assert: map[string]string{"state": "done"}},
+			EnvFrom: map[string]EnvFrom{"result_id": {Path: "id"}},
+			OnMatch: &OnMatchSpec{Path: "state", Equals: "done", Action: OnMatchSkipNext},
+		},
+	}
+	res, err := up.Execute(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ExtractedEnv["result_id"] != "r1" {
+		t.Fatalf("unexpected extracted env: %v", res.ExtractedEnv)
+	}
+	if res.Skip == nil || !res.Skip.Next {
+		t.Fatalf("expected on_match to read the matched line, got %+v", res.Skip)
+	}
+}
+
+func TestUp_Execute_NDJSON_Failures(t *testing.T) {
+	cases := map[string]struct {
+		lines []string
+		spec  *NDJSONSpec
+		want  string
+	}{
+		"final status": {
+			lines: []string{`{"status":"running"}`, `{"status":"failed","error":"disk full"}`},
+			spec:  &NDJSONSpec{Assert: map[string]string{"status": "succeeded"}},
+			want:  `ndjson assert status: got "failed", want "succeeded"`,
+		},
+		"no match": {
+			lines: []string{`{"type":"status"}`, `{"type":"status"}`},
+			spec:  &NDJSONSpec{Match: map[string]string{"type": "result"}},
+			want:  "none of the 2 lines matches type=result",
+		},
+		"invalid line": {
+			lines: []string{`{"status":"running"}`, `oops`},
+			want:  "ndjson line 2 is not a JSON object",
+		},
+		"empty stream": {
+			want: "ndjson response has no JSON lines",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := ndjsonServer(t, tc.lines...)
+			up := Up{
+				Env:      env.New(),
+				Request:  RequestSpec{Method: http.MethodGet, URL: srv.URL},
+				Response: ResponseSpec{Mode: ResponseModeNDJSON, NDJSON: tc.spec},
+			}
+			res, err := up.Execute(context.Background(), "", "")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+			if res == nil || res.Error == nil || res.Error.Reason != ReasonAssertion {
+				t.Fatalf("expected an assertion failure, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestUp_Execute_NDJSON_ResponseLimit(t *testing.T) {
+	lines := make([]string, 0, 64)
+	for i := 0; i < 64; i++ {
+		lines = append(lines, `{"status":"running","pad":"`+strings.Repeat("x", ResponseLimitMargin/32)+`"}`)
+	}
+	srv := ndjsonServer(t, lines...)
+	up := Up{
+		Env:      env.New(),
+		Request:  RequestSpec{Method: http.MethodGet, URL: srv.URL},
+		Response: ResponseSpec{Mode: ResponseModeNDJSON},
+	}
+	ctx := WithResponseLimit(context.Background(), 16)
+	if _, err := up.Execute(ctx, "", ""); err == nil || !strings.Contains(err.Error(), "response body exceeds") {
+		t.Fatalf("expected an over-limit error, got %v", err)
+	}
+}
+
+func TestResponseSpec_Validate_Mode(t *testing.T) {
+	cases := map[string]struct {
+		spec ResponseSpec
+		want string
+	}{
+		"ndjson":         {spec: ResponseSpec{Mode: "NDJSON", NDJSON: &NDJSONSpec{Match: map[string]string{"type": "result"}}}},
+		"unknown mode":   {spec: ResponseSpec{Mode: "sse"}, want: `unknown mode "sse"`},
+		"ndjson no mode": {spec: ResponseSpec{NDJSON: &NDJSONSpec{}}, want: "ndjson requires mode: ndjson"},
+		"empty path":     {spec: ResponseSpec{Mode: "ndjson", NDJSON: &NDJSONSpec{Assert: map[string]string{" ": "x"}}}, want: "ndjson: assert has an empty path"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.spec.Validate()
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// On-match actions.
+const (
+	// OnMatchSkipNext skips the next pending migration.
+	OnMatchSkipNext = "skip_next"
+	// onMatchSkipToPrefix, followed by a version N, skips the pending migrations below N.
+	onMatchSkipToPrefix = "skip_to:"
+)
+
+// OnMatchSpec lets a successful up response skip later migrations: when the value at Path in
+// the response body equals Equals (templated), Action is applied to the pending versions.
+// Skipped versions are recorded as applied without sending their requests.
+type OnMatchSpec struct {
+	Path   string `yaml:"path"`
+	Equals string `yaml:"equals"`
+	// Action is "skip_next" or "skip_to:N".
+	Action string `yaml:"action"`
+}
+
+// SkipAction is a triggered on_match action.
+type SkipAction struct {
+	// Next skips only the next pending migration.
+	Next bool
+	// To skips the pending migrations with a version below it.
+	To int
+}
+
+// Validate checks that Path is set and Action is well-formed.
+func (o *OnMatchSpec) Validate() error {
+	if strings.TrimSpace(o.Path) == "" {
+		return fmt.Errorf("on_match: path is required")
+	}
+	if _, err := o.action(); err != nil {
+		return fmt.Errorf("on_match: %w", err)
+	}
+	return nil
+}
+
+func (o *OnMatchSpec) action() (*SkipAction, error) {
+	a := strings.TrimSpace(o.Action)
+	if a == OnMatchSkipNext {
+		return &SkipAction{Next: true}, nil
+	}
+	if v, ok := strings.CutPrefix(a, onMatchSkipToPrefix); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid skip_to version %q", v)
+		}
+		return &SkipAction{To: n}, nil
+	}
+	return nil, fmt.Errorf("invalid action %q: must be %s or %sN", o.Action, OnMatchSkipNext, onMatchSkipToPrefix)
+}
+
+// evaluate returns the action to apply when body matches, or nil when it does not. A missing
+// path does not match.
+func (o *OnMatchSpec) evaluate(body []byte, e *env.Env) (*SkipAction, error) {
+	want, err := renderValue(e, o.Equals)
+	if err != nil {
+		return nil, fmt.Errorf("on_match equals: %w", err)
+	}
+	lookup := ResponseSpec{EnvFrom: map[string]EnvFrom{o.Path: {Path: o.Path}}}
+	got, err := lookup.ExtractEnv(body)
+	if err != nil {
+		return nil, fmt.Errorf("on_match: %w", err)
+	}
+	if v, ok := got[o.Path]; !ok || v != want {
+		return nil, nil
+	}
+	return o.action()
+}
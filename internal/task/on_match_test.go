@@ -0,0 +1,90 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestUp_Execute_OnMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"feature":{"state":"enabled"}}`))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name string
+		spec OnMatchSpec
+		want *SkipAction
+	}{
+		{"skip_next", OnMatchSpec{Path: "feature.state", Equals: "{{.env.state}}", Action: "skip_next"}, &SkipAction{Next: true}},
+		{"skip_to", OnMatchSpec{Path: "$.feature.state", Equals: "enabled", Action: "skip_to:7"}, &SkipAction{To: 7}},
+		{"no match", OnMatchSpec{Path: "feature.state", Equals: "disabled", Action: "skip_next"}, nil},
+		{"missing path", OnMatchSpec{Path: "feature.owner", Equals: "", Action: "skip_next"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := tc.spec
+			u := Up{
+				Env:      &env.Env{Local: env.FromStringMap(map[string]string{"state": "enabled"})},
+				Request:  RequestSpec{Method: http.MethodGet, URL: srv.URL},
+				Response: ResponseSpec{OnMatch: &spec},
+			}
+			res, err := u.Execute(context.Background(), "", "")
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			switch {
+			case tc.want == nil && res.Skip != nil:
+				t.Fatalf("expected no skip, got %+v", res.Skip)
+			case tc.want != nil && (res.Skip == nil || *res.Skip != *tc.want):
+				t.Fatalf("skip = %+v, want %+v", res.Skip, tc.want)
+			}
+		})
+	}
+}
+
+func TestUp_Execute_OnMatchIgnoredOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"exists":true}`))
+	}))
+	defer srv.Close()
+
+	u := Up{Env: env.New(), Request: RequestSpec{Method: http.MethodPost, URL: srv.URL}, Response: ResponseSpec{
+		ResultCode: []string{"2xx"},
+		OnMatch:    &OnMatchSpec{Path: "exists", Equals: "true", Action: "skip_next"},
+	}}
+	res, err := u.Execute(context.Background(), "", "")
+	if err == nil || res.Skip != nil {
+		t.Fatalf("expected a failure without skip, got %+v, %v", res, err)
+	}
+}
+
+func TestTaskDecode_OnMatchValidation(t *testing.T) {
+	cases := map[string]struct {
+		doc  string
+		want string
+	}{
+		"missing path":   {"up:\n  request: { method: GET, url: http://x }\n  response:\n    on_match: { equals: x, action: skip_next }", "path is required"},
+		"unknown action": {"up:\n  request: { method: GET, url: http://x }\n  response:\n    on_match: { path: a, action: goto }", `invalid action "goto"`},
+		"bad skip_to":    {"up:\n  request: { method: GET, url: http://x }\n  response:\n    on_match: { path: a, action: 'skip_to:0' }", "invalid skip_to version"},
+		"in probe": {"up:\n  request: { method: GET, url: http://x }\n  probe:\n    request: { url: http://x }\n" +
+			"    response:\n      on_match: { path: a, action: skip_next }", "up.probe.response"},
+		"in down.find": {"down:\n  find:\n    request: { method: GET, url: http://x }\n" +
+			"    response:\n      on_match: { path: a, action: skip_next }", "down.find.response"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var tk Task
+			err := tk.DecodeYAML(strings.NewReader(tc.doc + "\n"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package task
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// defaultMaxPages bounds a paginated find when max_pages is not set.
+const defaultMaxPages = 10
+
+// PaginateSpec makes the find request walk a paginated list endpoint: while the current page
+// does not yield every env_from value, the next page is requested, up to MaxPages pages.
+type PaginateSpec struct {
+	// Next selects the next page from the response body (gjson path, or JSONPath when it starts
+	// with "$."). An empty or missing value means there are no more pages.
+	Next string `yaml:"next"`
+	// CursorParam, when set, sends the Next value as this query parameter on the find URL.
+	// Otherwise the Next value is the URL of the next page, absolute or relative to the current one.
+	CursorParam string `yaml:"cursor_param"`
+	// MaxPages is the maximum number of pages requested, including the first (default 10).
+	MaxPages int `yaml:"max_pages"`
+}
+
+// Validate checks that a next expression is set and that MaxPages is not negative.
+func (p PaginateSpec) Validate() error {
+	if strings.TrimSpace(p.Next) == "" {
+		return fmt.Errorf("paginate.next is required")
+	}
+	if p.MaxPages < 0 {
+		return fmt.Errorf("paginate.max_pages must not be negative, got %d", p.MaxPages)
+	}
+	return nil
+}
+
+func (p PaginateSpec) maxPages() int {
+	if p.MaxPages <= 0 {
+		return defaultMaxPages
+	}
+	return p.MaxPages
+}
+
+// next returns the next page selector found in body, or "" when there is none.
+func (p PaginateSpec) next(body []byte) (string, error) {
+	expr := strings.TrimSpace(p.Next)
+	if isJSONPath(expr) {
+		v, ok, err := evalJSONPath(body, expr)
+		if err != nil || !ok || v == nil {
+			return "", err
+		}
+		return strings.TrimSpace(anyToString(v)), nil
+	}
+	res := gjson.GetBytes(body, expr)
+	if !res.Exists() || res.Type == gjson.Null {
+		return "", nil
+	}
+	return strings.TrimSpace(res.String()), nil
+}
+
+// nextRequest returns the URL and queries of the page selected by next, given the current ones.
+// A next URL replaces the configured queries since it is expected to carry its own.
+func (p PaginateSpec) nextRequest(current string, queries map[string]string, next string) (string, map[string]string, error) {
+	if p.CursorParam != "" {
+		out := make(map[string]string, len(queries)+1)
+		for k, v := range queries {
+			out[k] = v
+		}
+		out[p.CursorParam] = next
+		return current, out, nil
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", nil, fmt.Errorf("down.find.paginate: invalid url %q: %w", current, err)
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", nil, fmt.Errorf("down.find.paginate: invalid next page url %q: %w", next, err)
+	}
+	return base.ResolveReference(ref).String(), map[string]string{}, nil
+}
+
+// matches reports whether body yields every env_from value of the find response.
+func (f *FindSpec) matches(body []byte) bool {
+	probe := f.Response
+	probe.EnvMissing = "fail"
+	_, err := probe.ExtractEnv(body)
+	return err == nil
+}
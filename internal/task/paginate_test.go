@@ -0,0 +1,149 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// pagedUsers serves GET /users?page=N (or ?cursor=cN) with alice on page 3 of 5 and records
+// the requested pages; DELETE /users/{id} is accepted.
+func pagedUsers(t *testing.T, pages *[]int, deleted *string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			*deleted = strings.TrimPrefix(r.URL.Path, "/users/")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			page, _ = strconv.Atoi(strings.TrimPrefix(c, "c"))
+		}
+		*pages = append(*pages, page)
+		name := fmt.Sprintf("user%d", page)
+		if page == 3 {
+			name = "alice"
+		}
+		next, cursor := "null", "null"
+		if page < 5 {
+			next = fmt.Sprintf(`"/users?page=%d"`, page+1)
+			cursor = fmt.Sprintf(`"c%d"`, page+1)
+		}
+		_, _ = fmt.Fprintf(w, `{"items":[{"id":"%d","name":%q}],"next":%s,"cursor":%s}`, page*10, name, next, cursor)
+	}))
+}
+
+func pagedDown(base string, p *PaginateSpec) Down {
+	return Down{
+		Env: &env.Env{Local: env.Map{}},
+		Find: &FindSpec{
+			Request: RequestSpec{Method: http.MethodGet, URL: base + "/users"},
+			Response: ResponseSpec{
+				ResultCode: []string{"200"},
+				EnvFrom:    map[string]EnvFrom{"user_id": {Path: `items.#(name=="alice").id`}},
+				EnvMissing: "fail",
+			},
+			Paginate: p,
+		},
+		Method: http.MethodDelete,
+		URL:    base + "/users/{{.env.user_id}}",
+	}
+}
+
+func TestDown_Find_Paginate_FollowsNextURL(t *testing.T) {
+	var pages []int
+	var deleted string
+	srv := pagedUsers(t, &pages, &deleted)
+	defer srv.Close()
+
+	d := pagedDown(srv.URL, &PaginateSpec{Next: "next"})
+	if _, err := d.Execute(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if fmt.Sprint(pages) != "[1 2 3]" {
+		t.Fatalf("expected pages 1..3 to be requested, got %v", pages)
+	}
+	if deleted != "30" {
+		t.Fatalf("expected id from page 3 to be deleted, got %q", deleted)
+	}
+}
+
+func TestDown_Find_Paginate_CursorParam(t *testing.T) {
+	var pages []int
+	var deleted string
+	srv := pagedUsers(t, &pages, &deleted)
+	defer srv.Close()
+
+	d := pagedDown(srv.URL, &PaginateSpec{Next: "$.cursor", CursorParam: "cursor"})
+	if _, err := d.Execute(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if fmt.Sprint(pages) != "[1 2 3]" || deleted != "30" {
+		t.Fatalf("expected match on page 3, got pages=%v deleted=%q", pages, deleted)
+	}
+}
+
+func TestDown_Find_Paginate_StopsAtMaxPages(t *testing.T) {
+	var pages []int
+	var deleted string
+	srv := pagedUsers(t, &pages, &deleted)
+	defer srv.Close()
+
+	d := pagedDown(srv.URL, &PaginateSpec{Next: "next", MaxPages: 2})
+	res, err := d.Execute(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "user_id") {
+		t.Fatalf("expected missing env error, got %v", err)
+	}
+	if res == nil || res.StatusCode != 200 {
+		t.Fatalf("expected result of the last find page, got %+v", res)
+	}
+	if fmt.Sprint(pages) != "[1 2]" || deleted != "" {
+		t.Fatalf("expected two pages and no delete, got pages=%v deleted=%q", pages, deleted)
+	}
+}
+
+func TestDown_Find_Paginate_StopsWithoutNext(t *testing.T) {
+	var pages []int
+	var deleted string
+	srv := pagedUsers(t, &pages, &deleted)
+	defer srv.Close()
+
+	d := pagedDown(srv.URL, &PaginateSpec{Next: "next", MaxPages: 20})
+	d.Find.Response.EnvFrom = map[string]EnvFrom{"user_id": {Path: `items.#(name=="nobody").id`}}
+	if _, err := d.Execute(context.Background()); err == nil {
+		t.Fatal("expected missing env error")
+	}
+	if len(pages) != 5 {
+		t.Fatalf("expected all 5 pages to be requested, got %v", pages)
+	}
+}
+
+func TestPaginateSpec_ValidateOnDecode(t *testing.T) {
+	cases := map[string]string{
+		"missing next": "down:\n  find:\n    paginate:\n      max_pages: 3\n",
+		"negative max": "down:\n  find:\n    paginate:\n      next: next\n      max_pages: -1\n",
+	}
+	for name, doc := range cases {
+		var tk Task
+		if err := tk.DecodeYAML(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), "paginate") {
+			t.Fatalf("%s: expected paginate error, got %v", name, err)
+		}
+	}
+	var tk Task
+	if err := tk.DecodeYAML(strings.NewReader("down:\n  find:\n    paginate:\n      next: links.next\n")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if tk.Down.Find.Paginate.maxPages() != defaultMaxPages {
+		t.Fatalf("expected default max pages, got %d", tk.Down.Find.Paginate.maxPages())
+	}
+}
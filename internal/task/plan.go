@@ -0,0 +1,48 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+// RenderedRequest is a fully rendered HTTP request as it would be sent. It is produced in dry-run
+// mode so the plan can be reviewed without calling the API. Sensitive header and query values as
+// well as secrets inside URL and body are masked with the global Masker.
+type RenderedRequest struct {
+	// Step identifies the request within the task: "up", "down.find" or "down".
+	Step    string            `json:"step"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Queries map[string]string `json:"queries,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+func newRenderedRequest(step, method, url string, hdrs, queries map[string]string, body string) *RenderedRequest {
+	masker := common.GetGlobalMasker()
+	return &RenderedRequest{
+		Step:    step,
+		Method:  method,
+		URL:     masker.MaskString(url),
+		Headers: maskValues(masker, hdrs),
+		Queries: maskValues(masker, queries),
+		Body:    masker.MaskString(body),
+	}
+}
+
+func maskValues(masker *common.Masker, in map[string]string) map[string]string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = fmt.Sprint(masker.MaskValue(k, v))
+	}
+	return out
+}
+
+// PlaceholderValue is rendered in dry runs for values that are only known after a real response.
+func PlaceholderValue(key string) string {
+	return "${" + key + "}"
+}
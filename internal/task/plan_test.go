@@ -0,0 +1,95 @@
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestUpPlan_RendersAndMasks(t *testing.T) {
+	e := env.New()
+	_ = e.SetString("global", "base", "http://api.local")
+	_ = e.SetString("global", "name", "alice")
+	_ = e.SetString("auth", "svc", "tok123")
+	u := Up{
+		Env: e,
+		Request: RequestSpec{
+			Method:  "POST",
+			URL:     "{{.env.base}}/users",
+			Headers: []Header{{Name: "Authorization", Value: "Bearer {{.auth.svc}}"}, {Name: "X-Trace", Value: "t1"}},
+			Queries: []Query{{Name: "api_key", Value: "k1"}, {Name: "q", Value: "{{.env.name}}"}},
+			Body:    `{"name":"{{.env.name}}","password":"p4ss"}`,
+		},
+	}
+	req, err := u.Plan("", "")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if req.Step != "up" || req.Method != "POST" || req.URL != "http://api.local/users" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	if req.Headers["Authorization"] != "***MASKED***" || req.Headers["X-Trace"] != "t1" {
+		t.Fatalf("unexpected headers: %v", req.Headers)
+	}
+	if req.Queries["api_key"] != "***MASKED***" || req.Queries["q"] != "alice" {
+		t.Fatalf("unexpected queries: %v", req.Queries)
+	}
+	if strings.Contains(req.Body, "p4ss") || !strings.Contains(req.Body, `"name":"alice"`) {
+		t.Fatalf("unexpected body: %s", req.Body)
+	}
+}
+
+func TestDownPlan_IncludesFindStep(t *testing.T) {
+	e := env.New()
+	_ = e.SetString("local", "id", "42")
+	d := Down{
+		Env:    e,
+		Method: "delete",
+		URL:    "http://api.local/users/{{.env.id}}",
+		Find: &FindSpec{Request: RequestSpec{
+			Method: "get",
+			URL:    "http://api.local/users",
+		}},
+	}
+	reqs, err := d.Plan()
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("expected find and down requests, got %d", len(reqs))
+	}
+	if reqs[0].Step != "down.find" || reqs[0].Method != "GET" {
+		t.Fatalf("unexpected find request: %+v", reqs[0])
+	}
+	if reqs[1].Step != "down" || reqs[1].Method != "DELETE" || reqs[1].URL != "http://api.local/users/42" {
+		t.Fatalf("unexpected down request: %+v", reqs[1])
+	}
+}
+
+func TestDownPlan_MissingURL_Errors(t *testing.T) {
+	d := Down{Env: env.New(), Method: "DELETE"}
+	if _, err := d.Plan(); err == nil {
+		t.Fatalf("expected error for missing url")
+	}
+}
+
+func TestDownPlan_FindEnvFromPlaceholders(t *testing.T) {
+	d := Down{
+		Env:    env.New(),
+		Method: "DELETE",
+		URL:    "http://api.local/users/{{.env.user_id}}",
+		Body:   `{"id":"{{.env.user_id}}"}`,
+		Find: &FindSpec{
+			Request:  RequestSpec{Method: "GET", URL: "http://api.local/users"},
+			Response: ResponseSpec{EnvFrom: map[string]EnvFrom{"user_id": {Path: "$.items[0].id"}}},
+		},
+	}
+	reqs, err := d.Plan()
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if got := reqs[1].URL; got != "http://api.local/users/${user_id}" {
+		t.Fatalf("expected placeholder in url, got %s", got)
+	}
+}
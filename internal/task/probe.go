@@ -0,0 +1,158 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ProbeSpec is an optional read-only request of an up step that tells whether the change the
+// step makes is already in place, without running it (see the migrator's PlanDiff).
+// Only GET (the default) and HEAD are allowed.
+type ProbeSpec struct {
+	Request RequestSpec `yaml:"request"`
+	// Response.ResultCode lists the statuses meaning "already satisfied" (default: any 2xx);
+	// env_from/header_from values of a satisfied probe are available to later probes.
+	Response ResponseSpec `yaml:"response"`
+	// Assert maps response body paths (gjson, or JSONPath for "$." expressions) to the value
+	// they must have; an empty value only requires the path to exist. Values are templated.
+	Assert map[string]string `yaml:"assert"`
+}
+
+// ProbeResult is the outcome of a probe request.
+type ProbeResult struct {
+	Satisfied  bool
+	StatusCode int
+	// Reason tells why the probe did not count as satisfied; empty when it did.
+	Reason string
+	// ExtractedEnv holds the env_from/header_from values of a satisfied probe.
+	ExtractedEnv map[string]string
+}
+
+// Validate checks the probe method, result codes and assertion paths.
+func (p *ProbeSpec) Validate() error {
+	switch strings.ToUpper(strings.TrimSpace(p.Request.Method)) {
+	case "", http.MethodGet, http.MethodHead:
+	default:
+		return fmt.Errorf("probe must be read-only (GET or HEAD), got method %q", p.Request.Method)
+	}
+	if strings.TrimSpace(p.Request.URL) == "" {
+		return fmt.Errorf("probe request url is required")
+	}
+	for path := range p.Assert {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("probe assert has an empty path")
+		}
+	}
+	return p.Response.Validate()
+}
+
+// RunProbe sends the probe of this Up, rendered with the Up env, and evaluates its response.
+// Transport and template errors are returned as errors; an unexpected status or a failed
+// assertion is reported through ProbeResult.Reason.
+func (u *Up) RunProbe(ctx context.Context) (*ProbeResult, error) {
+	p := u.Probe
+	if p == nil {
+		return nil, fmt.Errorf("up step has no probe")
+	}
+	method := strings.ToUpper(strings.TrimSpace(p.Request.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	hdrs, queries, _, err := p.Request.renderFor(ctx, u.Env, method)
+	if err != nil {
+		return nil, fmt.Errorf("probe request template error: %v", err)
+	}
+	url, err := renderValue(u.Env, p.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("probe request url: %w", err)
+	}
+	expected := make(map[string]string, len(p.Assert))
+	for path, want := range p.Assert {
+		if expected[path], err = renderValue(u.Env, want); err != nil {
+			return nil, fmt.Errorf("probe assert %q: %w", path, err)
+		}
+	}
+
+	// The probe must not change anything, so the body is never sent
+	sg, err := p.Request.Sign.signer(u.Env)
+	if err != nil {
+		return nil, fmt.Errorf("probe request %w", err)
+	}
+	req := buildRequest(ctx, hdrs, queries, "", p.Request.Retry, sg)
+	gql := p.Request.GraphQL != nil
+	p.Response.prepare(req, gql)
+	resp, _, err := execTimed(req, method, url)
+	if err != nil {
+		return nil, err
+	}
+	status := resp.StatusCode()
+	res := &ProbeResult{StatusCode: status}
+
+	allowed := p.Response.AllowedStatus(u.Env)
+	if allowed == nil {
+		allowed = func(s int) bool { return s >= 200 && s < 300 }
+	}
+	if !allowed(status) {
+		if p.Response.streams(gql) {
+			_ = resp.RawBody().Close()
+		}
+		res.Reason = fmt.Sprintf("status %d does not indicate the change is in place", status)
+		return res, nil
+	}
+	_, body, contentType, err := p.Response.readDocument(ctx, resp, gql, u.Env)
+	var rerr *ndjsonReadError
+	if errors.As(err, &rerr) {
+		return nil, err
+	}
+	if err != nil {
+		res.Reason = err.Error()
+		return res, nil
+	}
+	if reason := checkAssertions(body, expected); reason != "" {
+		res.Reason = reason
+		return res, nil
+	}
+	extracted, err := p.Response.extractResponse(status, u.Env, contentType, body, resp.Header())
+	if err != nil {
+		res.Reason = err.Error()
+		return res, nil
+	}
+	res.Satisfied = true
+	res.ExtractedEnv = extracted
+	return res, nil
+}
+
+// checkAssertions returns why body does not match the expected path values (checked in path
+// order), or "" when all match.
+func checkAssertions(body []byte, expected map[string]string) string {
+	if len(expected) == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	lookup := ResponseSpec{EnvFrom: map[string]EnvFrom{}}
+	for _, path := range paths {
+		lookup.EnvFrom[path] = EnvFrom{Path: path}
+	}
+	got, err := lookup.ExtractEnv(body)
+	if err != nil {
+		return err.Error()
+	}
+	for _, path := range paths {
+		v, ok := got[path]
+		switch {
+		case !ok:
+			return fmt.Sprintf("assert %s: path not found", path)
+		case expected[path] != "" && v != expected[path]:
+			return fmt.Sprintf("assert %s: got %q, want %q", path, v, expected[path])
+		}
+	}
+	return ""
+}
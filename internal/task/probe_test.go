@@ -0,0 +1,122 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func probeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("probe sent %s, want GET", r.Method)
+		}
+		switch r.URL.Path {
+		case "/realms/demo":
+			w.Header().Set("ETag", `"v7"`)
+			_, _ = w.Write([]byte(`{"id":"r-1","realm":"demo","enabled":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func decodeProbeTask(t *testing.T, doc string) *Task {
+	t.Helper()
+	var tk Task
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return &tk
+}
+
+func TestUp_RunProbe_SatisfiedAndExtracts(t *testing.T) {
+	srv := probeServer(t)
+	tk := decodeProbeTask(t, `up:
+  env:
+    realm: demo
+  request:
+    method: POST
+    url: `+srv.URL+`/realms
+    body: '{"realm":"demo"}'
+  probe:
+    request:
+      url: `+srv.URL+`/realms/{{.env.realm}}
+    assert:
+      realm: '{{.env.realm}}'
+      enabled: 'true'
+      id: ''
+    response:
+      env_from:
+        realm_id: id
+      header_from:
+        realm_etag: ETag
+`)
+	res, err := tk.Up.RunProbe(context.Background())
+	if err != nil {
+		t.Fatalf("RunProbe: %v", err)
+	}
+	if !res.Satisfied || res.StatusCode != http.StatusOK || res.Reason != "" {
+		t.Fatalf("expected a satisfied probe, got %+v", res)
+	}
+	if res.ExtractedEnv["realm_id"] != "r-1" || res.ExtractedEnv["realm_etag"] != `"v7"` {
+		t.Fatalf("unexpected extracted env: %v", res.ExtractedEnv)
+	}
+}
+
+func TestUp_RunProbe_NotSatisfied(t *testing.T) {
+	srv := probeServer(t)
+	cases := []struct {
+		name   string
+		probe  string
+		reason string
+	}{
+		{"missing resource", "request: { url: " + srv.URL + "/realms/other }", "status 404"},
+		{"assert mismatch", "request: { url: " + srv.URL + "/realms/demo }\n    assert: { enabled: 'false' }", `assert enabled: got "true", want "false"`},
+		{"assert path missing", "request: { url: " + srv.URL + "/realms/demo }\n    assert: { theme: '' }", "assert theme: path not found"},
+		{"status not listed", "request: { url: " + srv.URL + "/realms/demo }\n    response: { result_code: ['204'] }", "status 200"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tk := decodeProbeTask(t, "up:\n  request: { method: POST, url: "+srv.URL+"/realms }\n  probe:\n    "+tc.probe+"\n")
+			res, err := tk.Up.RunProbe(context.Background())
+			if err != nil {
+				t.Fatalf("RunProbe: %v", err)
+			}
+			if res.Satisfied || !strings.Contains(res.Reason, tc.reason) {
+				t.Fatalf("expected an unsatisfied probe with reason %q, got %+v", tc.reason, res)
+			}
+		})
+	}
+}
+
+func TestUp_RunProbe_TransportError(t *testing.T) {
+	tk := decodeProbeTask(t, "up:\n  request: { method: POST, url: http://127.0.0.1:1/x }\n  probe:\n    request: { url: 'http://127.0.0.1:1/x' }\n")
+	tk.Up.Probe.Request.Retry = &RetryPolicy{MaxAttempts: 1}
+	if _, err := tk.Up.RunProbe(context.Background()); err == nil {
+		t.Fatalf("expected a transport error")
+	}
+}
+
+func TestTaskDecode_ProbeValidation(t *testing.T) {
+	cases := map[string]string{
+		"non-read-only method": "probe:\n    request: { method: DELETE, url: http://x }",
+		"missing url":          "probe:\n    request: { method: GET }",
+		"empty assert path":    "probe:\n    request: { url: http://x }\n    assert: { '': x }",
+		"bad result code":      "probe:\n    request: { url: http://x }\n    response: { result_code: ['2xz'] }",
+	}
+	for name, probe := range cases {
+		t.Run(name, func(t *testing.T) {
+			var tk Task
+			err := tk.DecodeYAML(strings.NewReader("up:\n  request: { method: POST, url: http://x }\n  " + probe + "\n"))
+			if err == nil || !strings.Contains(err.Error(), "up.probe") {
+				t.Fatalf("expected an up.probe error, got %v", err)
+			}
+		})
+	}
+}
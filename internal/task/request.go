@@ -1,7 +1,13 @@
 package task
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -17,14 +23,79 @@ type RequestSpec struct {
 	Body       string   `yaml:"body"`
 	BodyFile   string   `yaml:"body_file"`
 	RenderBody *bool    `yaml:"render_body"`
+	// ContentType sets the Content-Type header explicitly (it wins over a Content-Type in Headers).
+	ContentType string `yaml:"content_type"`
+	// BodyJSON is a structured body: string values are templates and the result is sent as JSON.
+	BodyJSON interface{} `yaml:"body_json"`
+	// GraphQL, when set, builds the body from a GraphQL query and variables (method defaults to POST).
+	GraphQL *GraphQLSpec `yaml:"graphql"`
+	// Form is sent as an application/x-www-form-urlencoded body; values are templated.
+	Form map[string]string `yaml:"form"`
+	// Multipart, when set, sends a streamed multipart/form-data body (up requests only).
+	Multipart *MultipartSpec `yaml:"multipart"`
+	// Retry optionally overrides the retry policy for this request.
+	Retry *RetryPolicy `yaml:"retry"`
+	// Sign optionally signs the request right before it is sent (see SignSpec).
+	Sign *SignSpec `yaml:"sign"`
+	// FS, when set, resolves body_file within it instead of the local filesystem.
+	FS fs.FS `yaml:"-"`
+	// DefaultHeaders are added unless a header with the same name is set in Headers.
+	DefaultHeaders map[string]string `yaml:"-"`
 }
 
 // Render builds headers, query params and body applying Go template rendering using Env.
 // It also injects Authorization header from AuthName if present in env and not already set.
+// Headers limited to other methods than the request's are left out.
 // Returns an error if the body template fails to parse/execute.
 func (r RequestSpec) Render(env *env.Env) (map[string]string, map[string]string, string, error) {
-	hdrs := renderHeaders(env, r.Headers)
-	queries := renderQueries(env, r.Queries)
+	return r.renderFor(context.Background(), env, graphQLMethod(r.GraphQL, r.Method))
+}
+
+// renderFor is Render for a request sent with method, which may differ from r.Method when the
+// caller supplies a default. A body_file URL is fetched with ctx.
+func (r RequestSpec) renderFor(ctx context.Context, env *env.Env, method string) (map[string]string, map[string]string, string, error) {
+	hdrs, err := renderHeaders(env, r.Headers, method)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	r.applyContentType(hdrs)
+	if err := applyDefaultHeaders(env, r.DefaultHeaders, hdrs); err != nil {
+		return nil, nil, "", err
+	}
+	queries, err := renderQueries(env, r.Queries)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if err := r.checkBodySources(); err != nil {
+		return hdrs, queries, "", err
+	}
+
+	if r.Form != nil {
+		body, err := renderForm(env, r.Form)
+		return hdrs, queries, body, err
+	}
+
+	if r.Multipart != nil {
+		// The body is streamed when sending (see renderMultipart)
+		return hdrs, queries, "", nil
+	}
+
+	if r.BodyJSON != nil {
+		if r.GraphQL != nil || r.BodyFile != "" || r.Body != "" {
+			return hdrs, queries, "", fmt.Errorf("body_json cannot be combined with body, body_file or graphql")
+		}
+		body, err := renderBodyJSON(env, r.BodyJSON)
+		return hdrs, queries, body, err
+	}
+
+	if r.GraphQL != nil {
+		if r.BodyFile != "" || r.Body != "" {
+			return hdrs, queries, "", fmt.Errorf("graphql cannot be combined with body or body_file")
+		}
+		body, err := r.GraphQL.body(env)
+		return hdrs, queries, body, err
+	}
 
 	if r.BodyFile == "" && r.Body == "" {
 		return hdrs, queries, "", nil
@@ -33,14 +104,15 @@ func (r RequestSpec) Render(env *env.Env) (map[string]string, map[string]string,
 	// Determine body source: BodyFile if provided, otherwise Body
 	var body string
 	if strings.TrimSpace(r.BodyFile) != "" {
-		path := r.BodyFile
-		path = env.RenderGoTemplate(path)
-		path = filepath.Clean(path)
-		if data, err := os.ReadFile(path); err == nil {
-			body = string(data)
-		} else {
+		p, err := renderValue(env, r.BodyFile)
+		if err != nil {
 			return hdrs, queries, "", err
 		}
+		data, err := r.readBodyFile(ctx, p)
+		if err != nil {
+			return hdrs, queries, "", err
+		}
+		body = string(data)
 	} else {
 		body = r.Body
 	}
@@ -51,7 +123,6 @@ func (r RequestSpec) Render(env *env.Env) (map[string]string, map[string]string,
 		render = *r.RenderBody
 	}
 	if render {
-		var err error
 		body, err = renderBody(env, body)
 		if err != nil {
 			return hdrs, queries, "", err
@@ -60,3 +131,78 @@ func (r RequestSpec) Render(env *env.Env) (map[string]string, map[string]string,
 
 	return hdrs, queries, body, nil
 }
+
+// checkBodySources rejects form or multipart combined with another body source.
+func (r RequestSpec) checkBodySources() error {
+	if r.Form == nil && r.Multipart == nil {
+		return nil
+	}
+	if r.Form != nil && r.Multipart != nil {
+		return fmt.Errorf("form cannot be combined with multipart")
+	}
+	name := "form"
+	if r.Multipart != nil {
+		name = "multipart"
+	}
+	if r.Body != "" || r.BodyFile != "" || r.BodyJSON != nil || r.GraphQL != nil {
+		return fmt.Errorf("%s cannot be combined with body, body_file, body_json or graphql", name)
+	}
+	return nil
+}
+
+// applyContentType sets Content-Type from content_type, replacing any header of that name, or
+// defaults it to application/json for body_json (application/x-www-form-urlencoded for form)
+// when no Content-Type header is set. Multipart bodies always set their own Content-Type.
+// content_type is used literally (media types such as "+json" would not survive templating).
+func (r RequestSpec) applyContentType(hdrs map[string]string) {
+	ct := strings.TrimSpace(r.ContentType)
+	if ct == "" {
+		switch {
+		case hasHeader(hdrs, "Content-Type"):
+		case r.BodyJSON != nil:
+			hdrs["Content-Type"] = "application/json"
+		case r.Form != nil:
+			hdrs["Content-Type"] = "application/x-www-form-urlencoded"
+		}
+		return
+	}
+	replaceHeader(hdrs, "Content-Type", ct)
+}
+
+// renderBodyJSON renders the string values of a body_json value and marshals it.
+func renderBodyJSON(e *env.Env, v interface{}) (string, error) {
+	rendered, err := renderNestedValue(e, v)
+	if err != nil {
+		return "", fmt.Errorf("body_json: %w", err)
+	}
+	b, err := json.Marshal(rendered)
+	if err != nil {
+		return "", fmt.Errorf("body_json: encode: %w", err)
+	}
+	return string(b), nil
+}
+
+// renderForm renders the form values and encodes them, sorted by key.
+func renderForm(e *env.Env, form map[string]string) (string, error) {
+	values := url.Values{}
+	for k, v := range form {
+		val, err := renderValue(e, v)
+		if err != nil {
+			return "", fmt.Errorf("form %s: %w", k, err)
+		}
+		values.Set(k, val)
+	}
+	return values.Encode(), nil
+}
+
+// readBodyFile fetches a rendered body_file that is an http(s) URL, and otherwise reads the path
+// from FS when set, or else from the local filesystem.
+func (r RequestSpec) readBodyFile(ctx context.Context, p string) ([]byte, error) {
+	if isBodyFileURL(p) {
+		return fetchBodyFile(ctx, p, r.Retry)
+	}
+	if r.FS != nil {
+		return fs.ReadFile(r.FS, path.Clean(p))
+	}
+	return os.ReadFile(filepath.Clean(p))
+}
@@ -3,6 +3,7 @@ package task
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	env2 "github.com/loykin/apirun/pkg/env"
@@ -103,6 +104,55 @@ func TestRequest_Render_DoesNotOverrideAuthorization(t *testing.T) {
 	}
 }
 
+func TestRequest_Render_DefaultHeaders_TemplatedAndOverriddenPerRequest(t *testing.T) {
+	env := env2.Env{
+		Local: env2.FromStringMap(map[string]string{"rid": "req-42"}),
+		Auth:  env2.FromStringMap(map[string]string{"svc": "Bearer token"}),
+	}
+	req := RequestSpec{
+		Headers: []Header{
+			{Name: "user-agent", Value: "per-request"},
+			{Name: "Authorization", Value: "{{.auth.svc}}"},
+		},
+		DefaultHeaders: map[string]string{
+			"X-Request-ID":  "{{.env.rid}}",
+			"User-Agent":    "apirun",
+			"Authorization": "Bearer default",
+		},
+	}
+	hdrs, _, _, err := req.Render(&env)
+	if err != nil {
+		t.Fatalf("unexpected render error: %v", err)
+	}
+	want := map[string]string{"X-Request-ID": "req-42", "user-agent": "per-request", "Authorization": "Bearer token"}
+	if !reflect.DeepEqual(hdrs, want) {
+		t.Fatalf("unexpected headers: %v", hdrs)
+	}
+}
+
+func TestRequest_Render_HeaderMethods(t *testing.T) {
+	env := env2.Env{Local: env2.FromStringMap(map[string]string{"key": "k-1"})}
+	headers := []Header{
+		{Name: "Accept", Value: "application/json"},
+		{Name: "Content-Type", Value: "application/json", Methods: []string{"POST", "PUT"}},
+		{Name: "Idempotency-Key", Value: "{{.env.key}}", Methods: []string{"post"}},
+	}
+	cases := map[string]map[string]string{
+		"GET":  {"Accept": "application/json"},
+		"PUT":  {"Accept": "application/json", "Content-Type": "application/json"},
+		"POST": {"Accept": "application/json", "Content-Type": "application/json", "Idempotency-Key": "k-1"},
+	}
+	for method, want := range cases {
+		hdrs, _, _, err := RequestSpec{Method: method, Headers: headers}.Render(&env)
+		if err != nil {
+			t.Fatalf("%s: unexpected render error: %v", method, err)
+		}
+		if !reflect.DeepEqual(hdrs, want) {
+			t.Fatalf("%s: unexpected headers: %v", method, hdrs)
+		}
+	}
+}
+
 func TestRequest_Render_PassThroughNoTemplates(t *testing.T) {
 	env := env2.Env{Local: env2.FromStringMap(map[string]string{"FOO": "bar"})}
 	req := RequestSpec{
@@ -241,3 +291,61 @@ func TestRequest_Render_BodyFile_RenderBodyFalse(t *testing.T) {
 		t.Fatalf("expected content unchanged from file, got %q", body)
 	}
 }
+
+func TestRequest_Render_BodyJSON_TemplatesAndSetsContentType(t *testing.T) {
+	env := env2.Env{Local: env2.FromStringMap(map[string]string{"name": "alice"})}
+	req := RequestSpec{
+		BodyJSON: map[string]interface{}{
+			"name":  "{{.env.name}}",
+			"quote": `say "hi"`,
+			"age":   30,
+			"tags":  []interface{}{"a", "{{.env.name}}"},
+			"meta":  map[string]interface{}{"active": true, "note": nil},
+		},
+		DefaultHeaders: map[string]string{"Content-Type": "text/plain"},
+	}
+	hdrs, _, body, err := req.Render(&env)
+	if err != nil {
+		t.Fatalf("unexpected render error: %v", err)
+	}
+	want := `{"age":30,"meta":{"active":true,"note":null},"name":"alice","quote":"say \"hi\"","tags":["a","alice"]}`
+	if body != want {
+		t.Fatalf("unexpected body:\n got %s\nwant %s", body, want)
+	}
+	if hdrs["Content-Type"] != "application/json" {
+		t.Fatalf("expected application/json content type, got %v", hdrs)
+	}
+}
+
+func TestRequest_Render_ContentType(t *testing.T) {
+	env := env2.Env{}
+	req := RequestSpec{
+		Headers:     []Header{{Name: "content-type", Value: "text/plain"}},
+		ContentType: "application/merge-patch+json",
+		BodyJSON:    map[string]interface{}{"a": 1},
+	}
+	hdrs, _, _, err := req.Render(&env)
+	if err != nil {
+		t.Fatalf("unexpected render error: %v", err)
+	}
+	if !reflect.DeepEqual(hdrs, map[string]string{"Content-Type": "application/merge-patch+json"}) {
+		t.Fatalf("unexpected headers: %v", hdrs)
+	}
+
+	// A Content-Type header alone overrides the body_json default
+	req = RequestSpec{Headers: []Header{{Name: "Content-Type", Value: "application/vnd.api+json"}}, BodyJSON: map[string]interface{}{}}
+	hdrs, _, body, err := req.Render(&env)
+	if err != nil {
+		t.Fatalf("unexpected render error: %v", err)
+	}
+	if hdrs["Content-Type"] != "application/vnd.api+json" || body != "{}" {
+		t.Fatalf("unexpected headers/body: %v %q", hdrs, body)
+	}
+}
+
+func TestRequest_Render_BodyJSON_ConflictsWithBody(t *testing.T) {
+	req := RequestSpec{Body: "{}", BodyJSON: map[string]interface{}{"a": 1}}
+	if _, _, _, err := req.Render(&env2.Env{}); err == nil {
+		t.Fatalf("expected error when body and body_json are both set")
+	}
+}
@@ -1,27 +1,452 @@
 package task
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/loykin/apirun/pkg/env"
 	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
 )
 
 type ResponseSpec struct {
 	// ResultCode entries may be integers or go-template strings (e.g., {{.result_code}}) in YAML.
-	// We load them as strings to allow templating at execution time.
-	ResultCode []string          `yaml:"result_code"`
-	EnvFrom    map[string]string `yaml:"env_from"`
-	// EnvMissing controls behavior when a configured EnvFrom mapping cannot be extracted from response body.
+	// We load them as strings to allow templating at execution time. An entry may also be written
+	// as {code: 409, extract: false}: the status is accepted but nothing is extracted from it.
+	ResultCode []string `yaml:"result_code"`
+	// NoExtractCodes are the ResultCode entries written with extract: false.
+	NoExtractCodes []string `yaml:"-"`
+	// EnvFrom extracts response body values into env, e.g. {user_id: id} or, with a fallback and
+	// a type check, {count: {path: total, default: "0", type: int}}.
+	EnvFrom map[string]EnvFrom `yaml:"env_from"`
+	// HeaderFrom extracts response header values into env like EnvFrom does for the body,
+	// e.g. {new_id: {header: Location, regex: "/users/([^/]+)$"}} or simply {location: Location}.
+	HeaderFrom map[string]HeaderFrom `yaml:"header_from"`
+	// EnvFromRegex extracts several values from a non-JSON body with one regex, one per named
+	// capture group, e.g. {pattern: "id=(?P<id>\\d+);name=(?P<name>\\w+)", keys: [id, name]}.
+	EnvFromRegex *EnvFromRegex `yaml:"env_from_regex"`
+	// EnvMissing controls behavior when a configured EnvFrom or HeaderFrom mapping cannot be extracted.
 	// Allowed values: "skip" (default) – ignore missing variables; "fail" – treat as error.
 	EnvMissing string `yaml:"env_missing"`
+	// StoreBody keeps the raw up response body for the down step, available there as {{.up_body}}.
+	// It is kept only while the version is applied, independently of SaveResponseBody.
+	StoreBody bool `yaml:"store_body"`
+	// OnMatch skips later migrations when the response matches; honoured in up.response only.
+	OnMatch *OnMatchSpec `yaml:"on_match"`
+	// Mode is "" (the body is one document) or "ndjson": the body is newline-delimited JSON and
+	// the line selected by NDJSON stands for it.
+	Mode string `yaml:"mode"`
+	// NDJSON selects and checks the line read in ndjson mode (default: the last line).
+	NDJSON *NDJSONSpec `yaml:"ndjson"`
 }
 
-// AllowedStatus renders ResultCode against provided env vars and returns a set of allowed codes.
-func (r ResponseSpec) AllowedStatus(env *env.Env) map[int]struct{} {
-	allowed := map[int]struct{}{}
+// resultCodeEntry is a result_code entry written as a mapping.
+type resultCodeEntry struct {
+	Code    string `yaml:"code"`
+	Extract *bool  `yaml:"extract"`
+}
+
+// UnmarshalYAML accepts result_code entries written as {code, extract} besides plain codes.
+func (r *ResponseSpec) UnmarshalYAML(value *yaml.Node) error {
+	type plain ResponseSpec
+	var noExtract []string
+	if value.Kind == yaml.MappingNode {
+		// Rewrite mapping entries as plain codes on a copy of the node
+		node := *value
+		node.Content = slices.Clone(value.Content)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			codes := node.Content[i+1]
+			if node.Content[i].Value != "result_code" || codes.Kind != yaml.SequenceNode {
+				continue
+			}
+			seq := *codes
+			seq.Content = slices.Clone(codes.Content)
+			for j, item := range seq.Content {
+				if item.Kind != yaml.MappingNode {
+					continue
+				}
+				var e resultCodeEntry
+				if err := item.Decode(&e); err != nil {
+					return err
+				}
+				if strings.TrimSpace(e.Code) == "" {
+					return fmt.Errorf("line %d: result_code entry without code", item.Line)
+				}
+				if e.Extract != nil && !*e.Extract {
+					noExtract = append(noExtract, e.Code)
+				}
+				seq.Content[j] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.Code, Line: item.Line, Column: item.Column}
+			}
+			node.Content[i+1] = &seq
+		}
+		value = &node
+	}
+	if err := value.Decode((*plain)(r)); err != nil {
+		return err
+	}
+	r.NoExtractCodes = noExtract
+	return nil
+}
+
+// EnvFrom selects a response body value for EnvFrom.
+type EnvFrom struct {
+	Path string `yaml:"path"`
+	// Default, when set, is used when Path is missing instead of applying EnvMissing.
+	Default *string `yaml:"default"`
+	// Type is "string" (default), "int" or "bool". Values of another type fail the extraction;
+	// int and bool values are normalized (e.g. "1.0" -> "1", "TRUE" -> "true").
+	Type string `yaml:"type"`
+	// Scope is "version" (default): the value is stored for the down step and offered to later
+	// migrations; or "global": it is also put in the global env for the rest of the run.
+	// Honoured in up.response only.
+	Scope string `yaml:"scope"`
+}
+
+// Env scopes accepted by EnvFrom.Scope.
+const (
+	EnvScopeVersion = "version"
+	EnvScopeGlobal  = "global"
+)
+
+// Env value types accepted by EnvFrom.Type.
+const (
+	EnvTypeString = "string"
+	EnvTypeInt    = "int"
+	EnvTypeBool   = "bool"
+)
+
+// UnmarshalYAML accepts a plain path as shorthand for {path: <path>}.
+func (e *EnvFrom) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		e.Path = value.Value
+		return nil
+	}
+	type plain EnvFrom
+	return value.Decode((*plain)(e))
+}
+
+// validate checks Type and Scope, and that Default, when set, is of that type.
+func (e EnvFrom) validate() error {
+	switch strings.ToLower(strings.TrimSpace(e.Type)) {
+	case "", EnvTypeString, EnvTypeInt, EnvTypeBool:
+	default:
+		return fmt.Errorf("unknown type %q (valid: string, int, bool)", e.Type)
+	}
+	switch strings.ToLower(strings.TrimSpace(e.Scope)) {
+	case "", EnvScopeVersion, EnvScopeGlobal:
+	default:
+		return fmt.Errorf("unknown scope %q (valid: version, global)", e.Scope)
+	}
+	if e.Default != nil {
+		if _, err := e.coerce(*e.Default); err != nil {
+			return fmt.Errorf("default %w", err)
+		}
+	}
+	return nil
+}
+
+// defaultValue returns the normalized Default, or false when none is set.
+func (e EnvFrom) defaultValue() (string, bool, error) {
+	if e.Default == nil {
+		return "", false, nil
+	}
+	v, err := e.coerce(*e.Default)
+	if err != nil {
+		return "", false, fmt.Errorf("default %w", err)
+	}
+	return v, true, nil
+}
+
+// coerce checks that v is of the configured Type and returns its normalized form.
+func (e EnvFrom) coerce(v string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(e.Type)) {
+	case "", EnvTypeString:
+		return v, nil
+	case EnvTypeInt:
+		s := strings.TrimSpace(v)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return strconv.FormatInt(n, 10), nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			return strconv.FormatInt(int64(f), 10), nil
+		}
+		return "", fmt.Errorf("%q is not an int", v)
+	case EnvTypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("%q is not a bool", v)
+		}
+		return strconv.FormatBool(b), nil
+	}
+	return "", fmt.Errorf("unknown type %q (valid: string, int, bool)", e.Type)
+}
+
+// EnvFromRegex selects response body values for EnvFromRegex.
+type EnvFromRegex struct {
+	// Pattern is matched against the raw body; each named group is stored under its name.
+	Pattern string `yaml:"pattern"`
+	// Keys lists the named groups to extract (empty = all of them). A group that does not take
+	// part in the match counts as missing.
+	Keys []string `yaml:"keys"`
+}
+
+// validate checks that Pattern compiles and that Keys are named groups of it.
+func (e *EnvFromRegex) validate() error {
+	if strings.TrimSpace(e.Pattern) == "" {
+		return fmt.Errorf("missing pattern")
+	}
+	re, err := regexp.Compile(e.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	if len(e.groups(re)) == 0 {
+		return fmt.Errorf("pattern %q has no named capture group", e.Pattern)
+	}
+	for _, k := range e.Keys {
+		if re.SubexpIndex(k) < 0 {
+			return fmt.Errorf("key '%s' is not a named group of pattern %q", k, e.Pattern)
+		}
+	}
+	return nil
+}
+
+// groups returns the names of the groups re extracts: Keys, or every named group of re.
+func (e *EnvFromRegex) groups(re *regexp.Regexp) []string {
+	if len(e.Keys) > 0 {
+		return e.Keys
+	}
+	var names []string
+	for _, n := range re.SubexpNames() {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// keys returns the env names EnvFromRegex may produce; none when Pattern is invalid.
+func (e *EnvFromRegex) keys() []string {
+	if e == nil {
+		return nil
+	}
+	re, err := regexp.Compile(e.Pattern)
+	if err != nil {
+		return nil
+	}
+	return e.groups(re)
+}
+
+// extract adds the groups of the first match in body to extracted and returns the keys it
+// could not extract, sorted. JSON bodies are not matched.
+func (e *EnvFromRegex) extract(body []byte, extracted map[string]string) ([]string, error) {
+	re, err := regexp.Compile(e.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	var m []int
+	if !isJSONDocument(body) {
+		m = re.FindSubmatchIndex(body)
+	}
+	var missing []string
+	for _, k := range e.groups(re) {
+		i := re.SubexpIndex(k)
+		if i < 0 || m == nil || m[2*i] < 0 {
+			missing = append(missing, k)
+			continue
+		}
+		extracted[k] = string(body[m[2*i]:m[2*i+1]])
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// isJSONDocument reports whether body is a JSON object or array.
+func isJSONDocument(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed)
+}
+
+// HeaderFrom selects a response header, and optionally part of its value, for HeaderFrom.
+type HeaderFrom struct {
+	Header string `yaml:"header"`
+	// Regex, when set, keeps its first capture group (the whole match when it has none).
+	// A value the regex does not match counts as missing.
+	Regex string `yaml:"regex"`
+}
+
+// UnmarshalYAML accepts a plain header name as shorthand for {header: <name>}.
+func (h *HeaderFrom) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		h.Header = value.Value
+		return nil
+	}
+	type plain HeaderFrom
+	return value.Decode((*plain)(h))
+}
+
+// extract returns the selected part of the header value in hdr.
+func (h HeaderFrom) extract(hdr http.Header) (string, bool, error) {
+	v := hdr.Get(strings.TrimSpace(h.Header))
+	if v == "" {
+		return "", false, nil
+	}
+	if strings.TrimSpace(h.Regex) == "" {
+		return v, true, nil
+	}
+	re, err := regexp.Compile(h.Regex)
+	if err != nil {
+		return "", false, err
+	}
+	m := re.FindStringSubmatch(v)
+	switch {
+	case m == nil:
+		return "", false, nil
+	case len(m) > 1:
+		return m[1], true, nil
+	}
+	return m[0], true, nil
+}
+
+// ExtractedKeys returns the env names EnvFrom, EnvFromRegex and HeaderFrom may produce, sorted.
+func (r ResponseSpec) ExtractedKeys() []string {
+	keys := make([]string, 0, len(r.EnvFrom)+len(r.HeaderFrom))
+	for k := range r.EnvFrom {
+		keys = append(keys, k)
+	}
+	keys = append(keys, r.EnvFromRegex.keys()...)
+	for k := range r.HeaderFrom {
+		if _, dup := r.EnvFrom[k]; !dup && !slices.Contains(r.EnvFromRegex.keys(), k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GlobalKeys returns the EnvFrom names with scope "global", sorted.
+func (r ResponseSpec) GlobalKeys() []string {
+	var keys []string
+	for k, e := range r.EnvFrom {
+		if strings.EqualFold(strings.TrimSpace(e.Scope), EnvScopeGlobal) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// UpBodyEnvKey is the stored env name under which a StoreBody up response body is kept.
+const UpBodyEnvKey = env.UpBodyKey
+
+// StatusPredicate reports whether a response status code is accepted.
+type StatusPredicate func(status int) bool
+
+// ValidateResultCode checks a single result_code entry. Accepted forms are exact codes ("201"),
+// wildcards ("2xx", "20x") and inclusive ranges ("200-299"). Templated entries ({{...}}) can only
+// be checked after rendering and are accepted as-is.
+func ValidateResultCode(code string) error {
+	if strings.TrimSpace(code) == "" || strings.Contains(code, "{{") {
+		return nil
+	}
+	_, err := parseResultCode(code)
+	return err
+}
+
+// Validate checks all non-templated ResultCode entries, the EnvFrom, EnvFromRegex and
+// HeaderFrom mappings, and the mode.
+func (r ResponseSpec) Validate() error {
+	for _, c := range r.ResultCode {
+		if err := ValidateResultCode(c); err != nil {
+			return err
+		}
+	}
+	for key, e := range r.EnvFrom {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("env_from for key '%s': %w", key, err)
+		}
+	}
+	if r.EnvFromRegex != nil {
+		if err := r.EnvFromRegex.validate(); err != nil {
+			return fmt.Errorf("env_from_regex: %w", err)
+		}
+		for _, k := range r.EnvFromRegex.keys() {
+			if _, dup := r.EnvFrom[k]; dup {
+				return fmt.Errorf("env_from_regex: key '%s' is also set in env_from", k)
+			}
+		}
+	}
+	for key, h := range r.HeaderFrom {
+		if strings.TrimSpace(h.Header) == "" {
+			return fmt.Errorf("header_from for key '%s': missing header name", key)
+		}
+		if _, err := regexp.Compile(h.Regex); err != nil {
+			return fmt.Errorf("header_from for key '%s': invalid regex: %w", key, err)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(r.Mode)) {
+	case "", ResponseModeNDJSON:
+	default:
+		return fmt.Errorf("unknown mode %q (valid: %s)", r.Mode, ResponseModeNDJSON)
+	}
+	if r.NDJSON != nil {
+		if !r.isNDJSON() {
+			return fmt.Errorf("ndjson requires mode: %s", ResponseModeNDJSON)
+		}
+		if err := r.NDJSON.validate(); err != nil {
+			return fmt.Errorf("ndjson: %w", err)
+		}
+	}
+	if r.OnMatch != nil {
+		return r.OnMatch.Validate()
+	}
+	return nil
+}
+
+func parseResultCode(code string) (StatusPredicate, error) {
+	s := strings.ToLower(strings.TrimSpace(code))
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		l, lerr := strconv.Atoi(strings.TrimSpace(lo))
+		h, herr := strconv.Atoi(strings.TrimSpace(hi))
+		if lerr != nil || herr != nil || l > h {
+			return nil, fmt.Errorf("invalid result_code range %q", code)
+		}
+		return func(status int) bool { return status >= l && status <= h }, nil
+	}
+	if strings.Contains(s, "x") {
+		prefix := strings.TrimRight(s, "x")
+		if len(s) != 3 || prefix == "" || strings.Contains(prefix, "x") {
+			return nil, fmt.Errorf("invalid result_code wildcard %q", code)
+		}
+		p, err := strconv.Atoi(prefix)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid result_code wildcard %q", code)
+		}
+		width := 1
+		for i := len(prefix); i < 3; i++ {
+			width *= 10
+		}
+		lo := p * width
+		return func(status int) bool { return status >= lo && status < lo+width }, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result_code %q", code)
+	}
+	return func(status int) bool { return status == n }, nil
+}
+
+// AllowedStatus renders ResultCode against provided env vars and returns a predicate matching
+// any of the entries. It returns nil when no (valid) entries are configured.
+func (r ResponseSpec) AllowedStatus(env *env.Env) StatusPredicate {
+	var preds []StatusPredicate
 	for _, c := range r.ResultCode {
 		// Support both {{.var}} and ${{.var}} forms by normalizing the latter
 		tpl := c
@@ -33,33 +458,96 @@ func (r ResponseSpec) AllowedStatus(env *env.Env) map[int]struct{} {
 		if rendered == "" {
 			continue
 		}
-		if n, err := strconv.Atoi(rendered); err == nil {
-			allowed[n] = struct{}{}
+		if p, err := parseResultCode(rendered); err == nil {
+			preds = append(preds, p)
 		}
 	}
-	return allowed
+	if len(preds) == 0 {
+		return nil
+	}
+	return func(status int) bool {
+		for _, p := range preds {
+			if p(status) {
+				return true
+			}
+		}
+		return false
+	}
 }
 
 // ValidateStatus checks whether status code is allowed. If no ResultCode specified, all statuses are considered success.
 func (r ResponseSpec) ValidateStatus(status int, env *env.Env) error {
 	allowed := r.AllowedStatus(env)
-	if len(allowed) == 0 {
+	if allowed == nil {
 		// No restrictions => any status is success
 		return nil
 	}
-	if _, ok := allowed[status]; !ok {
-		return fmt.Errorf("status %d not in allowed set", status)
+	if !allowed(status) {
+		return fmt.Errorf("status %d not in allowed set %v", status, r.ResultCode)
 	}
 	return nil
 }
 
+// ExtractsFor reports whether values are extracted from a response with status: false when it
+// matches a NoExtractCodes entry.
+func (r ResponseSpec) ExtractsFor(status int, env *env.Env) bool {
+	skip := ResponseSpec{ResultCode: r.NoExtractCodes}.AllowedStatus(env)
+	return skip == nil || !skip(status)
+}
+
+// extractResponse extracts the EnvFrom and EnvFromRegex values of body and the HeaderFrom
+// values of hdr, merged, unless ExtractsFor rejects status. Errors are extraction errors.
+func (r ResponseSpec) extractResponse(status int, env *env.Env, contentType string, body []byte, hdr http.Header) (map[string]string, error) {
+	if !r.ExtractsFor(status, env) {
+		return map[string]string{}, nil
+	}
+	extracted, err := r.ExtractEnvFor(contentType, body)
+	if err != nil {
+		return extracted, err
+	}
+	fromHeaders, err := r.ExtractHeaders(hdr)
+	for k, v := range fromHeaders {
+		extracted[k] = v
+	}
+	return extracted, err
+}
+
+// FailsOnMissing reports whether env_missing is "fail".
+func (r ResponseSpec) FailsOnMissing() bool {
+	return strings.EqualFold(strings.TrimSpace(r.EnvMissing), "fail")
+}
+
 // ExtractEnv extracts variables from a JSON response body using EnvFrom mappings.
-// Paths are evaluated with tidwall/gjson and are expected to be valid gjson paths.
-// It respects EnvMissing policy: "skip" (default) ignores missing variables; "fail" returns an error.
+// Paths are evaluated with tidwall/gjson, except expressions starting with "$." or "$["
+// which are evaluated as JSONPath (e.g. "$.items[0].id").
+// A missing path takes the mapping's Default when set; otherwise the EnvMissing policy applies:
+// "skip" (default) ignores missing variables; "fail" returns an error. A value that does not
+// have the mapping's Type is always an error. EnvFromRegex values are matched on the raw body
+// when it is not a JSON document, under the same EnvMissing policy.
 func (r ResponseSpec) ExtractEnv(body []byte) (map[string]string, error) {
+	return r.ExtractEnvFor("", body)
+}
+
+// ExtractEnvFor is ExtractEnv for a response with the given Content-Type: when an Extractor is
+// registered for its media type, the values come from it instead of the JSON paths. Defaults,
+// types and the EnvMissing policy apply the same way.
+func (r ResponseSpec) ExtractEnvFor(contentType string, body []byte) (map[string]string, error) {
 	// Ensure deterministic behavior regardless of Go's random map iteration order.
 	extracted := map[string]string{}
-	if len(r.EnvFrom) == 0 || len(body) == 0 {
+	if len(r.EnvFrom) == 0 && r.EnvFromRegex == nil {
+		return extracted, nil
+	}
+	if len(body) == 0 {
+		// Nothing to extract from: only the defaults apply
+		for key, e := range r.EnvFrom {
+			v, ok, err := e.defaultValue()
+			if err != nil {
+				return extracted, fmt.Errorf("env_from for key '%s': %w", key, err)
+			}
+			if ok {
+				extracted[key] = v
+			}
+		}
 		return extracted, nil
 	}
 
@@ -68,34 +556,129 @@ func (r ResponseSpec) ExtractEnv(body []byte) (map[string]string, error) {
 		policy = "skip"
 	}
 
-	parsed := gjson.ParseBytes(body)
+	lookup, err := r.bodyLookup(contentType, body)
+	if err != nil {
+		return extracted, err
+	}
 
 	// First pass: extract all keys that exist.
-	for key, path := range r.EnvFrom {
-		p := strings.TrimSpace(path)
+	missing := map[string]string{}
+	for key, e := range r.EnvFrom {
+		p := strings.TrimSpace(e.Path)
 		if p == "" {
 			continue
 		}
-		res := parsed.Get(p)
-		if res.Exists() {
-			extracted[key] = anyToString(res.Value())
+		val, ok, err := lookup(key, p)
+		if err != nil {
+			return extracted, fmt.Errorf("env_from for key '%s': %w", key, err)
+		}
+		if ok {
+			v, err := e.coerce(anyToString(val))
+			if err != nil {
+				return extracted, fmt.Errorf("env_from for key '%s' at path '%s': %w", key, p, err)
+			}
+			extracted[key] = v
+			continue
+		}
+		v, hasDefault, err := e.defaultValue()
+		if err != nil {
+			return extracted, fmt.Errorf("env_from for key '%s': %w", key, err)
+		}
+		if hasDefault {
+			extracted[key] = v
+		} else {
+			missing[key] = p
+		}
+	}
+	var regexMissing []string
+	if r.EnvFromRegex != nil {
+		if regexMissing, err = r.EnvFromRegex.extract(body, extracted); err != nil {
+			return extracted, fmt.Errorf("env_from_regex: %w", err)
 		}
 	}
 
-	// Second pass: if policy is fail, check for any missing keys and return error
+	// Second pass: if policy is fail, report a missing key (sorted for deterministic errors)
 	// while preserving the already extracted values from the first pass.
-	if policy == "fail" {
-		for key, path := range r.EnvFrom {
-			p := strings.TrimSpace(path)
-			if p == "" {
-				continue
-			}
-			res := parsed.Get(p)
-			if !res.Exists() {
-				return extracted, fmt.Errorf("missing env_from for key '%s' at path '%s'", key, p)
+	if policy == "fail" && len(missing) > 0 {
+		keys := make([]string, 0, len(missing))
+		for k := range missing {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		key := keys[0]
+		if isJSONPath(missing[key]) {
+			return extracted, &missingValueError{fmt.Sprintf("missing env_from for key '%s': JSONPath expression '%s' matched nothing", key, missing[key])}
+		}
+		return extracted, &missingValueError{fmt.Sprintf("missing env_from for key '%s' at path '%s'", key, missing[key])}
+	}
+	if policy == "fail" && len(regexMissing) > 0 {
+		return extracted, &missingValueError{fmt.Sprintf("missing env_from_regex for key '%s': pattern '%s' did not match the body", regexMissing[0], r.EnvFromRegex.Pattern)}
+	}
+
+	return extracted, nil
+}
+
+// bodyLookup returns how EnvFrom values are looked up in body: by the Extractor registered for
+// contentType, which is run once for all keys, or else by JSON path.
+func (r ResponseSpec) bodyLookup(contentType string, body []byte) (func(key, path string) (interface{}, bool, error), error) {
+	if fn, ok := lookupExtractor(contentType); ok {
+		spec := make(map[string]string, len(r.EnvFrom))
+		for key, e := range r.EnvFrom {
+			if p := strings.TrimSpace(e.Path); p != "" {
+				spec[key] = p
 			}
 		}
+		values, err := fn(body, spec)
+		if err != nil {
+			return nil, fmt.Errorf("env_from: %s extractor: %w", mediaType(contentType), err)
+		}
+		return func(key, _ string) (interface{}, bool, error) {
+			v, ok := values[key]
+			return v, ok, nil
+		}, nil
 	}
+	parsed := gjson.ParseBytes(body)
+	return func(_, p string) (interface{}, bool, error) {
+		if isJSONPath(p) {
+			return evalJSONPath(body, p)
+		}
+		res := parsed.Get(p)
+		return res.Value(), res.Exists(), nil
+	}, nil
+}
 
+// ExtractHeaders extracts variables from response headers using HeaderFrom mappings,
+// following the same EnvMissing policy as ExtractEnv.
+func (r ResponseSpec) ExtractHeaders(hdr http.Header) (map[string]string, error) {
+	extracted := map[string]string{}
+	if len(r.HeaderFrom) == 0 {
+		return extracted, nil
+	}
+	keys := make([]string, 0, len(r.HeaderFrom))
+	for k := range r.HeaderFrom {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fail := r.FailsOnMissing()
+	var missing []string
+	for _, key := range keys {
+		h := r.HeaderFrom[key]
+		v, ok, err := h.extract(hdr)
+		if err != nil {
+			return extracted, fmt.Errorf("header_from for key '%s': %w", key, err)
+		}
+		if ok {
+			extracted[key] = v
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	if fail && len(missing) > 0 {
+		h := r.HeaderFrom[missing[0]]
+		if strings.TrimSpace(h.Regex) != "" {
+			return extracted, &missingValueError{fmt.Sprintf("missing header_from for key '%s': header '%s' absent or not matching '%s'", missing[0], h.Header, h.Regex)}
+		}
+		return extracted, &missingValueError{fmt.Sprintf("missing header_from for key '%s': header '%s' absent", missing[0], h.Header)}
+	}
 	return extracted, nil
 }
@@ -0,0 +1,77 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ResponseLimitMargin is read past the limit given to WithResponseLimit, so that a body
+// somewhat over the stored size can still be used for extraction before it is truncated.
+const ResponseLimitMargin = 1 << 20
+
+type responseLimitKey struct{}
+
+// WithResponseLimit returns a context whose task requests read at most limit bytes of a
+// response body plus ResponseLimitMargin; a longer body fails the request without being
+// read further. limit <= 0 leaves bodies unbounded.
+func WithResponseLimit(ctx context.Context, limit int64) context.Context {
+	if limit <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, responseLimitKey{}, limit)
+}
+
+// responseLimit returns the read limit carried by ctx (0 = unbounded).
+func responseLimit(ctx context.Context) int64 {
+	limit, _ := ctx.Value(responseLimitKey{}).(int64)
+	if limit <= 0 {
+		return 0
+	}
+	return limit + ResponseLimitMargin
+}
+
+// applyResponseLimit sets the read limit carried by ctx on client.
+func applyResponseLimit(ctx context.Context, client *resty.Client) {
+	if limit := responseLimit(ctx); limit > 0 {
+		client.SetResponseBodyLimit(int(limit))
+	}
+}
+
+// describeBodyLimit replaces resty's error for an over-limit body with one naming the limit.
+func describeBodyLimit(ctx context.Context, err error) error {
+	if errors.Is(err, resty.ErrResponseBodyTooLarge) {
+		return fmt.Errorf("response body exceeds %d bytes, the maximum read (raise the max response body size): %w", responseLimit(ctx), err)
+	}
+	return err
+}
+
+// limitBody bounds rd, an unparsed response body, to the read limit of ctx: reading past it
+// fails with the error describeBodyLimit gives for an over-limit body.
+func limitBody(ctx context.Context, rd io.Reader) io.Reader {
+	limit := responseLimit(ctx)
+	if limit <= 0 {
+		return rd
+	}
+	return &limitedBody{r: rd, left: limit, err: describeBodyLimit(ctx, resty.ErrResponseBodyTooLarge)}
+}
+
+type limitedBody struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if l.left -= int64(n); l.left < 0 {
+		return 0, l.err
+	}
+	return n, err
+}
@@ -0,0 +1,51 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+func TestUp_ResponseLimit(t *testing.T) {
+	var requests atomic.Int32
+	size := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"id":"7","pad":"` + strings.Repeat("x", size) + `"}`))
+	}))
+	defer srv.Close()
+
+	up := Up{
+		Env:      env.New(),
+		Request:  RequestSpec{Method: http.MethodGet, URL: srv.URL},
+		Response: ResponseSpec{ResultCode: []string{"200"}, EnvFrom: map[string]EnvFrom{"id": {Path: "id"}}},
+	}
+	ctx := WithResponseLimit(context.Background(), 16)
+
+	// Over the limit but within the margin: read in full, so extraction still works
+	size = 1024
+	res, err := up.Execute(ctx, "", "")
+	if err != nil || res.ExtractedEnv["id"] != "7" || len(res.ResponseBody) < size {
+		t.Fatalf("expected the body to be read, got %v %+v", err, res)
+	}
+
+	// Past the margin: rejected without retries
+	size = ResponseLimitMargin
+	requests.Store(0)
+	if _, err := up.Execute(ctx, "", ""); err == nil || !strings.Contains(err.Error(), "response body exceeds") {
+		t.Fatalf("expected an over-limit error, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("expected a single request, got %d", n)
+	}
+
+	// No limit on the context: read in full
+	if _, err := up.Execute(context.Background(), "", ""); err != nil {
+		t.Fatalf("unbounded read failed: %v", err)
+	}
+}
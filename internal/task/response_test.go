@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/loykin/apirun/pkg/env"
@@ -37,3 +38,324 @@ func TestExecuteUp_NoResultCode_AllSuccess(t *testing.T) {
 		t.Fatalf("expected status 503, got %d", res.StatusCode)
 	}
 }
+
+func TestValidateStatus_PatternsAndRanges(t *testing.T) {
+	e := env.New()
+	cases := []struct {
+		codes  []string
+		status int
+		ok     bool
+	}{
+		{[]string{"200", "201"}, 201, true},
+		{[]string{"200"}, 204, false},
+		{[]string{"2xx"}, 204, true},
+		{[]string{"2XX"}, 299, true},
+		{[]string{"2xx"}, 302, false},
+		{[]string{"20x"}, 209, true},
+		{[]string{"20x"}, 210, false},
+		{[]string{"200-299"}, 250, true},
+		{[]string{"200-299"}, 300, false},
+		{[]string{"404", "5xx"}, 503, true},
+	}
+	for _, c := range cases {
+		err := ResponseSpec{ResultCode: c.codes}.ValidateStatus(c.status, e)
+		if (err == nil) != c.ok {
+			t.Fatalf("codes=%v status=%d: expected ok=%v, got err=%v", c.codes, c.status, c.ok, err)
+		}
+	}
+}
+
+func TestValidateResultCode_RejectsInvalidPatterns(t *testing.T) {
+	for _, bad := range []string{"abc", "2xxx", "x00", "2x0", "300-200", "2xx-3xx"} {
+		if err := ValidateResultCode(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+	for _, good := range []string{"200", "2xx", "20x", "200-299", "{{.code}}", ""} {
+		if err := ValidateResultCode(good); err != nil {
+			t.Fatalf("expected %q to be accepted, got %v", good, err)
+		}
+	}
+}
+
+func TestTaskDecode_RejectsInvalidResultCode(t *testing.T) {
+	var tk Task
+	err := tk.DecodeYAML(strings.NewReader("up:\n  request: { method: GET, url: http://x }\n  response:\n    result_code: ['2xz']\n"))
+	if err == nil || !strings.Contains(err.Error(), "2xz") {
+		t.Fatalf("expected load-time error for invalid result_code, got %v", err)
+	}
+}
+
+// A response outside the accepted pattern still fails the migration.
+func TestExecuteUp_ResultCodeWildcard_NonMatchingFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	up := Up{Env: env.New(), Response: ResponseSpec{ResultCode: []string{"2xx"}}}
+	res, err := up.Execute(context.Background(), http.MethodGet, srv.URL)
+	if err == nil {
+		t.Fatalf("expected 409 to fail against 2xx")
+	}
+	if res == nil || res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected result with status 409, got %+v", res)
+	}
+}
+
+func TestTaskDecode_HeaderFrom(t *testing.T) {
+	var tk Task
+	doc := "up:\n  request: { method: POST, url: http://x }\n  response:\n    header_from:\n      location: Location\n      user_id: { header: Location, regex: '/users/([^/]+)$' }\n"
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	hf := tk.Up.Response.HeaderFrom
+	if hf["location"].Header != "Location" || hf["location"].Regex != "" {
+		t.Fatalf("unexpected shorthand mapping: %+v", hf["location"])
+	}
+	if hf["user_id"].Header != "Location" || hf["user_id"].Regex == "" {
+		t.Fatalf("unexpected regex mapping: %+v", hf["user_id"])
+	}
+
+	bad := "up:\n  request: { method: POST, url: http://x }\n  response:\n    header_from:\n      id: { header: Location, regex: '(' }\n"
+	if err := tk.DecodeYAML(strings.NewReader(bad)); err == nil {
+		t.Fatalf("expected load-time error for invalid header_from regex")
+	}
+}
+
+func TestExecuteUp_HeaderFrom(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/api/users/u-42")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	up := Up{Env: env.New(), Response: ResponseSpec{HeaderFrom: map[string]HeaderFrom{
+		"user_id":    {Header: "location", Regex: `/users/([^/]+)$`},
+		"request_id": {Header: "X-Request-Id"},
+		"etag":       {Header: "ETag"},
+	}}}
+	res, err := up.Execute(context.Background(), http.MethodPost, srv.URL)
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if res.ExtractedEnv["user_id"] != "u-42" || res.ExtractedEnv["request_id"] != "req-1" {
+		t.Fatalf("unexpected extracted env: %v", res.ExtractedEnv)
+	}
+	if _, ok := res.ExtractedEnv["etag"]; ok {
+		t.Fatalf("absent header should be skipped by default, got %v", res.ExtractedEnv)
+	}
+
+	up.Response.EnvMissing = "fail"
+	if _, err := up.Execute(context.Background(), http.MethodPost, srv.URL); err == nil || !strings.Contains(err.Error(), "etag") {
+		t.Fatalf("expected missing header error with env_missing: fail, got %v", err)
+	}
+}
+
+func TestExtractHeaders_RegexWithoutGroupAndNoMatch(t *testing.T) {
+	hdr := http.Header{}
+	hdr.Set("Location", "/api/users/u-42")
+	r := ResponseSpec{EnvMissing: "fail", HeaderFrom: map[string]HeaderFrom{"path": {Header: "Location", Regex: `/users/[^/]+`}}}
+	got, err := r.ExtractHeaders(hdr)
+	if err != nil || got["path"] != "/users/u-42" {
+		t.Fatalf("expected the whole match, got %v, %v", got, err)
+	}
+	r.HeaderFrom["path"] = HeaderFrom{Header: "Location", Regex: `/groups/(.+)`}
+	if _, err := r.ExtractHeaders(hdr); err == nil || !strings.Contains(err.Error(), "not matching") {
+		t.Fatalf("expected a no-match error, got %v", err)
+	}
+}
+
+func TestTaskDecode_EnvFromExtended(t *testing.T) {
+	var tk Task
+	doc := "up:\n  request: { method: GET, url: http://x }\n  response:\n    env_from:\n      id: id\n      count: { path: total, default: 0, type: int }\n"
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	ef := tk.Up.Response.EnvFrom
+	if ef["id"].Path != "id" || ef["id"].Default != nil || ef["id"].Type != "" {
+		t.Fatalf("unexpected shorthand mapping: %+v", ef["id"])
+	}
+	if ef["count"].Path != "total" || ef["count"].Default == nil || *ef["count"].Default != "0" || ef["count"].Type != EnvTypeInt {
+		t.Fatalf("unexpected extended mapping: %+v", ef["count"])
+	}
+
+	for _, bad := range []string{"{ path: total, type: float }", "{ path: total, default: none, type: int }"} {
+		doc := "up:\n  request: { method: GET, url: http://x }\n  response:\n    env_from:\n      count: " + bad + "\n"
+		if err := tk.DecodeYAML(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), "count") {
+			t.Fatalf("expected load-time error for %s, got %v", bad, err)
+		}
+	}
+}
+
+func TestExtractEnv_DefaultsAndTypes(t *testing.T) {
+	zero, no := "0", "false"
+	r := ResponseSpec{EnvMissing: "fail", EnvFrom: map[string]EnvFrom{
+		"id":      {Path: "id"},
+		"count":   {Path: "total", Default: &zero, Type: EnvTypeInt},
+		"active":  {Path: "active", Default: &no, Type: EnvTypeBool},
+		"ratio":   {Path: "ratio", Type: EnvTypeInt},
+		"enabled": {Path: "enabled", Type: EnvTypeBool},
+	}}
+	got, err := r.ExtractEnv([]byte(`{"id":"u1","ratio":"2.0","enabled":"TRUE"}`))
+	if err != nil {
+		t.Fatalf("ExtractEnv: %v", err)
+	}
+	want := map[string]string{"id": "u1", "count": "0", "active": "false", "ratio": "2", "enabled": "true"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %q, want %q (got %v)", k, got[k], v, got)
+		}
+	}
+
+	// Defaults also apply to an empty body
+	got, err = r.ExtractEnv(nil)
+	if err != nil || got["count"] != "0" || got["active"] != "false" || len(got) != 2 {
+		t.Fatalf("empty body: got %v, %v", got, err)
+	}
+
+	// A value of the wrong type fails even with the default env_missing policy
+	typed := ResponseSpec{EnvFrom: map[string]EnvFrom{"count": {Path: "total", Default: &zero, Type: EnvTypeInt}}}
+	if _, err := typed.ExtractEnv([]byte(`{"total":"many"}`)); err == nil || !strings.Contains(err.Error(), `env_from for key 'count' at path 'total': "many" is not an int`) {
+		t.Fatalf("expected a type error, got %v", err)
+	}
+	flag := ResponseSpec{EnvFrom: map[string]EnvFrom{"on": {Path: "on", Type: EnvTypeBool}}}
+	if _, err := flag.ExtractEnv([]byte(`{"on":"maybe"}`)); err == nil || !strings.Contains(err.Error(), "is not a bool") {
+		t.Fatalf("expected a type error, got %v", err)
+	}
+}
+
+func TestResponseSpec_EnvFromScope(t *testing.T) {
+	r := ResponseSpec{EnvFrom: map[string]EnvFrom{
+		"token": {Path: "access_token", Scope: "Global"},
+		"id":    {Path: "id", Scope: "version"},
+		"name":  {Path: "name"},
+	}}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if got := r.GlobalKeys(); len(got) != 1 || got[0] != "token" {
+		t.Fatalf("GlobalKeys = %v, want [token]", got)
+	}
+	r.EnvFrom["bad"] = EnvFrom{Path: "x", Scope: "run"}
+	if err := r.Validate(); err == nil || !strings.Contains(err.Error(), "unknown scope") {
+		t.Fatalf("expected unknown scope error, got %v", err)
+	}
+}
+
+func TestExtractEnv_EnvFromRegex(t *testing.T) {
+	r := ResponseSpec{EnvFromRegex: &EnvFromRegex{Pattern: `id=(?P<id>\d+);name=(?P<name>\w+)(?:;role=(?P<role>\w+))?`, Keys: []string{"id", "name"}}}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	got, err := r.ExtractEnv([]byte("status ok\nid=42;name=alice\n"))
+	if err != nil || got["id"] != "42" || got["name"] != "alice" || len(got) != 2 {
+		t.Fatalf("matching body: got %v, %v", got, err)
+	}
+	if keys := r.ExtractedKeys(); strings.Join(keys, ",") != "id,name" {
+		t.Fatalf("ExtractedKeys = %v, want [id name]", keys)
+	}
+
+	// No match: skipped by default, an error with env_missing: fail
+	got, err = r.ExtractEnv([]byte("nothing here"))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("non-matching body with skip: got %v, %v", got, err)
+	}
+	r.EnvMissing = "fail"
+	if _, err := r.ExtractEnv([]byte("nothing here")); err == nil || !strings.Contains(err.Error(), "missing env_from_regex for key 'id'") || extractionReason(err) != ReasonEnvMissing {
+		t.Fatalf("expected a missing env_from_regex error, got %v", err)
+	}
+
+	// A group that does not take part in the match is missing; JSON bodies are not matched
+	all := ResponseSpec{EnvMissing: "fail", EnvFromRegex: &EnvFromRegex{Pattern: r.EnvFromRegex.Pattern}}
+	if _, err := all.ExtractEnv([]byte("id=1;name=bob")); err == nil || !strings.Contains(err.Error(), "key 'role'") {
+		t.Fatalf("expected the optional group to be missing, got %v", err)
+	}
+	if _, err := all.ExtractEnv([]byte(`{"msg":"id=1;name=bob;role=admin"}`)); err == nil {
+		t.Fatalf("expected a JSON body not to be matched")
+	}
+}
+
+func TestResponseSpec_EnvFromRegexValidate(t *testing.T) {
+	for _, tc := range []struct {
+		spec ResponseSpec
+		want string
+	}{
+		{ResponseSpec{EnvFromRegex: &EnvFromRegex{}}, "missing pattern"},
+		{ResponseSpec{EnvFromRegex: &EnvFromRegex{Pattern: `(`}}, "invalid pattern"},
+		{ResponseSpec{EnvFromRegex: &EnvFromRegex{Pattern: `id=(\d+)`}}, "no named capture group"},
+		{ResponseSpec{EnvFromRegex: &EnvFromRegex{Pattern: `id=(?P<id>\d+)`, Keys: []string{"name"}}}, "key 'name' is not a named group"},
+		{ResponseSpec{EnvFrom: map[string]EnvFrom{"id": {Path: "id"}}, EnvFromRegex: &EnvFromRegex{Pattern: `id=(?P<id>\d+)`}}, "also set in env_from"},
+	} {
+		if err := tc.spec.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("Validate(%+v) = %v, want %q", tc.spec.EnvFromRegex, err, tc.want)
+		}
+	}
+
+	var tk Task
+	doc := "up:\n  request: { method: GET, url: http://x }\n  response:\n    env_from_regex: { pattern: \"id=(?P<id>\\\\d+);name=(?P<name>\\\\w+)\", keys: [id, name] }\n"
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if re := tk.Up.Response.EnvFromRegex; re == nil || re.Pattern != `id=(?P<id>\d+);name=(?P<name>\w+)` || len(re.Keys) != 2 {
+		t.Fatalf("unexpected env_from_regex: %+v", re)
+	}
+}
+
+func TestResponseSpec_ResultCodeEntries(t *testing.T) {
+	var tk Task
+	doc := "up:\n  request: { method: POST, url: http://x }\n  response:\n    result_code: [{ code: 201, extract: true }, { code: 409, extract: false }, \"2xx\"]\n"
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	r := tk.Up.Response
+	if strings.Join(r.ResultCode, ",") != "201,409,2xx" || strings.Join(r.NoExtractCodes, ",") != "409" {
+		t.Fatalf("unexpected result codes %v, no extract %v", r.ResultCode, r.NoExtractCodes)
+	}
+	if !r.ExtractsFor(201, env.New()) || r.ExtractsFor(409, env.New()) {
+		t.Fatalf("expected extraction for 201 only")
+	}
+
+	for _, bad := range []string{"[{ extract: false }]", "[{ code: 2xxx, extract: false }]"} {
+		doc := "up:\n  request: { method: POST, url: http://x }\n  response:\n    result_code: " + bad + "\n"
+		if err := tk.DecodeYAML(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), "result_code") {
+			t.Fatalf("expected load-time error for %s, got %v", bad, err)
+		}
+	}
+}
+
+func TestUp_Execute_ResultCodeWithoutExtraction(t *testing.T) {
+	status := http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/users/u-1")
+		w.WriteHeader(status)
+		if status == http.StatusCreated {
+			_, _ = w.Write([]byte(`{"id":"u-1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"error":"already exists"}`))
+	}))
+	defer srv.Close()
+
+	up := Up{Env: env.New(), Response: ResponseSpec{
+		ResultCode:     []string{"201", "409"},
+		NoExtractCodes: []string{"409"},
+		EnvMissing:     "fail",
+		EnvFrom:        map[string]EnvFrom{"user_id": {Path: "id"}},
+		HeaderFrom:     map[string]HeaderFrom{"location": {Header: "Location"}},
+	}}
+	res, err := up.Execute(context.Background(), http.MethodPost, srv.URL)
+	if err != nil || res.ExtractedEnv["user_id"] != "u-1" || res.ExtractedEnv["location"] != "/users/u-1" {
+		t.Fatalf("201: got %v, %v", res, err)
+	}
+
+	status = http.StatusConflict
+	res, err = up.Execute(context.Background(), http.MethodPost, srv.URL)
+	if err != nil {
+		t.Fatalf("409 should be accepted without extraction, got %v", err)
+	}
+	if res.StatusCode != http.StatusConflict || len(res.ExtractedEnv) != 0 || res.Error != nil {
+		t.Fatalf("409: unexpected result %+v", res)
+	}
+}
@@ -0,0 +1,97 @@
+package task
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// SignTypeAWSV4 signs requests with AWS Signature Version 4.
+const SignTypeAWSV4 = "awsv4"
+
+// SignSpec signs a request right before it is sent, after templating and middleware, so the
+// signature covers the request as it goes out (it is computed again for every retry).
+type SignSpec struct {
+	// Type is the signing scheme; only "awsv4" is supported.
+	Type string `yaml:"type"`
+	// Region and Service make up the credential scope, e.g. us-east-1 and execute-api; both
+	// are templated.
+	Region  string `yaml:"region"`
+	Service string `yaml:"service"`
+	// AuthName names the auth whose value holds the credentials as
+	// "ACCESS_KEY_ID:SECRET_ACCESS_KEY" or "ACCESS_KEY_ID:SECRET_ACCESS_KEY:SESSION_TOKEN".
+	// Without it they are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN in the OS environment.
+	AuthName string `yaml:"auth_name"`
+}
+
+// Validate checks the type and the required fields of the sign block.
+func (s *SignSpec) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if t := strings.ToLower(strings.TrimSpace(s.Type)); t != SignTypeAWSV4 {
+		return fmt.Errorf("sign: unsupported type %q (supported: %s)", s.Type, SignTypeAWSV4)
+	}
+	if strings.TrimSpace(s.Region) == "" || strings.TrimSpace(s.Service) == "" {
+		return fmt.Errorf("sign: region and service are required")
+	}
+	return nil
+}
+
+// signer resolves the templated scope and the credentials of the sign block against e.
+// A nil block yields a nil signer, which leaves requests unsigned.
+func (s *SignSpec) signer(e *env.Env) (*awsV4Signer, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	region, err := renderValue(e, s.Region)
+	if err != nil {
+		return nil, fmt.Errorf("sign.region: %w", err)
+	}
+	service, err := renderValue(e, s.Service)
+	if err != nil {
+		return nil, fmt.Errorf("sign.service: %w", err)
+	}
+	sg := &awsV4Signer{region: strings.TrimSpace(region), service: strings.TrimSpace(service)}
+	if name := strings.TrimSpace(s.AuthName); name != "" {
+		value := e.GetString("auth", name)
+		if value == "" {
+			return nil, fmt.Errorf("sign: auth %q has no value", name)
+		}
+		parts := strings.SplitN(value, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("sign: auth %q must be ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]", name)
+		}
+		sg.accessKey, sg.secretKey = parts[0], parts[1]
+		if len(parts) == 3 {
+			sg.sessionToken = parts[2]
+		}
+	} else {
+		sg.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		sg.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		sg.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		if sg.accessKey == "" || sg.secretKey == "" {
+			return nil, fmt.Errorf("sign: no credentials: set sign.auth_name or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+	}
+	return sg, nil
+}
+
+// applySigner signs every request client sends with sg; a nil sg is a no-op.
+func applySigner(client *resty.Client, sg *awsV4Signer) {
+	if sg == nil {
+		return
+	}
+	client.SetPreRequestHook(func(_ *resty.Client, r *http.Request) error {
+		return sg.sign(r, time.Now())
+	})
+}
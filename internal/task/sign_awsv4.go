@@ -0,0 +1,180 @@
+package task
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	awsV4Algorithm  = "AWS4-HMAC-SHA256"
+	awsV4TimeFormat = "20060102T150405Z"
+)
+
+// awsV4Signer computes AWS Signature Version 4 for a region, service and credentials.
+type awsV4Signer struct {
+	region       string
+	service      string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// awsV4Unsigned are headers left out of the signature: they are set or changed by proxies
+// and the HTTP client after signing.
+var awsV4Unsigned = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"expect":          true,
+	"x-amzn-trace-id": true,
+	"connection":      true,
+}
+
+// sign sets X-Amz-Date (and X-Amz-Security-Token, X-Amz-Content-Sha256 for s3) and the
+// Authorization header of r for the time t. The body is read to hash it and put back.
+func (s *awsV4Signer) sign(r *http.Request, t time.Time) error {
+	payload, err := requestPayload(r)
+	if err != nil {
+		return fmt.Errorf("sign: %w", err)
+	}
+	t = t.UTC()
+	amzDate := t.Format(awsV4TimeFormat)
+	date := amzDate[:8]
+	payloadHash := hashHex(payload)
+
+	r.Header.Del("Authorization")
+	r.Header.Set("X-Amz-Date", amzDate)
+	if s.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	if s.service == "s3" {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonical, signed := s.canonicalRequest(r, payloadHash)
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{awsV4Algorithm, amzDate, scope, hashHex([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, s.service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsV4Algorithm, s.accessKey, scope, signed, signature))
+	return nil
+}
+
+// canonicalRequest returns the canonical form of r and its signed header list.
+func (s *awsV4Signer) canonicalRequest(r *http.Request, payloadHash string) (string, string) {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	headers := map[string][]string{"host": {host}}
+	for name, values := range r.Header {
+		lower := strings.ToLower(name)
+		if awsV4Unsigned[lower] || lower == "host" {
+			continue
+		}
+		headers[lower] = append(headers[lower], values...)
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, name := range names {
+		values := make([]string, len(headers[name]))
+		for i, v := range headers[name] {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		canonHeaders.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if s.service != "s3" {
+		// Every service but S3 expects the already escaped path to be escaped once more
+		path = awsV4Escape(path, true)
+	}
+	signed := strings.Join(names, ";")
+	return strings.Join([]string{r.Method, path, awsV4Query(r.URL), canonHeaders.String(), signed, payloadHash}, "\n"), signed
+}
+
+// awsV4Query returns the query string with keys and values escaped and sorted.
+func awsV4Query(u *url.URL) string {
+	values := u.Query()
+	pairs := make([]string, 0, len(values))
+	for k, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, awsV4Escape(k, false)+"="+awsV4Escape(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsV4Escape percent-encodes every byte except the unreserved characters (and "/" when
+// keepSlash is set), as SigV4 requires.
+func awsV4Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// requestPayload returns the body of r without consuming it.
+func requestPayload(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = body.Close() }()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	r.ContentLength = int64(len(data))
+	return data, nil
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
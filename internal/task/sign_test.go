@@ -0,0 +1,130 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// Cases from the AWS SigV4 test suite (credentials AKIDEXAMPLE, 2015-08-30T12:36:00Z).
+func TestAWSV4_SuiteVectors(t *testing.T) {
+	sg := &awsV4Signer{region: "us-east-1", service: "service", accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	at := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for name, tc := range map[string]struct{ url, signature string }{
+		"get-vanilla":                      {"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		"get-vanilla-query-order-key-case": {"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err := sg.sign(req, at); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tc.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Fatalf("%s:\n got %s\nwant %s", name, got, want)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+			t.Fatalf("%s: X-Amz-Date = %q", name, got)
+		}
+	}
+}
+
+func TestUp_SignAWSV4(t *testing.T) {
+	sg := &awsV4Signer{region: "eu-west-1", service: "execute-api", accessKey: "AKID", secretKey: "SECRET", sessionToken: "TOKEN"}
+	var authz string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authz = r.Header.Get("Authorization")
+		// Re-sign the request as received, with only the signed headers, and compare
+		_, list, _ := strings.Cut(authz, "SignedHeaders=")
+		list, _, _ = strings.Cut(list, ",")
+		body, _ := io.ReadAll(r.Body)
+		check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), strings.NewReader(string(body)))
+		for _, h := range strings.Split(list, ";") {
+			if h != "host" {
+				check.Header[http.CanonicalHeaderKey(h)] = r.Header.Values(h)
+			}
+		}
+		at, err := time.Parse(awsV4TimeFormat, r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			t.Errorf("X-Amz-Date: %v", err)
+		}
+		_ = sg.sign(check, at)
+		if check.Header.Get("Authorization") != authz {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	e := env.New()
+	e.Auth["aws"] = env.Str("AKID:SECRET:TOKEN")
+	_ = e.SetString("global", "region", "eu-west-1")
+	up := Up{Env: e, Request: RequestSpec{
+		Method:  http.MethodPost,
+		URL:     srv.URL + "/prod/items a?b=2&a=1",
+		Headers: []Header{{Name: "X-Custom", Value: "one  two"}},
+		Body:    `{"name":"x"}`,
+		Sign:    &SignSpec{Type: "awsv4", Region: "{{.env.region}}", Service: "execute-api", AuthName: "aws"},
+	}, Response: ResponseSpec{ResultCode: []string{"201"}}}
+	// Middleware runs before signing, so the header it adds is covered too
+	ctx := WithMiddleware(context.Background(), Middleware{Request: []func(*resty.Request){
+		func(r *resty.Request) { r.SetHeader("X-Added", "late") },
+	}})
+	if _, err := up.Execute(ctx, "", ""); err != nil {
+		t.Fatalf("Execute: %v (Authorization %q)", err, authz)
+	}
+	if !strings.HasPrefix(authz, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(authz, "/eu-west-1/execute-api/aws4_request, ") {
+		t.Fatalf("unexpected Authorization %q", authz)
+	}
+	for _, h := range []string{"content-type", "x-added", "x-amz-date", "x-amz-security-token", "x-custom"} {
+		if !strings.Contains(authz, h) {
+			t.Fatalf("expected %s to be signed: %q", h, authz)
+		}
+	}
+}
+
+func TestSignSpec_Errors(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	e := env.New()
+	e.Auth["bad"] = env.Str("only-a-key")
+	for name, spec := range map[string]*SignSpec{
+		"unsupported type": {Type: "hmac", Region: "r", Service: "s"},
+		"missing region":   {Type: "awsv4", Service: "s"},
+		"no credentials":   {Type: "awsv4", Region: "r", Service: "s"},
+		"unknown auth":     {Type: "awsv4", Region: "r", Service: "s", AuthName: "missing"},
+		"malformed auth":   {Type: "awsv4", Region: "r", Service: "s", AuthName: "bad"},
+	} {
+		if _, err := spec.signer(e); err == nil || !strings.HasPrefix(err.Error(), "sign: ") {
+			t.Fatalf("%s: expected a sign error, got %v", name, err)
+		}
+	}
+
+	var tk Task
+	err := tk.DecodeYAML(strings.NewReader("up:\n  request:\n    url: http://x\n    sign:\n      type: awsv4\n      region: us-east-1\n"))
+	if err == nil || !strings.Contains(err.Error(), "up.request.sign: region and service are required") {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestSignSpec_EnvCredentialsAndFromUp(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENVKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "ENVSECRET")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	var tk Task
+	doc := "up:\n  request:\n    method: PUT\n    url: http://x/items/1\n    sign: {type: awsv4, region: us-east-1, service: s3}\ndown:\n  from_up: true\n"
+	if err := tk.DecodeYAML(strings.NewReader(doc)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	sg, err := tk.Down.Sign.signer(env.New())
+	if err != nil || sg == nil || sg.accessKey != "ENVKEY" || sg.service != "s3" {
+		t.Fatalf("expected down to sign with the env credentials, got %+v %v", sg, err)
+	}
+}
@@ -3,15 +3,21 @@ package task
 import (
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Task struct {
-	Up   Up   `yaml:"up"`
-	Down Down `yaml:"down"`
+	// DependsOn lists the lower versions this migration needs. nil (omitted) means it depends on
+	// every lower version; an explicit empty list marks it independent.
+	DependsOn []int `yaml:"depends_on"`
+	// Tags group migrations (e.g. "seed", "schema") so an up run can select some of them.
+	Tags []string `yaml:"tags"`
+	Up   Up       `yaml:"up"`
+	Down Down     `yaml:"down"`
 }
 
 // decodeYAMLTo is an internal helper to unmarshal YAML into the provided Task.
@@ -21,20 +27,69 @@ func (t *Task) decodeYAMLTo(r io.Reader) error {
 	if err := dec.Decode(&tmp); err != nil {
 		return fmt.Errorf("failed to decode YAML task configuration: %w", err)
 	}
+	if err := tmp.Up.Response.Validate(); err != nil {
+		return fmt.Errorf("up.response: %w", err)
+	}
+	if err := tmp.Up.Request.Sign.Validate(); err != nil {
+		return fmt.Errorf("up.request.%w", err)
+	}
+	if tmp.Up.Probe != nil {
+		if err := tmp.Up.Probe.Validate(); err != nil {
+			return fmt.Errorf("up.probe: %w", err)
+		}
+		if err := tmp.Up.Probe.Request.Sign.Validate(); err != nil {
+			return fmt.Errorf("up.probe.request.%w", err)
+		}
+		if tmp.Up.Probe.Response.OnMatch != nil {
+			return fmt.Errorf("up.probe.response: on_match is only supported in up.response")
+		}
+	}
+	if err := tmp.Down.applyFromUp(tmp.Up.Request); err != nil {
+		return fmt.Errorf("down.%w", err)
+	}
+	if err := tmp.Down.ValidateIgnoreCodes(); err != nil {
+		return fmt.Errorf("down.%w", err)
+	}
+	if err := tmp.Down.Sign.Validate(); err != nil {
+		return fmt.Errorf("down.%w", err)
+	}
+	if tmp.Down.Find != nil {
+		if err := tmp.Down.Find.Request.Sign.Validate(); err != nil {
+			return fmt.Errorf("down.find.request.%w", err)
+		}
+		if err := tmp.Down.Find.Response.Validate(); err != nil {
+			return fmt.Errorf("down.find.response: %w", err)
+		}
+		if tmp.Down.Find.Response.OnMatch != nil {
+			return fmt.Errorf("down.find.response: on_match is only supported in up.response")
+		}
+		if p := tmp.Down.Find.Paginate; p != nil {
+			if err := p.Validate(); err != nil {
+				return fmt.Errorf("down.find: %w", err)
+			}
+		}
+		if tmp.Down.Find.FromUpBody {
+			if !tmp.Up.Response.StoreBody {
+				return fmt.Errorf("down.find: from_up_body requires store_body in up.response")
+			}
+			if len(tmp.Down.Find.Response.HeaderFrom) > 0 {
+				return fmt.Errorf("down.find: header_from cannot be used with from_up_body (response headers are not stored)")
+			}
+		}
+	}
 	*t = tmp
 	return nil
 }
 
-// LoadFromFile loads a Task from a YAML file path into the receiver.
+// LoadFromFile loads a Task from a YAML file path into the receiver, merging its includes.
 func (t *Task) LoadFromFile(path string) error {
-	clean := filepath.Clean(path)
-	// #nosec G304 -- path is provided by controlled migration listing
-	f, err := os.Open(clean)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = f.Close() }()
-	return t.decodeYAMLTo(f)
+	return t.decodeWithIncludes(osIncludeReader, filepath.Clean(path))
+}
+
+// LoadFromFS loads a Task from a YAML file within fsys (e.g. an embed.FS) into the receiver,
+// merging its includes (resolved within fsys).
+func (t *Task) LoadFromFS(fsys fs.FS, name string) error {
+	return t.decodeWithIncludes(fsIncludeReader(fsys), path.Clean(name))
 }
 
 // DecodeYAML decodes a Task from the provided reader into the receiver.
@@ -0,0 +1,52 @@
+package task
+
+import (
+	"errors"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loykin/apirun/internal/common"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the spans apirun creates.
+const TracerName = "github.com/loykin/apirun"
+
+// startRequestSpan starts a client span for an HTTP request when the request context carries
+// a recording span (i.e. the migrator has a TracerProvider); otherwise it returns nil and
+// leaves the request untouched.
+func startRequestSpan(req *resty.Request, method, url string) trace.Span {
+	ctx := req.Context()
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return nil
+	}
+	ctx, span := parent.TracerProvider().Tracer(TracerName).Start(ctx, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.full", common.GetGlobalMasker().MaskString(url)),
+		))
+	req.SetContext(ctx)
+	return span
+}
+
+// endRequestSpan records the response status (or transport error) and ends span; nil is a no-op.
+func endRequestSpan(span trace.Span, resp *resty.Response, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		// Transport errors may quote the request (e.g. a URL with credentials), so mask as in logs
+		msg := common.GetGlobalMasker().MaskString(err.Error())
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	} else if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode()))
+		if resp.StatusCode() >= 400 {
+			span.SetStatus(codes.Error, resp.Status())
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,37 @@
+package task
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// statusSpan records the status and errors set on it.
+type statusSpan struct {
+	noop.Span
+	code   codes.Code
+	desc   string
+	errors []string
+}
+
+func (s *statusSpan) SetStatus(code codes.Code, desc string) { s.code, s.desc = code, desc }
+func (s *statusSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.errors = append(s.errors, err.Error())
+}
+
+func TestEndRequestSpan_MasksError(t *testing.T) {
+	span := &statusSpan{}
+	endRequestSpan(span, nil, errors.New(`Post "http://api/login": body {"password": "secret123"} rejected`))
+	if span.code != codes.Error {
+		t.Fatalf("expected an error status, got %v", span.code)
+	}
+	for _, s := range append(span.errors, span.desc) {
+		if strings.Contains(s, "secret123") || !strings.Contains(s, "***MASKED***") {
+			t.Fatalf("expected the span error to be masked, got %q", s)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package task
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type transportKey struct{}
+
+// WithTransport returns a context whose task requests go through the RoundTrippers built by
+// wrap around the client's transport, e.g. for authentication schemes that need to answer a
+// challenge on the connection. Wrappers apply in order, the first one innermost.
+func WithTransport(ctx context.Context, wrap ...func(http.RoundTripper) http.RoundTripper) context.Context {
+	if len(wrap) == 0 {
+		return ctx
+	}
+	prev, _ := ctx.Value(transportKey{}).([]func(http.RoundTripper) http.RoundTripper)
+	all := append(append([]func(http.RoundTripper) http.RoundTripper(nil), prev...), wrap...)
+	return context.WithValue(ctx, transportKey{}, all)
+}
+
+// applyTransport wraps the transport of client with the wrappers carried by ctx.
+func applyTransport(ctx context.Context, client *resty.Client) {
+	wraps, _ := ctx.Value(transportKey{}).([]func(http.RoundTripper) http.RoundTripper)
+	if len(wraps) == 0 {
+		return
+	}
+	rt := client.GetClient().Transport
+	for _, w := range wraps {
+		if w != nil {
+			rt = w(rt)
+		}
+	}
+	client.SetTransport(rt)
+}
@@ -0,0 +1,45 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loykin/apirun/pkg/env"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestWithTransport_WrapsInOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Chain")))
+	}))
+	defer srv.Close()
+
+	tag := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(base http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				r.Header.Set("X-Chain", name+r.Header.Get("X-Chain"))
+				return base.RoundTrip(r)
+			})
+		}
+	}
+	ctx := WithTransport(WithTransport(context.Background(), tag("a")), nil, tag("b"))
+	for _, u := range []Up{
+		{Env: env.New(), Request: RequestSpec{Method: http.MethodGet, URL: srv.URL}},
+		{Env: env.New(), Request: RequestSpec{Method: http.MethodPost, URL: srv.URL, Multipart: &MultipartSpec{Fields: map[string]string{"f": "v"}}}},
+	} {
+		res, err := u.Execute(ctx, "", "")
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		// b wraps a, so b sets its tag first and a prepends its own
+		if res.ResponseBody != "ab" {
+			t.Fatalf("expected both wrappers to run, got %q", res.ResponseBody)
+		}
+	}
+}
@@ -1,9 +1,53 @@
 package task
 
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/loykin/apirun/internal/httpc"
+)
+
+// RetryPolicy configures retries with exponential backoff for transient HTTP failures.
+type RetryPolicy = httpc.RetryPolicy
+
+// TransportConfig tunes the HTTP transport used by task requests (HTTP/2, idle connections, keep-alives).
+type TransportConfig = httpc.TransportConfig
+
+// CircuitBreakerConfig configures failing fast for hosts with repeated request failures.
+type CircuitBreakerConfig = httpc.CircuitBreakerConfig
+
+// CircuitBreaker holds the per-host circuit state shared by task requests.
+type CircuitBreaker = httpc.CircuitBreaker
+
+// ErrCircuitOpen is wrapped by the errors of requests rejected by an open circuit.
+var ErrCircuitOpen = httpc.ErrCircuitOpen
+
+// NewCircuitBreaker returns a circuit breaker with all circuits closed.
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	return httpc.NewCircuitBreaker(cfg)
+}
+
 // Header represents a single header key-value pair.
 type Header struct {
 	Name  string `yaml:"name"`
 	Value string `yaml:"value"`
+	// Methods, when set, limits the header to requests sent with one of these methods.
+	Methods []string `yaml:"methods"`
+}
+
+// appliesTo reports whether the header is sent with a request of the given method.
+func (h Header) appliesTo(method string) bool {
+	if len(h.Methods) == 0 {
+		return true
+	}
+	method = strings.TrimSpace(method)
+	for _, m := range h.Methods {
+		if strings.EqualFold(strings.TrimSpace(m), method) {
+			return true
+		}
+	}
+	return false
 }
 
 // Query represents a single query parameter key-value pair.
@@ -19,4 +63,39 @@ type ExecResult struct {
 	ExtractedEnv map[string]string
 	// Raw response body as a string; may be empty on network error.
 	ResponseBody string
+	// ResponseHeaders of the request that produced this result; nil on network error.
+	ResponseHeaders http.Header
+	// Method and URL of the request that produced this result (the final step that ran).
+	Method string
+	URL    string
+	// StartedAt is when the request was sent; DurationMs is how long it took to get a response.
+	StartedAt  time.Time
+	DurationMs int64
+	// Skip is the on_match action triggered by a successful response, if any.
+	Skip *SkipAction
+	// Error describes why the response was rejected; nil when the step succeeded.
+	Error *ExecError
+}
+
+// timedStep captures the request metadata and timing of one executed HTTP step.
+type timedStep struct {
+	method    string
+	url       string
+	startedAt time.Time
+	elapsed   time.Duration
+	header    http.Header
+}
+
+// result builds an ExecResult for the step with the given response details.
+func (s timedStep) result(status int, extracted map[string]string, body string) *ExecResult {
+	return &ExecResult{
+		StatusCode:      status,
+		ExtractedEnv:    extracted,
+		ResponseBody:    body,
+		ResponseHeaders: s.header,
+		Method:          s.method,
+		URL:             s.url,
+		StartedAt:       s.startedAt,
+		DurationMs:      s.elapsed.Milliseconds(),
+	}
 }
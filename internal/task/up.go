@@ -2,9 +2,11 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/loykin/apirun/internal/common"
 	"github.com/loykin/apirun/pkg/env"
 )
@@ -14,6 +16,8 @@ type Up struct {
 	Env      *env.Env     `yaml:"env"`
 	Request  RequestSpec  `yaml:"request"`
 	Response ResponseSpec `yaml:"response"`
+	// Probe optionally checks whether this step's change is already in place (plan-diff).
+	Probe *ProbeSpec `yaml:"probe"`
 }
 
 // Execute runs this Up specification against the provided HTTP method and URL.
@@ -22,50 +26,122 @@ type Up struct {
 // passed as parameters. This allows a single migration directory to hit different endpoints.
 func (u *Up) Execute(ctx context.Context, method, url string) (*ExecResult, error) {
 	logger := common.GetLogger().WithComponent("task-up")
+	if u.Env != nil {
+		logger = logger.WithRunID(u.Env.RunID)
+	}
 	logger.Debug("executing up task", "method", method, "url", url, "name", u.Name)
 
-	// Build request components via RequestSpec method
-	hdrs, queries, body, rerr := u.Request.Render(u.Env)
+	methodToUse, urlToUse, hdrs, queries, body, rerr := u.render(ctx, method, url)
 	if rerr != nil {
 		logger.Error("failed to render request template", "error", rerr, "name", u.Name)
-		return nil, fmt.Errorf("up request body template error: %v", rerr)
+		return nil, rerr
 	}
 
-	// Determine method and url to use (allow per-request overrides)
-	methodToUse := method
-	if strings.TrimSpace(u.Request.Method) != "" {
-		methodToUse = u.Request.Method
-	}
-	urlToUse := url
-	if strings.TrimSpace(u.Request.URL) != "" {
-		urlToUse = u.Request.URL
-	}
-	// Render URL (RenderGoTemplate is idempotent for non-templates)
-	urlToUse = u.Env.RenderGoTemplate(urlToUse)
-
 	logger.Debug("request details", "method", methodToUse, "url", urlToUse, "headers_count", len(hdrs), "queries_count", len(queries))
 
-	req := buildRequest(ctx, hdrs, queries, body)
-	resp, err := execByMethod(req, methodToUse, urlToUse)
+	sg, err := u.Request.Sign.signer(u.Env)
+	if err != nil {
+		return nil, fmt.Errorf("up request %w", err)
+	}
+	var req *resty.Request
+	if u.Request.Multipart != nil {
+		mp, err := u.Request.renderMultipart(u.Env)
+		if err != nil {
+			logger.Error("failed to render multipart body", "error", err, "name", u.Name)
+			return nil, fmt.Errorf("up request %w", err)
+		}
+		req = buildMultipartRequest(ctx, hdrs, queries, mp, u.Request.Retry, sg)
+	} else {
+		req = buildRequest(ctx, hdrs, queries, body, u.Request.Retry, sg)
+	}
+	gql := u.Request.GraphQL != nil
+	u.Response.prepare(req, gql)
+	resp, step, err := execTimed(req, methodToUse, urlToUse)
 	if err != nil {
 		logger.Error("HTTP request failed", "error", err, "method", methodToUse, "url", urlToUse)
 		return nil, err
 	}
 
 	status := resp.StatusCode()
-	bodyBytes := resp.Body()
-	logger.Debug("received HTTP response", "status_code", status, "response_size", len(bodyBytes))
-
 	// Validate status via ResponseSpec method
 	if err := u.Response.ValidateStatus(status, u.Env); err != nil {
 		logger.Warn("response status validation failed", "status_code", status, "error", err)
-		return &ExecResult{StatusCode: status, ExtractedEnv: map[string]string{}, ResponseBody: string(bodyBytes)}, err
+		bodyBytes := u.Response.responseBody(ctx, resp, gql)
+		return step.result(status, map[string]string{}, string(bodyBytes)).fail(ReasonUnexpectedStatus, err, bodyBytes), err
+	}
+
+	// GraphQL responses carry errors in the body and env_from applies to the data object;
+	// in ndjson mode the selected line stands for the body
+	bodyBytes, extractFrom, contentType, err := u.Response.readDocument(ctx, resp, gql, u.Env)
+	var readErr *ndjsonReadError
+	if errors.As(err, &readErr) {
+		logger.Error("failed to read HTTP response", "error", err, "method", methodToUse, "url", urlToUse)
+		return nil, err
+	}
+	logger.Debug("received HTTP response", "status_code", status, "response_size", len(bodyBytes))
+	if err != nil {
+		logger.Warn("response check failed", "error", err)
+		return step.result(status, map[string]string{}, string(bodyBytes)).fail(ReasonAssertion, err, bodyBytes), err
 	}
 
-	// Extract env from response body via ResponseSpec method (may error if env_missing=fail)
-	extracted, eerr := u.Response.ExtractEnv(bodyBytes)
+	// Extract env from response body and headers via ResponseSpec (may error if env_missing=fail)
+	extracted, eerr := u.Response.extractResponse(status, u.Env, contentType, extractFrom, resp.Header())
 	if eerr != nil {
-		return &ExecResult{StatusCode: status, ExtractedEnv: extracted, ResponseBody: string(bodyBytes)}, eerr
+		return step.result(status, extracted, string(bodyBytes)).fail(extractionReason(eerr), eerr, bodyBytes), eerr
+	}
+	res := step.result(status, extracted, string(bodyBytes))
+	if u.Response.OnMatch != nil {
+		skip, err := u.Response.OnMatch.evaluate(extractFrom, u.Env)
+		if err != nil {
+			return res.fail(ReasonAssertion, err, bodyBytes), err
+		}
+		if skip != nil {
+			logger.Info("on_match triggered", "action", u.Response.OnMatch.Action, "name", u.Name)
+		}
+		res.Skip = skip
+	}
+	return res, nil
+}
+
+// Plan renders the request this Up would send without performing any HTTP call.
+func (u *Up) Plan(method, url string) (*RenderedRequest, error) {
+	methodToUse, urlToUse, hdrs, queries, body, err := u.render(planContext(), method, url)
+	if err != nil {
+		return nil, err
+	}
+	if u.Request.Multipart != nil {
+		mp, err := u.Request.renderMultipart(u.Env)
+		if err != nil {
+			return nil, fmt.Errorf("up request %w", err)
+		}
+		replaceHeader(hdrs, "Content-Type", "multipart/form-data")
+		body = mp.describe()
+	}
+	return newRenderedRequest("up", methodToUse, urlToUse, hdrs, queries, body), nil
+}
+
+// render resolves method, URL, headers, queries and body for the request using the task env.
+func (u *Up) render(ctx context.Context, method, url string) (string, string, map[string]string, map[string]string, string, error) {
+	// Determine method and url to use (allow per-request overrides)
+	methodToUse := method
+	if strings.TrimSpace(u.Request.Method) != "" {
+		methodToUse = u.Request.Method
+	}
+	methodToUse = graphQLMethod(u.Request.GraphQL, methodToUse)
+
+	// Build request components via RequestSpec method
+	hdrs, queries, body, rerr := u.Request.renderFor(ctx, u.Env, methodToUse)
+	if rerr != nil {
+		return "", "", nil, nil, "", fmt.Errorf("up request body template error: %v", rerr)
+	}
+	urlToUse := url
+	if strings.TrimSpace(u.Request.URL) != "" {
+		urlToUse = u.Request.URL
+	}
+	// Render URL (RenderGoTemplate is idempotent for non-templates)
+	urlToUse, err := renderValue(u.Env, urlToUse)
+	if err != nil {
+		return "", "", nil, nil, "", fmt.Errorf("up request url: %w", err)
 	}
-	return &ExecResult{StatusCode: status, ExtractedEnv: extracted, ResponseBody: string(bodyBytes)}, nil
+	return methodToUse, urlToUse, hdrs, queries, body, nil
 }
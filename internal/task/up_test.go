@@ -3,10 +3,13 @@ package task
 import (
 	"context"
 	"crypto/tls"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/loykin/apirun/internal/httpc"
 	"github.com/loykin/apirun/pkg/env"
 )
 
@@ -41,7 +44,7 @@ func TestUp_Execute_OverrideMethodURL_ExtractEnv(t *testing.T) {
 		},
 		Response: ResponseSpec{
 			ResultCode: []string{"201"},
-			EnvFrom:    map[string]string{"rid": "id"},
+			EnvFrom:    map[string]EnvFrom{"rid": {Path: "id"}},
 		},
 	}
 
@@ -55,6 +58,12 @@ func TestUp_Execute_OverrideMethodURL_ExtractEnv(t *testing.T) {
 	if res.ExtractedEnv["rid"] != "123" {
 		t.Fatalf("expected extracted rid=123, got %v", res.ExtractedEnv)
 	}
+	if res.Method != http.MethodPost || res.URL != srv.URL+"/create?q=ok" {
+		t.Fatalf("expected final method/url to be recorded, got %s %s", res.Method, res.URL)
+	}
+	if res.StartedAt.IsZero() || res.DurationMs < 0 {
+		t.Fatalf("expected timing to be recorded, got started=%v duration=%d", res.StartedAt, res.DurationMs)
+	}
 }
 
 func TestUp_TLS_Insecure_AllowsSelfSigned(t *testing.T) {
@@ -74,13 +83,13 @@ func TestUp_TLS_Insecure_AllowsSelfSigned(t *testing.T) {
 		Response: ResponseSpec{ResultCode: []string{"200"}},
 	}
 	// First, with default TLS (no insecure), request to self-signed server should fail
-	SetTLSConfig(nil)
 	if _, err := u.Execute(context.Background(), http.MethodGet, srv.URL); err == nil {
 		t.Fatalf("expected TLS verification error without insecure, got nil")
 	}
-	// Now enable insecure and expect success
-	SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
-	if res, err := u.Execute(context.Background(), http.MethodGet, srv.URL); err != nil || res == nil || res.StatusCode != 200 {
+	// Now send through an insecure transport carried by ctx and expect success
+	h := httpc.Httpc{TlsConfig: &tls.Config{InsecureSkipVerify: true}}
+	ctx := httpc.WithSharedTransport(context.Background(), h.NewTransport())
+	if res, err := u.Execute(ctx, http.MethodGet, srv.URL); err != nil || res == nil || res.StatusCode != 200 {
 		t.Fatalf("expected success with insecure TLS, got res=%v err=%v", res, err)
 	}
 }
@@ -108,6 +117,12 @@ func TestUp_Execute_StatusNotAllowed_ReturnsError(t *testing.T) {
 	if res == nil || res.StatusCode != 500 {
 		t.Fatalf("expected ExecResult with status 500, got %+v", res)
 	}
+	if res.Error == nil || res.Error.Reason != ReasonUnexpectedStatus || res.Error.StatusCode != 500 {
+		t.Fatalf("expected unexpected_status error details, got %+v", res.Error)
+	}
+	if res.Error.BodySnippet != `{"err":true}` || res.Error.Message != err.Error() {
+		t.Fatalf("unexpected error details: %+v", res.Error)
+	}
 }
 
 // Verify env_missing=fail returns error when a mapped key is absent, while default skip does not.
@@ -125,7 +140,7 @@ func TestUp_Execute_EnvMissingPolicy(t *testing.T) {
 				Request: RequestSpec{Method: http.MethodGet, URL: srv.URL},
 				Response: ResponseSpec{
 					ResultCode: []string{"200"},
-					EnvFrom:    map[string]string{"a": "present", "b": "missing"},
+					EnvFrom:    map[string]EnvFrom{"a": {Path: "present"}, "b": {Path: "missing"}},
 					EnvMissing: envMissing,
 				},
 			}
@@ -140,6 +155,12 @@ func TestUp_Execute_EnvMissingPolicy(t *testing.T) {
 				if res.ExtractedEnv["a"] != "ok" {
 					t.Fatalf("expected extracted a=ok, got %+v", res.ExtractedEnv)
 				}
+				if res.Error == nil || res.Error.Reason != ReasonEnvMissing || res.Error.StatusCode != 200 {
+					t.Fatalf("expected env_missing error details, got %+v", res.Error)
+				}
+				if res.Error.BodySnippet != `{"present":"ok"}` {
+					t.Fatalf("unexpected body snippet %q", res.Error.BodySnippet)
+				}
 			} else {
 				if err != nil {
 					t.Fatalf("unexpected error with skip policy: %v", err)
@@ -153,6 +174,9 @@ func TestUp_Execute_EnvMissingPolicy(t *testing.T) {
 				if _, ok := res.ExtractedEnv["b"]; ok {
 					t.Fatalf("did not expect b to be present in extracted env")
 				}
+				if res.Error != nil {
+					t.Fatalf("did not expect error details on success, got %+v", res.Error)
+				}
 			}
 		})
 	}
@@ -160,3 +184,194 @@ func TestUp_Execute_EnvMissingPolicy(t *testing.T) {
 	tRun("fail-policy", "fail", true)
 	tRun("skip-default", "", false)
 }
+
+// A value of the wrong type is an assertion failure; the body snippet is masked and bounded.
+func TestUp_Execute_ErrorDetailsMaskedSnippet(t *testing.T) {
+	body := `{"count":"many","password":"hunter2","pad":"` + strings.Repeat("x", 2*maxBodySnippet) + `"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	u := Up{
+		Env:     &env.Env{},
+		Request: RequestSpec{Method: http.MethodGet, URL: srv.URL},
+		Response: ResponseSpec{
+			EnvFrom: map[string]EnvFrom{"count": {Path: "count", Type: "int"}},
+		},
+	}
+	res, err := u.Execute(context.Background(), http.MethodGet, srv.URL)
+	if err == nil {
+		t.Fatalf("expected type error, got nil")
+	}
+	if res == nil || res.Error == nil || res.Error.Reason != ReasonAssertion {
+		t.Fatalf("expected assertion_failed error details, got %+v", res)
+	}
+	snippet := res.Error.BodySnippet
+	if strings.Contains(snippet, "hunter2") || !strings.Contains(snippet, "***MASKED***") {
+		t.Fatalf("expected masked snippet, got %q", snippet)
+	}
+	if len(snippet) > maxBodySnippet {
+		t.Fatalf("snippet longer than %d bytes: %d", maxBodySnippet, len(snippet))
+	}
+	if res.ResponseBody != body {
+		t.Fatalf("expected full response body to be kept")
+	}
+}
+
+// A body_file URL is fetched once per cache, rendered and sent; a failed fetch fails the step.
+func TestUp_Execute_BodyFileURL(t *testing.T) {
+	var fetches int
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/body.json":
+			fetches++
+			_, _ = w.Write([]byte(`{"name":"{{.env.name}}"}`))
+		case "/create":
+			b, _ := io.ReadAll(r.Body)
+			posted = append(posted, string(b))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	newUp := func(file string) Up {
+		return Up{
+			Env:     &env.Env{Local: env.FromStringMap(map[string]string{"name": "alice"})},
+			Request: RequestSpec{Method: http.MethodPost, URL: srv.URL + "/create", BodyFile: srv.URL + file},
+		}
+	}
+	ctx := WithBodyFileCache(context.Background())
+	for i := 0; i < 2; i++ {
+		u := newUp("/files/body.json")
+		if _, err := u.Execute(ctx, "", ""); err != nil {
+			t.Fatalf("execute %d: %v", i, err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("body file fetched %d times, want 1", fetches)
+	}
+	if len(posted) != 2 || posted[0] != `{"name":"alice"}` || posted[1] != posted[0] {
+		t.Fatalf("unexpected posted bodies: %q", posted)
+	}
+
+	u := newUp("/files/missing.json")
+	if _, err := u.Execute(ctx, "", ""); err == nil || !strings.Contains(err.Error(), "body_file: fetch") || !strings.Contains(err.Error(), "status 404") {
+		t.Fatalf("expected body_file fetch error, got %v", err)
+	}
+	if len(posted) != 2 {
+		t.Fatalf("request sent despite the failed fetch")
+	}
+
+	// Plans do not download the file
+	u = newUp("/files/body.json")
+	req, err := u.Plan("", "")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if fetches != 1 || !strings.Contains(req.Body, "<body_file ") {
+		t.Fatalf("plan fetched the body file or did not name it: %d fetches, body %q", fetches, req.Body)
+	}
+}
+
+func TestUp_Execute_SecretTemplates(t *testing.T) {
+	t.Setenv("APIRUN_TEST_UP_TOKEN", "up-secret-token")
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if got := r.Header.Get("Authorization"); got != "Bearer up-secret-token" {
+			t.Fatalf("expected resolved secret header, got %q", got)
+		}
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	u := Up{
+		Env: env.New(),
+		Request: RequestSpec{
+			Method:  http.MethodGet,
+			URL:     srv.URL,
+			Headers: []Header{{Name: "Authorization", Value: `Bearer {{ secret "APIRUN_TEST_UP_TOKEN" }}`}},
+		},
+	}
+	if _, err := u.Execute(context.Background(), "", ""); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// A missing secret fails the task before any request is sent
+	u.Request.Headers = []Header{{Name: "Authorization", Value: `Bearer {{ secret "APIRUN_TEST_UP_MISSING" }}`}}
+	_, err := u.Execute(context.Background(), "", "")
+	if err == nil || !strings.Contains(err.Error(), "APIRUN_TEST_UP_MISSING") {
+		t.Fatalf("expected missing secret error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly one request, got %d", calls)
+	}
+}
+
+func TestUp_Execute_BodyJSONAndContentType(t *testing.T) {
+	var gotBody, gotCT string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotCT = string(b), r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	u := Up{
+		Env: &env.Env{Local: env.FromStringMap(map[string]string{"id": "42"})},
+		Request: RequestSpec{
+			Method:   http.MethodPatch,
+			URL:      srv.URL,
+			BodyJSON: map[string]interface{}{"id": "{{.env.id}}", "enabled": false},
+		},
+	}
+	if _, err := u.Execute(context.Background(), "", ""); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if gotBody != `{"enabled":false,"id":"42"}` || gotCT != "application/json" {
+		t.Fatalf("unexpected request: content-type=%q body=%s", gotCT, gotBody)
+	}
+
+	// An explicit content_type is sent as-is even though the body is JSON
+	u.Request.ContentType = "application/merge-patch+json"
+	if _, err := u.Execute(context.Background(), "", ""); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if gotCT != "application/merge-patch+json" {
+		t.Fatalf("expected explicit content type, got %q", gotCT)
+	}
+}
+
+func TestUp_Execute_HeaderMethods(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.Header.Get("Idempotency-Key")+" "+r.Header.Get("X-Trace"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	headers := []Header{
+		{Name: "Idempotency-Key", Value: "once", Methods: []string{"POST"}},
+		{Name: "X-Trace", Value: "t"},
+	}
+	// The method passed to Execute counts when the request does not set its own
+	u := Up{Env: env.New(), Request: RequestSpec{URL: srv.URL, Headers: headers}}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if _, err := u.Execute(context.Background(), method, ""); err != nil {
+			t.Fatalf("%s: unexpected err: %v", method, err)
+		}
+	}
+	d := Down{Env: env.New(), Method: http.MethodDelete, URL: srv.URL, Headers: headers}
+	if _, err := d.Execute(context.Background()); err != nil {
+		t.Fatalf("down: unexpected err: %v", err)
+	}
+	want := []string{"GET  t", "POST once t", "DELETE  t"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected headers: %q", got)
+	}
+}
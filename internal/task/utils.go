@@ -3,12 +3,13 @@ package task
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
-	"sync/atomic"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/loykin/apirun/internal/httpc"
@@ -61,34 +62,125 @@ func anyToString(v interface{}) string {
 	}
 }
 
-func renderHeaders(e *env.Env, hs []Header) map[string]string {
+// renderHeaders renders the headers that apply to method (see Header.Methods).
+func renderHeaders(e *env.Env, hs []Header, method string) (map[string]string, error) {
 	hdrs := make(map[string]string)
 	for _, h := range hs {
-		if h.Name == "" {
+		if h.Name == "" || !h.appliesTo(method) {
 			continue
 		}
-		val := h.Value
-		if strings.Contains(val, "{{") {
-			val = e.RenderGoTemplate(val)
+		val, err := renderValue(e, h.Value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", h.Name, err)
 		}
 		hdrs[h.Name] = val
 	}
-	return hdrs
+	return hdrs, nil
+}
+
+// applyDefaultHeaders renders the default headers and adds those not already set in hdrs.
+// Names are compared case-insensitively, so per-request headers always win.
+func applyDefaultHeaders(e *env.Env, defaults map[string]string, hdrs map[string]string) error {
+	if len(defaults) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || hasHeader(hdrs, name) {
+			continue
+		}
+		val, err := renderValue(e, defaults[name])
+		if err != nil {
+			return fmt.Errorf("default header %s: %w", name, err)
+		}
+		hdrs[name] = val
+	}
+	return nil
 }
 
-func renderQueries(e *env.Env, qs []Query) map[string]string {
+func hasHeader(hdrs map[string]string, name string) bool {
+	for k := range hdrs {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceHeader sets name to val, dropping any header of that name in another case.
+func replaceHeader(hdrs map[string]string, name, val string) {
+	for k := range hdrs {
+		if strings.EqualFold(k, name) {
+			delete(hdrs, k)
+		}
+	}
+	hdrs[name] = val
+}
+
+func renderQueries(e *env.Env, qs []Query) (map[string]string, error) {
 	m := make(map[string]string)
 	for _, q := range qs {
 		if q.Name == "" {
 			continue
 		}
-		val := q.Value
-		if strings.Contains(val, "{{") {
-			val = e.RenderGoTemplate(val)
+		val, err := renderValue(e, q.Value)
+		if err != nil {
+			return nil, fmt.Errorf("query %s: %w", q.Name, err)
 		}
 		m[q.Name] = val
 	}
-	return m
+	return m, nil
+}
+
+// renderValue renders a templated value like RenderGoTemplate (keeping the original string on
+// template errors) except that unresolvable secrets/files are reported as errors.
+func renderValue(e *env.Env, val string) (string, error) {
+	if !strings.Contains(val, "{{") {
+		return val, nil
+	}
+	out, err := e.RenderGoTemplateErr(val)
+	if err != nil {
+		if errors.Is(err, env.ErrSecretResolution) {
+			return "", err
+		}
+		return val, nil
+	}
+	return out, nil
+}
+
+// renderNestedValue renders every string inside a decoded YAML value (maps and lists are
+// walked recursively); numbers, booleans and nulls are kept as-is.
+func renderNestedValue(e *env.Env, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return renderValue(e, val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			r, err := renderNestedValue(e, item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			r, err := renderNestedValue(e, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
 }
 
 func renderBody(e *env.Env, b string) (string, error) {
@@ -98,21 +190,20 @@ func renderBody(e *env.Env, b string) (string, error) {
 	return b, nil
 }
 
-var tlsConfig atomic.Pointer[tls.Config]
-
-// SetTLSConfig configures the TLS settings used by HTTP requests within tasks.
-// Passing nil resets to default client behavior.
-func SetTLSConfig(cfg *tls.Config) {
-	tlsConfig.Store(cfg)
-}
-
-func buildRequest(ctx context.Context, headers map[string]string, queries map[string]string, body string) *resty.Request {
-	h := httpc.Httpc{TlsConfig: tlsConfig.Load()}
+func buildRequest(ctx context.Context, headers map[string]string, queries map[string]string, body string, retry *RetryPolicy, sg *awsV4Signer) *resty.Request {
+	h := httpc.Httpc{Retry: retry, Shared: httpc.SharedTransport(ctx)}
 	client := h.New()
+	applyTransport(ctx, client)
+	applyMiddleware(ctx, client)
+	applyResponseLimit(ctx, client)
+	applyCookieJar(ctx, client)
+	applySigner(client, sg)
 	req := client.R().SetContext(ctx).SetHeaders(headers).SetQueryParams(queries)
 	if strings.TrimSpace(body) != "" {
 		if isJSON(body) {
-			req.SetHeader("Content-Type", "application/json")
+			if !hasHeader(headers, "Content-Type") {
+				req.SetHeader("Content-Type", "application/json")
+			}
 			req.SetBody([]byte(body))
 		} else {
 			req.SetBody(body)
@@ -121,6 +212,20 @@ func buildRequest(ctx context.Context, headers map[string]string, queries map[st
 	return req
 }
 
+// execTimed sends the request like execByMethod and records when it started and how long it took.
+func execTimed(req *resty.Request, method, url string) (*resty.Response, timedStep, error) {
+	span := startRequestSpan(req, method, url)
+	step := timedStep{method: method, url: url, startedAt: time.Now().UTC()}
+	resp, err := execByMethod(req, method, url)
+	err = describeBodyLimit(req.Context(), err)
+	step.elapsed = time.Since(step.startedAt)
+	if resp != nil {
+		step.header = resp.Header()
+	}
+	endRequestSpan(span, resp, err)
+	return resp, step, err
+}
+
 func execByMethod(req *resty.Request, method, url string) (*resty.Response, error) {
 	switch method {
 	case http.MethodGet:
@@ -66,10 +66,16 @@ func (e *Env) Clone() *Env {
 	}
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	out := &Env{Auth: Map{}, Global: Map{}, Local: Map{}}
+	out := &Env{Auth: Map{}, Global: Map{}, Local: Map{}, RunID: e.RunID, Precedence: e.Precedence}
 	for k, v := range e.Auth {
 		out.Auth[k] = v
 	}
+	if e.Ctx != nil {
+		out.Ctx = Map{}
+		for k, v := range e.Ctx {
+			out.Ctx[k] = v
+		}
+	}
 	for k, v := range e.Global {
 		out.Global[k] = v
 	}
@@ -145,14 +151,75 @@ func normalizeMapName(n string) string {
 // - Auth: variables from auth providers (apply to the whole run)
 // - Global: variables from config (apply to the whole run)
 // - Local: variables from each task (reset per task)
-// Lookup and rendering give precedence to Local over Global.
+// - Ctx: values taken from the run's context.Context, rendered as {{.ctx.name}}
+// Lookup and the merged {{.env.x}} view give precedence to Local over Global unless Precedence
+// says otherwise; {{.global.x}} and {{.local.x}} read one layer only.
 // Note: zero values (nil maps) are handled gracefully.
 type Env struct {
 	mu     sync.RWMutex
 	Auth   Map `yaml:"-" json:"-" mapstructure:"-"`
 	Global Map `yaml:"-" json:"-" mapstructure:"-"`
 	Local  Map `yaml:"-" json:"env" mapstructure:"env"`
-	sealed bool
+	Ctx    Map `yaml:"-" json:"-" mapstructure:"-"`
+	// RunID identifies the migration run the env belongs to; templates read it as {{.run_id}}.
+	RunID string `yaml:"-" json:"-" mapstructure:"-"`
+	// Precedence orders the layers of Lookup and {{.env.x}}, the first one winning: LayerLocal
+	// and LayerGlobal, each once (nil = DefaultPrecedence). See ValidatePrecedence.
+	Precedence []string `yaml:"-" json:"-" mapstructure:"-"`
+	sealed     bool
+}
+
+// Layer names used in Env.Precedence.
+const (
+	LayerLocal  = "local"
+	LayerGlobal = "global"
+)
+
+// DefaultPrecedence lets task-local values override the global ones.
+var DefaultPrecedence = []string{LayerLocal, LayerGlobal}
+
+// ValidatePrecedence checks that order names LayerLocal and LayerGlobal exactly once each
+// (case-insensitive). An empty order is valid and means DefaultPrecedence.
+func ValidatePrecedence(order []string) error {
+	if len(order) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, name := range order {
+		n := strings.ToLower(strings.TrimSpace(name))
+		if n != LayerLocal && n != LayerGlobal {
+			return fmt.Errorf("env precedence: unknown layer %q (use %q and %q)", name, LayerLocal, LayerGlobal)
+		}
+		if seen[n] {
+			return fmt.Errorf("env precedence: layer %q listed twice", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != 2 {
+		return fmt.Errorf("env precedence: %v must list both %q and %q", order, LayerLocal, LayerGlobal)
+	}
+	return nil
+}
+
+// layers returns the Local and Global maps in precedence order, the winning one first. An invalid
+// Precedence falls back to DefaultPrecedence.
+func (e *Env) layers() []Map {
+	if e == nil {
+		return nil
+	}
+	order := DefaultPrecedence
+	if len(e.Precedence) > 0 && ValidatePrecedence(e.Precedence) == nil {
+		order = e.Precedence
+	}
+	out := make([]Map, 0, len(order))
+	for _, name := range order {
+		if strings.EqualFold(strings.TrimSpace(name), LayerLocal) {
+			out = append(out, e.Local)
+		} else {
+			out = append(out, e.Global)
+		}
+	}
+	return out
 }
 
 // UnmarshalYAML allows decoding a plain mapping under the `env` key directly into Local.
@@ -169,29 +236,39 @@ func (e *Env) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
-// merged returns a combined map (Global then overridden by Local).
+// merged returns a combined map of Global and Local, the layer first in precedence winning.
 func (e *Env) merged() map[string]string {
 	m := map[string]string{}
-	if e != nil && e.Global != nil {
-		for k, v := range e.Global {
+	layers := e.layers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		for k, v := range layers[i] {
 			if v != nil {
 				m[k] = v.String()
 			}
 		}
 	}
-	if e != nil && e.Local != nil {
-		for k, v := range e.Local {
-			if v != nil {
-				m[k] = v.String()
-			}
+	return m
+}
+
+// stringMap returns the string values of a single layer.
+func stringMap(src Map) map[string]string {
+	m := make(map[string]string, len(src))
+	for k, v := range src {
+		if v != nil {
+			m[k] = v.String()
 		}
 	}
 	return m
 }
 
+// UpBodyKey is the env name of the up response body kept for a down step (response.store_body),
+// also available to templates as {{.up_body}}.
+const UpBodyKey = "up_body"
+
 // dataForTemplate builds the dot object for template execution supporting both
 // legacy flat lookups (e.g., {{.kc_base}}) and the new
-// grouped lookups ({{.env.kc_base}}, {{.auth.keycloak}}).
+// grouped lookups ({{.env.kc_base}}, {{.auth.keycloak}}, {{.ctx.tenant_id}}) plus {{.run_id}}
+// and {{.up_body}}. {{.global.x}} and {{.local.x}} read a single layer, whatever the precedence.
 func (e *Env) dataForTemplate() map[string]interface{} {
 	// Build merged env for grouped access only (no flat exposure)
 	merged := e.merged()
@@ -204,26 +281,35 @@ func (e *Env) dataForTemplate() map[string]interface{} {
 		}
 	}
 
+	ctxMap := make(map[string]string)
+	globalMap, localMap := map[string]string{}, map[string]string{}
+	runID := ""
+	if e != nil {
+		globalMap, localMap = stringMap(e.Global), stringMap(e.Local)
+		for k, v := range e.Ctx {
+			if v != nil {
+				ctxMap[k] = v.String()
+			}
+		}
+		runID = e.RunID
+	}
+
 	return map[string]interface{}{
-		"env":  merged,
-		"auth": authMap,
+		"env":     merged,
+		"auth":    authMap,
+		"ctx":     ctxMap,
+		"global":  globalMap,
+		"local":   localMap,
+		"run_id":  runID,
+		"up_body": merged[UpBodyKey],
 	}
 }
 
-// Lookup searches Local first, then Global.
+// Lookup searches the Local and Global layers in precedence order (Local first by default).
 func (e *Env) Lookup(key string) (string, bool) {
-	if e != nil && e.Local != nil {
-		if v, ok := e.Local[key]; ok {
-			if v != nil {
-				return v.String(), true
-			}
-		}
-	}
-	if e != nil && e.Global != nil {
-		if v, ok := e.Global[key]; ok {
-			if v != nil {
-				return v.String(), true
-			}
+	for _, layer := range e.layers() {
+		if v, ok := layer[key]; ok && v != nil {
+			return v.String(), true
 		}
 	}
 	return "", false
@@ -257,7 +343,7 @@ func (e *Env) RenderGoTemplateErr(s string) (string, error) {
 		return "", fmt.Errorf("template security validation failed: %w", err)
 	}
 
-	t, err := template.New("gotmpl").Option("missingkey=error").Parse(s)
+	t, err := template.New("gotmpl").Funcs(templateFuncs()).Option("missingkey=error").Parse(s)
 	if err != nil {
 		return "", err
 	}
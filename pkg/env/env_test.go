@@ -73,6 +73,29 @@ func TestCloneDeepCopy(t *testing.T) {
 	}
 }
 
+func TestRunID_RenderAndClone(t *testing.T) {
+	e := New()
+	if got, err := e.RenderGoTemplateErr("id={{.run_id}}"); err != nil || got != "id=" {
+		t.Fatalf("unset run id should render empty: %q, %v", got, err)
+	}
+	e.RunID = "r-1"
+	cl := e.Clone()
+	if got, err := cl.RenderGoTemplateErr("id={{.run_id}}"); err != nil || got != "id=r-1" {
+		t.Fatalf("clone should keep the run id: %q, %v", got, err)
+	}
+}
+
+func TestUpBody_Render(t *testing.T) {
+	e := New()
+	if got, err := e.RenderGoTemplateErr("body={{.up_body}}"); err != nil || got != "body=" {
+		t.Fatalf("no kept body should render empty: %q, %v", got, err)
+	}
+	_ = e.SetString("local", UpBodyKey, "created u1")
+	if got, err := e.RenderGoTemplateErr("{{.up_body}}|{{.env.up_body}}"); err != nil || got != "created u1|created u1" {
+		t.Fatalf("expected the kept body under both names: %q, %v", got, err)
+	}
+}
+
 func TestLookupPrecedence(t *testing.T) {
 	e := New()
 	_ = e.SetString("global", "x", "G")
@@ -82,6 +105,56 @@ func TestLookupPrecedence(t *testing.T) {
 	}
 }
 
+func TestPrecedence_MergedViewAndNamespaces(t *testing.T) {
+	const tmpl = "env={{.env.x}} only={{.env.g}}/{{.env.l}} global={{.global.x}} local={{.local.x}}"
+	cases := []struct {
+		name       string
+		precedence []string
+		want       string
+		lookup     string
+	}{
+		{"default", nil, "env=L only=g/l global=G local=L", "L"},
+		{"local first", []string{"local", "global"}, "env=L only=g/l global=G local=L", "L"},
+		{"global first", []string{" Global", "LOCAL"}, "env=G only=g/l global=G local=L", "G"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := New()
+			_ = e.SetString("global", "x", "G")
+			_ = e.SetString("global", "g", "g")
+			_ = e.SetString("local", "x", "L")
+			_ = e.SetString("local", "l", "l")
+			e.Precedence = c.precedence
+			if got, err := e.Clone().RenderGoTemplateErr(tmpl); err != nil || got != c.want {
+				t.Fatalf("render = %q, %v; want %q", got, err, c.want)
+			}
+			if v, ok := e.Lookup("x"); !ok || v != c.lookup {
+				t.Fatalf("Lookup(x) = %q, %v; want %q", v, ok, c.lookup)
+			}
+		})
+	}
+}
+
+func TestValidatePrecedence(t *testing.T) {
+	for _, ok := range [][]string{nil, {"local", "global"}, {"GLOBAL", " local "}} {
+		if err := ValidatePrecedence(ok); err != nil {
+			t.Fatalf("ValidatePrecedence(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range [][]string{{"global"}, {"local", "local"}, {"local", "auth"}, {"local", "global", "ctx"}} {
+		if err := ValidatePrecedence(bad); err == nil {
+			t.Fatalf("ValidatePrecedence(%q) should fail", bad)
+		}
+	}
+	// An invalid order is ignored by lookups rather than dropping a layer
+	e := New()
+	_ = e.SetString("global", "x", "G")
+	e.Precedence = []string{"local"}
+	if v, ok := e.Lookup("x"); !ok || v != "G" {
+		t.Fatalf("Lookup with an invalid precedence = %q, %v", v, ok)
+	}
+}
+
 func TestRenderGoTemplateBasics(t *testing.T) {
 	e := &Env{Global: FromStringMap(map[string]string{"name": "world", "api": "http://x"}), Local: FromStringMap(map[string]string{"id": "42"})}
 	// also expose auth
@@ -0,0 +1,139 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFiles reads the env files in order and merges their values, later files overriding earlier
+// ones. See LoadFile for the formats.
+func LoadFiles(paths ...string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, p := range paths {
+		vals, err := LoadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range vals {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// LoadFile reads an env file, its format chosen by extension: .yaml/.yml and .json hold a flat
+// object of scalar values; .env is dotenv (KEY=VALUE lines, optional `export`, # comments and
+// quoted values).
+func LoadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- env files are named by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file %q: %w", path, err)
+	}
+	var vals map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		var raw map[string]interface{}
+		if err = yaml.Unmarshal(data, &raw); err == nil {
+			vals, err = scalarValues(raw)
+		}
+	case ".json":
+		var raw map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err = dec.Decode(&raw); err == nil {
+			vals, err = scalarValues(raw)
+		}
+	case ".env":
+		vals, err = parseDotenv(data)
+	default:
+		return nil, fmt.Errorf("unsupported env file %q: use a .yaml, .yml, .json or .env extension", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid env file %q: %w", path, err)
+	}
+	return vals, nil
+}
+
+// scalarValues converts the values of a decoded YAML/JSON object to strings; null becomes "".
+func scalarValues(raw map[string]interface{}) (map[string]string, error) {
+	out := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch val := v.(type) {
+		case nil:
+			out[k] = ""
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("value of %q is not a scalar", k)
+		default:
+			out[k] = fmt.Sprint(val)
+		}
+	}
+	return out, nil
+}
+
+// parseDotenv parses KEY=VALUE lines. Double-quoted values take Go escapes (\n, \"), single-quoted
+// values are literal, and a " #" outside quotes starts a comment.
+func parseDotenv(data []byte) (map[string]string, error) {
+	out := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, val, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		val, err := dotenvValue(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w for %q", n, err, key)
+		}
+		out[key] = val
+	}
+	return out, sc.Err()
+}
+
+// dotenvValue returns the value of a dotenv line without its quotes and trailing comment.
+func dotenvValue(val string) (string, error) {
+	if val == "" || (val[0] != '"' && val[0] != '\'') {
+		if i := strings.Index(val, " #"); i >= 0 {
+			val = strings.TrimSpace(val[:i])
+		}
+		return val, nil
+	}
+	end := -1
+	for i := 1; i < len(val); i++ {
+		if val[0] == '"' && val[i] == '\\' {
+			i++ // skip the escaped character
+			continue
+		}
+		if val[i] == val[0] {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+	if rest := strings.TrimSpace(val[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected text after the quoted value")
+	}
+	if val[0] == '\'' {
+		return val[1:end], nil
+	}
+	unq, err := strconv.Unquote(val[:end+1])
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value")
+	}
+	return unq, nil
+}
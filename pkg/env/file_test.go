@@ -0,0 +1,86 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return p
+}
+
+func TestLoadFile_Formats(t *testing.T) {
+	want := map[string]string{"api_base": "https://eu.example.com", "realm_id": "42", "enabled": "true", "empty": ""}
+	files := map[string]string{
+		"prod.yaml": "api_base: https://eu.example.com\nrealm_id: 42\nenabled: true\nempty:\n",
+		"prod.yml":  "api_base: \"https://eu.example.com\"\nrealm_id: '42'\nenabled: true\nempty: null\n",
+		"prod.json": `{"api_base":"https://eu.example.com","realm_id":42,"enabled":true,"empty":null}`,
+		"prod.env":  "# prod values\nexport api_base=https://eu.example.com # region\nrealm_id = 42\nenabled='true'\nempty=\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			got, err := LoadFile(writeEnvFile(t, name, content))
+			if err != nil {
+				t.Fatalf("LoadFile: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Fatalf("%s = %q, want %q (all: %v)", k, got[k], v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadFile_DotenvQuoting(t *testing.T) {
+	got, err := LoadFile(writeEnvFile(t, ".env", "A=\"line1\\nline2 # kept\"  # comment\nB='a \\n b'\nC=x=y\nD=\"say \\\"hi\\\"\"\n"))
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if got["A"] != "line1\nline2 # kept" || got["B"] != `a \n b` || got["C"] != "x=y" || got["D"] != `say "hi"` {
+		t.Fatalf("unexpected values: %q", got)
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	for name, tc := range map[string]struct{ file, content, want string }{
+		"extension":  {"vals.txt", "a=1", "unsupported env file"},
+		"nested":     {"vals.yaml", "a:\n  b: 1\n", `value of "a" is not a scalar`},
+		"json array": {"vals.json", `{"a":[1]}`, `value of "a" is not a scalar`},
+		"no equals":  {"vals.env", "a=1\nbroken\n", "line 2: expected KEY=VALUE"},
+		"unclosed":   {"vals.env", "a=\"open\n", `line 1: unterminated quoted value for "a"`},
+		"bad yaml":   {"vals.yaml", "a: [1\n", "invalid env file"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadFile(writeEnvFile(t, tc.file, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
+
+func TestLoadFiles_LaterFilesOverride(t *testing.T) {
+	base := writeEnvFile(t, "base.yaml", "api_base: https://base\nrealm: demo\n")
+	prod := writeEnvFile(t, "prod.env", "api_base=https://prod\n")
+	got, err := LoadFiles(base, prod)
+	if err != nil {
+		t.Fatalf("LoadFiles: %v", err)
+	}
+	if got["api_base"] != "https://prod" || got["realm"] != "demo" {
+		t.Fatalf("unexpected merge: %v", got)
+	}
+}
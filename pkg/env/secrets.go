@@ -0,0 +1,83 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+// ErrSecretResolution is wrapped by errors returned from the `secret` and `file` template
+// functions so callers can fail instead of silently keeping the unrendered template.
+var ErrSecretResolution = errors.New("secret resolution failed")
+
+// SecretResolver resolves a named secret for the `secret` template function.
+// It returns ok=false when the secret does not exist.
+type SecretResolver func(name string) (value string, ok bool, err error)
+
+// EnvSecretResolver resolves secrets from OS environment variables. It is the default resolver.
+func EnvSecretResolver(name string) (string, bool, error) {
+	v, ok := os.LookupEnv(name)
+	return v, ok, nil
+}
+
+var (
+	secretResolverMu sync.RWMutex
+	secretResolver   SecretResolver = EnvSecretResolver
+)
+
+// SetSecretResolver replaces the resolver used by the `secret` template function
+// (e.g. to read from a vault). Passing nil restores EnvSecretResolver.
+func SetSecretResolver(r SecretResolver) {
+	secretResolverMu.Lock()
+	defer secretResolverMu.Unlock()
+	if r == nil {
+		r = EnvSecretResolver
+	}
+	secretResolver = r
+}
+
+// templateFuncs returns the functions available to every rendered template:
+//
+//	{{ secret "VAULT_TOKEN" }}     value from the configured SecretResolver (OS env by default)
+//	{{ file "/run/secrets/pw" }}   content of a file, without the trailing newline
+//
+// Resolved values are registered with the masker so they never show up in logs.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"secret": resolveSecret,
+		"file":   readSecretFile,
+	}
+}
+
+func resolveSecret(name string) (string, error) {
+	secretResolverMu.RLock()
+	r := secretResolver
+	secretResolverMu.RUnlock()
+	v, ok, err := r(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: secret %q: %v", ErrSecretResolution, name, err)
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: secret %q is not set", ErrSecretResolution, name)
+	}
+	common.RegisterSecret(v)
+	return v, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	clean := filepath.Clean(path)
+	// #nosec G304 -- reading user-referenced secret files is the purpose of this function
+	data, err := os.ReadFile(clean)
+	if err != nil {
+		return "", fmt.Errorf("%w: cannot read file %q: %v", ErrSecretResolution, clean, err)
+	}
+	v := strings.TrimRight(string(data), "\r\n")
+	common.RegisterSecret(v)
+	return v, nil
+}
@@ -0,0 +1,84 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loykin/apirun/internal/common"
+)
+
+func TestTemplateFunc_Secret_FromOSEnv(t *testing.T) {
+	t.Setenv("APIRUN_TEST_SECRET_TOKEN", "s3cr3t-token-value")
+	e := New()
+	out, err := e.RenderGoTemplateErr(`Bearer {{ secret "APIRUN_TEST_SECRET_TOKEN" }}`)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if out != "Bearer s3cr3t-token-value" {
+		t.Fatalf("unexpected render: %q", out)
+	}
+	// Resolved values are masked everywhere, including per-logger maskers
+	if got := common.NewMasker().MaskString("value=s3cr3t-token-value"); strings.Contains(got, "s3cr3t-token-value") {
+		t.Fatalf("secret not masked: %q", got)
+	}
+}
+
+func TestTemplateFunc_Secret_Missing(t *testing.T) {
+	e := New()
+	_, err := e.RenderGoTemplateErr(`{{ secret "APIRUN_TEST_SECRET_DOES_NOT_EXIST" }}`)
+	if err == nil || !errors.Is(err, ErrSecretResolution) {
+		t.Fatalf("expected ErrSecretResolution, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "APIRUN_TEST_SECRET_DOES_NOT_EXIST") {
+		t.Fatalf("expected error to name the secret, got %v", err)
+	}
+}
+
+func TestTemplateFunc_Secret_CustomResolver(t *testing.T) {
+	SetSecretResolver(func(name string) (string, bool, error) {
+		if name == "db" {
+			return "from-vault-pw", true, nil
+		}
+		return "", false, nil
+	})
+	defer SetSecretResolver(nil)
+
+	e := New()
+	out, err := e.RenderGoTemplateErr(`{{ secret "db" }}`)
+	if err != nil || out != "from-vault-pw" {
+		t.Fatalf("unexpected render: %q, %v", out, err)
+	}
+	if _, err := e.RenderGoTemplateErr(`{{ secret "other" }}`); !errors.Is(err, ErrSecretResolution) {
+		t.Fatalf("expected ErrSecretResolution, got %v", err)
+	}
+}
+
+func TestTemplateFunc_File(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "pw")
+	if err := os.WriteFile(p, []byte("file-secret-pw\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	e := New()
+	out, err := e.RenderGoTemplateErr(`{"password":"{{ file "` + p + `" }}"}`)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if out != `{"password":"file-secret-pw"}` {
+		t.Fatalf("unexpected render: %q", out)
+	}
+	if got := common.MaskSensitiveData("pw is file-secret-pw"); strings.Contains(got, "file-secret-pw") {
+		t.Fatalf("file secret not masked: %q", got)
+	}
+}
+
+func TestTemplateFunc_File_Missing(t *testing.T) {
+	e := New()
+	missing := filepath.Join(t.TempDir(), "nope")
+	_, err := e.RenderGoTemplateErr(`{{ file "` + missing + `" }}`)
+	if err == nil || !errors.Is(err, ErrSecretResolution) || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("expected descriptive ErrSecretResolution, got %v", err)
+	}
+}
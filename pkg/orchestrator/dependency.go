@@ -3,6 +3,7 @@ package orchestrator
 import (
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // DependencyGraph represents a directed graph of stage dependencies
@@ -265,3 +266,49 @@ func (dg *DependencyGraph) GetAllDependents(stageName string) []string {
 	collect(stageName)
 	return result
 }
+
+// DOT renders the graph in Graphviz DOT, an edge pointing from a stage to each stage depending on
+// it. The stages of each execution batch (see GetBatches) share a rank inside a "batch N" cluster,
+// so stages that can run in parallel line up; a graph with a cycle is rendered without batches.
+func (dg *DependencyGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph stages {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	if batches, err := dg.GetBatches(); err == nil {
+		for i, batch := range batches {
+			fmt.Fprintf(&b, "\tsubgraph cluster_batch_%d {\n\t\tlabel=\"batch %d\";\n\t\tstyle=dashed;\n\t\trank=same;\n", i+1, i+1)
+			for _, name := range batch {
+				fmt.Fprintf(&b, "\t\t%s;\n", dotID(name))
+			}
+			b.WriteString("\t}\n")
+		}
+	} else {
+		names := make([]string, 0, len(dg.nodes))
+		for name := range dg.nodes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "\t%s;\n", dotID(name))
+		}
+	}
+
+	froms := make([]string, 0, len(dg.edges))
+	for from := range dg.edges {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		tos := append([]string(nil), dg.edges[from]...)
+		sort.Strings(tos)
+		for _, to := range tos {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", dotID(from), dotID(to))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotID quotes a stage name as a DOT identifier.
+func dotID(name string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
@@ -3,6 +3,7 @@ package orchestrator
 import (
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -431,3 +432,63 @@ func TestDependencyGraph_GetBatches(t *testing.T) {
 		}
 	}
 }
+
+func TestDependencyGraph_DOT(t *testing.T) {
+	dg := NewDependencyGraph()
+	stages := []Stage{
+		{Name: "infra"},
+		{Name: "auth", DependsOn: []string{"infra"}},
+		{Name: "db", DependsOn: []string{"infra"}},
+		{Name: `app "v2"`, DependsOn: []string{"auth", "db"}},
+	}
+	if err := dg.BuildGraph(stages); err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	want := `digraph stages {
+	rankdir=LR;
+	node [shape=box];
+	subgraph cluster_batch_1 {
+		label="batch 1";
+		style=dashed;
+		rank=same;
+		"infra";
+	}
+	subgraph cluster_batch_2 {
+		label="batch 2";
+		style=dashed;
+		rank=same;
+		"auth";
+		"db";
+	}
+	subgraph cluster_batch_3 {
+		label="batch 3";
+		style=dashed;
+		rank=same;
+		"app \"v2\"";
+	}
+	"auth" -> "app \"v2\"";
+	"db" -> "app \"v2\"";
+	"infra" -> "auth";
+	"infra" -> "db";
+}
+`
+	if got := dg.DOT(); got != want {
+		t.Fatalf("DOT mismatch:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDependencyGraph_DOT_Cycle(t *testing.T) {
+	dg := NewDependencyGraph()
+	if err := dg.BuildGraph([]Stage{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}}); err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	got := dg.DOT()
+	for _, want := range []string{"\t\"a\";\n", "\t\"b\";\n", `"a" -> "b";`, `"b" -> "a";`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in DOT:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "cluster_batch") {
+		t.Fatalf("a cyclic graph has no batches:\n%s", got)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/loykin/apirun"
 	"gopkg.in/yaml.v3"
 )
 
@@ -73,6 +75,10 @@ func validateOrchestration(orchestration *StageOrchestration) error {
 			return fmt.Errorf("stage %s: invalid on_failure value: %s (must be one of: stop, continue, skip_dependents)", stage.Name, stage.OnFailure)
 		}
 
+		if r := stage.Retry; r != nil && (r.MaxAttempts < 0 || r.Backoff < 0) {
+			return fmt.Errorf("stage %s: retry max_attempts and backoff must not be negative", stage.Name)
+		}
+
 		// Self-dependency check
 		for _, dep := range stage.DependsOn {
 			if dep == stage.Name {
@@ -81,6 +87,10 @@ func validateOrchestration(orchestration *StageOrchestration) error {
 		}
 	}
 
+	if err := validateGlobalStore(orchestration); err != nil {
+		return err
+	}
+
 	// Validate all dependencies exist
 	for _, stage := range orchestration.Stages {
 		for _, dep := range stage.DependsOn {
@@ -93,11 +103,57 @@ func validateOrchestration(orchestration *StageOrchestration) error {
 	return nil
 }
 
+// validateGlobalStore checks global.store and that per-stage table prefixes are valid and distinct
+func validateGlobalStore(orchestration *StageOrchestration) error {
+	g := orchestration.Global
+	if g.Store == nil {
+		if g.TablePrefixPerStage {
+			return fmt.Errorf("global.table_prefix_per_stage requires global.store")
+		}
+		return nil
+	}
+	switch strings.ToLower(strings.TrimSpace(g.Store.Type)) {
+	case "", apirun.DriverSqlite, apirun.DriverPostgresql, "postgres":
+	default:
+		return fmt.Errorf("global.store: unsupported type %q (must be sqlite or postgres)", g.Store.Type)
+	}
+	owners := make(map[string]string)
+	for _, stage := range orchestration.Stages {
+		prefix := g.tablePrefixFor(stage.Name)
+		if prefix == "" {
+			continue
+		}
+		if !tablePrefixRe.MatchString(prefix) {
+			return fmt.Errorf("stage %s: %q is not a valid table prefix", stage.Name, prefix)
+		}
+		if g.TablePrefixPerStage {
+			if other, ok := owners[prefix]; ok {
+				return fmt.Errorf("stages %s and %s map to the same table prefix %q", other, stage.Name, prefix)
+			}
+			owners[prefix] = stage.Name
+		}
+	}
+	return nil
+}
+
 // resolveConfigPaths resolves relative paths in the orchestration configuration
 func resolveConfigPaths(orchestration *StageOrchestration, baseDir string) error {
+	// A relative global sqlite path is relative to the orchestration file
+	if gs := orchestration.Global.Store; gs != nil {
+		if p := strings.TrimSpace(gs.SQLite.Path); p != "" && !filepath.IsAbs(p) {
+			gs.SQLite.Path = filepath.Join(baseDir, p)
+		}
+	}
+
+	orchestration.baseDir = baseDir
 	for i := range orchestration.Stages {
 		stage := &orchestration.Stages[i]
 
+		// A templated config_path is resolved once rendered, when the stage runs
+		if isTemplated(stage.ConfigPath) {
+			continue
+		}
+
 		// Resolve config_path if it's relative
 		if !filepath.IsAbs(stage.ConfigPath) {
 			stage.ConfigPath = filepath.Join(baseDir, stage.ConfigPath)
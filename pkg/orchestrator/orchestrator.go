@@ -8,3 +8,4 @@ package orchestrator
 // - orchestrator_execution.go: Stage execution logic
 // - orchestrator_config.go: Configuration loading and management
 // - orchestrator_utils.go: Helper functions and utilities
+// - orchestrator_state.go: Persisted stage results for resuming a failed run
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/internal/constants"
+	"github.com/loykin/apirun/pkg/env"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,6 +19,8 @@ type StageConfig struct {
 	Auth        []apirun.Auth       `yaml:"auth"`
 	Env         map[string]string   `yaml:"env"`
 	StoreConfig *apirun.StoreConfig `yaml:"store"`
+	// VersionPattern overrides the migration file name pattern (see apirun.Migrator.VersionPattern)
+	VersionPattern string `yaml:"version_pattern"`
 }
 
 // loadStageConfig loads the configuration for a stage
@@ -30,11 +36,110 @@ func (o *Orchestrator) loadStageConfig(configPath string) (*StageConfig, error)
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	// Resolve relative paths
+	// Resolve relative paths; a templated migrate_dir is resolved once rendered (see stageConfig)
 	baseDir := filepath.Dir(configPath)
-	if config.MigrateDir != "" && !filepath.IsAbs(config.MigrateDir) {
+	if config.MigrateDir != "" && !isTemplated(config.MigrateDir) && !filepath.IsAbs(config.MigrateDir) {
 		config.MigrateDir = filepath.Join(baseDir, config.MigrateDir)
 	}
 
 	return &config, nil
 }
+
+// stageConfig loads the configuration of a stage. Its config_path and the config's migrate_dir
+// may be templates rendered with stageEnv, e.g. "{{.env.tenant_dir}}/migrations" with tenant_dir
+// taken from a dependency through env_from_stages. Rendered relative paths are resolved against
+// the orchestration file and the stage config respectively.
+func (o *Orchestrator) stageConfig(stage *Stage, stageEnv *env.Env) (*StageConfig, error) {
+	configPath, err := renderStagePath("config_path", stage.ConfigPath, o.config.baseDir, stageEnv)
+	if err != nil {
+		return nil, err
+	}
+	config, err := o.loadStageConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	config.MigrateDir, err = renderStagePath("migrate_dir", config.MigrateDir, filepath.Dir(configPath), stageEnv)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// renderStagePath renders a templated path with stageEnv and resolves it against baseDir when
+// relative. Paths without templates are returned unchanged.
+func renderStagePath(field, path, baseDir string, stageEnv *env.Env) (string, error) {
+	if !isTemplated(path) {
+		return path, nil
+	}
+	rendered, err := stageEnv.RenderGoTemplateErr(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s %q: %w", field, path, err)
+	}
+	rendered = strings.TrimSpace(rendered)
+	if rendered == "" {
+		return "", fmt.Errorf("%s %q rendered to an empty path", field, path)
+	}
+	if !filepath.IsAbs(rendered) && baseDir != "" {
+		rendered = filepath.Join(baseDir, rendered)
+	}
+	return rendered, nil
+}
+
+// isTemplated reports whether a path contains a Go template action.
+func isTemplated(path string) bool {
+	return strings.Contains(path, "{{")
+}
+
+// tablePrefixRe matches prefixes that yield valid SQL identifiers
+var tablePrefixRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// stageTablePrefix derives a table name prefix from a stage name ("infra-db" -> "infra_db")
+func stageTablePrefix(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '.', ' ':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// tablePrefixFor returns the table prefix a stage uses on the global store ("" = default tables)
+func (g Global) tablePrefixFor(stage string) string {
+	prefix := ""
+	if g.Store != nil {
+		prefix = strings.TrimSpace(g.Store.TablePrefix)
+	}
+	if !g.TablePrefixPerStage {
+		return prefix
+	}
+	if prefix != "" {
+		return prefix + "_" + stageTablePrefix(stage)
+	}
+	return stageTablePrefix(stage)
+}
+
+// stageStoreConfig returns the store for a stage's migrator: the stage's own `store` when set,
+// otherwise a fresh config built from Global.Store (nil = default sqlite under migrate_dir)
+func (o *Orchestrator) stageStoreConfig(stage *Stage, config *StageConfig) *apirun.StoreConfig {
+	if config.StoreConfig != nil || o.config.Global.Store == nil {
+		return config.StoreConfig
+	}
+	gs := o.config.Global.Store
+	var names apirun.TableNames
+	if prefix := o.config.Global.tablePrefixFor(stage.Name); prefix != "" {
+		names = apirun.TableNames{
+			SchemaMigrations: prefix + constants.SchemaMigrationsSuffix,
+			MigrationRuns:    prefix + constants.MigrationLogSuffix,
+			StoredEnv:        prefix + constants.StoredEnvSuffix,
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(gs.Type)) {
+	case apirun.DriverPostgresql, "postgres":
+		pg := gs.Postgres
+		return apirun.NewPostgresStoreConfig(&pg, names)
+	default:
+		sc := gs.SQLite
+		return apirun.NewSqliteStoreConfig(&sc, names)
+	}
+}
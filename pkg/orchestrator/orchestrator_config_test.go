@@ -1,11 +1,20 @@
 package orchestrator
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/pkg/env"
 )
 
 func TestOrchestrator_loadStageConfig(t *testing.T) {
@@ -188,3 +197,187 @@ func TestStageConfig_Types(t *testing.T) {
 		t.Error("StageConfig.StoreConfig field not working")
 	}
 }
+
+func TestOrchestrator_stageStoreConfig(t *testing.T) {
+	stage := &Stage{Name: "infra"}
+	orch := NewOrchestrator(&StageOrchestration{Global: Global{
+		Store: &GlobalStore{
+			Type:        "postgres",
+			Postgres:    apirun.PostgresConfig{DSN: "postgres://u:p@db/app"},
+			TablePrefix: "apirun",
+		},
+		TablePrefixPerStage: true,
+	}})
+
+	cfg := orch.stageStoreConfig(stage, &StageConfig{})
+	if cfg == nil || cfg.Driver != apirun.DriverPostgresql {
+		t.Fatalf("expected postgres store config, got %+v", cfg)
+	}
+	want := apirun.TableNames{
+		SchemaMigrations: "apirun_infra_schema_migrations",
+		MigrationRuns:    "apirun_infra_migration_log",
+		StoredEnv:        "apirun_infra_stored_env",
+	}
+	if cfg.TableNames != want {
+		t.Fatalf("unexpected table names: %+v", cfg.TableNames)
+	}
+	if pg, ok := cfg.DriverConfig.(*apirun.PostgresConfig); !ok || pg.DSN != "postgres://u:p@db/app" || pg == &orch.config.Global.Store.Postgres {
+		t.Fatalf("expected a copy of the shared postgres config, got %#v", cfg.DriverConfig)
+	}
+
+	// A stage with its own store keeps it
+	own := &apirun.StoreConfig{}
+	if got := orch.stageStoreConfig(stage, &StageConfig{StoreConfig: own}); got != own {
+		t.Fatalf("expected stage store override to win")
+	}
+
+	// Without per-stage prefixes all stages share the global table prefix
+	orch.config.Global.TablePrefixPerStage = false
+	if got := orch.stageStoreConfig(stage, &StageConfig{}); got.TableNames.SchemaMigrations != "apirun_schema_migrations" {
+		t.Fatalf("unexpected shared table names: %+v", got.TableNames)
+	}
+
+	// No global store leaves the stage config untouched
+	if got := NewOrchestrator(&StageOrchestration{}).stageStoreConfig(stage, &StageConfig{}); got != nil {
+		t.Fatalf("expected nil store config, got %+v", got)
+	}
+}
+
+func TestValidateGlobalStore(t *testing.T) {
+	stages := []Stage{{Name: "infra-db"}, {Name: "infra.db"}}
+	tests := []struct {
+		name    string
+		global  Global
+		wantErr string
+	}{
+		{"no store", Global{}, ""},
+		{"prefix per stage without store", Global{TablePrefixPerStage: true}, "requires global.store"},
+		{"bad type", Global{Store: &GlobalStore{Type: "mysql"}}, "unsupported type"},
+		{"colliding prefixes", Global{Store: &GlobalStore{}, TablePrefixPerStage: true}, "same table prefix"},
+		{"shared tables", Global{Store: &GlobalStore{Type: "sqlite"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGlobalStore(&StageOrchestration{Stages: stages, Global: tt.global})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+	err := validateGlobalStore(&StageOrchestration{Stages: []Stage{{Name: "1st"}}, Global: Global{Store: &GlobalStore{}, TablePrefixPerStage: true}})
+	if err == nil || !strings.Contains(err.Error(), "not a valid table prefix") {
+		t.Fatalf("expected invalid prefix error, got %v", err)
+	}
+}
+
+func TestValidateOrchestration_Retry(t *testing.T) {
+	for _, r := range []*StageRetry{{MaxAttempts: -1}, {MaxAttempts: 2, Backoff: -time.Second}} {
+		o := &StageOrchestration{Stages: []Stage{{Name: "a", ConfigPath: "a.yaml", Retry: r}}}
+		if err := validateOrchestration(o); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+			t.Fatalf("expected a retry error for %+v, got %v", r, err)
+		}
+	}
+	o := &StageOrchestration{Stages: []Stage{{Name: "a", ConfigPath: "a.yaml", Retry: &StageRetry{MaxAttempts: 3, Backoff: time.Second}}}}
+	if err := validateOrchestration(o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestOrchestrator_TemplatedStagePaths(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"tenant":"acme"}`))
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	write := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	// Stage "tenant" extracts the tenant whose directory holds the config of stage "app"
+	write("tenant/stage.yaml", "migrate_dir: ./migrations\n")
+	write("tenant/migrations/001_create.yaml", fmt.Sprintf("up:\n  name: create tenant\n  request:\n    method: POST\n    url: %s/tenants\n"+
+		"  response:\n    result_code: [\"200\"]\n    env_from:\n      tenant: tenant\n", srv.URL))
+	write("tenants/acme/stage.yaml", "migrate_dir: \"migrations-{{.env.tenant}}\"\n")
+	write("tenants/acme/migrations-acme/001_deploy.yaml", fmt.Sprintf("up:\n  name: deploy\n  request:\n    method: POST\n    url: %[1]s/apps/{{.env.tenant}}\n"+
+		"down:\n  name: undeploy\n  method: DELETE\n  url: %[1]s/apps/acme\n", srv.URL))
+	write("stages.yaml", `global:
+  env:
+    tenants_dir: tenants
+  state_file: .stages.state
+stages:
+  - name: tenant
+    config_path: tenant/stage.yaml
+  - name: app
+    config_path: "{{.env.tenants_dir}}/{{.env.tenant}}/stage.yaml"
+    depends_on: [tenant]
+    env_from_stages:
+      - stage: tenant
+        vars: [tenant]
+`)
+
+	cfgPath := filepath.Join(root, "stages.yaml")
+	orch, err := LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("ExecuteStages: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tenants", "acme", "migrations-acme", apirun.StoreDBFileName)); err != nil {
+		t.Fatalf("app must run from the rendered migrate dir: %v", err)
+	}
+
+	// Rolling back in a new run finds the directory through the recorded tenant
+	orch, err = LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if err := orch.ExecuteStagesDown(context.Background(), "app", "tenant"); err != nil {
+		t.Fatalf("ExecuteStagesDown: %v", err)
+	}
+	want := []string{"POST /tenants", "POST /apps/acme", "DELETE /apps/acme"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRenderStagePath(t *testing.T) {
+	e := env.New()
+	_ = e.SetString("local", "dir", "out/acme")
+	tests := []struct {
+		path, base, want, wantErr string
+	}{
+		{path: "/plain/stage.yaml", base: "/root", want: "/plain/stage.yaml"},
+		{path: "{{.env.dir}}/stage.yaml", base: "/root", want: "/root/out/acme/stage.yaml"},
+		{path: "/abs/{{.env.dir}}", base: "/root", want: "/abs/out/acme"},
+		{path: "{{.env.missing}}/stage.yaml", base: "/root", wantErr: "failed to render config_path"},
+		{path: "{{\"\"}}", base: "/root", wantErr: "rendered to an empty path"},
+	}
+	for _, tt := range tests {
+		got, err := renderStagePath("config_path", tt.path, tt.base, e)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("%q: expected error containing %q, got %v", tt.path, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("%q: got %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
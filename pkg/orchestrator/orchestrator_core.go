@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -28,6 +30,11 @@ type Orchestrator struct {
 	context *ExecutionContext
 	logger  *slog.Logger
 	mu      sync.RWMutex
+	// completed holds the stages Resume restored as successful; ExecuteStages skips them
+	completed map[string]bool
+	// statePath is the file stage results are persisted to ("" = no persistence)
+	statePath string
+	stateMu   sync.Mutex
 }
 
 // NewOrchestrator creates a new orchestrator with the given configuration
@@ -40,7 +47,8 @@ func NewOrchestrator(config *StageOrchestration) *Orchestrator {
 			GlobalEnv:     config.Global.Env,
 			SkippedStages: make(map[string]string),
 		},
-		logger: slog.With("component", "orchestrator"),
+		logger:    slog.With("component", "orchestrator"),
+		completed: make(map[string]bool),
 	}
 }
 
@@ -55,6 +63,12 @@ func LoadFromFile(configPath string) (*Orchestrator, error) {
 	if err := orchestrator.initialize(); err != nil {
 		return nil, fmt.Errorf("failed to initialize orchestrator: %w", err)
 	}
+	if stateFile := strings.TrimSpace(config.Global.StateFile); stateFile != "" {
+		if !filepath.IsAbs(stateFile) {
+			stateFile = filepath.Join(filepath.Dir(configPath), stateFile)
+		}
+		orchestrator.SetStateFile(stateFile)
+	}
 
 	return orchestrator, nil
 }
@@ -10,7 +10,8 @@ import (
 	"github.com/loykin/apirun/pkg/env"
 )
 
-// ExecuteStages executes stages in dependency order, running independent stages concurrently within each batch
+// ExecuteStages executes stages in dependency order; independent stages of a batch may run concurrently
+// (see Global.MaxParallelStages)
 func (o *Orchestrator) ExecuteStages(ctx context.Context, fromStage, toStage string) error {
 	o.logger.Info("starting stage execution",
 		"from_stage", fromStage,
@@ -53,47 +54,74 @@ func (o *Orchestrator) ExecuteStages(ctx context.Context, fromStage, toStage str
 	return nil
 }
 
-// executeBatch runs all stages in the batch concurrently and waits for all to finish
+// executeBatch runs the stages of a batch, concurrently up to Global.MaxParallelStages.
+// A failing stage whose on_failure is "stop" cancels its still running siblings.
 func (o *Orchestrator) executeBatch(ctx context.Context, batch []string) error {
-	if len(batch) == 1 {
-		stage := o.getStageByName(batch[0])
+	stages := make([]*Stage, 0, len(batch))
+	for _, stageName := range batch {
+		stage := o.getStageByName(stageName)
 		if stage == nil {
-			return fmt.Errorf("stage not found: %s", batch[0])
+			return fmt.Errorf("stage not found: %s", stageName)
 		}
-		if err := o.executeStage(ctx, stage); err != nil {
-			return o.handleStageFailure(stage, err)
+		stages = append(stages, stage)
+	}
+
+	workers := o.config.Global.MaxParallelStages
+	if workers <= 1 || len(stages) == 1 {
+		for _, stage := range stages {
+			if err := o.executeStage(ctx, stage); err != nil {
+				if handleErr := o.handleStageFailure(stage, err); handleErr != nil {
+					return handleErr
+				}
+			}
 		}
 		return nil
 	}
+	if workers > len(stages) {
+		workers = len(stages)
+	}
 
-	var wg sync.WaitGroup
-	errCh := make(chan error, len(batch))
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	for _, stageName := range batch {
-		stage := o.getStageByName(stageName)
-		if stage == nil {
-			return fmt.Errorf("stage not found: %s", stageName)
-		}
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	queue := make(chan *Stage)
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(s *Stage) {
+		go func() {
 			defer wg.Done()
-			if err := o.executeStage(ctx, s); err != nil {
-				if handleErr := o.handleStageFailure(s, err); handleErr != nil {
-					errCh <- handleErr
+			for s := range queue {
+				if err := o.executeStage(batchCtx, s); err != nil {
+					if handleErr := o.handleStageFailure(s, err); handleErr != nil {
+						errOnce.Do(func() {
+							firstErr = handleErr
+							cancel()
+						})
+					}
 				}
 			}
-		}(stage)
+		}()
 	}
 
+dispatch:
+	for _, stage := range stages {
+		select {
+		case queue <- stage:
+		case <-batchCtx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
 	wg.Wait()
-	close(errCh)
 
-	for err := range errCh {
-		if err != nil {
-			return err
-		}
+	if firstErr != nil {
+		return firstErr
 	}
-	return nil
+	return ctx.Err()
 }
 
 // ExecuteStagesDown executes down migrations for stages in reverse order
@@ -138,6 +166,7 @@ func (o *Orchestrator) ExecuteStagesDown(ctx context.Context, fromStage, toStage
 		if err := o.executeStageDown(ctx, stage); err != nil {
 			return o.handleStageFailure(stage, err)
 		}
+		o.forgetStage(stageName)
 
 		// Wait between stages if configured
 		if i < len(filteredOrder)-1 && o.config.Global.WaitBetweenStages > 0 {
@@ -155,6 +184,11 @@ func (o *Orchestrator) ExecuteStagesDown(ctx context.Context, fromStage, toStage
 
 // executeStage executes a single stage
 func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
+	if o.isCompleted(stage.Name) {
+		o.logger.Info("stage already completed, skipping on resume", "stage", stage.Name)
+		return nil
+	}
+
 	// Check if stage is marked as skipped
 	o.mu.RLock()
 	reason, isSkipped := o.context.SkippedStages[stage.Name]
@@ -169,6 +203,7 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 			Name:         stage.Name,
 			Success:      false,
 			Error:        fmt.Sprintf("skipped: %s", reason),
+			SkipReason:   reason,
 			StartTime:    time.Now(),
 			EndTime:      time.Now(),
 			Duration:     0,
@@ -178,6 +213,7 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 		o.mu.Lock()
 		o.context.StageResults[stage.Name] = result
 		o.mu.Unlock()
+		o.saveState()
 
 		return nil // Don't propagate error for skipped stages
 	}
@@ -194,6 +230,7 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 		o.mu.Lock()
 		o.context.StageResults[stage.Name] = result
 		o.mu.Unlock()
+		o.saveState()
 	}()
 
 	// Check condition if specified
@@ -202,6 +239,7 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 			"stage", stage.Name,
 			"condition", stage.Condition)
 		result.Success = true
+		result.SkipReason = fmt.Sprintf("condition %q not met", stage.Condition)
 		return nil
 	}
 
@@ -213,7 +251,7 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 	}
 
 	// Load stage configuration
-	config, err := o.loadStageConfig(stage.ConfigPath)
+	config, err := o.stageConfig(stage, stageEnv)
 	if err != nil {
 		result.Error = err.Error()
 		return fmt.Errorf("failed to load config for stage %s: %w", stage.Name, err)
@@ -221,21 +259,14 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 
 	// Execute the stage using apirun Migrator
 	migrator := &apirun.Migrator{
-		Dir:         config.MigrateDir,
-		Env:         stageEnv,
-		Auth:        config.Auth,
-		StoreConfig: config.StoreConfig,
-	}
-
-	// Apply stage timeout if specified
-	stageCtx := ctx
-	if stage.Timeout > 0 {
-		var cancel context.CancelFunc
-		stageCtx, cancel = context.WithTimeout(ctx, stage.Timeout)
-		defer cancel()
+		Dir:            config.MigrateDir,
+		VersionPattern: config.VersionPattern,
+		Env:            stageEnv,
+		Auth:           config.Auth,
+		StoreConfig:    o.stageStoreConfig(stage, config),
 	}
 
-	execResults, err := migrator.MigrateUp(stageCtx, 0)
+	execResults, err := o.migrateUpWithRetry(ctx, stage, migrator, result)
 	if err != nil {
 		result.Error = err.Error()
 		return err
@@ -254,11 +285,58 @@ func (o *Orchestrator) executeStage(ctx context.Context, stage *Stage) error {
 	o.logger.Info("stage executed successfully",
 		"stage", stage.Name,
 		"duration", result.Duration,
+		"attempts", result.Attempts,
 		"extracted_vars", len(result.ExtractedEnv))
 
 	return nil
 }
 
+// migrateUpWithRetry runs the stage's migrations, retrying failures as configured by stage.Retry.
+// Each attempt gets its own stage timeout and restarts result.StartTime, so the recorded duration
+// is that of the last attempt. Applied versions are kept between attempts: a retry continues at
+// the migration that failed.
+func (o *Orchestrator) migrateUpWithRetry(ctx context.Context, stage *Stage, migrator *apirun.Migrator, result *StageResult) ([]*apirun.ExecWithVersion, error) {
+	attempts := 1
+	var backoff time.Duration
+	if stage.Retry != nil && stage.Retry.MaxAttempts > 1 {
+		attempts = stage.Retry.MaxAttempts
+		backoff = stage.Retry.Backoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		result.StartTime = time.Now()
+		result.Attempts = attempt
+		execResults, err := o.migrateUpAttempt(ctx, stage, migrator)
+		if err == nil {
+			return execResults, nil
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			return execResults, err
+		}
+		o.logger.Warn("stage attempt failed, retrying",
+			"stage", stage.Name,
+			"attempt", attempt,
+			"max_attempts", attempts,
+			"backoff", backoff,
+			"error", err)
+		if backoff > 0 {
+			if sleepErr := contextSleep(ctx, backoff); sleepErr != nil {
+				return execResults, err
+			}
+		}
+	}
+}
+
+// migrateUpAttempt runs the stage's migrations once, bounded by the stage timeout if specified.
+func (o *Orchestrator) migrateUpAttempt(ctx context.Context, stage *Stage, migrator *apirun.Migrator) ([]*apirun.ExecWithVersion, error) {
+	if stage.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, stage.Timeout)
+		defer cancel()
+	}
+	return migrator.MigrateUp(ctx, 0)
+}
+
 // executeStageDown executes a single stage's down migration
 func (o *Orchestrator) executeStageDown(ctx context.Context, stage *Stage) error {
 	// Build environment for this stage (simplified for down migrations)
@@ -268,17 +346,18 @@ func (o *Orchestrator) executeStageDown(ctx context.Context, stage *Stage) error
 	}
 
 	// Load stage configuration
-	config, err := o.loadStageConfig(stage.ConfigPath)
+	config, err := o.stageConfig(stage, o.pathEnvForDown(stage, stageEnv))
 	if err != nil {
 		return fmt.Errorf("failed to load config for stage %s: %w", stage.Name, err)
 	}
 
 	// Execute down migration
 	migrator := &apirun.Migrator{
-		Dir:         config.MigrateDir,
-		Env:         stageEnv,
-		Auth:        config.Auth,
-		StoreConfig: config.StoreConfig,
+		Dir:            config.MigrateDir,
+		VersionPattern: config.VersionPattern,
+		Env:            stageEnv,
+		Auth:           config.Auth,
+		StoreConfig:    o.stageStoreConfig(stage, config),
 	}
 
 	// Apply stage timeout if specified
@@ -365,3 +444,38 @@ func (o *Orchestrator) buildStageEnvironmentForDown(stage *Stage) (*env.Env, err
 
 	return stageEnv, nil
 }
+
+// pathEnvForDown returns the env templated stage paths are rendered with on down: stageEnv plus
+// the env_from_stages variables recorded for the dependencies in this run or in the state file,
+// so a stage whose directory came from a dependency can still be found.
+func (o *Orchestrator) pathEnvForDown(stage *Stage, stageEnv *env.Env) *env.Env {
+	if len(stage.EnvFromStages) == 0 {
+		return stageEnv
+	}
+	results := o.GetStageResults()
+	o.stateMu.Lock()
+	if o.statePath != "" {
+		if state, err := o.readState(o.statePath); err == nil && state != nil {
+			for name, r := range state.StageResults {
+				if _, ok := results[name]; !ok && r != nil {
+					results[name] = r
+				}
+			}
+		}
+	}
+	o.stateMu.Unlock()
+
+	pathEnv := stageEnv.Clone()
+	for _, envFromStage := range stage.EnvFromStages {
+		r, ok := results[envFromStage.Stage]
+		if !ok {
+			continue
+		}
+		for _, varName := range envFromStage.Vars {
+			if value, ok := r.ExtractedEnv[varName]; ok {
+				_ = pathEnv.SetString("local", varName, value)
+			}
+		}
+	}
+	return pathEnv
+}
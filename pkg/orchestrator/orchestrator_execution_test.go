@@ -2,11 +2,16 @@ package orchestrator
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/loykin/apirun"
 )
 
 func TestOrchestrator_ExecuteStages(t *testing.T) {
@@ -518,3 +523,240 @@ func TestOrchestrator_executeStage_Timeout(t *testing.T) {
 		t.Error("executeStage() with short timeout expected error")
 	}
 }
+
+// makeHTTPStage writes a stage config with a single migration calling url and returns the config path.
+func makeHTTPStage(t *testing.T, root, name, url string) string {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, "migrations"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	mig := fmt.Sprintf("up:\n  name: %s\n  request:\n    method: GET\n    url: %s\n    retry:\n      max_attempts: 1\n  response:\n    result_code: [\"200\"]\n", name, url)
+	if err := os.WriteFile(filepath.Join(dir, "migrations", "001_call.yaml"), []byte(mig), 0644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+	p := filepath.Join(dir, "stage.yaml")
+	if err := os.WriteFile(p, []byte("migrate_dir: ./migrations\n"), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return p
+}
+
+func TestOrchestrator_executeBatch_MaxParallelStages(t *testing.T) {
+	for _, tc := range []struct {
+		limit   int
+		wantMax int64
+	}{
+		{limit: 0, wantMax: 1},
+		{limit: 1, wantMax: 1},
+		{limit: 2, wantMax: 2},
+	} {
+		t.Run(fmt.Sprintf("limit=%d", tc.limit), func(t *testing.T) {
+			var current, maxSeen int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c := atomic.AddInt64(&current, 1)
+				for {
+					old := atomic.LoadInt64(&maxSeen)
+					if c <= old || atomic.CompareAndSwapInt64(&maxSeen, old, c) {
+						break
+					}
+				}
+				time.Sleep(100 * time.Millisecond)
+				atomic.AddInt64(&current, -1)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			root := t.TempDir()
+			var stages []Stage
+			for i := 1; i <= 3; i++ {
+				name := fmt.Sprintf("s%d", i)
+				stages = append(stages, Stage{Name: name, ConfigPath: makeHTTPStage(t, root, name, srv.URL)})
+			}
+			orch := NewOrchestrator(&StageOrchestration{Stages: stages, Global: Global{MaxParallelStages: tc.limit}})
+			if err := orch.initialize(); err != nil {
+				t.Fatalf("initialize: %v", err)
+			}
+			if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+				t.Fatalf("ExecuteStages: %v", err)
+			}
+			if got := atomic.LoadInt64(&maxSeen); got != tc.wantMax {
+				t.Fatalf("expected at most %d concurrent stages, observed %d", tc.wantMax, got)
+			}
+			for name, r := range orch.GetStageResults() {
+				if !r.Success {
+					t.Errorf("stage %s failed: %s", name, r.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_executeBatch_StopFailureCancelsSiblings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	stages := []Stage{
+		{Name: "failing", ConfigPath: makeHTTPStage(t, root, "failing", srv.URL+"/fail"), OnFailure: "stop"},
+		{Name: "slow", ConfigPath: makeHTTPStage(t, root, "slow", srv.URL+"/slow"), OnFailure: "continue"},
+	}
+	orch := NewOrchestrator(&StageOrchestration{Stages: stages, Global: Global{MaxParallelStages: 2}})
+	if err := orch.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+
+	start := time.Now()
+	err := orch.ExecuteStages(context.Background(), "", "")
+	if err == nil {
+		t.Fatalf("expected error from failing stage")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("sibling was not cancelled, took %v", elapsed)
+	}
+	results := orch.GetStageResults()
+	if r := results["slow"]; r == nil || r.Success {
+		t.Fatalf("expected slow stage to be cancelled, got %+v", r)
+	}
+}
+
+func TestOrchestrator_ExecuteStages_SharedStoreTablePrefixPerStage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	dbPath := filepath.Join(root, "shared.db")
+	stages := []Stage{
+		{Name: "infra", ConfigPath: makeHTTPStage(t, root, "infra", srv.URL)},
+		{Name: "app-services", ConfigPath: makeHTTPStage(t, root, "app-services", srv.URL), DependsOn: []string{"infra"}},
+	}
+	orch := NewOrchestrator(&StageOrchestration{Stages: stages, Global: Global{
+		Store:               &GlobalStore{Type: "sqlite", SQLite: apirun.SqliteConfig{Path: dbPath}},
+		TablePrefixPerStage: true,
+	}})
+	if err := orch.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("ExecuteStages: %v", err)
+	}
+
+	for _, prefix := range []string{"infra", "app_services"} {
+		names := apirun.TableNames{
+			SchemaMigrations: prefix + "_schema_migrations",
+			MigrationRuns:    prefix + "_migration_log",
+			StoredEnv:        prefix + "_stored_env",
+		}
+		st, err := apirun.OpenStoreFromOptions(root, apirun.NewSqliteStoreConfig(&apirun.SqliteConfig{Path: dbPath}, names))
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		applied, err := st.ListApplied()
+		_ = st.Close()
+		if err != nil || len(applied) != 1 || applied[0] != 1 {
+			t.Fatalf("expected version 1 applied in %s tables, got %v (%v)", prefix, applied, err)
+		}
+	}
+	for _, name := range []string{"infra", "app-services"} {
+		if _, err := os.Stat(filepath.Join(root, name, "migrations", apirun.StoreDBFileName)); !os.IsNotExist(err) {
+			t.Fatalf("stage %s must use the shared store, not a per-directory sqlite file", name)
+		}
+	}
+}
+
+func TestOrchestrator_executeStage_RetryUntilSuccess(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	stage := Stage{Name: "flaky", ConfigPath: makeHTTPStage(t, t.TempDir(), "flaky", srv.URL),
+		Retry: &StageRetry{MaxAttempts: 3, Backoff: 10 * time.Millisecond}}
+	orch := NewOrchestrator(&StageOrchestration{Stages: []Stage{stage}})
+	if err := orch.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("ExecuteStages: %v", err)
+	}
+	r := orch.GetStageResults()["flaky"]
+	if r == nil || !r.Success || r.Attempts != 2 || r.Error != "" {
+		t.Fatalf("expected success on the second attempt, got %+v", r)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected 2 requests, got %d", got)
+	}
+}
+
+func TestOrchestrator_executeStage_RetryExhausted(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	stage := Stage{Name: "broken", ConfigPath: makeHTTPStage(t, t.TempDir(), "broken", srv.URL),
+		OnFailure: "continue", Retry: &StageRetry{MaxAttempts: 3}}
+	orch := NewOrchestrator(&StageOrchestration{Stages: []Stage{stage}})
+	if err := orch.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	// on_failure applies once the attempts are used up
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("on_failure continue must not fail the run: %v", err)
+	}
+	r := orch.GetStageResults()["broken"]
+	if r == nil || r.Success || r.Attempts != 3 || r.Error == "" {
+		t.Fatalf("expected a failure after 3 attempts, got %+v", r)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 requests, got %d", got)
+	}
+}
+
+func TestOrchestrator_executeStage_RetryTimeoutPerAttempt(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// The first attempt times out; the second gets a fresh timeout and succeeds
+	stage := Stage{Name: "slow", ConfigPath: makeHTTPStage(t, t.TempDir(), "slow", srv.URL),
+		Timeout: 1500 * time.Millisecond, Retry: &StageRetry{MaxAttempts: 2}}
+	orch := NewOrchestrator(&StageOrchestration{Stages: []Stage{stage}})
+	if err := orch.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("ExecuteStages: %v", err)
+	}
+	if r := orch.GetStageResults()["slow"]; r == nil || !r.Success || r.Attempts != 2 {
+		t.Fatalf("expected success on the second attempt, got %+v", r)
+	}
+}
@@ -0,0 +1,232 @@
+package orchestrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stageState is the progress of an orchestration persisted between runs. It belongs to the
+// configuration whose hash it carries; state of a changed configuration is ignored.
+type stageState struct {
+	ConfigHash    string                  `yaml:"config_hash"`
+	StageResults  map[string]*StageResult `yaml:"stage_results"`
+	SkippedStages map[string]string       `yaml:"skipped_stages,omitempty"`
+}
+
+// DefaultStatePath returns the state file kept next to a stages config, e.g. ".stages.yaml.state".
+func DefaultStatePath(configPath string) string {
+	clean := filepath.Clean(configPath)
+	return filepath.Join(filepath.Dir(clean), "."+filepath.Base(clean)+".state")
+}
+
+// SetStateFile persists stage results to path as stages finish, so that a later run can Resume.
+// LoadFromFile uses the global state_file of the stages config; "" disables persistence.
+func (o *Orchestrator) SetStateFile(path string) {
+	o.stateMu.Lock()
+	defer o.stateMu.Unlock()
+	o.statePath = path
+}
+
+// StateFile returns the file stage results are persisted to, "" when they are not.
+func (o *Orchestrator) StateFile() string {
+	o.stateMu.Lock()
+	defer o.stateMu.Unlock()
+	return o.statePath
+}
+
+// Resume restores the successful stage results of the previous run from the state file, including
+// their extracted env. ExecuteStages then skips those stages and passes their env to dependents.
+// Failed and skipped stages run again. It returns how many stages were restored; a missing state
+// file or one written for a different configuration restores nothing.
+func (o *Orchestrator) Resume() (int, error) {
+	o.stateMu.Lock()
+	path := o.statePath
+	o.stateMu.Unlock()
+	if path == "" {
+		return 0, fmt.Errorf("resume requires a state file")
+	}
+
+	state, err := o.readState(path)
+	if err != nil || state == nil {
+		return 0, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	restored := 0
+	for name, result := range state.StageResults {
+		if result == nil || !result.Success || o.getStageByName(name) == nil {
+			continue
+		}
+		if result.ExtractedEnv == nil {
+			result.ExtractedEnv = make(map[string]string)
+		}
+		o.context.StageResults[name] = result
+		o.completed[name] = true
+		restored++
+	}
+	o.logger.Info("resuming orchestration", "state_file", path, "completed_stages", restored)
+	return restored, nil
+}
+
+// LoadState restores every stage result of the state file, failed and skipped ones included, so
+// that GetStageResults reports the previous run (as `stages status` does). Unlike Resume it does
+// not make ExecuteStages skip anything. It returns how many results were restored; without a
+// state file, or with one written for a different configuration, it restores nothing.
+func (o *Orchestrator) LoadState() (int, error) {
+	o.stateMu.Lock()
+	path := o.statePath
+	o.stateMu.Unlock()
+	if path == "" {
+		return 0, nil
+	}
+
+	state, err := o.readState(path)
+	if err != nil || state == nil {
+		return 0, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	restored := 0
+	for name, result := range state.StageResults {
+		if result == nil || o.getStageByName(name) == nil {
+			continue
+		}
+		o.context.StageResults[name] = result
+		restored++
+	}
+	return restored, nil
+}
+
+// isCompleted reports whether the stage was restored as successful by Resume.
+func (o *Orchestrator) isCompleted(stageName string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.completed[stageName]
+}
+
+// saveState writes the current stage results to the state file. Failures are logged only:
+// losing the state costs a resume, not the run.
+func (o *Orchestrator) saveState() {
+	o.stateMu.Lock()
+	defer o.stateMu.Unlock()
+	if o.statePath == "" {
+		return
+	}
+	hash, err := o.configHash()
+	if err != nil {
+		o.logger.Warn("failed to save orchestration state", "error", err)
+		return
+	}
+
+	o.mu.RLock()
+	state := stageState{
+		ConfigHash:    hash,
+		StageResults:  make(map[string]*StageResult, len(o.context.StageResults)),
+		SkippedStages: make(map[string]string, len(o.context.SkippedStages)),
+	}
+	for k, v := range o.context.StageResults {
+		copyValue := *v
+		state.StageResults[k] = &copyValue
+	}
+	for k, v := range o.context.SkippedStages {
+		state.SkippedStages[k] = v
+	}
+	o.mu.RUnlock()
+
+	if err := o.writeState(&state); err != nil {
+		o.logger.Warn("failed to save orchestration state", "state_file", o.statePath, "error", err)
+	}
+}
+
+// forgetStage drops a rolled back stage from the state file, so that a resumed run executes it again.
+func (o *Orchestrator) forgetStage(stageName string) {
+	o.mu.Lock()
+	delete(o.context.StageResults, stageName)
+	delete(o.completed, stageName)
+	o.mu.Unlock()
+
+	o.stateMu.Lock()
+	defer o.stateMu.Unlock()
+	if o.statePath == "" {
+		return
+	}
+	state, err := o.readState(o.statePath)
+	if err == nil && state != nil {
+		if _, ok := state.StageResults[stageName]; !ok {
+			return
+		}
+		delete(state.StageResults, stageName)
+		err = o.writeState(state)
+	}
+	if err != nil {
+		o.logger.Warn("failed to update orchestration state", "state_file", o.statePath, "error", err)
+	}
+}
+
+// readState reads the state file at path. It returns nil without error when there is no state
+// for the current configuration.
+func (o *Orchestrator) readState(path string) (*stageState, error) {
+	// #nosec G304 -- the state file path derives from the stages config path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		o.logger.Info("no orchestration state found", "state_file", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orchestration state %s: %w", path, err)
+	}
+	var state stageState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse orchestration state %s: %w", path, err)
+	}
+	hash, err := o.configHash()
+	if err != nil {
+		return nil, err
+	}
+	if state.ConfigHash != hash {
+		o.logger.Info("orchestration state belongs to a different configuration, ignoring it", "state_file", path)
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// writeState replaces the state file atomically. Extracted env may hold secrets, so the file is
+// readable by its owner only (os.CreateTemp creates it with mode 0600).
+func (o *Orchestrator) writeState(state *stageState) error {
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(o.statePath), filepath.Base(o.statePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), o.statePath)
+}
+
+// configHash identifies the orchestration configuration the state belongs to.
+func (o *Orchestrator) configHash() (string, error) {
+	data, err := yaml.Marshal(o.config)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash orchestration config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
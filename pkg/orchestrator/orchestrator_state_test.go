@@ -0,0 +1,218 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// resumeServer creates a database on POST /db and serves GET /app/{id}, failing the latter
+// while failApp is set. It records the paths the app stage called.
+type resumeServer struct {
+	dbCalls  atomic.Int32
+	failApp  atomic.Bool
+	mu       sync.Mutex
+	appPaths []string
+}
+
+func (s *resumeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/db" {
+		s.dbCalls.Add(1)
+		_, _ = w.Write([]byte(`{"id":"db-42"}`))
+		return
+	}
+	s.mu.Lock()
+	s.appPaths = append(s.appPaths, r.URL.Path)
+	s.mu.Unlock()
+	if s.failApp.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeResumeStages writes a two-stage orchestration in root: "app" depends on "db" and uses its db_id.
+func writeResumeStages(t *testing.T, root, url string) string {
+	t.Helper()
+	migs := map[string]string{
+		"db": fmt.Sprintf("up:\n  name: create db\n  request:\n    method: POST\n    url: %s/db\n"+
+			"  response:\n    result_code: [\"200\"]\n    env_from:\n      db_id: id\n", url),
+		"app": fmt.Sprintf("up:\n  name: deploy app\n  request:\n    method: GET\n    url: %[1]s/app/{{.env.db_id}}\n"+
+			"    retry:\n      max_attempts: 1\n  response:\n    result_code: [\"200\"]\n"+
+			"down:\n  name: remove app\n  method: DELETE\n  url: %[1]s/app/{{.env.db_id}}\n", url),
+	}
+	for name, mig := range migs {
+		dir := filepath.Join(root, name, "migrations")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "001_step.yaml"), []byte(mig), 0o600); err != nil {
+			t.Fatalf("write migration: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name, "stage.yaml"), []byte("migrate_dir: ./migrations\n"), 0o600); err != nil {
+			t.Fatalf("write stage config: %v", err)
+		}
+	}
+	cfg := `global:
+  state_file: .stages.yaml.state
+stages:
+  - name: db
+    config_path: db/stage.yaml
+  - name: app
+    config_path: app/stage.yaml
+    depends_on: [db]
+    env_from_stages:
+      - stage: db
+        vars: [db_id]
+`
+	p := filepath.Join(root, "stages.yaml")
+	if err := os.WriteFile(p, []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write stages config: %v", err)
+	}
+	return p
+}
+
+func TestOrchestrator_ResumeAfterFailure(t *testing.T) {
+	srv := &resumeServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	root := t.TempDir()
+	cfgPath := writeResumeStages(t, root, ts.URL)
+
+	// First run: db succeeds, app fails
+	srv.failApp.Store(true)
+	orch, err := LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err == nil {
+		t.Fatalf("expected the app stage to fail")
+	}
+	statePath := filepath.Join(root, ".stages.yaml.state")
+	info, err := os.Stat(statePath)
+	if err != nil {
+		t.Fatalf("expected a state file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("state file must be private, got %v", info.Mode().Perm())
+	}
+
+	// Resumed run: db is skipped and app still gets its db_id
+	srv.failApp.Store(false)
+	orch, err = LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	n, err := orch.Resume()
+	if err != nil || n != 1 {
+		t.Fatalf("Resume = %d, %v; want 1 restored stage", n, err)
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("resumed ExecuteStages: %v", err)
+	}
+	if got := srv.dbCalls.Load(); got != 1 {
+		t.Fatalf("db stage must not run again, got %d calls", got)
+	}
+	if len(srv.appPaths) != 2 || srv.appPaths[1] != "/app/db-42" {
+		t.Fatalf("app must receive the db_id restored from the state, got %v", srv.appPaths)
+	}
+	results := orch.GetStageResults()
+	if !results["db"].Success || results["db"].ExtractedEnv["db_id"] != "db-42" || !results["app"].Success {
+		t.Fatalf("unexpected results: db=%+v app=%+v", results["db"], results["app"])
+	}
+
+	// A second resume finds both stages done
+	orch, _ = LoadFromFile(cfgPath)
+	if n, err := orch.Resume(); err != nil || n != 2 {
+		t.Fatalf("Resume = %d, %v; want 2 restored stages", n, err)
+	}
+
+	// Rolling a stage back drops it from the state
+	orch, _ = LoadFromFile(cfgPath)
+	if err := orch.ExecuteStagesDown(context.Background(), "app", "db"); err != nil {
+		t.Fatalf("ExecuteStagesDown: %v", err)
+	}
+	orch, _ = LoadFromFile(cfgPath)
+	if n, err := orch.Resume(); err != nil || n != 1 {
+		t.Fatalf("Resume after rolling back app = %d, %v; want 1", n, err)
+	}
+}
+
+func TestOrchestrator_ResumeIgnoresStateOfChangedConfig(t *testing.T) {
+	srv := &resumeServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	root := t.TempDir()
+	cfgPath := writeResumeStages(t, root, ts.URL)
+
+	srv.failApp.Store(true)
+	orch, err := LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	_ = orch.ExecuteStages(context.Background(), "", "")
+
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	changed := strings.Replace(string(data), "vars: [db_id]", "vars: [db_id, db_host]", 1)
+	if err := os.WriteFile(cfgPath, []byte(changed), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	orch, err = LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if n, err := orch.Resume(); err != nil || n != 0 {
+		t.Fatalf("Resume = %d, %v; want nothing restored for a changed config", n, err)
+	}
+}
+
+func TestOrchestrator_ResumeWithoutStateFile(t *testing.T) {
+	orch := NewOrchestrator(&StageOrchestration{})
+	if _, err := orch.Resume(); err == nil {
+		t.Fatalf("expected an error without a state file")
+	}
+	orch.SetStateFile(filepath.Join(t.TempDir(), "missing.state"))
+	if n, err := orch.Resume(); err != nil || n != 0 {
+		t.Fatalf("Resume = %d, %v; want nothing restored", n, err)
+	}
+}
+
+func TestLoadFromFile_StateFileIsOptIn(t *testing.T) {
+	srv := &resumeServer{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	root := t.TempDir()
+	cfgPath := writeResumeStages(t, root, ts.URL)
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	withoutState := strings.Replace(string(data), "  state_file: .stages.yaml.state\n", "", 1)
+	if err := os.WriteFile(cfgPath, []byte(withoutState), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	orch, err := LoadFromFile(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if orch.StateFile() != "" {
+		t.Fatalf("StateFile = %q, want none without global.state_file", orch.StateFile())
+	}
+	if err := orch.ExecuteStages(context.Background(), "", ""); err != nil {
+		t.Fatalf("ExecuteStages: %v", err)
+	}
+	if _, err := os.Stat(DefaultStatePath(cfgPath)); !os.IsNotExist(err) {
+		t.Fatalf("expected no state file without opting in, got %v", err)
+	}
+}
@@ -297,3 +297,9 @@ func (o *Orchestrator) GetExecutionPlan(fromStage, toStage string, direction str
 
 	return o.getFilteredBatches(fromStage, toStage)
 }
+
+// GraphDOT renders the stage dependency graph in Graphviz DOT, grouping the stages of each
+// execution batch (stages that run in parallel) on one rank. See DependencyGraph.DOT.
+func (o *Orchestrator) GraphDOT() string {
+	return o.graph.DOT()
+}
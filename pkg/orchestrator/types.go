@@ -2,6 +2,8 @@ package orchestrator
 
 import (
 	"time"
+
+	"github.com/loykin/apirun"
 )
 
 // StageOrchestration represents the top-level configuration for stage orchestration
@@ -10,6 +12,10 @@ type StageOrchestration struct {
 	Kind       string  `yaml:"kind"`
 	Stages     []Stage `yaml:"stages"`
 	Global     Global  `yaml:"global"`
+
+	// baseDir is the directory of the orchestration file; templated config paths are resolved
+	// against it once rendered
+	baseDir string
 }
 
 // Stage represents a single stage in the orchestration
@@ -22,6 +28,16 @@ type Stage struct {
 	Condition     string            `yaml:"condition"`
 	OnFailure     string            `yaml:"on_failure"` // stop, continue, skip_dependents
 	Timeout       time.Duration     `yaml:"timeout"`
+	// Retry re-runs a failed stage before on_failure applies; Timeout bounds each attempt
+	Retry *StageRetry `yaml:"retry"`
+}
+
+// StageRetry configures retries of a failed stage
+type StageRetry struct {
+	// MaxAttempts is the total number of attempts including the first one (<= 1 disables retries)
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff is the delay before each retry
+	Backoff time.Duration `yaml:"backoff"`
 }
 
 // EnvFromStage represents environment variables to inherit from other stages
@@ -34,6 +50,24 @@ type EnvFromStage struct {
 type Global struct {
 	Env               map[string]string `yaml:"env"`
 	WaitBetweenStages time.Duration     `yaml:"wait_between_stages"`
+	// MaxParallelStages limits how many independent stages of a batch run concurrently (<= 1 = sequential)
+	MaxParallelStages int `yaml:"max_parallel_stages"`
+	// Store is used by every stage whose config does not declare its own `store`
+	Store *GlobalStore `yaml:"store"`
+	// TablePrefixPerStage gives each stage on the shared Store its own tables, prefixed with the
+	// stage name (e.g. infra_schema_migrations)
+	TablePrefixPerStage bool `yaml:"table_prefix_per_stage"`
+	// StateFile persists stage results between runs (relative to the orchestration file). Unset,
+	// results are kept in memory only; `stages up --resume` then uses DefaultStatePath.
+	StateFile string `yaml:"state_file"`
+}
+
+// GlobalStore is the store configuration shared by stages
+type GlobalStore struct {
+	Type        string                `yaml:"type"` // sqlite (default) or postgres
+	SQLite      apirun.SqliteConfig   `yaml:"sqlite"`
+	Postgres    apirun.PostgresConfig `yaml:"postgres"`
+	TablePrefix string                `yaml:"table_prefix"`
 }
 
 // StageResult represents the result of executing a stage
@@ -45,6 +79,10 @@ type StageResult struct {
 	StartTime    time.Time         `yaml:"start_time"`
 	EndTime      time.Time         `yaml:"end_time"`
 	Duration     time.Duration     `yaml:"duration"`
+	// Attempts is how many times the stage was run (more than 1 when it was retried)
+	Attempts int `yaml:"attempts,omitempty"`
+	// SkipReason is set when the stage did not run: its condition was false or a dependency failed
+	SkipReason string `yaml:"skip_reason,omitempty"`
 }
 
 // ExecutionContext holds the context for stage execution
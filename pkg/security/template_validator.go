@@ -52,6 +52,9 @@ func NewTemplateValidator() *TemplateValidator {
 		"contains":  true,
 		"hasPrefix": true,
 		"hasSuffix": true,
+		// Secret resolution (OS env / vault resolver and secret files)
+		"secret": true,
+		"file":   true,
 	}
 
 	// Patterns that indicate potential security issues
@@ -94,8 +97,14 @@ func (v *TemplateValidator) ValidateTemplate(templateStr string) error {
 		return err
 	}
 
-	// Parse the template to analyze its structure
-	tree, err := parse.Parse("validator", templateStr, "{{", "}}")
+	// Parse the template to analyze its structure; only allowed functions are known to the parser
+	funcs := make(map[string]any, len(v.AllowedFunctions))
+	for name, ok := range v.AllowedFunctions {
+		if ok {
+			funcs[name] = struct{}{}
+		}
+	}
+	tree, err := parse.Parse("validator", templateStr, "{{", "}}", funcs)
 	if err != nil {
 		// If we can't parse it, it's probably safe from injection but invalid
 		return fmt.Errorf("template parse error: %w", err)
@@ -187,9 +196,18 @@ func (v *TemplateValidator) validateIdentifier(ident string) error {
 	return nil
 }
 
+// builtinFields are template data fields apirun provides itself; they are allowed even when
+// their name contains a blocked pattern.
+var builtinFields = map[string]bool{
+	"run_id": true,
+}
+
 // validateFieldAccess validates field access for dangerous patterns
 func (v *TemplateValidator) validateFieldAccess(fields []string) error {
 	for _, field := range fields {
+		if builtinFields[field] {
+			continue
+		}
 		lower := strings.ToLower(field)
 		// Block access to potentially dangerous fields
 		if strings.Contains(lower, "exec") ||
@@ -29,6 +29,16 @@ func TestTemplateValidator_ValidateTemplate(t *testing.T) {
 			template:    "{{.env.username}}",
 			expectError: false,
 		},
+		{
+			name:        "allowed secret functions",
+			template:    `{{ secret "VAULT_TOKEN" }}:{{ file "/run/secrets/pw" }}:{{ printf "%s" .env.x }}`,
+			expectError: false,
+		},
+		{
+			name:        "unknown function",
+			template:    `{{ shell "id" }}`,
+			expectError: true,
+		},
 		{
 			name:        "dangerous exec pattern",
 			template:    "{{.env.Exec(\"rm -rf /\")}}",
@@ -71,6 +81,11 @@ func TestTemplateValidator_ValidateTemplate(t *testing.T) {
 			expectError: true,
 			errorType:   ErrDangerousAction,
 		},
+		{
+			name:        "run id field",
+			template:    "{{.run_id}}",
+			expectError: false,
+		},
 		{
 			name:        "empty template",
 			template:    "",
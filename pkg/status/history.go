@@ -0,0 +1,84 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/loykin/apirun"
+)
+
+// HistoryFromStore returns the run history matching filter, oldest first. Filtering and the
+// limit are applied by the database, so large histories are not loaded into memory.
+func HistoryFromStore(st *apirun.Store, filter apirun.RunFilter) ([]HistoryItem, error) {
+	if d := filter.Direction; d != "" && d != "up" && d != "down" && d != apirun.DirectionBaseline && d != apirun.DirectionSkipped {
+		return nil, fmt.Errorf("invalid direction %q: must be up, down, baseline or skipped", d)
+	}
+	if filter.Version < 0 || filter.Limit < 0 {
+		return nil, fmt.Errorf("version and limit must not be negative")
+	}
+	runs, err := apirun.ListRunsFiltered(st, filter)
+	if err != nil {
+		return nil, err
+	}
+	return historyItems(runs), nil
+}
+
+func historyItems(runs []apirun.RunHistory) []HistoryItem {
+	items := make([]HistoryItem, 0, len(runs))
+	for _, r := range runs {
+		items = append(items, HistoryItem{
+			ID:         r.ID,
+			Version:    r.Version,
+			Direction:  r.Direction,
+			StatusCode: r.StatusCode,
+			Failed:     r.Failed,
+			RanAt:      r.RanAt,
+			Body:       r.Body,
+			Env:        r.Env,
+			Method:     r.Method,
+			URL:        r.URL,
+			StartedAt:  r.StartedAt,
+			DurationMs: r.DurationMs,
+			Headers:    r.Headers,
+			RunID:      r.RunID,
+		})
+	}
+	return items
+}
+
+// FormatHistoryTable renders history items as an aligned table, in the given order.
+func FormatHistoryTable(items []HistoryItem) string {
+	if len(items) == 0 {
+		return "no runs found\n"
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tVERSION\tDIRECTION\tSTATUS\tFAILED\tDURATION\tRAN AT\tREQUEST")
+	for _, h := range items {
+		duration := "-"
+		if h.DurationMs != nil {
+			duration = fmt.Sprintf("%dms", *h.DurationMs)
+		}
+		request := strings.TrimSpace(h.Method + " " + h.URL)
+		if request == "" {
+			request = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%t\t%s\t%s\t%s\n", h.ID, h.Version, h.Direction, h.StatusCode, h.Failed, duration, h.RanAt, request)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// FormatHistoryJSON renders history items as an indented JSON array ([] when empty).
+func FormatHistoryJSON(items []HistoryItem) (string, error) {
+	if items == nil {
+		items = []HistoryItem{}
+	}
+	out, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
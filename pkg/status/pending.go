@@ -0,0 +1,79 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/loykin/apirun"
+	imig "github.com/loykin/apirun/internal/migration"
+)
+
+// PlanFromStore returns the migrations in dir that an "up" run would apply against st, computed
+// by the migrator itself. Nothing is executed or recorded. A missing directory yields an empty plan.
+// versionPattern is the migrator's VersionPattern ("" = apirun.DefaultVersionPattern).
+func PlanFromStore(ctx context.Context, dir string, st *apirun.Store, versionPattern string) (*apirun.MigrationPlan, error) {
+	im := imig.Migrator{Dir: dir, Store: *st, VersionPattern: versionPattern}
+	plan, err := im.Plan(ctx)
+	if errors.Is(err, fs.ErrNotExist) {
+		cur, cerr := st.CurrentVersion()
+		if cerr != nil {
+			return nil, cerr
+		}
+		return &apirun.MigrationPlan{Direction: "up", CurrentVersion: cur, Migrations: []apirun.PlannedMigration{}}, nil
+	}
+	return plan, err
+}
+
+// DriftFromStore returns the applied versions in dir whose file changed after they were applied.
+// A missing directory yields no drift. versionPattern is as for PlanFromStore.
+func DriftFromStore(ctx context.Context, dir string, st *apirun.Store, versionPattern string) ([]apirun.ChecksumDrift, error) {
+	im := imig.Migrator{Dir: dir, Store: *st, VersionPattern: versionPattern}
+	drifts, err := im.VerifyChecksums(ctx)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []apirun.ChecksumDrift{}, nil
+	}
+	return drifts, err
+}
+
+// DriftedVersions returns the versions of drifts, in order.
+func DriftedVersions(drifts []apirun.ChecksumDrift) []int {
+	out := make([]int, 0, len(drifts))
+	for _, d := range drifts {
+		out = append(out, d.Version)
+	}
+	return out
+}
+
+// FormatDrift lists applied migrations whose file changed since they were applied; it returns
+// an empty string when there are none so that the regular status output is unchanged.
+func FormatDrift(drifts []apirun.ChecksumDrift) string {
+	if len(drifts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "drifted: %d (applied files changed since they were applied)\n", len(drifts))
+	for _, d := range drifts {
+		fmt.Fprintf(&b, "  v=%d file=%s\n", d.Version, d.File)
+	}
+	return b.String()
+}
+
+// FormatPending returns a human-friendly list of the planned migrations.
+func FormatPending(plan *apirun.MigrationPlan) string {
+	if len(plan.Migrations) == 0 {
+		return "pending: none\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "pending: %d\n", len(plan.Migrations))
+	for _, pm := range plan.Migrations {
+		line := fmt.Sprintf("  v=%d file=%s", pm.Version, pm.File)
+		if pm.Name != "" {
+			line += fmt.Sprintf(" name=%q", pm.Name)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
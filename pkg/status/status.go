@@ -1,7 +1,12 @@
 package status
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/loykin/apirun"
 	"github.com/loykin/apirun/internal/common"
@@ -16,21 +21,36 @@ const (
 // RanAt is an RFC3339 timestamp in UTC.
 // Body may be nil when response body saving was disabled.
 // Env contains stored environment variables snapshot when available.
+// Method, URL, StartedAt and DurationMs describe the executed request when recorded.
+// Headers holds the response headers saved with the run, if any, and RunID the run that recorded it.
 type HistoryItem struct {
-	ID         int
-	Version    int
-	Direction  string
-	StatusCode int
-	Failed     bool
-	RanAt      string
-	Body       *string
-	Env        map[string]string
+	ID         int               `json:"id"`
+	Version    int               `json:"version"`
+	Direction  string            `json:"direction"`
+	StatusCode int               `json:"status_code"`
+	Failed     bool              `json:"failed"`
+	RanAt      string            `json:"ran_at"`
+	Body       *string           `json:"body,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Method     string            `json:"method,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	StartedAt  string            `json:"started_at,omitempty"`
+	DurationMs *int64            `json:"duration_ms,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	RunID      string            `json:"run_id,omitempty"`
 }
 
-// Info aggregates status information: current version, applied list, and run history.
+// Info aggregates status information: current version, applied list, pending and drifted
+// versions (only known when the migration directory was scanned), and run history.
 type Info struct {
 	Version int
 	Applied []int
+	// AppliedAt holds when each applied version was applied; versions applied before the
+	// time was recorded are absent.
+	AppliedAt map[int]time.Time
+	Pending   []int
+	// Drifted lists applied versions whose file changed after they were applied.
+	Drifted []int
 	History []HistoryItem
 }
 
@@ -40,38 +60,113 @@ func FromStore(st *apirun.Store) (Info, error) {
 	if err != nil {
 		return Info{}, err
 	}
-	applied, err := st.ListApplied()
+	appliedInfo, err := st.ListAppliedWithTime()
 	if err != nil {
 		return Info{}, err
 	}
+	var applied []int
+	appliedAt := map[int]time.Time{}
+	for _, ai := range appliedInfo {
+		applied = append(applied, ai.Version)
+		if !ai.AppliedAt.IsZero() {
+			appliedAt[ai.Version] = ai.AppliedAt
+		}
+	}
 	runs, err := apirun.ListRuns(st)
 	if err != nil {
 		return Info{}, err
 	}
-	items := make([]HistoryItem, 0, len(runs))
-	for _, r := range runs {
-		items = append(items, HistoryItem{
-			ID:         r.ID,
-			Version:    r.Version,
-			Direction:  r.Direction,
-			StatusCode: r.StatusCode,
-			Failed:     r.Failed,
-			RanAt:      r.RanAt,
-			Body:       r.Body,
-			Env:        r.Env,
-		})
-	}
-	return Info{Version: cur, Applied: applied, History: items}, nil
+	return Info{Version: cur, Applied: applied, AppliedAt: appliedAt, History: historyItems(runs)}, nil
 }
 
 // FromOptions opens a store using the provided options, collects status, and closes it.
+// Pending versions are those an "up" run would apply (see PlanFromStore).
 func FromOptions(dir string, cfg *apirun.StoreConfig) (Info, error) {
 	st, err := apirun.OpenStoreFromOptions(dir, cfg)
 	if err != nil {
 		return Info{}, err
 	}
 	defer func() { _ = st.Close() }()
-	return FromStore(st)
+	info, err := FromStore(st)
+	if err != nil {
+		return Info{}, err
+	}
+	plan, err := PlanFromStore(context.Background(), dir, st, "")
+	if err != nil {
+		return Info{}, err
+	}
+	info.Pending = plan.Versions()
+	drifts, err := DriftFromStore(context.Background(), dir, st, "")
+	if err != nil {
+		return Info{}, err
+	}
+	info.Drifted = DriftedVersions(drifts)
+	return info, nil
+}
+
+// jsonInfo is the machine-readable status document written by FormatJSON.
+type jsonInfo struct {
+	Version int   `json:"version"`
+	Applied []int `json:"applied"`
+	Pending []int `json:"pending"`
+	Drifted []int `json:"drifted,omitempty"`
+	// AppliedAt maps applied versions to RFC3339 UTC timestamps, when recorded.
+	AppliedAt map[int]string `json:"applied_at,omitempty"`
+	// History is a pointer so a requested but empty history is still emitted as [].
+	History *[]HistoryItem `json:"history,omitempty"`
+}
+
+// FormatJSON returns the status as indented JSON for scripting and CI gating.
+// Version lists are ascending and history is ordered by run id (oldest first), so the
+// output is stable for diffing. history=true includes the run history array.
+func (i Info) FormatJSON(history bool) (string, error) {
+	doc := jsonInfo{Version: i.Version, Applied: sortedCopy(i.Applied), Pending: sortedCopy(i.Pending)}
+	if len(i.Drifted) > 0 {
+		doc.Drifted = sortedCopy(i.Drifted)
+	}
+	if len(i.AppliedAt) > 0 {
+		doc.AppliedAt = make(map[int]string, len(i.AppliedAt))
+		for v, at := range i.AppliedAt {
+			doc.AppliedAt[v] = at.UTC().Format(time.RFC3339)
+		}
+	}
+	if history {
+		items := make([]HistoryItem, len(i.History))
+		copy(items, i.History)
+		sort.SliceStable(items, func(a, b int) bool { return items[a].ID < items[b].ID })
+		doc.History = &items
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// sortedCopy returns an ascending copy of vs; nil becomes an empty slice so JSON shows [].
+func sortedCopy(vs []int) []int {
+	out := make([]int, len(vs))
+	copy(out, vs)
+	sort.Ints(out)
+	return out
+}
+
+// FormatApplied lists the applied versions with the time each was applied, ascending.
+// Versions applied before the time was recorded show "at=unknown".
+func (i Info) FormatApplied() string {
+	if len(i.Applied) == 0 {
+		return "applied at: none\n"
+	}
+	var b strings.Builder
+	b.WriteString("applied at:\n")
+	for _, v := range sortedCopy(i.Applied) {
+		at := "unknown"
+		if t, ok := i.AppliedAt[v]; ok {
+			at = t.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "  v=%d at=%s\n", v, at)
+	}
+	return b.String()
 }
 
 // FormatHuman returns a human-friendly multiline string for CLI output.
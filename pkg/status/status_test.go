@@ -1,10 +1,14 @@
 package status
 
 import (
+	"encoding/json"
 	"net/http"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/loykin/apirun"
 )
@@ -32,6 +36,39 @@ func TestFormatHuman_NoHistory(t *testing.T) {
 	}
 }
 
+func TestFromStore_AppliedAt(t *testing.T) {
+	st := openTempStoreForStatus(t)
+	defer func() { _ = st.Close() }()
+	if err := st.Apply(1); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	info, err := FromStore(st)
+	if err != nil {
+		t.Fatalf("FromStore: %v", err)
+	}
+	if !reflect.DeepEqual(info.Applied, []int{1}) || info.AppliedAt[1].IsZero() {
+		t.Fatalf("expected version 1 with an applied time, got %v %v", info.Applied, info.AppliedAt)
+	}
+}
+
+func TestFormatApplied(t *testing.T) {
+	i := Info{Applied: []int{3, 1}, AppliedAt: map[int]time.Time{3: time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)}}
+	want := "applied at:\n  v=1 at=unknown\n  v=3 at=2025-02-01T10:00:00Z\n"
+	if got := i.FormatApplied(); got != want {
+		t.Fatalf("FormatApplied mismatch\nwant: %q\n got: %q", want, got)
+	}
+	if got := (Info{}).FormatApplied(); got != "applied at: none\n" {
+		t.Fatalf("unexpected output without applied versions: %q", got)
+	}
+	out, err := i.FormatJSON(false)
+	if err != nil {
+		t.Fatalf("FormatJSON: %v", err)
+	}
+	if !strings.Contains(out, "\"applied_at\": {\n    \"3\": \"2025-02-01T10:00:00Z\"\n  }") {
+		t.Fatalf("expected applied_at in JSON, got:\n%s", out)
+	}
+}
+
 func TestFormatHumanWithLimit_NewestFirstAndLimit(t *testing.T) {
 	i := Info{
 		Version: 5,
@@ -134,3 +171,44 @@ func TestFromOptions_DefaultSqlite(t *testing.T) {
 		t.Fatalf("unexpected initial status: %+v", info)
 	}
 }
+
+func TestFormatJSON_StableOrderingAndHistory(t *testing.T) {
+	i := Info{
+		Version: 3,
+		Applied: []int{3, 1},
+		History: []HistoryItem{
+			{ID: 2, Version: 3, Direction: "up", StatusCode: http.StatusOK, RanAt: "t2", Env: map[string]string{"b": "2", "a": "1"}},
+			{ID: 1, Version: 1, Direction: "up", StatusCode: http.StatusOK, RanAt: "t1"},
+		},
+	}
+	noHistory, err := i.FormatJSON(false)
+	if err != nil {
+		t.Fatalf("FormatJSON: %v", err)
+	}
+	if noHistory != "{\n  \"version\": 3,\n  \"applied\": [\n    1,\n    3\n  ],\n  \"pending\": []\n}\n" {
+		t.Fatalf("unexpected JSON without history:\n%s", noHistory)
+	}
+
+	withHistory, err := i.FormatJSON(true)
+	if err != nil {
+		t.Fatalf("FormatJSON: %v", err)
+	}
+	var doc struct {
+		History []HistoryItem `json:"history"`
+	}
+	if err := json.Unmarshal([]byte(withHistory), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(doc.History) != 2 || doc.History[0].ID != 1 || doc.History[1].ID != 2 {
+		t.Fatalf("expected history ordered by id, got %+v", doc.History)
+	}
+	again, _ := i.FormatJSON(true)
+	if again != withHistory {
+		t.Fatalf("expected identical output across calls")
+	}
+
+	empty, _ := Info{}.FormatJSON(true)
+	if !strings.Contains(empty, `"history": []`) {
+		t.Fatalf("expected empty history array, got:\n%s", empty)
+	}
+}
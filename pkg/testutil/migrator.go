@@ -0,0 +1,80 @@
+// Package testutil helps testing migrations from Go tests: it writes migration files to a
+// temporary directory, wires them to an in-memory store and serves the requests they send.
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loykin/apirun"
+	"github.com/loykin/apirun/pkg/env"
+)
+
+// memorySeq numbers the in-memory databases of NewTestMigrator.
+var memorySeq atomic.Int64
+
+// NewTestMigrator writes migrations (file name, e.g. "001_create.yaml", to content) to a new
+// temporary directory and returns a Migrator for it backed by an in-memory SQLite store, with
+// that store opened for assertions. The Migrator has an empty Env and no delay between
+// migrations; the store is closed when the test ends.
+func NewTestMigrator(t testing.TB, migrations map[string]string) (*apirun.Migrator, *apirun.Store) {
+	t.Helper()
+	dir := WriteMigrations(t, migrations)
+	// Both connections use the same named in-memory database, kept alive by st
+	name := fmt.Sprintf("testutil-%d", memorySeq.Add(1))
+	storeCfg := apirun.NewSqliteStoreConfig(&apirun.SqliteConfig{Memory: true, Path: name}, apirun.TableNames{})
+	st, err := apirun.OpenStoreFromOptions(dir, storeCfg)
+	if err != nil {
+		t.Fatalf("testutil: open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	m := &apirun.Migrator{
+		Dir:                    dir,
+		Env:                    env.New(),
+		StoreConfig:            storeCfg,
+		DelayBetweenMigrations: time.Nanosecond,
+	}
+	return m, st
+}
+
+// WriteMigrations writes files (name to content) to a new temporary directory and returns it.
+// Names may contain subdirectories.
+func WriteMigrations(t testing.TB, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("testutil: create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("testutil: write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// RequireApplied fails the test unless exactly the versions want are applied in st.
+func RequireApplied(t testing.TB, st *apirun.Store, want ...int) {
+	t.Helper()
+	got, err := st.ListApplied()
+	if err != nil {
+		t.Fatalf("testutil: list applied: %v", err)
+	}
+	if want == nil {
+		want = []int{}
+	}
+	if got == nil {
+		got = []int{}
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("applied versions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package testutil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Response is a canned answer of a MockServer.
+type Response struct {
+	// Status is the status code (0 = 200).
+	Status int
+	// Header is set on the response.
+	Header map[string]string
+	// Body is written as is; it is served as JSON unless Header sets Content-Type.
+	Body string
+}
+
+// RecordedRequest is a request received by a MockServer.
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+// MockServer is an HTTP test server that records every request it receives and answers those
+// matching a route registered with Handle or HandleFunc; others get 404 Not Found.
+type MockServer struct {
+	// URL is the base URL of the server, e.g. "http://127.0.0.1:1234".
+	URL string
+
+	srv      *httptest.Server
+	mu       sync.Mutex
+	routes   map[string]http.HandlerFunc
+	requests []RecordedRequest
+}
+
+// NewMockServer starts a MockServer that is closed when the test ends.
+func NewMockServer(t testing.TB) *MockServer {
+	t.Helper()
+	s := &MockServer{routes: map[string]http.HandlerFunc{}}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.URL = s.srv.URL
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// Handle answers requests for method and path with resp. An empty method matches any method;
+// registering the same route again replaces it.
+func (s *MockServer) Handle(method, path string, resp Response) {
+	s.HandleFunc(method, path, func(w http.ResponseWriter, _ *http.Request) {
+		if _, ok := resp.Header["Content-Type"]; !ok {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		for k, v := range resp.Header {
+			w.Header().Set(k, v)
+		}
+		status := resp.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, resp.Body)
+	})
+}
+
+// HandleFunc answers requests for method and path with fn, like Handle. The request body has
+// already been read; it is available again from r.Body.
+func (s *MockServer) HandleFunc(method, path string, fn http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[routeKey(method, path)] = fn
+}
+
+// Requests returns the requests received so far, in order of arrival.
+func (s *MockServer) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// RequestsTo returns the requests received so far for method and path (any method when empty).
+func (s *MockServer) RequestsTo(method, path string) []RecordedRequest {
+	var out []RecordedRequest
+	for _, r := range s.Requests() {
+		if (method == "" || strings.EqualFold(r.Method, method)) && r.Path == path {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Reset forgets the recorded requests; routes are kept.
+func (s *MockServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *MockServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, RecordedRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   string(body),
+	})
+	fn, ok := s.routes[routeKey(r.Method, r.URL.Path)]
+	if !ok {
+		fn, ok = s.routes[routeKey("", r.URL.Path)]
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fn(w, r)
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
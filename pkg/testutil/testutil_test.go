@@ -0,0 +1,160 @@
+package testutil
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewTestMigrator_UpAndDown(t *testing.T) {
+	srv := NewMockServer(t)
+	srv.Handle(http.MethodPost, "/users", Response{Status: http.StatusCreated, Body: `{"id":"42"}`})
+	srv.Handle(http.MethodDelete, "/users/42", Response{Status: http.StatusNoContent})
+
+	m, st := NewTestMigrator(t, map[string]string{
+		"001_create_user.yaml": `
+up:
+  name: create user
+  request:
+    method: POST
+    url: "{{.env.base_url}}/users"
+    headers:
+      - name: X-Test
+        value: "yes"
+    body: '{"name":"alice"}'
+  response:
+    result_code: ["201"]
+    env_from:
+      user_id: id
+down:
+  name: delete user
+  method: DELETE
+  url: "{{.env.base_url}}/users/{{.env.user_id}}"
+`,
+	})
+	if err := m.Env.SetString("global", "base_url", srv.URL); err != nil {
+		t.Fatalf("set base_url: %v", err)
+	}
+
+	if _, err := m.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	RequireApplied(t, st, 1)
+	reqs := srv.RequestsTo(http.MethodPost, "/users")
+	if len(reqs) != 1 {
+		t.Fatalf("POST /users requests = %d, want 1", len(reqs))
+	}
+	if reqs[0].Body != `{"name":"alice"}` || reqs[0].Header.Get("X-Test") != "yes" {
+		t.Fatalf("unexpected recorded request: %+v", reqs[0])
+	}
+	if vals, err := st.LoadStoredEnv(1); err != nil || vals["user_id"] != "42" {
+		t.Fatalf("stored env = %v, %v; want user_id=42", vals, err)
+	}
+
+	if _, err := m.MigrateDown(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	RequireApplied(t, st)
+	if got := len(srv.RequestsTo(http.MethodDelete, "/users/42")); got != 1 {
+		t.Fatalf("DELETE /users/42 requests = %d, want 1", got)
+	}
+}
+
+func TestNewTestMigrator_IsolatedStores(t *testing.T) {
+	srv := NewMockServer(t)
+	srv.Handle("", "/ok", Response{})
+	files := map[string]string{
+		"001_ok.yaml": "up:\n  request:\n    method: GET\n    url: \"{{.env.base_url}}/ok\"\n  response:\n    result_code: [\"200\"]\n",
+	}
+
+	m1, st1 := NewTestMigrator(t, files)
+	_, st2 := NewTestMigrator(t, files)
+	_ = m1.Env.SetString("global", "base_url", srv.URL)
+	if _, err := m1.MigrateUp(context.Background(), 0); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	RequireApplied(t, st1, 1)
+	RequireApplied(t, st2)
+}
+
+func TestWriteMigrations_Subdirectories(t *testing.T) {
+	dir := WriteMigrations(t, map[string]string{"bodies/user.json": `{"name":"bob"}`})
+	data, err := os.ReadFile(filepath.Join(dir, "bodies", "user.json"))
+	if err != nil || string(data) != `{"name":"bob"}` {
+		t.Fatalf("read written file: %q, %v", data, err)
+	}
+}
+
+func TestMockServer_RecordsAndRoutes(t *testing.T) {
+	srv := NewMockServer(t)
+	srv.Handle(http.MethodGet, "/items", Response{Status: http.StatusAccepted, Header: map[string]string{"X-Req": "1"}, Body: `[]`})
+	srv.HandleFunc("", "/echo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	resp, err := http.Get(srv.URL + "/items?page=2")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("X-Req") != "1" || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	resp, err = http.Post(srv.URL+"/echo", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Fatalf("status = %d, want 418", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/items", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unrouted status = %d, want 404", resp.StatusCode)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("requests = %d, want 3", len(reqs))
+	}
+	if reqs[0].Query.Get("page") != "2" || reqs[1].Body != "hello" || reqs[1].Method != http.MethodPost {
+		t.Fatalf("unexpected recorded requests: %+v", reqs)
+	}
+	if got := len(srv.RequestsTo("", "/items")); got != 2 {
+		t.Fatalf("requests to /items = %d, want 2", got)
+	}
+
+	srv.Reset()
+	if got := len(srv.Requests()); got != 0 {
+		t.Fatalf("requests after Reset = %d, want 0", got)
+	}
+}
+
+func TestMockServer_Concurrent(t *testing.T) {
+	srv := NewMockServer(t)
+	srv.Handle("", "/x", Response{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := http.Get(srv.URL + "/x"); err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(srv.RequestsTo(http.MethodGet, "/x")); got != 10 {
+		t.Fatalf("requests = %d, want 10", got)
+	}
+}